@@ -0,0 +1,169 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"strings"
+)
+
+// snowflakeDomains are the top-level domains of the Snowflake hosts, e.g., snowflakecomputing.cn in China.
+var snowflakeDomains = []string{defaultDomain, ".snowflakecomputing.cn"}
+
+// privateLinkSuffix follows the region in the hosts of AWS PrivateLink, Azure Private Link and Google Cloud Private
+// Service Connect, e.g., xy12345.us-east-1.privatelink.snowflakecomputing.com.
+const privateLinkSuffix = ".privatelink"
+
+// hostDomain returns the Snowflake domain the host ends with, or an empty string if the host is a custom DNS name.
+func hostDomain(host string) string {
+	host = strings.ToLower(host)
+	for _, d := range snowflakeDomains {
+		if strings.HasSuffix(host, d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// isPrivateLinkHost returns true if the host is a private connectivity endpoint of Snowflake.
+func isPrivateLinkHost(host string) bool {
+	host = strings.ToLower(host)
+	return strings.HasSuffix(strings.TrimSuffix(host, hostDomain(host)), privateLinkSuffix)
+}
+
+// defaultRegion is the region of the account locators whose hosts have no region.
+const defaultRegion = "us-west-2"
+
+// regionClouds are the clouds of the regions whose hosts include the cloud, e.g., xy12345.us-east-2.aws. The
+// hosts in the other regions of AWS, e.g., xy12345.us-east-1, have no cloud.
+var regionClouds = map[string]string{
+	"us-east-2":         "aws",
+	"us-gov-west-1":     "aws",
+	"ca-central-1":      "aws",
+	"sa-east-1":         "aws",
+	"eu-west-2":         "aws",
+	"eu-west-3":         "aws",
+	"eu-north-1":        "aws",
+	"eu-central-2":      "aws",
+	"ap-northeast-1":    "aws",
+	"ap-northeast-2":    "aws",
+	"ap-northeast-3":    "aws",
+	"ap-south-1":        "aws",
+	"ap-southeast-3":    "aws",
+	"us-central1":       "gcp",
+	"us-east4":          "gcp",
+	"europe-west2":      "gcp",
+	"europe-west3":      "gcp",
+	"europe-west4":      "gcp",
+	"me-central2":       "gcp",
+	"west-us-2":         "azure",
+	"central-us":        "azure",
+	"south-central-us":  "azure",
+	"east-us-2":         "azure",
+	"us-gov-virginia":   "azure",
+	"canada-central":    "azure",
+	"uk-south":          "azure",
+	"north-europe":      "azure",
+	"west-europe":       "azure",
+	"switzerland-north": "azure",
+	"uae-north":         "azure",
+	"central-india":     "azure",
+	"japan-east":        "azure",
+	"southeast-asia":    "azure",
+	"australia-east":    "azure",
+}
+
+// regionHost returns the region in the host of an account locator in the region, i.e., the region followed by
+// the cloud if required, or an empty string for the default region. The region may include the cloud, and may be
+// followed by .privatelink, in which case the default region is not omitted.
+func regionHost(region string) string {
+	region = strings.ToLower(region)
+	if strings.HasSuffix(region, privateLinkSuffix) {
+		r := regionHost(strings.TrimSuffix(region, privateLinkSuffix))
+		if r == "" {
+			r = defaultRegion
+		}
+		return r + privateLinkSuffix
+	}
+	if region == defaultRegion || region == defaultRegion+".aws" {
+		return ""
+	}
+	if cloud, ok := regionClouds[region]; ok {
+		return region + "." + cloud
+	}
+	return region
+}
+
+// isOrganizationAccount returns true if the account identifier is <organization>-<account name> rather than an
+// account locator. The account names are global, so the hosts have no region.
+func isOrganizationAccount(account string) bool {
+	return strings.ContainsAny(account, "-_")
+}
+
+// accountHost returns the host of the account identifier, i.e., <organization>-<account name> or
+// <locator>[.<region>[.<cloud>]]. The underscores of an account name are replaced with hyphens, which are valid in
+// host names.
+func accountHost(account string) string {
+	label, region := account, ""
+	if posDot := strings.Index(account, "."); posDot > 0 {
+		label, region = account[:posDot], account[posDot+1:]
+	}
+	if isOrganizationAccount(label) {
+		label = strings.Replace(label, "_", "-", -1)
+	}
+	if region = regionHost(region); region != "" {
+		label += "." + region
+	}
+	return label + defaultDomain
+}
+
+// ConfigHost returns the host the connections of the config are made to, i.e., Config.Host if set, otherwise the
+// host of the account and region, e.g., to call the Snowflake REST APIs with the same config as the connections.
+// It returns an error if the region doesn't match the host. The config isn't changed.
+func ConfigHost(cfg *Config) (string, error) {
+	c := *cfg
+	fillHost(&c)
+	c.Region = strings.Trim(c.Region, " ")
+	if c.Region != "" {
+		if err := fillRegionHost(&c); err != nil {
+			return "", err
+		}
+	}
+	return c.Host, nil
+}
+
+// fillRegionHost adds the region to the host of the account if the host has no region. It returns an error if the
+// host is in another region or of an organization account.
+func fillRegionHost(cfg *Config) error {
+	domain := hostDomain(cfg.Host)
+	if domain == "" || len(cfg.Host) == len(domain) {
+		return nil
+	}
+	prefix := cfg.Host[:len(cfg.Host)-len(domain)]
+	region := regionHost(cfg.Region)
+	posDot := strings.Index(prefix, ".")
+	if posDot < 0 {
+		if region == "" {
+			return nil
+		}
+		if isOrganizationAccount(prefix) {
+			return regionMismatchError(cfg)
+		}
+		cfg.Host = prefix + "." + region + domain
+		return nil
+	}
+	hostRegion := strings.ToLower(prefix[posDot+1:])
+	for _, r := range []string{region, strings.ToLower(cfg.Region)} {
+		if r != "" && (hostRegion == r || strings.HasPrefix(hostRegion, r+".")) {
+			return nil
+		}
+	}
+	return regionMismatchError(cfg)
+}
+
+func regionMismatchError(cfg *Config) error {
+	return &SnowflakeError{
+		Number:      ErrCodeRegionMismatch,
+		Message:     errMsgRegionMismatch,
+		MessageArgs: []interface{}{cfg.Region, cfg.Host},
+	}
+}
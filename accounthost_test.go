@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"testing"
+)
+
+func TestParseDSNAccountHost(t *testing.T) {
+	testcases := []struct {
+		dsn     string
+		account string
+		region  string
+		host    string
+		err     int
+	}{
+		{dsn: "u:p@xy12345", account: "xy12345", host: "xy12345.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-east-1", account: "xy12345", region: "us-east-1", host: "xy12345.us-east-1.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-east-2", account: "xy12345", region: "us-east-2", host: "xy12345.us-east-2.aws.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-east-2.aws", account: "xy12345", region: "us-east-2.aws", host: "xy12345.us-east-2.aws.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.east-us-2.azure", account: "xy12345", region: "east-us-2.azure", host: "xy12345.east-us-2.azure.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-central1.gcp", account: "xy12345", region: "us-central1.gcp", host: "xy12345.us-central1.gcp.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345?region=east-us-2", account: "xy12345", region: "east-us-2", host: "xy12345.east-us-2.azure.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345?region=us-central1.gcp", account: "xy12345", region: "us-central1.gcp", host: "xy12345.us-central1.gcp.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345?region=us-west-2", account: "xy12345", region: "us-west-2", host: "xy12345.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.east-us-2.azure?region=east-us-2", account: "xy12345", region: "east-us-2", host: "xy12345.east-us-2.azure.snowflakecomputing.com"},
+		{dsn: "u:p@myorg-my_account", account: "myorg-my_account", host: "myorg-my-account.snowflakecomputing.com"},
+		{dsn: "u:p@/db?account=xy12345.eu-west-1", account: "xy12345", region: "eu-west-1", host: "xy12345.eu-west-1.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-east-1.privatelink", account: "xy12345", region: "us-east-1.privatelink", host: "xy12345.us-east-1.privatelink.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-east-2.privatelink", account: "xy12345", region: "us-east-2.privatelink", host: "xy12345.us-east-2.aws.privatelink.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-west-2.privatelink", account: "xy12345", region: "us-west-2.privatelink", host: "xy12345.us-west-2.privatelink.snowflakecomputing.com"},
+		{dsn: "u:p@myorg-myaccount.privatelink", account: "myorg-myaccount", region: "privatelink", host: "myorg-myaccount.privatelink.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.snowflakecomputing.cn", account: "xy12345", host: "xy12345.snowflakecomputing.cn"},
+		{dsn: "u:p@xy12345.snowflakecomputing.cn:443?region=cn-northwest-1", account: "xy12345", region: "cn-northwest-1", host: "xy12345.cn-northwest-1.snowflakecomputing.cn"},
+		{dsn: "u:p@xy12345.us-east-1.privatelink.snowflakecomputing.com:443?region=us-east-1", account: "xy12345", region: "us-east-1", host: "xy12345.us-east-1.privatelink.snowflakecomputing.com"},
+		{dsn: "u:p@xy12345.us-east-1?region=eu-west-1", err: ErrCodeRegionMismatch},
+		{dsn: "u:p@xy12345.us-east-2.aws?region=us-west-2", err: ErrCodeRegionMismatch},
+		{dsn: "u:p@myorg-myaccount?region=us-east-1", err: ErrCodeRegionMismatch},
+	}
+	for _, test := range testcases {
+		cfg, err := ParseDSN(test.dsn)
+		if test.err != 0 {
+			if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != test.err {
+				t.Errorf("should have failed to parse the DSN. dsn: %v, err: %v", test.dsn, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to parse the DSN. dsn: %v, err: %v", test.dsn, err)
+			continue
+		}
+		if cfg.Account != test.account || cfg.Region != test.region || cfg.Host != test.host {
+			t.Errorf("failed to set the host. dsn: %v, expected: %v/%v/%v, got: %v/%v/%v", test.dsn,
+				test.account, test.region, test.host, cfg.Account, cfg.Region, cfg.Host)
+		}
+	}
+}
+
+func TestConfigAccountHost(t *testing.T) {
+	testcases := []struct {
+		cfg  Config
+		host string
+		err  int
+	}{
+		{cfg: Config{Account: "xy12345", Region: "ap-northeast-1"}, host: "xy12345.ap-northeast-1.aws.snowflakecomputing.com"},
+		{cfg: Config{Account: "xy12345.west-europe.azure"}, host: "xy12345.west-europe.azure.snowflakecomputing.com"},
+		{cfg: Config{Account: "myorg-myaccount"}, host: "myorg-myaccount.snowflakecomputing.com"},
+		{cfg: Config{Account: "xy12345", Region: "eu-faraway"}, host: "xy12345.eu-faraway.snowflakecomputing.com"},
+		{cfg: Config{Account: "xy12345", Region: "us-east-1", Host: "xy12345.us-east-1.snowflakecomputing.com"}, host: "xy12345.us-east-1.snowflakecomputing.com"},
+		{cfg: Config{Account: "xy12345.us-east-1", Region: "eu-west-1"}, err: ErrCodeRegionMismatch},
+		{cfg: Config{Account: "myorg-myaccount", Region: "us-east-1"}, err: ErrCodeRegionMismatch},
+	}
+	for _, test := range testcases {
+		host, err := ConfigHost(&test.cfg)
+		if test.err != 0 {
+			if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != test.err {
+				t.Errorf("should have failed to get the host. cfg: %v/%v, err: %v", test.cfg.Account, test.cfg.Region, err)
+			}
+		} else if err != nil || host != test.host {
+			t.Errorf("failed to get the host. cfg: %v/%v, expected: %v, got: %v, err: %v", test.cfg.Account, test.cfg.Region, test.host, host, err)
+		}
+
+		cfg := test.cfg
+		cfg.User, cfg.Password = "u", "p"
+		fillHost(&cfg)
+		err = fillMissingConfigParameters(&cfg)
+		if test.err != 0 {
+			if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != test.err {
+				t.Errorf("should have failed to fill the host. cfg: %v/%v, err: %v", test.cfg.Account, test.cfg.Region, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to fill the host. cfg: %v/%v, err: %v", test.cfg.Account, test.cfg.Region, err)
+			continue
+		}
+		if cfg.Host != test.host {
+			t.Errorf("failed to fill the host. cfg: %v/%v, expected: %v, got: %v", test.cfg.Account, test.cfg.Region, test.host, cfg.Host)
+		}
+	}
+}
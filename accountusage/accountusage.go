@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package accountusage provides typed helpers for common SNOWFLAKE.ACCOUNT_USAGE queries, i.e., warehouse
+// metering, query history and storage usage. The views have latency of up to a few hours and require a role
+// with access to the SNOWFLAKE database, e.g., ACCOUNTADMIN. The time ranges are bound as TIMESTAMP_LTZ, so
+// the zone of the given time.Time values is respected.
+package accountusage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// Queryer is the interface satisfied by *sql.DB, *sql.Conn and *sql.Tx to run queries.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// WarehouseMetering is an hourly credit usage of a warehouse.
+type WarehouseMetering struct {
+	StartTime     time.Time
+	EndTime       time.Time
+	WarehouseName string
+	CreditsUsed   float64
+}
+
+// WarehouseMeteringHistory returns the hourly credit usage of the warehouses between start and end.
+// If warehouse is not empty, only the usage of the warehouse is returned.
+func WarehouseMeteringHistory(ctx context.Context, q Queryer, warehouse string, start, end time.Time) ([]WarehouseMetering, error) {
+	rows, err := q.QueryContext(ctx, `SELECT START_TIME, END_TIME, WAREHOUSE_NAME, CREDITS_USED
+FROM SNOWFLAKE.ACCOUNT_USAGE.WAREHOUSE_METERING_HISTORY
+WHERE START_TIME >= ? AND START_TIME < ? AND (? = '' OR WAREHOUSE_NAME = ?)
+ORDER BY START_TIME, WAREHOUSE_NAME`, sf.DataTypeTimestampLtz, start, end, warehouse, warehouse)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []WarehouseMetering
+	for rows.Next() {
+		var m WarehouseMetering
+		if err = rows.Scan(&m.StartTime, &m.EndTime, &m.WarehouseName, &m.CreditsUsed); err != nil {
+			return nil, err
+		}
+		ret = append(ret, m)
+	}
+	return ret, rows.Err()
+}
+
+// QueryHistory is a query executed in the account.
+type QueryHistory struct {
+	QueryID          string
+	QueryText        string
+	UserName         string
+	WarehouseName    string
+	QueryTag         string
+	ExecutionStatus  string
+	StartTime        time.Time
+	EndTime          time.Time
+	TotalElapsedTime time.Duration
+	BytesScanned     int64
+}
+
+// QueryHistoryByTag returns the queries with the query tag that started between start and end.
+func QueryHistoryByTag(ctx context.Context, q Queryer, tag string, start, end time.Time) ([]QueryHistory, error) {
+	rows, err := q.QueryContext(ctx, `SELECT QUERY_ID, QUERY_TEXT, COALESCE(USER_NAME, ''), COALESCE(WAREHOUSE_NAME, ''),
+  QUERY_TAG, EXECUTION_STATUS, START_TIME, END_TIME, COALESCE(TOTAL_ELAPSED_TIME, 0), COALESCE(BYTES_SCANNED, 0)
+FROM SNOWFLAKE.ACCOUNT_USAGE.QUERY_HISTORY
+WHERE QUERY_TAG = ? AND START_TIME >= ? AND START_TIME < ?
+ORDER BY START_TIME`, tag, sf.DataTypeTimestampLtz, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []QueryHistory
+	for rows.Next() {
+		var h QueryHistory
+		var elapsedMs int64
+		if err = rows.Scan(&h.QueryID, &h.QueryText, &h.UserName, &h.WarehouseName, &h.QueryTag,
+			&h.ExecutionStatus, &h.StartTime, &h.EndTime, &elapsedMs, &h.BytesScanned); err != nil {
+			return nil, err
+		}
+		h.TotalElapsedTime = time.Duration(elapsedMs) * time.Millisecond
+		ret = append(ret, h)
+	}
+	return ret, rows.Err()
+}
+
+// StorageUsage is the daily average storage usage of the account.
+type StorageUsage struct {
+	UsageDate     time.Time
+	StorageBytes  int64
+	StageBytes    int64
+	FailsafeBytes int64
+}
+
+// StorageUsageHistory returns the daily storage usage between start and end.
+func StorageUsageHistory(ctx context.Context, q Queryer, start, end time.Time) ([]StorageUsage, error) {
+	rows, err := q.QueryContext(ctx, `SELECT USAGE_DATE, COALESCE(STORAGE_BYTES, 0), COALESCE(STAGE_BYTES, 0),
+  COALESCE(FAILSAFE_BYTES, 0)
+FROM SNOWFLAKE.ACCOUNT_USAGE.STORAGE_USAGE
+WHERE USAGE_DATE >= ? AND USAGE_DATE < ?
+ORDER BY USAGE_DATE`, sf.DataTypeTimestampLtz, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []StorageUsage
+	for rows.Next() {
+		var u StorageUsage
+		if err = rows.Scan(&u.UsageDate, &u.StorageBytes, &u.StageBytes, &u.FailsafeBytes); err != nil {
+			return nil, err
+		}
+		ret = append(ret, u)
+	}
+	return ret, rows.Err()
+}
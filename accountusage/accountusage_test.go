@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package accountusage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func TestAccountUsage(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	ts := time.Date(2018, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("WAREHOUSE_METERING_HISTORY").WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "START_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "END_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "WAREHOUSE_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "CREDITS_USED", Type: "FIXED", Scale: 9}).
+		AddRow(ts, ts.Add(time.Hour), "WH", "1.5"))
+	mock.ExpectQuery("QUERY_HISTORY").WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "QUERY_ID", Type: "TEXT"},
+		sfmock.Column{Name: "QUERY_TEXT", Type: "TEXT"},
+		sfmock.Column{Name: "USER_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "WAREHOUSE_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "QUERY_TAG", Type: "TEXT"},
+		sfmock.Column{Name: "EXECUTION_STATUS", Type: "TEXT"},
+		sfmock.Column{Name: "START_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "END_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "TOTAL_ELAPSED_TIME", Type: "FIXED"},
+		sfmock.Column{Name: "BYTES_SCANNED", Type: "FIXED"}).
+		AddRow("qid", "SELECT 1", "U", "WH", "etl", "SUCCESS", ts, ts.Add(time.Second), "1500", "1024"))
+	mock.ExpectQuery("STORAGE_USAGE").WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "USAGE_DATE", Type: "DATE"},
+		sfmock.Column{Name: "STORAGE_BYTES", Type: "FIXED"},
+		sfmock.Column{Name: "STAGE_BYTES", Type: "FIXED"},
+		sfmock.Column{Name: "FAILSAFE_BYTES", Type: "FIXED"}).
+		AddRow(ts, "100", "20", "3"))
+
+	ctx := context.Background()
+	ms, err := WarehouseMeteringHistory(ctx, db, "", ts, ts.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get warehouse metering history. err: %v", err)
+	}
+	if len(ms) != 1 || ms[0].WarehouseName != "WH" || ms[0].CreditsUsed != 1.5 {
+		t.Errorf("unexpected warehouse metering history: %v", ms)
+	}
+	hs, err := QueryHistoryByTag(ctx, db, "etl", ts, ts.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get query history. err: %v", err)
+	}
+	if len(hs) != 1 || hs[0].QueryID != "qid" || hs[0].TotalElapsedTime != 1500*time.Millisecond || hs[0].BytesScanned != 1024 {
+		t.Errorf("unexpected query history: %v", hs)
+	}
+	us, err := StorageUsageHistory(ctx, db, ts, ts.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get storage usage. err: %v", err)
+	}
+	if len(us) != 1 || us[0].StorageBytes != 100 || us[0].FailsafeBytes != 3 {
+		t.Errorf("unexpected storage usage: %v", us)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
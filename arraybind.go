@@ -0,0 +1,240 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	sessionArrayBindStageThreshold = "client_stage_array_binding_threshold"
+	// defaultArrayBindStageThreshold is the number of bind values above which the arrays are uploaded to the
+	// stage if the server doesn't return CLIENT_STAGE_ARRAY_BINDING_THRESHOLD.
+	defaultArrayBindStageThreshold = 65280
+	// bindStageName is the temporary stage of the session where the arrays are uploaded.
+	bindStageName = "SYSTEM$BIND"
+)
+
+// Array returns the value binding the elements of the slice, e.g., []int64, []string or []time.Time, to a
+// parameter of a bulk INSERT. The statement is applied to each index of the arrays, which must have the same
+// length, in one request:
+//
+//	_, err = db.Exec("INSERT INTO t VALUES (?, ?)", sf.Array(ids), sf.Array(names))
+//
+// All parameters of the statement must be arrays. Above CLIENT_STAGE_ARRAY_BINDING_THRESHOLD values, the arrays
+// are uploaded to a temporary stage of the session instead of the request.
+func Array(a interface{}) interface{} {
+	return &bindArray{a: a}
+}
+
+// bindArray is the slice given by Array.
+type bindArray struct {
+	a interface{}
+}
+
+// arrayBinding is an array converted to the driver values of a Snowflake type.
+type arrayBinding struct {
+	typ    string
+	tsmode string
+	values []driver.Value
+}
+
+// CheckNamedValue accepts the arrays of Array, the times of TimestampNtz, TimestampLtz and TimestampTz, the
+// spatial values of Geography and Geometry, and the values bound as JSON, and leaves the other values to the default conversion of database/sql.
+func (sc *snowflakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch nv.Value.(type) {
+	case *bindArray, typedTime, spatialText:
+		return nil
+	}
+	if isVariant(nv.Value) {
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func invalidBindArrayError(reason interface{}) error {
+	return &SnowflakeError{
+		Number:      ErrInvalidBindArray,
+		Message:     errMsgInvalidBindArray,
+		MessageArgs: []interface{}{reason},
+	}
+}
+
+// bind converts the elements of the array to the driver values. The type is taken from the non-null elements,
+// which must have the same type.
+func (ba *bindArray) bind(tsmode string) (*arrayBinding, error) {
+	v := reflect.ValueOf(ba.a)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, invalidBindArrayError(fmt.Sprintf("not a slice: %T", ba.a))
+	}
+	b := &arrayBinding{tsmode: tsmode, values: make([]driver.Value, v.Len())}
+	var err error
+	for i := range b.values {
+		var e driver.Value
+		t := ""
+		if tt, ok := v.Index(i).Interface().(typedTime); ok {
+			e, t = tt.t, tt.tsmode
+			b.tsmode = tt.tsmode
+		} else if e, err = driver.DefaultParameterConverter.ConvertValue(v.Index(i).Interface()); err != nil {
+			return nil, invalidBindArrayError(err)
+		}
+		b.values[i] = e
+		if e == nil {
+			continue
+		}
+		if t == "" {
+			t = goTypeToSnowflake(e, tsmode)
+		}
+		if t == "CHANGE_TYPE" {
+			t = "TEXT"
+		}
+		if b.typ != "" && b.typ != t {
+			return nil, invalidBindArrayError(fmt.Sprintf("mixed types %v and %v", b.typ, t))
+		}
+		b.typ = t
+	}
+	if b.typ == "" {
+		b.typ = "TEXT"
+	}
+	return b, nil
+}
+
+// parameter returns the binding of the array in the request.
+func (b *arrayBinding) parameter() (execBindParameter, error) {
+	values := make([]*string, len(b.values))
+	for i, v := range b.values {
+		s, err := valueToString(v, b.tsmode)
+		if err != nil {
+			return execBindParameter{}, err
+		}
+		values[i] = s
+	}
+	return execBindParameter{Type: b.typ, Value: values}, nil
+}
+
+// stageValue returns the value in the text format of the CSV files loaded by Snowflake, or nil for NULL.
+func (b *arrayBinding) stageValue(i int) (*string, error) {
+	tm, ok := b.values[i].(time.Time)
+	if !ok {
+		return valueToString(b.values[i], b.tsmode)
+	}
+	var s string
+	switch b.tsmode {
+	case "DATE":
+		s = tm.Format("2006-01-02")
+	case "TIME":
+		s = tm.Format("15:04:05.999999999")
+	case "TIMESTAMP_LTZ", "TIMESTAMP_TZ":
+		s = tm.Format("2006-01-02 15:04:05.999999999 -07:00")
+	default:
+		s = tm.UTC().Format("2006-01-02 15:04:05.999999999")
+	}
+	return &s, nil
+}
+
+// checkArrayBindings returns an error unless all n parameters are arrays of the same length.
+func checkArrayBindings(n int, arrays []*arrayBinding) error {
+	if len(arrays) != n {
+		return invalidBindArrayError("all parameters must be arrays")
+	}
+	for _, b := range arrays[1:] {
+		if len(b.values) != len(arrays[0].values) {
+			return invalidBindArrayError(fmt.Sprintf("arrays of different lengths %v and %v",
+				len(arrays[0].values), len(b.values)))
+		}
+	}
+	return nil
+}
+
+// arrayBindingsCSV returns the rows of the arrays in CSV. The values other than NULL are enclosed in double
+// quotes so that empty strings are distinguished from NULL.
+func arrayBindingsCSV(arrays []*arrayBinding) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := range arrays[0].values {
+		for j, b := range arrays {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			s, err := b.stageValue(i)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				buf.WriteByte('"')
+				buf.WriteString(strings.Replace(*s, `"`, `""`, -1))
+				buf.WriteByte('"')
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// arrayBindStageThreshold returns CLIENT_STAGE_ARRAY_BINDING_THRESHOLD of the session.
+func (sc *snowflakeConn) arrayBindStageThreshold() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for k, v := range sc.cfg.Params {
+		if strings.EqualFold(k, sessionArrayBindStageThreshold) && v != nil {
+			if n, err := strconv.Atoi(*v); err == nil {
+				return n
+			}
+		}
+	}
+	return defaultArrayBindStageThreshold
+}
+
+// stageArrayBindings uploads the arrays to the bind stage if they have more values than the threshold, and
+// returns the stage location, or an empty string if the arrays are bound in the request. The arrays are bound in
+// the request if the upload fails, e.g., the role can't create the stage.
+func (sc *snowflakeConn) stageArrayBindings(ctx context.Context, arrays []*arrayBinding) string {
+	threshold := sc.arrayBindStageThreshold()
+	if threshold <= 0 || len(arrays)*len(arrays[0].values) <= threshold {
+		return ""
+	}
+	location, err := sc.uploadArrayBindings(ctx, arrays)
+	if err != nil {
+		glog.V(1).Infof("failed to upload the array bindings to the stage. binding them in the request. err: %v", err)
+		return ""
+	}
+	return location
+}
+
+func (sc *snowflakeConn) uploadArrayBindings(ctx context.Context, arrays []*arrayBinding) (string, error) {
+	sc.mu.Lock()
+	created := sc.bindStageCreated
+	sc.mu.Unlock()
+	if !created {
+		query := fmt.Sprintf(`CREATE TEMPORARY STAGE IF NOT EXISTS %v file_format=(type=csv field_optionally_enclosed_by='"')`, bindStageName)
+		if _, err := sc.exec(ctx, query, false, true, nil); err != nil {
+			return "", err
+		}
+		sc.mu.Lock()
+		sc.bindStageCreated = true
+		sc.mu.Unlock()
+	}
+	data, err := arrayBindingsCSV(arrays)
+	if err != nil {
+		return "", err
+	}
+	location := fmt.Sprintf("@%v/%v", bindStageName, uuid.New().String())
+	ctx = WithFileStream(ctx, bytes.NewReader(data))
+	res, err := sc.exec(ctx, fmt.Sprintf("PUT 'file:///tmp/bindings.csv' '%v' overwrite=true", location), false, true, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err = sc.transferFiles(ctx, res); err != nil {
+		return "", err
+	}
+	glog.V(2).Infof("uploaded %v rows of the array bindings to %v", len(arrays[0].values), location)
+	return location, nil
+}
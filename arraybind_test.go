@@ -0,0 +1,188 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnitArrayBind(t *testing.T) {
+	s := "a"
+	testcases := []struct {
+		array  interface{}
+		typ    string
+		values []driver.Value
+	}{
+		{array: []int{1, 2}, typ: "FIXED", values: []driver.Value{int64(1), int64(2)}},
+		{array: []float32{1.5}, typ: "REAL", values: []driver.Value{float64(1.5)}},
+		{array: []bool{true, false}, typ: "BOOLEAN", values: []driver.Value{true, false}},
+		{array: []*string{nil, &s}, typ: "TEXT", values: []driver.Value{nil, "a"}},
+		{array: []interface{}{nil, int64(3)}, typ: "FIXED", values: []driver.Value{nil, int64(3)}},
+		{array: []interface{}{nil}, typ: "TEXT", values: []driver.Value{nil}},
+		{array: [2]string{"x", "y"}, typ: "TEXT", values: []driver.Value{"x", "y"}},
+	}
+	for _, test := range testcases {
+		b, err := Array(test.array).(*bindArray).bind("TIMESTAMP_NTZ")
+		if err != nil {
+			t.Errorf("failed to bind the array. array: %v, err: %v", test.array, err)
+			continue
+		}
+		if b.typ != test.typ || !reflect.DeepEqual(b.values, test.values) {
+			t.Errorf("failed to bind the array. array: %v, expected: %v %v, got: %v %v", test.array, test.typ, test.values, b.typ, b.values)
+		}
+	}
+	tm := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	if b, err := Array([]time.Time{tm}).(*bindArray).bind("TIMESTAMP_LTZ"); err != nil || b.typ != "TIMESTAMP_LTZ" {
+		t.Errorf("failed to bind the time array. err: %v", err)
+	}
+	for _, array := range []interface{}{1, "abc", []interface{}{1, "a"}, []uint64{1 << 63}} {
+		_, err := Array(array).(*bindArray).bind("TIMESTAMP_NTZ")
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidBindArray {
+			t.Errorf("should have failed to bind %v. err: %v", array, err)
+		}
+	}
+}
+
+func TestUnitCheckArrayBindings(t *testing.T) {
+	two := &arrayBinding{values: make([]driver.Value, 2)}
+	three := &arrayBinding{values: make([]driver.Value, 3)}
+	testcases := []struct {
+		n      int
+		arrays []*arrayBinding
+		ok     bool
+	}{
+		{n: 2, arrays: []*arrayBinding{two, two}, ok: true},
+		{n: 2, arrays: []*arrayBinding{two}, ok: false},
+		{n: 2, arrays: []*arrayBinding{two, three}, ok: false},
+	}
+	for _, test := range testcases {
+		if err := checkArrayBindings(test.n, test.arrays); (err == nil) != test.ok {
+			t.Errorf("failed to check the arrays. n: %v, expected: %v, err: %v", test.n, test.ok, err)
+		}
+	}
+}
+
+func TestUnitArrayBindingsCSV(t *testing.T) {
+	loc := time.FixedZone("PST", -8*3600)
+	tm := time.Date(2018, 1, 2, 3, 4, 5, 6000, loc)
+	arrays := []*arrayBinding{
+		{typ: "FIXED", values: []driver.Value{int64(1), nil, int64(3)}},
+		{typ: "TEXT", values: []driver.Value{`say "hi", bye`, "", "line\nbreak"}},
+		{typ: "TIMESTAMP_NTZ", tsmode: "TIMESTAMP_NTZ", values: []driver.Value{tm, tm, nil}},
+		{typ: "TIMESTAMP_TZ", tsmode: "TIMESTAMP_TZ", values: []driver.Value{tm, nil, nil}},
+		{typ: "DATE", tsmode: "DATE", values: []driver.Value{tm, nil, nil}},
+	}
+	b, err := arrayBindingsCSV(arrays)
+	if err != nil {
+		t.Fatalf("failed to write CSV. err: %v", err)
+	}
+	expected := `"1","say ""hi"", bye","2018-01-02 11:04:05.000006","2018-01-02 03:04:05.000006 -08:00","2018-01-02"` + "\n" +
+		`,"","2018-01-02 11:04:05.000006",,` + "\n" +
+		`"3","line` + "\n" + `break",,,` + "\n"
+	if string(b) != expected {
+		t.Errorf("failed to write CSV. expected: %q, got: %q", expected, b)
+	}
+}
+
+func TestArrayBindExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake_bind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var sent []execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		sent = append(sent, req)
+		if strings.HasPrefix(req.SQLText, "PUT ") {
+			return &execResponse{Success: true, Data: execResponseData{
+				Command:      "UPLOAD",
+				SrcLocations: []string{"/tmp/bindings.csv"},
+				AutoCompress: true,
+				StageInfo:    &execResponseStageInfo{LocationType: "LOCAL_FS", Location: dir},
+			}}, nil
+		}
+		return &execResponse{Success: true}, nil
+	}
+	ctx := context.Background()
+	args := []driver.NamedValue{{Ordinal: 1, Value: Array([]int{1, 2})}, {Ordinal: 2, Value: Array([]string{"a", "b"})}}
+	if _, err = sc.ExecContext(ctx, "INSERT INTO t VALUES (?, ?)", args); err != nil {
+		t.Fatalf("failed to insert the arrays. err: %v", err)
+	}
+	if len(sent) != 1 || sent[0].BindStage != "" {
+		t.Fatalf("failed to bind the arrays in the request. sent: %v", sent)
+	}
+	if b := sent[0].Bindings["2"]; b.Type != "TEXT" || !reflect.DeepEqual(b.Value, []interface{}{"a", "b"}) {
+		t.Errorf("failed to bind the array. got: %v", b)
+	}
+
+	threshold := "3"
+	sc.cfg.Params[sessionArrayBindStageThreshold] = &threshold
+	sent = nil
+	for i := 0; i < 2; i++ {
+		if _, err = sc.ExecContext(ctx, "INSERT INTO t VALUES (?, ?)", args); err != nil {
+			t.Fatalf("failed to insert the arrays. err: %v", err)
+		}
+	}
+	if len(sent) != 5 || !strings.HasPrefix(sent[0].SQLText, "CREATE TEMPORARY STAGE") || !strings.HasPrefix(sent[3].SQLText, "PUT ") {
+		t.Fatalf("failed to upload the arrays to the stage once. sent: %v", sent)
+	}
+	insert := sent[2]
+	if !strings.HasPrefix(insert.BindStage, "@SYSTEM$BIND/") || insert.Bindings != nil || !strings.Contains(sent[1].SQLText, insert.BindStage) {
+		t.Errorf("failed to bind the stage. got: %v, %v", insert.BindStage, insert.Bindings)
+	}
+	if got := gunzipFile(t, filepath.Join(dir, "bindings.csv.gz")); got != "\"1\",\"a\"\n\"2\",\"b\"\n" {
+		t.Errorf("failed to upload the arrays. got: %q", got)
+	}
+
+	args = append(args, driver.NamedValue{Ordinal: 3, Value: int64(1)})
+	_, err = sc.ExecContext(ctx, "INSERT INTO t VALUES (?, ?, ?)", args)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidBindArray {
+		t.Errorf("should have failed to bind a scalar with arrays. err: %v", err)
+	}
+	if err = sc.CheckNamedValue(&args[0]); err != nil {
+		t.Errorf("failed to accept the array. err: %v", err)
+	}
+	if err = sc.CheckNamedValue(&args[2]); err != driver.ErrSkip {
+		t.Errorf("should have left the value to the default conversion. err: %v", err)
+	}
+}
+
+func TestArrayBindStageFallback(t *testing.T) {
+	var sent []execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		sent = append(sent, req)
+		if strings.HasPrefix(req.SQLText, "CREATE ") {
+			return &execResponse{Success: false, Code: "3001", Message: "insufficient privileges"}, nil
+		}
+		return &execResponse{Success: true}, nil
+	}
+	threshold := "1"
+	sc.cfg.Params[sessionArrayBindStageThreshold] = &threshold
+	args := []driver.NamedValue{{Ordinal: 1, Value: Array([]int{1, 2})}}
+	if _, err := sc.ExecContext(context.Background(), "INSERT INTO t VALUES (?)", args); err != nil {
+		t.Fatalf("failed to insert the arrays. err: %v", err)
+	}
+	if len(sent) != 2 || sent[1].BindStage != "" || len(sent[1].Bindings) != 1 {
+		t.Errorf("failed to bind the arrays in the request. sent: %v", sent)
+	}
+}
@@ -0,0 +1,878 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The results are fetched in Arrow if Config.ResultFormat or the session parameter is set to Arrow. The chunks in
+// Arrow are decoded into the same values as the JSON chunks are converted to.
+const (
+	queryResultFormatParam = "GO_QUERY_RESULT_FORMAT"
+	queryResultFormatArrow = "arrow"
+)
+
+// ResultFormat is the format the query results are fetched in. The rows are converted to the same Go types in
+// either format.
+type ResultFormat string
+
+const (
+	// ResultFormatJSON fetches the results in JSON. This is the default.
+	ResultFormatJSON ResultFormat = "json"
+	// ResultFormatArrow fetches the results in Apache Arrow, which is decoded with much less CPU and memory than
+	// JSON for large result sets.
+	ResultFormatArrow ResultFormat = "arrow"
+	// ResultFormatVerify fetches the results in Arrow, and verifies each result set against the same result
+	// fetched in JSON by RESULT_SCAN. The first value converted differently fails the query with
+	// ErrResultFormatMismatch. The results are read twice before the rows are returned, so use it to test the
+	// migration to Arrow rather than in production.
+	ResultFormatVerify ResultFormat = "verify"
+)
+
+func parseResultFormat(s string) (ResultFormat, error) {
+	switch f := ResultFormat(s); f {
+	case ResultFormatJSON, ResultFormatArrow, ResultFormatVerify:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid resultFormat: %v. must be json, arrow or verify", s)
+}
+
+// Arrow type IDs of the Type union in Schema.fbs
+const (
+	arrowTypeNull            = 1
+	arrowTypeInt             = 2
+	arrowTypeFloatingPoint   = 3
+	arrowTypeBinary          = 4
+	arrowTypeUtf8            = 5
+	arrowTypeBool            = 6
+	arrowTypeDecimal         = 7
+	arrowTypeDate            = 8
+	arrowTypeStruct          = 13
+	arrowTypeFixedSizeBinary = 15
+)
+
+// Arrow message header types of the MessageHeader union in Message.fbs
+const (
+	arrowMessageSchema      = 1
+	arrowMessageRecordBatch = 3
+)
+
+const arrowContinuation = 0xFFFFFFFF
+
+var arrowOrder = binary.LittleEndian
+
+// fbTable is a table in a flatbuffer, which the Arrow messages are encoded in. The offsets are checked against the
+// buffer. The first offset out of the buffer is kept in err shared by the tables of the buffer, and the reads after
+// it return zero values.
+type fbTable struct {
+	buf []byte
+	pos int
+	err *error
+}
+
+func fbRoot(buf []byte) fbTable {
+	t := fbTable{buf: buf, err: new(error)}
+	t.pos = int(t.u32(0))
+	return t
+}
+
+// error returns the error of the first offset out of the buffer, or nil.
+func (t fbTable) error() error {
+	return *t.err
+}
+
+// inBounds returns true if the n bytes at p are in the buffer. Otherwise, the error is kept.
+func (t fbTable) inBounds(p int, n int) bool {
+	if *t.err != nil {
+		return false
+	}
+	if p < 0 || n < 0 || p > len(t.buf)-n {
+		*t.err = fmt.Errorf("offset out of the flatbuffer. offset: %v, length: %v, buffer: %v", p, n, len(t.buf))
+		return false
+	}
+	return true
+}
+
+func (t fbTable) u8(p int) uint8 {
+	if !t.inBounds(p, 1) {
+		return 0
+	}
+	return t.buf[p]
+}
+
+func (t fbTable) u16(p int) uint16 {
+	if !t.inBounds(p, 2) {
+		return 0
+	}
+	return arrowOrder.Uint16(t.buf[p:])
+}
+
+func (t fbTable) u32(p int) uint32 {
+	if !t.inBounds(p, 4) {
+		return 0
+	}
+	return arrowOrder.Uint32(t.buf[p:])
+}
+
+func (t fbTable) u64(p int) uint64 {
+	if !t.inBounds(p, 8) {
+		return 0
+	}
+	return arrowOrder.Uint64(t.buf[p:])
+}
+
+// field returns the position of the field, or 0 if the field is not set.
+func (t fbTable) field(id int) int {
+	if t.buf == nil {
+		// the table is not set
+		return 0
+	}
+	vt := t.pos - int(int32(t.u32(t.pos)))
+	o := 4 + 2*id
+	if o >= int(t.u16(vt)) {
+		return 0
+	}
+	if off := int(t.u16(vt + o)); off != 0 {
+		return t.pos + off
+	}
+	return 0
+}
+
+func (t fbTable) uint8(id int, def uint8) uint8 {
+	if p := t.field(id); p != 0 {
+		return t.u8(p)
+	}
+	return def
+}
+
+func (t fbTable) int16(id int, def int16) int16 {
+	if p := t.field(id); p != 0 {
+		return int16(t.u16(p))
+	}
+	return def
+}
+
+func (t fbTable) int32(id int, def int32) int32 {
+	if p := t.field(id); p != 0 {
+		return int32(t.u32(p))
+	}
+	return def
+}
+
+func (t fbTable) int64(id int, def int64) int64 {
+	if p := t.field(id); p != 0 {
+		return int64(t.u64(p))
+	}
+	return def
+}
+
+func (t fbTable) deref(p int) int {
+	return p + int(t.u32(p))
+}
+
+// table returns the table of the field. If the field is not set, the fields of the returned table are not set.
+func (t fbTable) table(id int) (fbTable, bool) {
+	p := t.field(id)
+	if p == 0 {
+		return fbTable{err: t.err}, false
+	}
+	return fbTable{t.buf, t.deref(p), t.err}, true
+}
+
+func (t fbTable) string(id int) string {
+	p := t.field(id)
+	if p == 0 {
+		return ""
+	}
+	s := t.deref(p)
+	n := int(t.u32(s))
+	if !t.inBounds(s+4, n) {
+		return ""
+	}
+	return string(t.buf[s+4 : s+4+n])
+}
+
+// vector returns the position of the first element and the number of elements of the vector field, whose elements
+// are of the size.
+func (t fbTable) vector(id int, size int) (int, int) {
+	p := t.field(id)
+	if p == 0 {
+		return 0, 0
+	}
+	v := t.deref(p)
+	n := int(t.u32(v))
+	if !t.inBounds(v+4, n*size) {
+		return 0, 0
+	}
+	return v + 4, n
+}
+
+func (t fbTable) tables(id int) []fbTable {
+	start, n := t.vector(id, 4)
+	tables := make([]fbTable, n)
+	for i := range tables {
+		tables[i] = fbTable{t.buf, t.deref(start + 4*i), t.err}
+	}
+	return tables
+}
+
+// arrowField is a column, or a child of a struct column, in the Arrow schema.
+type arrowField struct {
+	name      string
+	typeID    uint8
+	bitWidth  int   // Int and Decimal
+	precision int16 // FloatingPoint
+	dateUnit  int16 // Date
+	scale     int   // Snowflake scale in the field metadata, or -1
+	children  []*arrowField
+}
+
+func parseArrowField(t fbTable) (*arrowField, error) {
+	f := &arrowField{
+		name:   t.string(0),
+		typeID: t.uint8(2, 0),
+		scale:  -1,
+	}
+	if _, ok := t.table(4); ok {
+		return nil, fmt.Errorf("dictionary encoded column is not supported: %v", f.name)
+	}
+	typ, _ := t.table(3)
+	switch f.typeID {
+	case arrowTypeInt:
+		f.bitWidth = int(typ.int32(0, 0))
+	case arrowTypeFloatingPoint:
+		f.precision = typ.int16(0, 0)
+	case arrowTypeDecimal:
+		f.bitWidth = int(typ.int32(2, 128))
+	case arrowTypeDate:
+		f.dateUnit = typ.int16(0, 1)
+	case arrowTypeFixedSizeBinary:
+		f.bitWidth = 8 * int(typ.int32(0, 0))
+	case arrowTypeNull, arrowTypeBinary, arrowTypeUtf8, arrowTypeBool, arrowTypeStruct:
+	default:
+		return nil, fmt.Errorf("unsupported Arrow type %v of column: %v", f.typeID, f.name)
+	}
+	for _, kv := range t.tables(6) {
+		if kv.string(0) == "scale" {
+			if scale, err := strconv.Atoi(kv.string(1)); err == nil {
+				f.scale = scale
+			}
+		}
+	}
+	for _, c := range t.tables(5) {
+		child, err := parseArrowField(c)
+		if err != nil {
+			return nil, err
+		}
+		f.children = append(f.children, child)
+	}
+	if err := t.error(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// arrowArray is the data of a column in a record batch.
+type arrowArray struct {
+	field     *arrowField
+	nullCount int64
+	validity  []byte
+	offsets   []byte // Binary and Utf8
+	values    []byte
+	children  []*arrowArray
+}
+
+func (a *arrowArray) isNull(i int) bool {
+	if a.field.typeID == arrowTypeNull {
+		return true
+	}
+	return a.nullCount > 0 && len(a.validity) > 0 && a.validity[i>>3]&(1<<uint(i&7)) == 0
+}
+
+// validate checks the buffers have the values of the rows, so that the values are read without checking the
+// bounds.
+func (a *arrowArray) validate(length int) error {
+	f := a.field
+	if length == 0 || f.typeID == arrowTypeNull {
+		return nil
+	}
+	if a.nullCount > 0 && len(a.validity) > 0 && len(a.validity) < (length+7)/8 {
+		return fmt.Errorf("validity bitmap of %v bytes is short for %v rows of column: %v", len(a.validity), length, f.name)
+	}
+	if f.typeID == arrowTypeStruct {
+		// the children are validated when read
+		return nil
+	}
+	var width int // bytes of a value
+	switch f.typeID {
+	case arrowTypeInt:
+		if f.bitWidth != 8 && f.bitWidth != 16 && f.bitWidth != 32 && f.bitWidth != 64 {
+			return fmt.Errorf("unsupported int bit width %v of column: %v", f.bitWidth, f.name)
+		}
+		width = f.bitWidth / 8
+	case arrowTypeDecimal:
+		if f.bitWidth != 128 {
+			return fmt.Errorf("unsupported decimal bit width %v of column: %v", f.bitWidth, f.name)
+		}
+		width = 16
+	case arrowTypeFloatingPoint:
+		switch f.precision {
+		case 1:
+			width = 4
+		case 2:
+			width = 8
+		default:
+			return fmt.Errorf("unsupported floating point precision %v of column: %v", f.precision, f.name)
+		}
+	case arrowTypeDate:
+		width = 8
+		if f.dateUnit == 0 {
+			width = 4
+		}
+	case arrowTypeFixedSizeBinary:
+		if width = f.bitWidth / 8; width <= 0 {
+			return fmt.Errorf("invalid byte width %v of column: %v", width, f.name)
+		}
+	case arrowTypeBool:
+		if len(a.values) < (length+7)/8 {
+			return fmt.Errorf("values of %v bytes are short for %v rows of column: %v", len(a.values), length, f.name)
+		}
+	case arrowTypeBinary, arrowTypeUtf8:
+		if len(a.offsets) < 4*(length+1) {
+			return fmt.Errorf("offsets of %v bytes are short for %v rows of column: %v", len(a.offsets), length, f.name)
+		}
+		prev := arrowOrder.Uint32(a.offsets)
+		for i := 1; i <= length; i++ {
+			o := arrowOrder.Uint32(a.offsets[4*i:])
+			if o < prev {
+				return fmt.Errorf("offsets are not in order at row %v of column: %v", i, f.name)
+			}
+			prev = o
+		}
+		if int64(prev) > int64(len(a.values)) {
+			return fmt.Errorf("offset %v is out of the values of %v bytes of column: %v", prev, len(a.values), f.name)
+		}
+	}
+	if len(a.values) < width*length {
+		return fmt.Errorf("values of %v bytes are short for %v rows of column: %v", len(a.values), length, f.name)
+	}
+	return nil
+}
+
+func (a *arrowArray) int(i int) int64 {
+	switch a.field.bitWidth {
+	case 8:
+		return int64(int8(a.values[i]))
+	case 16:
+		return int64(int16(arrowOrder.Uint16(a.values[2*i:])))
+	case 32:
+		return int64(int32(arrowOrder.Uint32(a.values[4*i:])))
+	}
+	return int64(arrowOrder.Uint64(a.values[8*i:]))
+}
+
+func (a *arrowArray) bytes(i int) []byte {
+	if a.field.typeID == arrowTypeFixedSizeBinary {
+		w := a.field.bitWidth / 8
+		return a.values[w*i : w*(i+1)]
+	}
+	return a.values[arrowOrder.Uint32(a.offsets[4*i:]):arrowOrder.Uint32(a.offsets[4*(i+1):])]
+}
+
+func (a *arrowArray) child(name string) *arrowArray {
+	for _, c := range a.children {
+		if c.field.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// recordBatch reads the columns of the record batch message in the order of the nodes and buffers.
+type recordBatch struct {
+	meta    fbTable
+	body    []byte
+	nodes   int
+	buffers int
+	nNodes  int
+	nBufs   int
+}
+
+func (rb *recordBatch) buffer() ([]byte, error) {
+	if rb.buffers >= rb.nBufs {
+		return nil, fmt.Errorf("missing buffer %v", rb.buffers)
+	}
+	start, _ := rb.meta.vector(2, 16)
+	p := start + 16*rb.buffers
+	rb.buffers++
+	offset := int64(rb.meta.u64(p))
+	length := int64(rb.meta.u64(p + 8))
+	if err := rb.meta.error(); err != nil {
+		return nil, err
+	}
+	if offset < 0 || length < 0 || offset+length > int64(len(rb.body)) {
+		return nil, fmt.Errorf("buffer out of the body. offset: %v, length: %v", offset, length)
+	}
+	return rb.body[offset : offset+length], nil
+}
+
+// array returns the array of the column, whose buffers are validated for the rows of the record batch.
+func (rb *recordBatch) array(f *arrowField, length int) (*arrowArray, error) {
+	if rb.nodes >= rb.nNodes {
+		return nil, fmt.Errorf("missing node of column: %v", f.name)
+	}
+	start, _ := rb.meta.vector(1, 16)
+	p := start + 16*rb.nodes
+	rb.nodes++
+	n := int64(rb.meta.u64(p))
+	a := &arrowArray{field: f, nullCount: int64(rb.meta.u64(p + 8))}
+	if err := rb.meta.error(); err != nil {
+		return nil, err
+	}
+	if n != int64(length) {
+		return nil, fmt.Errorf("length %v of column %v doesn't match the record batch of %v rows", n, f.name, length)
+	}
+	if f.typeID == arrowTypeNull {
+		return a, nil
+	}
+	var err error
+	if a.validity, err = rb.buffer(); err != nil {
+		return nil, err
+	}
+	switch f.typeID {
+	case arrowTypeStruct:
+		for _, c := range f.children {
+			child, err := rb.array(c, length)
+			if err != nil {
+				return nil, err
+			}
+			a.children = append(a.children, child)
+		}
+		if err = a.validate(length); err != nil {
+			return nil, err
+		}
+		return a, nil
+	case arrowTypeBinary, arrowTypeUtf8:
+		if a.offsets, err = rb.buffer(); err != nil {
+			return nil, err
+		}
+	}
+	if a.values, err = rb.buffer(); err != nil {
+		return nil, err
+	}
+	if err = a.validate(length); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// readArrowMessage reads the next encapsulated message of the Arrow stream. It returns io.EOF at the end of the
+// stream.
+func readArrowMessage(r io.Reader) (fbTable, []byte, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		// the stream may end without the end-of-stream marker
+		return fbTable{}, nil, err
+	}
+	size := arrowOrder.Uint32(b[:])
+	if size == arrowContinuation {
+		if err := readFull(r, b[:]); err != nil {
+			return fbTable{}, nil, err
+		}
+		size = arrowOrder.Uint32(b[:])
+	}
+	if size == 0 {
+		return fbTable{}, nil, io.EOF
+	}
+	meta, err := readN(r, int64(size))
+	if err != nil {
+		return fbTable{}, nil, err
+	}
+	msg := fbRoot(meta)
+	bodyLength := msg.int64(3, 0)
+	if err := msg.error(); err != nil {
+		return fbTable{}, nil, err
+	}
+	if bodyLength < 0 {
+		return fbTable{}, nil, fmt.Errorf("invalid body length: %v", bodyLength)
+	}
+	body, err := readN(r, bodyLength)
+	if err != nil {
+		return fbTable{}, nil, err
+	}
+	return msg, body, nil
+}
+
+// readN reads the n bytes of the message. The buffer grows as the bytes are read, so that a corrupted length
+// doesn't allocate more than the stream has.
+func readN(r io.Reader, n int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// readFull reads the rest of the message. The stream must not end in the middle of the message.
+func readFull(r io.Reader, b []byte) error {
+	_, err := io.ReadFull(r, b)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readArrowChunk reads the chunk of result set in the Arrow stream format, and keeps the stream with the rows
+// decoded from it to spill the chunk.
+func readArrowChunk(r io.Reader, rowType []execResponseRowType) (*chunkRows, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	chunk, err := decodeArrowChunk(bytes.NewReader(b), rowType)
+	if err != nil {
+		return nil, err
+	}
+	chunk.arrow = b
+	return chunk, nil
+}
+
+// decodeArrowChunk decodes the chunk of result set in the Arrow stream format into the values of the rows, which
+// are the values the strings of the JSON chunks are converted to by stringToValue.
+func decodeArrowChunk(r io.Reader, rowType []execResponseRowType) (chunk *chunkRows, err error) {
+	defer func() {
+		if err != nil {
+			err = &SnowflakeError{
+				Number:      ErrInvalidArrowData,
+				Message:     errMsgInvalidArrowData,
+				MessageArgs: []interface{}{err},
+				Err:         err,
+			}
+		}
+	}()
+	var fields []*arrowField
+	var rows [][]driver.Value
+	hasSchema := false
+	for {
+		msg, body, err := readArrowMessage(r)
+		if err == io.EOF {
+			return &chunkRows{values: rows}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		header, ok := msg.table(2)
+		if !ok {
+			return nil, fmt.Errorf("no message header")
+		}
+		typ := msg.uint8(1, 0)
+		if err = msg.error(); err != nil {
+			return nil, err
+		}
+		switch typ {
+		case arrowMessageSchema:
+			fields = nil
+			for _, t := range header.tables(1) {
+				f, err := parseArrowField(t)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, f)
+			}
+			if err = header.error(); err != nil {
+				return nil, err
+			}
+			hasSchema = true
+			if len(fields) != len(rowType) {
+				return nil, fmt.Errorf("number of columns doesn't match. expected: %v, got: %v", len(rowType), len(fields))
+			}
+		case arrowMessageRecordBatch:
+			if !hasSchema {
+				return nil, fmt.Errorf("record batch before the schema")
+			}
+			if rows, err = appendRecordBatch(rows, header, body, fields, rowType); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported message type: %v", typ)
+		}
+	}
+}
+
+func appendRecordBatch(rows [][]driver.Value, meta fbTable, body []byte, fields []*arrowField, rowType []execResponseRowType) ([][]driver.Value, error) {
+	if _, ok := meta.table(3); ok {
+		return nil, fmt.Errorf("compressed record batch is not supported")
+	}
+	rb := &recordBatch{meta: meta, body: body}
+	_, rb.nNodes = meta.vector(1, 16)
+	_, rb.nBufs = meta.vector(2, 16)
+	length := int(meta.int64(0, 0))
+	if err := meta.error(); err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("invalid record batch length: %v", length)
+	}
+	arrays := make([]*arrowArray, len(fields))
+	for col, f := range fields {
+		a, err := rb.array(f, length)
+		if err != nil {
+			return nil, err
+		}
+		arrays[col] = a
+	}
+	batch := make([][]driver.Value, length)
+	values := make([]driver.Value, length*len(fields))
+	for i := range batch {
+		batch[i] = values[i*len(fields) : (i+1)*len(fields)]
+	}
+	for col, a := range arrays {
+		f := a.field
+		scale := int(rowType[col].Scale)
+		if f.scale >= 0 {
+			scale = f.scale
+		}
+		for i := 0; i < length; i++ {
+			if a.isNull(i) {
+				continue
+			}
+			v, err := arrowColumnValue(a, i, scale, rowType[col])
+			if err != nil {
+				return nil, err
+			}
+			batch[i][col] = v
+		}
+	}
+	return append(rows, batch...), nil
+}
+
+// arrowColumnValue returns the value at the index converted to the Go type of the column. The numbers, strings and
+// the values of the types not decoded here are converted from the format of the JSON chunks.
+func arrowColumnValue(a *arrowArray, i int, scale int, rowType execResponseRowType) (driver.Value, error) {
+	switch rowType.Type {
+	case "text", "fixed", "real", "variant", "object", "array":
+		return arrowValue(a, i, scale)
+	case "date":
+		if a.field.typeID == arrowTypeDate {
+			return time.Unix(arrowDays(a, i)*86400, 0).UTC(), nil
+		}
+	case "binary":
+		if a.field.typeID == arrowTypeBinary || a.field.typeID == arrowTypeFixedSizeBinary {
+			return append([]byte{}, a.bytes(i)...), nil
+		}
+	case "time", "timestamp_ntz", "timestamp_ltz", "timestamp_tz":
+		sec, nsec, offset, err := arrowEpoch(a, i, scale)
+		if err != nil {
+			return nil, err
+		}
+		if (offset != nil) != (rowType.Type == "timestamp_tz") {
+			return nil, fmt.Errorf("unsupported %v column: %v", rowType.Type, a.field.name)
+		}
+		switch rowType.Type {
+		case "time":
+			return time.Time{}.Add(time.Duration(sec*1e9 + nsec)), nil
+		case "timestamp_ntz":
+			return time.Unix(sec, nsec).UTC(), nil
+		case "timestamp_ltz":
+			// the location is set to the time zone of the session by the rows
+			return time.Unix(sec, nsec), nil
+		}
+		return time.Unix(sec, nsec).In(Location(int(*offset) - 1440)), nil
+	}
+	v, err := arrowValue(a, i, scale)
+	if err != nil {
+		return nil, err
+	}
+	var dest driver.Value
+	err = stringToValue(&dest, rowType, &v)
+	return dest, err
+}
+
+// arrowEpoch returns the seconds and nanoseconds of the TIME or TIMESTAMP value, which are the ones extractTimestamp
+// reads from the value in the format of the JSON chunks, and the time zone offset of TIMESTAMP_TZ.
+func arrowEpoch(a *arrowArray, i int, scale int) (sec, nsec int64, offset *int64, err error) {
+	if scale > 9 {
+		return 0, 0, nil, fmt.Errorf("invalid scale %v of column: %v", scale, a.field.name)
+	}
+	if a.field.typeID == arrowTypeInt {
+		sec, nsec = scaledEpoch(a.int(i), scale)
+		return sec, nsec, nil, nil
+	}
+	epoch := a.child("epoch")
+	if a.field.typeID != arrowTypeStruct || epoch == nil {
+		return 0, 0, nil, fmt.Errorf("unsupported Arrow type %v of column: %v", a.field.typeID, a.field.name)
+	}
+	for _, c := range a.children {
+		if c.field.typeID != arrowTypeInt {
+			return 0, 0, nil, fmt.Errorf("unsupported struct column: %v", a.field.name)
+		}
+	}
+	if fraction := a.child("fraction"); fraction != nil {
+		sec, nsec = epoch.int(i), fraction.int(i)
+		if nsec < 0 || nsec >= 1e9 {
+			return 0, 0, nil, fmt.Errorf("invalid fraction %v of column: %v", nsec, a.field.name)
+		}
+		if sec < 0 && nsec > 0 {
+			// formatEpochFraction writes the negative seconds rounded toward zero
+			sec, nsec = sec+1, 1e9-nsec
+		}
+	} else {
+		sec, nsec = scaledEpoch(epoch.int(i), scale)
+	}
+	if tz := a.child("timezone"); tz != nil {
+		v := tz.int(i)
+		offset = &v
+	}
+	return sec, nsec, offset, nil
+}
+
+// scaledEpoch returns the seconds and nanoseconds of v scaled down by the scale, as extractTimestamp reads them
+// from formatScaled.
+func scaledEpoch(v int64, scale int) (int64, int64) {
+	if scale <= 0 {
+		return v, 0
+	}
+	p := int64(math.Pow10(scale))
+	nsec := v % p
+	if nsec < 0 {
+		nsec = -nsec
+	}
+	return v / p, nsec * int64(math.Pow10(9-scale))
+}
+
+// arrowDays returns the days since the epoch of the DATE value.
+func arrowDays(a *arrowArray, i int) int64 {
+	if a.field.dateUnit == 0 {
+		return int64(int32(arrowOrder.Uint32(a.values[4*i:])))
+	}
+	return floorDiv(int64(arrowOrder.Uint64(a.values[8*i:])), 86400000)
+}
+
+// arrowValue returns the value at the index in the format of the JSON chunks.
+func arrowValue(a *arrowArray, i int, scale int) (string, error) {
+	switch a.field.typeID {
+	case arrowTypeInt:
+		// FIXED, TIME and TIMESTAMP scaled by the column scale
+		return formatScaled(a.int(i), scale), nil
+	case arrowTypeDecimal:
+		v := new(big.Int).SetInt64(int64(arrowOrder.Uint64(a.values[16*i+8:])))
+		v.Lsh(v, 64)
+		v.Or(v, new(big.Int).SetUint64(arrowOrder.Uint64(a.values[16*i:])))
+		return formatScaledString(v.String(), scale), nil
+	case arrowTypeFloatingPoint:
+		if a.field.precision == 1 {
+			return strconv.FormatFloat(float64(math.Float32frombits(arrowOrder.Uint32(a.values[4*i:]))), 'g', -1, 32), nil
+		}
+		return strconv.FormatFloat(math.Float64frombits(arrowOrder.Uint64(a.values[8*i:])), 'g', -1, 64), nil
+	case arrowTypeBool:
+		if a.values[i>>3]&(1<<uint(i&7)) != 0 {
+			return "1", nil
+		}
+		return "0", nil
+	case arrowTypeDate:
+		return strconv.FormatInt(arrowDays(a, i), 10), nil
+	case arrowTypeUtf8:
+		return string(a.bytes(i)), nil
+	case arrowTypeBinary, arrowTypeFixedSizeBinary:
+		return hex.EncodeToString(a.bytes(i)), nil
+	case arrowTypeStruct:
+		return arrowTimestamp(a, i, scale)
+	}
+	return "", fmt.Errorf("unsupported Arrow type %v of column: %v", a.field.typeID, a.field.name)
+}
+
+// arrowTimestamp returns the TIMESTAMP value of the struct column. The epoch is in seconds if the struct has the
+// fraction in nanoseconds, otherwise scaled by the column scale. TIMESTAMP_TZ has the time zone offset in minutes
+// plus 1440.
+func arrowTimestamp(a *arrowArray, i int, scale int) (string, error) {
+	epoch := a.child("epoch")
+	if epoch == nil || epoch.field.typeID != arrowTypeInt {
+		return "", fmt.Errorf("unsupported struct column: %v", a.field.name)
+	}
+	for _, c := range a.children {
+		if c.field.typeID != arrowTypeInt {
+			return "", fmt.Errorf("unsupported struct column: %v", a.field.name)
+		}
+	}
+	var v string
+	if fraction := a.child("fraction"); fraction != nil {
+		v = formatEpochFraction(epoch.int(i), fraction.int(i))
+	} else {
+		v = formatScaled(epoch.int(i), scale)
+	}
+	if tz := a.child("timezone"); tz != nil {
+		v += " " + strconv.FormatInt(tz.int(i), 10)
+	}
+	return v, nil
+}
+
+// formatScaled returns the decimal of v scaled down by the scale.
+func formatScaled(v int64, scale int) string {
+	return formatScaledString(strconv.FormatInt(v, 10), scale)
+}
+
+func formatScaledString(s string, scale int) string {
+	if scale <= 0 {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-scale] + "." + s[len(s)-scale:]
+}
+
+// formatEpochFraction returns the decimal seconds of the epoch seconds plus the fraction nanoseconds.
+func formatEpochFraction(epoch int64, fraction int64) string {
+	if epoch < 0 && fraction > 0 {
+		return fmt.Sprintf("-%d.%09d", -(epoch + 1), 1000000000-fraction)
+	}
+	return fmt.Sprintf("%d.%09d", epoch, fraction)
+}
+
+func floorDiv(a int64, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// firstChunk returns the rows of the first chunk of the result set in the response.
+func firstChunk(data *execResponse) (*chunkRows, error) {
+	if data.Data.QueryResultFormat != queryResultFormatArrow {
+		return jsonChunk(data.Data.RowSet), nil
+	}
+	if data.Data.RowSetBase64 == "" {
+		return &chunkRows{}, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(data.Data.RowSetBase64)
+	if err != nil {
+		return nil, &SnowflakeError{
+			Number:      ErrInvalidArrowData,
+			Message:     errMsgInvalidArrowData,
+			MessageArgs: []interface{}{err},
+			Err:         err,
+		}
+	}
+	return decodeArrowChunk(bytes.NewReader(b), data.Data.RowType)
+}
@@ -0,0 +1,588 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fbBuilder writes the flatbuffers of the test Arrow streams. A table is a slice of the fields by the field ID:
+// nil for the absent fields, []byte for the scalars, and fbTableValue, fbTables, fbStructs or string for the
+// references. Each table is written before its children, so that the references point forward.
+type fbBuilder struct {
+	buf []byte
+}
+
+type fbTableValue []interface{}
+
+type fbTables []fbTableValue
+
+type fbStructs [][]byte
+
+func (b *fbBuilder) uint32(v uint32) {
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], v)
+}
+
+func (b *fbBuilder) patch(p int, target int) {
+	binary.LittleEndian.PutUint32(b.buf[p:], uint32(target-p))
+}
+
+func (b *fbBuilder) table(fields fbTableValue) int {
+	vt := len(b.buf)
+	size := 4
+	offsets := make([]int, len(fields))
+	for i, f := range fields {
+		switch v := f.(type) {
+		case nil:
+			continue
+		case []byte:
+			offsets[i] = size
+			size += len(v)
+		default:
+			offsets[i] = size
+			size += 4
+		}
+	}
+	vtable := []uint16{uint16(4 + 2*len(fields)), uint16(size)}
+	for _, o := range offsets {
+		vtable = append(vtable, uint16(o))
+	}
+	for _, v := range vtable {
+		b.buf = append(b.buf, byte(v), byte(v>>8))
+	}
+	pos := len(b.buf)
+	b.uint32(uint32(pos - vt))
+	for _, f := range fields {
+		switch v := f.(type) {
+		case nil:
+		case []byte:
+			b.buf = append(b.buf, v...)
+		default:
+			b.uint32(0)
+		}
+	}
+	for i, f := range fields {
+		switch f.(type) {
+		case nil, []byte:
+		default:
+			b.patch(pos+offsets[i], b.value(f))
+		}
+	}
+	return pos
+}
+
+func (b *fbBuilder) value(v interface{}) int {
+	pos := len(b.buf)
+	switch v := v.(type) {
+	case fbTableValue:
+		return b.table(v)
+	case string:
+		b.uint32(uint32(len(v)))
+		b.buf = append(b.buf, v...)
+		b.buf = append(b.buf, 0)
+	case fbStructs:
+		b.uint32(uint32(len(v)))
+		for _, s := range v {
+			b.buf = append(b.buf, s...)
+		}
+	case fbTables:
+		b.uint32(uint32(len(v)))
+		for range v {
+			b.uint32(0)
+		}
+		for i, t := range v {
+			b.patch(pos+4+4*i, b.table(t))
+		}
+	}
+	return pos
+}
+
+func fbBytes(root fbTableValue) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	binary.LittleEndian.PutUint32(b.buf, uint32(b.table(root)))
+	return b.buf
+}
+
+func le16(v int16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(v))
+	return b
+}
+
+func le32(v int32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func le64(v int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+// testArrowColumn is a column of the test Arrow streams.
+type testArrowColumn struct {
+	name      string
+	typeID    uint8
+	typ       fbTableValue
+	scale     string
+	children  []testArrowColumn
+	length    int
+	nullCount int
+	buffers   [][]byte // validity and the data buffers
+}
+
+func (c testArrowColumn) field() fbTableValue {
+	var children fbTables
+	for _, child := range c.children {
+		children = append(children, child.field())
+	}
+	if c.typ == nil {
+		c.typ = fbTableValue{}
+	}
+	f := fbTableValue{c.name, []byte{1}, []byte{c.typeID}, c.typ, nil, children}
+	if c.scale != "" {
+		f = append(f, fbTables{{"scale", c.scale}})
+	}
+	return f
+}
+
+func (c testArrowColumn) nodes(nodes fbStructs, buffers [][]byte) (fbStructs, [][]byte) {
+	nodes = append(nodes, append(le64(int64(c.length)), le64(int64(c.nullCount))...))
+	buffers = append(buffers, c.buffers...)
+	for _, child := range c.children {
+		nodes, buffers = child.nodes(nodes, buffers)
+	}
+	return nodes, buffers
+}
+
+func appendArrowMessage(stream []byte, headerType byte, header fbTableValue, body []byte) []byte {
+	meta := fbBytes(fbTableValue{le16(4), []byte{headerType}, header, le64(int64(len(body)))})
+	stream = append(stream, le32(-1)...)
+	stream = append(stream, le32(int32(len(meta)))...)
+	stream = append(stream, meta...)
+	return append(stream, body...)
+}
+
+// testArrowStream returns the Arrow stream of the schema and a record batch of the columns per batch.
+func testArrowStream(batches ...[]testArrowColumn) []byte {
+	var fields fbTables
+	for _, c := range batches[0] {
+		fields = append(fields, c.field())
+	}
+	stream := appendArrowMessage(nil, arrowMessageSchema, fbTableValue{le16(0), fields}, nil)
+	for _, columns := range batches {
+		var nodes fbStructs
+		var buffers [][]byte
+		for _, c := range columns {
+			nodes, buffers = c.nodes(nodes, buffers)
+		}
+		var body []byte
+		var bufs fbStructs
+		for _, b := range buffers {
+			bufs = append(bufs, append(le64(int64(len(body))), le64(int64(len(b)))...))
+			body = append(body, b...)
+			for len(body)%8 != 0 {
+				body = append(body, 0)
+			}
+		}
+		stream = appendArrowMessage(stream, arrowMessageRecordBatch, fbTableValue{le64(int64(columns[0].length)), nodes, bufs}, body)
+	}
+	// end-of-stream marker
+	return append(append(stream, le32(-1)...), le32(0)...)
+}
+
+// validity returns the validity bitmap of the values, and the number of nulls.
+func validity(n int, nulls []int) ([]byte, int) {
+	if len(nulls) == 0 {
+		return nil, 0
+	}
+	b := bytes.Repeat([]byte{0xff}, (n+7)/8)
+	for _, i := range nulls {
+		b[i/8] &^= 1 << uint(i%8)
+	}
+	return b, len(nulls)
+}
+
+func arrowIntColumn(name string, bitWidth int, values []int64, nulls ...int) testArrowColumn {
+	var data []byte
+	for _, v := range values {
+		data = append(data, le64(v)[:bitWidth/8]...)
+	}
+	valid, nullCount := validity(len(values), nulls)
+	return testArrowColumn{
+		name:      name,
+		typeID:    arrowTypeInt,
+		typ:       fbTableValue{le32(int32(bitWidth)), []byte{1}},
+		length:    len(values),
+		nullCount: nullCount,
+		buffers:   [][]byte{valid, data},
+	}
+}
+
+func arrowStringColumn(name string, typeID uint8, values []string, nulls ...int) testArrowColumn {
+	offsets := le32(0)
+	var data []byte
+	for _, v := range values {
+		data = append(data, v...)
+		offsets = append(offsets, le32(int32(len(data)))...)
+	}
+	valid, nullCount := validity(len(values), nulls)
+	return testArrowColumn{
+		name:      name,
+		typeID:    typeID,
+		length:    len(values),
+		nullCount: nullCount,
+		buffers:   [][]byte{valid, offsets, data},
+	}
+}
+
+func arrowStructColumn(name string, children ...testArrowColumn) testArrowColumn {
+	return testArrowColumn{
+		name:     name,
+		typeID:   arrowTypeStruct,
+		children: children,
+		length:   children[0].length,
+		buffers:  [][]byte{nil},
+	}
+}
+
+func sp(s string) *string {
+	return &s
+}
+
+func TestDecodeArrowChunk(t *testing.T) {
+	float64s := append(append(le64(int64(math.Float64bits(1.5))), le64(0)...), le64(int64(math.Float64bits(-0.25)))...)
+	decimals := append(append(le64(-1234), le64(-1)...), append(le64(5), le64(1)...)...)
+	scaled := arrowIntColumn("C2", 64, []int64{12345, -5, 0})
+	scaled.scale = "2"
+	rowType := []execResponseRowType{
+		{Name: "C1", Type: "fixed"},
+		{Name: "C2", Type: "fixed"},
+		{Name: "C3", Type: "fixed", Scale: 3},
+		{Name: "C4", Type: "real"},
+		{Name: "C5", Type: "text"},
+		{Name: "C6", Type: "boolean"},
+		{Name: "C7", Type: "date"},
+		{Name: "C8", Type: "binary"},
+		{Name: "C9", Type: "time", Scale: 3},
+		{Name: "C10", Type: "timestamp_ntz", Scale: 9},
+		{Name: "C11", Type: "timestamp_tz", Scale: 3},
+		{Name: "C12", Type: "timestamp_tz", Scale: 9},
+	}
+	columns := []testArrowColumn{
+		arrowIntColumn("C1", 8, []int64{1, -2, 0}, 2),
+		scaled,
+		{name: "C3", typeID: arrowTypeDecimal, typ: fbTableValue{le32(38), le32(3)}, length: 3, nullCount: 1,
+			buffers: [][]byte{{0x3}, append(decimals, make([]byte, 16)...)}},
+		{name: "C4", typeID: arrowTypeFloatingPoint, typ: fbTableValue{le16(2)}, length: 3, nullCount: 1,
+			buffers: [][]byte{{0x5}, float64s}},
+		arrowStringColumn("C5", arrowTypeUtf8, []string{"a", "", "日本"}),
+		{name: "C6", typeID: arrowTypeBool, length: 3, nullCount: 1, buffers: [][]byte{{0x3}, {0x1}}},
+		{name: "C7", typeID: arrowTypeDate, typ: fbTableValue{le16(0)}, length: 3,
+			buffers: [][]byte{nil, append(append(le32(0), le32(-1)...), le32(18000)...)}},
+		arrowStringColumn("C8", arrowTypeBinary, []string{"\xab", "", "\x01\x02"}, 1),
+		arrowIntColumn("C9", 32, []int64{3723004, 0, 1}),
+		arrowStructColumn("C10",
+			arrowIntColumn("epoch", 64, []int64{-2, 1, 0}),
+			arrowIntColumn("fraction", 32, []int64{500000000, 5, 0})),
+		arrowStructColumn("C11",
+			arrowIntColumn("epoch", 64, []int64{1500, -1500, 0}),
+			arrowIntColumn("timezone", 32, []int64{1500, 1380, 1440})),
+		arrowStructColumn("C12",
+			arrowIntColumn("epoch", 64, []int64{1, 2, 3}),
+			arrowIntColumn("fraction", 32, []int64{1, 20, 300}),
+			arrowIntColumn("timezone", 32, []int64{1440, 1440, 1441})),
+	}
+	expected := [][]*string{
+		{sp("1"), sp("123.45"), sp("-1.234"), sp("1.5"), sp("a"), sp("1"), sp("0"), sp("ab"), sp("3723.004"),
+			sp("-1.500000000"), sp("1.500 1500"), sp("1.000000001 1440")},
+		{sp("-2"), sp("-0.05"), sp("18446744073709551.621"), nil, sp(""), sp("0"), sp("-1"), nil, sp("0.000"),
+			sp("1.000000005"), sp("-1.500 1380"), sp("2.000000020 1440")},
+		{nil, sp("0.00"), nil, sp("-0.25"), sp("日本"), nil, sp("18000"), sp("0102"), sp("0.001"),
+			sp("0.000000000"), sp("0.000 1440"), sp("3.000000300 1441")},
+	}
+	chunk, err := decodeArrowChunk(bytes.NewReader(testArrowStream(columns, columns)), rowType)
+	if err != nil {
+		t.Fatalf("failed to decode the chunk. err: %v", err)
+	}
+	if len(chunk.values) != 2*len(expected) {
+		t.Fatalf("failed to decode all record batches. expected: %v rows, got: %v", 2*len(expected), len(chunk.values))
+	}
+	for i, row := range chunk.values {
+		for j, v := range row {
+			// the value is the one the string in the JSON chunk is converted to
+			var e driver.Value
+			if err := stringToValue(&e, rowType[j], expected[i%len(expected)][j]); err != nil {
+				t.Fatalf("failed to convert the value at (%v, %v). err: %v", i, j, err)
+			}
+			if !sameValue(v, e) {
+				t.Errorf("failed to decode the value at (%v, %v). expected: %v, got: %v", i, j, e, v)
+			}
+		}
+	}
+}
+
+func TestDecodeArrowChunkError(t *testing.T) {
+	column := arrowIntColumn("C1", 64, []int64{1, 2})
+	rowType := []execResponseRowType{{Name: "C1", Type: "fixed"}}
+	stream := testArrowStream([]testArrowColumn{column})
+	missingBuffer := column
+	missingBuffer.buffers = missingBuffer.buffers[:1]
+	shortValues := column
+	shortValues.buffers = [][]byte{nil, le64(1)}
+	badOffset := arrowStringColumn("C1", arrowTypeUtf8, []string{"a", "b"})
+	badOffset.buffers[1] = append(le32(0), append(le32(1), le32(100)...)...)
+	shortValidity := arrowStructColumn("C1",
+		arrowIntColumn("epoch", 64, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9}),
+		arrowIntColumn("fraction", 32, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9}))
+	shortValidity.nullCount = 1
+	shortValidity.buffers = [][]byte{{0xfe}}
+	testcases := []struct {
+		name    string
+		stream  []byte
+		rowType []execResponseRowType
+	}{
+		{name: "truncated", stream: stream[:len(stream)-12], rowType: rowType},
+		{name: "column count", stream: stream, rowType: append(rowType, rowType[0])},
+		{name: "unsupported type", stream: testArrowStream([]testArrowColumn{{name: "C1", typeID: 12, length: 1}}), rowType: rowType},
+		{name: "missing buffer", stream: testArrowStream([]testArrowColumn{missingBuffer}), rowType: rowType},
+		{name: "corrupted", stream: append(le32(-1), append(le32(8), 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0)...), rowType: rowType},
+		{name: "short values", stream: testArrowStream([]testArrowColumn{shortValues}), rowType: rowType},
+		{name: "offset out of values", stream: testArrowStream([]testArrowColumn{badOffset}), rowType: rowType},
+		{name: "bit width", stream: testArrowStream([]testArrowColumn{arrowIntColumn("C1", 24, []int64{1})}), rowType: rowType},
+		{name: "short struct validity", stream: testArrowStream([]testArrowColumn{shortValidity}), rowType: []execResponseRowType{{Name: "C1", Type: "timestamp_ntz", Scale: 9}}},
+		{name: "short metadata", stream: append(le32(-1), append(le32(2), 0, 0)...), rowType: rowType},
+	}
+	for _, test := range testcases {
+		_, err := decodeArrowChunk(bytes.NewReader(test.stream), test.rowType)
+		if err == nil {
+			t.Errorf("%v: should have failed to decode the chunk", test.name)
+			continue
+		}
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidArrowData {
+			t.Errorf("%v: failed to return the error code. err: %v", test.name, err)
+		}
+	}
+}
+
+func TestDecodeArrowChunkCorrupted(t *testing.T) {
+	rowType := []execResponseRowType{{Name: "C1", Type: "fixed"}, {Name: "C2", Type: "text"}, {Name: "C3", Type: "timestamp_tz"}}
+	stream := testArrowStream([]testArrowColumn{
+		arrowIntColumn("C1", 32, []int64{1, 2, 3}, 1),
+		arrowStringColumn("C2", arrowTypeUtf8, []string{"a", "bc", ""}),
+		arrowStructColumn("C3",
+			arrowIntColumn("epoch", 64, []int64{1, 2, 3}),
+			arrowIntColumn("fraction", 32, []int64{0, 1, 2}),
+			arrowIntColumn("timezone", 32, []int64{1440, 1440, 1440})),
+	})
+	if _, err := decodeArrowChunk(bytes.NewReader(stream), rowType); err != nil {
+		t.Fatalf("failed to decode the chunk. err: %v", err)
+	}
+	// every corrupted byte is either decoded or returned as the error without panicking
+	corrupted := make([]byte, len(stream))
+	for i := range stream {
+		for _, b := range []byte{0x00, 0x7f, 0xff} {
+			copy(corrupted, stream)
+			corrupted[i] = b
+			if _, err := decodeArrowChunk(bytes.NewReader(corrupted), rowType); err != nil {
+				if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidArrowData {
+					t.Fatalf("failed to return the error code. byte: %v, err: %v", i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestFormatScaled(t *testing.T) {
+	testcases := []struct {
+		v     int64
+		scale int
+		out   string
+	}{
+		{v: 0, scale: 0, out: "0"},
+		{v: 5, scale: 3, out: "0.005"},
+		{v: -5, scale: 3, out: "-0.005"},
+		{v: 123456, scale: 2, out: "1234.56"},
+		{v: math.MinInt64, scale: 9, out: "-9223372036.854775808"},
+	}
+	for _, test := range testcases {
+		if s := formatScaled(test.v, test.scale); s != test.out {
+			t.Errorf("failed to format %v with scale %v. expected: %v, got: %v", test.v, test.scale, test.out, s)
+		}
+	}
+}
+
+func TestRowsArrowResult(t *testing.T) {
+	rowType := []execResponseRowType{{Name: "C1", Type: "fixed"}, {Name: "C2", Type: "timestamp_ntz", Scale: 3}}
+	chunk := func(values ...int64) []byte {
+		return testArrowStream([]testArrowColumn{
+			arrowIntColumn("C1", 64, values),
+			arrowIntColumn("C2", 64, values),
+		})
+	}
+	data := &execResponse{Data: execResponseData{
+		RowType:           rowType,
+		QueryResultFormat: queryResultFormatArrow,
+		RowSetBase64:      base64.StdEncoding.EncodeToString(chunk(1, 2)),
+		Total:             2,
+	}}
+	rows := &snowflakeRows{sc: getDefaultSnowflakeConn(), ctx: context.Background()}
+	if err := rows.setResult(data); err != nil {
+		t.Fatalf("failed to set the result. err: %v", err)
+	}
+	first := rows.ChunkDownloader.CurrentChunk
+
+	var mu sync.Mutex
+	var fetched []string
+	rows.ChunkDownloader = &snowflakeChunkDownloader{
+		sc:                 rows.sc,
+		ctx:                context.Background(),
+		CurrentChunk:       first,
+		ChunkMetas:         []execResponseChunk{{URL: "chunk1", RowCount: 1}, {URL: "chunk2", RowCount: 2}},
+		Total:              5,
+		TotalRowIndex:      int64(-1),
+		RowType:            rowType,
+		QueryResultFormat:  queryResultFormatArrow,
+		FuncDownload:       downloadChunk,
+		FuncDownloadHelper: downloadChunkHelper,
+		FuncGet: func(_ context.Context, _ *snowflakeChunkDownloader, url string, _ map[string]string, _ time.Duration) (*http.Response, error) {
+			mu.Lock()
+			fetched = append(fetched, url)
+			mu.Unlock()
+			body := chunk(3)
+			if url == "chunk2" {
+				body = chunk(4, 5)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+		},
+	}
+	rows.ChunkDownloader.start()
+	var got []int64
+	dest := make([]driver.Value, 2)
+	for rows.Next(dest) == nil {
+		v, err := strconv.ParseInt(dest[0].(string), 10, 64)
+		if err != nil {
+			t.Fatalf("failed to parse the value. err: %v", err)
+		}
+		got = append(got, v)
+		if ts := dest[1].(time.Time); ts.UnixNano() != v*int64(time.Millisecond) {
+			t.Errorf("failed to convert the timestamp. value: %v, got: %v", v, ts)
+		}
+	}
+	if !reflect.DeepEqual(got, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("failed to read all chunks. got: %v", got)
+	}
+	if len(fetched) != 2 {
+		t.Errorf("failed to download the chunks. fetched: %v", fetched)
+	}
+
+	data.Data.RowSetBase64 = "!"
+	if err := rows.setResult(data); err == nil {
+		t.Error("should have failed to decode the first chunk")
+	}
+}
+
+// BenchmarkReadChunk reads the values of a chunk of a number, a string and a timestamp column in JSON and Arrow.
+func BenchmarkReadChunk(b *testing.B) {
+	const numRows = 10000
+	rowType := []execResponseRowType{
+		{Name: "C1", Type: "fixed"}, {Name: "C2", Type: "text"}, {Name: "C3", Type: "timestamp_ntz", Scale: 9}}
+	numbers := make([]int64, numRows)
+	texts := make([]string, numRows)
+	epochs := make([]int64, numRows)
+	fractions := make([]int64, numRows)
+	var jsonBody bytes.Buffer
+	for i := range numbers {
+		numbers[i], texts[i], epochs[i], fractions[i] = int64(i), "value"+strconv.Itoa(i), 1500000000+int64(i), int64(i)
+		if i > 0 {
+			jsonBody.WriteByte(',')
+		}
+		fmt.Fprintf(&jsonBody, `["%v","%v","%v"]`, numbers[i], texts[i], formatEpochFraction(epochs[i], fractions[i]))
+	}
+	arrowBody := testArrowStream([]testArrowColumn{
+		arrowIntColumn("C1", 64, numbers),
+		arrowStringColumn("C2", arrowTypeUtf8, texts),
+		arrowStructColumn("C3", arrowIntColumn("epoch", 64, epochs), arrowIntColumn("fraction", 32, fractions)),
+	})
+	read := func(b *testing.B, decode func() (*chunkRows, error)) {
+		b.ReportAllocs()
+		dest := make([]driver.Value, len(rowType))
+		for i := 0; i < b.N; i++ {
+			chunk, err := decode()
+			if err != nil {
+				b.Fatalf("failed to decode the chunk. err: %v", err)
+			}
+			for j := 0; j < chunk.len(); j++ {
+				row := chunk.row(j)
+				for k := range dest {
+					if err = row.value(&dest[k], k, rowType[k]); err != nil {
+						b.Fatalf("failed to convert the value. err: %v", err)
+					}
+				}
+			}
+		}
+	}
+	b.Run("json", func(b *testing.B) {
+		read(b, func() (*chunkRows, error) {
+			rows, err := decodeJSONChunk(bytes.NewReader(jsonBody.Bytes()))
+			return jsonChunk(rows), err
+		})
+	})
+	b.Run("arrow", func(b *testing.B) {
+		read(b, func() (*chunkRows, error) {
+			return decodeArrowChunk(bytes.NewReader(arrowBody), rowType)
+		})
+	})
+}
+
+func TestUnitAuthenticateQueryResultFormat(t *testing.T) {
+	var params map[string]string
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostAuth = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, jsonBody []byte, _ time.Duration) (*authResponse, error) {
+		var ar authRequest
+		if err := json.Unmarshal(jsonBody, &ar); err != nil {
+			return nil, err
+		}
+		params = ar.Data.SessionParameters
+		return &authResponse{Success: true, Data: authResponseMain{Token: "t", MasterToken: "m"}}, nil
+	}
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if _, ok := params[queryResultFormatParam]; ok {
+		t.Errorf("should have fetched the results in JSON by default. params: %v", params)
+	}
+	sc.cfg.ResultFormat = ResultFormatArrow
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if params[queryResultFormatParam] != "ARROW" {
+		t.Errorf("failed to request the results in Arrow. params: %v", params)
+	}
+	format := "json"
+	sc.cfg.Params["go_query_result_format"] = &format
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if params[queryResultFormatParam] != "json" {
+		t.Errorf("failed to keep the result format of the parameter. params: %v", params)
+	}
+}
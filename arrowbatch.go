@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"io/ioutil"
+)
+
+const arrowBatchesKey contextKey = "arrowBatches"
+
+// WithArrowBatches returns a context that keeps the results of the queries run with it in Arrow, to be read by
+// SnowflakeRows.GetArrowBatches instead of Next. The rows are available only from the driver connection, e.g.,
+// in sql.Conn.Raw:
+//
+//	err = conn.Raw(func(dc interface{}) error {
+//		rows, err := dc.(driver.QueryerContext).QueryContext(sf.WithArrowBatches(ctx), query, nil)
+//		if err != nil {
+//			return err
+//		}
+//		defer rows.Close()
+//		batches, err := rows.(sf.SnowflakeRows).GetArrowBatches()
+//		...
+//	})
+func WithArrowBatches(ctx context.Context) context.Context {
+	return context.WithValue(ctx, arrowBatchesKey, true)
+}
+
+func isArrowBatchesMode(ctx context.Context) bool {
+	v, ok := ctx.Value(arrowBatchesKey).(bool)
+	return ok && v
+}
+
+// SnowflakeRows is the interface of the rows of the driver beyond database/sql/driver.
+type SnowflakeRows interface {
+	driver.Rows
+	// GetArrowBatches returns the chunks of the current result set in Arrow. The query must be run with
+	// WithArrowBatches.
+	GetArrowBatches() ([]*ArrowBatch, error)
+	// QueryID returns the ID of the query of the current result.
+	QueryID() string
+	// SeekRow moves to the row at the offset of the current result, so that Next returns the row. The chunks
+	// before the row are not downloaded.
+	SeekRow(offset int64) error
+}
+
+// ArrowBatch is a chunk of a result set in Arrow. The chunks can be fetched concurrently.
+type ArrowBatch struct {
+	// RowCount is the number of rows in the chunk.
+	RowCount int
+
+	data []byte // the first chunk, returned in the query response
+	scd  *snowflakeChunkDownloader
+	idx  int
+}
+
+// Fetch returns the chunk in the Arrow IPC stream format: the schema followed by the record batches, which any
+// Arrow implementation reads without converting the values, e.g., ipc.NewReader of the Go Arrow library. The
+// chunks other than the first one are downloaded from the cloud storage.
+func (b *ArrowBatch) Fetch(ctx context.Context) ([]byte, error) {
+	if b.scd == nil {
+		return b.data, nil
+	}
+	body, err := b.scd.getChunkBody(ctx, b.idx)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// GetArrowBatches returns the chunks of the current result set in Arrow. The first batch is the chunk returned in
+// the query response, if any.
+func (rows *snowflakeRows) GetArrowBatches() ([]*ArrowBatch, error) {
+	if !rows.arrowBatches {
+		return nil, &SnowflakeError{
+			Number:  ErrNoArrowBatches,
+			Message: errMsgNoArrowBatchesMode,
+		}
+	}
+	data := rows.result.Data
+	if data.QueryResultFormat != queryResultFormatArrow {
+		return nil, &SnowflakeError{
+			Number:      ErrNoArrowBatches,
+			Message:     errMsgNoArrowBatchesFormat,
+			MessageArgs: []interface{}{data.QueryResultFormat},
+		}
+	}
+	var batches []*ArrowBatch
+	if data.RowSetBase64 != "" {
+		b, err := base64.StdEncoding.DecodeString(data.RowSetBase64)
+		if err != nil {
+			return nil, &SnowflakeError{
+				Number:      ErrInvalidArrowData,
+				Message:     errMsgInvalidArrowData,
+				MessageArgs: []interface{}{err},
+				Err:         err,
+			}
+		}
+		rowCount := data.Total
+		for _, c := range data.Chunks {
+			rowCount -= int64(c.RowCount)
+		}
+		batches = append(batches, &ArrowBatch{RowCount: int(rowCount), data: b})
+	}
+	for i, c := range data.Chunks {
+		batches = append(batches, &ArrowBatch{RowCount: c.RowCount, scd: rows.ChunkDownloader, idx: i})
+	}
+	return batches, nil
+}
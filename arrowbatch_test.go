@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var _ SnowflakeRows = &snowflakeRows{}
+
+func TestArrowBatches(t *testing.T) {
+	rowType := []execResponseRowType{{Name: "C1", Type: "fixed"}}
+	chunk := func(values ...int64) []byte {
+		return testArrowStream([]testArrowColumn{arrowIntColumn("C1", 64, values)})
+	}
+	chunks := map[string][]byte{"chunk1": chunk(3), "chunk2": chunk(4, 5)}
+	data := &execResponse{Data: execResponseData{
+		RowType:           rowType,
+		QueryResultFormat: queryResultFormatArrow,
+		RowSetBase64:      base64.StdEncoding.EncodeToString(chunk(1, 2)),
+		Chunks:            []execResponseChunk{{URL: "chunk1", RowCount: 1}, {URL: "chunk2", RowCount: 2}},
+		Total:             5,
+	}}
+	ctx := WithArrowBatches(context.Background())
+	rows := &snowflakeRows{sc: getDefaultSnowflakeConn(), ctx: ctx, arrowBatches: isArrowBatchesMode(ctx)}
+	if err := rows.setResult(data); err != nil {
+		t.Fatalf("failed to set the result. err: %v", err)
+	}
+	var fetched []string
+	rows.ChunkDownloader.FuncGet = func(_ context.Context, _ *snowflakeChunkDownloader, url string, _ map[string]string, _ time.Duration) (*http.Response, error) {
+		fetched = append(fetched, url)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(chunks[url]))}, nil
+	}
+	if len(fetched) != 0 {
+		t.Errorf("should not have downloaded the chunks before Fetch. fetched: %v", fetched)
+	}
+	batches, err := rows.GetArrowBatches()
+	if err != nil {
+		t.Fatalf("failed to get the Arrow batches. err: %v", err)
+	}
+	expected := [][]string{{"1", "2"}, {"3"}, {"4", "5"}}
+	if len(batches) != len(expected) {
+		t.Fatalf("failed to get all batches. expected: %v, got: %v", len(expected), len(batches))
+	}
+	for i, b := range batches {
+		if b.RowCount != len(expected[i]) {
+			t.Errorf("%d: failed to get the row count. expected: %v, got: %v", i, len(expected[i]), b.RowCount)
+		}
+		stream, err := b.Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%d: failed to fetch the batch. err: %v", i, err)
+		}
+		chunk, err := decodeArrowChunk(bytes.NewReader(stream), rowType)
+		if err != nil {
+			t.Fatalf("%d: failed to decode the batch. err: %v", i, err)
+		}
+		for j, v := range chunk.values {
+			if v[0] != expected[i][j] {
+				t.Errorf("%d: failed to get the value. expected: %v, got: %v", i, expected[i][j], v[0])
+			}
+		}
+	}
+	if len(fetched) != 2 {
+		t.Errorf("failed to download the chunks. fetched: %v", fetched)
+	}
+	if err = rows.Next(make([]driver.Value, 1)); err == nil {
+		t.Error("should have failed to read the rows by Next")
+	} else if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrArrowBatchesOnly {
+		t.Errorf("failed to return the error code. err: %v", err)
+	}
+}
+
+func TestArrowBatchesError(t *testing.T) {
+	testcases := []struct {
+		name         string
+		arrowBatches bool
+		format       string
+	}{
+		{name: "no context", arrowBatches: false, format: queryResultFormatArrow},
+		{name: "json", arrowBatches: true, format: "json"},
+	}
+	for _, test := range testcases {
+		rows := &snowflakeRows{sc: getDefaultSnowflakeConn(), ctx: context.Background(), arrowBatches: test.arrowBatches}
+		data := &execResponse{Data: execResponseData{QueryResultFormat: test.format}}
+		if err := rows.setResult(data); err != nil {
+			t.Fatalf("%v: failed to set the result. err: %v", test.name, err)
+		}
+		if _, err := rows.GetArrowBatches(); err == nil {
+			t.Errorf("%v: should have failed to get the Arrow batches", test.name)
+		} else if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrNoArrowBatches {
+			t.Errorf("%v: failed to return the error code. err: %v", test.name, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package gosnowflake
 
 import (
 	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -26,8 +27,41 @@ const (
 	authenticatorOAuth           = "OAUTH"
 	authenticatorSnowflake       = "SNOWFLAKE"
 	authenticatorOkta            = "OKTA"
+	// authenticatorAuto resolves the credentials with the credential provider chain
+	authenticatorAuto             = "AUTO"
+	authenticatorWorkloadIdentity = "WORKLOAD_IDENTITY"
+	authenticatorJWT              = "SNOWFLAKE_JWT"
+	// authenticatorUsernamePasswordMFA logs in with the password and the cached MFA token
+	authenticatorUsernamePasswordMFA = "USERNAME_PASSWORD_MFA"
 )
 
+// knownAuthenticators are the authenticators other than the Okta URLs.
+var knownAuthenticators = map[string]bool{
+	authenticatorExternalBrowser:     true,
+	authenticatorOAuth:               true,
+	authenticatorSnowflake:           true,
+	authenticatorAuto:                true,
+	authenticatorWorkloadIdentity:    true,
+	authenticatorJWT:                 true,
+	authenticatorUsernamePasswordMFA: true,
+}
+
+// checkAuthenticator fails if the authenticator is neither a known one nor an Okta URL, so that a misspelled
+// authenticator doesn't fall back to the password login.
+func checkAuthenticator(authenticator string) error {
+	if knownAuthenticators[strings.ToUpper(authenticator)] || isOktaURL(authenticator) {
+		return nil
+	}
+	return &SnowflakeError{
+		Number:      ErrCodeUnknownAuthenticator,
+		Message:     errMsgUnknownAuthenticator,
+		MessageArgs: []interface{}{authenticator},
+	}
+}
+
+// clientRequestMFAToken is the session parameter to request the MFA token at login.
+const clientRequestMFAToken = "CLIENT_REQUEST_MFA_TOKEN"
+
 // platform consists of compiler and architecture type in string
 var platform = fmt.Sprintf("%v-%v", runtime.Compiler, runtime.GOARCH)
 
@@ -58,6 +92,7 @@ type authRequestData struct {
 	BrowserModeRedirectPort string                       `json:"BROWSER_MODE_REDIRECT_PORT,omitempty"`
 	ProofKey                string                       `json:"PROOF_KEY,omitempty"`
 	Token                   string                       `json:"TOKEN,omitempty"`
+	Provider                string                       `json:"PROVIDER,omitempty"`
 }
 type authRequest struct {
 	Data authRequestData `json:"data"`
@@ -93,6 +128,7 @@ type authResponseMain struct {
 	TokenURL                string                  `json:"tokenUrl,omitempty"`
 	SSOURL                  string                  `json:"ssoUrl,omitempty"`
 	ProofKey                string                  `json:"proofKey,omitempty"`
+	MfaToken                string                  `json:"mfaToken,omitempty"`
 }
 type authResponse struct {
 	Data    authResponseMain `json:"data"`
@@ -102,6 +138,7 @@ type authResponse struct {
 }
 
 func postAuth(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	params *url.Values,
 	headers map[string]string,
@@ -109,11 +146,9 @@ func postAuth(
 	timeout time.Duration) (
 	data *authResponse, err error) {
 	params.Add("requestId", uuid.New().String())
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port,
-		"/session/v1/login-request?"+params.Encode())
+	fullURL := sr.getFullURL(endpointLogin, params)
 	glog.V(2).Infof("full URL: %v", fullURL)
-	resp, err := sr.FuncPost(context.TODO(), sr, fullURL, headers, body, timeout, true)
+	resp, err := postWithCircuitBreaker(ctx, sr, fullURL, headers, body, timeout, true)
 	if err != nil {
 		return nil, err
 	}
@@ -173,8 +208,18 @@ func getHeaders() map[string]string {
 	return headers
 }
 
+// getAuthenticatorType returns the authenticator of the authenticator parameter in upper case, or
+// authenticatorOkta for the Okta URL.
+func getAuthenticatorType(authenticator string) string {
+	if isOktaURL(authenticator) {
+		return authenticatorOkta
+	}
+	return strings.ToUpper(authenticator)
+}
+
 // Used to authenticate the user with Snowflake.
 func authenticate(
+	ctx context.Context,
 	sc *snowflakeConn,
 	samlResponse []byte,
 	proofKey []byte,
@@ -192,6 +237,18 @@ func authenticate(
 		// upper casing to normalize keys
 		sessionParameters[strings.ToUpper(k)] = *v
 	}
+	if _, ok := sessionParameters[queryResultFormatParam]; !ok && (sc.cfg.ResultFormat == ResultFormatArrow || sc.cfg.ResultFormat == ResultFormatVerify) {
+		sessionParameters[queryResultFormatParam] = "ARROW"
+	}
+	if sc.cfg.GeoOutputFormat != "" {
+		for _, k := range []string{sessionGeographyOutputFormat, sessionGeometryOutputFormat} {
+			if _, ok := sessionParameters[strings.ToUpper(k)]; !ok {
+				sessionParameters[strings.ToUpper(k)] = string(sc.cfg.GeoOutputFormat)
+			}
+		}
+	}
+
+	sc.loginParams = sessionParameters
 
 	requestMain := authRequestData{
 		ClientAppID:       clientType,
@@ -201,7 +258,7 @@ func authenticate(
 		ClientEnvironment: clientEnvironment,
 	}
 
-	authenticator := strings.ToUpper(sc.cfg.Authenticator)
+	authenticator := getAuthenticatorType(sc.cfg.Authenticator)
 	switch authenticator {
 	case authenticatorExternalBrowser:
 		requestMain.ProofKey = string(proofKey)
@@ -212,6 +269,20 @@ func authenticate(
 		requestMain.LoginName = sc.cfg.User
 		requestMain.Authenticator = authenticatorOAuth
 		requestMain.Token = sc.cfg.Token
+	case authenticatorWorkloadIdentity:
+		requestMain.Authenticator = authenticatorWorkloadIdentity
+		requestMain.Provider = sc.cfg.workloadIdentityProvider
+		requestMain.Token = sc.cfg.Token
+	case authenticatorJWT:
+		var key *rsa.PrivateKey
+		if key, err = loadPrivateKey(sc.cfg); err != nil {
+			return nil, err
+		}
+		requestMain.LoginName = sc.cfg.User
+		requestMain.Authenticator = authenticatorJWT
+		if requestMain.Token, err = generateJWT(sc.cfg, key); err != nil {
+			return nil, err
+		}
 	case authenticatorOkta:
 		requestMain.RawSAMLResponse = string(samlResponse)
 	case authenticatorSnowflake:
@@ -220,6 +291,12 @@ func authenticate(
 		glog.V(2).Info("Username and password")
 		requestMain.LoginName = sc.cfg.User
 		requestMain.Password = sc.cfg.Password
+		if sc.cfg.ClientRequestMFAToken {
+			// the server skips the MFA prompt for the valid token, and prompts again otherwise
+			requestMain.Authenticator = authenticatorUsernamePasswordMFA
+			requestMain.Token = getCachedMFAToken(sc.cfg)
+			sessionParameters[clientRequestMFAToken] = "true"
+		}
 		switch {
 		case sc.cfg.PasscodeInPassword:
 			requestMain.ExtAuthnDuoMethod = "passcode"
@@ -254,16 +331,18 @@ func authenticate(
 	glog.V(2).Infof("PARAMS for Auth: %v, %v, %v, %v, %v, %v",
 		params, sc.rest.Protocol, sc.rest.Host, sc.rest.Port, sc.rest.LoginTimeout, sc.rest.Authenticator)
 
-	respd, err := sc.rest.FuncPostAuth(sc.rest, params, headers, jsonBody, sc.rest.LoginTimeout)
+	respd, err := sc.rest.FuncPostAuth(ctx, sc.rest, params, headers, jsonBody, sc.rest.LoginTimeout)
 	if err != nil {
 		return nil, err
 	}
 	if !respd.Success {
 		glog.V(1).Infoln("Authentication FAILED")
 		glog.Flush()
-		sc.rest.Token = ""
-		sc.rest.MasterToken = ""
+		sc.rest.setToken("", "", 0)
 		sc.rest.SessionID = -1
+		if requestMain.Authenticator == authenticatorUsernamePasswordMFA && requestMain.Token != "" {
+			setCachedMFAToken(sc.cfg, "")
+		}
 		code, err := strconv.Atoi(respd.Code)
 		if err != nil {
 			code = -1
@@ -276,8 +355,12 @@ func authenticate(
 		}
 	}
 	glog.V(2).Info("Authentication SUCCESS")
-	sc.rest.Token = respd.Data.Token
-	sc.rest.MasterToken = respd.Data.MasterToken
+	if respd.Data.MfaToken != "" {
+		setCachedMFAToken(sc.cfg, respd.Data.MfaToken)
+	}
+	sc.rest.setToken(respd.Data.Token, respd.Data.MasterToken, respd.Data.ValidityInSeconds*time.Second)
 	sc.rest.SessionID = respd.Data.SessionID
+	sc.rest.api = negotiateRESTAPI(respd.Data.ServerVersion)
+	glog.V(2).Infof("server version: %v, protocol version: %v", respd.Data.ServerVersion, sc.rest.api.version)
 	return &respd.Data, nil
 }
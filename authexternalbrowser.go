@@ -6,16 +6,17 @@ package gosnowflake
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/browser"
 )
@@ -53,16 +54,30 @@ func buildResponse(application string) bytes.Buffer {
 	return b
 }
 
-// This opens a socket that listens on all available unicast
-// and any anycast IP addresses locally. By specifying "0", we are
-// able to bind to a free port.
-func bindToPort() (net.Listener, error) {
-	l, err := net.Listen("tcp", "localhost:0")
-	if err != nil {
-		glog.V(1).Infof("unable to bind to a port on localhost,  err: %v", err)
-		return nil, err
+// bindToPort opens a socket listening on localhost for the callback from the browser. The first free port
+// from minPort to maxPort is used, or any free port if minPort is 0, e.g., if the firewall allows only
+// specific ports.
+func bindToPort(minPort, maxPort int) (net.Listener, error) {
+	if minPort == 0 {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			glog.V(1).Infof("unable to bind to a port on localhost,  err: %v", err)
+			return nil, err
+		}
+		return l, nil
+	}
+	if maxPort < minPort {
+		maxPort = minPort
 	}
-	return l, nil
+	var err error
+	for port := minPort; port <= maxPort; port++ {
+		var l net.Listener
+		if l, err = net.Listen("tcp", "localhost:"+strconv.Itoa(port)); err == nil {
+			return l, nil
+		}
+	}
+	glog.V(1).Infof("unable to bind to a port from %v to %v on localhost, err: %v", minPort, maxPort, err)
+	return nil, err
 }
 
 // Opens a browser window (or new tab) with the configured IDP Url.
@@ -81,6 +96,7 @@ func openBrowser(idpURL string) error {
 // Note: FuncPostAuthSaml will return a fully qualified error if
 // there is something wrong getting data from Snowflake.
 func getIdpURLProofKey(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	authenticator string,
 	application string,
@@ -117,7 +133,7 @@ func getIdpURLProofKey(
 		return "", "", err
 	}
 
-	respd, err := sr.FuncPostAuthSAML(sr, headers, jsonBody, sr.LoginTimeout)
+	respd, err := sr.FuncPostAuthSAML(ctx, sr, headers, jsonBody, sr.LoginTimeout)
 	if err != nil {
 		return "", "", err
 	}
@@ -152,6 +168,31 @@ func getTokenFromResponse(response string) (string, error) {
 	return token, nil
 }
 
+// validateSSOURL returns an error unless the SSO URL is an https URL, or of the protocol of the connection, e.g.,
+// http for a test server, so that the browser isn't opened with another scheme. An SSO URL on a Snowflake host must
+// be in the Snowflake domain of the connection, e.g., snowflakecomputing.cn, unless the connection is to a custom
+// DNS name.
+func validateSSOURL(sr *snowflakeRestful, ssoURL string) error {
+	u, err := url.Parse(ssoURL)
+	valid := err == nil && u.Host != "" && (u.Scheme == "https" || u.Scheme == sr.Protocol)
+	if valid {
+		if d := hostDomain(u.Hostname()); d != "" {
+			if connDomain := hostDomain(sr.Host); connDomain != "" && connDomain != d {
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return &SnowflakeError{
+			Number:      ErrCodeSSOURLNotMatch,
+			SQLState:    SQLStateConnectionRejected,
+			Message:     errMsgSSOURLNotMatch,
+			MessageArgs: []interface{}{"https://*" + hostDomain(sr.Host), ssoURL},
+		}
+	}
+	return nil
+}
+
 // Authentication by an external browser takes place via the following:
 // - the golang snowflake driver communicates to Snowflake that the user wishes to
 //   authenticate via external browser
@@ -162,14 +203,18 @@ func getTokenFromResponse(response string) (string, error) {
 // - Snowflake directs the user back to the driver
 // - authenticate is complete!
 func authenticateByExternalBrowser(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	authenticator string,
 	application string,
 	account string,
 	user string,
 	password string,
+	minPort int,
+	maxPort int,
+	timeout time.Duration,
 ) ([]byte, []byte, error) {
-	l, err := bindToPort()
+	l, err := bindToPort(minPort, maxPort)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -177,76 +222,126 @@ func authenticateByExternalBrowser(
 
 	callbackPort := l.Addr().(*net.TCPAddr).Port
 	idpURL, proofKey, err := getIdpURLProofKey(
-		sr, authenticator, application, account, callbackPort)
+		ctx, sr, authenticator, application, account, callbackPort)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err = validateSSOURL(sr, idpURL); err != nil {
+		return nil, nil, err
+	}
 
 	if err = openBrowser(idpURL); err != nil {
 		return nil, nil, err
 	}
 
-	var encodedSamlResponse string
-	var acceptErr error
-	var tokenErr error
-	acceptErr = nil
-	for {
-		conn, err := l.Accept()
-		if err != nil {
-			glog.V(1).Infof("unable to accept connection. err: %v", err)
-			log.Fatal(err)
+	encodedSamlResponse, err := waitForBrowserResponse(ctx, l, application, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	escapedSamlResponse, err := url.QueryUnescape(encodedSamlResponse)
+	if err != nil {
+		glog.V(1).Infof("unable to unescape saml response. err: %v", err)
+		return nil, nil, err
+	}
+	return []byte(escapedSamlResponse), []byte(proofKey), nil
+}
+
+// browserResponse is the result of a callback from the browser.
+type browserResponse struct {
+	token string
+	err   error
+}
+
+// waitForBrowserResponse returns the token of the first callback from the browser to the listener. It fails
+// with ErrExternalBrowserTimeout if no callback arrives within the timeout, e.g., the user never completes SSO,
+// or with the error of the context if it is done first.
+func waitForBrowserResponse(ctx context.Context, l net.Listener, application string, timeout time.Duration) (string, error) {
+	done := make(chan struct{})
+	defer close(done)
+	ch := make(chan browserResponse, 1)
+	send := func(r browserResponse) {
+		select {
+		case ch <- r:
+		case <-done:
 		}
-		go func(c net.Conn) {
-			var buf bytes.Buffer
-			total := 0
-			for {
-				b := make([]byte, bufSize)
-				n, err := c.Read(b)
-				if err != nil {
-					if err != io.EOF {
-						glog.V(1).Infof("error reading from socket. err: %v", err)
-						acceptErr = err
-					}
-					break
-				}
-				total += n
-				buf.Write(b)
-				if n < bufSize {
-					// We successfully read all data
-					s := string(buf.Bytes()[:total])
-					encodedSamlResponse, tokenErr = getTokenFromResponse(s)
-					break
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				select {
+				case <-done:
+					// the listener is closed after the response
+				default:
+					glog.V(1).Infof("unable to accept connection. err: %v", err)
+					send(browserResponse{err: err})
 				}
-				buf.Grow(bufSize)
-			}
-			if encodedSamlResponse != "" {
-				httpResponse := buildResponse(application)
-				c.Write(httpResponse.Bytes())
+				return
 			}
-			c.Close()
-		}(conn)
-		if acceptErr != nil || encodedSamlResponse != "" {
-			break
+			// the browser may open more connections than it uses, so the connections are read concurrently
+			go func(c net.Conn) {
+				defer c.Close()
+				token, err := readBrowserResponse(c, application)
+				if err != nil || token != "" {
+					send(browserResponse{token: token, err: err})
+				}
+			}(conn)
 		}
-	}
-
-	if tokenErr != nil {
-		return nil, nil, tokenErr
-	}
+	}()
 
-	if acceptErr != nil {
-		return nil, nil, &SnowflakeError{
+	select {
+	case r := <-ch:
+		if r.err == nil {
+			return r.token, nil
+		}
+		if _, ok := r.err.(*SnowflakeError); ok {
+			return "", r.err
+		}
+		return "", &SnowflakeError{
 			Number:      ErrFailedToGetExternalBrowserResponse,
 			SQLState:    SQLStateConnectionRejected,
 			Message:     errMsgFailedToGetExternalBrowserResponse,
-			MessageArgs: []interface{}{acceptErr},
+			MessageArgs: []interface{}{r.err},
+			Err:         r.err,
+		}
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-getClock().After(timeout):
+		return "", &SnowflakeError{
+			Number:      ErrExternalBrowserTimeout,
+			SQLState:    SQLStateConnectionRejected,
+			Message:     errMsgExternalBrowserTimeout,
+			MessageArgs: []interface{}{timeout},
 		}
 	}
+}
 
-	escapedSamlResponse, err := url.QueryUnescape(encodedSamlResponse)
+// readBrowserResponse reads the callback request from the connection and returns the token. It returns an
+// empty token if the connection is closed without a request.
+func readBrowserResponse(c net.Conn, application string) (string, error) {
+	var buf bytes.Buffer
+	b := make([]byte, bufSize)
+	for {
+		n, err := c.Read(b)
+		if err != nil {
+			if err != io.EOF {
+				glog.V(1).Infof("error reading from socket. err: %v", err)
+				return "", err
+			}
+			return "", nil
+		}
+		buf.Write(b[:n])
+		if n < bufSize {
+			// We successfully read all data
+			break
+		}
+	}
+	token, err := getTokenFromResponse(buf.String())
 	if err != nil {
-		glog.V(1).Infof("unable to unescape saml response. err: %v", err)
-		return nil, nil, err
+		return "", err
 	}
-	return []byte(escapedSamlResponse), []byte(proofKey), nil
+	httpResponse := buildResponse(application)
+	c.Write(httpResponse.Bytes())
+	return token, nil
 }
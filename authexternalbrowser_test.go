@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUnitBindToPort(t *testing.T) {
+	busy, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen. err: %v", err)
+	}
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	l, err := bindToPort(port, port+20)
+	if err != nil {
+		t.Fatalf("failed to bind to a port from %v. err: %v", port, err)
+	}
+	got := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	if got <= port || got > port+20 {
+		t.Errorf("failed to bind to a free port in the range. range: %v-%v, got: %v", port, port+20, got)
+	}
+	if _, err = bindToPort(port, port); err == nil {
+		t.Errorf("should have failed to bind to the port in use: %v", port)
+	}
+}
+
+func TestUnitValidateSSOURL(t *testing.T) {
+	testcases := []struct {
+		host   string
+		ssoURL string
+		valid  bool
+	}{
+		{host: "a.snowflakecomputing.com", ssoURL: "https://example.okta.com/app/sso/saml", valid: true},
+		{host: "a.snowflakecomputing.com", ssoURL: "https://a.snowflakecomputing.com/fed/login", valid: true},
+		{host: "a.us-east-1.privatelink.snowflakecomputing.com", ssoURL: "https://a.snowflakecomputing.com/fed/login", valid: true},
+		{host: "a.snowflakecomputing.cn", ssoURL: "https://a.snowflakecomputing.cn/fed/login", valid: true},
+		{host: "a.snowflakecomputing.cn", ssoURL: "https://a.snowflakecomputing.com/fed/login", valid: false},
+		{host: "snowflake.example.com", ssoURL: "https://a.snowflakecomputing.com/fed/login", valid: true},
+		{host: "a.snowflakecomputing.com", ssoURL: "http://example.okta.com/app/sso/saml", valid: false},
+		{host: "a.snowflakecomputing.com", ssoURL: "file:///etc/passwd", valid: false},
+		{host: "a.snowflakecomputing.com", ssoURL: "", valid: false},
+	}
+	for _, tc := range testcases {
+		err := validateSSOURL(&snowflakeRestful{Protocol: "https", Host: tc.host}, tc.ssoURL)
+		if tc.valid && err != nil {
+			t.Errorf("failed to validate the SSO URL. host: %v, url: %v, err: %v", tc.host, tc.ssoURL, err)
+		}
+		if !tc.valid {
+			if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodeSSOURLNotMatch {
+				t.Errorf("should have rejected the SSO URL. host: %v, url: %v, err: %v", tc.host, tc.ssoURL, err)
+			}
+		}
+	}
+}
+
+func TestUnitWaitForBrowserResponse(t *testing.T) {
+	l, err := bindToPort(0, 0)
+	if err != nil {
+		t.Fatalf("failed to bind to a port. err: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		// a connection without a request, then the callback
+		idle, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer idle.Close()
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		fmt.Fprintf(c, "GET /?token=abc%%3D HTTP/1.1\r\nHost: %v\r\n\r\n", l.Addr())
+		c.Read(make([]byte, bufSize))
+	}()
+	token, err := waitForBrowserResponse(context.Background(), l, "app", 10*time.Second)
+	if err != nil {
+		t.Fatalf("failed to get the response. err: %v", err)
+	}
+	if token != "abc%3D" {
+		t.Errorf("failed to get the token. expected: %v, got: %v", "abc%3D", token)
+	}
+}
+
+func TestUnitWaitForBrowserResponseTimeout(t *testing.T) {
+	l, err := bindToPort(0, 0)
+	if err != nil {
+		t.Fatalf("failed to bind to a port. err: %v", err)
+	}
+	defer l.Close()
+	_, err = waitForBrowserResponse(context.Background(), l, "app", 10*time.Millisecond)
+	se, ok := err.(*SnowflakeError)
+	if !ok || se.Number != ErrExternalBrowserTimeout {
+		t.Errorf("should have timed out. err: %v", err)
+	}
+}
+
+func TestUnitWaitForBrowserResponseCanceled(t *testing.T) {
+	l, err := bindToPort(0, 0)
+	if err != nil {
+		t.Fatalf("failed to bind to a port. err: %v", err)
+	}
+	defer l.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = waitForBrowserResponse(ctx, l, "app", 10*time.Second)
+	if err != context.Canceled {
+		t.Errorf("should have been canceled. err: %v", err)
+	}
+}
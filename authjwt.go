@@ -0,0 +1,207 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtExpiration is the validity of the JWT sent at login. Snowflake accepts up to 1 hour.
+var jwtExpiration = 60 * time.Second
+
+// jwtMinRSABits is the minimum RSA key size in FIPS mode.
+const jwtMinRSABits = 2048
+
+// jwtInvalidCode is the login error number for the JWT rejected by Snowflake, e.g., signed by a key whose public
+// key isn't set to the user.
+const jwtInvalidCode = 390144
+
+// numPrivateKeys returns the number of the private keys of the key rotation, i.e., Config.PrivateKey or
+// Config.PrivateKeyFile followed by Config.PrivateKeys.
+func numPrivateKeys(cfg *Config) int {
+	n := len(cfg.PrivateKeys)
+	if cfg.PrivateKey != nil || cfg.PrivateKeyFile != "" {
+		n++
+	}
+	return n
+}
+
+// loadPrivateKey returns the private key of the key rotation for the login: Config.PrivateKey or the key read
+// from Config.PrivateKeyFile first, and then Config.PrivateKeys in order. The file is read at every login, so
+// that a rotated key is picked up by the new connections.
+func loadPrivateKey(cfg *Config) (*rsa.PrivateKey, error) {
+	i := cfg.privateKeyIndex
+	if cfg.PrivateKey != nil || cfg.PrivateKeyFile != "" {
+		if i == 0 {
+			if cfg.PrivateKey != nil {
+				return cfg.PrivateKey, nil
+			}
+			return readPrivateKeyFile(cfg.PrivateKeyFile)
+		}
+		i--
+	}
+	if i < len(cfg.PrivateKeys) {
+		return cfg.PrivateKeys[i], nil
+	}
+	return nil, ErrEmptyPrivateKey
+}
+
+// keyRotation is the private key of the key rotation accepted by the last login of the connections sharing a config,
+// e.g., of a connector, so that a new connection starts with it rather than with a key already rejected.
+type keyRotation struct {
+	mu    sync.Mutex
+	index int
+}
+
+func (r *keyRotation) get() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.index
+}
+
+func (r *keyRotation) set(index int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.index = index
+}
+
+// authenticateWithKeyRotation logs in with the private keys of the key rotation in turn while the JWT is rejected,
+// starting with the key accepted last by the connections sharing the config, and records the key accepted.
+func authenticateWithKeyRotation(ctx context.Context, sc *snowflakeConn, samlResponse []byte, proofKey []byte) (*authResponseMain, error) {
+	rotation := sc.cfg.keyRotation
+	n := numPrivateKeys(sc.cfg)
+	if rotation != nil && rotation.get() < n {
+		sc.cfg.privateKeyIndex = rotation.get()
+	}
+	authData, err := authenticate(ctx, sc, samlResponse, proofKey)
+	for tried := 1; err != nil && isRejectedJWT(err) && tried < n; tried++ {
+		// the public key of the user may be rotated. retry with the next private key.
+		sc.cfg.privateKeyIndex = (sc.cfg.privateKeyIndex + 1) % n
+		glog.V(1).Infof("JWT is rejected. retrying with the private key %v. err: %v", sc.cfg.privateKeyIndex, err)
+		authData, err = authenticate(ctx, sc, samlResponse, proofKey)
+	}
+	if err == nil && rotation != nil {
+		rotation.set(sc.cfg.privateKeyIndex)
+	}
+	return authData, err
+}
+
+// isRejectedJWT returns true if the login failed because of the JWT, so that the next private key may be tried.
+func isRejectedJWT(err error) bool {
+	se, ok := err.(*SnowflakeError)
+	return ok && se.Number == jwtInvalidCode
+}
+
+// readPrivateKeyFile reads the unencrypted PEM encoded RSA private key.
+func readPrivateKeyFile(file string) (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, privateKeyError(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, privateKeyError(fmt.Errorf("no PEM data in %v", file))
+	}
+	if x509.IsEncryptedPEMBlock(block) {
+		return nil, privateKeyError(fmt.Errorf("encrypted private keys are not supported"))
+	}
+	return parsePrivateKey(block.Bytes)
+}
+
+// parsePrivateKey parses a PKCS #8 or PKCS #1 DER encoded RSA private key.
+func parsePrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, privateKeyError(err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, privateKeyError(fmt.Errorf("not an RSA private key: %T", key))
+	}
+	return rsaKey, nil
+}
+
+func privateKeyError(err error) error {
+	return &SnowflakeError{
+		Number:      ErrCodePrivateKeyParseError,
+		Message:     errMsgPrivateKeyParseError,
+		MessageArgs: []interface{}{err},
+		Err:         err,
+	}
+}
+
+// publicKeyFingerprint returns the fingerprint of the public key registered to the user with
+// ALTER USER ... SET RSA_PUBLIC_KEY, i.e., SHA256:<base64 of the SHA-256 digest of the DER encoded key>.
+func publicKeyFingerprint(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// generateJWT returns the JWT signed with RS256 for the key pair authentication of the user.
+func generateJWT(cfg *Config, key *rsa.PrivateKey) (string, error) {
+	if isFIPSMode() && key.N.BitLen() < jwtMinRSABits {
+		return "", privateKeyError(fmt.Errorf("RSA key of %v bits is not allowed in FIPS mode", key.N.BitLen()))
+	}
+	fingerprint, err := publicKeyFingerprint(key)
+	if err != nil {
+		return "", privateKeyError(err)
+	}
+	// the account name excludes the region
+	account := strings.ToUpper(cfg.Account)
+	if i := strings.Index(account, "."); i > 0 {
+		account = account[:i]
+	}
+	subject := account + "." + strings.ToUpper(cfg.User)
+	now := getClock().Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": subject + "." + fingerprint,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(jwtExpiration).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// KeyPairJWT returns a JWT for the key pair authentication of the user of the config with Config.PrivateKey,
+// Config.PrivateKeyFile or the first of Config.PrivateKeys, e.g., to call the Snowflake REST APIs that accept KEYPAIR_JWT tokens, such as the
+// Snowpipe REST API. The JWT is valid for a minute, so generate one per request.
+func KeyPairJWT(cfg *Config) (string, error) {
+	key, err := loadPrivateKey(cfg)
+	if err != nil {
+		return "", err
+	}
+	return generateJWT(cfg, key)
+}
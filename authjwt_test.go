@@ -0,0 +1,251 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testPrivateKey *rsa.PrivateKey
+
+func getTestPrivateKey(t *testing.T) *rsa.PrivateKey {
+	if testPrivateKey == nil {
+		var err error
+		if testPrivateKey, err = rsa.GenerateKey(rand.Reader, 1024); err != nil {
+			t.Fatalf("failed to generate a key. err: %v", err)
+		}
+	}
+	return testPrivateKey
+}
+
+// verifyJWT verifies the signature of the JWT and returns the claims.
+func verifyJWT(token string, key *rsa.PublicKey) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	err = json.Unmarshal(b, &claims)
+	return claims, err
+}
+
+func TestUnitAuthenticateJWT(t *testing.T) {
+	key := getTestPrivateKey(t)
+	var ar authRequest
+	sr := &snowflakeRestful{
+		FuncPostAuth: func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, jsonBody []byte, _ time.Duration) (*authResponse, error) {
+			if err := json.Unmarshal(jsonBody, &ar); err != nil {
+				return nil, err
+			}
+			return &authResponse{Success: true, Data: authResponseMain{Token: "t", MasterToken: "m"}}, nil
+		},
+	}
+	sc := getDefaultSnowflakeConn()
+	sc.cfg.Account = "testaccount.us-east-1"
+	sc.cfg.User = "etl_svc"
+	sc.cfg.Password = ""
+	sc.cfg.Authenticator = "snowflake_jwt"
+	sc.cfg.PrivateKey = key
+	sc.rest = sr
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if ar.Data.Authenticator != authenticatorJWT || ar.Data.LoginName != "etl_svc" || ar.Data.Password != "" {
+		t.Fatalf("failed to send the JWT login request. data: %v", ar.Data)
+	}
+	claims, err := verifyJWT(ar.Data.Token, &key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to verify the JWT. err: %v", err)
+	}
+	fingerprint, _ := publicKeyFingerprint(key)
+	if claims["sub"] != "TESTACCOUNT.ETL_SVC" || claims["iss"] != "TESTACCOUNT.ETL_SVC."+fingerprint {
+		t.Errorf("failed to set the subject and issuer. claims: %v", claims)
+	}
+	if exp, iat := claims["exp"].(float64), claims["iat"].(float64); exp-iat != jwtExpiration.Seconds() {
+		t.Errorf("failed to set the expiration. claims: %v", claims)
+	}
+}
+
+func TestAuthenticateWithKeyRotation(t *testing.T) {
+	oldKey := getTestPrivateKey(t)
+	newKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate a key. err: %v", err)
+	}
+	accepted := newKey
+	calls := 0
+	postAuth := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, jsonBody []byte, _ time.Duration) (*authResponse, error) {
+		calls++
+		var ar authRequest
+		if err := json.Unmarshal(jsonBody, &ar); err != nil {
+			return nil, err
+		}
+		if _, err := verifyJWT(ar.Data.Token, &accepted.PublicKey); err != nil {
+			return &authResponse{Success: false, Code: strconv.Itoa(jwtInvalidCode), Message: "JWT token is invalid."}, nil
+		}
+		return &authResponse{Success: true, Data: authResponseMain{Token: "t", MasterToken: "m"}}, nil
+	}
+	shared := Config{Account: "a", User: "u", Authenticator: "snowflake_jwt",
+		PrivateKeys: []*rsa.PrivateKey{oldKey, newKey}, keyRotation: &keyRotation{}}
+	login := func() int {
+		calls = 0
+		cfg := shared
+		sc := getDefaultSnowflakeConn()
+		sc.cfg = &cfg
+		sc.rest = &snowflakeRestful{FuncPostAuth: postAuth}
+		if _, err := authenticateWithKeyRotation(context.Background(), sc, nil, nil); err != nil {
+			t.Fatalf("failed to authenticate. err: %v", err)
+		}
+		return calls
+	}
+	if n := login(); n != 2 {
+		t.Errorf("should have retried with the next key. calls: %v", n)
+	}
+	if n := login(); n != 1 {
+		t.Errorf("should have started with the key accepted last. calls: %v", n)
+	}
+	accepted = oldKey
+	if n := login(); n != 2 || shared.keyRotation.get() != 0 {
+		t.Errorf("should have wrapped around to the first key. calls: %v, index: %v", n, shared.keyRotation.get())
+	}
+	calls = 0
+	cfg := shared
+	sc := getDefaultSnowflakeConn()
+	sc.cfg = &cfg
+	sc.rest = &snowflakeRestful{FuncPostAuth: func(ctx context.Context, sr *snowflakeRestful, params *url.Values, headers map[string]string, body []byte, timeout time.Duration) (*authResponse, error) {
+		calls++
+		return &authResponse{Success: false, Code: strconv.Itoa(jwtInvalidCode), Message: "JWT token is invalid."}, nil
+	}}
+	if _, err = authenticateWithKeyRotation(context.Background(), sc, nil, nil); !isRejectedJWT(err) || calls != 2 {
+		t.Errorf("should have failed after trying every key once. calls: %v, err: %v", calls, err)
+	}
+}
+
+func TestJWTFIPSMode(t *testing.T) {
+	fipsModeMutex.Lock()
+	orig := fipsMode
+	fipsMode = true
+	fipsModeMutex.Unlock()
+	defer func() {
+		fipsModeMutex.Lock()
+		fipsMode = orig
+		fipsModeMutex.Unlock()
+	}()
+	_, err := generateJWT(&Config{Account: "a", User: "u"}, getTestPrivateKey(t))
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodePrivateKeyParseError {
+		t.Errorf("should have rejected the 1024 bits key. err: %v", err)
+	}
+}
+
+func TestKeyPairJWT(t *testing.T) {
+	key := getTestPrivateKey(t)
+	token, err := KeyPairJWT(&Config{Account: "a.us-east-1", User: "u", PrivateKey: key})
+	if err != nil {
+		t.Fatalf("failed to generate the JWT. err: %v", err)
+	}
+	claims, err := verifyJWT(token, &key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to verify the JWT. err: %v", err)
+	}
+	if claims["sub"] != "A.U" {
+		t.Errorf("failed to set the subject. claims: %v", claims)
+	}
+	if _, err = KeyPairJWT(&Config{Account: "a", User: "u"}); err != ErrEmptyPrivateKey {
+		t.Errorf("should have failed without a private key. err: %v", err)
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	key := getTestPrivateKey(t)
+	dir, err := ioutil.TempDir("", "gosnowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, typ string, der []byte) string {
+		f := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(f, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{
+		write("pkcs1.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		write("pkcs8.pem", "PRIVATE KEY", pkcs8),
+	} {
+		loaded, err := loadPrivateKey(&Config{PrivateKeyFile: f})
+		if err != nil {
+			t.Errorf("failed to load the private key. file: %v, err: %v", f, err)
+			continue
+		}
+		if loaded.N.Cmp(key.N) != 0 {
+			t.Errorf("failed to load the same key. file: %v", f)
+		}
+	}
+	for _, f := range []string{filepath.Join(dir, "missing.pem"), write("cert.pem", "CERTIFICATE", []byte("junk"))} {
+		_, err = loadPrivateKey(&Config{PrivateKeyFile: f})
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodePrivateKeyParseError {
+			t.Errorf("should have failed to load the private key. file: %v, err: %v", f, err)
+		}
+	}
+	if _, err = loadPrivateKey(&Config{}); err != ErrEmptyPrivateKey {
+		t.Errorf("should have failed without the private key. err: %v", err)
+	}
+}
+
+func TestParseDSNPrivateKey(t *testing.T) {
+	key := getTestPrivateKey(t)
+	dsn, err := DSN(&Config{Account: "a", User: "u", Authenticator: "snowflake_jwt", PrivateKey: key})
+	if err != nil {
+		t.Fatalf("failed to create DSN. err: %v", err)
+	}
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("failed to parse DSN. dsn: %v, err: %v", dsn, err)
+	}
+	if cfg.PrivateKey == nil || cfg.PrivateKey.N.Cmp(key.N) != 0 {
+		t.Errorf("failed to parse the private key. dsn: %v", dsn)
+	}
+	if cfg, err = ParseDSN("u@a.snowflakecomputing.com?authenticator=snowflake_jwt&privateKeyFile=%2Fkeys%2Frsa_key.p8"); err != nil || cfg.PrivateKeyFile != "/keys/rsa_key.p8" {
+		t.Errorf("failed to parse the private key file. cfg: %v, err: %v", cfg, err)
+	}
+	if _, err = ParseDSN("u@a.snowflakecomputing.com?authenticator=snowflake_jwt"); err != ErrEmptyPrivateKey {
+		t.Errorf("should have failed without the private key. err: %v", err)
+	}
+	if _, err = ParseDSN("u@a.snowflakecomputing.com?authenticator=snowflake_jwt&privateKey=junk"); err == nil {
+		t.Errorf("should have failed to parse the private key")
+	}
+}
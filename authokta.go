@@ -23,7 +23,24 @@ type authOKTARequest struct {
 }
 
 type authOKTAResponse struct {
-	CookieToken string `json:"cookieToken"`
+	Status       string `json:"status"`
+	CookieToken  string `json:"cookieToken"`
+	SessionToken string `json:"sessionToken"`
+}
+
+// oneTimeToken returns the token to get the SAML response with. Okta returns the session token instead of the
+// deprecated cookie token by default.
+func (r *authOKTAResponse) oneTimeToken() string {
+	if r.CookieToken != "" {
+		return r.CookieToken
+	}
+	return r.SessionToken
+}
+
+// isOktaURL returns true if the authenticator is the https URL of Okta, e.g., https://<okta_account_name>.okta.com.
+func isOktaURL(authenticator string) bool {
+	u, err := url.Parse(authenticator)
+	return err == nil && u.Scheme == "https" && u.Host != ""
 }
 
 /*
@@ -51,6 +68,7 @@ Explanation:
 	another SP.
 */
 func authenticateBySAML(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	authenticator string,
 	application string,
@@ -84,16 +102,15 @@ func authenticateBySAML(
 	if err != nil {
 		return nil, err
 	}
-	glog.V(2).Infof("PARAMS for Auth: %v, %v", params, sr)
-	respd, err := sr.FuncPostAuthSAML(sr, headers, jsonBody, sr.LoginTimeout)
+	glog.V(2).Infof("PARAMS for Auth: %v, %v, %v, %v", params, sr.Protocol, sr.Host, sr.Port)
+	respd, err := sr.FuncPostAuthSAML(ctx, sr, headers, jsonBody, sr.LoginTimeout)
 	if err != nil {
 		return nil, err
 	}
 	if !respd.Success {
 		glog.V(1).Infoln("Authentication FAILED")
 		glog.Flush()
-		sr.Token = ""
-		sr.MasterToken = ""
+		sr.setToken("", "", 0)
 		sr.SessionID = -1
 		code, err := strconv.Atoi(respd.Code)
 		if err != nil {
@@ -130,19 +147,28 @@ func authenticateBySAML(
 	if err != nil {
 		return nil, err
 	}
-	respa, err := sr.FuncPostAuthOKTA(sr, headers, jsonBody, respd.Data.TokenURL, sr.LoginTimeout)
+	respa, err := sr.FuncPostAuthOKTA(ctx, sr, headers, jsonBody, respd.Data.TokenURL, sr.LoginTimeout)
 	if err != nil {
 		return nil, err
 	}
+	if respa.oneTimeToken() == "" {
+		// e.g., MFA_REQUIRED or PASSWORD_EXPIRED, which need the interaction with the user
+		return nil, &SnowflakeError{
+			Number:      ErrFailedToAuthOKTA,
+			SQLState:    SQLStateConnectionRejected,
+			Message:     errMsgFailedToAuthOKTAStatus,
+			MessageArgs: []interface{}{respa.Status},
+		}
+	}
 
 	glog.V(2).Info("step 4: query IDP URL snowflake app to get SAML response")
 	params = &url.Values{}
 	params.Add("RelayState", "/some/deep/link")
-	params.Add("onetimetoken", respa.CookieToken)
+	params.Add("onetimetoken", respa.oneTimeToken())
 
 	headers = make(map[string]string)
 	headers["accept"] = "*/*"
-	bd, err := sr.FuncGetSSO(sr, params, headers, respd.Data.SSOURL, sr.LoginTimeout)
+	bd, err := sr.FuncGetSSO(ctx, sr, params, headers, respd.Data.SSOURL, sr.LoginTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -200,8 +226,8 @@ func isPrefixEqual(url1 string, url2 string) (bool, error) {
 	if p1 == "" && u1.Scheme == "https" {
 		p1 = "443"
 	}
-	p2 := u1.Port()
-	if p2 == "" && u1.Scheme == "https" {
+	p2 := u2.Port()
+	if p2 == "" && u2.Scheme == "https" {
 		p2 = "443"
 	}
 	return u1.Hostname() == u2.Hostname() && p1 == p2 && u1.Scheme == u2.Scheme, nil
@@ -210,17 +236,17 @@ func isPrefixEqual(url1 string, url2 string) (bool, error) {
 // Makes a request to /session/authenticator-request to get SAML Information,
 // such as the IDP Url and Proof Key, depending on the authenticator
 func postAuthSAML(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	headers map[string]string,
 	body []byte,
 	timeout time.Duration) (
 	data *authResponse, err error) {
-	requestID := fmt.Sprintf("requestId=%v", uuid.New().String())
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port,
-		"/session/authenticator-request?"+requestID)
+	params := &url.Values{}
+	params.Add("requestId", uuid.New().String())
+	fullURL := sr.getFullURL(endpointAuthenticator, params)
 	glog.V(2).Infof("fullURL: %v", fullURL)
-	resp, err := sr.FuncPost(context.TODO(), sr, fullURL, headers, body, timeout, true)
+	resp, err := sr.FuncPost(ctx, sr, fullURL, headers, body, timeout, true)
 	if err != nil {
 		return nil, err
 	}
@@ -270,6 +296,7 @@ func postAuthSAML(
 }
 
 func postAuthOKTA(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	headers map[string]string,
 	body []byte,
@@ -277,7 +304,7 @@ func postAuthOKTA(
 	timeout time.Duration) (
 	data *authOKTAResponse, err error) {
 	glog.V(2).Infof("fullURL: %v", fullURL)
-	resp, err := sr.FuncPost(context.TODO(), sr, fullURL, headers, body, timeout, false)
+	resp, err := sr.FuncPost(ctx, sr, fullURL, headers, body, timeout, false)
 	if err != nil {
 		return nil, err
 	}
@@ -311,6 +338,7 @@ func postAuthOKTA(
 }
 
 func getSSO(
+	ctx context.Context,
 	sr *snowflakeRestful,
 	params *url.Values,
 	headers map[string]string,
@@ -319,7 +347,7 @@ func getSSO(
 	bd []byte, err error) {
 	fullURL := fmt.Sprintf("%s?%s", url, params.Encode())
 	glog.V(2).Infof("fullURL: %v", fullURL)
-	resp, err := sr.FuncGet(context.TODO(), sr, fullURL, headers, timeout)
+	resp, err := sr.FuncGet(ctx, sr, fullURL, headers, timeout)
 	if err != nil {
 		return nil, err
 	}
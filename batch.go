@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+)
+
+// defaultBatchParallelism is the number of the statements of a Batch in flight if Batch.MaxParallel is 0.
+const defaultBatchParallelism = 4
+
+// Batch is a queue of independent statements executed together over the session of a connection by
+// SnowflakeConn.ExecBatch, with up to MaxParallel statements in flight, so that migration and seeding tools
+// don't wait for a round trip per statement. The statements run in any order, so they must not depend on each
+// other. With Go 1.13 or later, the connection is available in sql.Conn.Raw:
+//
+//	var b sf.Batch
+//	b.Queue("INSERT INTO regions VALUES (?, ?)", 1, "EMEA")
+//	b.Queue("INSERT INTO regions VALUES (?, ?)", 2, "APAC")
+//	err = conn.Raw(func(dc interface{}) error {
+//		for _, r := range dc.(sf.SnowflakeConn).ExecBatch(ctx, &b) {
+//			if r.Err != nil {
+//				log.Printf("failed to run %v: %v", r.Query, r.Err)
+//			}
+//		}
+//		return nil
+//	})
+type Batch struct {
+	MaxParallel int // statements in flight. 4 if 0.
+
+	stmts []batchStatement
+}
+
+type batchStatement struct {
+	query string
+	args  []interface{}
+}
+
+// Queue adds the statement with the args to the batch. The args are bound as by sql.DB.ExecContext, including
+// sql.Named.
+func (b *Batch) Queue(query string, args ...interface{}) {
+	b.stmts = append(b.stmts, batchStatement{query: query, args: args})
+}
+
+// Len returns the number of the statements queued.
+func (b *Batch) Len() int {
+	return len(b.stmts)
+}
+
+// BatchResult is the outcome of a statement of a Batch.
+type BatchResult struct {
+	Query  string
+	Result driver.Result // nil if Err is not nil
+	Err    error
+}
+
+// ExecBatch executes the statements of the batch in the session, and returns their results in the order queued.
+// A failing statement doesn't stop the others. The statements not started when ctx is done fail with the error
+// of ctx. Config.MaxConcurrentQueries caps the statements in flight too.
+func (sc *snowflakeConn) ExecBatch(ctx context.Context, b *Batch) []BatchResult {
+	results := make([]BatchResult, len(b.stmts))
+	parallel := b.MaxParallel
+	if parallel <= 0 {
+		parallel = defaultBatchParallelism
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, s := range b.stmts {
+		results[i].Query = s.query
+		select {
+		case sem <- struct{}{}:
+			if err := ctx.Err(); err != nil {
+				// the slot was freed as ctx was done
+				<-sem
+				results[i].Err = err
+				continue
+			}
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(r *BatchResult, s batchStatement) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			args, err := sc.batchArgs(s.args)
+			if err == nil {
+				r.Result, err = sc.ExecContext(ctx, s.query, args)
+			}
+			r.Err = err
+		}(&results[i], s)
+	}
+	wg.Wait()
+	return results
+}
+
+// batchArgs converts the args as database/sql does: the values accepted by CheckNamedValue are bound as is,
+// and the others are converted by driver.DefaultParameterConverter.
+func (sc *snowflakeConn) batchArgs(args []interface{}) ([]driver.NamedValue, error) {
+	ret := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: a}
+		if na, ok := a.(sql.NamedArg); ok {
+			nv.Name = na.Name
+			nv.Value = na.Value
+		}
+		if err := sc.CheckNamedValue(&nv); err == driver.ErrSkip {
+			v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+			if err != nil {
+				return nil, err
+			}
+			nv.Value = v
+		} else if err != nil {
+			return nil, err
+		}
+		ret[i] = nv
+	}
+	return ret, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	var mu sync.Mutex
+	bindings := make(map[string]map[string]execBindParameter)
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		mu.Lock()
+		bindings[req.SQLText] = req.Bindings
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasPrefix(req.SQLText, "INSERT INTO missing") {
+			return &execResponse{Success: false, Code: "2003", Message: "Table 'MISSING' does not exist"}, nil
+		}
+		one := "1"
+		return &execResponse{Success: true, Data: execResponseData{
+			StatementTypeID: statementTypeIDInsert,
+			RowType:         []execResponseRowType{{Name: "number of rows inserted", Type: "fixed"}},
+			RowSet:          [][]*string{{&one}},
+		}}, nil
+	}
+	sc := &snowflakeConn{
+		cfg:  &Config{},
+		rest: &snowflakeRestful{FuncPostQuery: postQuery},
+	}
+
+	var b Batch
+	b.MaxParallel = 2
+	b.Queue("INSERT INTO t1 VALUES (?)", 1)
+	b.Queue("INSERT INTO missing VALUES (1)")
+	b.Queue("INSERT INTO t2 VALUES (:name)", sql.Named("name", "a"))
+	for i := 0; i < 5; i++ {
+		b.Queue("INSERT INTO t3 VALUES (1)")
+	}
+	if b.Len() != 8 {
+		t.Fatalf("failed to queue the statements. len: %v", b.Len())
+	}
+	results := sc.ExecBatch(context.Background(), &b)
+	if len(results) != b.Len() {
+		t.Fatalf("should have returned a result per statement. got: %v", len(results))
+	}
+	for i, r := range results {
+		if r.Query != b.stmts[i].query {
+			t.Errorf("%d: should have returned the results in the order queued. got: %v", i, r.Query)
+		}
+		if i == 1 {
+			if r.Err == nil || r.Result != nil {
+				t.Errorf("should have failed the statement. err: %v", r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("%d: failed to exec. err: %v", i, r.Err)
+			continue
+		}
+		if n, err := r.Result.RowsAffected(); err != nil || n != 1 {
+			t.Errorf("%d: failed to get the rows affected. n: %v, err: %v", i, n, err)
+		}
+	}
+	if m := atomic.LoadInt32(&maxInFlight); m != 2 {
+		t.Errorf("should have run 2 statements at a time. got: %v", m)
+	}
+	if v := bindings["INSERT INTO t1 VALUES (?)"]["1"]; v.Type != "FIXED" || v.Value != "1" {
+		t.Errorf("failed to bind the arg. got: %v", v)
+	}
+	if v := bindings["INSERT INTO t2 VALUES (?)"]["1"]; v.Type != "TEXT" || v.Value != "a" {
+		t.Errorf("failed to bind the named arg. got: %v", v)
+	}
+}
+
+func TestExecBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, _ []byte, _ time.Duration) (*execResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		cancel()
+		return &execResponse{Success: true}, nil
+	}
+	sc := &snowflakeConn{
+		cfg:  &Config{},
+		rest: &snowflakeRestful{FuncPostQuery: postQuery},
+	}
+	b := Batch{MaxParallel: 1}
+	for i := 0; i < 3; i++ {
+		b.Queue("INSERT INTO t VALUES (1)")
+	}
+	results := sc.ExecBatch(ctx, &b)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("should have stopped starting the statements. calls: %v", n)
+	}
+	for _, r := range results[1:] {
+		if r.Err != context.Canceled {
+			t.Errorf("should have returned context.Canceled. err: %v", r.Err)
+		}
+	}
+}
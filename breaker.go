@@ -0,0 +1,157 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultCircuitBreakerCooldown is the time the circuit stays open before a probe request is let through.
+const defaultCircuitBreakerCooldown = 30 * time.Second
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker fails the requests fast after threshold consecutive availability failures. After cooldown,
+// one probe request is let through: the circuit closes if it succeeds and opens again if it fails.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	host      string
+	threshold int
+	cooldown  time.Duration
+	state     circuitState
+	failures  int       // consecutive failures
+	openedAt  time.Time // time the circuit was opened
+}
+
+var (
+	circuitBreakersMutex = &sync.Mutex{}
+	circuitBreakers      = make(map[string]*circuitBreaker)
+)
+
+// getCircuitBreaker returns the circuit breaker of the host shared by all connections, or nil if threshold
+// is not positive.
+func getCircuitBreaker(host string, threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	circuitBreakersMutex.Lock()
+	defer circuitBreakersMutex.Unlock()
+	cb, ok := circuitBreakers[host]
+	if !ok {
+		cb = &circuitBreaker{host: host}
+		circuitBreakers[host] = cb
+	}
+	cb.mu.Lock()
+	cb.threshold = threshold
+	cb.cooldown = cooldown
+	cb.mu.Unlock()
+	return cb
+}
+
+// allow returns an error if the circuit is open, or a probe is already in flight.
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case circuitOpen:
+		if wait := cb.openedAt.Add(cb.cooldown).Sub(getClock().Now()); wait > 0 {
+			return cb.openError(wait)
+		}
+		glog.V(2).Infof("circuit half-open. probing %v", cb.host)
+		cb.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		return cb.openError(0)
+	}
+	return nil
+}
+
+// record records the result of an allowed request.
+func (cb *circuitBreaker) record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if !failed {
+		if cb.state != circuitClosed {
+			glog.V(2).Infof("circuit closed. host: %v", cb.host)
+		}
+		cb.state = circuitClosed
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.threshold {
+		glog.V(2).Infof("circuit open. host: %v, failures: %v", cb.host, cb.failures)
+		cb.state = circuitOpen
+		cb.openedAt = getClock().Now()
+	}
+}
+
+func (cb *circuitBreaker) openError(retryIn time.Duration) error {
+	return &SnowflakeError{
+		Number:      ErrCircuitOpen,
+		SQLState:    SQLStateConnectionWasNotEstablished,
+		Message:     errMsgCircuitOpen,
+		MessageArgs: []interface{}{cb.host, cb.failures, retryIn},
+	}
+}
+
+// abandon is called instead of record if the caller canceled the request. A canceled probe lets the next
+// request probe.
+func (cb *circuitBreaker) abandon() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitOpen
+	}
+}
+
+// isAvailabilityFailure returns true if the request failed because Snowflake is unreachable or unavailable.
+func isAvailabilityFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// postWithCircuitBreaker posts the request unless the circuit of the host is open.
+func postWithCircuitBreaker(
+	ctx context.Context,
+	sr *snowflakeRestful,
+	fullURL string,
+	headers map[string]string,
+	body []byte,
+	timeout time.Duration,
+	raise4XX bool) (
+	*http.Response, error) {
+	cb := sr.breaker
+	if cb == nil {
+		return sr.FuncPost(ctx, sr, fullURL, headers, body, timeout, raise4XX)
+	}
+	if err := cb.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := sr.FuncPost(ctx, sr, fullURL, headers, body, timeout, raise4XX)
+	if ctx.Err() != nil {
+		// the cancellations and deadlines of the caller are not failures of Snowflake
+		cb.abandon()
+		return resp, err
+	}
+	cb.record(isAvailabilityFailure(resp, err))
+	return resp, err
+}
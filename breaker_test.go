@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	cb := &circuitBreaker{host: "a.snowflakecomputing.com", threshold: 2, cooldown: 10 * time.Second}
+	for i := 0; i < 2; i++ {
+		if err := cb.allow(); err != nil {
+			t.Fatalf("failed to allow a request in the closed circuit. err: %v", err)
+		}
+		cb.record(true)
+	}
+	err := cb.allow()
+	if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrCircuitOpen {
+		t.Fatalf("failed to open the circuit. err: %v", err)
+	}
+
+	c.Sleep(10 * time.Second)
+	if err = cb.allow(); err != nil {
+		t.Fatalf("failed to allow a probe. err: %v", err)
+	}
+	if err = cb.allow(); err == nil {
+		t.Fatal("should fail the request while probing")
+	}
+	cb.record(true)
+	if err = cb.allow(); err == nil {
+		t.Fatal("should open the circuit again after the probe failure")
+	}
+
+	c.Sleep(10 * time.Second)
+	if err = cb.allow(); err != nil {
+		t.Fatalf("failed to allow a probe. err: %v", err)
+	}
+	cb.abandon()
+	if err = cb.allow(); err != nil {
+		t.Fatalf("failed to allow a probe after the canceled probe. err: %v", err)
+	}
+	cb.record(false)
+	if cb.state != circuitClosed || cb.failures != 0 {
+		t.Errorf("failed to close the circuit. state: %v, failures: %v", cb.state, cb.failures)
+	}
+}
+
+func TestGetCircuitBreaker(t *testing.T) {
+	if cb := getCircuitBreaker("disabled.snowflakecomputing.com", 0, 0); cb != nil {
+		t.Errorf("should not create a circuit breaker if disabled")
+	}
+	cb1 := getCircuitBreaker("shared.snowflakecomputing.com", 3, 0)
+	cb2 := getCircuitBreaker("shared.snowflakecomputing.com", 3, time.Minute)
+	if cb1 != cb2 {
+		t.Errorf("failed to share the circuit breaker of the host")
+	}
+	if cb2.cooldown != time.Minute {
+		t.Errorf("failed to update the cooldown. got: %v", cb2.cooldown)
+	}
+}
+
+func TestPostQueryCircuitBreaker(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	posts := 0
+	sr := &snowflakeRestful{
+		breaker: &circuitBreaker{host: "a.snowflakecomputing.com", threshold: 2, cooldown: time.Minute},
+		FuncPost: func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error) {
+			posts++
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: &fakeResponseBody{}}, nil
+		},
+	}
+	for i := 0; i < 3; i++ {
+		_, err := postRestfulQueryHelper(context.Background(), sr, &url.Values{}, make(map[string]string), []byte{0}, 0, "abc")
+		if err == nil {
+			t.Fatal("should fail to post the query")
+		}
+	}
+	if posts != 2 {
+		t.Errorf("failed to fail fast. expected posts: 2, got: %v", posts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sr.breaker = &circuitBreaker{host: "b.snowflakecomputing.com", threshold: 1, cooldown: time.Minute}
+	sr.FuncPost = func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error) {
+		cancel()
+		return nil, errors.New("canceled")
+	}
+	if _, err := postRestfulQueryHelper(ctx, sr, &url.Values{}, make(map[string]string), []byte{0}, 0, "abc"); err == nil {
+		t.Fatal("should fail to post the query")
+	}
+	if sr.breaker.state != circuitClosed {
+		t.Errorf("should not open the circuit for the canceled request")
+	}
+}
@@ -0,0 +1,494 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package bulkload loads CSV data and Go structs into a table through a stage, which is much faster than INSERT
+// for large data. The rows are written to gzipped CSV chunks of up to Options.ChunkSize bytes in temporary
+// files, each chunk is uploaded to the stage by PUT as it is filled, and COPY INTO loads the chunks into the
+// table. The result reports the outcome of each chunk.
+//
+//	result, err := bulkload.LoadCSV(ctx, db, "orders", f, &bulkload.Options{Header: true, OnError: "CONTINUE"})
+//	if err != nil {
+//		return err
+//	}
+//	for _, c := range result.Failed() {
+//		log.Printf("failed to load %v: %v at line %v", c.File, c.Load.FirstError, c.Load.FirstErrorLine)
+//	}
+//
+// LoadStructs loads the structs received from a channel. The exported fields are loaded into the columns of
+// their names, or of the names in the snowflake tag. The fields tagged with "-" are not loaded:
+//
+//	type order struct {
+//		ID       int64     `snowflake:"ID"`
+//		Customer string    `snowflake:"CUSTOMER"`
+//		Ordered  time.Time `snowflake:"ORDERED_AT"`
+//		Items    []item    `snowflake:"ITEMS"` // VARIANT, loaded as JSON
+//		cached   bool
+//	}
+//
+// Unless Options.Stage is given, the chunks are uploaded to a temporary stage, which exists only in the session.
+// So, given a *sql.DB, the load runs on a connection taken from the pool for the load.
+package bulkload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/copyinto"
+)
+
+const (
+	// defaultChunkSize is the uncompressed size of a chunk if Options.ChunkSize is 0.
+	defaultChunkSize = 64 << 20
+	// maxCopyFiles is the maximum number of the files listed in FILES of a COPY INTO.
+	maxCopyFiles = 1000
+	// fileFormat is the format of the chunks. The fields other than NULL are enclosed by double quotes, so that
+	// an empty string is loaded as is and an empty field as NULL.
+	fileFormat = `FILE_FORMAT = (TYPE = CSV FIELD_OPTIONALLY_ENCLOSED_BY = '"' COMPRESSION = GZIP)`
+	// timestampFormat is the format of time.Time, which Snowflake detects for DATE, TIME and TIMESTAMP.
+	timestampFormat = "2006-01-02 15:04:05.999999999 -07:00"
+)
+
+// Options are the options of a load.
+type Options struct {
+	Stage     string   // stage, and optionally the path in it, to upload the chunks to. A temporary stage if empty.
+	Columns   []string // columns of the fields of the CSV data in order. All columns of the table if empty.
+	Header    bool     // skip the first line of the CSV data
+	ChunkSize int64    // uncompressed size of a chunk in bytes. 64MB if 0.
+	OnError   string   // ON_ERROR of COPY INTO, e.g., CONTINUE or SKIP_FILE. ABORT_STATEMENT if empty.
+	Purge     bool     // remove the chunks loaded from the stage
+	TempDir   string   // directory of the temporary files. The default directory for temporary files if empty.
+}
+
+// ChunkResult is the outcome of a chunk.
+type ChunkResult struct {
+	File string              // name of the chunk in the stage
+	Rows int64               // rows written to the chunk
+	Load copyinto.FileResult // outcome of COPY INTO. Status is empty if COPY INTO didn't report the chunk.
+}
+
+// Result is the outcome of a load.
+type Result struct {
+	Chunks []ChunkResult
+}
+
+// RowsLoaded returns the rows loaded from all chunks.
+func (r *Result) RowsLoaded() int64 {
+	var n int64
+	for _, c := range r.Chunks {
+		n += c.Load.RowsLoaded
+	}
+	return n
+}
+
+// Failed returns the chunks not loaded fully.
+func (r *Result) Failed() []ChunkResult {
+	var ret []ChunkResult
+	for _, c := range r.Chunks {
+		if c.Load.Status != copyinto.StatusLoaded {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// LoadCSV loads the CSV data read from the reader into the table. The empty fields are loaded as NULL. opts may
+// be nil.
+func LoadCSV(ctx context.Context, db sf.ExecQueryer, table string, r io.Reader, opts *Options) (*Result, error) {
+	l, err := newLoader(ctx, db, table, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer l.close()
+	cr := csv.NewReader(r)
+	if l.opts.Header {
+		if _, err = cr.Read(); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		fields := make([]*string, len(record))
+		for i := range record {
+			if record[i] != "" {
+				fields[i] = &record[i]
+			}
+		}
+		if err = l.write(fields); err != nil {
+			return nil, err
+		}
+	}
+	return l.finish(l.opts.Columns)
+}
+
+// LoadStructs loads the structs, or pointers to them, received from the channel into the table until the channel
+// is closed. All structs must be of the same type. The values of the fields are loaded as:
+//
+//	nil, nil pointers, slices and maps, and driver.Valuer returning nil: NULL
+//	string, bool, integers and floats: the text representation
+//	[]byte: hex, the default format of BINARY
+//	time.Time: YYYY-MM-DD HH24:MI:SS.FF TZH:TZM
+//	other types: JSON, for VARIANT, OBJECT and ARRAY
+//
+// Options.Columns is not used. On failure, LoadStructs returns without receiving the rest of the channel, so the
+// sender must stop by the context. opts may be nil.
+func LoadStructs(ctx context.Context, db sf.ExecQueryer, table string, rows <-chan interface{}, opts *Options) (*Result, error) {
+	l, err := newLoader(ctx, db, table, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer l.close()
+	var typ reflect.Type
+	var fields []structField
+	for {
+		var v interface{}
+		var ok bool
+		select {
+		case v, ok = <-rows:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		if rv.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("not a struct: %T", v)
+		}
+		if typ == nil {
+			typ = rv.Type()
+			if fields = structFields(typ); len(fields) == 0 {
+				return nil, fmt.Errorf("no exported fields: %v", typ)
+			}
+		} else if rv.Type() != typ {
+			return nil, fmt.Errorf("mixed struct types: %v and %v", typ, rv.Type())
+		}
+		record := make([]*string, len(fields))
+		for i, f := range fields {
+			if record[i], err = formatValue(rv.Field(f.index).Interface()); err != nil {
+				return nil, fmt.Errorf("failed to format %v.%v: %v", typ, typ.Field(f.index).Name, err)
+			}
+		}
+		if err = l.write(record); err != nil {
+			return nil, err
+		}
+	}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.column
+	}
+	return l.finish(columns)
+}
+
+// conner is implemented by *sql.DB.
+type conner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// loader writes the chunks and uploads them to the stage.
+type loader struct {
+	ctx       context.Context
+	db        sf.ExecQueryer
+	conn      *sql.Conn // taken from the pool for the load
+	table     string
+	opts      Options
+	id        string
+	stage     string
+	location  string // the stage and path to upload the chunks to
+	tempStage bool
+	tempDir   string
+	chunk     *chunk
+	buf       bytes.Buffer
+	result    Result
+}
+
+// chunk is the temporary file of a chunk being written.
+type chunk struct {
+	name string
+	file *os.File
+	gz   *gzip.Writer
+	size int64
+	rows int64
+}
+
+func newLoader(ctx context.Context, db sf.ExecQueryer, table string, opts *Options) (*loader, error) {
+	l := &loader{
+		ctx:   ctx,
+		db:    db,
+		table: table,
+		id:    "bulkload_" + strings.Replace(uuid.New().String(), "-", "", -1),
+	}
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.ChunkSize <= 0 {
+		l.opts.ChunkSize = defaultChunkSize
+	}
+	if c, ok := db.(conner); ok {
+		conn, err := c.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		l.conn = conn
+		l.db = conn
+	}
+	var err error
+	if l.tempDir, err = ioutil.TempDir(l.opts.TempDir, "bulkload"); err != nil {
+		l.close()
+		return nil, err
+	}
+	if l.stage = strings.TrimPrefix(l.opts.Stage, "@"); l.stage != "" {
+		l.location = "@" + strings.TrimSuffix(l.stage, "/") + "/" + l.id
+		return l, nil
+	}
+	l.stage = l.id
+	l.location = "@" + l.stage
+	if _, err = l.db.ExecContext(ctx, "CREATE TEMPORARY STAGE "+l.stage); err != nil {
+		l.close()
+		return nil, err
+	}
+	l.tempStage = true
+	return l, nil
+}
+
+// write writes the record to the chunk, and uploads the chunk if filled.
+func (l *loader) write(fields []*string) error {
+	if err := l.ctx.Err(); err != nil {
+		return err
+	}
+	if l.chunk == nil {
+		name := fmt.Sprintf("chunk_%06d.csv.gz", len(l.result.Chunks)+1)
+		f, err := os.Create(filepath.Join(l.tempDir, name))
+		if err != nil {
+			return err
+		}
+		l.chunk = &chunk{name: name, file: f, gz: gzip.NewWriter(f)}
+	}
+	l.buf.Reset()
+	for i, f := range fields {
+		if i > 0 {
+			l.buf.WriteByte(',')
+		}
+		if f != nil {
+			l.buf.WriteByte('"')
+			l.buf.WriteString(strings.Replace(*f, `"`, `""`, -1))
+			l.buf.WriteByte('"')
+		}
+	}
+	l.buf.WriteByte('\n')
+	n, err := l.chunk.gz.Write(l.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	l.chunk.size += int64(n)
+	l.chunk.rows++
+	if l.chunk.size >= l.opts.ChunkSize {
+		return l.upload()
+	}
+	return nil
+}
+
+// upload uploads the chunk being written to the stage, and removes the temporary file.
+func (l *loader) upload() error {
+	c := l.chunk
+	l.chunk = nil
+	err := c.gz.Close()
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	defer os.Remove(c.file.Name())
+	if err != nil {
+		return err
+	}
+	put := "PUT 'file://" + filepath.ToSlash(c.file.Name()) + "' " + l.location +
+		" AUTO_COMPRESS = FALSE SOURCE_COMPRESSION = GZIP"
+	rows, err := l.db.QueryContext(l.ctx, put)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make(map[string]string, len(cols))
+		for i, col := range cols {
+			row[strings.ToLower(col)] = values[i].String
+		}
+		if row["status"] != "UPLOADED" {
+			return fmt.Errorf("failed to upload %v. status: %v, message: %v", c.name, row["status"], row["message"])
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	l.result.Chunks = append(l.result.Chunks, ChunkResult{File: c.name, Rows: c.rows})
+	return nil
+}
+
+// finish uploads the last chunk, and loads the chunks into the columns of the table by COPY INTO, up to
+// maxCopyFiles chunks at a time.
+func (l *loader) finish(columns []string) (*Result, error) {
+	if l.chunk != nil {
+		if err := l.upload(); err != nil {
+			return nil, err
+		}
+	}
+	chunks := make(map[string]*ChunkResult, len(l.result.Chunks))
+	for i := range l.result.Chunks {
+		chunks[l.result.Chunks[i].File] = &l.result.Chunks[i]
+	}
+	for start := 0; start < len(l.result.Chunks); start += maxCopyFiles {
+		end := start + maxCopyFiles
+		if end > len(l.result.Chunks) {
+			end = len(l.result.Chunks)
+		}
+		files := make([]string, end-start)
+		for i, c := range l.result.Chunks[start:end] {
+			files[i] = "'" + c.File + "'"
+		}
+		stmt := "COPY INTO " + l.table
+		if len(columns) > 0 {
+			stmt += " (" + strings.Join(columns, ", ") + ")"
+		}
+		stmt += " FROM " + l.location + " FILES = (" + strings.Join(files, ", ") + ") " + fileFormat
+		if l.opts.OnError != "" {
+			stmt += " ON_ERROR = " + l.opts.OnError
+		}
+		if l.opts.Purge {
+			stmt += " PURGE = TRUE"
+		}
+		report, err := copyinto.Exec(l.ctx, l.db, stmt, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range report.Files {
+			if c, ok := chunks[path.Base(f.File)]; ok {
+				c.Load = f
+			}
+		}
+	}
+	return &l.result, nil
+}
+
+// close removes the temporary files and stage, and returns the connection to the pool.
+func (l *loader) close() {
+	if l.chunk != nil {
+		l.chunk.file.Close()
+	}
+	if l.tempDir != "" {
+		os.RemoveAll(l.tempDir)
+	}
+	if l.tempStage {
+		// even if the load was canceled. The stage is dropped at the end of the session anyway if this fails.
+		l.db.ExecContext(context.Background(), "DROP STAGE IF EXISTS "+l.stage)
+	}
+	if l.conn != nil {
+		l.conn.Close()
+	}
+}
+
+// structField is an exported field of a struct and its column.
+type structField struct {
+	index  int
+	column string
+}
+
+// structFields returns the fields of the struct type loaded into the columns.
+func structFields(typ reflect.Type) []structField {
+	var ret []structField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		column := f.Tag.Get("snowflake")
+		if column == "-" {
+			continue
+		}
+		if column == "" {
+			column = f.Name
+		}
+		ret = append(ret, structField{index: i, column: column})
+	}
+	return ret
+}
+
+// formatValue returns the CSV field of the value, nil if NULL.
+func formatValue(v interface{}) (*string, error) {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+	if vr, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = vr.Value(); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var s string
+	switch x := rv.Interface().(type) {
+	case []byte:
+		s = hex.EncodeToString(x)
+	case time.Time:
+		s = x.Format(timestampFormat)
+	default:
+		switch rv.Kind() {
+		case reflect.String:
+			s = rv.String()
+		case reflect.Bool:
+			s = strconv.FormatBool(rv.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(rv.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(rv.Uint(), 10)
+		case reflect.Float32, reflect.Float64:
+			s = strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits())
+		default:
+			b, err := json.Marshal(x)
+			if err != nil {
+				return nil, err
+			}
+			s = string(b)
+		}
+	}
+	return &s, nil
+}
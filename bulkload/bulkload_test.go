@@ -0,0 +1,233 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package bulkload
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+var putRegexp = regexp.MustCompile(`^PUT 'file://([^']+)'`)
+
+// recorder runs the statements on the mock, and records the chunks uploaded by PUT. It doesn't implement Conn,
+// so the load runs on the db as is.
+type recorder struct {
+	db     *sql.DB
+	chunks []string
+}
+
+func (r *recorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.db.ExecContext(ctx, query, args...)
+}
+
+func (r *recorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if m := putRegexp.FindStringSubmatch(query); m != nil {
+		f, err := os.Open(m[1])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(gz)
+		if err != nil {
+			return nil, err
+		}
+		r.chunks = append(r.chunks, string(b))
+	}
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+func copyResult(files ...[]driver.Value) *sfmock.Rows {
+	rows := sfmock.NewRows(
+		sfmock.Column{Name: "file", Type: "TEXT"},
+		sfmock.Column{Name: "status", Type: "TEXT"},
+		sfmock.Column{Name: "rows_parsed", Type: "FIXED"},
+		sfmock.Column{Name: "rows_loaded", Type: "FIXED"},
+		sfmock.Column{Name: "first_error", Type: "TEXT", Nullable: true})
+	for _, f := range files {
+		rows.AddRow(f...)
+	}
+	return rows
+}
+
+type item struct {
+	SKU string `json:"sku"`
+}
+
+type order struct {
+	ID       int64     `snowflake:"ID"`
+	Customer string    `snowflake:"CUSTOMER"`
+	Note     *string   `snowflake:"NOTE"`
+	Items    []item    `snowflake:"ITEMS"`
+	Ordered  time.Time `snowflake:"ORDERED_AT"`
+	Amount   float64
+	Paid     sql.NullBool `snowflake:"PAID"`
+	Ignored  int          `snowflake:"-"`
+	cached   bool
+}
+
+func TestLoadStructs(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	tempDir, err := ioutil.TempDir("", "bulkload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mock.ExpectExec(`^CREATE TEMPORARY STAGE bulkload_[0-9a-f]{32}$`)
+	for _, name := range []string{"chunk_000001.csv.gz", "chunk_000002.csv.gz"} {
+		mock.ExpectQuery(`^PUT 'file://.+/` + regexp.QuoteMeta(name) + `' @bulkload_[0-9a-f]{32} ` +
+			`AUTO_COMPRESS = FALSE SOURCE_COMPRESSION = GZIP$`).WillReturnRows(sfmock.PutResult(sfmock.PutFile{
+			Source: name, Target: name, SourceCompression: "GZIP", TargetCompression: "GZIP", Status: "UPLOADED"}))
+	}
+	mock.ExpectQuery(`^COPY INTO orders \(ID, CUSTOMER, NOTE, ITEMS, ORDERED_AT, Amount, PAID\) ` +
+		`FROM @bulkload_[0-9a-f]{32} FILES = \('chunk_000001\.csv\.gz', 'chunk_000002\.csv\.gz'\) ` +
+		`FILE_FORMAT = \(TYPE = CSV FIELD_OPTIONALLY_ENCLOSED_BY = '"' COMPRESSION = GZIP\) ON_ERROR = CONTINUE$`).
+		WillReturnRows(copyResult(
+			[]driver.Value{"chunk_000001.csv.gz", "LOADED", "1", "1", nil},
+			[]driver.Value{"chunk_000002.csv.gz", "LOAD_FAILED", "1", "0", "Numeric value 'x' is not recognized"}))
+	mock.ExpectExec(`^DROP STAGE IF EXISTS bulkload_[0-9a-f]{32}$`)
+
+	note := "leave at the door"
+	ordered := time.Date(2018, 1, 2, 3, 4, 5, 500000000, time.UTC)
+	rows := make(chan interface{}, 2)
+	rows <- order{ID: 1, Customer: `Acme "West"`, Items: []item{{SKU: "A-1"}}, Ordered: ordered, Amount: 1.5,
+		Paid: sql.NullBool{Bool: true, Valid: true}, Ignored: 9}
+	rows <- &order{ID: 2, Note: &note, Ordered: ordered}
+	close(rows)
+	rec := &recorder{db: db}
+	result, err := LoadStructs(context.Background(), rec, "orders", rows,
+		&Options{ChunkSize: 1, OnError: "CONTINUE", TempDir: tempDir})
+	if err != nil {
+		t.Fatalf("failed to load. err: %v", err)
+	}
+	expected := []string{
+		`"1","Acme ""West""",,"[{""sku"":""A-1""}]","2018-01-02 03:04:05.5 +00:00","1.5","true"` + "\n",
+		`"2","","leave at the door",,"2018-01-02 03:04:05.5 +00:00","0",` + "\n",
+	}
+	if strings.Join(rec.chunks, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected chunks. expected: %q, got: %q", expected, rec.chunks)
+	}
+	if len(result.Chunks) != 2 || result.Chunks[0].Rows != 1 || result.RowsLoaded() != 1 {
+		t.Errorf("unexpected result: %v", result)
+	}
+	if f := result.Failed(); len(f) != 1 || f[0].File != "chunk_000002.csv.gz" || f[0].Load.Status != "LOAD_FAILED" ||
+		f[0].Load.FirstError == "" {
+		t.Errorf("unexpected failed chunks: %v", f)
+	}
+	if files, _ := ioutil.ReadDir(tempDir); len(files) != 0 {
+		t.Errorf("should have removed the temporary files. got: %v", len(files))
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	for _, test := range []struct {
+		rows []interface{}
+		err  string
+	}{
+		{rows: []interface{}{1}, err: "not a struct"},
+		{rows: []interface{}{order{}, item{}}, err: "mixed struct types"},
+		{rows: []interface{}{struct{ a int }{}}, err: "no exported fields"},
+	} {
+		mock.ExpectExec(`^CREATE TEMPORARY STAGE`)
+		mock.ExpectExec(`^DROP STAGE IF EXISTS`)
+		rows := make(chan interface{}, len(test.rows))
+		for _, r := range test.rows {
+			rows <- r
+		}
+		close(rows)
+		if _, err = LoadStructs(context.Background(), db, "orders", rows, nil); err == nil ||
+			!strings.Contains(err.Error(), test.err) {
+			t.Errorf("should have failed with %v. err: %v", test.err, err)
+		}
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`^PUT 'file://.+/chunk_000001\.csv\.gz' @s/path/bulkload_[0-9a-f]{32} `).WillReturnRows(
+		sfmock.PutResult(sfmock.PutFile{Source: "chunk_000001.csv.gz", Target: "chunk_000001.csv.gz", Status: "UPLOADED"}))
+	mock.ExpectQuery(`^COPY INTO t \(A, B\) FROM @s/path/bulkload_[0-9a-f]{32} FILES = \('chunk_000001\.csv\.gz'\) ` +
+		`FILE_FORMAT = \(.+\) PURGE = TRUE$`).WillReturnRows(copyResult(
+		[]driver.Value{"path/bulkload_0/chunk_000001.csv.gz", "LOADED", "2", "2", nil}))
+
+	rec := &recorder{db: db}
+	data := "a,b\n1,\"x,y\"\n2,\n"
+	result, err := LoadCSV(context.Background(), rec, "t", strings.NewReader(data),
+		&Options{Stage: "@s/path/", Columns: []string{"A", "B"}, Header: true, Purge: true})
+	if err != nil {
+		t.Fatalf("failed to load. err: %v", err)
+	}
+	if len(rec.chunks) != 1 || rec.chunks[0] != "\"1\",\"x,y\"\n\"2\",\n" {
+		t.Errorf("unexpected chunks: %q", rec.chunks)
+	}
+	if len(result.Chunks) != 1 || result.Chunks[0].Rows != 2 || result.RowsLoaded() != 2 || len(result.Failed()) != 0 {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	// a file not uploaded fails the load
+	mock.ExpectQuery(`^PUT `).WillReturnRows(sfmock.PutResult(sfmock.PutFile{
+		Source: "chunk_000001.csv.gz", Status: "ERROR", Message: "access denied"}))
+	if _, err = LoadCSV(context.Background(), db, "t", strings.NewReader("1\n"), &Options{Stage: "s"}); err == nil ||
+		!strings.Contains(err.Error(), "access denied") {
+		t.Errorf("should have failed to upload. err: %v", err)
+	}
+	if _, err = LoadCSV(context.Background(), db, "t", strings.NewReader("1,2\n3\n"), &Options{Stage: "s"}); err == nil {
+		t.Error("should have failed to parse the CSV data")
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadCanceled(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectExec(`^CREATE TEMPORARY STAGE`)
+	mock.ExpectExec(`^DROP STAGE IF EXISTS`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows := make(chan interface{})
+	go func() {
+		rows <- order{ID: 1}
+		cancel()
+	}()
+	if _, err = LoadStructs(ctx, db, "orders", rows, nil); err != context.Canceled {
+		t.Errorf("should have been canceled. err: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
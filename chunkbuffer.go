@@ -0,0 +1,228 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// workers returns the number of the chunks downloaded ahead of the chunk read.
+func (scd *snowflakeChunkDownloader) workers() int {
+	if scd.MaxWorkers > 0 {
+		return scd.MaxWorkers
+	}
+	return maxChunkDownloadWorkers
+}
+
+// fill schedules the downloads of the chunks ahead of the chunk read, up to the workers and, unless the chunks are
+// spilled, MaxBufferBytes. At least one chunk is downloaded ahead, so that the rows are read however large the
+// chunks are.
+func (scd *snowflakeChunkDownloader) fill() {
+	for scd.ahead < scd.workers() && scd.nextChunk < len(scd.ChunkMetas) {
+		if scd.ahead > 0 && !scd.hasBufferRoom(scd.nextChunk) {
+			glog.V(2).Infof("chunk buffer is full. deferring chunk: %v", scd.nextChunk+1)
+			return
+		}
+		scd.schedule()
+	}
+}
+
+// hasBufferRoom returns true if the chunk fits in MaxBufferBytes with the chunks downloaded ahead, by the
+// uncompressed size of the chunk.
+func (scd *snowflakeChunkDownloader) hasBufferRoom(idx int) bool {
+	if scd.MaxBufferBytes <= 0 || scd.SpillDir != "" {
+		return true
+	}
+	scd.ChunksMutex.Lock()
+	defer scd.ChunksMutex.Unlock()
+	return scd.bufferedBytes+scd.ChunkMetas[idx].UncompressedSize <= scd.MaxBufferBytes
+}
+
+// reserve counts the chunk scheduled for download in the buffer by its uncompressed size until it's downloaded.
+func (scd *snowflakeChunkDownloader) reserve(idx int) {
+	scd.ahead++
+	scd.nextChunk = idx + 1
+	if scd.MaxBufferBytes <= 0 || scd.SpillDir != "" {
+		return
+	}
+	size := scd.ChunkMetas[idx].UncompressedSize
+	scd.ChunksMutex.Lock()
+	scd.chunkBytes[idx] = size
+	scd.bufferedBytes += size
+	scd.ChunksMutex.Unlock()
+	addMetricsGauge(MetricChunkBufferBytes, float64(size))
+}
+
+// release removes the chunk read from the buffer.
+func (scd *snowflakeChunkDownloader) release(idx int) {
+	scd.ahead--
+	scd.ChunksMutex.Lock()
+	size := scd.chunkBytes[idx]
+	delete(scd.chunkBytes, idx)
+	scd.bufferedBytes -= size
+	scd.ChunksMutex.Unlock()
+	if size != 0 {
+		addMetricsGauge(MetricChunkBufferBytes, -float64(size))
+	}
+}
+
+// storeChunk keeps the downloaded chunk in memory, or spills it to a file in SpillDir if the chunks in memory would
+// exceed MaxBufferBytes. The chunk is dropped if the downloader is closed.
+func (scd *snowflakeChunkDownloader) storeChunk(idx int, chunk *chunkRows) error {
+	size := chunkRowsBytes(chunk)
+	scd.ChunksMutex.Lock()
+	if scd.closed {
+		scd.ChunksMutex.Unlock()
+		return nil
+	}
+	if scd.chunkBytes == nil {
+		scd.chunkBytes = make(map[int]int64)
+	}
+	spill := scd.SpillDir != "" && scd.MaxBufferBytes > 0 && scd.bufferedBytes+size > scd.MaxBufferBytes
+	delta := -scd.chunkBytes[idx]
+	if spill {
+		delete(scd.chunkBytes, idx)
+	} else {
+		delta += size
+		scd.chunkBytes[idx] = size
+		chunk.arrow = nil
+		scd.Chunks[idx] = chunk
+	}
+	scd.bufferedBytes += delta
+	scd.ChunksMutex.Unlock()
+	if delta != 0 {
+		addMetricsGauge(MetricChunkBufferBytes, float64(delta))
+	}
+	if spill {
+		path, err := spillChunk(scd.SpillDir, chunk)
+		if err != nil {
+			return err
+		}
+		scd.ChunksMutex.Lock()
+		closed := scd.closed
+		if !closed {
+			if scd.spilled == nil {
+				scd.spilled = make(map[int]string)
+			}
+			scd.spilled[idx] = path
+		}
+		scd.ChunksMutex.Unlock()
+		if closed {
+			os.Remove(path)
+			return nil
+		}
+		addMetricsCounter(MetricChunksSpilled, 1)
+		glog.V(2).Infof("spilled chunk: %v, bytes: %v, file: %v", idx+1, size, path)
+	}
+	scd.chunkReady(idx)
+	return nil
+}
+
+// takeChunk returns the downloaded chunk, read back from the file if spilled, or nil if it's not downloaded yet.
+func (scd *snowflakeChunkDownloader) takeChunk(idx int) (*chunkRows, error) {
+	scd.ChunksMutex.Lock()
+	chunk := scd.Chunks[idx]
+	path, spilled := scd.spilled[idx]
+	delete(scd.spilled, idx)
+	scd.ChunksMutex.Unlock()
+	if !spilled {
+		return chunk, nil
+	}
+	return scd.readSpilledChunk(path)
+}
+
+// close cancels the downloads, drops the chunks downloaded ahead and removes the spilled files.
+func (scd *snowflakeChunkDownloader) close() {
+	if scd.cancel != nil {
+		scd.cancel()
+	}
+	if scd.ChunksMutex == nil {
+		return
+	}
+	scd.ChunksMutex.Lock()
+	scd.closed = true
+	size := scd.bufferedBytes
+	scd.bufferedBytes = 0
+	scd.chunkBytes = nil
+	spilled := scd.spilled
+	scd.spilled = nil
+	scd.ChunksMutex.Unlock()
+	if size != 0 {
+		addMetricsGauge(MetricChunkBufferBytes, -float64(size))
+	}
+	for _, path := range spilled {
+		os.Remove(path)
+	}
+}
+
+// chunkRowsBytes returns the approximate bytes of the decoded chunk in memory.
+func chunkRowsBytes(chunk *chunkRows) int64 {
+	var n int64
+	for _, row := range chunk.strings {
+		n += 24 + 8*int64(len(row))
+		for _, v := range row {
+			if v != nil {
+				n += 16 + int64(len(*v))
+			}
+		}
+	}
+	for _, row := range chunk.values {
+		n += 24 + 16*int64(len(row))
+		for _, v := range row {
+			switch v := v.(type) {
+			case string:
+				n += 16 + int64(len(v))
+			case []byte:
+				n += 24 + int64(len(v))
+			case time.Time:
+				n += 24
+			}
+		}
+	}
+	return n
+}
+
+// spillChunk writes the chunk to a new file in the directory, readable only by the owner. A JSON chunk is written
+// as the decoded strings, and an Arrow chunk as the Arrow stream it's decoded from.
+func spillChunk(dir string, chunk *chunkRows) (string, error) {
+	f, err := ioutil.TempFile(dir, "gosnowflake_chunk_")
+	if err != nil {
+		return "", err
+	}
+	if chunk.arrow != nil {
+		_, err = f.Write(chunk.arrow)
+	} else {
+		err = json.NewEncoder(f).Encode(chunk.strings)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// readSpilledChunk reads the chunk written by spillChunk and removes the file.
+func (scd *snowflakeChunkDownloader) readSpilledChunk(path string) (*chunkRows, error) {
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if scd.QueryResultFormat == queryResultFormatArrow {
+		return decodeArrowChunk(f, scd.RowType)
+	}
+	var rows [][]*string
+	if err = json.NewDecoder(f).Decode(&rows); err != nil {
+		return nil, err
+	}
+	return jsonChunk(rows), nil
+}
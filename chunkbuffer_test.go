@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// bufferTestDownloader returns a downloader of numChunks chunks of 10 rows of 100 bytes, whose values are the row
+// numbers padded.
+func bufferTestDownloader(numChunks int, maxBufferBytes int64, spillDir string) *snowflakeChunkDownloader {
+	const rowCount = 10
+	metas := make([]execResponseChunk, numChunks)
+	for i := range metas {
+		metas[i] = execResponseChunk{URL: "dummyURL" + strconv.Itoa(i), RowCount: rowCount, UncompressedSize: 1000}
+	}
+	scd := &snowflakeChunkDownloader{
+		ctx:            context.Background(),
+		ChunkMetas:     metas,
+		TotalRowIndex:  int64(-1),
+		MaxWorkers:     10,
+		MaxBufferBytes: maxBufferBytes,
+		SpillDir:       spillDir,
+	}
+	scd.FuncDownload = func(scd *snowflakeChunkDownloader, idx int) {
+		chunk := make([][]*string, rowCount)
+		for i := range chunk {
+			v := strconv.Itoa(idx*rowCount + i)
+			v += string(make([]byte, 100-len(v)))
+			chunk[i] = []*string{&v}
+		}
+		if err := scd.storeChunk(idx, jsonChunk(chunk)); err != nil {
+			scd.ChunksError <- &chunkError{Index: idx, Error: err}
+		}
+	}
+	return scd
+}
+
+// readBufferTestRows reads the rows of the downloader in order, and returns the number of the rows and the chunks
+// scheduled ahead of the chunk read at most.
+func readBufferTestRows(t *testing.T, scd *snowflakeChunkDownloader) (int, int) {
+	n, maxAhead := 0, scd.ahead
+	for {
+		row, err := scd.Next()
+		if scd.ahead > maxAhead {
+			maxAhead = scd.ahead
+		}
+		if err == io.EOF {
+			return n, maxAhead
+		}
+		if err != nil {
+			t.Fatalf("failed to read the row. err: %v", err)
+		}
+		if v := (*row.strings[0])[:len(strconv.Itoa(n))]; v != strconv.Itoa(n) {
+			t.Fatalf("failed to read the row in order. expected: %v, got: %v", n, v)
+		}
+		n++
+	}
+}
+
+func TestChunkBufferDefersDownloads(t *testing.T) {
+	c := newTestMetricsCollector()
+	RegisterMetricsCollector(c)
+	defer UnregisterMetricsCollector(c)
+
+	scd := bufferTestDownloader(8, 2500, "")
+	scd.start()
+	n, maxAhead := readBufferTestRows(t, scd)
+	if n != 80 {
+		t.Errorf("failed to read all rows. expected: 80, got: %v", n)
+	}
+	if maxAhead != 2 {
+		t.Errorf("failed to cap the chunks downloaded ahead. expected: 2, got: %v", maxAhead)
+	}
+	c.mu.Lock()
+	g := c.gauges[MetricChunkBufferBytes]
+	c.mu.Unlock()
+	if g != 0 {
+		t.Errorf("failed to release the buffer. got: %v", g)
+	}
+
+	// a chunk larger than the buffer is downloaded
+	scd = bufferTestDownloader(3, 10, "")
+	scd.start()
+	if n, _ = readBufferTestRows(t, scd); n != 30 {
+		t.Errorf("failed to read all rows. expected: 30, got: %v", n)
+	}
+}
+
+func TestChunkBufferSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake_spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := newTestMetricsCollector()
+	RegisterMetricsCollector(c)
+	defer UnregisterMetricsCollector(c)
+
+	scd := bufferTestDownloader(8, 2500, dir)
+	scd.start()
+	n, maxAhead := readBufferTestRows(t, scd)
+	if n != 80 {
+		t.Errorf("failed to read all rows. expected: 80, got: %v", n)
+	}
+	if maxAhead != 8 {
+		t.Errorf("should not have deferred the downloads. got: %v", maxAhead)
+	}
+	c.mu.Lock()
+	spilled, buffered := c.counters[MetricChunksSpilled], c.gauges[MetricChunkBufferBytes]
+	c.mu.Unlock()
+	if spilled == 0 {
+		t.Error("should have spilled the chunks over the buffer")
+	}
+	if buffered != 0 {
+		t.Errorf("failed to release the buffer. got: %v", buffered)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("failed to remove the spilled files. got: %v", len(files))
+	}
+
+	// the spilled chunks not read are removed on close
+	scd = bufferTestDownloader(8, 1, dir)
+	scd.start()
+	if _, err = scd.Next(); err != nil {
+		t.Fatalf("failed to read the row. err: %v", err)
+	}
+	scd.close()
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("failed to remove the spilled files on close. got: %v", len(files))
+	}
+}
+
+func TestChunkBufferSpillArrow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake_spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rowType := []execResponseRowType{{Name: "C1", Type: "fixed"}, {Name: "C2", Type: "timestamp_ntz", Scale: 3}}
+	stream := testArrowStream([]testArrowColumn{
+		arrowIntColumn("C1", 64, []int64{1, 2}),
+		arrowIntColumn("C2", 64, []int64{1, 2}, 1),
+	})
+	chunk, err := readArrowChunk(bytes.NewReader(stream), rowType)
+	if err != nil {
+		t.Fatalf("failed to decode the chunk. err: %v", err)
+	}
+	scd := &snowflakeChunkDownloader{
+		ChunkMetas:        []execResponseChunk{{URL: "dummyURL"}},
+		RowType:           rowType,
+		QueryResultFormat: queryResultFormatArrow,
+		MaxBufferBytes:    1,
+		SpillDir:          dir,
+		ChunksMutex:       &sync.Mutex{},
+		Chunks:            make(map[int]*chunkRows),
+		ChunksReady:       make(chan int, 1),
+	}
+	if err = scd.storeChunk(0, chunk); err != nil {
+		t.Fatalf("failed to store the chunk. err: %v", err)
+	}
+	if len(scd.spilled) != 1 {
+		t.Fatal("should have spilled the chunk over the buffer")
+	}
+	// the Arrow chunk is spilled as the stream and decoded again
+	spilled, err := scd.takeChunk(0)
+	if err != nil {
+		t.Fatalf("failed to read the spilled chunk. err: %v", err)
+	}
+	if !reflect.DeepEqual(spilled.values, chunk.values) {
+		t.Errorf("failed to read the values of the spilled chunk. expected: %v, got: %v", chunk.values, spilled.values)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("failed to remove the spilled file. got: %v", len(files))
+	}
+}
+
+func TestChunkRowsBytes(t *testing.T) {
+	a := "abc"
+	if n := chunkRowsBytes(jsonChunk([][]*string{{&a, nil}})); n != 24+16+16+3 {
+		t.Errorf("failed to count the bytes of the chunk. got: %v", n)
+	}
+	if n := chunkRowsBytes(&chunkRows{values: [][]driver.Value{{a, nil, []byte{1}}}}); n != 24+3*16+16+3+24+1 {
+		t.Errorf("failed to count the bytes of the Arrow chunk. got: %v", n)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time, timers and sleeps to the driver. The driver uses it for the
+// session token expiration, heartbeat scheduling and retry backoff, so that tests can replace it
+// with a fake clock and fast-forward without real sleeps.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// systemClock is the Clock based on the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+var (
+	clock      Clock = systemClock{}
+	clockMutex       = &sync.RWMutex{}
+)
+
+// SetClock replaces the Clock used by the driver. Passing nil restores the system clock.
+func SetClock(c Clock) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	if c == nil {
+		c = systemClock{}
+	}
+	clock = c
+}
+
+// sleepContext sleeps for d, or returns the error of ctx once ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if _, ok := getClock().(systemClock); ok {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// the fake clocks of the tests move forward by Sleep without waiting
+	getClock().Sleep(d)
+	return ctx.Err()
+}
+
+// getClock returns the Clock currently used by the driver.
+func getClock() Clock {
+	clockMutex.RLock()
+	defer clockMutex.RUnlock()
+	return clock
+}
@@ -0,0 +1,224 @@
+// gosnow is a minimal command line client for Snowflake built on the Go Snowflake Driver. It runs the queries
+// given by -q or read from stdin, including PUT and GET commands, and prints the results as a table, CSV or JSON.
+//
+//	echo "select current_version();" | gosnow -profile dev
+//	gosnow -dsn "user:pass@account/db/schema" -o csv -q "select * from t"
+//
+// The connection is taken from -dsn, the named -profile in the profile file, or the SNOWFLAKE_TEST_*
+// environment variables, in that order. The profile file is a JSON object mapping profile names to DSNs. All
+// queries run in one session, so that USE and ALTER SESSION statements apply to the queries following them.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+var (
+	dsnFlag     = flag.String("dsn", "", "DSN to connect to Snowflake")
+	profileFlag = flag.String("profile", "", "profile name in the profile file")
+	profileFile = flag.String("profiles", defaultProfileFile(), "profile file")
+	queryFlag   = flag.String("q", "", "queries to run. If not specified, the queries are read from stdin")
+	outputFlag  = flag.String("o", "table", "output format: table, csv or json")
+)
+
+func defaultProfileFile() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.Getenv("USERPROFILE")
+	}
+	return filepath.Join(home, ".gosnow.json")
+}
+
+// getDSN returns the DSN from the flags, the profile file or the environment variables.
+func getDSN() (string, error) {
+	if *dsnFlag != "" {
+		return *dsnFlag, nil
+	}
+	if *profileFlag != "" {
+		b, err := ioutil.ReadFile(*profileFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the profile file %v. err: %v", *profileFile, err)
+		}
+		var profiles map[string]string
+		if err = json.Unmarshal(b, &profiles); err != nil {
+			return "", fmt.Errorf("failed to parse the profile file %v. err: %v", *profileFile, err)
+		}
+		dsn, ok := profiles[*profileFlag]
+		if !ok {
+			return "", fmt.Errorf("profile %v is not found in %v", *profileFlag, *profileFile)
+		}
+		return dsn, nil
+	}
+	account := os.Getenv("SNOWFLAKE_TEST_ACCOUNT")
+	if account == "" {
+		return "", fmt.Errorf("no connection is specified. Use -dsn, -profile or SNOWFLAKE_TEST_* environment variables")
+	}
+	port, _ := strconv.Atoi(os.Getenv("SNOWFLAKE_TEST_PORT"))
+	return sf.DSN(&sf.Config{
+		Account:   account,
+		User:      os.Getenv("SNOWFLAKE_TEST_USER"),
+		Password:  os.Getenv("SNOWFLAKE_TEST_PASSWORD"),
+		Host:      os.Getenv("SNOWFLAKE_TEST_HOST"),
+		Port:      port,
+		Protocol:  os.Getenv("SNOWFLAKE_TEST_PROTOCOL"),
+		Database:  os.Getenv("SNOWFLAKE_TEST_DATABASE"),
+		Schema:    os.Getenv("SNOWFLAKE_TEST_SCHEMA"),
+		Warehouse: os.Getenv("SNOWFLAKE_TEST_WAREHOUSE"),
+		Role:      os.Getenv("SNOWFLAKE_TEST_ROLE"),
+	})
+}
+
+func run(ctx context.Context, conn *sql.Conn, query string, w io.Writer) error {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var records [][]*string
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		record := make([]*string, len(cols))
+		for i, v := range values {
+			if v.Valid {
+				s := v.String
+				record[i] = &s
+			}
+		}
+		records = append(records, record)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return printResult(w, *outputFlag, cols, records)
+}
+
+func printResult(w io.Writer, format string, cols []string, records [][]*string) error {
+	str := func(v *string) string {
+		if v == nil {
+			return "NULL"
+		}
+		return *v
+	}
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+		for _, r := range records {
+			line := make([]string, len(r))
+			for i, v := range r {
+				if v != nil {
+					line[i] = *v
+				}
+			}
+			if err := cw.Write(line); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		out := make([]map[string]*string, len(records))
+		for i, r := range records {
+			out[i] = make(map[string]*string, len(cols))
+			for j, c := range cols {
+				out[i][c] = r[j]
+			}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+		for _, r := range records {
+			line := make([]string, len(r))
+			for i, v := range r {
+				line[i] = str(v)
+			}
+			fmt.Fprintln(tw, strings.Join(line, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v row(s)\n", len(records))
+		return nil
+	}
+	return fmt.Errorf("unknown output format: %v", format)
+}
+
+func main() {
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+
+	dsn, err := getDSN()
+	if err != nil {
+		log.Fatal(err)
+	}
+	input := *queryFlag
+	if input == "" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("failed to read stdin. err: %v", err)
+		}
+		input = string(b)
+	}
+
+	// cancel the running query by Ctrl+C
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	defer signal.Stop(c)
+	go func() {
+		<-c
+		cancel()
+	}()
+
+	db, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		log.Fatalf("failed to connect. err: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("failed to connect. err: %v", err)
+	}
+	defer conn.Close()
+	for _, q := range sf.SplitStatements(input) {
+		if err = run(ctx, conn, q, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			conn.Close()
+			db.Close()
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnNameCase is the case of the column names returned by Rows.Columns.
+type ColumnNameCase string
+
+const (
+	// ColumnNameAsIs returns the column names as Snowflake returns them, e.g., ID for an unquoted id. This is
+	// the default.
+	ColumnNameAsIs ColumnNameCase = "asis"
+	// ColumnNameLower returns the column names in lower case.
+	ColumnNameLower ColumnNameCase = "lower"
+	// ColumnNameNormalize returns the names of the columns created by unquoted identifiers in lower case, and
+	// keeps the quoted identifiers as is, e.g., id for id and "Id" for "Id". If the session parameter
+	// QUOTED_IDENTIFIERS_IGNORE_CASE is true, quoted identifiers are upper-cased by Snowflake too, so all names
+	// are returned in lower case.
+	ColumnNameNormalize ColumnNameCase = "normalize"
+)
+
+const sessionQuotedIdentifiersIgnoreCase = "quoted_identifiers_ignore_case"
+
+func parseColumnNameCase(s string) (ColumnNameCase, error) {
+	switch c := ColumnNameCase(s); c {
+	case ColumnNameAsIs, ColumnNameLower, ColumnNameNormalize:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid columnNameCase: %v. must be asis, lower or normalize", s)
+}
+
+// convertColumnName returns the column name in the case. ignoreCase is the value of the session parameter
+// QUOTED_IDENTIFIERS_IGNORE_CASE.
+func convertColumnName(c ColumnNameCase, name string, ignoreCase bool) string {
+	switch c {
+	case ColumnNameLower:
+		return strings.ToLower(name)
+	case ColumnNameNormalize:
+		if ignoreCase || isUpperCaseIdentifier(name) {
+			return strings.ToLower(name)
+		}
+	}
+	return name
+}
+
+// isUpperCaseIdentifier returns true if the name is the form of an unquoted identifier resolved by Snowflake.
+func isUpperCaseIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'A' && r <= 'Z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '$'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertColumnName(t *testing.T) {
+	testcases := []struct {
+		c          ColumnNameCase
+		name       string
+		ignoreCase bool
+		out        string
+	}{
+		{c: "", name: "ID", out: "ID"},
+		{c: ColumnNameAsIs, name: "Id", out: "Id"},
+		{c: ColumnNameLower, name: "Id", out: "id"},
+		{c: ColumnNameNormalize, name: "USER_ID", out: "user_id"},
+		{c: ColumnNameNormalize, name: "C$1", out: "c$1"},
+		{c: ColumnNameNormalize, name: "UserId", out: "UserId"},
+		{c: ColumnNameNormalize, name: "USER ID", out: "USER ID"},
+		{c: ColumnNameNormalize, name: "1A", out: "1A"},
+		{c: ColumnNameNormalize, name: "COUNT(*)", out: "COUNT(*)"},
+		{c: ColumnNameNormalize, name: "COUNT(*)", ignoreCase: true, out: "count(*)"},
+	}
+	for _, test := range testcases {
+		if out := convertColumnName(test.c, test.name, test.ignoreCase); out != test.out {
+			t.Errorf("failed to convert %v with %v. expected: %v, got: %v", test.name, test.c, test.out, out)
+		}
+	}
+}
+
+func TestParseColumnNameCase(t *testing.T) {
+	for _, s := range []string{"asis", "lower", "normalize"} {
+		if c, err := parseColumnNameCase(s); err != nil || string(c) != s {
+			t.Errorf("failed to parse %v. got: %v, err: %v", s, c, err)
+		}
+	}
+	if _, err := parseColumnNameCase("upper"); err == nil {
+		t.Errorf("should have failed to parse upper")
+	}
+}
+
+func TestRowsColumnNameCase(t *testing.T) {
+	v := "true"
+	sc := &snowflakeConn{cfg: &Config{
+		ColumnNameCase: ColumnNameNormalize,
+		Params:         map[string]*string{"QUOTED_IDENTIFIERS_IGNORE_CASE": &v},
+	}}
+	if !sc.isQuotedIdentifiersIgnoreCase() {
+		t.Fatalf("failed to get QUOTED_IDENTIFIERS_IGNORE_CASE")
+	}
+	rows := &snowflakeRows{
+		RowType:        []execResponseRowType{{Name: "ID"}, {Name: "UserName"}},
+		columnNameCase: ColumnNameNormalize,
+	}
+	if cols := rows.Columns(); !reflect.DeepEqual(cols, []string{"id", "UserName"}) {
+		t.Errorf("failed to normalize the column names. got: %v", cols)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// requestCompressionThreshold is the size of the query request bodies, in bytes, above which the bodies are
+// compressed with gzip. Large SQL texts and bindings compress well, and the compression costs less than the
+// transfer.
+var requestCompressionThreshold = 64 * 1024
+
+// gzipBody compresses a request body.
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryRequestCompression(t *testing.T) {
+	var gotHeaders map[string]string
+	var gotBody []byte
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, headers map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		gotHeaders = headers
+		gotBody = body
+		return &execResponse{Success: true}, nil
+	}
+	sc := &snowflakeConn{
+		cfg:  &Config{},
+		rest: &snowflakeRestful{FuncPostQuery: postQuery},
+	}
+	if _, err := sc.exec(context.Background(), "select 1", false, false, nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if _, ok := gotHeaders[headerContentEncoding]; ok {
+		t.Errorf("should not have compressed a small body")
+	}
+	query := "select '" + strings.Repeat("x", requestCompressionThreshold) + "'"
+	if _, err := sc.exec(context.Background(), query, false, false, nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if gotHeaders[headerContentEncoding] != headerContentEncodingGzip {
+		t.Fatalf("failed to set Content-Encoding. headers: %v", gotHeaders)
+	}
+	if len(gotBody) > requestCompressionThreshold/10 {
+		t.Errorf("failed to compress the body. size: %v", len(gotBody))
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gotBody))
+	if err != nil {
+		t.Fatalf("failed to read gzip. err: %v", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decompress. err: %v", err)
+	}
+	var req execRequest
+	if err = json.Unmarshal(b, &req); err != nil || req.SQLText != query {
+		t.Errorf("failed to get the request. err: %v", err)
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
@@ -23,15 +25,39 @@ const (
 )
 
 const (
-	sessionClientSessionKeepAlive = "client_session_keep_alive"
+	sessionClientSessionKeepAlive                   = "client_session_keep_alive"
+	sessionClientSessionKeepAliveHeartbeatFrequency = "client_session_keep_alive_heartbeat_frequency"
+	sessionTimezone                                 = "timezone"
+	// minKeepAliveHeartbeatFrequency is the lower bound of CLIENT_SESSION_KEEP_ALIVE_HEARTBEAT_FREQUENCY.
+	minKeepAliveHeartbeatFrequency = 900 * time.Second
 )
 
 type snowflakeConn struct {
 	cfg            *Config
 	rest           *snowflakeRestful
 	SequeceCounter uint64
-	QueryID        string
+	queryID        string // ID of the last query
 	SQLState       string
+	interceptors   []StatementInterceptor
+	limiter        *queryLimiter // caps the in-flight queries. nil if unlimited.
+	openedAt       time.Time
+	badReason      BadConnReason // why the connection went bad. empty if usable.
+
+	bindStageCreated bool // the temporary stage of the array bindings is created in the session
+
+	loginParams  map[string]string // session parameters sent by the login by upper case names
+	loginContext UseOptions        // role, warehouse, database and schema at the login
+	tempTables   []string          // temporary tables created by the statements, dropped by SessionResetFull
+
+	healthCheckInterval time.Duration // interval of the heartbeats returned by the login. 0 if not returned.
+	masterValidity      time.Duration // validity of the master token returned by the login. 0 if not returned.
+	serverVersion       string        // version of Snowflake returned by the login
+	telemetry           *snowflakeTelemetry
+	queryContext        queryContextCache // query context of the session returned by the queries, guarded by mu
+
+	// mu guards the session state updated by the statements, which may run concurrently through the
+	// prepared statements of the connection.
+	mu sync.Mutex
 }
 
 // isDml returns true if the statement type code is in the range of DML.
@@ -49,87 +75,199 @@ func (sc *snowflakeConn) exec(
 	ctx context.Context,
 	query string, noResult bool, isInternal bool, parameters []driver.NamedValue) (*execResponse, error) {
 	var err error
+	ctx = WithLogFields(ctx, LogField{LogFieldSessionID, sc.rest.SessionID})
+	if query, parameters, err = bindNamedParameters(query, parameters); err != nil {
+		return nil, err
+	}
+	if query, parameters, err = bindConvertedValues(query, parameters); err != nil {
+		return nil, err
+	}
+	var bindings map[string]execBindParameter
+	var bindStage string
+	if len(parameters) > 0 {
+		// the arrays are bound before acquiring the limiter as they may be uploaded by another query
+		if bindings, bindStage, err = sc.bindings(ctx, parameters); err != nil {
+			return nil, err
+		}
+	}
+	if err = sc.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer sc.limiter.release()
 	counter := atomic.AddUint64(&sc.SequeceCounter, 1) // query sequence counter
 
 	req := execRequest{
 		SQLText:    query,
 		AsyncExec:  noResult,
 		SequenceID: counter,
+		Bindings:   bindings,
+		BindStage:  bindStage,
 	}
 	req.IsInternal = isInternal
-	tsmode := "TIMESTAMP_NTZ"
-	idx := 1
-	if len(parameters) > 0 {
-		req.Bindings = make(map[string]execBindParameter, len(parameters))
-		for i, n := 0, len(parameters); i < n; i++ {
-			t := goTypeToSnowflake(parameters[i].Value, tsmode)
-			glog.V(2).Infof("tmode: %v\n", t)
-			if t == "CHANGE_TYPE" {
-				tsmode, err = dataTypeMode(parameters[i].Value)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				v1, err := valueToString(parameters[i].Value, tsmode)
-				if err != nil {
-					return nil, err
-				}
-				req.Bindings[strconv.Itoa(idx)] = execBindParameter{
-					Type:  t,
-					Value: v1,
-				}
-				idx++
+	req.DescribeOnly = isDescribeOnly(ctx)
+	if n, ok := ctx.Value(multiStatementCountKey).(int); ok {
+		req.Parameters = map[string]string{multiStatementCountParam: strconv.Itoa(n)}
+	}
+	if f, ok := ctx.Value(queryResultFormatKey).(string); ok {
+		if req.Parameters == nil {
+			req.Parameters = make(map[string]string)
+		}
+		req.Parameters[queryResultFormatParam] = f
+	}
+	if !isInternal {
+		req.SQLText = commentQuery(ctx, sc.cfg, query)
+		if tag, ok := queryTag(ctx); ok {
+			if req.Parameters == nil {
+				req.Parameters = make(map[string]string)
 			}
+			req.Parameters[queryTagParam] = tag
 		}
 	}
-	glog.V(2).Infof("bindings: %v", req.Bindings)
+	if !sc.cfg.DisableQueryContextCache {
+		sc.mu.Lock()
+		req.QueryContext = sc.queryContext.request()
+		sc.mu.Unlock()
+	}
+	glog.WithContext(ctx).V(2).Infof("bindings: %v", req.Bindings)
 
 	headers := make(map[string]string)
 	headers["Content-Type"] = headerContentTypeApplicationJSON
 	headers["accept"] = headerAcceptTypeApplicationSnowflake // TODO v1.1: change to JSON in case of PUT/GET
 	headers["User-Agent"] = userAgent
+	addRequestHeaders(ctx, headers)
 
 	jsonBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
+	if len(jsonBody) > requestCompressionThreshold {
+		if jsonBody, err = gzipBody(jsonBody); err != nil {
+			return nil, err
+		}
+		headers[headerContentEncoding] = headerContentEncodingGzip
+	}
 
 	var data *execResponse
+	start := getClock().Now()
 	data, err = sc.rest.FuncPostQuery(ctx, sc.rest, &url.Values{}, headers, jsonBody, sc.rest.RequestTimeout)
+	roundTrip := getClock().Now().Sub(start)
+	if err == nil && !isInternal {
+		sc.telemetry.addQueryRoundTrip(data.Data.QueryID, roundTrip)
+	}
+	if !isInternal {
+		recordQueryMetrics(roundTrip, err != nil || !data.Success)
+	}
 	if err != nil {
+		if reason := badConnReasonOf(ctx, err); reason != "" {
+			sc.markBad(reason)
+		}
 		return nil, err
 	}
-	var code int
-	if data.Code != "" {
-		code, err = strconv.Atoi(data.Code)
-		if err != nil {
-			code = -1
-			return nil, err
-		}
-	} else {
-		code = -1
+	if !sc.cfg.DisableQueryContextCache && data.Data.QueryContext != nil {
+		// the failed queries return the query context too
+		sc.mu.Lock()
+		sc.queryContext.merge(data.Data.QueryContext, sc.queryContextCacheSize())
+		sc.mu.Unlock()
 	}
-	glog.V(2).Infof("Success: %v, Code: %v", data.Success, code)
+	logger := glog.WithContext(WithLogFields(ctx, LogField{LogFieldQueryID, data.Data.QueryID}))
+	logger.V(2).Infof("Success: %v, Code: %v", data.Success, data.Code)
 	if !data.Success {
-		return nil, &SnowflakeError{
-			Number:   code,
-			SQLState: data.Data.SQLState,
-			Message:  data.Message,
-			QueryID:  data.Data.QueryID,
+		err = execResponseError(data)
+		if data.Data.QueryID != "" {
+			sc.mu.Lock()
+			sc.queryID = data.Data.QueryID
+			sc.mu.Unlock()
+		}
+		if reason := badConnReasonOf(ctx, err); reason != "" {
+			sc.markBad(reason)
 		}
+		return nil, err
 	}
-	glog.V(2).Info("Exec/Query SUCCESS")
+	logger.V(2).Info("Exec/Query SUCCESS")
+	sc.mu.Lock()
 	sc.cfg.Database = data.Data.FinalDatabaseName
 	sc.cfg.Schema = data.Data.FinalSchemaName
 	sc.cfg.Role = data.Data.FinalRoleName
 	sc.cfg.Warehouse = data.Data.FinalWarehouseName
-	sc.QueryID = data.Data.QueryID
+	sc.queryID = data.Data.QueryID
 	sc.SQLState = data.Data.SQLState
 	sc.populateSessionParameters(data.Data.Parameters)
+	if !isInternal {
+		sc.trackTempTable(query)
+	}
+	sc.mu.Unlock()
 	sc.startHeartBeat()
 	return data, err
 }
 
+// bindings returns the bindings of the parameters in the request, or the stage location where the arrays of
+// the parameters are uploaded.
+func (sc *snowflakeConn) bindings(ctx context.Context, parameters []driver.NamedValue) (map[string]execBindParameter, string, error) {
+	var err error
+	tsmode := "TIMESTAMP_NTZ"
+	idx := 1
+	bindings := make(map[string]execBindParameter, len(parameters))
+	var arrays []*arrayBinding
+	for i, n := 0, len(parameters); i < n; i++ {
+		if a, ok := parameters[i].Value.(*bindArray); ok {
+			b, err := a.bind(tsmode)
+			if err != nil {
+				return nil, "", err
+			}
+			if bindings[strconv.Itoa(idx)], err = b.parameter(); err != nil {
+				return nil, "", err
+			}
+			arrays = append(arrays, b)
+			idx++
+			continue
+		}
+		if tt, ok := parameters[i].Value.(typedTime); ok {
+			v1, err := valueToString(tt.t, tt.tsmode)
+			if err != nil {
+				return nil, "", err
+			}
+			bindings[strconv.Itoa(idx)] = execBindParameter{Type: tt.tsmode, Value: v1}
+			idx++
+			continue
+		}
+		t := goTypeToSnowflake(parameters[i].Value, tsmode)
+		glog.V(2).Infof("tmode: %v\n", t)
+		if t == "CHANGE_TYPE" {
+			tsmode, err = dataTypeMode(parameters[i].Value)
+			if err != nil {
+				return nil, "", err
+			}
+		} else {
+			v1, err := valueToString(parameters[i].Value, tsmode)
+			if err != nil {
+				return nil, "", err
+			}
+			bindings[strconv.Itoa(idx)] = execBindParameter{
+				Type:  t,
+				Value: v1,
+			}
+			idx++
+		}
+	}
+	if len(arrays) == 0 {
+		return bindings, "", nil
+	}
+	if err = checkArrayBindings(len(bindings), arrays); err != nil {
+		return nil, "", err
+	}
+	if stage := sc.stageArrayBindings(ctx, arrays); stage != "" {
+		return nil, stage, nil
+	}
+	return bindings, "", nil
+}
+
+// QueryID returns the ID of the last query run by the connection, or an empty string if none has been run.
+func (sc *snowflakeConn) QueryID() string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.queryID
+}
+
 func (sc *snowflakeConn) Begin() (driver.Tx, error) {
 	return sc.BeginTx(context.TODO(), driver.TxOptions{})
 }
@@ -150,8 +288,8 @@ func (sc *snowflakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (dr
 			Message:  errMsgNoDefaultTransactionIsolationLevel,
 		}
 	}
-	if sc.rest == nil {
-		return nil, driver.ErrBadConn
+	if err := sc.checkConn(); err != nil {
+		return nil, err
 	}
 	_, err := sc.exec(ctx, "BEGIN", false, false, nil)
 	if err != nil {
@@ -168,29 +306,47 @@ func (sc *snowflakeConn) cleanup() {
 
 func (sc *snowflakeConn) Close() (err error) {
 	glog.V(2).Infoln("Close")
-	sc.stopHeartBeat()
+	// the session is closed even if the hook fails
+	hookErr := sc.runSessionHook(context.Background(), "OnSessionClose", sc.cfg.OnSessionClose)
+	sc.closeSession()
+	return hookErr
+}
+
+// closeSession rolls back the transaction and closes the session.
+func (sc *snowflakeConn) closeSession() {
+	recordConnClosed(sc.lifetime())
 
 	// ensure transaction is rollbacked
-	_, err = sc.exec(context.Background(), "ROLLBACK", false, false, nil)
+	_, err := sc.exec(context.Background(), "ROLLBACK", false, false, nil)
 	if err != nil {
 		glog.V(2).Info(err)
 	}
+	sc.stopHeartBeat()
+	if err = sc.telemetry.sendBatch(); err != nil {
+		glog.V(2).Infof("failed to send the telemetry. err: %v", err)
+	}
 	err = sc.rest.FuncCloseSession(sc.rest)
 	if err != nil {
 		glog.V(2).Info(err)
 	}
 	sc.cleanup()
-	return nil
 }
 func (sc *snowflakeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	glog.V(2).Infoln("Prepare")
-	if sc.rest == nil {
-		return nil, driver.ErrBadConn
+	if err := sc.checkConn(); err != nil {
+		return nil, err
 	}
 	stmt := &snowflakeStmt{
 		sc:    sc,
 		query: query,
 	}
+	if isDescribable(ctx, query) {
+		desc, err := sc.describe(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		stmt.desc = desc
+	}
 	return stmt, nil
 }
 
@@ -198,66 +354,128 @@ func (sc *snowflakeConn) Prepare(query string) (driver.Stmt, error) {
 	return sc.PrepareContext(context.TODO(), query)
 }
 
-func (sc *snowflakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+func (sc *snowflakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
 	glog.V(2).Infof("Exec: %#v, %v", query, args)
-	if sc.rest == nil {
-		return nil, driver.ErrBadConn
+	if err = sc.checkConn(); err != nil {
+		return nil, err
 	}
+	query, args, err = sc.intercept(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withConfigProgress(ctx, sc.cfg)
+	ctx, cancel := withStatementDeadline(ctx, sc.cfg.QueryTimeout)
+	defer cancel()
+	defer func() {
+		if err != nil {
+			err = queryTimeoutError(ctx, err)
+		}
+	}()
 	// TODO: handle noResult and isInternal
 	data, err := sc.exec(ctx, query, false, false, args)
 	if err != nil {
 		return nil, err
 	}
-	var updatedRows int64
+	if isDescribeOnly(ctx) {
+		return &snowflakeResultNoRows{Result: driver.ResultNoRows, queryID: data.Data.QueryID}, nil
+	}
+	if data.Data.Command != "" {
+		// PUT or GET
+		if _, err = sc.transferFiles(ctx, data); err != nil {
+			return nil, withQueryID(err, data.Data.QueryID)
+		}
+		return &snowflakeResultNoRows{Result: driver.ResultNoRows, queryID: data.Data.QueryID}, nil
+	}
+	if data.Data.StatementTypeID == statementTypeIDMultiStatement {
+		n, err := sc.multiStatementRowsAffected(ctx, data)
+		if err != nil {
+			return nil, withQueryID(err, data.Data.QueryID)
+		}
+		return &snowflakeResult{
+			affectedRows: n,
+			insertID:     -1,
+			queryID:      data.Data.QueryID}, nil
+	}
 	if sc.isDml(data.Data.StatementTypeID) {
-		// collects all values from the returned row sets
-		updatedRows = 0
-		for i, n := 0, len(data.Data.RowType); i < n; i++ {
-			v, err := strconv.ParseInt(*data.Data.RowSet[0][i], 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			updatedRows += v
+		n, err := updatedRows(data)
+		if err != nil {
+			return nil, withQueryID(err, data.Data.QueryID)
 		}
-		glog.V(2).Infof("number of updated rows: %#v", updatedRows)
+		glog.V(2).Infof("number of updated rows: %#v", n)
 		return &snowflakeResult{
-			affectedRows: updatedRows,
-			insertID:     -1}, nil // last insert id is not supported by Snowflake
+			affectedRows: n,
+			insertID:     -1, // last insert id is not supported by Snowflake
+			queryID:      data.Data.QueryID}, nil
 	}
 	glog.V(2).Info("DDL")
-	return driver.ResultNoRows, nil
+	return &snowflakeResultNoRows{Result: driver.ResultNoRows, queryID: data.Data.QueryID}, nil
 }
 
-func (sc *snowflakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+func (sc *snowflakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
 	glog.V(2).Infoln("Query")
-	if sc.rest == nil {
-		return nil, driver.ErrBadConn
+	if err = sc.checkConn(); err != nil {
+		return nil, err
 	}
+	query, args, err = sc.intercept(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withConfigProgress(ctx, sc.cfg)
+	// the statement timeout covers fetching the result, so it's released when the rows are closed.
+	ctx, cancel := withStatementDeadline(ctx, sc.cfg.QueryTimeout)
+	defer func() {
+		if err != nil {
+			err = queryTimeoutError(ctx, err)
+		}
+	}()
 	// TODO: handle noResult and isInternal
-	data, err := sc.exec(ctx, query, false, false, args)
+	data, err := sc.queryWithResultCache(ctx, query, args)
 	if err != nil {
 		glog.V(2).Infof("error: %v", err)
+		cancel()
 		return nil, err
 	}
+	if data.Data.Command != "" && !isDescribeOnly(ctx) {
+		// PUT or GET, whose result is the files transferred by the client
+		queryID := data.Data.QueryID
+		if data, err = sc.transferFiles(ctx, data); err != nil {
+			cancel()
+			return nil, withQueryID(err, queryID)
+		}
+	}
+
+	rows := sc.newRows(ctx, cancel)
+	if ids := multiStatementResultIDs(data); len(ids) > 0 {
+		// the rows start with the result of the first statement
+		if data, err = sc.queryResult(ctx, ids[0]); err != nil {
+			cancel()
+			return nil, err
+		}
+		rows.resultIDs = ids[1:]
+	}
+	if err = rows.setResult(data); err != nil {
+		cancel()
+		return nil, withQueryID(err, data.Data.QueryID)
+	}
+	return rows, nil
+}
 
+// newRows returns the rows of a result read with the context, which is canceled by cancel when the rows are closed.
+func (sc *snowflakeConn) newRows(ctx context.Context, cancel context.CancelFunc) *snowflakeRows {
 	rows := new(snowflakeRows)
 	rows.sc = sc
-	rows.RowType = data.Data.RowType
-	rows.ChunkDownloader = &snowflakeChunkDownloader{
-		sc:                 sc,
-		ctx:                ctx,
-		CurrentChunk:       data.Data.RowSet,
-		ChunkMetas:         data.Data.Chunks,
-		Total:              int64(data.Data.Total),
-		TotalRowIndex:      int64(-1),
-		Qrmk:               data.Data.Qrmk,
-		ChunkHeader:        data.Data.ChunkHeaders,
-		FuncDownload:       downloadChunk,
-		FuncDownloadHelper: downloadChunkHelper,
-		FuncGet:            getChunk,
-	}
-	rows.ChunkDownloader.start()
-	return rows, err
+	rows.ctx = ctx
+	rows.cancel = cancel
+	rows.invalidUTF8 = sc.cfg.InvalidUTF8
+	rows.location = queryLocation(ctx, sc.cfg)
+	rows.ltzLocation = sc.sessionLocation()
+	rows.geographyFormat = sc.geoOutputFormat(sessionGeographyOutputFormat)
+	rows.geometryFormat = sc.geoOutputFormat(sessionGeometryOutputFormat)
+	rows.numberMode = queryNumberMode(ctx, sc.cfg)
+	rows.columnNameCase = sc.cfg.ColumnNameCase
+	rows.quotedIdentifiersIgnoreCase = sc.isQuotedIdentifiersIgnoreCase()
+	rows.arrowBatches = isArrowBatchesMode(ctx)
+	return rows
 }
 
 func (sc *snowflakeConn) Exec(
@@ -274,16 +492,36 @@ func (sc *snowflakeConn) Query(
 	return sc.QueryContext(context.TODO(), query, toNamedValues(args))
 }
 
+// Ping checks the session is alive by a heartbeat, which renews the session token if expired, instead of running
+// a query in the warehouse. The connection is marked bad if the session is gone or Snowflake is unreachable.
 func (sc *snowflakeConn) Ping(ctx context.Context) error {
 	glog.V(2).Infoln("Ping")
-	if sc.rest == nil {
-		return driver.ErrBadConn
+	if err := sc.checkConn(); err != nil {
+		return err
+	}
+	if !sc.rest.hasCapability(capabilityHeartbeat) {
+		_, err := sc.exec(ctx, "SELECT 1", false, false, []driver.NamedValue{})
+		return err
+	}
+	hb := &heartbeat{restful: sc.rest}
+	err := hb.heartbeatMain(ctx)
+	if reason := badConnReasonOf(ctx, err); reason != "" {
+		sc.markBad(reason)
 	}
-	// TODO: handle noResult and isInternal
-	_, err := sc.exec(ctx, "SELECT 1", false, false, []driver.NamedValue{})
 	return err
 }
 
+// IsValid implements driver.Validator, so that database/sql discards the connection gone bad instead of
+// returning it to the pool. It doesn't call Snowflake.
+func (sc *snowflakeConn) IsValid() bool {
+	if sc.rest == nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.badReason == ""
+}
+
 func (sc *snowflakeConn) populateSessionParameters(parameters []nameValueParameter) {
 	// other session parameters (not all)
 	glog.V(2).Infof("params: %#v", parameters)
@@ -308,31 +546,145 @@ func (sc *snowflakeConn) populateSessionParameters(parameters []nameValueParamet
 			}
 		}
 		glog.V(3).Infof("parameter. name: %v, value: %v", param.Name, v)
-		sc.cfg.Params[strings.ToLower(param.Name)] = &v
+		sc.setSessionParameter(param.Name, &v)
 	}
 }
 
 func (sc *snowflakeConn) isClientSessionKeepAliveEnabled() bool {
-	v, ok := sc.cfg.Params[sessionClientSessionKeepAlive]
-	if !ok {
-		return false
+	v, ok := sc.SessionParameter(sessionClientSessionKeepAlive)
+	return ok && strings.EqualFold(v, "true")
+}
+
+// keepAliveInterval returns the interval of the heartbeats enabled by client_session_keep_alive, i.e.,
+// client_session_keep_alive_heartbeat_frequency seconds between 900 seconds and a quarter of the master token
+// validity, as the Python driver does. If the frequency isn't set, the health check interval of the login is used
+// if returned, otherwise a quarter of the master token validity.
+func (sc *snowflakeConn) keepAliveInterval() time.Duration {
+	max := heartBeatInterval
+	if sc.masterValidity > 0 {
+		max = sc.masterValidity / 4
+	}
+	v, ok := sc.SessionParameter(sessionClientSessionKeepAliveHeartbeatFrequency)
+	var n int64
+	var err error
+	if ok {
+		n, err = strconv.ParseInt(v, 10, 64)
+	}
+	if !ok || err != nil || n <= 0 {
+		if sc.healthCheckInterval > 0 {
+			return sc.healthCheckInterval
+		}
+		return max
 	}
-	return strings.Compare(*v, "true") == 0
+	interval := time.Duration(n) * time.Second
+	if interval > max {
+		interval = max
+	}
+	if interval < minKeepAliveHeartbeatFrequency {
+		interval = minKeepAliveHeartbeatFrequency
+	}
+	return interval
 }
 
+func (sc *snowflakeConn) isQuotedIdentifiersIgnoreCase() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	// the session parameters given in DSN keep the case
+	for k, v := range sc.cfg.Params {
+		if strings.EqualFold(k, sessionQuotedIdentifiersIgnoreCase) && v != nil {
+			return strings.EqualFold(*v, "true")
+		}
+	}
+	return false
+}
+
+// heartbeatInterval returns the interval of the heartbeats of the connection, or 0 if the heartbeats are disabled.
+// If client_session_keep_alive is set, it decides whether the heartbeats run, at keepAliveInterval. Otherwise the
+// health check interval of the login is used if returned.
+func (sc *snowflakeConn) heartbeatInterval() time.Duration {
+	if sc.cfg.DisableHeartbeat {
+		return 0
+	}
+	if _, ok := sc.SessionParameter(sessionClientSessionKeepAlive); ok {
+		if !sc.isClientSessionKeepAliveEnabled() {
+			return 0
+		}
+		return sc.keepAliveInterval()
+	}
+	return sc.healthCheckInterval
+}
+
+// startHeartBeat starts the heartbeat of the connection at heartbeatInterval. It's called after every statement,
+// so the heartbeat is restarted or stopped when the session parameters enabling it are altered.
 func (sc *snowflakeConn) startHeartBeat() {
-	if !sc.isClientSessionKeepAliveEnabled() {
+	interval := sc.heartbeatInterval()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if hb := sc.rest.HeartBeat; hb != nil {
+		if hb.interval == interval {
+			// already started by a previous statement
+			return
+		}
+		hb.stop()
+		sc.rest.HeartBeat = nil
+	}
+	if interval == 0 {
 		return
 	}
 	sc.rest.HeartBeat = &heartbeat{
-		restful: sc.rest,
+		restful:  sc.rest,
+		interval: interval,
 	}
 	sc.rest.HeartBeat.start()
 }
 
 func (sc *snowflakeConn) stopHeartBeat() {
-	if !sc.isClientSessionKeepAliveEnabled() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.rest == nil || sc.rest.HeartBeat == nil {
 		return
 	}
 	sc.rest.HeartBeat.stop()
+	sc.rest.HeartBeat = nil
+}
+
+// updatedRows returns the number of rows affected by the DML statement, i.e., the sum of the values in the
+// returned row set, e.g., the rows inserted and updated by MERGE.
+func updatedRows(data *execResponse) (int64, error) {
+	var ret int64
+	chunk, err := firstChunk(data)
+	if err != nil || chunk.len() == 0 {
+		return 0, err
+	}
+	row := chunk.row(0)
+	for i, n := 0, len(data.Data.RowType); i < n; i++ {
+		var v driver.Value
+		if err = row.value(&v, i, data.Data.RowType[i]); err != nil {
+			return 0, err
+		}
+		s, _ := v.(string)
+		count, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		ret += count
+	}
+	return ret, nil
+}
+
+// execResponseError returns the error of the failed query response.
+func execResponseError(data *execResponse) error {
+	code := -1
+	if data.Code != "" {
+		var err error
+		if code, err = strconv.Atoi(data.Code); err != nil {
+			return err
+		}
+	}
+	return &SnowflakeError{
+		Number:   code,
+		SQLState: data.Data.SQLState,
+		Message:  data.Message,
+		QueryID:  data.Data.QueryID,
+	}
 }
@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+)
+
+// Option configures a Connector of NewConnector.
+type Option func(*connector)
+
+// WithTransport sets the transport of all HTTP requests of the connections unless the Config has one.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *connector) {
+		c.driver.Transport = rt
+	}
+}
+
+// WithInterceptor sets the interceptor called before every Exec and Query of the connections, after the
+// interceptors registered by RegisterStatementInterceptor.
+func WithInterceptor(i StatementInterceptor) Option {
+	return func(c *connector) {
+		c.driver.Interceptor = i
+	}
+}
+
+// WithTokenProvider sets the provider of the OAuth access token at every login unless the Config has one.
+func WithTokenProvider(p TokenProvider) Option {
+	return func(c *connector) {
+		c.driver.TokenProvider = p
+	}
+}
+
+// WithCredentialProviders sets the credential provider chain for authenticator=auto unless the Config has one.
+func WithCredentialProviders(providers ...CredentialProvider) Option {
+	return func(c *connector) {
+		c.driver.CredentialProviders = providers
+	}
+}
+
+type connector struct {
+	driver SnowflakeDriver
+	cfg    Config
+}
+
+// NewConnector returns the connector opening the connections with the config, for sql.OpenDB, so that the
+// fields that cannot be included in a DSN, e.g., PrivateKey or Transport, are used:
+//
+//	db := sql.OpenDB(sf.NewConnector(&sf.Config{Account: "a", User: "u", Authenticator: "snowflake_jwt", PrivateKey: key}))
+//
+// The config is copied, so changing it later doesn't affect the connector.
+func NewConnector(cfg *Config, opts ...Option) driver.Connector {
+	c := &connector{cfg: *cfg}
+	c.cfg.Params = copyParams(cfg.Params)
+	// the connections of the connector start with the private key accepted last
+	c.cfg.keyRotation = &keyRotation{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Connect opens a connection with the config.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	glog.V(2).Info("Connect")
+	return c.driver.OpenWithConfig(ctx, c.cfg)
+}
+
+// Driver returns the driver of the connector.
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// copyParams returns a copy of the session parameters, which are updated by each connection.
+func copyParams(params map[string]*string) map[string]*string {
+	copied := make(map[string]*string, len(params))
+	for k, v := range params {
+		copied[k] = v
+	}
+	return copied
+}
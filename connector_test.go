@@ -0,0 +1,39 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"testing"
+)
+
+func TestNewConnector(t *testing.T) {
+	tz := "UTC"
+	cfg := &Config{Account: "a", User: "u", Password: "p", Params: map[string]*string{"timezone": &tz}}
+	interceptor := func(ctx context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+		return query, args, nil
+	}
+	tokenProvider := func(ctx context.Context) (string, error) {
+		return "t", nil
+	}
+	c := NewConnector(cfg,
+		WithTransport(http.DefaultTransport),
+		WithInterceptor(interceptor),
+		WithTokenProvider(tokenProvider),
+		WithCredentialProviders(&EnvCredentialProvider{}),
+	).(*connector)
+	cfg.User = "changed"
+	cfg.Params["timezone"] = nil
+	if c.cfg.User != "u" || c.cfg.Params["timezone"] != &tz {
+		t.Errorf("should have copied the config. got: %v, %v", c.cfg.User, c.cfg.Params)
+	}
+	d, ok := c.Driver().(SnowflakeDriver)
+	if !ok {
+		t.Fatalf("should have been SnowflakeDriver. got: %T", c.Driver())
+	}
+	if d.Transport != http.DefaultTransport || d.Interceptor == nil || d.TokenProvider == nil || len(d.CredentialProviders) != 1 {
+		t.Errorf("failed to apply the options. got: %+v", d)
+	}
+}
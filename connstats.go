@@ -0,0 +1,180 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"sync"
+	"time"
+)
+
+// BadConnReason is the reason the driver returned driver.ErrBadConn, which makes database/sql discard the
+// connection and retry on another one.
+type BadConnReason string
+
+const (
+	// BadConnClosed is used when the connection was already closed.
+	BadConnClosed BadConnReason = "closed"
+	// BadConnTokenExpired is used when the session token could not be renewed, e.g., the master token expired.
+	BadConnTokenExpired BadConnReason = "token_expired"
+	// BadConnNetwork is used when a request of the connection failed with a network error.
+	BadConnNetwork BadConnReason = "network"
+	// BadConnServerAbort is used when the server dropped the session.
+	BadConnServerAbort BadConnReason = "server_abort"
+	// BadConnResetFailed is used when the session could not be reset by Config.SessionReset.
+	BadConnResetFailed BadConnReason = "reset_failed"
+)
+
+// sessionGoneCode is the error code of the session dropped by the server.
+const sessionGoneCode = 390111
+
+// lifetimeBuckets are the upper bounds of the connection lifetime histograms.
+var lifetimeBuckets = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 4 * time.Hour,
+}
+
+// LifetimeHistogram is a histogram of connection lifetimes. Counts[i] is the number of connections that lived
+// up to Buckets[i], and the last element of Counts is the number of connections that lived longer.
+type LifetimeHistogram struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Sum     time.Duration
+	Max     time.Duration
+}
+
+func (h *LifetimeHistogram) observe(d time.Duration) {
+	if h.Counts == nil {
+		h.Buckets = lifetimeBuckets
+		h.Counts = make([]int64, len(lifetimeBuckets)+1)
+	}
+	i := 0
+	for i < len(h.Buckets) && d > h.Buckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+func (h LifetimeHistogram) clone() LifetimeHistogram {
+	h.Counts = append([]int64(nil), h.Counts...)
+	return h
+}
+
+// ConnStats is a snapshot of the connection counters of the driver, to tune SetConnMaxLifetime and
+// SetConnMaxIdleTime of sql.DB with the actual lifetimes. For example, if the connections go bad with
+// BadConnTokenExpired after 4 hours, a shorter SetConnMaxLifetime avoids the failures.
+type ConnStats struct {
+	Opened           int64                   // connections opened
+	Closed           int64                   // connections closed
+	BadConn          map[BadConnReason]int64 // driver.ErrBadConn returned by reason
+	Lifetimes        LifetimeHistogram       // lifetimes of the closed connections
+	BadConnLifetimes LifetimeHistogram       // lifetimes of the connections when they went bad
+}
+
+var connStats = struct {
+	mu sync.Mutex
+	ConnStats
+}{ConnStats: ConnStats{BadConn: make(map[BadConnReason]int64)}}
+
+// GetConnStats returns the connection counters of all connections opened by the driver.
+func GetConnStats() ConnStats {
+	connStats.mu.Lock()
+	defer connStats.mu.Unlock()
+	s := connStats.ConnStats
+	s.BadConn = make(map[BadConnReason]int64, len(connStats.BadConn))
+	for k, v := range connStats.BadConn {
+		s.BadConn[k] = v
+	}
+	s.Lifetimes = s.Lifetimes.clone()
+	s.BadConnLifetimes = s.BadConnLifetimes.clone()
+	return s
+}
+
+func recordConnOpened() {
+	connStats.mu.Lock()
+	defer connStats.mu.Unlock()
+	connStats.Opened++
+	addMetricsGauge(MetricOpenSessions, 1)
+}
+
+func recordConnClosed(lifetime time.Duration) {
+	connStats.mu.Lock()
+	defer connStats.mu.Unlock()
+	connStats.Closed++
+	connStats.Lifetimes.observe(lifetime)
+	addMetricsGauge(MetricOpenSessions, -1)
+}
+
+func recordBadConn(reason BadConnReason) {
+	connStats.mu.Lock()
+	defer connStats.mu.Unlock()
+	connStats.BadConn[reason]++
+}
+
+// badConnReasonOf returns the reason the connection can't be used anymore after the error, or an empty string
+// if the connection is still usable.
+func badConnReasonOf(ctx context.Context, err error) BadConnReason {
+	if ctx.Err() != nil {
+		// canceled by the application
+		return ""
+	}
+	switch e := err.(type) {
+	case *SnowflakeError:
+		switch e.Number {
+		case ErrFailedToRenewSession, ErrCodeSessionExpired, 390114:
+			return BadConnTokenExpired
+		case sessionGoneCode:
+			return BadConnServerAbort
+		case ErrRetryTimeout:
+			return BadConnNetwork
+		}
+	case net.Error:
+		return BadConnNetwork
+	}
+	return ""
+}
+
+// lifetime returns the time since the connection was opened.
+func (sc *snowflakeConn) lifetime() time.Duration {
+	if sc.openedAt.IsZero() {
+		return 0
+	}
+	return getClock().Now().Sub(sc.openedAt)
+}
+
+// markBad records the connection went bad, so that the next call returns driver.ErrBadConn.
+func (sc *snowflakeConn) markBad(reason BadConnReason) {
+	sc.mu.Lock()
+	first := sc.badReason == ""
+	if first {
+		sc.badReason = reason
+	}
+	sc.mu.Unlock()
+	if first {
+		glog.V(1).Infof("connection went bad after %v. reason: %v", sc.lifetime(), reason)
+		connStats.mu.Lock()
+		connStats.BadConnLifetimes.observe(sc.lifetime())
+		connStats.mu.Unlock()
+	}
+}
+
+// checkConn returns driver.ErrBadConn if the connection is closed or went bad.
+func (sc *snowflakeConn) checkConn() error {
+	if sc.rest == nil {
+		recordBadConn(BadConnClosed)
+		return driver.ErrBadConn
+	}
+	sc.mu.Lock()
+	reason := sc.badReason
+	sc.mu.Unlock()
+	if reason != "" {
+		recordBadConn(reason)
+		return driver.ErrBadConn
+	}
+	return nil
+}
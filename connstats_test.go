@@ -0,0 +1,159 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBadConnReasonOf(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	testcases := []struct {
+		ctx    context.Context
+		err    error
+		reason BadConnReason
+	}{
+		{ctx: context.Background(), err: &SnowflakeError{Number: ErrFailedToRenewSession}, reason: BadConnTokenExpired},
+		{ctx: context.Background(), err: &SnowflakeError{Number: 390114}, reason: BadConnTokenExpired},
+		{ctx: context.Background(), err: &SnowflakeError{Number: sessionGoneCode}, reason: BadConnServerAbort},
+		{ctx: context.Background(), err: &SnowflakeError{Number: ErrRetryTimeout}, reason: BadConnNetwork},
+		{ctx: context.Background(), err: &net.OpError{Op: "dial", Err: errors.New("refused")}, reason: BadConnNetwork},
+		{ctx: context.Background(), err: &SnowflakeError{Number: 1003}, reason: ""},
+		{ctx: context.Background(), err: errors.New("boom"), reason: ""},
+		{ctx: canceled, err: &net.OpError{Op: "read", Err: context.Canceled}, reason: ""},
+	}
+	for _, test := range testcases {
+		if reason := badConnReasonOf(test.ctx, test.err); reason != test.reason {
+			t.Errorf("failed to classify the error. err: %v, expected: %v, got: %v", test.err, test.reason, reason)
+		}
+	}
+}
+
+func TestLifetimeHistogram(t *testing.T) {
+	var h LifetimeHistogram
+	for _, d := range []time.Duration{30 * time.Second, time.Minute, 10 * time.Minute, 5 * time.Hour} {
+		h.observe(d)
+	}
+	expected := []int64{2, 0, 1, 0, 0, 0, 1}
+	for i, c := range expected {
+		if h.Counts[i] != c {
+			t.Errorf("failed to count the bucket. bucket: %v, expected: %v, got: %v", i, c, h.Counts[i])
+		}
+	}
+	if h.Max != 5*time.Hour || h.Sum != 5*time.Hour+11*time.Minute+30*time.Second {
+		t.Errorf("failed to sum the lifetimes. sum: %v, max: %v", h.Sum, h.Max)
+	}
+}
+
+func TestBadConnAccounting(t *testing.T) {
+	before := GetConnStats()
+	var postErr error
+	sc := &snowflakeConn{
+		cfg: &Config{},
+		rest: &snowflakeRestful{
+			FuncPostQuery: func(context.Context, *snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*execResponse, error) {
+				if postErr != nil {
+					return nil, postErr
+				}
+				return &execResponse{Success: true}, nil
+			},
+			FuncCloseSession: func(*snowflakeRestful) error { return nil },
+		},
+		openedAt: time.Now().Add(-10 * time.Minute),
+	}
+	if _, err := sc.ExecContext(context.Background(), "select 1", nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	postErr = &SnowflakeError{Number: ErrFailedToRenewSession}
+	if _, err := sc.ExecContext(context.Background(), "select 1", nil); err != postErr {
+		t.Fatalf("should have returned the original error. err: %v", err)
+	}
+	postErr = nil
+	for i := 0; i < 2; i++ {
+		if _, err := sc.ExecContext(context.Background(), "select 1", nil); err != driver.ErrBadConn {
+			t.Fatalf("should have returned ErrBadConn. err: %v", err)
+		}
+	}
+	sc.Close()
+	if err := sc.Ping(context.Background()); err != driver.ErrBadConn {
+		t.Fatalf("should have returned ErrBadConn for the closed connection. err: %v", err)
+	}
+
+	after := GetConnStats()
+	if n := after.BadConn[BadConnTokenExpired] - before.BadConn[BadConnTokenExpired]; n != 2 {
+		t.Errorf("failed to count the token expiry. got: %v", n)
+	}
+	if n := after.BadConn[BadConnClosed] - before.BadConn[BadConnClosed]; n != 1 {
+		t.Errorf("failed to count the closed connection. got: %v", n)
+	}
+	if after.Closed-before.Closed != 1 || after.Lifetimes.Counts[2]-countAt(before.Lifetimes, 2) != 1 {
+		t.Errorf("failed to record the lifetime of the closed connection. stats: %v", after.Lifetimes)
+	}
+	if after.BadConnLifetimes.Counts[2]-countAt(before.BadConnLifetimes, 2) != 1 {
+		t.Errorf("failed to record the lifetime of the bad connection. stats: %v", after.BadConnLifetimes)
+	}
+}
+
+func TestPingIsValid(t *testing.T) {
+	testcases := []struct {
+		body  string
+		err   error
+		valid bool
+	}{
+		{body: `{"success":true}`, valid: true},
+		{body: `{"success":false,"code":"390111","message":"Session no longer exists."}`, valid: false},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, valid: false},
+	}
+	for i, test := range testcases {
+		var queries int
+		sc := &snowflakeConn{
+			cfg: &Config{},
+			rest: &snowflakeRestful{
+				FuncPost: func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error) {
+					if test.err != nil {
+						return nil, test.err
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBufferString(test.body))}, nil
+				},
+				FuncPostQuery: func(context.Context, *snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*execResponse, error) {
+					queries++
+					return &execResponse{Success: true}, nil
+				},
+			},
+		}
+		if !sc.IsValid() {
+			t.Fatalf("%d: should have been valid before the ping", i)
+		}
+		err := sc.Ping(context.Background())
+		if (err == nil) != test.valid {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+		if sc.IsValid() != test.valid {
+			t.Errorf("%d: failed to validate. expected: %v", i, test.valid)
+		}
+		if queries != 0 {
+			t.Errorf("%d: should not have run a query", i)
+		}
+	}
+	sc := &snowflakeConn{}
+	if sc.IsValid() {
+		t.Errorf("should not have been valid after closed")
+	}
+}
+
+func countAt(h LifetimeHistogram, i int) int64 {
+	if h.Counts == nil {
+		return 0
+	}
+	return h.Counts[i]
+}
@@ -53,7 +53,7 @@ func snowflakeTypeToGo(dbtype string, scale int64) reflect.Type {
 		return reflect.TypeOf(float64(0))
 	case "real":
 		return reflect.TypeOf(float64(0))
-	case "text", "variant", "object", "array":
+	case "text", "variant", "object", "array", "geography", "geometry":
 		return reflect.TypeOf("")
 	case "date", "time", "timestamp_ltz", "timestamp_ntz", "timestamp_tz":
 		return reflect.TypeOf(time.Now())
@@ -117,8 +117,6 @@ func valueToString(v driver.Value, tsmode string) (*string, error) {
 				s := fmt.Sprintf("%d", tm.UnixNano())
 				return &s, nil
 			case "TIMESTAMP_LTZ":
-				_, offset := tm.Zone()
-				tm = tm.Add(time.Second * time.Duration(offset))
 				s := fmt.Sprintf("%d", tm.UnixNano())
 				return &s, nil
 			case "TIMESTAMP_TZ":
@@ -172,7 +170,7 @@ func stringToValue(dest *driver.Value, srcColumnMeta execResponseRowType, srcVal
 	}
 	glog.V(3).Infof("snowflake data type: %v, raw value: %v", srcColumnMeta.Type, *srcValue)
 	switch srcColumnMeta.Type {
-	case "text", "fixed", "real", "variant", "object":
+	case "text", "fixed", "real", "variant", "object", "array":
 		*dest = *srcValue
 		return nil
 	case "date":
@@ -202,10 +200,8 @@ func stringToValue(dest *driver.Value, srcColumnMeta execResponseRowType, srcVal
 		if err != nil {
 			return err
 		}
-		tt := time.Unix(sec, nsec)
-		zone, offset := tt.Zone() // get timezone for the given datetime
-		glog.V(2).Infof("local: %v, %v", zone, offset)
-		*dest = tt.Add(time.Second * time.Duration(-offset))
+		// the location is set to the time zone of the session by the rows
+		*dest = time.Unix(sec, nsec)
 		return nil
 	case "timestamp_tz":
 		glog.V(2).Infof("tz: %v", *srcValue)
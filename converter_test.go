@@ -149,3 +149,31 @@ func TestStringToValue(t *testing.T) {
 
 	}
 }
+
+func TestValueToStringTimestamp(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	tm := time.Date(2018, 1, 2, 3, 4, 5, 6, pst)
+	testcases := []struct {
+		tsmode string
+		out    string
+	}{
+		{tsmode: "TIMESTAMP_NTZ", out: "1514891045000000006"},
+		{tsmode: "TIMESTAMP_LTZ", out: "1514891045000000006"},
+		{tsmode: "TIMESTAMP_TZ", out: "1514891045000000006 960"},
+	}
+	for _, test := range testcases {
+		s, err := valueToString(tm, test.tsmode)
+		if err != nil {
+			t.Errorf("failed to convert the time. tsmode: %v, err: %v", test.tsmode, err)
+			continue
+		}
+		if *s != test.out {
+			t.Errorf("failed to convert the time. tsmode: %v, expected: %v, got: %v", test.tsmode, test.out, *s)
+		}
+	}
+	var dest driver.Value
+	s := "1514891045.000000006"
+	if err := stringToValue(&dest, execResponseRowType{Type: "timestamp_ltz"}, &s); err != nil || !dest.(time.Time).Equal(tm) {
+		t.Errorf("failed to get the instant of TIMESTAMP_LTZ. expected: %v, got: %v, err: %v", tm, dest, err)
+	}
+}
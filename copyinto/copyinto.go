@@ -0,0 +1,236 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package copyinto provides a typed report of COPY INTO <table> statements loading staged files. Snowflake
+// returns the outcome of a load as a result set with a row per file, and the errors of a validation as a result
+// set with a row per error. The columns are read by name, so the report doesn't depend on their order.
+//
+//	report, err := copyinto.Exec(ctx, db, "COPY INTO orders FROM @orders_stage ON_ERROR = CONTINUE", nil)
+//	if err != nil {
+//		return err
+//	}
+//	for _, f := range report.Failed() {
+//		log.Printf("failed to load %v: %v at line %v", f.File, f.FirstError, f.FirstErrorLine)
+//	}
+package copyinto
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// File load statuses of COPY INTO.
+const (
+	StatusLoaded          = "LOADED"
+	StatusLoadFailed      = "LOAD_FAILED"
+	StatusPartiallyLoaded = "PARTIALLY_LOADED"
+	StatusLoadSkipped     = "LOAD_SKIPPED"
+)
+
+// ValidationMode validates the files instead of loading them.
+type ValidationMode string
+
+// Validation modes of COPY INTO. The errors are returned in Report.Errors.
+const (
+	// ValidationReturnErrors returns the errors of the files, as many as ON_ERROR allows.
+	ValidationReturnErrors ValidationMode = "RETURN_ERRORS"
+	// ValidationReturnAllErrors returns the errors of the files including those of the files loaded
+	// partially before with ON_ERROR = CONTINUE.
+	ValidationReturnAllErrors ValidationMode = "RETURN_ALL_ERRORS"
+)
+
+// Options are the options of Exec.
+type Options struct {
+	// ValidationMode, if not empty, is added to the statement to validate the files without loading them.
+	ValidationMode ValidationMode
+}
+
+// FileResult is the outcome of loading a file.
+type FileResult struct {
+	File                 string
+	Status               string
+	RowsParsed           int64
+	RowsLoaded           int64
+	ErrorLimit           int64
+	ErrorsSeen           int64
+	FirstError           string // empty unless ErrorsSeen > 0
+	FirstErrorLine       int64
+	FirstErrorCharacter  int64
+	FirstErrorColumnName string
+}
+
+// LoadError is an error found in a file by a validation.
+type LoadError struct {
+	Error          string
+	File           string
+	Line           int64
+	Character      int64
+	ByteOffset     int64
+	Category       string
+	Code           int64
+	SQLState       string
+	ColumnName     string
+	RowNumber      int64
+	RowStartLine   int64
+	RejectedRecord string
+}
+
+// Report is the outcome of a COPY INTO statement. Files is empty if no file was new to the table, and Errors
+// is set only with a ValidationMode.
+type Report struct {
+	Files  []FileResult
+	Errors []LoadError
+}
+
+// RowsLoaded returns the rows loaded from all files.
+func (r *Report) RowsLoaded() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.RowsLoaded
+	}
+	return n
+}
+
+// RowsParsed returns the rows parsed in all files.
+func (r *Report) RowsParsed() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.RowsParsed
+	}
+	return n
+}
+
+// ErrorsSeen returns the errors seen in all files.
+func (r *Report) ErrorsSeen() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.ErrorsSeen
+	}
+	return n
+}
+
+// FilesLoaded returns the number of the files loaded fully.
+func (r *Report) FilesLoaded() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Status == StatusLoaded {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the files not loaded fully.
+func (r *Report) Failed() []FileResult {
+	var ret []FileResult
+	for _, f := range r.Files {
+		if f.Status != StatusLoaded && f.Status != StatusLoadSkipped {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+var copyIntoRegexp = regexp.MustCompile(`(?i)^\s*COPY\s+INTO\s`)
+
+// Exec executes the COPY INTO <table> statement with the args, and returns the report parsed from the result.
+// opts may be nil.
+func Exec(ctx context.Context, q sf.Queryer, statement string, opts *Options, args ...interface{}) (*Report, error) {
+	if !copyIntoRegexp.MatchString(statement) {
+		return nil, fmt.Errorf("not a COPY INTO statement: %q", statement)
+	}
+	if opts != nil && opts.ValidationMode != "" {
+		statement = strings.TrimRight(strings.TrimSpace(statement), ";") + " VALIDATION_MODE = " + string(opts.ValidationMode)
+	}
+	rows, err := q.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	report := &Report{}
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, c := range cols {
+			row[strings.ToUpper(c)] = values[i].String
+		}
+		if err = report.add(row); err != nil {
+			return nil, err
+		}
+	}
+	return report, rows.Err()
+}
+
+// add adds the row of the result to the report, by the columns of the load or the validation result. The row
+// of the result of a load of no files has only the status.
+func (r *Report) add(row map[string]string) error {
+	p := &intParser{row: row}
+	if _, ok := row["REJECTED_RECORD"]; ok {
+		r.Errors = append(r.Errors, LoadError{
+			Error:          row["ERROR"],
+			File:           row["FILE"],
+			Line:           p.parse("LINE"),
+			Character:      p.parse("CHARACTER"),
+			ByteOffset:     p.parse("BYTE_OFFSET"),
+			Category:       row["CATEGORY"],
+			Code:           p.parse("CODE"),
+			SQLState:       row["SQL_STATE"],
+			ColumnName:     row["COLUMN_NAME"],
+			RowNumber:      p.parse("ROW_NUMBER"),
+			RowStartLine:   p.parse("ROW_START_LINE"),
+			RejectedRecord: row["REJECTED_RECORD"],
+		})
+		return p.err
+	}
+	if _, ok := row["FILE"]; !ok {
+		return nil
+	}
+	r.Files = append(r.Files, FileResult{
+		File:                 row["FILE"],
+		Status:               row["STATUS"],
+		RowsParsed:           p.parse("ROWS_PARSED"),
+		RowsLoaded:           p.parse("ROWS_LOADED"),
+		ErrorLimit:           p.parse("ERROR_LIMIT"),
+		ErrorsSeen:           p.parse("ERRORS_SEEN"),
+		FirstError:           row["FIRST_ERROR"],
+		FirstErrorLine:       p.parse("FIRST_ERROR_LINE"),
+		FirstErrorCharacter:  p.parse("FIRST_ERROR_CHARACTER"),
+		FirstErrorColumnName: row["FIRST_ERROR_COLUMN_NAME"],
+	})
+	return p.err
+}
+
+// intParser parses the integer columns of a row, keeping the first error.
+type intParser struct {
+	row map[string]string
+	err error
+}
+
+// parse returns the integer value of the column, or 0 if the column is missing or NULL.
+func (p *intParser) parse(col string) int64 {
+	s := p.row[col]
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("failed to parse column %v of the COPY INTO result. value: %v, err: %v", col, s, err)
+	}
+	return n
+}
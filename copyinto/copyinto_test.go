@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package copyinto
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func TestExec(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`^COPY INTO t FROM @s ON_ERROR = CONTINUE$`).WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "file", Type: "TEXT"},
+		sfmock.Column{Name: "status", Type: "TEXT"},
+		sfmock.Column{Name: "rows_parsed", Type: "FIXED"},
+		sfmock.Column{Name: "rows_loaded", Type: "FIXED"},
+		sfmock.Column{Name: "error_limit", Type: "FIXED"},
+		sfmock.Column{Name: "errors_seen", Type: "FIXED"},
+		sfmock.Column{Name: "first_error", Type: "TEXT", Nullable: true},
+		sfmock.Column{Name: "first_error_line", Type: "FIXED", Nullable: true},
+		sfmock.Column{Name: "first_error_character", Type: "FIXED", Nullable: true},
+		sfmock.Column{Name: "first_error_column_name", Type: "TEXT", Nullable: true}).
+		AddRow("s/a.csv.gz", StatusLoaded, "10", "10", "10", "0", nil, nil, nil, nil).
+		AddRow("s/b.csv.gz", StatusPartiallyLoaded, "5", "4", "5", "1",
+			"Numeric value 'x' is not recognized", "3", "7", `"T"["ID":1]`))
+	mock.ExpectQuery(`^COPY INTO t FROM @s$`).WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "status", Type: "TEXT"}).
+		AddRow("Copy executed with 0 files processed."))
+	mock.ExpectQuery(`^COPY INTO t FROM @s VALIDATION_MODE = RETURN_ERRORS$`).WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "ERROR", Type: "TEXT"},
+		sfmock.Column{Name: "FILE", Type: "TEXT"},
+		sfmock.Column{Name: "LINE", Type: "FIXED"},
+		sfmock.Column{Name: "CHARACTER", Type: "FIXED"},
+		sfmock.Column{Name: "BYTE_OFFSET", Type: "FIXED"},
+		sfmock.Column{Name: "CATEGORY", Type: "TEXT"},
+		sfmock.Column{Name: "CODE", Type: "FIXED"},
+		sfmock.Column{Name: "SQL_STATE", Type: "TEXT"},
+		sfmock.Column{Name: "COLUMN_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "ROW_NUMBER", Type: "FIXED"},
+		sfmock.Column{Name: "ROW_START_LINE", Type: "FIXED"},
+		sfmock.Column{Name: "REJECTED_RECORD", Type: "TEXT"}).
+		AddRow("Numeric value 'x' is not recognized", "s/b.csv.gz", "3", "7", "40", "conversion", "100038",
+			"22018", `"T"["ID":1]`, "2", "3", "x,b"))
+	mock.ExpectQuery(`^COPY INTO t FROM @s$`).WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "file", Type: "TEXT"},
+		sfmock.Column{Name: "rows_loaded", Type: "FIXED"}).
+		AddRow("s/a.csv.gz", "ten"))
+
+	ctx := context.Background()
+	report, err := Exec(ctx, db, "COPY INTO t FROM @s ON_ERROR = CONTINUE", nil)
+	if err != nil {
+		t.Fatalf("failed to copy. err: %v", err)
+	}
+	if len(report.Files) != 2 || report.RowsParsed() != 15 || report.RowsLoaded() != 14 || report.ErrorsSeen() != 1 ||
+		report.FilesLoaded() != 1 {
+		t.Errorf("unexpected report: %v", report)
+	}
+	if f := report.Failed(); len(f) != 1 || f[0].File != "s/b.csv.gz" || f[0].FirstErrorLine != 3 ||
+		f[0].FirstErrorCharacter != 7 || f[0].FirstErrorColumnName != `"T"["ID":1]` {
+		t.Errorf("unexpected failed files: %v", f)
+	}
+	if f := report.Files[0]; f.FirstError != "" || f.FirstErrorLine != 0 {
+		t.Errorf("unexpected loaded file: %v", f)
+	}
+
+	report, err = Exec(ctx, db, "COPY INTO t FROM @s", nil)
+	if err != nil {
+		t.Fatalf("failed to copy. err: %v", err)
+	}
+	if len(report.Files) != 0 || len(report.Errors) != 0 {
+		t.Errorf("should have loaded no files. report: %v", report)
+	}
+
+	report, err = Exec(ctx, db, "COPY INTO t FROM @s;", &Options{ValidationMode: ValidationReturnErrors})
+	if err != nil {
+		t.Fatalf("failed to validate. err: %v", err)
+	}
+	if len(report.Files) != 0 || len(report.Errors) != 1 {
+		t.Fatalf("unexpected validation report: %v", report)
+	}
+	if e := report.Errors[0]; e.File != "s/b.csv.gz" || e.Code != 100038 || e.ByteOffset != 40 || e.RowNumber != 2 ||
+		e.RejectedRecord != "x,b" {
+		t.Errorf("unexpected load error: %v", e)
+	}
+
+	if _, err = Exec(ctx, db, "COPY INTO t FROM @s", nil); err == nil {
+		t.Error("should have failed to parse the rows loaded")
+	}
+	if _, err = Exec(ctx, db, "SELECT 1", nil); err == nil {
+		t.Error("should have failed to execute a statement other than COPY INTO")
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials are the authentication parameters resolved by a CredentialProvider.
+type Credentials struct {
+	Authenticator string // snowflake, oauth or workload_identity. snowflake if empty.
+	User          string
+	Password      string
+	Token         string // OAuth access token or workload identity attestation
+	Provider      string // workload identity provider: AWS, GCP or AZURE
+}
+
+// CredentialProvider resolves the credentials to login with. Retrieve returns nil without an error if the
+// provider is not available in the environment, so that the next provider in the chain is tried.
+type CredentialProvider interface {
+	Name() string
+	Retrieve(ctx context.Context, cfg *Config) (*Credentials, error)
+}
+
+// CredentialInvalidator is implemented by the CredentialProviders that cache the credentials. If the login with
+// the credentials is rejected, e.g., because the secret was rotated, InvalidateCredentials is called and the
+// login is retried once with the credentials retrieved again.
+type CredentialInvalidator interface {
+	InvalidateCredentials()
+}
+
+// rejectedCredentialsCodes are the login error numbers for the invalid password, key pair JWT and OAuth token.
+var rejectedCredentialsCodes = map[int]bool{
+	390100: true,
+	390144: true,
+	390303: true,
+	390318: true,
+}
+
+// isRejectedCredentials returns true if the login failed because of the credentials.
+func isRejectedCredentials(err error) bool {
+	se, ok := err.(*SnowflakeError)
+	return ok && (rejectedCredentialsCodes[se.Number] || se.Number == ErrCodeFailedToConnect)
+}
+
+// TokenProvider returns an OAuth access token. It is called at every login of the OAuth authenticator, and
+// again if the token is rejected, e.g., it expired, so that the tokens rotated by the identity provider are
+// used by the new connections without reopening sql.DB.
+type TokenProvider func(ctx context.Context) (string, error)
+
+// provideToken sets Config.Token to the token by Config.TokenProvider if it is set.
+func provideToken(ctx context.Context, cfg *Config) error {
+	if cfg.TokenProvider == nil {
+		return nil
+	}
+	token, err := cfg.TokenProvider(ctx)
+	if err != nil {
+		return &SnowflakeError{
+			Number:      ErrCodeFailedToGetCredentials,
+			Message:     errMsgFailedToGetCredentials,
+			MessageArgs: []interface{}{"token provider", err},
+			Err:         err,
+		}
+	}
+	cfg.Token = token
+	return nil
+}
+
+// DefaultCredentialProviders returns the chain of providers used for AUTHENTICATOR=AUTO if
+// Config.CredentialProviders is not set: the Snowpark Container Services token file, the AWS, GCP and Azure
+// workload identities, the environment variables and the credentials file. Only the environment variables are
+// used in WebAssembly.
+func DefaultCredentialProviders() []CredentialProvider {
+	if !hasFileSystem {
+		return []CredentialProvider{&EnvCredentialProvider{}}
+	}
+	return []CredentialProvider{
+		&SPCSTokenProvider{},
+		&AWSWorkloadIdentityProvider{},
+		&GCPWorkloadIdentityProvider{},
+		&AzureWorkloadIdentityProvider{},
+		&EnvCredentialProvider{},
+		&FileCredentialProvider{},
+	}
+}
+
+// resolveCredentials runs the credential provider chain and applies the first credentials found to cfg.
+func resolveCredentials(ctx context.Context, cfg *Config) error {
+	providers := cfg.CredentialProviders
+	if len(providers) == 0 {
+		providers = DefaultCredentialProviders()
+	}
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+		creds, err := p.Retrieve(ctx, cfg)
+		if err != nil {
+			return &SnowflakeError{
+				Number:      ErrCodeFailedToGetCredentials,
+				Message:     errMsgFailedToGetCredentials,
+				MessageArgs: []interface{}{p.Name(), err},
+				Err:         err,
+			}
+		}
+		if creds == nil {
+			continue
+		}
+		if creds.Authenticator != "" {
+			if err = checkAuthenticator(creds.Authenticator); err != nil {
+				return err
+			}
+		}
+		glog.V(2).Infof("credentials are provided by %v", p.Name())
+		applyCredentials(cfg, creds)
+		cfg.credentialProvider = p
+		return nil
+	}
+	return &SnowflakeError{
+		Number:      ErrCodeNoCredentials,
+		Message:     errMsgNoCredentials,
+		MessageArgs: []interface{}{strings.Join(names, ", ")},
+	}
+}
+
+func applyCredentials(cfg *Config, creds *Credentials) {
+	cfg.Authenticator = strings.ToLower(creds.Authenticator)
+	if cfg.Authenticator == "" {
+		cfg.Authenticator = defaultAuthenticator
+	}
+	if creds.User != "" {
+		cfg.User = creds.User
+	}
+	if creds.Password != "" {
+		cfg.Password = creds.Password
+	}
+	if creds.Token != "" {
+		cfg.Token = creds.Token
+	}
+	cfg.workloadIdentityProvider = creds.Provider
+}
+
+// defaultSPCSTokenPath is the OAuth token file mounted into Snowpark Container Services containers.
+const defaultSPCSTokenPath = "/snowflake/session/token"
+
+// SPCSTokenProvider provides the OAuth token of a Snowpark Container Services container.
+type SPCSTokenProvider struct {
+	Path string // defaultSPCSTokenPath if empty
+}
+
+// Name returns the provider name.
+func (p *SPCSTokenProvider) Name() string {
+	return "spcs"
+}
+
+// Retrieve reads the token file if it exists.
+func (p *SPCSTokenProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	path := p.Path
+	if path == "" {
+		path = defaultSPCSTokenPath
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Authenticator: authenticatorOAuth, Token: strings.TrimSpace(string(b))}, nil
+}
+
+// EnvCredentialProvider provides the credentials from the environment variables SNOWFLAKE_USER and
+// SNOWFLAKE_PASSWORD, or SNOWFLAKE_TOKEN for OAuth.
+type EnvCredentialProvider struct{}
+
+// Name returns the provider name.
+func (p *EnvCredentialProvider) Name() string {
+	return "env"
+}
+
+// Retrieve reads the environment variables.
+func (p *EnvCredentialProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	user := os.Getenv("SNOWFLAKE_USER")
+	if token := os.Getenv("SNOWFLAKE_TOKEN"); token != "" {
+		return &Credentials{Authenticator: authenticatorOAuth, User: user, Token: token}, nil
+	}
+	password := os.Getenv("SNOWFLAKE_PASSWORD")
+	if user == "" || password == "" {
+		return nil, nil
+	}
+	return &Credentials{Authenticator: authenticatorSnowflake, User: user, Password: password}, nil
+}
+
+// FileCredentialProvider provides the credentials from a JSON file with the keys authenticator, user,
+// password and token.
+type FileCredentialProvider struct {
+	Path string // SNOWFLAKE_CREDENTIALS_FILE or $HOME/.snowflake/credentials.json if empty
+}
+
+// Name returns the provider name.
+func (p *FileCredentialProvider) Name() string {
+	return "file"
+}
+
+// Retrieve reads the credentials file if it exists.
+func (p *FileCredentialProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	path := p.Path
+	if path == "" {
+		path = os.Getenv("SNOWFLAKE_CREDENTIALS_FILE")
+	}
+	if path == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			home = os.Getenv("USERPROFILE")
+		}
+		if home == "" {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".snowflake", "credentials.json")
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Authenticator string `json:"authenticator"`
+		User          string `json:"user"`
+		Password      string `json:"password"`
+		Token         string `json:"token"`
+	}
+	if err = json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %v. err: %v", path, err)
+	}
+	return &Credentials{Authenticator: f.Authenticator, User: f.User, Password: f.Password, Token: f.Token}, nil
+}
@@ -0,0 +1,378 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tcCredentialProvider struct {
+	name  string
+	creds *Credentials
+	err   error
+	calls int
+}
+
+func (p *tcCredentialProvider) Name() string {
+	return p.name
+}
+
+func (p *tcCredentialProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	p.calls++
+	return p.creds, p.err
+}
+
+func TestResolveCredentialsChain(t *testing.T) {
+	first := &tcCredentialProvider{name: "first"}
+	second := &tcCredentialProvider{name: "second", creds: &Credentials{User: "u", Password: "p"}}
+	third := &tcCredentialProvider{name: "third", creds: &Credentials{Authenticator: "oauth", Token: "t"}}
+	cfg := &Config{Authenticator: "auto", CredentialProviders: []CredentialProvider{first, second, third}}
+	if err := resolveCredentials(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to resolve credentials. err: %v", err)
+	}
+	if first.calls != 1 || second.calls != 1 || third.calls != 0 {
+		t.Errorf("failed to stop at the first credentials. calls: %v, %v, %v", first.calls, second.calls, third.calls)
+	}
+	if cfg.Authenticator != defaultAuthenticator || cfg.User != "u" || cfg.Password != "p" {
+		t.Errorf("failed to apply credentials. cfg: %v, %v, %v", cfg.Authenticator, cfg.User, cfg.Password)
+	}
+	if cfg.credentialProvider != second {
+		t.Errorf("failed to record the provider. got: %v", cfg.credentialProvider)
+	}
+}
+
+func TestIsRejectedCredentials(t *testing.T) {
+	testcases := []struct {
+		err      error
+		rejected bool
+	}{
+		{err: &SnowflakeError{Number: 390100}, rejected: true},
+		{err: &SnowflakeError{Number: 390318}, rejected: true},
+		{err: &SnowflakeError{Number: ErrCodeFailedToConnect}, rejected: true},
+		{err: &SnowflakeError{Number: ErrCodeServiceUnavailable}, rejected: false},
+		{err: fmt.Errorf("boom"), rejected: false},
+	}
+	for _, test := range testcases {
+		if isRejectedCredentials(test.err) != test.rejected {
+			t.Errorf("failed to classify the error. err: %v, expected: %v", test.err, test.rejected)
+		}
+	}
+}
+
+func TestResolveCredentialsErrors(t *testing.T) {
+	cfg := &Config{CredentialProviders: []CredentialProvider{
+		&tcCredentialProvider{name: "a"}, &tcCredentialProvider{name: "b"}}}
+	err := resolveCredentials(context.Background(), cfg)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodeNoCredentials {
+		t.Fatalf("should have failed with no credentials. err: %v", err)
+	}
+	if !strings.Contains(err.Error(), "a, b") {
+		t.Errorf("failed to list the providers. err: %v", err)
+	}
+	cfg = &Config{CredentialProviders: []CredentialProvider{
+		&tcCredentialProvider{name: "a", err: fmt.Errorf("boom")},
+		&tcCredentialProvider{name: "b", creds: &Credentials{User: "u", Password: "p"}}}}
+	err = resolveCredentials(context.Background(), cfg)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodeFailedToGetCredentials {
+		t.Fatalf("should have failed to get credentials. err: %v", err)
+	}
+	cfg = &Config{CredentialProviders: []CredentialProvider{
+		&tcCredentialProvider{name: "a", creds: &Credentials{Authenticator: "oauht", Token: "t"}}}}
+	err = resolveCredentials(context.Background(), cfg)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodeUnknownAuthenticator {
+		t.Fatalf("should have failed with the unknown authenticator. err: %v", err)
+	}
+}
+
+func TestEnvCredentialProvider(t *testing.T) {
+	for _, k := range []string{"SNOWFLAKE_USER", "SNOWFLAKE_PASSWORD", "SNOWFLAKE_TOKEN"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+	p := &EnvCredentialProvider{}
+	creds, err := p.Retrieve(context.Background(), &Config{})
+	if err != nil || creds != nil {
+		t.Fatalf("should not have provided credentials. creds: %v, err: %v", creds, err)
+	}
+	os.Setenv("SNOWFLAKE_USER", "u")
+	os.Setenv("SNOWFLAKE_PASSWORD", "p")
+	creds, err = p.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil || creds.User != "u" || creds.Password != "p" {
+		t.Fatalf("failed to get the user and password. creds: %v, err: %v", creds, err)
+	}
+	os.Setenv("SNOWFLAKE_TOKEN", "t")
+	creds, err = p.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil || creds.Authenticator != authenticatorOAuth || creds.Token != "t" {
+		t.Fatalf("failed to get the token. creds: %v, err: %v", creds, err)
+	}
+}
+
+func TestResolveCredentialsAWSWithoutRegion(t *testing.T) {
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"SNOWFLAKE_USER", "SNOWFLAKE_PASSWORD", "SNOWFLAKE_TOKEN"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	os.Setenv("SNOWFLAKE_USER", "u")
+	os.Setenv("SNOWFLAKE_PASSWORD", "p")
+	cfg := &Config{Authenticator: "auto", CredentialProviders: []CredentialProvider{
+		&AWSWorkloadIdentityProvider{}, &EnvCredentialProvider{}}}
+	if err := resolveCredentials(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to resolve credentials. err: %v", err)
+	}
+	if cfg.Authenticator != defaultAuthenticator || cfg.User != "u" || cfg.Password != "p" {
+		t.Errorf("failed to fall back to the environment variables. cfg: %v, %v, %v", cfg.Authenticator, cfg.User, cfg.Password)
+	}
+}
+
+func TestFileCredentialProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenPath := filepath.Join(dir, "token")
+	spcs := &SPCSTokenProvider{Path: tokenPath}
+	if creds, err := spcs.Retrieve(context.Background(), &Config{}); err != nil || creds != nil {
+		t.Fatalf("should not have provided credentials. creds: %v, err: %v", creds, err)
+	}
+	if err = ioutil.WriteFile(tokenPath, []byte("spcs-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := spcs.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil || creds.Token != "spcs-token" || creds.Authenticator != authenticatorOAuth {
+		t.Fatalf("failed to read the token file. creds: %v, err: %v", creds, err)
+	}
+
+	credsPath := filepath.Join(dir, "credentials.json")
+	file := &FileCredentialProvider{Path: credsPath}
+	if creds, err = file.Retrieve(context.Background(), &Config{}); err != nil || creds != nil {
+		t.Fatalf("should not have provided credentials. creds: %v, err: %v", creds, err)
+	}
+	if err = ioutil.WriteFile(credsPath, []byte(`{"user":"u","password":"p"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err = file.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil || creds.User != "u" || creds.Password != "p" {
+		t.Fatalf("failed to read the credentials file. creds: %v, err: %v", creds, err)
+	}
+	if err = ioutil.WriteFile(credsPath, []byte(`{`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Retrieve(context.Background(), &Config{}); err == nil {
+		t.Errorf("should have failed to parse the credentials file")
+	}
+}
+
+func TestAWSAttestation(t *testing.T) {
+	now := time.Date(2018, 5, 1, 10, 20, 30, 0, time.UTC)
+	token := awsAttestation("AKID", "secret", "session", "us-west-2", now)
+	b, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("failed to decode the attestation. err: %v", err)
+	}
+	var a struct {
+		URL     string            `json:"url"`
+		Method  string            `json:"method"`
+		Headers map[string]string `json:"headers"`
+	}
+	if err = json.Unmarshal(b, &a); err != nil {
+		t.Fatalf("failed to parse the attestation. err: %v", err)
+	}
+	if a.URL != "https://sts.us-west-2.amazonaws.com/?Action=GetCallerIdentity&Version=2011-06-15" || a.Method != "POST" {
+		t.Errorf("failed to build the request. url: %v, method: %v", a.URL, a.Method)
+	}
+	if a.Headers["X-Amz-Date"] != "20180501T102030Z" || a.Headers["X-Snowflake-Audience"] != workloadIdentityAudience ||
+		a.Headers["X-Amz-Security-Token"] != "session" {
+		t.Errorf("failed to set the headers. headers: %v", a.Headers)
+	}
+	auth := a.Headers["Authorization"]
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20180501/us-west-2/sts/aws4_request, "+
+		"SignedHeaders=host;x-amz-date;x-amz-security-token;x-snowflake-audience, Signature=") {
+		t.Errorf("failed to sign the request. authorization: %v", auth)
+	}
+	if token != awsAttestation("AKID", "secret", "session", "us-west-2", now) {
+		t.Errorf("failed to sign deterministically")
+	}
+}
+
+func TestMetadataWorkloadIdentityProviders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Metadata-Flavor") == "Google":
+			if r.URL.Query().Get("audience") != workloadIdentityAudience {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "gcp-token")
+		case r.Header.Get("Metadata") == "true":
+			if r.URL.Query().Get("resource") != "api://res" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"azure-token"}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	gcp := &GCPWorkloadIdentityProvider{MetadataHost: strings.TrimPrefix(ts.URL, "http://")}
+	creds, err := gcp.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil || creds.Token != "gcp-token" || creds.Provider != "GCP" {
+		t.Fatalf("failed to get the GCP token. creds: %v, err: %v", creds, err)
+	}
+	azure := &AzureWorkloadIdentityProvider{Endpoint: ts.URL, Resource: "api://res"}
+	creds, err = azure.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil || creds.Token != "azure-token" || creds.Provider != "AZURE" {
+		t.Fatalf("failed to get the Azure token. creds: %v, err: %v", creds, err)
+	}
+	azure.Resource = "api://other"
+	if _, err = azure.Retrieve(context.Background(), &Config{}); err == nil {
+		t.Errorf("should have failed to get the Azure token")
+	}
+}
+
+// awsAttestationCredential returns the credential of the Authorization header of the AWS attestation.
+func awsAttestationCredential(t *testing.T, token string) string {
+	b, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("failed to decode the attestation. err: %v", err)
+	}
+	var a struct {
+		Headers map[string]string `json:"headers"`
+	}
+	if err = json.Unmarshal(b, &a); err != nil {
+		t.Fatalf("failed to parse the attestation. err: %v", err)
+	}
+	auth := strings.TrimPrefix(a.Headers["Authorization"], "AWS4-HMAC-SHA256 Credential=")
+	return auth[:strings.Index(auth, ",")]
+}
+
+func TestAWSWorkloadIdentitySources(t *testing.T) {
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_REGION",
+		"AWS_DEFAULT_REGION", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+		"AWS_EC2_METADATA_DISABLED", "AWS_EC2_METADATA_SERVICE_ENDPOINT"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const imdsToken = "imds-token"
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/latest/api/token":
+			if r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, imdsToken)
+		case strings.HasPrefix(r.URL.Path, "/latest/meta-data/"):
+			if r.Header.Get("X-aws-ec2-metadata-token") != imdsToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch strings.TrimPrefix(r.URL.Path, "/latest/meta-data/") {
+			case "placement/region":
+				fmt.Fprint(w, "eu-west-1")
+			case "iam/security-credentials/":
+				fmt.Fprint(w, "instance-role")
+			case "iam/security-credentials/instance-role":
+				fmt.Fprint(w, `{"Code":"Success","AccessKeyId":"EC2KEY","SecretAccessKey":"s","Token":"t"}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.URL.Path == "/ecs":
+			if r.Header.Get("Authorization") != "ecs-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"AccessKeyId":"ECSKEY","SecretAccessKey":"s","Token":"t"}`)
+		case r.Method == "POST" && r.URL.Path == "/":
+			r.ParseForm()
+			if r.PostForm.Get("Action") != "AssumeRoleWithWebIdentity" || r.PostForm.Get("WebIdentityToken") != "web-token" ||
+				r.PostForm.Get("RoleArn") != "arn:aws:iam::123456789012:role/pod" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<AssumeRoleWithWebIdentityResult><Credentials><SessionToken>t</SessionToken><SecretAccessKey>s</SecretAccessKey>
+<AccessKeyId>WEBKEY</AccessKeyId></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	p := &AWSWorkloadIdentityProvider{MetadataEndpoint: ts.URL, STSEndpoint: ts.URL}
+	now := getClock().Now().UTC().Format("20060102")
+
+	// the instance profile of EC2 in the region of the instance
+	creds, err := p.Retrieve(context.Background(), &Config{})
+	if err != nil || creds == nil {
+		t.Fatalf("failed to get the EC2 credentials. creds: %v, err: %v", creds, err)
+	}
+	if c := awsAttestationCredential(t, creds.Token); c != "EC2KEY/"+now+"/eu-west-1/sts/aws4_request" {
+		t.Errorf("failed to sign by the EC2 credentials. credential: %v", c)
+	}
+
+	// the container credentials endpoint
+	os.Setenv("AWS_REGION", "us-west-2")
+	os.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", ts.URL+"/ecs")
+	os.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "ecs-token")
+	if creds, err = p.Retrieve(context.Background(), &Config{}); err != nil || creds == nil {
+		t.Fatalf("failed to get the container credentials. creds: %v, err: %v", creds, err)
+	}
+	if c := awsAttestationCredential(t, creds.Token); c != "ECSKEY/"+now+"/us-west-2/sts/aws4_request" {
+		t.Errorf("failed to sign by the container credentials. credential: %v", c)
+	}
+
+	// the web identity token file
+	dir, err := ioutil.TempDir("", "aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err = ioutil.WriteFile(tokenFile, []byte("web-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	os.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/pod")
+	if creds, err = p.Retrieve(context.Background(), &Config{}); err != nil || creds == nil {
+		t.Fatalf("failed to get the web identity credentials. creds: %v, err: %v", creds, err)
+	}
+	if c := awsAttestationCredential(t, creds.Token); c != "WEBKEY/"+now+"/us-west-2/sts/aws4_request" {
+		t.Errorf("failed to sign by the web identity credentials. credential: %v", c)
+	}
+
+	// the rejected token fails the provider
+	if err = ioutil.WriteFile(tokenFile, []byte("other-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if creds, err = p.Retrieve(context.Background(), &Config{}); err == nil {
+		t.Errorf("should have failed to exchange the web identity token. creds: %v", creds)
+	}
+
+	// no source without the instance metadata service
+	for _, k := range []string{"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CONTAINER_CREDENTIALS_FULL_URI"} {
+		os.Unsetenv(k)
+	}
+	os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	if creds, err = (&AWSWorkloadIdentityProvider{}).Retrieve(context.Background(), &Config{}); err != nil || creds != nil {
+		t.Errorf("should not have provided credentials. creds: %v, err: %v", creds, err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CredentialStore stores the tokens returned at login to reuse them at the later logins, e.g., the MFA token
+// requested by Config.ClientRequestMFAToken. The keys identify the host, user and token type.
+type CredentialStore interface {
+	GetCredential(key string) (string, error) // returns an empty string if the key is not stored
+	SetCredential(key string, value string) error
+	DeleteCredential(key string) error
+}
+
+// mfaTokenType is the token type of the MFA tokens in the credential store.
+const mfaTokenType = "MFA_TOKEN"
+
+// credentialCacheFileName is the file of FileCredentialStore in the cache directory.
+const credentialCacheFileName = "credential_cache.json"
+
+// credentialKey returns the key of the token of the user in the credential store.
+func credentialKey(host string, user string, tokenType string) string {
+	return strings.ToUpper(host + ":" + user + ":" + tokenType)
+}
+
+// FileCredentialStore is the default CredentialStore. It keeps the tokens in a JSON file readable only by the
+// owner, shared by the processes of the user.
+type FileCredentialStore struct {
+	// Path is the file of the tokens. If empty, credential_cache.json in SF_TEMPORARY_CREDENTIAL_CACHE_DIR or
+	// $HOME/.cache/snowflake.
+	Path string
+
+	mu sync.Mutex
+}
+
+var defaultCredentialStore = &FileCredentialStore{}
+
+// getCredentialStore returns Config.CredentialStore or the default FileCredentialStore. It returns nil if there
+// is no file system.
+func getCredentialStore(cfg *Config) CredentialStore {
+	if cfg.CredentialStore != nil {
+		return cfg.CredentialStore
+	}
+	if !hasFileSystem {
+		return nil
+	}
+	return defaultCredentialStore
+}
+
+func (s *FileCredentialStore) path() (string, error) {
+	if s.Path != "" {
+		return s.Path, nil
+	}
+	dir := os.Getenv("SF_TEMPORARY_CREDENTIAL_CACHE_DIR")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			home = os.Getenv("USERPROFILE")
+		}
+		if home == "" {
+			return "", fmt.Errorf("no home directory for the credential cache")
+		}
+		dir = filepath.Join(home, ".cache", "snowflake")
+	}
+	return filepath.Join(dir, credentialCacheFileName), nil
+}
+
+func (s *FileCredentialStore) read(path string) (map[string]string, error) {
+	creds := make(map[string]string)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse %v. err: %v", path, err)
+	}
+	return creds, nil
+}
+
+func (s *FileCredentialStore) write(path string, creds map[string]string) error {
+	b, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// replaces the file at once, so that the other processes don't read a partial file
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// GetCredential returns the token of the key.
+func (s *FileCredentialStore) GetCredential(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.path()
+	if err != nil {
+		return "", err
+	}
+	creds, err := s.read(path)
+	if err != nil {
+		return "", err
+	}
+	return creds[key], nil
+}
+
+// SetCredential stores the token of the key.
+func (s *FileCredentialStore) SetCredential(key string, value string) error {
+	return s.update(func(creds map[string]string) {
+		creds[key] = value
+	})
+}
+
+// DeleteCredential removes the token of the key.
+func (s *FileCredentialStore) DeleteCredential(key string) error {
+	return s.update(func(creds map[string]string) {
+		delete(creds, key)
+	})
+}
+
+func (s *FileCredentialStore) update(f func(creds map[string]string)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	creds, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	f(creds)
+	return s.write(path, creds)
+}
+
+// getCachedMFAToken returns the MFA token of the user in the credential store, or an empty string.
+func getCachedMFAToken(cfg *Config) string {
+	store := getCredentialStore(cfg)
+	if store == nil {
+		return ""
+	}
+	token, err := store.GetCredential(credentialKey(cfg.Host, cfg.User, mfaTokenType))
+	if err != nil {
+		glog.V(1).Infof("failed to get the MFA token from the credential store. err: %v", err)
+		return ""
+	}
+	return token
+}
+
+// setCachedMFAToken stores the MFA token of the user, or removes it if the token is empty. The cache is best
+// effort, so the errors are only logged.
+func setCachedMFAToken(cfg *Config, token string) {
+	store := getCredentialStore(cfg)
+	if store == nil {
+		return
+	}
+	key := credentialKey(cfg.Host, cfg.User, mfaTokenType)
+	var err error
+	if token == "" {
+		err = store.DeleteCredential(key)
+	} else {
+		err = store.SetCredential(key, token)
+	}
+	if err != nil {
+		glog.V(1).Infof("failed to update the MFA token in the credential store. err: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credstore")
+	if err != nil {
+		t.Fatalf("failed to create a temp dir. err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache", credentialCacheFileName)
+	store := &FileCredentialStore{Path: path}
+
+	key := credentialKey("a.snowflakecomputing.com", "u", mfaTokenType)
+	if v, err := store.GetCredential(key); err != nil || v != "" {
+		t.Fatalf("failed to get the missing credential. value: %v, err: %v", v, err)
+	}
+	if err = store.SetCredential(key, "mfa"); err != nil {
+		t.Fatalf("failed to set the credential. err: %v", err)
+	}
+	if err = store.SetCredential("other", "x"); err != nil {
+		t.Fatalf("failed to set the credential. err: %v", err)
+	}
+	if v, err := (&FileCredentialStore{Path: path}).GetCredential(key); err != nil || v != "mfa" {
+		t.Errorf("failed to get the credential. value: %v, err: %v", v, err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("failed to restrict the permission. stat: %v, err: %v", fi, err)
+	}
+	if err = store.DeleteCredential(key); err != nil {
+		t.Fatalf("failed to delete the credential. err: %v", err)
+	}
+	if v, err := store.GetCredential(key); err != nil || v != "" {
+		t.Errorf("failed to delete the credential. value: %v, err: %v", v, err)
+	}
+	if v, err := store.GetCredential("other"); err != nil || v != "x" {
+		t.Errorf("failed to keep the other credential. value: %v, err: %v", v, err)
+	}
+}
+
+type mapCredentialStore map[string]string
+
+func (s mapCredentialStore) GetCredential(key string) (string, error) {
+	return s[key], nil
+}
+
+func (s mapCredentialStore) SetCredential(key string, value string) error {
+	s[key] = value
+	return nil
+}
+
+func (s mapCredentialStore) DeleteCredential(key string) error {
+	delete(s, key)
+	return nil
+}
+
+func TestUnitAuthenticateMFAToken(t *testing.T) {
+	var sent []authRequestData
+	success := true
+	sc := getDefaultSnowflakeConn()
+	sc.cfg.Host = "a.snowflakecomputing.com"
+	sc.cfg.ClientRequestMFAToken = true
+	store := mapCredentialStore{}
+	sc.cfg.CredentialStore = store
+	sc.rest.FuncPostAuth = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, jsonBody []byte, _ time.Duration) (*authResponse, error) {
+		var ar authRequest
+		if err := json.Unmarshal(jsonBody, &ar); err != nil {
+			return nil, err
+		}
+		sent = append(sent, ar.Data)
+		if !success {
+			return &authResponse{Success: false, Code: "390100", Message: "Incorrect username or password was specified."}, nil
+		}
+		return &authResponse{
+			Success: true,
+			Data:    authResponseMain{Token: "t", MasterToken: "m", MfaToken: "mfa"},
+		}, nil
+	}
+	key := credentialKey(sc.cfg.Host, sc.cfg.User, mfaTokenType)
+
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	success = false
+	if _, err := authenticate(context.Background(), sc, nil, nil); err == nil {
+		t.Fatal("should have failed to authenticate")
+	}
+
+	testcases := []string{"", "mfa", "mfa"}
+	for i, token := range testcases {
+		if sent[i].Authenticator != authenticatorUsernamePasswordMFA || sent[i].SessionParameters[clientRequestMFAToken] != "true" {
+			t.Errorf("%d: failed to request the MFA token. authenticator: %v, params: %v", i, sent[i].Authenticator, sent[i].SessionParameters)
+		}
+		if sent[i].Token != token {
+			t.Errorf("%d: failed to send the cached MFA token. expected: %v, got: %v", i, token, sent[i].Token)
+		}
+	}
+	if _, ok := store[key]; ok {
+		t.Errorf("failed to remove the MFA token after the login failure")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"fmt"
+	"time"
 )
 
 const (
@@ -53,6 +54,31 @@ var (
 	DataTypeBoolean = []byte{booleanType}
 )
 
+// typedTime is a time bound as the timestamp type regardless of the DataType markers of the preceding
+// parameters.
+type typedTime struct {
+	t      time.Time
+	tsmode string
+}
+
+// TimestampNtz returns the value binding t as TIMESTAMP_NTZ, the wall clock of t in UTC:
+//
+//	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?)", sf.TimestampNtz(t), sf.TimestampLtz(t), sf.TimestampTz(t))
+func TimestampNtz(t time.Time) interface{} {
+	return typedTime{t: t, tsmode: "TIMESTAMP_NTZ"}
+}
+
+// TimestampLtz returns the value binding t as TIMESTAMP_LTZ, the instant of t, which is shown in the time zone
+// of the session.
+func TimestampLtz(t time.Time) interface{} {
+	return typedTime{t: t, tsmode: "TIMESTAMP_LTZ"}
+}
+
+// TimestampTz returns the value binding t as TIMESTAMP_TZ, the instant of t with the offset of its location.
+func TimestampTz(t time.Time) interface{} {
+	return typedTime{t: t, tsmode: "TIMESTAMP_TZ"}
+}
+
 // dataTypeMode returns the subsequent data type in a string representation.
 func dataTypeMode(v driver.Value) (tsmode string, err error) {
 	if bd, ok := v.([]byte); ok {
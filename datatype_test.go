@@ -3,9 +3,14 @@
 package gosnowflake
 
 import (
+	"context"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"reflect"
 	"testing"
+	"time"
 )
 
 type tcDataTypeMode struct {
@@ -45,3 +50,44 @@ func TestDataTypeMode(t *testing.T) {
 		}
 	}
 }
+
+func TestTypedTimeBind(t *testing.T) {
+	var sent execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		if err := json.Unmarshal(body, &sent); err != nil {
+			return nil, err
+		}
+		return &execResponse{Success: true}, nil
+	}
+	tm := time.Date(2018, 1, 2, 3, 4, 5, 0, time.FixedZone("PST", -8*3600))
+	args := []driver.NamedValue{
+		{Ordinal: 1, Value: DataTypeDate},
+		{Ordinal: 2, Value: TimestampTz(tm)},
+		{Ordinal: 3, Value: TimestampLtz(tm)},
+		{Ordinal: 4, Value: TimestampNtz(tm)},
+		{Ordinal: 5, Value: tm},
+	}
+	for _, nv := range args[1:4] {
+		if err := sc.CheckNamedValue(&nv); err != nil {
+			t.Errorf("failed to accept the typed time. err: %v", err)
+		}
+	}
+	if _, err := sc.ExecContext(context.Background(), "INSERT INTO t VALUES (?, ?, ?, ?)", args); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	expected := map[string]execBindParameter{
+		"1": {Type: "TIMESTAMP_TZ", Value: "1514891045000000000 960"},
+		"2": {Type: "TIMESTAMP_LTZ", Value: "1514891045000000000"},
+		"3": {Type: "TIMESTAMP_NTZ", Value: "1514891045000000000"},
+		"4": {Type: "DATE", Value: "1514862245000"}, // the wall clock in milliseconds
+	}
+	if !reflect.DeepEqual(sent.Bindings, expected) {
+		t.Errorf("failed to bind the typed times. expected: %v, got: %v", expected, sent.Bindings)
+	}
+
+	b, err := Array([]interface{}{TimestampTz(tm), nil}).(*bindArray).bind("TIMESTAMP_NTZ")
+	if err != nil || b.typ != "TIMESTAMP_TZ" || b.tsmode != "TIMESTAMP_TZ" {
+		t.Errorf("failed to bind the array of the typed times. got: %v, err: %v", b, err)
+	}
+}
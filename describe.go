@@ -0,0 +1,27 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import "context"
+
+// describeOnlyKey makes the query describe the result and binds without running the statement.
+const describeOnlyKey contextKey = "describeOnly"
+
+// WithDescribeOnly returns a context whose statements are compiled and described by Snowflake without running
+// them in a warehouse, e.g., for schema discovery tools and query linters. A query returns the rows with the
+// columns of its result set but no rows, so Rows.Columns and Rows.ColumnTypes return the names, types and
+// nullability, and Exec returns driver.ResultNoRows. PUT and GET don't transfer the files.
+//
+//	rows, err := db.QueryContext(sf.WithDescribeOnly(ctx), "SELECT * FROM orders")
+//	if err != nil {
+//		return err // e.g., the syntax error or the missing object
+//	}
+//	defer rows.Close()
+//	columns, err := rows.ColumnTypes()
+func WithDescribeOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, describeOnlyKey, true)
+}
+
+func isDescribeOnly(ctx context.Context) bool {
+	return ctx.Value(describeOnlyKey) != nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWithDescribeOnly(t *testing.T) {
+	var requests []execRequest
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+		resp := &execResponse{Success: true, Data: execResponseData{
+			QueryID: "01a2b3c4-0000-0000-0000-000000000001",
+			RowType: []execResponseRowType{
+				{Name: "ID", Type: "fixed", Precision: 38},
+				{Name: "NAME", Type: "text", Length: 16, Nullable: true},
+			},
+		}}
+		if req.SQLText == "PUT file:///tmp/a.csv @~" {
+			resp.Data.RowType = nil
+			resp.Data.Command = "UPLOAD"
+		}
+		if req.SQLText == "INSERT INTO t VALUES (1)" {
+			resp.Data.StatementTypeID = statementTypeIDInsert
+		}
+		return resp, nil
+	}
+	sc := &snowflakeConn{
+		cfg:  &Config{ResultCacheTTL: time.Minute},
+		rest: &snowflakeRestful{FuncPostQuery: postQuery},
+	}
+	ctx := WithDescribeOnly(context.Background())
+
+	for i := 0; i < 2; i++ {
+		rows, err := sc.QueryContext(ctx, "SELECT id, name FROM t", nil)
+		if err != nil {
+			t.Fatalf("failed to describe. err: %v", err)
+		}
+		r := rows.(*snowflakeRows)
+		if cols := r.Columns(); len(cols) != 2 || cols[0] != "ID" || cols[1] != "NAME" {
+			t.Errorf("unexpected columns: %v", cols)
+		}
+		if r.ColumnTypeDatabaseTypeName(0) != "FIXED" {
+			t.Errorf("unexpected type: %v", r.ColumnTypeDatabaseTypeName(0))
+		}
+		if nullable, ok := r.ColumnTypeNullable(1); !ok || !nullable {
+			t.Errorf("failed to get the nullability")
+		}
+		if err = rows.Next(make([]driver.Value, 2)); err != io.EOF {
+			t.Errorf("should have returned no rows. err: %v", err)
+		}
+		rows.Close()
+	}
+	if _, err := sc.ExecContext(ctx, "PUT file:///tmp/a.csv @~", nil); err != nil {
+		t.Errorf("should have described PUT without transferring the files. err: %v", err)
+	}
+	res, err := sc.ExecContext(ctx, "INSERT INTO t VALUES (1)", nil)
+	if err != nil {
+		t.Fatalf("failed to describe. err: %v", err)
+	}
+	if _, err = res.RowsAffected(); err == nil {
+		t.Errorf("should not have returned the rows affected")
+	}
+	if len(requests) != 4 {
+		t.Fatalf("should have sent a request per statement without the result cache. got: %v", len(requests))
+	}
+	for i, req := range requests {
+		if !req.DescribeOnly {
+			t.Errorf("%d: failed to send describeOnly", i)
+		}
+	}
+
+	requests = nil
+	if _, err = sc.ExecContext(context.Background(), "INSERT INTO t VALUES (1)", nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if len(requests) != 1 || requests[0].DescribeOnly {
+		t.Errorf("should not have sent describeOnly without the context")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsCacheEntry is the resolved addresses of a host.
+type dnsCacheEntry struct {
+	addrs      []string
+	expires    time.Time
+	refreshing bool
+}
+
+// dnsCache caches the addresses of the Snowflake and stage hosts. An expired entry is still used while it's
+// refreshed in the background, so that a slow resolver doesn't block the requests, and kept if the refresh
+// fails.
+type dnsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*dnsCacheEntry
+	lookup  func(ctx context.Context, host string) ([]string, error)
+}
+
+var (
+	defaultDNSCache = &dnsCache{
+		entries: make(map[string]*dnsCacheEntry),
+		lookup:  net.DefaultResolver.LookupHost,
+	}
+	// defaultDialer is the dialer of the builtin transports.
+	defaultDialer = &net.Dialer{}
+)
+
+// SetDNSCacheTTL enables caching the resolved addresses of the hosts the driver connects to for ttl. Each entry
+// expires with a jitter of up to 10% of ttl, so that the entries are not refreshed at once. Zero disables the
+// cache, which is the default.
+func SetDNSCacheTTL(ttl time.Duration) {
+	defaultDNSCache.mu.Lock()
+	defer defaultDNSCache.mu.Unlock()
+	defaultDNSCache.ttl = ttl
+	defaultDNSCache.entries = make(map[string]*dnsCacheEntry)
+}
+
+// expiration returns the jittered expiration of an entry resolved now.
+func (c *dnsCache) expiration() time.Time {
+	jitter := time.Duration(0)
+	if c.ttl >= 10 {
+		jitter = time.Duration(rand.Int63n(int64(c.ttl/5))) - c.ttl/10
+	}
+	return getClock().Now().Add(c.ttl + jitter)
+}
+
+// resolve returns the addresses of the host. It returns false if the cache is disabled.
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]string, bool, error) {
+	c.mu.Lock()
+	if c.ttl <= 0 {
+		c.mu.Unlock()
+		return nil, false, nil
+	}
+	entry, ok := c.entries[host]
+	if ok {
+		if getClock().Now().After(entry.expires) && !entry.refreshing {
+			entry.refreshing = true
+			go c.refresh(host, entry)
+		}
+		addrs := entry.addrs
+		c.mu.Unlock()
+		return addrs, true, nil
+	}
+	c.mu.Unlock()
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, true, err
+	}
+	c.mu.Lock()
+	c.entries[host] = &dnsCacheEntry{addrs: addrs, expires: c.expiration()}
+	c.mu.Unlock()
+	return addrs, true, nil
+}
+
+// refresh resolves the host of the expired entry again.
+func (c *dnsCache) refresh(host string, entry *dnsCacheEntry) {
+	addrs, err := c.lookup(context.Background(), host)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refreshing = false
+	if err != nil {
+		glog.V(1).Infof("failed to refresh the address of %v. keep using %v. err: %v", host, entry.addrs, err)
+		return
+	}
+	entry.addrs = addrs
+	entry.expires = c.expiration()
+}
+
+// dialContext dials the address with the addresses in the DNS cache if enabled.
+func dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return defaultDialer.DialContext(ctx, network, address)
+	}
+	addrs, ok, err := defaultDNSCache.resolve(ctx, host)
+	if !ok {
+		return defaultDialer.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = defaultDialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
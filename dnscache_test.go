@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tcResolver struct {
+	mu    sync.Mutex
+	addrs []string
+	err   error
+	calls int
+}
+
+func (r *tcResolver) lookup(_ context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	return r.addrs, r.err
+}
+
+func (r *tcResolver) get() ([]string, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addrs, r.calls
+}
+
+func waitForRefresh(c *dnsCache, host string) {
+	for i := 0; i < 1000; i++ {
+		c.mu.Lock()
+		refreshing := c.entries[host].refreshing
+		c.mu.Unlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	r := &tcResolver{addrs: []string{"10.0.0.1"}}
+	c := &dnsCache{entries: make(map[string]*dnsCacheEntry), lookup: r.lookup}
+	ctx := context.Background()
+	if _, ok, _ := c.resolve(ctx, "a.snowflakecomputing.com"); ok {
+		t.Fatalf("should have been disabled")
+	}
+	c.ttl = time.Minute
+	for i := 0; i < 3; i++ {
+		addrs, ok, err := c.resolve(ctx, "a.snowflakecomputing.com")
+		if !ok || err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+			t.Fatalf("failed to resolve. addrs: %v, err: %v", addrs, err)
+		}
+	}
+	if _, calls := r.get(); calls != 1 {
+		t.Errorf("failed to cache. calls: %v", calls)
+	}
+	if d := c.entries["a.snowflakecomputing.com"].expires.Sub(time.Now()); d < 50*time.Second || d > 70*time.Second {
+		t.Errorf("failed to jitter within 10%% of TTL. expires in: %v", d)
+	}
+
+	// an expired entry is used while refreshing, and kept if the refresh fails.
+	r.mu.Lock()
+	r.addrs = []string{"10.0.0.2"}
+	r.err = errors.New("timeout")
+	r.mu.Unlock()
+	c.entries["a.snowflakecomputing.com"].expires = time.Now().Add(-time.Second)
+	addrs, _, err := c.resolve(ctx, "a.snowflakecomputing.com")
+	if err != nil || addrs[0] != "10.0.0.1" {
+		t.Fatalf("failed to use the stale entry. addrs: %v, err: %v", addrs, err)
+	}
+	waitForRefresh(c, "a.snowflakecomputing.com")
+	if addrs, _, _ = c.resolve(ctx, "a.snowflakecomputing.com"); addrs[0] != "10.0.0.1" {
+		t.Errorf("failed to keep the entry. addrs: %v", addrs)
+	}
+	waitForRefresh(c, "a.snowflakecomputing.com")
+	r.mu.Lock()
+	r.err = nil
+	r.mu.Unlock()
+	c.resolve(ctx, "a.snowflakecomputing.com")
+	waitForRefresh(c, "a.snowflakecomputing.com")
+	if addrs, _, _ = c.resolve(ctx, "a.snowflakecomputing.com"); addrs[0] != "10.0.0.2" {
+		t.Errorf("failed to refresh the entry. addrs: %v", addrs)
+	}
+}
+
+func TestDialContextDNSCache(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	r := &tcResolver{addrs: []string{"127.0.0.1"}}
+	orgLookup := defaultDNSCache.lookup
+	defaultDNSCache.lookup = r.lookup
+	SetDNSCacheTTL(time.Minute)
+	defer func() {
+		SetDNSCacheTTL(0)
+		defaultDNSCache.lookup = orgLookup
+	}()
+	for i := 0; i < 2; i++ {
+		conn, err := dialContext(context.Background(), "tcp", net.JoinHostPort("a.snowflakecomputing.com", port))
+		if err != nil {
+			t.Fatalf("failed to dial. err: %v", err)
+		}
+		conn.Close()
+	}
+	if _, calls := r.get(); calls != 1 {
+		t.Errorf("failed to use the cache. calls: %v", calls)
+	}
+}
@@ -30,7 +30,10 @@ The Go Snowflake Driver supports the following connection syntaxes (or data sour
 	* username[:password]@accountname/dbname[?param1=value&...&paramN=valueN
 	* username[:password]@hostname:port/dbname/schemaname?account=<your_account>[&param1=value&...&paramN=valueN]
 
-where all parameters must be escaped or use `Config` and `DSN` to construct a DSN string.
+where all parameters must be escaped or use `Config` and `DSN` to construct a DSN string. Config.DSN returns the
+DSN that ParseDSN parses back to the same Config, or an error if a field such as Transport can't be represented
+in a DSN. ParseDSN returns ErrCodeInvalidDSNParameter for a malformed parameter value. The timeouts are in
+seconds, or in the format of time.ParseDuration, e.g., 1500ms.
 
 The following example opens a database handle with the Snowflake account
 myaccount where the username is jsmith, password is mypassword, database is
@@ -38,6 +41,11 @@ mydb, schema is testschema, and warehouse is mywh:
 
 	db, err := sql.Open("snowflake", "jsmith:mypassword@myaccount/mydb/testschema?warehouse=mywh")
 
+NewConnector opens the connections of sql.OpenDB with a Config instead of a DSN, so that the fields that cannot be
+included in a DSN, e.g., PrivateKey or Transport, are used. The options set the driver level hooks:
+
+	db := sql.OpenDB(sf.NewConnector(cfg, sf.WithTransport(rt), sf.WithInterceptor(audit)))
+
 Connection Parameters
 
 The following connection parameters are supported:
@@ -46,12 +54,22 @@ The following connection parameters are supported:
 		US East region, specify us-east-1.
 		EU (Frankfurt) region, specify eu-central-1.
 		AU (Australia) region, specify ap-southeast-2.
+		The cloud is added to the host for the regions that require it, e.g., us-east-2.aws,
+		east-us-2.azure or us-central1.gcp, and may be included in the region.
 
 	* account <string>: Specifies the name of your Snowflake account, where string is the name
 		assigned to your account by Snowflake. In the URL you received from
 		Snowflake, your account name is the first segment in the domain (e.g.
 		abc123 in https://abc123.snowflakecomputing.com). This parameter is
 		optional if your account is specified after the @ character.
+		The account may be an account locator followed by the region and cloud,
+		e.g., abc123.east-us-2.azure, or <organization>-<account name>, e.g.,
+		myorg-myaccount, which has no region. The host is built from the account,
+		and a region conflicting with the region in the account or specified for an
+		organization account fails with ErrCodeRegionMismatch. For private connectivity,
+		e.g., AWS PrivateLink, add .privatelink to the account, e.g.,
+		abc123.us-east-1.privatelink or myorg-myaccount.privatelink. In China or with a
+		custom DNS name, specify the host, e.g., abc123.snowflakecomputing.cn:443.
 
 	* database: Specifies the database to use by default in the client session
 		(can be changed after login).
@@ -71,27 +89,192 @@ The following connection parameters are supported:
 		embedded in the login password. Appends the MFA passcode to the end of the
 		password.
 
+	* clientRequestMfaToken: false by default. Set to true to cache the MFA token returned by the server, so that
+		the later logins of the user to the host don't prompt MFA, e.g., Duo Push, until the token expires. The
+		MFA token caching must be allowed for the account with ALLOW_CLIENT_MFA_CACHING. The tokens are stored in
+		credential_cache.json of SF_TEMPORARY_CREDENTIAL_CACHE_DIR or $HOME/.cache/snowflake, readable only by the
+		owner. Set Config.CredentialStore to store them elsewhere, e.g., in the keychain of the OS.
+
 	* loginTimeout: Specifies the timeout, in seconds, for login. The default
 		is 60 seconds. The login request gives up after the timeout length if the
 		HTTP response is success.
 
+	* maxRetryDuration: Specifies the maximum time, in seconds, of the attempts of a request to Snowflake, if shorter
+		than loginTimeout or the request timeout. The requests failed with a network error, HTTP 403, 408, 429 or
+		5XX are retried with exponential backoff and jitter, and each attempt has a new request_guid and the
+		retry count. The requestId is kept across the attempts, so that Snowflake doesn't run a retried query
+		twice. The host names not found and the certificates rejected fail without retries. After the time,
+		the error number is ErrRetryTimeout, or ErrTooManyRequests if throttled, and SnowflakeError.Attempts
+		is the number of the attempts. Unlimited by default.
+
+	* queryTimeout: Specifies the default timeout, in seconds, of the queries. WithStatementTimeout overrides it
+		for a query. After the timeout, the query is canceled in Snowflake and fails with ErrQueryTimeout,
+		unlike the timeout of the login. No timeout by default.
+
 	* authenticator: Specifies the authenticator to use for authenticating user credentials:
 		- To use the internal Snowflake authenticator, specify snowflake (Default).
 		- To authenticate through Okta, specify https://<okta_account_name>.okta.com (URL prefix for Okta).
+		  The driver logs in to Okta with the user and password, and submits the SAML response to Snowflake
+		  after verifying the URLs returned by Snowflake and Okta are of the Okta and Snowflake accounts. The
+		  Okta users that require MFA are not supported.
 		- To authenticate using your IDP via a browser, specify externalbrowser.
 		- To authenticate via OAuth, specify oauth and provide an OAuth Access Token (see the token parameter below).
+		- To authenticate with a key pair, specify snowflake_jwt and provide the RSA private key of the public key
+		  set to the user with ALTER USER ... SET RSA_PUBLIC_KEY (see the privateKey and privateKeyFile
+		  parameters below). The driver logs in with a JWT signed by the key.
+		- To resolve the credentials from the environment, specify auto. The driver tries the Snowpark Container
+		  Services token file, the AWS, GCP and Azure workload identities, the SNOWFLAKE_USER, SNOWFLAKE_PASSWORD
+		  and SNOWFLAKE_TOKEN environment variables and the ~/.snowflake/credentials.json file in order, and
+		  logs in with the first credentials found. Set Config.CredentialProviders or
+		  SnowflakeDriver.CredentialProviders to change the chain, e.g., to the secret stores of the secrets
+		  package.
+
+	* browserPort: any free port by default. Specifies the local port, or the range of ports, e.g., 8001-8010,
+		that the externalbrowser authenticator listens on for the callback from the browser. The first free port
+		in the range is used.
+
+	* browserAuthTimeout: Specifies the timeout, in seconds, for the user to complete the externalbrowser
+		authentication. The default is 120 seconds. The error number is ErrExternalBrowserTimeout after the
+		timeout.
 
 	* application: Identifies your application to Snowflake Support.
 
+	* queryComment: false by default. Set to true to prepend a comment with the application name in JSON, e.g.,
+		{"application":"etl"}, to the statements, so that they are attributed in the query history.
+		WithQueryComment adds fields, e.g., the trace ID, to the comment.
+
 	* insecureMode false by default. Set to true to bypass the Online
 		Certificate Status Protocol (OCSP) certificate revocation check.
 		IMPORTANT: Change the default value for testing or emergency situations only.
 
+	* ocspFailOpen: true by default. In fail-open mode, a certificate is rejected only if the OCSP responder
+		returns revoked or unknown, and the other OCSP failures, e.g., the responder is unavailable, are logged.
+		Set to false for fail-closed mode, which rejects a certificate unless its revocation status is good.
+		The OCSP responses are cached in memory and in ocsp_response_cache of the cache directory, and the
+		missing responses are downloaded from the OCSP cache server of Snowflake before the OCSP responders are
+		queried. The cache server is of the domain of the host, e.g., ocsp.snowflakecomputing.cn, or
+		ocsp.<host> for a private connectivity host. Set SF_OCSP_RESPONSE_CACHE_SERVER_URL to use another
+		cache server, or SF_OCSP_RESPONSE_CACHE_SERVER_ENABLED=false to query the OCSP responders only.
+
 	* token: a token that can be used to authenticate. Should be used in conjunction with the "oauth" authenticator.
+		Set Config.TokenProvider or SnowflakeDriver.TokenProvider instead to get a fresh token at every login. If
+		the token is rejected, e.g., it expired, the provider is called again and the login is retried once.
+
+	* privateKey: the RSA private key for the "snowflake_jwt" authenticator, PKCS #1 or PKCS #8 DER encoded in
+		URL-safe base64. Alternatively, set Config.PrivateKey.
+
+	* privateKeyFile: the path of the PEM file of the unencrypted RSA private key, PKCS #1 or PKCS #8, for the
+		"snowflake_jwt" authenticator. The file is read at every login. To rotate the key, set the new public key
+		to the user with ALTER USER ... SET RSA_PUBLIC_KEY_2 and add the private key to Config.PrivateKeys. If
+		the JWT is rejected, the login is retried with the next key in order. The new connections of a connector
+		of NewConnector start with the key accepted last.
 
 	* client_session_keep_alive: Set to true have a heartbeat in the background every hour to keep the connection alive
 		such that the connection session will never expire. Care should be taken in using this option as it opens up
-		the access forever as long as the process is alive.
+		the access forever as long as the process is alive. If the parameter is set to false, no heartbeats are
+		sent even if the login returns a health check interval. The session token is renewed by the heartbeats
+		when it expires. The parameter may also be altered after the login, e.g., by
+		SnowflakeConn.SetSessionParameter, which starts or stops the heartbeats.
+
+	* client_session_keep_alive_heartbeat_frequency: the seconds between the heartbeats of
+		client_session_keep_alive, from 900 to a quarter of the master token validity. If it isn't set, the
+		health check interval returned by the login is used, or a quarter of the master token validity.
+
+	* disableHeartbeat: false by default. Set to true to send no heartbeats, e.g., in short-lived command line
+		tools. Unless client_session_keep_alive is set, an open connection sends a heartbeat at the health check
+		interval returned by the login so that the session of an idle pooled connection isn't invalidated. The heartbeats stop when the
+		connection is closed.
+
+	* disableTelemetry: false by default. Set to true to send no client telemetry events to Snowflake. By
+		default, a connection sends the latency of the login, the round trips of the queries, the failed chunk
+		downloads and the failed OCSP checks to Snowflake in batches, so that Snowflake support can diagnose
+		driver issues. No events are sent if the account sets CLIENT_TELEMETRY_ENABLED to false.
+
+	* disableQueryContextCache: false by default. Set to true to keep no query context. By default, a
+		connection keeps the query context returned by the queries, up to QUERY_CONTEXT_CACHE_SIZE entries,
+		and sends it with the next queries, so that the queries read the writes of the session, e.g., to
+		hybrid tables, consistently across the instances of the cloud services.
+
+	* clientRedirect: false by default. Set to true if the host is a Client Redirect connection URL, e.g.,
+		myorg-myconnection.snowflakecomputing.com. The driver resolves the connection URL to the current primary
+		account, and resolves it again and retries once if the login fails, so that the application follows a
+		failover to another region without configuration changes.
+
+	* invalidUTF8: passthrough by default. Specifies the handling of invalid UTF-8 byte sequences in the string
+		values of result sets: passthrough returns the values as is, replace replaces the invalid sequences with
+		U+FFFD, and error fails the row fetch. Note the JSON result format is decoded with encoding/json, which
+		already replaces invalid sequences inside JSON strings with U+FFFD.
+
+	* columnNameCase: asis by default. Specifies the case of the column names returned by Rows.Columns: asis
+		returns the names as Snowflake returns them, lower returns them in lower case, and normalize returns the
+		names of unquoted identifiers in lower case and keeps quoted identifiers as is, following the session
+		parameter QUOTED_IDENTIFIERS_IGNORE_CASE.
+
+	* sessionReset: none by default. Specifies how the session is reset when database/sql reuses the connection,
+		so that the state left by a borrower doesn't leak to the next one: variables unsets the session
+		variables, and full also drops the temporary tables created by the connection, restores the session
+		parameters of the login with ALTER SESSION and switches back to the role, warehouse, database and schema
+		of the login. If the reset fails, the connection is discarded. Requires Go 1.10 or later.
+
+	* geoOutputFormat: GeoJSON, WKT, EWKT, WKB or EWKB. Sets the session parameters GEOGRAPHY_OUTPUT_FORMAT and
+		GEOMETRY_OUTPUT_FORMAT, unless they are given in DSN. WKB and EWKB are fetched as []byte.
+
+	* numberMode: default by default. Specifies the Go type of the NUMBER values: default scans them as int64
+		for scale 0 and float64 otherwise, big returns *big.Int and *big.Rat, and string returns the decimal
+		strings. WithNumberMode overrides it for a query.
+
+	* resultFormat: json by default. Set to arrow to fetch the query results in Apache Arrow, or verify to
+		verify the results in Arrow against JSON (see Result Format).
+
+	* http2: false by default. Set to true to use HTTP/2, which multiplexes the requests of the connections over
+		fewer TCP connections. HTTP/1.1 is used by default because some proxies break HTTP/2. Requires Go 1.13
+		or later.
+
+	* hedgeChunkDownloads: false by default. Set to true to send a second request for a chunk of a large result
+		set if the download takes longer than the 95th percentile of the previous downloads, and take whichever
+		response arrives first. This cuts the tail latency of the fetches over lossy networks at the cost of
+		some duplicate downloads.
+
+	* maxChunkDownloadWorkers: 10 by default. Specifies the number of chunks of a large result set downloaded
+		concurrently ahead of the rows read. A new download starts as a chunk is read, so at most this many
+		chunks are held in memory besides the current one.
+
+	* maxChunkBufferBytes: 0 (unlimited) by default. Specifies the maximum bytes of the chunks downloaded ahead of
+		the rows read and held in memory. The downloads are deferred while the chunks exceed it, except that one
+		chunk is always downloaded ahead, unless chunkSpillDir is set. MetricChunkBufferBytes is the bytes held.
+
+	* chunkSpillDir: Specifies the directory where the chunks downloaded ahead are written, readable only by the
+		owner, instead of being held in memory while the chunks exceed maxChunkBufferBytes, so that the
+		downloads are not deferred. The files are removed when the chunks are read or the rows are closed.
+
+	* uploadPartSize: 67108864 (64MB) by default. Specifies the size in bytes of the parts of the files uploaded
+		by PUT to S3 stages in parts, at least 5242880 (5MB). The files larger are uploaded by the multipart
+		upload of S3, whose parts are uploaded over multiple connections at once. The streams of WithFileStream
+		are read in parts of the size to all stages, and only the parts being uploaded are held in memory.
+
+	* uploadPartParallel: 4 by default. Specifies the number of the parts of a file uploaded at once. The files
+		are also uploaded in parallel by the PARALLEL option of PUT.
+
+	* maxConcurrentQueries: 0 (unlimited) by default. Specifies the maximum number of queries in flight at a time
+		on a connection, e.g., through the prepared statements shared by goroutines. The other queries wait
+		in the order of arrival until a query completes or their context is canceled.
+
+	* circuitBreakerThreshold: 0 (disabled) by default. Specifies the number of consecutive availability failures
+		of the login and query requests to a host, e.g., connection errors and HTTP 502, 503 and 504 after the
+		retries, after which the requests to the host fail fast with ErrCircuitOpen instead of piling up. The
+		circuit is shared by the connections to the host.
+
+	* circuitBreakerCooldown: 30 by default. Specifies the seconds the circuit stays open. Then one probe request
+		is let through; the circuit closes if it succeeds and opens again if it fails.
+
+	* resultCacheTTL: 0 (disabled) by default. Specifies the seconds the result of a SELECT query is reused by
+		the identical query with the same bind values, user, role, database and schema, e.g., of dashboards.
+		The result is read with RESULT_SCAN instead of running the query again, so changes to the data
+		within the TTL are not visible. The cache is shared by the connections of the process.
+
+	* location: UTC by default. Specifies the IANA time zone name, e.g., America/New_York, of the location
+		attached to the scanned DATE and TIMESTAMP_NTZ values. The wall clock of the values is kept. Use
+		WithLocation to override it per query.
 
 
 All other parameters are taken as session parameters. For example, TIMESTAMP_OUTPUT_FORMAT session parameter can be
@@ -103,15 +286,237 @@ Proxy
 
 The Go Snowflake Driver honors the environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY for the forward proxy setting.
 
+Alternatively, set the proxy of the connection with the proxyHost and proxyPort parameters, and the credentials
+of the basic authentication with proxyUser and proxyPassword, or the same fields of Config. The hosts in
+nonProxyHosts, separated by |, are connected directly; * matches any characters, and a pattern starting with a
+dot matches the subdomains. The OCSP requests of the certificate revocation check are also sent via the proxy,
+and the environment variables are ignored:
+
+	user:password@myaccount/mydb?proxyHost=proxy.example.com&proxyPort=3128&proxyUser=pu&proxyPassword=pp&nonProxyHosts=localhost%7C*.internal
+
+Custom Transport
+
+Set Config.Transport to send all HTTP requests of a connection, including the result chunk downloads and the file
+transfers, via your http.RoundTripper, e.g., for proxy settings, mTLS client certificates or request tracing. Open
+the connection with NewConnector or SnowflakeDriver.OpenWithConfig, as a DSN cannot include a transport. SnowflakeDriver.Transport
+sets the transport of all connections opened by the driver. A custom transport replaces the builtin transports, so
+wrap SnowflakeTransport to keep the OCSP check:
+
+	cfg.Transport = &tracingTransport{base: sf.SnowflakeTransport}
+	conn, err := sf.SnowflakeDriver{}.OpenWithConfig(ctx, *cfg)
+
+Session Hooks
+
+Config.OnSessionOpen is called after the login of every connection, including the connections opened by the
+pool of database/sql, for example, to set session parameters, run USE SECONDARY ROLES or record the session ID.
+The connection fails if the hook returns an error, and its session is closed. Config.OnSessionClose is called
+before the session is closed. SessionInfo.Conn runs statements in the session:
+
+	cfg.OnSessionOpen = func(ctx context.Context, info sf.SessionInfo) error {
+		_, err := info.Conn.ExecContext(ctx, "USE SECONDARY ROLES ALL", nil)
+		return err
+	}
+	db := sql.OpenDB(sf.NewConnector(cfg))
+
+DNS Cache
+
+By default, the host names are resolved for every new connection. Call SetDNSCacheTTL to cache the resolved
+addresses of the Snowflake and stage hosts, for example, if the corporate DNS is slow. Expired addresses are
+used while they are resolved again in the background.
+
+	sf.SetDNSCacheTTL(5 * time.Minute)
+
+WebAssembly
+
+The driver compiles for GOOS=js GOARCH=wasm. In WebAssembly, the requests are sent with the Fetch API of the
+browser or the edge runtime, which verifies the certificates instead of the driver's OCSP check. Nothing is read
+from or written to the filesystem: the OCSP response cache is not persisted unless a store is set with
+SetOCSPCacheStore, and the auto authenticator uses only the environment variables. The Snowflake account must
+allow the origin of the page with CORS to be called from a browser.
+
+FIPS Mode
+
+Call EnableFIPSMode before opening connections to restrict the TLS settings of the builtin transports to
+FIPS-approved algorithms: TLS 1.2, AES-GCM cipher suites and the P-256 and P-384 curves. It is enabled
+automatically if the Go FIPS 140 module is enabled (Go 1.24 or later with GODEBUG=fips140=on), or the driver
+is built with the BoringCrypto toolchain and the fips tag, which also restricts crypto/tls process-wide:
+
+	GOEXPERIMENT=boringcrypto go build -tags fips
+
+In FIPS mode, PUT encrypts the files in AES-CBC even if the stage accepts AES-GCM, and GET decrypts AES-GCM files
+in memory with crypto/cipher, so the stage encryption uses only the validated implementations.
+
+Throttling
+
+If Snowflake throttles the requests with HTTP 429, or 503 with Retry-After, the driver waits for the time
+given by Retry-After and retries. While throttled, the requests of the connection are spaced out, and the
+interval shrinks as the requests succeed again. If the requests are still throttled at the request timeout,
+the error number is ErrTooManyRequests.
+
+Bad Connections
+
+When the session token expires, the driver renews it with the master token and retries the query rejected by
+the server once. The concurrent queries of a connection share one renewal. The master token expires after 4
+hours unless client_session_keep_alive is set, after which the session can't be renewed.
+
+If the session token can't be renewed, the server drops the session, or the requests fail with a network
+error, the statement returns the error and the connection returns driver.ErrBadConn afterwards, so that
+database/sql discards it and retries on another connection. The connection gone bad is also discarded when it's
+returned to the pool, by driver.Validator on Go 1.15 or later. Ping sends a heartbeat to check the session is
+alive without running a query in the warehouse. GetConnStats returns the number of
+driver.ErrBadConn by reason and the histograms of the connection lifetimes, to tune SetConnMaxLifetime and
+SetConnMaxIdleTime of sql.DB:
+
+	stats := sf.GetConnStats()
+	fmt.Println(stats.BadConn[sf.BadConnTokenExpired], stats.BadConnLifetimes.Max)
+
+Metrics
+
+RegisterMetricsCollector registers a MetricsCollector receiving the metrics of all connections as they change:
+the queries executed and failed, the round trip seconds of the queries, the rows fetched, the bytes of the result
+chunks downloaded, the HTTP requests retried, the failed logins, the open sessions, and the bytes of the chunks
+buffered in memory and the chunks spilled to disk by maxChunkBufferBytes and chunkSpillDir. The names of the metrics,
+e.g., MetricQueries, follow the Prometheus naming conventions, so a collector can update the Prometheus counters,
+histograms and gauges of the same names:
+
+	func (c *promCollector) AddCounter(name string, delta float64) {
+		c.counters[name].Add(delta)
+	}
+
+Errors
+
+The errors of Snowflake and the driver are *SnowflakeError including the error number, SQL state and query ID.
+AsSnowflakeError gets it from the wrapped errors, and Retryable returns true for the network failures and
+timeouts. With Go 1.13 or later, errors.Is matches the kinds of errors, i.e., ErrAuthFailed, ErrQueryCanceled
+and ErrObjectNotFound, and the underlying error of the driver errors, e.g., the network error:
+
+	if errors.Is(err, sf.ErrObjectNotFound) {
+		...
+	} else if se, ok := sf.AsSnowflakeError(err); ok {
+		fmt.Println(se.Number, se.SQLState, se.QueryID, se.Retryable())
+	}
+
+Query ID
+
+The query IDs are needed for RESULT_SCAN, cancellation and support. With Go 1.13 or later, get them from the
+driver connection in sql.Conn.Raw: SnowflakeConn.QueryID returns the ID of the last query of the connection,
+SnowflakeRows.QueryID and SnowflakeResult.QueryID the ID of the query of the rows and result. The errors of
+the queries include the query ID in SnowflakeError.QueryID:
+
+	err = conn.Raw(func(dc interface{}) error {
+		rows, err := dc.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		fmt.Println(rows.(sf.SnowflakeRows).QueryID())
+		return nil
+	})
+
+SnowflakeConn.FetchResultByID returns the rows of the result of a query by its ID without running the query
+again, e.g., to page through a large result across the requests of a web backend, while Snowflake keeps the
+result for 24 hours. The query must have been run by the same user. ErrInvalidQueryID is returned for a
+malformed query ID. SnowflakeRows.SeekRow moves to a row offset of the result, locating the chunk of the row by
+the row counts of the chunks, so that a page far into a large result is read without downloading the chunks
+before it.
+
+Query Tags and Comments
+
+WithQueryTag sets QUERY_TAG of the statements run with the context, and WithQueryComment prepends a comment
+with the application name and the fields in JSON to them, so that the statements are attributed in the query
+history. Set queryComment to prepend the comment to all statements of the connection:
+
+	ctx = sf.WithQueryTag(ctx, "etl-job-42")
+	ctx = sf.WithQueryComment(ctx, map[string]string{"trace_id": traceID})
+	_, err = db.ExecContext(ctx, "INSERT INTO t SELECT * FROM s")
+
+Session Context
+
+SnowflakeConn.Use switches the role, warehouse, database and schema of a connection with only the USE
+statements needed, validates the identifiers and verifies the context in the responses, which is safer than
+building USE statements from tenant names. With Go 1.13 or later, get the connection from sql.Conn.Raw.
+
+The session parameters of a connection are updated from the responses of the queries. SessionParameter and
+SessionParameters return them, SessionInfo returns the current role, warehouse, database and schema, and
+SetSessionParameter and UnsetSessionParameter run ALTER SESSION:
+
+	err = conn.Raw(func(dc interface{}) error {
+		return dc.(sf.SnowflakeConn).SetSessionParameter(ctx, "TIMEZONE", "UTC")
+	})
+
+Prepared Statements
+
+Prepare describes the statement without running it, so a syntax error or a missing object fails at Prepare, and
+SnowflakeStmt.ColumnTypes returns the result columns before the first execution. The descriptions are cached by
+the SQL text in the session context for 10 minutes and shared by the connections, so the statements prepared for
+every execution, e.g., by an ORM, are not described again. PUT, GET and multi-statement queries are not described.
+
+WithDescribeOnly describes the statements run with the context without running them in a warehouse. The queries
+return the columns of their result sets without rows, for schema discovery tools and query linters:
+
+	rows, err := db.QueryContext(sf.WithDescribeOnly(ctx), "SELECT * FROM orders")
+
+Batches
+
+Batch queues independent statements, and SnowflakeConn.ExecBatch runs them over the session of a connection with
+up to Batch.MaxParallel statements in flight, 4 by default, to save round trips in migration and seeding tools.
+The results and errors are returned per statement in the order queued, and a failing statement doesn't stop the
+others. The statements may run in any order, so they must not depend on each other:
+
+	var b sf.Batch
+	b.Queue("INSERT INTO regions VALUES (?, ?)", 1, "EMEA")
+	b.Queue("INSERT INTO regions VALUES (?, ?)", 2, "APAC")
+	err = conn.Raw(func(dc interface{}) error {
+		results := dc.(sf.SnowflakeConn).ExecBatch(ctx, &b)
+		...
+	})
+
+Progress
+
+The callbacks of Progress report the bytes of the files transferred by PUT and GET, the chunks of a large result
+downloaded, and the status of a query polled while it runs, so that CLIs can render progress bars. Set
+Config.Progress for all statements of the connections, or WithProgress for the statements run with a context, whose
+callbacks take precedence. The callbacks are called concurrently:
+
+	ctx := sf.WithProgress(context.Background(), sf.Progress{
+		Transfer: func(p sf.TransferProgress) {
+			bar.Set(p.File, p.Bytes, p.Total)
+		},
+	})
+	_, err = db.ExecContext(ctx, "PUT file:///tmp/data/large.csv @mystage")
+
 Logging
 
-By default, the driver's builtin logger is NOP; no output is generated. This is
-intentional for those applications that use the same set of logger parameters
-not to conflict with glog, which is incorporated in the driver logging
-framework.
+By default, the driver logs are discarded. SetLogger sends them to your Logger, which receives the level, message
+and fields of each record, and SetLogLevel selects the level, which can be changed at any time. NewSlogLogger
+adapts a log/slog logger with Go 1.21 or later:
+
+	sf.SetLogger(sf.NewSlogLogger(slog.Default()))
+	sf.SetLogLevel(sf.LogLevelDebug)
+
+Other structured loggers, e.g., logrus, are adapted by implementing Log:
+
+	type logrusLogger struct{ l logrus.FieldLogger }
+
+	func (a logrusLogger) Log(level sf.LogLevel, msg string, fields []sf.LogField) {
+		f := logrus.Fields{}
+		for _, field := range fields {
+			f[field.Key] = field.Value
+		}
+		a.l.WithFields(f).Debug(msg)
+	}
+
+The logs of the queries have the session, request and query IDs as the fields sessionId, requestId and queryId.
+WithLogFields adds your fields, e.g., a trace ID, to the logs of the queries run with the context:
+
+	rows, err := db.QueryContext(sf.WithLogFields(ctx, sf.LogField{Key: "traceId", Value: traceID}), query)
 
-In order to enable debug logging for the driver, add a build tag sfdebug to the
-go tool command lines, for example:
+The passwords, tokens, passcodes, private keys and cloud storage credentials are masked as **** in all logs and
+the messages of SnowflakeError, including the DSN parameters and the request and response bodies. The masking
+can't be disabled.
+
+The driver doesn't register glog or its flags unless it's built with the sfdebug tag, which makes glog the default
+logger, for example:
 
 	go build -tags=sfdebug
 
@@ -119,23 +524,14 @@ For tests, run the test command with the tag along with glog parameters. For
 example, the following command will generate all acitivty logs in the standard
 error.
 
-	go test -tags=sfdebug -v . -vmodule=*=2 -stderrthreshold=INFO
+	go test -tags=sfdebug -v . -v=2 -stderrthreshold=INFO
 
 Likewise, if you build your application with the tag, you may specify the same
 set of glog parameters.
 
-	your_go_program -vmodule=*=2 -stderrthreshold=INFO
-
-To get the logs for a specific module, use the -vmodule option. For example, to
-retrieve the driver.go and connection.go module logs:
-
-	your_go_program -vmodule=driver=2,connection=2 -stderrthreshold=INFO
-
-Note: If your request retrieves no logs, call db.Close() or glog.flush() to flush the glog buffer.
+	your_go_program -v=2 -stderrthreshold=INFO
 
-Note: The logger may be changed in the future for better logging. Currently if
-the applications use the same parameters as glog, you cannot collect both
-application and driver logs at the same time.
+Note: If your request retrieves no logs, call db.Close() or glog.Flush() to flush the glog buffer.
 
 Canceling Query by CtrlC
 
@@ -160,8 +556,41 @@ e.g., QueryContext, ExecContext.
 	rows, err := db.QueryContext(ctx, query)
 	... (Ctrl+C to cancel the query)
 
+When the context is canceled or its deadline passes, the driver aborts the query running in the warehouse, so
+that it stops consuming credits, and returns the context error, e.g., context.Canceled, even if the abort
+request fails.
+
 See cmd/selectmany.go for the full example.
 
+Multi-Statement Queries
+
+By default, a query text must have one statement. Call WithMultiStatement to run several statements separated by
+semicolons in one request:
+
+	ctx := sf.WithMultiStatement(context.Background(), 2)
+	rows, err := db.QueryContext(ctx, "SELECT 1; SELECT 2, 3")
+
+The number must match the statements in the query text, or 0 to allow any number. QueryContext returns the result
+of the first statement, and NextResultSet moves to the result of the next one. ExecContext returns the total rows
+affected by the DML statements.
+
+Result Format
+
+The driver fetches the query results in JSON by default. Set resultFormat to arrow to fetch them in Apache Arrow,
+which is decoded with much less CPU and memory than JSON for large result sets. The rows are converted to the same
+Go types in either format:
+
+	user:password@account/database?resultFormat=arrow
+
+To test the migration to Arrow, set resultFormat to verify. Each result set is fetched in Arrow and again in JSON by
+RESULT_SCAN, and the query fails with ErrResultFormatMismatch at the first value converted differently. The
+results are read twice before the rows are returned, so don't use it in production.
+
+To hand the results to an Arrow-based engine without converting them row by row, run the query with
+WithArrowBatches on a connection with resultFormat set to arrow, and call SnowflakeRows.GetArrowBatches on the
+driver rows. Each ArrowBatch is a chunk of the
+result set, and Fetch returns it in the Arrow IPC stream format, readable by any Arrow implementation.
+
 Supported Data Types
 
 Queries return SQL column type information in the ColumnType type. The
@@ -180,9 +609,43 @@ data types:
 	VARIANT	                VARIANT
 	OBJECT	                OBJECT
 	ARRAY	                ARRAY
+	GEOGRAPHY	        GEOGRAPHY
+	GEOMETRY	        GEOMETRY
 	BINARY	                BINARY
 	BOOLEAN	                BOOLEAN
 
+High Precision Numbers
+
+NUMBER has up to 38 digits, which int64 and float64 can't hold. Set numberMode to big, or run the query with
+WithNumberMode, to fetch the values as *big.Int for scale 0 and *big.Rat otherwise:
+
+	var n *big.Int
+	err = db.QueryRowContext(sf.WithNumberMode(ctx, sf.NumberBig), "SELECT 12345678901234567890123456789").Scan(&n)
+
+With string, the decimal strings are returned for any decimal library. ColumnTypeScanType reports the type of the
+mode, and ColumnTypePrecisionScale the precision and scale of the column.
+
+Named Parameters
+
+The parameters given by sql.Named are bound to the :name placeholders of the query, so that the SQL files
+shared with the other Snowflake connectors work as is. A name may be used more than once:
+
+	rows, err := db.Query("SELECT * FROM t WHERE id = :id OR parent = :id", sql.Named("id", 42))
+
+Named parameters can't be mixed with positional parameters or ? placeholders. The colons of the paths of
+semi-structured data, e.g., src:name, casts, string literals and comments are not placeholders.
+
+Array Binding
+
+Array binds the elements of a slice to a parameter, so that a bulk INSERT sends all rows in one request instead
+of a round trip per row. All parameters of the statement must be arrays of the same length:
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?)", sf.Array(ids), sf.Array(names))
+
+If the arrays have more values than CLIENT_STAGE_ARRAY_BINDING_THRESHOLD, 65280 by default, they are uploaded
+as CSV to the temporary stage SYSTEM$BIND of the session, and the statement reads them from the stage. If the
+stage can't be created, e.g., with a read-only role, the arrays are sent in the request.
+
 Binding Time Type
 
 Go's database/sql package limits Go's data types to the following for binding and fetching:
@@ -225,6 +688,17 @@ or BINARY data type. The above example could be rewritten as follows:
 	// ...
 	_, err = stmt.Exec(sf.DataTypeTimestampNtz, tmValue, sf.DataTypeTimestampLtz, tmValue)
 
+Alternatively, TimestampNtz, TimestampLtz and TimestampTz bind a single time.Time as the type regardless of the
+flags of the preceding parameters:
+
+	_, err = stmt.Exec(sf.TimestampNtz(tmValue), sf.TimestampLtz(tmValue))
+
+TIMESTAMP_NTZ is bound as the wall clock of the time in UTC, TIMESTAMP_LTZ as the instant of the time, and
+TIMESTAMP_TZ as the instant with the offset of the location of the time.
+
+TIMESTAMP_LTZ values are fetched in the time zone of the TIMEZONE parameter of the session, or the local time
+zone if the parameter is unknown.
+
 Timestamps with Time Zones
 
 The driver fetches TIMESTAMP_TZ (timestamp with time zone) data using the
@@ -248,8 +722,58 @@ example, sf is an alias for the gosnowflake package:
 	var b = []byte{0x01, 0x02, 0x03}
 	_, err = stmt.Exec(sf.DataTypeBinary, b)
 
-Limitations
+Semi-structured Data
+
+VARIANT, OBJECT and ARRAY columns are fetched as JSON text, whose ScanType is string. Scan them into
+Variant, or into a struct or map by ScanVariant:
+
+	var order Order
+	err = db.QueryRow("SELECT doc FROM orders WHERE id = ?", 1).Scan(sf.ScanVariant(&order))
+
+Variant, json.RawMessage, structs and maps are bound as JSON, and their placeholders are parsed by PARSE_JSON.
+INSERT ... VALUES is run as INSERT ... SELECT ... FROM VALUES, since VALUES doesn't accept PARSE_JSON:
+
+	_, err = db.Exec("INSERT INTO orders (id, doc) VALUES (?, ?)", 1, order)
+
+Spatial Data
+
+GEOGRAPHY and GEOMETRY columns are fetched in the format of the session parameters GEOGRAPHY_OUTPUT_FORMAT and
+GEOMETRY_OUTPUT_FORMAT, which are set by geoOutputFormat. GeoJSON, the default, WKT and EWKT are fetched as
+strings, and WKB and EWKB as []byte. Geography and Geometry bind a string in WKT, EWKT, GeoJSON or hex WKB as the
+spatial type:
+
+	_, err = db.Exec("INSERT INTO places VALUES (?, ?)", "office", sf.Geography("POINT(-122.35 37.55)"))
+
+Uploading and Downloading Files
+
+PUT uploads local files to a stage, and GET downloads the files in a stage. Both run with Exec or Query, whose rows
+are the result of each file:
+
+	_, err = db.Exec("PUT file:///tmp/data/*.csv @mystage AUTO_COMPRESS=TRUE")
+	rows, err := db.Query("GET @mystage/data.csv.gz file:///tmp/out")
+
+The files are transferred by the driver directly to and from the cloud storage of the stage, i.e., S3, Azure Blob
+Storage or Google Cloud Storage, with the temporary credentials in the response. The PARALLEL option sets the
+number of files transferred at once. Unless OVERWRITE=TRUE, the files already in the stage with the same content
+are SKIPPED. The large files are uploaded to S3 in parts by uploadPartSize and uploadPartParallel, through the
+S3 Transfer Acceleration endpoint if the stage uses it. Progress.Transfer reports the bytes transferred. The
+clients of the cloud storages call their REST APIs with the standard library, as the Arrow decoder reads the
+results, so the driver links no cloud storage or Arrow SDK.
+
+If the stage requires, the files are encrypted on the client with a random key per file, wrapped by the master key
+of the stage in the response, as the other Snowflake clients do, so the files are read by them too. The files are
+encrypted in AES-GCM if the stage accepts it, otherwise in AES-CBC, in blocks, so that a file isn't held in memory.
+GET decrypts the files in either cipher, and writes a file only if it's authenticated. The files in S3 stages
+without the client-side encryption are encrypted by S3.
+
+To upload data generated by the application without writing it to a local file, run PUT with the context of
+WithFileStream. The file name in the command is the name of the file in the stage. The data are compressed and
+encrypted as read, and uploaded in parts of uploadPartSize by the multipart upload of S3, the blocks of Azure or the
+resumable upload of GCS, so that neither the disk nor the memory holds the whole stream. A stream uploaded to the
+presigned URL of a GCS stage without an access token isn't retried on a failure. Unless OVERWRITE=TRUE, a stream
+is SKIPPED if a file of the name is in the stage:
 
-Currently, GET and PUT operations are unsupported.
+	ctx := sf.WithFileStream(context.Background(), r)
+	_, err = db.ExecContext(ctx, "PUT file://data.csv @mystage AUTO_COMPRESS=TRUE")
 */
 package gosnowflake
@@ -3,34 +3,85 @@
 package gosnowflake
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // SnowflakeDriver is a context of Go Driver
-type SnowflakeDriver struct{}
+type SnowflakeDriver struct {
+	// Transport, if set, is Config.Transport of the connections opened by the driver unless the Config has one,
+	// and is used for all HTTP requests instead of the builtin transports. Register the driver under another name to use it, for example:
+	//	sql.Register("snowflake-replay", &SnowflakeDriver{Transport: rt})
+	Transport http.RoundTripper
+	// Interceptor, if set, is called before every Exec and Query of the connections opened by the driver, after
+	// the interceptors registered by RegisterStatementInterceptor.
+	Interceptor StatementInterceptor
+	// CredentialProviders, if set, is the credential provider chain for authenticator=auto instead of
+	// DefaultCredentialProviders.
+	CredentialProviders []CredentialProvider
+	// TokenProvider, if set, is Config.TokenProvider of the connections opened by the driver unless the Config
+	// has one.
+	TokenProvider TokenProvider
+}
 
 // Open creates a new connection.
 func (d SnowflakeDriver) Open(dsn string) (driver.Conn, error) {
 	glog.V(2).Info("Open")
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Open has no context. the login requests are bounded by LoginTimeout.
+	return d.open(context.Background(), cfg)
+}
+
+// OpenWithConfig creates a new connection with the config instead of a DSN, so that the fields that cannot be
+// included in a DSN, e.g., Transport, are used. The host is set from the account and region if it is empty.
+func (d SnowflakeDriver) OpenWithConfig(ctx context.Context, config Config) (driver.Conn, error) {
+	glog.V(2).Info("OpenWithConfig")
+	cfg := &config
+	// the session parameters are updated by the connection
+	cfg.Params = copyParams(cfg.Params)
+	fillHost(cfg)
+	if err := fillMissingConfigParameters(cfg); err != nil {
+		return nil, err
+	}
+	return d.open(ctx, cfg)
+}
+
+func (d SnowflakeDriver) open(ctx context.Context, cfg *Config) (driver.Conn, error) {
 	var err error
 	sc := &snowflakeConn{
 		SequeceCounter: 0,
+		interceptors:   registeredStatementInterceptors(),
+		cfg:            cfg,
 	}
-	sc.cfg, err = ParseDSN(dsn)
-	if err != nil {
-		sc.cleanup()
-		return nil, err
+	if d.Interceptor != nil {
+		sc.interceptors = append(sc.interceptors, d.Interceptor)
 	}
-	st := SnowflakeTransport
-	if sc.cfg.InsecureMode {
-		// no revocation check with OCSP. Think twice when you want to enable this option.
-		st = snowflakeInsecureTransport
+	sc.limiter = newQueryLimiter(sc.cfg.MaxConcurrentQueries)
+	if len(sc.cfg.CredentialProviders) == 0 {
+		sc.cfg.CredentialProviders = d.CredentialProviders
 	}
-	if err != nil {
-		return nil, err
+	if sc.cfg.TokenProvider == nil {
+		sc.cfg.TokenProvider = d.TokenProvider
+	}
+	if strings.ToUpper(sc.cfg.Authenticator) == authenticatorAuto {
+		if err = resolveCredentials(ctx, sc.cfg); err != nil {
+			sc.cleanup()
+			return nil, err
+		}
+	}
+	if sc.cfg.Transport == nil {
+		sc.cfg.Transport = d.Transport
+	}
+	st := sc.cfg.Transport
+	if st == nil {
+		st = getTransport(sc.cfg)
 	}
 	// authenticate
 	sc.rest = &snowflakeRestful{
@@ -44,6 +95,9 @@ func (d SnowflakeDriver) Open(dsn string) (driver.Conn, error) {
 		Authenticator:       sc.cfg.Authenticator,
 		LoginTimeout:        sc.cfg.LoginTimeout,
 		RequestTimeout:      sc.cfg.RequestTimeout,
+		MaxRetryDuration:    sc.cfg.MaxRetryDuration,
+		pacer:               &pacer{},
+		breaker:             getCircuitBreaker(sc.cfg.Host, sc.cfg.CircuitBreakerThreshold, sc.cfg.CircuitBreakerCooldown),
 		FuncPost:            postRestful,
 		FuncGet:             getRestful,
 		FuncPostQuery:       postRestfulQuery,
@@ -52,36 +106,51 @@ func (d SnowflakeDriver) Open(dsn string) (driver.Conn, error) {
 		FuncPostAuth:        postAuth,
 		FuncCloseSession:    closeSession,
 		FuncCancelQuery:     cancelQuery,
+		FuncGetQueryResult:  getQueryResult,
 		FuncPostAuthSAML:    postAuthSAML,
 		FuncPostAuthOKTA:    postAuthOKTA,
 		FuncGetSSO:          getSSO,
 	}
+	if sc.cfg.ClientRedirect {
+		sc.rest.Host = resolveClientRedirect(sc.cfg.Host, false)
+	}
+	setOCSPCacheServerHost(sc.rest.Host)
 	var authData *authResponseMain
 	var samlResponse []byte
 	var proofKey []byte
 
-	authenticator := strings.ToUpper(sc.cfg.Authenticator)
+	authenticator := getAuthenticatorType(sc.cfg.Authenticator)
 	glog.V(2).Infof("Authenticating via %v", authenticator)
+	authStart := getClock().Now()
 	switch authenticator {
 	case authenticatorExternalBrowser:
 		samlResponse, proofKey, err = authenticateByExternalBrowser(
+			ctx,
 			sc.rest,
 			sc.cfg.Authenticator,
 			sc.cfg.Application,
 			sc.cfg.Account,
 			sc.cfg.User,
-			sc.cfg.Password)
+			sc.cfg.Password,
+			sc.cfg.BrowserPortMin,
+			sc.cfg.BrowserPortMax,
+			sc.cfg.BrowserAuthTimeout)
 		if err != nil {
 			sc.cleanup()
 			return nil, err
 		}
 	case authenticatorOAuth:
+		if err = provideToken(ctx, sc.cfg); err != nil {
+			sc.cleanup()
+			return nil, err
+		}
+	case authenticatorWorkloadIdentity, authenticatorJWT:
 	case authenticatorSnowflake:
 		// Nothing to do, parameters needed for auth should be already set in sc.cfg
 		break
-	default:
-		// this is actually okta, which is something misleading
+	case authenticatorOkta:
 		samlResponse, err = authenticateBySAML(
+			ctx,
 			sc.rest,
 			sc.cfg.Authenticator,
 			sc.cfg.Application,
@@ -93,11 +162,52 @@ func (d SnowflakeDriver) Open(dsn string) (driver.Conn, error) {
 			return nil, err
 		}
 	}
-	authData, err = authenticate(
+	login := authenticate
+	if authenticator == authenticatorJWT {
+		login = authenticateWithKeyRotation
+	}
+	authData, err = login(
+		ctx,
 		sc,
 		samlResponse,
 		proofKey)
+	if err != nil && sc.cfg.ClientRedirect && isConnectionFailure(err) {
+		// the connection may have failed over to another region. resolve it again and retry with new connections.
+		glog.V(1).Infof("failed to login to %v. resolving Client Redirect again. err: %v", sc.rest.Host, err)
+		closeIdleConnections(sc.rest)
+		sc.rest.Host = resolveClientRedirect(sc.cfg.Host, true)
+		authData, err = login(
+			ctx,
+			sc,
+			samlResponse,
+			proofKey)
+	}
+	if inv, ok := sc.cfg.credentialProvider.(CredentialInvalidator); ok && err != nil && isRejectedCredentials(err) {
+		// the cached credentials may be rotated. retry with the credentials retrieved again.
+		glog.V(1).Infof("credentials by %v are rejected. retrieving again. err: %v", sc.cfg.credentialProvider.Name(), err)
+		inv.InvalidateCredentials()
+		if err = resolveCredentials(ctx, sc.cfg); err == nil {
+			sc.rest.Authenticator = sc.cfg.Authenticator
+			authData, err = authenticate(
+				ctx,
+				sc,
+				samlResponse,
+				proofKey)
+		}
+	}
+	if err != nil && authenticator == authenticatorOAuth && sc.cfg.TokenProvider != nil && isRejectedCredentials(err) {
+		// the token may have expired or been revoked since it was provided. retry with a fresh token.
+		glog.V(1).Infof("OAuth token is rejected. getting a new token. err: %v", err)
+		if err = provideToken(ctx, sc.cfg); err == nil {
+			authData, err = authenticate(
+				ctx,
+				sc,
+				samlResponse,
+				proofKey)
+		}
+	}
 	if err != nil {
+		addMetricsCounter(MetricAuthFailures, 1)
 		sc.cleanup()
 		return nil, err
 	}
@@ -117,7 +227,20 @@ func (d SnowflakeDriver) Open(dsn string) (driver.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+	sc.loginContext = UseOptions{Role: sc.cfg.Role, Warehouse: sc.cfg.Warehouse, Database: sc.cfg.Database, Schema: sc.cfg.Schema}
 	sc.populateSessionParameters(authData.Parameters)
+	sc.healthCheckInterval = authData.HealthCheckInterval * time.Second
+	sc.masterValidity = authData.MasterValidityInSeconds * time.Second
+	sc.serverVersion = authData.ServerVersion
+	sc.telemetry = newTelemetry(sc)
+	sc.telemetry.addAuthLatency(authenticator, getClock().Now().Sub(authStart))
+	sc.startHeartBeat()
+	sc.openedAt = getClock().Now()
+	recordConnOpened()
+	if err = sc.runSessionHook(ctx, "OnSessionOpen", sc.cfg.OnSessionOpen); err != nil {
+		sc.closeSession()
+		return nil, err
+	}
 	return sc, nil
 }
 
@@ -3,7 +3,12 @@
 package gosnowflake
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -12,6 +17,7 @@ import (
 
 const (
 	defaultLoginTimeout   = 60 * time.Second
+	defaultBrowserTimeout = 120 * time.Second
 	defaultRequestTimeout = 0 * time.Second
 	defaultAuthenticator  = "snowflake"
 	defaultDomain         = ".snowflakecomputing.com"
@@ -33,37 +39,127 @@ type Config struct {
 	Host     string // hostname (optional)
 	Port     int    // port (optional)
 
-	Authenticator      string // snowflake, okta URL, oauth or externalbrowser
+	Authenticator      string // snowflake, okta URL, oauth, externalbrowser, snowflake_jwt or auto
 	Passcode           string
 	PasscodeInPassword bool
 
+	ClientRequestMFAToken bool            // cache the MFA token to skip the MFA prompt at the later logins
+	CredentialStore       CredentialStore // store of the cached tokens. FileCredentialStore if nil.
+
 	LoginTimeout   time.Duration // Login timeout
 	RequestTimeout time.Duration // request timeout
+	QueryTimeout   time.Duration // default statement timeout of the queries without WithStatementTimeout. no timeout if 0.
+
+	MaxRetryDuration time.Duration // maximum time of the attempts of a REST request, if shorter than the timeouts
+
+	BrowserAuthTimeout time.Duration // time to wait for the externalbrowser SSO. 120 seconds by default.
+	BrowserPortMin     int           // first local port of the externalbrowser callback. any free port if 0.
+	BrowserPortMax     int           // last local port of the externalbrowser callback. BrowserPortMin if 0.
 
 	Application  string // application name.
+	QueryComment bool   // prepend a comment with the application name and the fields of WithQueryComment
 	InsecureMode bool   // driver doesn't check certificate revocation status
 
-	Token string // Token to use for OAuth / JWT / other forms of token based auth
+	OCSPFailOpen OCSPFailOpenMode // OCSP check if the revocation status cannot be determined. fail-open if not set.
+
+	Token         string        // Token to use for OAuth / JWT / other forms of token based auth
+	TokenProvider TokenProvider // provides the OAuth access token at every login instead of Token
+
+	PrivateKey     *rsa.PrivateKey   // RSA private key for the snowflake_jwt authenticator
+	PrivateKeyFile string            // PEM file of the private key, PKCS #1 or PKCS #8, if PrivateKey is nil
+	PrivateKeys    []*rsa.PrivateKey // keys tried in order if the JWT of the previous key is rejected, e.g., rotated
+
+	ClientRedirect bool // Host is a Client Redirect connection URL. Follows the failover to another region.
+
+	InvalidUTF8     InvalidUTF8Policy // handling of invalid UTF-8 in string values. passthrough if empty.
+	ColumnNameCase  ColumnNameCase    // case of the column names. asis if empty.
+	SessionReset    SessionResetMode  // reset of the session when database/sql reuses the connection. none if empty.
+	Location        *time.Location    // location of the scanned DATE and TIMESTAMP_NTZ values. UTC if nil.
+	GeoOutputFormat GeoOutputFormat   // format of the GEOGRAPHY and GEOMETRY values. the session parameters if empty.
+	NumberMode      NumberMode        // Go type of the NUMBER values. int64 or float64 if empty.
+	ResultFormat    ResultFormat      // format the query results are fetched in. json if empty.
+	EnableHTTP2     bool              // use HTTP/2 instead of HTTP/1.1. requires Go 1.13 or later.
+
+	HedgeChunkDownloads     bool // send a second request for a slow chunk download and take the first response
+	MaxChunkDownloadWorkers int  // chunks of a large result set downloaded ahead of the rows read. 10 if 0.
+	// MaxChunkBufferBytes caps the bytes of the chunks downloaded ahead of the rows read and held in memory.
+	// Unlimited if 0.
+	MaxChunkBufferBytes int64
+	ChunkSpillDir       string // directory where the chunks over MaxChunkBufferBytes are spilled. deferred if empty.
+
+	UploadPartSize     int64 // the files to S3 and the streams larger are uploaded in parts of the size, at least 5MB. 64MB if 0.
+	UploadPartParallel int   // parts of a file uploaded at once. 4 if 0.
+
+	MaxConcurrentQueries int // maximum in-flight queries of the connection. 0 is unlimited.
+
+	DisableHeartbeat bool // don't send the heartbeats that keep the session of an idle connection alive
+	DisableTelemetry bool // don't send the client telemetry events to Snowflake
+
+	DisableQueryContextCache bool // don't keep and send the query context returned by the queries
+
+	CircuitBreakerThreshold int           // consecutive availability failures to open the circuit. 0 disables it.
+	CircuitBreakerCooldown  time.Duration // time the circuit stays open before a probe. 30 seconds if 0.
+
+	ResultCacheTTL time.Duration // time the results of the SELECT queries are reused. 0 disables it.
+
+	CredentialProviders []CredentialProvider // credential provider chain for the auto authenticator
+
+	OnSessionOpen  SessionHook // called after the login, e.g., to set up the session. the connection fails on an error.
+	OnSessionClose SessionHook // called before the session is closed.
+
+	Progress *Progress // callbacks of the progress of PUT, GET, result chunks and queries of all statements
+
+	Transport http.RoundTripper // transport of all HTTP requests of the connection instead of the builtin transports
+
+	ProxyHost     string // host of the HTTP proxy of all requests instead of HTTP_PROXY and HTTPS_PROXY
+	ProxyPort     int    // port of the HTTP proxy. required if ProxyHost is set.
+	ProxyUser     string // user of the basic authentication of the proxy
+	ProxyPassword string // password of the basic authentication of the proxy
+	NonProxyHosts string // hosts connected without the proxy separated by |, e.g., localhost|*.internal.example.com
+
+	workloadIdentityProvider string             // AWS, GCP or AZURE for the workload_identity authenticator
+	credentialProvider       CredentialProvider // provider of the credentials resolved for the auto authenticator
+	privateKeyIndex          int                // private key of the key rotation used by the login
+	keyRotation              *keyRotation       // key accepted by the connections sharing the config, if set
 }
 
-// DSN constructs a DSN for Snowflake db.
-func DSN(cfg *Config) (dsn string, err error) {
-	hasHost := true
-	if cfg.Host == "" {
-		hasHost = false
-		if cfg.Region == "" {
-			cfg.Host = cfg.Account + defaultDomain
-		} else {
-			cfg.Host = cfg.Account + "." + cfg.Region + defaultDomain
+// DSN returns the DSN of the config, which ParseDSN parses back to the same config. Unlike the DSN function, it
+// doesn't modify the config, and returns an error if a field can't be represented in DSN, e.g., Transport or a
+// Location other than an IANA time zone.
+func (cfg *Config) DSN() (string, error) {
+	unsupported := func(field string) error {
+		return invalidDSNParameterError(field, errors.New("can't be represented in DSN"))
+	}
+	switch {
+	case cfg.CredentialStore != nil:
+		return "", unsupported("CredentialStore")
+	case cfg.TokenProvider != nil:
+		return "", unsupported("TokenProvider")
+	case cfg.CredentialProviders != nil:
+		return "", unsupported("CredentialProviders")
+	case cfg.Transport != nil:
+		return "", unsupported("Transport")
+	case cfg.OnSessionOpen != nil:
+		return "", unsupported("OnSessionOpen")
+	case cfg.OnSessionClose != nil:
+		return "", unsupported("OnSessionClose")
+	case cfg.Progress != nil:
+		return "", unsupported("Progress")
+	case len(cfg.PrivateKeys) > 0:
+		return "", unsupported("PrivateKeys")
+	}
+	if cfg.Location != nil && cfg.Location != time.UTC {
+		if _, err := time.LoadLocation(cfg.Location.String()); err != nil {
+			return "", invalidDSNParameterError("location", err)
 		}
 	}
-	// in case account includes region
-	posDot := strings.Index(cfg.Account, ".")
-	if posDot > 0 {
-		cfg.Region = cfg.Account[posDot+1:]
-		cfg.Account = cfg.Account[:posDot]
-	}
+	c := *cfg
+	return DSN(&c)
+}
 
+// DSN constructs a DSN for Snowflake db.
+func DSN(cfg *Config) (dsn string, err error) {
+	hasHost := fillHost(cfg)
 	err = fillMissingConfigParameters(cfg)
 	if err != nil {
 		return "", err
@@ -97,21 +193,134 @@ func DSN(cfg *Config) (dsn string, err error) {
 	if cfg.PasscodeInPassword {
 		params.Add("passcodeInPassword", strconv.FormatBool(cfg.PasscodeInPassword))
 	}
+	if cfg.ClientRequestMFAToken {
+		params.Add("clientRequestMfaToken", strconv.FormatBool(cfg.ClientRequestMFAToken))
+	}
 	if cfg.LoginTimeout != defaultLoginTimeout {
-		params.Add("loginTimeout", strconv.FormatInt(int64(cfg.LoginTimeout/time.Second), 10))
+		params.Add("loginTimeout", formatDSNDuration(cfg.LoginTimeout))
 	}
 	if cfg.RequestTimeout != defaultRequestTimeout {
-		params.Add("requestTimeout", strconv.FormatInt(int64(cfg.RequestTimeout/time.Second), 10))
+		params.Add("requestTimeout", formatDSNDuration(cfg.RequestTimeout))
+	}
+	if cfg.QueryTimeout > 0 {
+		params.Add("queryTimeout", formatDSNDuration(cfg.QueryTimeout))
+	}
+	if cfg.MaxRetryDuration > 0 {
+		params.Add("maxRetryDuration", formatDSNDuration(cfg.MaxRetryDuration))
+	}
+	if cfg.BrowserAuthTimeout != defaultBrowserTimeout {
+		params.Add("browserAuthTimeout", formatDSNDuration(cfg.BrowserAuthTimeout))
+	}
+	if cfg.BrowserPortMin > 0 {
+		ports := strconv.Itoa(cfg.BrowserPortMin)
+		if cfg.BrowserPortMax > cfg.BrowserPortMin {
+			ports += "-" + strconv.Itoa(cfg.BrowserPortMax)
+		}
+		params.Add("browserPort", ports)
 	}
 	if cfg.Application != clientType {
 		params.Add("application", cfg.Application)
 	}
+	if cfg.QueryComment {
+		params.Add("queryComment", strconv.FormatBool(cfg.QueryComment))
+	}
+	if cfg.InsecureMode {
+		params.Add("insecureMode", strconv.FormatBool(cfg.InsecureMode))
+	}
 	if cfg.Protocol != "" && cfg.Protocol != "https" {
 		params.Add("protocol", cfg.Protocol)
 	}
 	if cfg.Token != "" {
 		params.Add("token", cfg.Token)
 	}
+	if cfg.PrivateKey != nil {
+		params.Add("privateKey", base64.URLEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(cfg.PrivateKey)))
+	}
+	if cfg.PrivateKeyFile != "" {
+		params.Add("privateKeyFile", cfg.PrivateKeyFile)
+	}
+	if cfg.OCSPFailOpen != OCSPFailOpenNotSet {
+		params.Add("ocspFailOpen", strconv.FormatBool(cfg.OCSPFailOpen == OCSPFailOpenTrue))
+	}
+	if cfg.ProxyHost != "" {
+		params.Add("proxyHost", cfg.ProxyHost)
+		params.Add("proxyPort", strconv.Itoa(cfg.ProxyPort))
+	}
+	if cfg.ProxyUser != "" {
+		params.Add("proxyUser", cfg.ProxyUser)
+	}
+	if cfg.ProxyPassword != "" {
+		params.Add("proxyPassword", cfg.ProxyPassword)
+	}
+	if cfg.NonProxyHosts != "" {
+		params.Add("nonProxyHosts", cfg.NonProxyHosts)
+	}
+	if cfg.ClientRedirect {
+		params.Add("clientRedirect", strconv.FormatBool(cfg.ClientRedirect))
+	}
+	if cfg.InvalidUTF8 != "" && cfg.InvalidUTF8 != InvalidUTF8PassThrough {
+		params.Add("invalidUTF8", string(cfg.InvalidUTF8))
+	}
+	if cfg.ColumnNameCase != "" && cfg.ColumnNameCase != ColumnNameAsIs {
+		params.Add("columnNameCase", string(cfg.ColumnNameCase))
+	}
+	if cfg.SessionReset != "" && cfg.SessionReset != SessionResetNone {
+		params.Add("sessionReset", string(cfg.SessionReset))
+	}
+	if cfg.GeoOutputFormat != "" {
+		params.Add("geoOutputFormat", string(cfg.GeoOutputFormat))
+	}
+	if cfg.NumberMode != "" && cfg.NumberMode != NumberDefault {
+		params.Add("numberMode", string(cfg.NumberMode))
+	}
+	if cfg.ResultFormat != "" && cfg.ResultFormat != ResultFormatJSON {
+		params.Add("resultFormat", string(cfg.ResultFormat))
+	}
+	if cfg.HedgeChunkDownloads {
+		params.Add("hedgeChunkDownloads", strconv.FormatBool(cfg.HedgeChunkDownloads))
+	}
+	if cfg.MaxChunkDownloadWorkers > 0 {
+		params.Add("maxChunkDownloadWorkers", strconv.Itoa(cfg.MaxChunkDownloadWorkers))
+	}
+	if cfg.MaxChunkBufferBytes > 0 {
+		params.Add("maxChunkBufferBytes", strconv.FormatInt(cfg.MaxChunkBufferBytes, 10))
+	}
+	if cfg.ChunkSpillDir != "" {
+		params.Add("chunkSpillDir", cfg.ChunkSpillDir)
+	}
+	if cfg.EnableHTTP2 {
+		params.Add("http2", strconv.FormatBool(cfg.EnableHTTP2))
+	}
+	if cfg.DisableHeartbeat {
+		params.Add("disableHeartbeat", strconv.FormatBool(cfg.DisableHeartbeat))
+	}
+	if cfg.DisableTelemetry {
+		params.Add("disableTelemetry", strconv.FormatBool(cfg.DisableTelemetry))
+	}
+	if cfg.DisableQueryContextCache {
+		params.Add("disableQueryContextCache", strconv.FormatBool(cfg.DisableQueryContextCache))
+	}
+	if cfg.UploadPartSize > 0 {
+		params.Add("uploadPartSize", strconv.FormatInt(cfg.UploadPartSize, 10))
+	}
+	if cfg.UploadPartParallel > 0 {
+		params.Add("uploadPartParallel", strconv.Itoa(cfg.UploadPartParallel))
+	}
+	if cfg.MaxConcurrentQueries > 0 {
+		params.Add("maxConcurrentQueries", strconv.Itoa(cfg.MaxConcurrentQueries))
+	}
+	if cfg.CircuitBreakerThreshold > 0 {
+		params.Add("circuitBreakerThreshold", strconv.Itoa(cfg.CircuitBreakerThreshold))
+	}
+	if cfg.CircuitBreakerCooldown > 0 {
+		params.Add("circuitBreakerCooldown", formatDSNDuration(cfg.CircuitBreakerCooldown))
+	}
+	if cfg.ResultCacheTTL > 0 {
+		params.Add("resultCacheTTL", formatDSNDuration(cfg.ResultCacheTTL))
+	}
+	if cfg.Location != nil && cfg.Location != time.UTC {
+		params.Add("location", cfg.Location.String())
+	}
 	if cfg.Params != nil {
 		for k, v := range cfg.Params {
 			params.Add(k, *v)
@@ -124,6 +333,24 @@ func DSN(cfg *Config) (dsn string, err error) {
 	return
 }
 
+// fillHost sets the host of the account if the host is not set, and splits the region included in the account.
+// The region is added to the host by fillMissingConfigParameters. It returns false if the host is not set.
+func fillHost(cfg *Config) bool {
+	hasHost := cfg.Host != ""
+	if !hasHost && cfg.Account != "" {
+		cfg.Host = accountHost(cfg.Account)
+	}
+	// in case account includes region
+	posDot := strings.Index(cfg.Account, ".")
+	if posDot > 0 {
+		if cfg.Region == "" {
+			cfg.Region = cfg.Account[posDot+1:]
+		}
+		cfg.Account = cfg.Account[:posDot]
+	}
+	return hasHost
+}
+
 // ParseDSN parses the DSN string to a Config.
 func ParseDSN(dsn string) (cfg *Config, err error) {
 	// New config with some default values
@@ -180,11 +407,21 @@ func ParseDSN(dsn string) (cfg *Config, err error) {
 			if err != nil {
 				return
 			}
+			// the database and schema in the path are escaped. the parameters are unescaped by parseDSNParams.
 			if secondSlash {
-				cfg.Database = dsn[posSecondSlash+1 : i]
-				cfg.Schema = dsn[i+1 : posQuestion]
+				cfg.Database, err = url.QueryUnescape(dsn[posSecondSlash+1 : i])
+				if err != nil {
+					return nil, err
+				}
+				cfg.Schema, err = url.QueryUnescape(dsn[i+1 : posQuestion])
+				if err != nil {
+					return nil, err
+				}
 			} else {
-				cfg.Database = dsn[posSecondSlash+1 : posQuestion]
+				cfg.Database, err = url.QueryUnescape(dsn[posSecondSlash+1 : posQuestion])
+				if err != nil {
+					return nil, err
+				}
 			}
 			done = true
 		case dsn[i] == '?':
@@ -217,19 +454,20 @@ func ParseDSN(dsn string) (cfg *Config, err error) {
 			return
 		}
 	}
-	if cfg.Account == "" && strings.HasSuffix(cfg.Host, defaultDomain) {
+	if cfg.Account == "" && hostDomain(cfg.Host) != "" {
 		posDot := strings.Index(cfg.Host, ".")
 		if posDot > 0 {
 			cfg.Account = cfg.Host[:posDot]
 		}
 	}
+	fillHost(cfg)
 
 	err = fillMissingConfigParameters(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// unescape parameters
+	// unescape the user and password. the parameters are unescaped by parseDSNParams.
 	var s string
 	s, err = url.QueryUnescape(cfg.User)
 	if err != nil {
@@ -241,26 +479,6 @@ func ParseDSN(dsn string) (cfg *Config, err error) {
 		return nil, err
 	}
 	cfg.Password = s
-	s, err = url.QueryUnescape(cfg.Database)
-	if err != nil {
-		return nil, err
-	}
-	cfg.Database = s
-	s, err = url.QueryUnescape(cfg.Schema)
-	if err != nil {
-		return nil, err
-	}
-	cfg.Schema = s
-	s, err = url.QueryUnescape(cfg.Role)
-	if err != nil {
-		return nil, err
-	}
-	cfg.Role = s
-	s, err = url.QueryUnescape(cfg.Warehouse)
-	if err != nil {
-		return nil, err
-	}
-	cfg.Warehouse = s
 	return cfg, nil
 }
 
@@ -271,14 +489,33 @@ func fillMissingConfigParameters(cfg *Config) error {
 	if strings.Trim(cfg.Account, " ") == "" {
 		return ErrEmptyAccount
 	}
+	if strings.Contains(cfg.Authenticator, "://") && !isOktaURL(cfg.Authenticator) {
+		// the password is sent to the URL
+		return &SnowflakeError{
+			Number:      ErrCodeInvalidOktaURL,
+			Message:     errMsgInvalidOktaURL,
+			MessageArgs: []interface{}{cfg.Authenticator},
+		}
+	}
+	if err := checkAuthenticator(cfg.Authenticator); err != nil {
+		return err
+	}
 	authenticator := strings.ToUpper(cfg.Authenticator)
+	if authenticator == authenticatorAuto || authenticator == authenticatorWorkloadIdentity {
+		// credentials are resolved by the credential providers
+		authenticator = authenticatorOAuth
+	}
 
 	if authenticator != authenticatorOAuth && strings.Trim(cfg.User, " ") == "" {
 		// oauth does not require a username
 		return ErrEmptyUsername
 	}
 
-	if authenticator != authenticatorExternalBrowser && authenticator != authenticatorOAuth && strings.Trim(cfg.Password, " ") == "" {
+	if authenticator == authenticatorJWT {
+		if numPrivateKeys(cfg) == 0 {
+			return ErrEmptyPrivateKey
+		}
+	} else if authenticator != authenticatorExternalBrowser && authenticator != authenticatorOAuth && strings.Trim(cfg.Password, " ") == "" {
 		// no password parameter is required for EXTERNALBROWSER and OAUTH.
 		return ErrEmptyPassword
 	}
@@ -292,12 +529,22 @@ func fillMissingConfigParameters(cfg *Config) error {
 	cfg.Region = strings.Trim(cfg.Region, " ")
 	if cfg.Region != "" {
 		// region is specified but not included in Host
-		i := strings.Index(cfg.Host, defaultDomain)
-		if i >= 1 {
-			hostPrefix := cfg.Host[0:i]
-			if !strings.HasSuffix(hostPrefix, cfg.Region) {
-				cfg.Host = hostPrefix + "." + cfg.Region + defaultDomain
-			}
+		if err := fillRegionHost(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.ProxyHost != "" && (cfg.ProxyPort <= 0 || cfg.ProxyPort > 65535) {
+		return &SnowflakeError{
+			Number:      ErrCodeInvalidProxy,
+			Message:     errMsgInvalidProxy,
+			MessageArgs: []interface{}{"proxyPort must be 1-65535: " + strconv.Itoa(cfg.ProxyPort)},
+		}
+	}
+	if cfg.ProxyHost == "" && cfg.ProxyUser != "" {
+		return &SnowflakeError{
+			Number:      ErrCodeInvalidProxy,
+			Message:     errMsgInvalidProxy,
+			MessageArgs: []interface{}{"proxyUser requires proxyHost"},
 		}
 	}
 	if cfg.LoginTimeout == 0 {
@@ -306,10 +553,13 @@ func fillMissingConfigParameters(cfg *Config) error {
 	if cfg.RequestTimeout == 0 {
 		cfg.RequestTimeout = defaultRequestTimeout
 	}
+	if cfg.BrowserAuthTimeout == 0 {
+		cfg.BrowserAuthTimeout = defaultBrowserTimeout
+	}
 	if strings.Trim(cfg.Application, " ") == "" {
 		cfg.Application = clientType
 	}
-	if strings.HasSuffix(cfg.Host, defaultDomain) && len(cfg.Host) == len(defaultDomain) {
+	if d := hostDomain(cfg.Host); d != "" && len(cfg.Host) == len(d) {
 		return &SnowflakeError{
 			Number:      ErrCodeFailedToParseHost,
 			Message:     errMsgFailedToParseHost,
@@ -321,16 +571,12 @@ func fillMissingConfigParameters(cfg *Config) error {
 
 // transformAccountToHost transforms host to accout name
 func transformAccountToHost(cfg *Config) (err error) {
-	if cfg.Port == 0 && !strings.HasSuffix(cfg.Host, defaultDomain) && cfg.Host != "" {
+	if cfg.Port == 0 && hostDomain(cfg.Host) == "" && cfg.Host != "" {
 		// account name is specified instead of host:port
 		cfg.Account = cfg.Host
-		cfg.Host = cfg.Account + defaultDomain
+		cfg.Host = ""
 		cfg.Port = 443
-		posDot := strings.Index(cfg.Account, ".")
-		if posDot > 0 {
-			cfg.Region = cfg.Account[posDot+1:]
-			cfg.Account = cfg.Account[:posDot]
-		}
+		fillHost(cfg)
 	}
 	return nil
 }
@@ -396,57 +642,310 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 		if err != nil {
 			return err
 		}
-		switch param[0] {
-		// Disable INFILE whitelist / enable all files
-		case "account":
-			cfg.Account = value
-		case "warehouse":
-			cfg.Warehouse = value
-		case "database":
-			cfg.Database = value
-		case "schema":
-			cfg.Schema = value
-		case "role":
-			cfg.Role = value
-		case "region":
-			cfg.Region = value
-		case "protocol":
-			cfg.Protocol = value
-		case "passcode":
-			cfg.Passcode = value
-		case "passcodeInPassword":
-			var vv bool
-			vv, err = strconv.ParseBool(value)
-			if err != nil {
-				return
-			}
-			cfg.PasscodeInPassword = vv
-		case "loginTimeout":
-			var vv int64
-			vv, err = strconv.ParseInt(value, 10, 64)
-			if err != nil {
-				return
-			}
-			cfg.LoginTimeout = time.Duration(vv * int64(time.Second))
-		case "application":
-			cfg.Application = value
-		case "authenticator":
-			cfg.Authenticator = strings.ToLower(value)
-		case "insecureMode":
-			var vv bool
-			vv, err = strconv.ParseBool(value)
-			if err != nil {
-				return
-			}
-			cfg.InsecureMode = vv
-		case "token":
-			cfg.Token = value
-		default:
-			if cfg.Params == nil {
-				cfg.Params = make(map[string]*string)
-			}
-			cfg.Params[param[0]] = &value
+		if err = parseDSNParam(cfg, param[0], value); err != nil {
+			return invalidDSNParameterError(param[0], err)
 		}
 	}
 	return
 }
+
+// invalidDSNParameterError returns the error of the parameter, or err as is if it's already a SnowflakeError.
+func invalidDSNParameterError(name string, err error) error {
+	if _, ok := err.(*SnowflakeError); ok {
+		return err
+	}
+	return &SnowflakeError{
+		Number:      ErrCodeInvalidDSNParameter,
+		Message:     errMsgInvalidDSNParameter,
+		MessageArgs: []interface{}{name, err},
+		Err:         err,
+	}
+}
+
+// parseDSNParam sets the parameter of the DSN to the config. Unknown parameters are session parameters.
+func parseDSNParam(cfg *Config, name string, value string) (err error) {
+	switch name {
+	// Disable INFILE whitelist / enable all files
+	case "account":
+		cfg.Account = value
+	case "warehouse":
+		cfg.Warehouse = value
+	case "database":
+		cfg.Database = value
+	case "schema":
+		cfg.Schema = value
+	case "role":
+		cfg.Role = value
+	case "region":
+		cfg.Region = value
+	case "protocol":
+		cfg.Protocol = value
+	case "passcode":
+		cfg.Passcode = value
+	case "passcodeInPassword":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.PasscodeInPassword = vv
+	case "loginTimeout":
+		cfg.LoginTimeout, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "clientRequestMfaToken":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.ClientRequestMFAToken = vv
+	case "requestTimeout":
+		cfg.RequestTimeout, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "queryTimeout":
+		cfg.QueryTimeout, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "maxRetryDuration":
+		cfg.MaxRetryDuration, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "browserAuthTimeout":
+		cfg.BrowserAuthTimeout, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "browserPort":
+		cfg.BrowserPortMin, cfg.BrowserPortMax, err = parsePortRange(value)
+		if err != nil {
+			return
+		}
+	case "application":
+		cfg.Application = value
+	case "authenticator":
+		cfg.Authenticator = strings.ToLower(value)
+	case "queryComment":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.QueryComment = vv
+	case "insecureMode":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.InsecureMode = vv
+	case "ocspFailOpen":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		if vv {
+			cfg.OCSPFailOpen = OCSPFailOpenTrue
+		} else {
+			cfg.OCSPFailOpen = OCSPFailOpenFalse
+		}
+	case "token":
+		cfg.Token = value
+	case "privateKey":
+		var der []byte
+		if der, err = base64.URLEncoding.DecodeString(value); err != nil {
+			return privateKeyError(err)
+		}
+		if cfg.PrivateKey, err = parsePrivateKey(der); err != nil {
+			return
+		}
+	case "privateKeyFile":
+		cfg.PrivateKeyFile = value
+	case "proxyHost":
+		cfg.ProxyHost = value
+	case "proxyPort":
+		cfg.ProxyPort, err = strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+	case "proxyUser":
+		cfg.ProxyUser = value
+	case "proxyPassword":
+		cfg.ProxyPassword = value
+	case "nonProxyHosts":
+		cfg.NonProxyHosts = value
+	case "clientRedirect":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.ClientRedirect = vv
+	case "invalidUTF8":
+		cfg.InvalidUTF8, err = parseInvalidUTF8Policy(value)
+		if err != nil {
+			return
+		}
+	case "columnNameCase":
+		cfg.ColumnNameCase, err = parseColumnNameCase(value)
+		if err != nil {
+			return
+		}
+	case "sessionReset":
+		cfg.SessionReset, err = parseSessionResetMode(value)
+		if err != nil {
+			return
+		}
+	case "geoOutputFormat":
+		cfg.GeoOutputFormat, err = parseGeoOutputFormat(value)
+		if err != nil {
+			return
+		}
+	case "numberMode":
+		cfg.NumberMode, err = parseNumberMode(value)
+		if err != nil {
+			return
+		}
+	case "resultFormat":
+		cfg.ResultFormat, err = parseResultFormat(value)
+		if err != nil {
+			return
+		}
+	case "hedgeChunkDownloads":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.HedgeChunkDownloads = vv
+	case "http2":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.EnableHTTP2 = vv
+	case "maxChunkDownloadWorkers":
+		cfg.MaxChunkDownloadWorkers, err = strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+	case "maxChunkBufferBytes":
+		cfg.MaxChunkBufferBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return
+		}
+	case "chunkSpillDir":
+		cfg.ChunkSpillDir = value
+	case "disableHeartbeat":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.DisableHeartbeat = vv
+	case "disableTelemetry":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.DisableTelemetry = vv
+	case "disableQueryContextCache":
+		var vv bool
+		vv, err = strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		cfg.DisableQueryContextCache = vv
+	case "uploadPartSize":
+		cfg.UploadPartSize, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return
+		}
+		if cfg.UploadPartSize < minUploadPartSize {
+			return fmt.Errorf("must be at least %v bytes", minUploadPartSize)
+		}
+	case "uploadPartParallel":
+		cfg.UploadPartParallel, err = strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+	case "maxConcurrentQueries":
+		cfg.MaxConcurrentQueries, err = strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+	case "circuitBreakerThreshold":
+		cfg.CircuitBreakerThreshold, err = strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+	case "circuitBreakerCooldown":
+		cfg.CircuitBreakerCooldown, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "resultCacheTTL":
+		cfg.ResultCacheTTL, err = parseDSNDuration(value)
+		if err != nil {
+			return
+		}
+	case "location":
+		cfg.Location, err = time.LoadLocation(value)
+		if err != nil {
+			return
+		}
+	default:
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]*string)
+		}
+		cfg.Params[name] = &value
+	}
+	return
+}
+
+// parseDSNDuration parses a duration in seconds, or in the format of time.ParseDuration, e.g., 1500ms.
+func parseDSNDuration(value string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("not seconds or a duration: %v", value)
+	}
+	return d, nil
+}
+
+// formatDSNDuration formats the duration in seconds, or in the format of time.Duration if it's not whole
+// seconds.
+func formatDSNDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10)
+	}
+	return d.String()
+}
+
+// parsePortRange parses a port number or an inclusive range of port numbers, e.g., 8001-8010.
+func parsePortRange(value string) (int, int, error) {
+	ports := strings.SplitN(value, "-", 2)
+	minPort, err := strconv.Atoi(ports[0])
+	maxPort := minPort
+	if err == nil && len(ports) == 2 {
+		maxPort, err = strconv.Atoi(ports[1])
+	}
+	if err != nil || minPort <= 0 || maxPort < minPort || maxPort > 65535 {
+		return 0, 0, &SnowflakeError{
+			Number:      ErrCodeFailedToParsePort,
+			Message:     errMsgFailedToParsePort,
+			MessageArgs: []interface{}{value},
+		}
+	}
+	return minPort, maxPort, nil
+}
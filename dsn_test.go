@@ -1,6 +1,9 @@
 package gosnowflake
 
 import (
+	"context"
+	"crypto/rsa"
+	"net/http"
 	"net/url"
 	"reflect"
 	"testing"
@@ -48,6 +51,341 @@ func TestParseDSN(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			dsn: "user:pass@org-conn.snowflakecomputing.com:443?account=ac&clientRedirect=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "org-conn.snowflakecomputing.com", Port: 443,
+				ClientRedirect: true,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&invalidUTF8=replace",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				InvalidUTF8: InvalidUTF8Replace,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&hedgeChunkDownloads=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				HedgeChunkDownloads: true,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&http2=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				EnableHTTP2: true,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&maxChunkDownloadWorkers=4",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				MaxChunkDownloadWorkers: 4,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&maxChunkBufferBytes=67108864&chunkSpillDir=%2Ftmp%2Fspill",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				MaxChunkBufferBytes: 64 << 20, ChunkSpillDir: "/tmp/spill",
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&disableHeartbeat=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				DisableHeartbeat: true,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&disableTelemetry=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				DisableTelemetry: true,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&disableQueryContextCache=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				DisableQueryContextCache: true,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&maxRetryDuration=90",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				MaxRetryDuration: 90 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&queryTimeout=30",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				QueryTimeout: 30 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&ocspFailOpen=false",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				OCSPFailOpen: OCSPFailOpenFalse,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&ocspFailOpen=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				OCSPFailOpen: OCSPFailOpenTrue,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&uploadPartSize=10485760&uploadPartParallel=8",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				UploadPartSize: 10 << 20, UploadPartParallel: 8,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&maxConcurrentQueries=4",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				MaxConcurrentQueries: 4,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&circuitBreakerThreshold=5&circuitBreakerCooldown=10",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				CircuitBreakerThreshold: 5, CircuitBreakerCooldown: 10 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&resultCacheTTL=300",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				ResultCacheTTL: 5 * time.Minute,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&authenticator=externalbrowser&browserPort=8001-8010&browserAuthTimeout=30",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				BrowserPortMin: 8001, BrowserPortMax: 8010, BrowserAuthTimeout: 30 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&browserPort=8001",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				BrowserPortMin: 8001, BrowserPortMax: 8001,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&clientRequestMfaToken=true",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				ClientRequestMFAToken: true,
+			},
+			err: nil,
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&authenticator=http%3A%2F%2Ftestaccount.okta.com",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidOktaURL},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&authenticator=externalbrowsr",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeUnknownAuthenticator},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&authenticator=okta",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeUnknownAuthenticator},
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&proxyHost=proxy.example.com&proxyPort=3128&proxyUser=pu&proxyPassword=p%40ss&nonProxyHosts=localhost%7C*.internal",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				ProxyHost: "proxy.example.com", ProxyPort: 3128, ProxyUser: "pu", ProxyPassword: "p@ss",
+				NonProxyHosts: "localhost|*.internal",
+			},
+			err: nil,
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&proxyHost=proxy.example.com",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidProxy},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&proxyUser=pu",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidProxy},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&browserPort=8010-8001",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeFailedToParsePort},
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&location=UTC",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				Location: time.UTC,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&columnNameCase=normalize",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				ColumnNameCase: ColumnNameNormalize,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&sessionReset=full",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				SessionReset: SessionResetFull,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&geoOutputFormat=wkb",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				GeoOutputFormat: GeoOutputWKB,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&numberMode=big",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				NumberMode: NumberBig,
+			},
+			err: nil,
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&resultFormat=arrow",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				ResultFormat: ResultFormatArrow,
+			},
+			err: nil,
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&resultFormat=csv",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&geoOutputFormat=kml",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&invalidUTF8=drop",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&sessionReset=all",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&uploadPartSize=1024",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&loginTimeout=abc",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&insecureMode=maybe",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&disableQueryContextCache=maybe",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeInvalidDSNParameter},
+		},
+		{
+			dsn:    "user:pass@host:123?account=ac&browserPort=0",
+			config: &Config{},
+			err:    &SnowflakeError{Number: ErrCodeFailedToParsePort},
+		},
+		{
+			dsn: "user:pass@host:123?account=ac&requestTimeout=1500ms&loginTimeout=30&",
+			config: &Config{
+				Account: "ac", User: "user", Password: "pass",
+				Protocol: "https", Host: "host", Port: 123,
+				RequestTimeout: 1500 * time.Millisecond, LoginTimeout: 30 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			dsn: "@host:123?account=ac&authenticator=auto",
+			config: &Config{
+				Account: "ac", Protocol: "https", Host: "host", Port: 123,
+			},
+			err: nil,
+		},
 		{
 			dsn: "user:pass@host:123/db/schema?account=ac&protocol=http",
 			config: &Config{
@@ -122,12 +460,12 @@ func TestParseDSN(t *testing.T) {
 			},
 		},
 		{
-			dsn: "u:p@a?database=d&schema=s&role=r&application=aa&authenticator=snowflake&insecureMode=true&passcode=pp&passcodeInPassword=true",
+			dsn: "u:p@a?database=d&schema=s&role=r&application=aa&authenticator=snowflake&insecureMode=true&passcode=pp&passcodeInPassword=true&queryComment=true",
 			config: &Config{
 				Account: "a", User: "u", Password: "p",
 				Protocol: "https", Host: "a.snowflakecomputing.com", Port: 443,
 				Database: "d", Schema: "s", Role: "r", Authenticator: "snowflake", Application: "aa",
-				InsecureMode: true, Passcode: "pp", PasscodeInPassword: true,
+				InsecureMode: true, Passcode: "pp", PasscodeInPassword: true, QueryComment: true,
 			},
 			err: nil,
 		},
@@ -201,6 +539,14 @@ func TestParseDSN(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			dsn: "u:p@/D%2BB/S+C?account=ac&role=R%2B1&warehouse=WH%2520X",
+			config: &Config{
+				Account: "ac", User: "u", Password: "p", Database: "D+B", Schema: "S C", Role: "R+1", Warehouse: "WH%20X",
+				Protocol: "https", Host: "ac.snowflakecomputing.com", Port: 443,
+			},
+			err: nil,
+		},
 	}
 	for i, test := range testcases {
 		glog.V(2).Infof("#%v\n", i)
@@ -254,6 +600,133 @@ func TestParseDSN(t *testing.T) {
 				t.Fatalf("%d: Failed to match passcodeInPassword. expected: %v, got: %v",
 					i, test.config.PasscodeInPassword, cfg.PasscodeInPassword)
 			}
+			if test.config.HedgeChunkDownloads != cfg.HedgeChunkDownloads {
+				t.Fatalf("%d: Failed to match hedgeChunkDownloads. expected: %v, got: %v",
+					i, test.config.HedgeChunkDownloads, cfg.HedgeChunkDownloads)
+			}
+			if test.config.EnableHTTP2 != cfg.EnableHTTP2 {
+				t.Fatalf("%d: Failed to match http2. expected: %v, got: %v",
+					i, test.config.EnableHTTP2, cfg.EnableHTTP2)
+			}
+			if test.config.MaxChunkDownloadWorkers != cfg.MaxChunkDownloadWorkers {
+				t.Fatalf("%d: Failed to match maxChunkDownloadWorkers. expected: %v, got: %v",
+					i, test.config.MaxChunkDownloadWorkers, cfg.MaxChunkDownloadWorkers)
+			}
+			if test.config.MaxChunkBufferBytes != cfg.MaxChunkBufferBytes {
+				t.Fatalf("%d: Failed to match maxChunkBufferBytes. expected: %v, got: %v",
+					i, test.config.MaxChunkBufferBytes, cfg.MaxChunkBufferBytes)
+			}
+			if test.config.ChunkSpillDir != cfg.ChunkSpillDir {
+				t.Fatalf("%d: Failed to match chunkSpillDir. expected: %v, got: %v",
+					i, test.config.ChunkSpillDir, cfg.ChunkSpillDir)
+			}
+			if test.config.DisableHeartbeat != cfg.DisableHeartbeat {
+				t.Fatalf("%d: Failed to match disableHeartbeat. expected: %v, got: %v",
+					i, test.config.DisableHeartbeat, cfg.DisableHeartbeat)
+			}
+			if test.config.DisableTelemetry != cfg.DisableTelemetry {
+				t.Fatalf("%d: Failed to match disableTelemetry. expected: %v, got: %v",
+					i, test.config.DisableTelemetry, cfg.DisableTelemetry)
+			}
+			if test.config.DisableQueryContextCache != cfg.DisableQueryContextCache {
+				t.Fatalf("%d: Failed to match disableQueryContextCache. expected: %v, got: %v",
+					i, test.config.DisableQueryContextCache, cfg.DisableQueryContextCache)
+			}
+			if test.config.ProxyHost != cfg.ProxyHost || test.config.ProxyPort != cfg.ProxyPort ||
+				test.config.ProxyUser != cfg.ProxyUser || test.config.ProxyPassword != cfg.ProxyPassword ||
+				test.config.NonProxyHosts != cfg.NonProxyHosts {
+				t.Fatalf("%d: Failed to match proxy. expected: %v:%v %v %v %v, got: %v:%v %v %v %v", i,
+					test.config.ProxyHost, test.config.ProxyPort, test.config.ProxyUser, test.config.ProxyPassword, test.config.NonProxyHosts,
+					cfg.ProxyHost, cfg.ProxyPort, cfg.ProxyUser, cfg.ProxyPassword, cfg.NonProxyHosts)
+			}
+			if test.config.MaxRetryDuration != cfg.MaxRetryDuration {
+				t.Fatalf("%d: Failed to match maxRetryDuration. expected: %v, got: %v",
+					i, test.config.MaxRetryDuration, cfg.MaxRetryDuration)
+			}
+			if test.config.QueryTimeout != cfg.QueryTimeout {
+				t.Fatalf("%d: Failed to match queryTimeout. expected: %v, got: %v",
+					i, test.config.QueryTimeout, cfg.QueryTimeout)
+			}
+			if test.config.OCSPFailOpen != cfg.OCSPFailOpen {
+				t.Fatalf("%d: Failed to match ocspFailOpen. expected: %v, got: %v",
+					i, test.config.OCSPFailOpen, cfg.OCSPFailOpen)
+			}
+			if test.config.UploadPartSize != cfg.UploadPartSize || test.config.UploadPartParallel != cfg.UploadPartParallel {
+				t.Fatalf("%d: Failed to match uploadPartSize and uploadPartParallel. expected: %v, %v, got: %v, %v",
+					i, test.config.UploadPartSize, test.config.UploadPartParallel, cfg.UploadPartSize, cfg.UploadPartParallel)
+			}
+			if test.config.MaxConcurrentQueries != cfg.MaxConcurrentQueries {
+				t.Fatalf("%d: Failed to match maxConcurrentQueries. expected: %v, got: %v",
+					i, test.config.MaxConcurrentQueries, cfg.MaxConcurrentQueries)
+			}
+			if test.config.CircuitBreakerThreshold != cfg.CircuitBreakerThreshold {
+				t.Fatalf("%d: Failed to match circuitBreakerThreshold. expected: %v, got: %v",
+					i, test.config.CircuitBreakerThreshold, cfg.CircuitBreakerThreshold)
+			}
+			if test.config.CircuitBreakerCooldown != cfg.CircuitBreakerCooldown {
+				t.Fatalf("%d: Failed to match circuitBreakerCooldown. expected: %v, got: %v",
+					i, test.config.CircuitBreakerCooldown, cfg.CircuitBreakerCooldown)
+			}
+			if test.config.ResultCacheTTL != cfg.ResultCacheTTL {
+				t.Fatalf("%d: Failed to match resultCacheTTL. expected: %v, got: %v",
+					i, test.config.ResultCacheTTL, cfg.ResultCacheTTL)
+			}
+			if test.config.QueryComment != cfg.QueryComment {
+				t.Fatalf("%d: Failed to match queryComment. expected: %v, got: %v",
+					i, test.config.QueryComment, cfg.QueryComment)
+			}
+			if test.config.ClientRequestMFAToken != cfg.ClientRequestMFAToken {
+				t.Fatalf("%d: Failed to match clientRequestMfaToken. expected: %v, got: %v",
+					i, test.config.ClientRequestMFAToken, cfg.ClientRequestMFAToken)
+			}
+			if test.config.BrowserPortMin != cfg.BrowserPortMin || test.config.BrowserPortMax != cfg.BrowserPortMax {
+				t.Fatalf("%d: Failed to match browserPort. expected: %v-%v, got: %v-%v",
+					i, test.config.BrowserPortMin, test.config.BrowserPortMax, cfg.BrowserPortMin, cfg.BrowserPortMax)
+			}
+			if test.config.LoginTimeout != 0 && test.config.LoginTimeout != cfg.LoginTimeout {
+				t.Fatalf("%d: Failed to match loginTimeout. expected: %v, got: %v",
+					i, test.config.LoginTimeout, cfg.LoginTimeout)
+			}
+			if test.config.RequestTimeout != cfg.RequestTimeout {
+				t.Fatalf("%d: Failed to match requestTimeout. expected: %v, got: %v",
+					i, test.config.RequestTimeout, cfg.RequestTimeout)
+			}
+			if test.config.BrowserAuthTimeout != 0 && test.config.BrowserAuthTimeout != cfg.BrowserAuthTimeout {
+				t.Fatalf("%d: Failed to match browserAuthTimeout. expected: %v, got: %v",
+					i, test.config.BrowserAuthTimeout, cfg.BrowserAuthTimeout)
+			}
+			if test.config.Location != cfg.Location {
+				t.Fatalf("%d: Failed to match location. expected: %v, got: %v",
+					i, test.config.Location, cfg.Location)
+			}
+			if test.config.ColumnNameCase != cfg.ColumnNameCase {
+				t.Fatalf("%d: Failed to match columnNameCase. expected: %v, got: %v",
+					i, test.config.ColumnNameCase, cfg.ColumnNameCase)
+			}
+			if test.config.NumberMode != cfg.NumberMode {
+				t.Fatalf("%d: Failed to match numberMode. expected: %v, got: %v",
+					i, test.config.NumberMode, cfg.NumberMode)
+			}
+			if test.config.ResultFormat != cfg.ResultFormat {
+				t.Fatalf("%d: Failed to match resultFormat. expected: %v, got: %v",
+					i, test.config.ResultFormat, cfg.ResultFormat)
+			}
+			if test.config.GeoOutputFormat != cfg.GeoOutputFormat {
+				t.Fatalf("%d: Failed to match geoOutputFormat. expected: %v, got: %v",
+					i, test.config.GeoOutputFormat, cfg.GeoOutputFormat)
+			}
+			if test.config.SessionReset != cfg.SessionReset {
+				t.Fatalf("%d: Failed to match sessionReset. expected: %v, got: %v",
+					i, test.config.SessionReset, cfg.SessionReset)
+			}
+			if test.config.InvalidUTF8 != cfg.InvalidUTF8 {
+				t.Fatalf("%d: Failed to match invalidUTF8. expected: %v, got: %v",
+					i, test.config.InvalidUTF8, cfg.InvalidUTF8)
+			}
+			if test.config.ClientRedirect != cfg.ClientRedirect {
+				t.Fatalf("%d: Failed to match clientRedirect. expected: %v, got: %v",
+					i, test.config.ClientRedirect, cfg.ClientRedirect)
+			}
 		case test.err != nil:
 			driverErrE, okE := test.err.(*SnowflakeError)
 			driverErrG, okG := err.(*SnowflakeError)
@@ -337,14 +810,14 @@ func TestDSN(t *testing.T) {
 				Schema:             "sc",
 				Role:               "ro",
 				Region:             "b",
-				Authenticator:      "au",
+				Authenticator:      "externalbrowser",
 				Passcode:           "db",
 				PasscodeInPassword: true,
 				LoginTimeout:       10 * time.Second,
 				RequestTimeout:     300 * time.Second,
 				Application:        "special go",
 			},
-			dsn: "u:p@a.b.snowflakecomputing.com:443?application=special+go&authenticator=au&database=db&loginTimeout=10&passcode=db&passcodeInPassword=true&region=b&requestTimeout=300&role=ro&schema=sc",
+			dsn: "u:p@a.b.snowflakecomputing.com:443?application=special+go&authenticator=externalbrowser&database=db&loginTimeout=10&passcode=db&passcodeInPassword=true&region=b&requestTimeout=300&role=ro&schema=sc",
 		},
 		{
 			cfg: &Config{
@@ -368,6 +841,242 @@ func TestDSN(t *testing.T) {
 			},
 			dsn: "u:%3A%40abc@a.e.snowflakecomputing.com:443?TIMESTAMP_OUTPUT_FORMAT=MM-DD-YYYY&region=e",
 		},
+		{
+			cfg: &Config{
+				User:           "u",
+				Password:       "p",
+				Account:        "a",
+				Host:           "org-conn.snowflakecomputing.com",
+				ClientRedirect: true,
+			},
+			dsn: "u:p@org-conn.snowflakecomputing.com:443?account=a&clientRedirect=true",
+		},
+		{
+			cfg: &Config{
+				User:        "u",
+				Password:    "p",
+				Account:     "a",
+				InvalidUTF8: InvalidUTF8Error,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?invalidUTF8=error",
+		},
+		{
+			cfg: &Config{
+				User:           "u",
+				Password:       "p",
+				Account:        "a",
+				ColumnNameCase: ColumnNameLower,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?columnNameCase=lower",
+		},
+		{
+			cfg: &Config{
+				User:            "u",
+				Password:        "p",
+				Account:         "a",
+				GeoOutputFormat: GeoOutputEWKT,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?geoOutputFormat=EWKT",
+		},
+		{
+			cfg: &Config{
+				User:         "u",
+				Password:     "p",
+				Account:      "a",
+				SessionReset: SessionResetVariables,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?sessionReset=variables",
+		},
+		{
+			cfg: &Config{
+				User:       "u",
+				Password:   "p",
+				Account:    "a",
+				NumberMode: NumberString,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?numberMode=string",
+		},
+		{
+			cfg: &Config{
+				User:         "u",
+				Password:     "p",
+				Account:      "a",
+				ResultFormat: ResultFormatArrow,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?resultFormat=arrow",
+		},
+		{
+			cfg: &Config{
+				User:     "u",
+				Password: "p",
+				Account:  "a",
+				Location: time.FixedZone("Asia/Tokyo", 9*3600),
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?location=Asia%2FTokyo",
+		},
+		{
+			cfg: &Config{
+				User:        "u",
+				Password:    "p",
+				Account:     "a",
+				EnableHTTP2: true,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?http2=true",
+		},
+		{
+			cfg: &Config{
+				User:                    "u",
+				Password:                "p",
+				Account:                 "a",
+				CircuitBreakerThreshold: 3,
+				CircuitBreakerCooldown:  time.Minute,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?circuitBreakerCooldown=60&circuitBreakerThreshold=3",
+		},
+		{
+			cfg: &Config{
+				User:                    "u",
+				Password:                "p",
+				Account:                 "a",
+				MaxChunkDownloadWorkers: 16,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?maxChunkDownloadWorkers=16",
+		},
+		{
+			cfg: &Config{
+				User:                "u",
+				Password:            "p",
+				Account:             "a",
+				MaxChunkBufferBytes: 1048576,
+				ChunkSpillDir:       "/tmp/spill",
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?chunkSpillDir=%2Ftmp%2Fspill&maxChunkBufferBytes=1048576",
+		},
+		{
+			cfg: &Config{
+				User:             "u",
+				Password:         "p",
+				Account:          "a",
+				DisableHeartbeat: true,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?disableHeartbeat=true",
+		},
+		{
+			cfg: &Config{
+				User:             "u",
+				Password:         "p",
+				Account:          "a",
+				DisableTelemetry: true,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?disableTelemetry=true",
+		},
+		{
+			cfg: &Config{
+				User:                     "u",
+				Password:                 "p",
+				Account:                  "a",
+				DisableQueryContextCache: true,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?disableQueryContextCache=true",
+		},
+		{
+			cfg: &Config{
+				User:             "u",
+				Password:         "p",
+				Account:          "a",
+				MaxRetryDuration: 2 * time.Minute,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?maxRetryDuration=120",
+		},
+		{
+			cfg: &Config{
+				User:         "u",
+				Password:     "p",
+				Account:      "a",
+				QueryTimeout: 90 * time.Second,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?queryTimeout=90",
+		},
+		{
+			cfg: &Config{
+				User:         "u",
+				Password:     "p",
+				Account:      "a",
+				OCSPFailOpen: OCSPFailOpenFalse,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?ocspFailOpen=false",
+		},
+		{
+			cfg: &Config{
+				User:               "u",
+				Password:           "p",
+				Account:            "a",
+				UploadPartSize:     8 << 20,
+				UploadPartParallel: 2,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?uploadPartParallel=2&uploadPartSize=8388608",
+		},
+		{
+			cfg: &Config{
+				User:                 "u",
+				Password:             "p",
+				Account:              "a",
+				MaxConcurrentQueries: 8,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?maxConcurrentQueries=8",
+		},
+		{
+			cfg: &Config{
+				User:           "u",
+				Password:       "p",
+				Account:        "a",
+				ResultCacheTTL: time.Hour,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?resultCacheTTL=3600",
+		},
+		{
+			cfg: &Config{
+				User:               "u",
+				Account:            "a",
+				Authenticator:      "externalbrowser",
+				BrowserPortMin:     8001,
+				BrowserPortMax:     8010,
+				BrowserAuthTimeout: 5 * time.Minute,
+			},
+			dsn: "u:@a.snowflakecomputing.com:443?authenticator=externalbrowser&browserAuthTimeout=300&browserPort=8001-8010",
+		},
+		{
+			cfg: &Config{
+				User:                  "u",
+				Password:              "p",
+				Account:               "a",
+				ClientRequestMFAToken: true,
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?clientRequestMfaToken=true",
+		},
+		{
+			cfg: &Config{
+				User:          "u",
+				Password:      "p",
+				Account:       "a",
+				ProxyHost:     "proxy.example.com",
+				ProxyPort:     3128,
+				ProxyUser:     "pu",
+				ProxyPassword: "p@ss",
+				NonProxyHosts: "localhost|.internal",
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443?nonProxyHosts=localhost%7C.internal&proxyHost=proxy.example.com&proxyPassword=p%40ss&proxyPort=3128&proxyUser=pu",
+		},
+		{
+			cfg: &Config{
+				User:      "u",
+				Password:  "p",
+				Account:   "a",
+				ProxyHost: "proxy.example.com",
+			},
+			dsn: "u:p@a.snowflakecomputing.com:443",
+			err: &SnowflakeError{Number: ErrCodeInvalidProxy},
+		},
 	}
 	for _, test := range testcases {
 		dsn, err := DSN(test.cfg)
@@ -391,3 +1100,122 @@ func TestDSN(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigDSNRoundTrip(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no time zone database. err: %v", err)
+	}
+	timezone, tag := "Asia/Tokyo", "etl &job=1"
+	cfg := &Config{
+		Account:                  "a",
+		User:                     "u@example.com",
+		Password:                 "p:@/?&",
+		Database:                 "db",
+		Schema:                   "sc",
+		Warehouse:                "wh",
+		Role:                     "ro",
+		Region:                   "ap-northeast-1",
+		Params:                   map[string]*string{"TIMEZONE": &timezone, "QUERY_TAG": &tag},
+		Protocol:                 "https",
+		Host:                     "a.ap-northeast-1.snowflakecomputing.com",
+		Port:                     443,
+		Authenticator:            "snowflake_jwt",
+		Passcode:                 "123456",
+		PasscodeInPassword:       true,
+		ClientRequestMFAToken:    true,
+		LoginTimeout:             30 * time.Second,
+		RequestTimeout:           1500 * time.Millisecond,
+		MaxRetryDuration:         2 * time.Minute,
+		QueryTimeout:             45 * time.Second,
+		BrowserAuthTimeout:       90 * time.Second,
+		BrowserPortMin:           8001,
+		BrowserPortMax:           8010,
+		Application:              "app",
+		QueryComment:             true,
+		InsecureMode:             true,
+		OCSPFailOpen:             OCSPFailOpenFalse,
+		Token:                    "tok",
+		PrivateKey:               getTestPrivateKey(t),
+		PrivateKeyFile:           "/keys/rsa_key.p8",
+		ClientRedirect:           true,
+		InvalidUTF8:              InvalidUTF8Replace,
+		ColumnNameCase:           ColumnNameNormalize,
+		SessionReset:             SessionResetFull,
+		Location:                 tokyo,
+		GeoOutputFormat:          GeoOutputWKT,
+		NumberMode:               NumberBig,
+		ResultFormat:             ResultFormatArrow,
+		EnableHTTP2:              true,
+		HedgeChunkDownloads:      true,
+		MaxChunkDownloadWorkers:  4,
+		MaxChunkBufferBytes:      1 << 20,
+		ChunkSpillDir:            "/tmp/spill",
+		UploadPartSize:           16 << 20,
+		UploadPartParallel:       6,
+		MaxConcurrentQueries:     8,
+		DisableHeartbeat:         true,
+		DisableTelemetry:         true,
+		CircuitBreakerThreshold:  5,
+		DisableQueryContextCache: true,
+		CircuitBreakerCooldown:   45 * time.Second,
+		ResultCacheTTL:           10 * time.Minute,
+		ProxyHost:                "proxy.example.com",
+		ProxyPort:                3128,
+		ProxyUser:                "pu",
+		ProxyPassword:            "pp",
+		NonProxyHosts:            "localhost|*.internal",
+	}
+	dsn, err := cfg.DSN()
+	if err != nil {
+		t.Fatalf("failed to get DSN. err: %v", err)
+	}
+	if cfg.Region != "ap-northeast-1" || cfg.Host != "a.ap-northeast-1.snowflakecomputing.com" {
+		t.Errorf("should not have modified the config. got: %v, %v", cfg.Region, cfg.Host)
+	}
+	parsed, err := ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("failed to parse DSN. dsn: %v, err: %v", dsn, err)
+	}
+	if !parsed.PrivateKey.Equal(cfg.PrivateKey) {
+		t.Error("failed to match the private key")
+	}
+	if parsed.Location.String() != cfg.Location.String() {
+		t.Errorf("failed to match the location. got: %v", parsed.Location)
+	}
+	expected := *cfg
+	expected.PrivateKey, parsed.PrivateKey = nil, nil
+	expected.Location, parsed.Location = nil, nil
+	if !reflect.DeepEqual(parsed, &expected) {
+		t.Errorf("failed to round-trip the config. dsn: %v\nexpected: %+v\n     got: %+v", dsn, expected, *parsed)
+	}
+
+	for _, v := range []string{"R+1", "R%2B1", "WH%2520X", "D+B", "a/b", "a b", "%", "+ /%"} {
+		c := &Config{Account: "a", User: v, Password: v, Database: v, Schema: v, Warehouse: v, Role: v}
+		dsn, err = c.DSN()
+		if err != nil {
+			t.Fatalf("failed to get DSN. err: %v", err)
+		}
+		parsed, err = ParseDSN(dsn)
+		if err != nil {
+			t.Fatalf("failed to parse DSN. dsn: %v, err: %v", dsn, err)
+		}
+		if parsed.User != v || parsed.Password != v || parsed.Database != v || parsed.Schema != v ||
+			parsed.Warehouse != v || parsed.Role != v {
+			t.Errorf("failed to round-trip %q. dsn: %v, got: %+v", v, dsn, *parsed)
+		}
+	}
+
+	for _, c := range []*Config{
+		{Account: "a", User: "u", Password: "p", Transport: http.DefaultTransport},
+		{Account: "a", User: "u", Password: "p", OnSessionOpen: func(context.Context, SessionInfo) error { return nil }},
+		{Account: "a", User: "u", Password: "p", Location: Location(540)},
+		{Account: "a", User: "u", Password: "p", Progress: &Progress{}},
+		{Account: "a", User: "u", Authenticator: "snowflake_jwt", PrivateKeys: []*rsa.PrivateKey{getTestPrivateKey(t)}},
+	} {
+		_, err = c.DSN()
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodeInvalidDSNParameter {
+			t.Errorf("should have failed to represent the config in DSN. err: %v", err)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// encryptionBufferSize is the size of the blocks encrypted and decrypted at once.
+const encryptionBufferSize = 64 * 1024
+
+// Ciphers of the files in the stage
+const (
+	cipherAESCBC = "AES_CBC"
+	cipherAESGCM = "AES_GCM"
+)
+
+// gcmIVSize is the size of the IVs of AES-GCM.
+const gcmIVSize = 12
+
+// encryptMetadata is the file key and IV of an encrypted file, stored in the metadata of the file in the stage.
+type encryptMetadata struct {
+	key     string // file key wrapped by the query stage master key, in base64
+	iv      string // in base64
+	matdesc string // materialDescriptor in JSON
+	keyIV   string // IV of the wrapped file key in base64. AES-GCM if set, otherwise AES-CBC.
+	keyAAD  string // additional authenticated data of the wrapped file key in base64. AES-GCM only.
+	dataAAD string // additional authenticated data of the file in base64. AES-GCM only.
+}
+
+// encryptFunc returns the metadata to decrypt src with the material, and the reader of src encrypted. The metadata
+// are returned before src is read, so that the data are encrypted as uploaded.
+type encryptFunc func(material *snowflakeFileEncryption, src io.Reader) (*encryptMetadata, io.Reader, error)
+
+// encryptReader encrypts the data of src read in blocks of encryptionBufferSize.
+type encryptReader struct {
+	src   io.Reader
+	buf   []byte
+	out   []byte // the data encrypted but not read yet
+	done  bool
+	crypt func(b []byte, last bool) []byte // encrypts b, and appends the padding or the tag to the last block
+}
+
+func newEncryptReader(src io.Reader, crypt func(b []byte, last bool) []byte) *encryptReader {
+	// the last block may have the padding or the tag
+	return &encryptReader{src: src, buf: make([]byte, encryptionBufferSize, encryptionBufferSize+aes.BlockSize), crypt: crypt}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return 0, err
+		}
+		r.out = r.crypt(r.buf[:n], last)
+		r.done = last
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// stageEncryptFunc returns the encryption of the files uploaded to the stage. The files are encrypted in AES-GCM
+// if the stage accepts it, otherwise in AES-CBC. In FIPS mode they are always encrypted in AES-CBC, because the
+// AES-GCM of the files is computed in blocks by gcmStream rather than by the validated crypto/cipher.
+func stageEncryptFunc(stage *execResponseStageInfo) encryptFunc {
+	if isFIPSMode() {
+		return encryptStream
+	}
+	for _, c := range strings.Split(stage.Ciphers, ",") {
+		if strings.EqualFold(strings.TrimSpace(c), cipherAESGCM) {
+			return encryptStreamGCM
+		}
+	}
+	return encryptStream
+}
+
+// materialDescriptor identifies the query stage master key the file key is wrapped by.
+type materialDescriptor struct {
+	SmkID   string `json:"smkId"`
+	QueryID string `json:"queryId"`
+	KeySize string `json:"keySize"`
+}
+
+// encryptStream encrypts src in AES-CBC with a random file key of the same size as the query stage master key, and
+// returns the file key wrapped by the master key in AES-ECB.
+func encryptStream(material *snowflakeFileEncryption, src io.Reader) (*encryptMetadata, io.Reader, error) {
+	masterKey, err := base64.StdEncoding.DecodeString(material.QueryStageMasterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileKey := make([]byte, len(masterKey))
+	if _, err = rand.Read(fileKey); err != nil {
+		return nil, nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(iv); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrappedKey, err := encryptECB(masterKey, padPKCS5(fileKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	matdesc, err := marshalMaterialDescriptor(material, len(masterKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := &encryptMetadata{
+		key:     base64.StdEncoding.EncodeToString(wrappedKey),
+		iv:      base64.StdEncoding.EncodeToString(iv),
+		matdesc: matdesc,
+	}
+	mode := cipher.NewCBCEncrypter(block, iv)
+	return meta, newEncryptReader(src, func(b []byte, last bool) []byte {
+		if last {
+			b = padPKCS5(b)
+		}
+		mode.CryptBlocks(b, b)
+		return b
+	}), nil
+}
+
+// encryptStreamGCM encrypts src in AES-GCM with a random file key of the same size as the query stage master key,
+// and returns the file key wrapped by the master key in AES-GCM. The authentication tag follows the data.
+func encryptStreamGCM(material *snowflakeFileEncryption, src io.Reader) (*encryptMetadata, io.Reader, error) {
+	masterKey, err := base64.StdEncoding.DecodeString(material.QueryStageMasterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileKey := make([]byte, len(masterKey))
+	dataIV := make([]byte, gcmIVSize)
+	keyIV := make([]byte, gcmIVSize)
+	for _, b := range [][]byte{fileKey, dataIV, keyIV} {
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	gcm, err := newGCMStream(fileKey, dataIV, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrappedKey, err := sealGCM(masterKey, keyIV, fileKey, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	matdesc, err := marshalMaterialDescriptor(material, len(masterKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := &encryptMetadata{
+		key:     base64.StdEncoding.EncodeToString(wrappedKey),
+		iv:      base64.StdEncoding.EncodeToString(dataIV),
+		keyIV:   base64.StdEncoding.EncodeToString(keyIV),
+		matdesc: matdesc,
+	}
+	return meta, newEncryptReader(src, func(b []byte, last bool) []byte {
+		gcm.encrypt(b, b)
+		if last {
+			b = append(b, gcm.tag()...)
+		}
+		return b
+	}), nil
+}
+
+func marshalMaterialDescriptor(material *snowflakeFileEncryption, keyLen int) (string, error) {
+	b, err := json.Marshal(materialDescriptor{
+		SmkID:   strconv.FormatInt(material.SMKID, 10),
+		QueryID: material.QueryID,
+		KeySize: strconv.Itoa(8 * keyLen),
+	})
+	return string(b), err
+}
+
+// decryptStream decrypts src encrypted by encryptStream or encryptStreamGCM into dst.
+func decryptStream(material *snowflakeFileEncryption, meta *encryptMetadata, src io.Reader, dst io.Writer) error {
+	if meta.keyIV != "" {
+		return decryptStreamGCM(material, meta, src, dst)
+	}
+	masterKey, err := base64.StdEncoding.DecodeString(material.QueryStageMasterKey)
+	if err != nil {
+		return err
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(meta.key)
+	if err != nil {
+		return err
+	}
+	iv, err := base64.StdEncoding.DecodeString(meta.iv)
+	if err != nil {
+		return err
+	}
+	if len(iv) != aes.BlockSize {
+		return fmt.Errorf("invalid IV length: %v", len(iv))
+	}
+	fileKey, err := decryptECB(masterKey, wrappedKey)
+	if err != nil {
+		return err
+	}
+	if fileKey, err = unpadPKCS5(fileKey); err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return err
+	}
+	mode := cipher.NewCBCDecrypter(block, iv)
+	buf := make([]byte, encryptionBufferSize)
+	var pending []byte // the last block, which has the padding
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n%aes.BlockSize != 0 {
+			return fmt.Errorf("encrypted data is not a multiple of the block size")
+		}
+		if n > 0 {
+			mode.CryptBlocks(buf[:n], buf[:n])
+			if _, err := dst.Write(pending); err != nil {
+				return err
+			}
+			if _, err := dst.Write(buf[:n-aes.BlockSize]); err != nil {
+				return err
+			}
+			pending = append(pending[:0], buf[n-aes.BlockSize:n]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	last, err := unpadPKCS5(pending)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(last)
+	return err
+}
+
+// decryptStreamGCM decrypts src encrypted in AES-GCM into dst. The data are written as decrypted, and an error is
+// returned at the end if the file isn't authenticated, so the caller must discard dst on an error. In FIPS mode the
+// file is read into memory and opened at once by crypto/cipher.
+func decryptStreamGCM(material *snowflakeFileEncryption, meta *encryptMetadata, src io.Reader, dst io.Writer) error {
+	var masterKey, wrappedKey, dataIV, keyIV, keyAAD, dataAAD []byte
+	for _, d := range []struct {
+		dst *[]byte
+		src string
+	}{
+		{&masterKey, material.QueryStageMasterKey},
+		{&wrappedKey, meta.key},
+		{&dataIV, meta.iv},
+		{&keyIV, meta.keyIV},
+		{&keyAAD, meta.keyAAD},
+		{&dataAAD, meta.dataAAD},
+	} {
+		b, err := base64.StdEncoding.DecodeString(d.src)
+		if err != nil {
+			return err
+		}
+		*d.dst = b
+	}
+	fileKey, err := openGCM(masterKey, keyIV, wrappedKey, keyAAD)
+	if err != nil {
+		return err
+	}
+	if isFIPSMode() {
+		// open the whole file by crypto/cipher instead of gcmStream
+		ciphertext, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		if len(ciphertext) < gcmTagSize {
+			return errGCMAuthentication
+		}
+		data, err := openGCM(fileKey, dataIV, ciphertext, dataAAD)
+		if err != nil {
+			return errGCMAuthentication
+		}
+		_, err = dst.Write(data)
+		return err
+	}
+	gcm, err := newGCMStream(fileKey, dataIV, dataAAD)
+	if err != nil {
+		return err
+	}
+	// the last bytes read may be the tag
+	buf := make([]byte, encryptionBufferSize+gcmTagSize)
+	pending := 0
+	for {
+		n, err := io.ReadFull(src, buf[pending:])
+		n += pending
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+		if n < gcmTagSize {
+			return errGCMAuthentication
+		}
+		data := buf[:n-gcmTagSize]
+		gcm.decrypt(data, data)
+		if _, err := dst.Write(data); err != nil {
+			return err
+		}
+		if last {
+			return gcm.verify(buf[n-gcmTagSize : n])
+		}
+		pending = copy(buf, buf[n-gcmTagSize:n])
+	}
+}
+
+func newGCM(key []byte, iv []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcmIVSize {
+		return nil, fmt.Errorf("invalid IV length: %v", len(iv))
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealGCM(key, iv, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nil, iv, plaintext, aad), nil
+}
+
+func openGCM(key, iv, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, iv, ciphertext, aad)
+}
+
+func encryptECB(key []byte, src []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, len(src))
+	for i := 0; i < len(src); i += aes.BlockSize {
+		block.Encrypt(dst[i:], src[i:])
+	}
+	return dst, nil
+}
+
+func decryptECB(key []byte, src []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(src)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("wrapped key is not a multiple of the block size")
+	}
+	dst := make([]byte, len(src))
+	for i := 0; i < len(src); i += aes.BlockSize {
+		block.Decrypt(dst[i:], src[i:])
+	}
+	return dst, nil
+}
+
+func padPKCS5(b []byte) []byte {
+	n := aes.BlockSize - len(b)%aes.BlockSize
+	return append(b, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpadPKCS5(b []byte) ([]byte, error) {
+	if len(b) == 0 || len(b)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid padded length: %v", len(b))
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(b[len(b)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return b[:len(b)-n], nil
+}
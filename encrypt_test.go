@@ -0,0 +1,297 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func testEncryptionMaterial(t *testing.T, size int) *snowflakeFileEncryption {
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate a key. err: %v", err)
+	}
+	return &snowflakeFileEncryption{
+		QueryStageMasterKey: base64.StdEncoding.EncodeToString(key),
+		QueryID:             "01a2b3c4",
+		SMKID:               1234,
+	}
+}
+
+// encryptTo encrypts src into dst.
+func encryptTo(encrypt encryptFunc, material *snowflakeFileEncryption, src io.Reader, dst io.Writer) (*encryptMetadata, error) {
+	meta, r, err := encrypt(material, src)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(dst, r)
+	return meta, err
+}
+
+func TestUnitEncryptStream(t *testing.T) {
+	testcases := []struct {
+		keySize int
+		size    int
+	}{
+		{keySize: 16, size: 0},
+		{keySize: 16, size: 1},
+		{keySize: 16, size: 16},
+		{keySize: 32, size: 17},
+		{keySize: 32, size: encryptionBufferSize},
+		{keySize: 32, size: encryptionBufferSize + 1},
+		{keySize: 16, size: 3*encryptionBufferSize - 5},
+	}
+	for _, test := range testcases {
+		material := testEncryptionMaterial(t, test.keySize)
+		src := make([]byte, test.size)
+		rand.Read(src)
+		var encrypted bytes.Buffer
+		meta, err := encryptTo(encryptStream, material, bytes.NewReader(src), &encrypted)
+		if err != nil {
+			t.Fatalf("failed to encrypt. size: %v, err: %v", test.size, err)
+		}
+		if encrypted.Len() != (test.size/16+1)*16 {
+			t.Errorf("failed to pad. size: %v, got: %v", test.size, encrypted.Len())
+		}
+		var md materialDescriptor
+		if err = json.Unmarshal([]byte(meta.matdesc), &md); err != nil {
+			t.Fatalf("failed to parse the material descriptor. err: %v", err)
+		}
+		if md.SmkID != "1234" || md.QueryID != "01a2b3c4" || md.KeySize != strconv.Itoa(8*test.keySize) {
+			t.Errorf("failed to get the material descriptor. got: %v", meta.matdesc)
+		}
+		var decrypted bytes.Buffer
+		if err = decryptStream(material, meta, &encrypted, &decrypted); err != nil {
+			t.Fatalf("failed to decrypt. size: %v, err: %v", test.size, err)
+		}
+		if !bytes.Equal(src, decrypted.Bytes()) {
+			t.Errorf("failed to decrypt the data. size: %v", test.size)
+		}
+	}
+}
+
+func TestUnitDecryptStreamInvalid(t *testing.T) {
+	material := testEncryptionMaterial(t, 16)
+	var encrypted bytes.Buffer
+	meta, err := encryptTo(encryptStream, material, bytes.NewReader([]byte("hello")), &encrypted)
+	if err != nil {
+		t.Fatalf("failed to encrypt. err: %v", err)
+	}
+	var decrypted bytes.Buffer
+	if err = decryptStream(testEncryptionMaterial(t, 16), meta, bytes.NewReader(encrypted.Bytes()), &decrypted); err == nil {
+		t.Error("should have failed to decrypt with another master key")
+	}
+	truncated := encrypted.Bytes()[:encrypted.Len()-1]
+	if err = decryptStream(material, meta, bytes.NewReader(truncated), &decrypted); err == nil {
+		t.Error("should have failed to decrypt the truncated data")
+	}
+}
+
+func TestUnitEncryptStreamGCM(t *testing.T) {
+	for _, keySize := range []int{16, 32} {
+		for _, size := range []int{0, 1, encryptionBufferSize - gcmTagSize, encryptionBufferSize, encryptionBufferSize + 1, 3*encryptionBufferSize - 5} {
+			material := testEncryptionMaterial(t, keySize)
+			src := make([]byte, size)
+			rand.Read(src)
+			var encrypted bytes.Buffer
+			meta, err := encryptTo(encryptStreamGCM, material, bytes.NewReader(src), &encrypted)
+			if err != nil {
+				t.Fatalf("failed to encrypt. size: %v, err: %v", size, err)
+			}
+			if encrypted.Len() != size+16 || meta.keyIV == "" {
+				t.Errorf("failed to encrypt in AES-GCM. size: %v, got: %v", size, encrypted.Len())
+			}
+			var decrypted bytes.Buffer
+			if err = decryptStream(material, meta, &encrypted, &decrypted); err != nil {
+				t.Fatalf("failed to decrypt. size: %v, err: %v", size, err)
+			}
+			if !bytes.Equal(src, decrypted.Bytes()) {
+				t.Errorf("failed to decrypt the data. size: %v", size)
+			}
+		}
+	}
+
+	material := testEncryptionMaterial(t, 16)
+	var encrypted bytes.Buffer
+	meta, err := encryptTo(encryptStreamGCM, material, bytes.NewReader([]byte("hello")), &encrypted)
+	if err != nil {
+		t.Fatalf("failed to encrypt. err: %v", err)
+	}
+	tampered := append([]byte(nil), encrypted.Bytes()...)
+	tampered[0] ^= 1
+	var decrypted bytes.Buffer
+	if err = decryptStream(material, meta, bytes.NewReader(tampered), &decrypted); err == nil {
+		t.Error("should have failed to authenticate the tampered data")
+	}
+	if err = decryptStream(material, meta, bytes.NewReader(encrypted.Bytes()[:gcmTagSize-1]), &decrypted); err == nil {
+		t.Error("should have failed to authenticate the truncated data")
+	}
+	withAAD := *meta
+	withAAD.dataAAD = base64.StdEncoding.EncodeToString([]byte("aad"))
+	if err = decryptStream(material, &withAAD, bytes.NewReader(encrypted.Bytes()), &decrypted); err == nil {
+		t.Error("should have failed to authenticate the data with another AAD")
+	}
+	if err = decryptStream(testEncryptionMaterial(t, 16), meta, bytes.NewReader(encrypted.Bytes()), &decrypted); err == nil {
+		t.Error("should have failed to decrypt with another master key")
+	}
+}
+
+func TestGCMStream(t *testing.T) {
+	key := make([]byte, 32)
+	iv := make([]byte, gcmIVSize)
+	rand.Read(key)
+	rand.Read(iv)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, aadSize := range []int{0, 3, 16, 33} {
+		for _, size := range []int{0, 1, 15, 16, 17, 100, 4099} {
+			aad := make([]byte, aadSize)
+			src := make([]byte, size)
+			rand.Read(aad)
+			rand.Read(src)
+			expected := aead.Seal(nil, iv, src, aad)
+
+			// in parts of odd sizes
+			s, err := newGCMStream(key, iv, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encrypted := append([]byte(nil), src...)
+			for i, n := 0, 7; i < size; i, n = i+n, n*2+1 {
+				if i+n > size {
+					n = size - i
+				}
+				s.encrypt(encrypted[i:i+n], encrypted[i:i+n])
+			}
+			encrypted = append(encrypted, s.tag()...)
+			if !bytes.Equal(encrypted, expected) {
+				t.Errorf("failed to encrypt as crypto/cipher. aad: %v, size: %v", aadSize, size)
+			}
+
+			s, err = newGCMStream(key, iv, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted := make([]byte, size)
+			s.decrypt(decrypted, expected[:size])
+			if err = s.verify(expected[size:]); err != nil || !bytes.Equal(decrypted, src) {
+				t.Errorf("failed to decrypt. aad: %v, size: %v, err: %v", aadSize, size, err)
+			}
+		}
+	}
+}
+
+func TestUnitEncryptStreamGCMLarge(t *testing.T) {
+	const size = 32 << 20
+	material := testEncryptionMaterial(t, 32)
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	allocated := ms.TotalAlloc
+	f, err := ioutil.TempFile("", "gcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	meta, err := encryptTo(encryptStreamGCM, material, io.LimitReader(zeroReader{}, size), f)
+	if err != nil {
+		t.Fatalf("failed to encrypt. err: %v", err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	w := &countingWriter{}
+	if err = decryptStream(material, meta, f, w); err != nil || w.n != size {
+		t.Fatalf("failed to decrypt. size: %v, err: %v", w.n, err)
+	}
+	runtime.ReadMemStats(&ms)
+	if ms.TotalAlloc-allocated > size/4 {
+		t.Errorf("should not have held the file in memory. allocated: %v", ms.TotalAlloc-allocated)
+	}
+}
+
+func TestUnitDecryptFileUnauthenticated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	material := testEncryptionMaterial(t, 16)
+	var encrypted bytes.Buffer
+	meta, err := encryptTo(encryptStreamGCM, material, bytes.NewReader(make([]byte, 3*encryptionBufferSize)), &encrypted)
+	if err != nil {
+		t.Fatalf("failed to encrypt. err: %v", err)
+	}
+	b := encrypted.Bytes()
+	b[len(b)-1] ^= 1
+	src := filepath.Join(dir, "src")
+	if err = ioutil.WriteFile(src, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "dst")
+	if err = decryptFile(material, meta, src, dst); err == nil {
+		t.Fatal("should have failed to authenticate the file")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("should not have written the unauthenticated file. files: %v", len(files))
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestUnitStageEncryptFunc(t *testing.T) {
+	testcases := []struct {
+		ciphers string
+		gcm     bool
+	}{
+		{ciphers: "", gcm: false},
+		{ciphers: "AES_CBC", gcm: false},
+		{ciphers: "AES_GCM,AES_CBC", gcm: true},
+		{ciphers: "AES_CBC, aes_gcm", gcm: true},
+	}
+	material := testEncryptionMaterial(t, 16)
+	for _, test := range testcases {
+		meta, err := encryptTo(stageEncryptFunc(&execResponseStageInfo{Ciphers: test.ciphers}), material, bytes.NewReader(nil), &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("failed to encrypt. err: %v", err)
+		}
+		if gcm := meta.keyIV != ""; gcm != test.gcm {
+			t.Errorf("failed to choose the cipher. ciphers: %v, gcm: %v", test.ciphers, gcm)
+		}
+	}
+}
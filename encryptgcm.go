@@ -0,0 +1,202 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// gcmTagSize is the size of the authentication tags of AES-GCM, appended to the encrypted files.
+const gcmTagSize = 16
+
+var errGCMAuthentication = errors.New("message authentication failed")
+
+// gcmStream encrypts or decrypts a file in AES-GCM in blocks, so that a file isn't held in memory. The result is the
+// same as cipher.AEAD of crypto/cipher, which seals and opens a whole message at once: the data are encrypted in
+// AES-CTR from the IV and the counter 2, and authenticated by GHASH of the AAD and the encrypted data.
+type gcmStream struct {
+	ctr     cipher.Stream
+	tagMask [aes.BlockSize]byte // the counter block 1 encrypted
+	hash    ghash
+	aadLen  uint64
+	dataLen uint64
+}
+
+func newGCMStream(key, iv, aad []byte) (*gcmStream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcmIVSize {
+		return nil, errors.New("invalid IV length")
+	}
+	s := &gcmStream{aadLen: uint64(len(aad))}
+	var h [aes.BlockSize]byte
+	block.Encrypt(h[:], h[:])
+	s.hash.init(h[:])
+	var counter [aes.BlockSize]byte
+	copy(counter[:], iv)
+	counter[aes.BlockSize-1] = 1
+	block.Encrypt(s.tagMask[:], counter[:])
+	counter[aes.BlockSize-1] = 2
+	s.ctr = cipher.NewCTR(block, counter[:])
+	s.hash.write(aad)
+	s.hash.flush()
+	return s, nil
+}
+
+// encrypt encrypts src into dst, which may be the same.
+func (s *gcmStream) encrypt(dst, src []byte) {
+	s.ctr.XORKeyStream(dst, src)
+	s.hash.write(dst[:len(src)])
+	s.dataLen += uint64(len(src))
+}
+
+// decrypt decrypts src into dst, which may be the same. The data must not be used unless verify succeeds.
+func (s *gcmStream) decrypt(dst, src []byte) {
+	s.hash.write(src)
+	s.ctr.XORKeyStream(dst, src)
+	s.dataLen += uint64(len(src))
+}
+
+// tag returns the authentication tag of the data encrypted or decrypted.
+func (s *gcmStream) tag() []byte {
+	s.hash.flush()
+	var lengths [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(lengths[:8], s.aadLen*8)
+	binary.BigEndian.PutUint64(lengths[8:], s.dataLen*8)
+	s.hash.write(lengths[:])
+	tag := s.hash.sum()
+	for i := range tag {
+		tag[i] ^= s.tagMask[i]
+	}
+	return tag
+}
+
+// verify returns an error if the tag isn't the tag of the data decrypted.
+func (s *gcmStream) verify(tag []byte) error {
+	if subtle.ConstantTimeCompare(s.tag(), tag) != 1 {
+		return errGCMAuthentication
+	}
+	return nil
+}
+
+// gcmFieldElement is an element of GF(2^128) of GHASH, in the bit order of the generic GCM of the Go standard
+// library.
+type gcmFieldElement struct {
+	low, high uint64
+}
+
+// gcmReductionTable is the reduction of the 4 bits shifted out of a field element by the multiplication.
+var gcmReductionTable = []uint16{
+	0x0000, 0x1c20, 0x3840, 0x2460, 0x7080, 0x6ca0, 0x48c0, 0x54e0,
+	0xe100, 0xfd20, 0xd940, 0xc560, 0x9180, 0x8da0, 0xa9c0, 0xb5e0,
+}
+
+// ghash is GHASH of AES-GCM written in parts. The data are padded to the block size only by flush.
+type ghash struct {
+	table [16]gcmFieldElement // the multiples of H by 4 bits
+	y     gcmFieldElement
+	buf   [aes.BlockSize]byte
+	n     int // bytes in buf
+}
+
+func (g *ghash) init(h []byte) {
+	x := gcmFieldElement{binary.BigEndian.Uint64(h[:8]), binary.BigEndian.Uint64(h[8:])}
+	g.table[reverseBits(1)] = x
+	for i := 2; i < 16; i += 2 {
+		g.table[reverseBits(i)] = gcmDouble(&g.table[reverseBits(i/2)])
+		g.table[reverseBits(i+1)] = gcmAdd(&g.table[reverseBits(i)], &x)
+	}
+}
+
+func (g *ghash) write(p []byte) {
+	if g.n > 0 {
+		k := copy(g.buf[g.n:], p)
+		g.n += k
+		p = p[k:]
+		if g.n < aes.BlockSize {
+			return
+		}
+		g.update(g.buf[:])
+		g.n = 0
+	}
+	full := len(p) / aes.BlockSize * aes.BlockSize
+	for i := 0; i < full; i += aes.BlockSize {
+		g.update(p[i : i+aes.BlockSize])
+	}
+	g.n = copy(g.buf[:], p[full:])
+}
+
+// flush pads the partial block with zeros.
+func (g *ghash) flush() {
+	if g.n == 0 {
+		return
+	}
+	for i := g.n; i < aes.BlockSize; i++ {
+		g.buf[i] = 0
+	}
+	g.update(g.buf[:])
+	g.n = 0
+}
+
+func (g *ghash) sum() []byte {
+	out := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(out[:8], g.y.low)
+	binary.BigEndian.PutUint64(out[8:], g.y.high)
+	return out
+}
+
+func (g *ghash) update(block []byte) {
+	g.y.low ^= binary.BigEndian.Uint64(block[:8])
+	g.y.high ^= binary.BigEndian.Uint64(block[8:])
+	g.mul(&g.y)
+}
+
+// mul sets y to y*H.
+func (g *ghash) mul(y *gcmFieldElement) {
+	var z gcmFieldElement
+	for i := 0; i < 2; i++ {
+		word := y.high
+		if i == 1 {
+			word = y.low
+		}
+		for j := 0; j < 64; j += 4 {
+			msw := z.high & 0xf
+			z.high >>= 4
+			z.high |= z.low << 60
+			z.low >>= 4
+			z.low ^= uint64(gcmReductionTable[msw]) << 48
+			t := &g.table[word&0xf]
+			z.low ^= t.low
+			z.high ^= t.high
+			word >>= 4
+		}
+	}
+	*y = z
+}
+
+func reverseBits(i int) int {
+	i = ((i << 2) & 0xc) | ((i >> 2) & 0x3)
+	i = ((i << 1) & 0xa) | ((i >> 1) & 0x5)
+	return i
+}
+
+func gcmAdd(x, y *gcmFieldElement) gcmFieldElement {
+	return gcmFieldElement{x.low ^ y.low, x.high ^ y.high}
+}
+
+func gcmDouble(x *gcmFieldElement) gcmFieldElement {
+	var double gcmFieldElement
+	msbSet := x.high&1 == 1
+	double.high = x.high>>1 | x.low<<63
+	double.low = x.low >> 1
+	if msbSet {
+		double.low ^= 0xe100000000000000
+	}
+	return double
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"sort"
+)
+
+// ErrorCategory is a coarse classification of Snowflake and driver error numbers.
+type ErrorCategory string
+
+const (
+	// ErrorCategoryAuth covers authentication, session and token failures.
+	ErrorCategoryAuth ErrorCategory = "auth"
+	// ErrorCategorySyntax covers SQL compilation errors and unsupported usages.
+	ErrorCategorySyntax ErrorCategory = "syntax"
+	// ErrorCategoryResource covers missing or inaccessible database objects and warehouses.
+	ErrorCategoryResource ErrorCategory = "resource"
+	// ErrorCategoryTransient covers network, availability and timeout failures that may succeed on retry.
+	ErrorCategoryTransient ErrorCategory = "transient"
+	// ErrorCategoryOther is used for the known error numbers that don't fit the other categories.
+	ErrorCategoryOther ErrorCategory = "other"
+	// ErrorCategoryUnknown is returned for error numbers not in the catalog.
+	ErrorCategoryUnknown ErrorCategory = "unknown"
+)
+
+// ErrorCode describes a Snowflake (GS) or driver error number.
+type ErrorCode struct {
+	Number   int
+	Name     string
+	Category ErrorCategory
+}
+
+// errorCatalog is the list of known error numbers. Server side numbers come from GS,
+// 26xxxx numbers are generated by the driver itself.
+var errorCatalog = map[int]ErrorCode{
+	/* GS: SQL compilation */
+	1003: {1003, "SQL_COMPILATION_SYNTAX_ERROR", ErrorCategorySyntax},
+	904:  {904, "INVALID_IDENTIFIER", ErrorCategorySyntax},
+	2003: {2003, "OBJECT_DOES_NOT_EXIST_OR_NOT_AUTHORIZED", ErrorCategoryResource},
+	2043: {2043, "OBJECT_DOES_NOT_EXIST", ErrorCategoryResource},
+	606:  {606, "NO_ACTIVE_WAREHOUSE", ErrorCategoryResource},
+
+	/* GS: execution */
+	604: {604, "QUERY_CANCELED", ErrorCategoryOther},
+	630: {630, "STATEMENT_TIMEOUT", ErrorCategoryTransient},
+
+	/* GS: query status */
+	333333: {333333, "QUERY_IN_PROGRESS", ErrorCategoryTransient},
+	333334: {333334, "QUERY_IN_PROGRESS_ASYNC", ErrorCategoryTransient},
+
+	/* GS: authentication and session */
+	390100: {390100, "INCORRECT_USERNAME_PASSWORD", ErrorCategoryAuth},
+	390111: {390111, "SESSION_GONE", ErrorCategoryAuth},
+	390112: {390112, "SESSION_EXPIRED", ErrorCategoryAuth},
+	390114: {390114, "SESSION_TOKEN_EXPIRED", ErrorCategoryAuth},
+	390144: {390144, "JWT_TOKEN_INVALID", ErrorCategoryAuth},
+	390303: {390303, "OAUTH_ACCESS_TOKEN_INVALID", ErrorCategoryAuth},
+	390318: {390318, "OAUTH_ACCESS_TOKEN_EXPIRED", ErrorCategoryAuth},
+
+	/* driver: connection */
+	ErrCodeEmptyAccountCode:       {ErrCodeEmptyAccountCode, "EMPTY_ACCOUNT", ErrorCategoryAuth},
+	ErrCodeEmptyUsernameCode:      {ErrCodeEmptyUsernameCode, "EMPTY_USERNAME", ErrorCategoryAuth},
+	ErrCodeEmptyPasswordCode:      {ErrCodeEmptyPasswordCode, "EMPTY_PASSWORD", ErrorCategoryAuth},
+	ErrCodeFailedToParseHost:      {ErrCodeFailedToParseHost, "FAILED_TO_PARSE_HOST", ErrorCategorySyntax},
+	ErrCodeFailedToParsePort:      {ErrCodeFailedToParsePort, "FAILED_TO_PARSE_PORT", ErrorCategorySyntax},
+	ErrCodeIdpConnectionError:     {ErrCodeIdpConnectionError, "IDP_CONNECTION_ERROR", ErrorCategoryAuth},
+	ErrCodeSSOURLNotMatch:         {ErrCodeSSOURLNotMatch, "SSO_URL_NOT_MATCH", ErrorCategoryAuth},
+	ErrCodeServiceUnavailable:     {ErrCodeServiceUnavailable, "SERVICE_UNAVAILABLE", ErrorCategoryTransient},
+	ErrCodeFailedToConnect:        {ErrCodeFailedToConnect, "FAILED_TO_CONNECT", ErrorCategoryAuth},
+	ErrCodeObjectNotExists:        {ErrCodeObjectNotExists, "OBJECT_NOT_EXISTS", ErrorCategoryResource},
+	ErrCodeFailedToGetCredentials: {ErrCodeFailedToGetCredentials, "FAILED_TO_GET_CREDENTIALS", ErrorCategoryAuth},
+	ErrCodeNoCredentials:          {ErrCodeNoCredentials, "NO_CREDENTIALS", ErrorCategoryAuth},
+	ErrCodeEmptyPrivateKey:        {ErrCodeEmptyPrivateKey, "EMPTY_PRIVATE_KEY", ErrorCategoryAuth},
+	ErrCodePrivateKeyParseError:   {ErrCodePrivateKeyParseError, "PRIVATE_KEY_PARSE_ERROR", ErrorCategoryAuth},
+	ErrCodeInvalidOktaURL:         {ErrCodeInvalidOktaURL, "INVALID_OKTA_URL", ErrorCategoryAuth},
+	ErrCodeInvalidProxy:           {ErrCodeInvalidProxy, "INVALID_PROXY", ErrorCategorySyntax},
+	ErrCodeInvalidDSNParameter:    {ErrCodeInvalidDSNParameter, "INVALID_DSN_PARAMETER", ErrorCategorySyntax},
+	ErrCodeRegionMismatch:         {ErrCodeRegionMismatch, "REGION_MISMATCH", ErrorCategorySyntax},
+	ErrCodeUnknownAuthenticator:   {ErrCodeUnknownAuthenticator, "UNKNOWN_AUTHENTICATOR", ErrorCategoryAuth},
+
+	/* driver: network */
+	ErrFailedToPostQuery:                  {ErrFailedToPostQuery, "FAILED_TO_POST_QUERY", ErrorCategoryTransient},
+	ErrFailedToRenewSession:               {ErrFailedToRenewSession, "FAILED_TO_RENEW_SESSION", ErrorCategoryAuth},
+	ErrFailedToCancelQuery:                {ErrFailedToCancelQuery, "FAILED_TO_CANCEL_QUERY", ErrorCategoryTransient},
+	ErrFailedToCloseSession:               {ErrFailedToCloseSession, "FAILED_TO_CLOSE_SESSION", ErrorCategoryTransient},
+	ErrFailedToAuth:                       {ErrFailedToAuth, "FAILED_TO_AUTH", ErrorCategoryAuth},
+	ErrFailedToAuthSAML:                   {ErrFailedToAuthSAML, "FAILED_TO_AUTH_SAML", ErrorCategoryAuth},
+	ErrFailedToAuthOKTA:                   {ErrFailedToAuthOKTA, "FAILED_TO_AUTH_OKTA", ErrorCategoryAuth},
+	ErrFailedToGetSSO:                     {ErrFailedToGetSSO, "FAILED_TO_GET_SSO", ErrorCategoryAuth},
+	ErrFailedToParseResponse:              {ErrFailedToParseResponse, "FAILED_TO_PARSE_RESPONSE", ErrorCategoryOther},
+	ErrFailedToGetExternalBrowserResponse: {ErrFailedToGetExternalBrowserResponse, "FAILED_TO_GET_EXTERNAL_BROWSER_RESPONSE", ErrorCategoryAuth},
+	ErrFailedToHeartbeat:                  {ErrFailedToHeartbeat, "FAILED_TO_HEARTBEAT", ErrorCategoryTransient},
+	ErrTooManyRequests:                    {ErrTooManyRequests, "TOO_MANY_REQUESTS", ErrorCategoryTransient},
+	ErrRetryTimeout:                       {ErrRetryTimeout, "RETRY_TIMEOUT", ErrorCategoryTransient},
+	ErrCircuitOpen:                        {ErrCircuitOpen, "CIRCUIT_OPEN", ErrorCategoryTransient},
+	ErrExternalBrowserTimeout:             {ErrExternalBrowserTimeout, "EXTERNAL_BROWSER_TIMEOUT", ErrorCategoryAuth},
+	ErrFailedToGetQueryResult:             {ErrFailedToGetQueryResult, "FAILED_TO_GET_QUERY_RESULT", ErrorCategoryTransient},
+	ErrQueryTimeout:                       {ErrQueryTimeout, "QUERY_TIMEOUT", ErrorCategoryTransient},
+
+	/* driver: session */
+	ErrInvalidIdentifier:      {ErrInvalidIdentifier, "INVALID_IDENTIFIER_NAME", ErrorCategorySyntax},
+	ErrSessionContextMismatch: {ErrSessionContextMismatch, "SESSION_CONTEXT_MISMATCH", ErrorCategoryOther},
+
+	/* driver: rows */
+	ErrFailedToGetChunk:     {ErrFailedToGetChunk, "FAILED_TO_GET_CHUNK", ErrorCategoryTransient},
+	ErrInvalidArrowData:     {ErrInvalidArrowData, "INVALID_ARROW_DATA", ErrorCategoryOther},
+	ErrNoArrowBatches:       {ErrNoArrowBatches, "NO_ARROW_BATCHES", ErrorCategorySyntax},
+	ErrArrowBatchesOnly:     {ErrArrowBatchesOnly, "ARROW_BATCHES_ONLY", ErrorCategorySyntax},
+	ErrInvalidQueryID:       {ErrInvalidQueryID, "INVALID_QUERY_ID", ErrorCategorySyntax},
+	ErrInvalidRowOffset:     {ErrInvalidRowOffset, "INVALID_ROW_OFFSET", ErrorCategorySyntax},
+	ErrResultFormatMismatch: {ErrResultFormatMismatch, "RESULT_FORMAT_MISMATCH", ErrorCategoryOther},
+
+	/* driver: file transfer */
+	ErrFileNotExists:              {ErrFileNotExists, "FILE_NOT_EXISTS", ErrorCategoryResource},
+	ErrInvalidStageLocation:       {ErrInvalidStageLocation, "INVALID_STAGE_LOCATION", ErrorCategoryOther},
+	ErrFailedToUploadToStage:      {ErrFailedToUploadToStage, "FAILED_TO_UPLOAD_TO_STAGE", ErrorCategoryTransient},
+	ErrFailedToDownloadFromStage:  {ErrFailedToDownloadFromStage, "FAILED_TO_DOWNLOAD_FROM_STAGE", ErrorCategoryTransient},
+	ErrInvalidEncryption:          {ErrInvalidEncryption, "INVALID_ENCRYPTION", ErrorCategoryOther},
+	ErrUnknownFileTransferCommand: {ErrUnknownFileTransferCommand, "UNKNOWN_FILE_TRANSFER_COMMAND", ErrorCategoryOther},
+	ErrStreamTooLarge:             {ErrStreamTooLarge, "STREAM_TOO_LARGE", ErrorCategoryResource},
+
+	/* driver: transaction */
+	ErrNoReadOnlyTransaction:              {ErrNoReadOnlyTransaction, "NO_READONLY_TRANSACTION", ErrorCategorySyntax},
+	ErrNoDefaultTransactionIsolationLevel: {ErrNoDefaultTransactionIsolationLevel, "NO_DEFAULT_TRANSACTION_ISOLATION_LEVEL", ErrorCategorySyntax},
+
+	/* driver: converter */
+	ErrInvalidTimestampTz:     {ErrInvalidTimestampTz, "INVALID_TIMESTAMP_TZ", ErrorCategoryOther},
+	ErrInvalidOffsetStr:       {ErrInvalidOffsetStr, "INVALID_OFFSET_STRING", ErrorCategoryOther},
+	ErrInvalidBinaryHexForm:   {ErrInvalidBinaryHexForm, "INVALID_BINARY_HEX_FORM", ErrorCategoryOther},
+	ErrUnsupportedLiteralType: {ErrUnsupportedLiteralType, "UNSUPPORTED_LITERAL_TYPE", ErrorCategorySyntax},
+	ErrColumnCountMismatch:    {ErrColumnCountMismatch, "COLUMN_COUNT_MISMATCH", ErrorCategorySyntax},
+	ErrInvalidUTF8:            {ErrInvalidUTF8, "INVALID_UTF8", ErrorCategoryOther},
+	ErrInvalidBindArray:       {ErrInvalidBindArray, "INVALID_BIND_ARRAY", ErrorCategorySyntax},
+	ErrInvalidNamedParameter:  {ErrInvalidNamedParameter, "INVALID_NAMED_PARAMETER", ErrorCategorySyntax},
+	ErrInvalidVariant:         {ErrInvalidVariant, "INVALID_VARIANT", ErrorCategorySyntax},
+	ErrRowTooLarge:            {ErrRowTooLarge, "ROW_TOO_LARGE", ErrorCategorySyntax},
+}
+
+// LookupErrorCode returns the catalog entry for the given error number. The second return value
+// is false if the number is not in the catalog.
+func LookupErrorCode(number int) (ErrorCode, bool) {
+	ec, ok := errorCatalog[number]
+	return ec, ok
+}
+
+// ErrorCodes returns all entries in the error code catalog ordered by the error number.
+func ErrorCodes() []ErrorCode {
+	ret := make([]ErrorCode, 0, len(errorCatalog))
+	for _, ec := range errorCatalog {
+		ret = append(ret, ec)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Number < ret[j].Number })
+	return ret
+}
+
+// Category returns the catalog category of the error number, or ErrorCategoryUnknown.
+func (se *SnowflakeError) Category() ErrorCategory {
+	if ec, ok := errorCatalog[se.Number]; ok {
+		return ec.Category
+	}
+	return ErrorCategoryUnknown
+}
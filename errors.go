@@ -13,10 +13,16 @@ type SnowflakeError struct {
 	QueryID        string
 	Message        string
 	MessageArgs    []interface{}
-	IncludeQueryID bool // TODO: populate this in connection
+	IncludeQueryID bool  // TODO: populate this in connection
+	Attempts       int   // number of the attempts of the HTTP request that failed after retries
+	Err            error // underlying error, e.g., the network error of the last attempt, if any
 }
 
 func (se *SnowflakeError) Error() string {
+	return maskSecrets(se.format())
+}
+
+func (se *SnowflakeError) format() string {
 	message := se.Message
 	if len(se.MessageArgs) > 0 {
 		message = fmt.Sprintf(se.Message, se.MessageArgs...)
@@ -33,6 +39,69 @@ func (se *SnowflakeError) Error() string {
 	return fmt.Sprintf("%06d: %s", se.Number, message)
 }
 
+// Unwrap returns the underlying error if any.
+func (se *SnowflakeError) Unwrap() error {
+	return se.Err
+}
+
+// Is returns true if the target is a sentinel error, e.g., ErrAuthFailed, including the error number, or a
+// SnowflakeError with the same error number.
+func (se *SnowflakeError) Is(target error) bool {
+	switch t := target.(type) {
+	case *sentinelError:
+		for _, n := range t.numbers {
+			if se.Number == n {
+				return true
+			}
+		}
+	case *SnowflakeError:
+		return t.Number == se.Number
+	}
+	return false
+}
+
+// Retryable returns true if the same request may succeed on retry, e.g., network failures and timeouts.
+func (se *SnowflakeError) Retryable() bool {
+	return se.Category() == ErrorCategoryTransient
+}
+
+// AsSnowflakeError returns the first SnowflakeError in the chain of the wrapped errors:
+//
+//	if se, ok := sf.AsSnowflakeError(err); ok {
+//		log.Printf("query %v failed. code: %v, state: %v", se.QueryID, se.Number, se.SQLState)
+//	}
+func AsSnowflakeError(err error) (*SnowflakeError, bool) {
+	for err != nil {
+		if se, ok := err.(*SnowflakeError); ok {
+			return se, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// withQueryID sets the query ID to the SnowflakeError without one.
+func withQueryID(err error, queryID string) error {
+	if se, ok := err.(*SnowflakeError); ok && se.QueryID == "" {
+		se.QueryID = queryID
+	}
+	return err
+}
+
+// sentinelError matches the SnowflakeErrors with any of the error numbers by errors.Is.
+type sentinelError struct {
+	message string
+	numbers []int
+}
+
+func (e *sentinelError) Error() string {
+	return e.message
+}
+
 const (
 	/* connection */
 
@@ -56,6 +125,25 @@ const (
 	ErrCodeFailedToConnect = 260008
 	// ErrCodeObjectNotExists is an error code for the case where the specified database object doesn't exist
 	ErrCodeObjectNotExists = 260009
+	// ErrCodeFailedToGetCredentials is an error code for the case where a credential provider failed
+	ErrCodeFailedToGetCredentials = 260010
+	// ErrCodeNoCredentials is an error code for the case where no credential provider found credentials
+	ErrCodeNoCredentials = 260011
+	// ErrCodeEmptyPrivateKey is an error code for the case where the snowflake_jwt authenticator has no private key
+	ErrCodeEmptyPrivateKey = 260012
+	// ErrCodePrivateKeyParseError is an error code for the case where the private key is invalid
+	ErrCodePrivateKeyParseError = 260013
+	// ErrCodeInvalidOktaURL is an error code for the case where the authenticator is an Okta URL other than https.
+	ErrCodeInvalidOktaURL = 260014
+	// ErrCodeInvalidProxy is an error code for the case where the proxy settings are invalid.
+	ErrCodeInvalidProxy = 260015
+	// ErrCodeInvalidDSNParameter is an error code for the case where a DSN parameter has an invalid value.
+	ErrCodeInvalidDSNParameter = 260016
+	// ErrCodeRegionMismatch is an error code for the case where the region conflicts with the host of the account.
+	ErrCodeRegionMismatch = 260017
+	// ErrCodeUnknownAuthenticator is an error code for the case where the authenticator is neither a known one nor an
+	// Okta URL.
+	ErrCodeUnknownAuthenticator = 260018
 
 	/* network */
 
@@ -81,11 +169,68 @@ const (
 	ErrFailedToGetExternalBrowserResponse = 261009
 	// ErrFailedToHeartbeat is an error code when a heartbeat fails.
 	ErrFailedToHeartbeat = 261010
+	// ErrTooManyRequests is an error code when the requests are still throttled by the server at the timeout.
+	ErrTooManyRequests = 261011
+	// ErrCircuitOpen is an error code when the request fails fast because the circuit breaker of the host is open.
+	ErrCircuitOpen = 261012
+	// ErrExternalBrowserTimeout is an error code when the external browser authentication is not completed within
+	// the timeout.
+	ErrExternalBrowserTimeout = 261013
+	// ErrFailedToGetQueryResult is an error code when the result of a query can't be fetched.
+	ErrFailedToGetQueryResult = 261014
+	// ErrRetryTimeout is an error code when the request keeps failing with the network errors or the retryable HTTP
+	// status until the timeout.
+	ErrRetryTimeout = 261015
+	// ErrQueryTimeout is an error code when the query is canceled after the statement timeout, i.e.,
+	// Config.QueryTimeout or WithStatementTimeout.
+	ErrQueryTimeout = 261016
+
+	/* session */
+
+	// ErrInvalidIdentifier is an error code for the case where a name given to the driver is not a valid identifier.
+	ErrInvalidIdentifier = 264000
+	// ErrSessionContextMismatch is an error code for the case where the session context after a USE statement
+	// is not the requested one.
+	ErrSessionContextMismatch = 264001
 
 	/* rows */
 
 	// ErrFailedToGetChunk is an error code for the case where it failed to get chunk of result set
 	ErrFailedToGetChunk = 262000
+	// ErrInvalidArrowData is an error code for the case where a chunk of result set in Arrow can't be decoded.
+	ErrInvalidArrowData = 262001
+	// ErrNoArrowBatches is an error code for the case where the result set is not available in Arrow batches.
+	ErrNoArrowBatches = 262002
+	// ErrArrowBatchesOnly is an error code for the case where the rows of a query run with WithArrowBatches are
+	// read by Next.
+	ErrArrowBatchesOnly = 262003
+	// ErrInvalidQueryID is an error code for the case where the query ID of the result to fetch is not valid.
+	ErrInvalidQueryID = 262004
+	// ErrInvalidRowOffset is an error code for the case where the row offset to seek to is negative.
+	ErrInvalidRowOffset = 262005
+	// ErrResultFormatMismatch is an error code for the case where the result in Arrow is converted differently from
+	// the same result in JSON in the verification mode.
+	ErrResultFormatMismatch = 262006
+
+	/* file transfer */
+
+	// ErrFileNotExists is an error code for the case where the local files of PUT don't exist.
+	ErrFileNotExists = 265000
+	// ErrInvalidStageLocation is an error code for the case where the stage location type is not supported.
+	ErrInvalidStageLocation = 265001
+	// ErrFailedToUploadToStage is an error code for the case where a file of PUT can't be uploaded to the stage.
+	ErrFailedToUploadToStage = 265002
+	// ErrFailedToDownloadFromStage is an error code for the case where a file of GET can't be downloaded from the
+	// stage.
+	ErrFailedToDownloadFromStage = 265003
+	// ErrInvalidEncryption is an error code for the case where a file in the stage can't be encrypted or decrypted
+	// with the encryption material.
+	ErrInvalidEncryption = 265004
+	// ErrUnknownFileTransferCommand is an error code for the case where the file transfer command in the response
+	// is not UPLOAD or DOWNLOAD.
+	ErrUnknownFileTransferCommand = 265005
+	// ErrStreamTooLarge is an error code for the case where the stream of PUT exceeds the parts of the upload.
+	ErrStreamTooLarge = 265006
 
 	/* transaction*/
 
@@ -103,6 +248,30 @@ const (
 	ErrInvalidOffsetStr = 268001
 	// ErrInvalidBinaryHexForm is an error code for the case where a binary data in hex form is invalid.
 	ErrInvalidBinaryHexForm = 268002
+	// ErrUnsupportedLiteralType is an error code for the case where a value cannot be formatted as a SQL literal.
+	ErrUnsupportedLiteralType = 268003
+	// ErrColumnCountMismatch is an error code for the case where the number of values doesn't match the number of columns.
+	ErrColumnCountMismatch = 268004
+	// ErrInvalidUTF8 is an error code for the case where a string value includes invalid UTF-8 byte sequences.
+	ErrInvalidUTF8 = 268005
+	// ErrInvalidBindArray is an error code for the case where the arrays of Array can't be bound, e.g., the arrays
+	// have different lengths.
+	ErrInvalidBindArray = 268006
+	// ErrInvalidNamedParameter is an error code for the case where the parameters given by sql.Named don't match
+	// the :name placeholders of the query.
+	ErrInvalidNamedParameter = 268007
+	// ErrInvalidVariant is an error code for the case where a value can't be bound as JSON.
+	ErrInvalidVariant = 268008
+	// ErrRowTooLarge is an error code for the case where a row of InsertBuilder doesn't fit in the maximum statement
+	// size.
+	ErrRowTooLarge = 268009
+)
+
+// Error codes returned by Snowflake rather than generated by the driver.
+const (
+	// ErrCodeSessionExpired is the error code of Snowflake for the case where the session has expired, e.g., a
+	// query is rejected again after the session is renewed.
+	ErrCodeSessionExpired = 390112
 )
 
 const (
@@ -115,11 +284,14 @@ const (
 	errMsgFailedToGetChunk                   = "failed to get a chunk of result sets. idx: %v"
 	errMsgFailedToPostQuery                  = "failed to POST. HTTP: %v, URL: %v"
 	errMsgFailedToRenew                      = "failed to renew session. HTTP: %v, URL: %v"
+	errMsgSessionExpired                     = "session expired after the renewal: %v"
+	errMsgTokenRenewNotSupported             = "failed to renew session. the protocol version %v doesn't renew tokens"
 	errMsgFailedToCancelQuery                = "failed to cancel query. HTTP: %v, URL: %v"
 	errMsgFailedToCloseSession               = "failed to close session. HTTP: %v, URL: %v"
 	errMsgFailedToAuth                       = "failed to auth for unknown reason. HTTP: %v, URL: %v"
 	errMsgFailedToAuthSAML                   = "failed to auth via SAML for unknown reason. HTTP: %v, URL: %v"
 	errMsgFailedToAuthOKTA                   = "failed to auth via OKTA for unknown reason. HTTP: %v, URL: %v"
+	errMsgFailedToAuthOKTAStatus             = "failed to auth via OKTA. no one-time token is returned. status: %v"
 	errMsgFailedToGetSSO                     = "failed to auth via OKTA for unknown reason. HTTP: %v, URL: %v"
 	errMsgFailedToParseResponse              = "failed to parse a response from Snowflake. Response: %v"
 	errMsgFailedToGetExternalBrowserResponse = "failed to get an external browser response from Snowflake, err: %s"
@@ -128,6 +300,43 @@ const (
 	errMsgServiceUnavailable                 = "service is unavailable. check your connectivity. you may need a proxy server. HTTP: %v, URL: %v"
 	errMsgFailedToConnect                    = "failed to connect to db. verify account name is correct. HTTP: %v, URL: %v"
 	errMsgObjectNotExists                    = "specified object doesn't exists: %v"
+	errMsgFailedToGetCredentials             = "failed to get credentials from %v. err: %v"
+	errMsgNoCredentials                      = "no credentials found by the providers: %v"
+	errMsgUnsupportedLiteralType             = "unsupported type for a SQL literal: %T"
+	errMsgColumnCountMismatch                = "number of values doesn't match the number of columns. expected: %v, got: %v"
+	errMsgInvalidUTF8                        = "invalid UTF-8 byte sequence in column: %v"
+	errMsgTooManyRequests                    = "too many requests. throttled until the timeout. HTTP: %v, URL: %v"
+	errMsgRetryTimeout                       = "request failed after %v attempts in %v. last error: %v"
+	errMsgCircuitOpen                        = "circuit breaker is open. host: %v, consecutive failures: %v, retry in: %v"
+	errMsgPrivateKeyParseError               = "failed to parse the private key. err: %v"
+	errMsgInvalidIdentifier                  = "invalid identifier: %v"
+	errMsgSessionContextMismatch             = "failed to switch the %v. expected: %v, got: %v"
+	errMsgInvalidOktaURL                     = "Okta URL of the authenticator must be https: %v"
+	errMsgUnknownAuthenticator               = "unknown authenticator: %v"
+	errMsgInvalidProxy                       = "invalid proxy settings: %v"
+	errMsgQueryTimeout                       = "query exceeded the statement timeout of %v and was canceled"
+	errMsgRegionMismatch                     = "region %v conflicts with host %v. An organization account has no region, and the region in the account must match the region parameter"
+	errMsgInvalidDSNParameter                = "invalid DSN parameter %v: %v"
+	errMsgFailedToGetQueryResult             = "failed to get the query result. HTTP: %v, URL: %v"
+	errMsgExternalBrowserTimeout             = "external browser authentication was not completed within %v"
+	errMsgInvalidArrowData                   = "invalid Arrow result data. %v"
+	errMsgNoArrowBatchesMode                 = "the query must be run with WithArrowBatches to get the Arrow batches"
+	errMsgNoArrowBatchesFormat               = "the result set is not in Arrow. format: %v"
+	errMsgArrowBatchesOnly                   = "the rows of the query run with WithArrowBatches must be read by GetArrowBatches"
+	errMsgFileNotExists                      = "file doesn't exist: %v"
+	errMsgInvalidStageLocation               = "unsupported stage location type: %v"
+	errMsgFailedToUploadToStage              = "failed to upload %v to the stage. HTTP: %v, URL: %v"
+	errMsgFailedToDownloadFromStage          = "failed to download %v from the stage. HTTP: %v, URL: %v"
+	errMsgInvalidEncryption                  = "invalid encryption of %v. %v"
+	errMsgUnknownFileTransferCommand         = "unsupported file transfer command: %v"
+	errMsgStreamTooLarge                     = "the stream of %v exceeds %v parts of %v bytes. set a larger uploadPartSize"
+	errMsgInvalidBindArray                   = "invalid array binding: %v"
+	errMsgInvalidNamedParameter              = "invalid named parameter: %v"
+	errMsgInvalidVariant                     = "invalid variant binding: %v"
+	errMsgRowTooLarge                        = "row %v of the INSERT statement is %v bytes, larger than the maximum statement size of %v bytes"
+	errMsgInvalidQueryID                     = "invalid query ID: %v"
+	errMsgInvalidRowOffset                   = "invalid row offset: %v"
+	errMsgResultFormatMismatch               = "query results in Arrow and JSON differ. %v"
 )
 
 var (
@@ -145,4 +354,26 @@ var (
 	ErrEmptyPassword = &SnowflakeError{
 		Number:  ErrCodeEmptyPasswordCode,
 		Message: "password is empty"}
+	// ErrEmptyPrivateKey is returned if the snowflake_jwt authenticator has neither a private key nor its file.
+	ErrEmptyPrivateKey = &SnowflakeError{
+		Number:  ErrCodeEmptyPrivateKey,
+		Message: "private key is empty",
+	}
+
+	// ErrAuthFailed matches the login errors, e.g., incorrect user name or password, an invalid JWT or OAuth
+	// token, and the SAML or Okta authentication failures.
+	ErrAuthFailed error = &sentinelError{
+		message: "authentication failed",
+		numbers: []int{390100, 390144, 390303, 390318, ErrFailedToAuth, ErrFailedToAuthSAML, ErrFailedToAuthOKTA},
+	}
+	// ErrQueryCanceled matches the errors of the queries canceled in Snowflake.
+	ErrQueryCanceled error = &sentinelError{
+		message: "query canceled",
+		numbers: []int{604},
+	}
+	// ErrObjectNotFound matches the errors of the database objects that don't exist or are not authorized.
+	ErrObjectNotFound error = &sentinelError{
+		message: "object does not exist",
+		numbers: []int{2003, 2043, ErrCodeObjectNotExists},
+	}
 )
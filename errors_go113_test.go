@@ -0,0 +1,41 @@
+// +build go1.13
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestSnowflakeErrorIs(t *testing.T) {
+	testcases := []struct {
+		err    error
+		target error
+		out    bool
+	}{
+		{&SnowflakeError{Number: 390100}, ErrAuthFailed, true},
+		{&SnowflakeError{Number: ErrFailedToAuthOKTA}, ErrAuthFailed, true},
+		{&SnowflakeError{Number: 604, QueryID: "abcdef"}, ErrQueryCanceled, true},
+		{&SnowflakeError{Number: 2003}, ErrObjectNotFound, true},
+		{&SnowflakeError{Number: ErrCodeObjectNotExists}, ErrObjectNotFound, true},
+		{&SnowflakeError{Number: 2003}, ErrAuthFailed, false},
+		{fmt.Errorf("failed to query: %w", &SnowflakeError{Number: 604}), ErrQueryCanceled, true},
+		{&SnowflakeError{Number: ErrCodeEmptyAccountCode}, ErrEmptyAccount, true},
+		{&SnowflakeError{Number: ErrCodeEmptyUsernameCode}, ErrEmptyAccount, false},
+		{&SnowflakeError{Number: ErrRetryTimeout, Err: io.ErrUnexpectedEOF}, io.ErrUnexpectedEOF, true},
+		{io.EOF, ErrAuthFailed, false},
+	}
+	for _, test := range testcases {
+		if errors.Is(test.err, test.target) != test.out {
+			t.Errorf("failed to match the error. err: %v, target: %v, expected: %v", test.err, test.target, test.out)
+		}
+	}
+	var se *SnowflakeError
+	if !errors.As(fmt.Errorf("wrapped: %w", &SnowflakeError{Number: 604}), &se) || se.Number != 604 {
+		t.Errorf("failed to get SnowflakeError by errors.As")
+	}
+}
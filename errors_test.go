@@ -3,6 +3,8 @@
 package gosnowflake
 
 import (
+	"io"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -79,3 +81,76 @@ func TestErrorMessage(t *testing.T) {
 		t.Errorf("failed to format error. %v", e)
 	}
 }
+
+func TestLookupErrorCode(t *testing.T) {
+	testcases := []struct {
+		number   int
+		ok       bool
+		category ErrorCategory
+	}{
+		{390100, true, ErrorCategoryAuth},
+		{1003, true, ErrorCategorySyntax},
+		{2003, true, ErrorCategoryResource},
+		{ErrFailedToPostQuery, true, ErrorCategoryTransient},
+		{999999, false, ""},
+	}
+	for _, test := range testcases {
+		ec, ok := LookupErrorCode(test.number)
+		if ok != test.ok {
+			t.Errorf("failed to look up error code. number: %v, expected: %v, got: %v", test.number, test.ok, ok)
+		}
+		if ec.Category != test.category {
+			t.Errorf("wrong category. number: %v, expected: %v, got: %v", test.number, test.category, ec.Category)
+		}
+	}
+	e := &SnowflakeError{Number: 999999}
+	if e.Category() != ErrorCategoryUnknown {
+		t.Errorf("should be unknown. got: %v", e.Category())
+	}
+	codes := ErrorCodes()
+	for i := 1; i < len(codes); i++ {
+		if codes[i-1].Number >= codes[i].Number {
+			t.Fatalf("error codes are not sorted. %v, %v", codes[i-1].Number, codes[i].Number)
+		}
+	}
+}
+
+func TestAsSnowflakeError(t *testing.T) {
+	se := &SnowflakeError{Number: 2003, SQLState: "02000", QueryID: "abcdef"}
+	timeout := &SnowflakeError{Number: ErrRetryTimeout, Err: se}
+	testcases := []struct {
+		err error
+		out *SnowflakeError
+	}{
+		{se, se},
+		{timeout, timeout},
+		{&url.Error{Op: "Post", URL: "https://example.com", Err: se}, se},
+		{&url.Error{Op: "Post", URL: "https://example.com", Err: io.EOF}, nil},
+		{io.EOF, nil},
+		{nil, nil},
+	}
+	for _, test := range testcases {
+		got, ok := AsSnowflakeError(test.err)
+		if got != test.out || ok != (test.out != nil) {
+			t.Errorf("failed to get SnowflakeError. err: %v, expected: %v, got: %v", test.err, test.out, got)
+		}
+	}
+}
+
+func TestSnowflakeErrorRetryable(t *testing.T) {
+	testcases := []struct {
+		number    int
+		retryable bool
+	}{
+		{ErrRetryTimeout, true},
+		{630, true},
+		{390100, false},
+		{2003, false},
+		{999999, false},
+	}
+	for _, test := range testcases {
+		if (&SnowflakeError{Number: test.number}).Retryable() != test.retryable {
+			t.Errorf("failed to check retryable. number: %v, expected: %v", test.number, test.retryable)
+		}
+	}
+}
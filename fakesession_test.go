@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// fakeSession is a fake session of the unit tests switching the session context. It runs USE statements on its
+// context, answers SHOW VARIABLES and SHOW PARAMETERS IN SESSION, and records the statements.
+type fakeSession struct {
+	state      map[string]string    // ROLE, WAREHOUSE, DATABASE and SCHEMA
+	vars       []string             // names returned by SHOW VARIABLES
+	params     [][4]string          // key, value, level and type returned by SHOW PARAMETERS IN SESSION
+	respParams []nameValueParameter // session parameters returned in every response
+	fail       string               // prefix of the statements failing as the object doesn't exist
+	sent       []string
+}
+
+func (s *fakeSession) postQuery(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+	var req execRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	s.sent = append(s.sent, req.SQLText)
+	if s.fail != "" && strings.HasPrefix(req.SQLText, s.fail) {
+		return &execResponse{Success: false, Code: "2043", Message: "Object does not exist"}, nil
+	}
+	resp := &execResponse{Success: true}
+	switch f := strings.SplitN(req.SQLText, " ", 3); {
+	case req.SQLText == "SHOW VARIABLES":
+		resp.Data.RowType = []execResponseRowType{{Name: "name", Type: "text"}}
+		for i := range s.vars {
+			resp.Data.RowSet = append(resp.Data.RowSet, []*string{&s.vars[i]})
+		}
+	case strings.HasPrefix(req.SQLText, "UNSET "):
+		s.vars = nil
+	case req.SQLText == "SHOW PARAMETERS IN SESSION":
+		resp.Data.RowType = []execResponseRowType{
+			{Name: "key", Type: "text"}, {Name: "value", Type: "text"}, {Name: "level", Type: "text"}, {Name: "type", Type: "text"}}
+		for i := range s.params {
+			p := &s.params[i]
+			resp.Data.RowSet = append(resp.Data.RowSet, []*string{&p[0], &p[1], &p[2], &p[3]})
+		}
+	case f[0] == "USE" && len(f) == 3:
+		name, _ := resolveIdentifier(f[2], false)
+		s.state[f[1]] = name
+		if f[1] == "DATABASE" {
+			s.state["SCHEMA"] = "PUBLIC"
+		}
+	}
+	resp.Data.FinalRoleName = s.state["ROLE"]
+	resp.Data.FinalWarehouseName = s.state["WAREHOUSE"]
+	resp.Data.FinalDatabaseName = s.state["DATABASE"]
+	resp.Data.FinalSchemaName = s.state["SCHEMA"]
+	resp.Data.Parameters = s.respParams
+	return resp, nil
+}
+
+// fakeSessionConn returns a connection to a fake session logged in with the role PUBLIC, the warehouse WH, the
+// database DB and the schema PUBLIC.
+func fakeSessionConn() (*snowflakeConn, *fakeSession) {
+	s := &fakeSession{state: map[string]string{"ROLE": "PUBLIC", "WAREHOUSE": "WH", "DATABASE": "DB", "SCHEMA": "PUBLIC"}}
+	sc := &snowflakeConn{
+		cfg: &Config{
+			Role: "PUBLIC", Warehouse: "WH", Database: "DB", Schema: "PUBLIC", Params: make(map[string]*string)},
+		rest:         &snowflakeRestful{FuncPostQuery: s.postQuery},
+		loginContext: UseOptions{Role: "PUBLIC", Warehouse: "WH", Database: "DB", Schema: "PUBLIC"},
+	}
+	return sc, s
+}
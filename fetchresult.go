@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/google/uuid"
+)
+
+// FetchResultByID returns the rows of the result of a query run before, e.g., by another connection of the same
+// user, from the persisted result without running the query again. Snowflake keeps the results for 24 hours, so
+// a web backend can page through a large result across requests by the query ID:
+//
+//	err = conn.Raw(func(dc interface{}) error {
+//		rows, err := dc.(sf.SnowflakeConn).FetchResultByID(ctx, queryID)
+//		...
+//	})
+//
+// The rows of a multi-statement query are the results of its statements, moved to by NextResultSet.
+func (sc *snowflakeConn) FetchResultByID(ctx context.Context, queryID string) (driver.Rows, error) {
+	if err := sc.checkConn(); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(queryID); err != nil {
+		return nil, &SnowflakeError{
+			Number:      ErrInvalidQueryID,
+			SQLState:    SQLStateSyntaxError,
+			Message:     errMsgInvalidQueryID,
+			MessageArgs: []interface{}{queryID},
+		}
+	}
+	ctx, cancel := withStatementDeadline(ctx, sc.cfg.QueryTimeout)
+	data, err := sc.queryResult(ctx, queryID)
+	if err != nil {
+		cancel()
+		return nil, withQueryID(queryTimeoutError(ctx, err), queryID)
+	}
+	if data.Data.QueryID == "" {
+		data.Data.QueryID = queryID
+	}
+	rows := sc.newRows(ctx, cancel)
+	if ids := multiStatementResultIDs(data); len(ids) > 0 {
+		if data, err = sc.queryResult(ctx, ids[0]); err != nil {
+			cancel()
+			return nil, queryTimeoutError(ctx, err)
+		}
+		rows.resultIDs = ids[1:]
+	}
+	if err = rows.setResult(data); err != nil {
+		cancel()
+		return nil, withQueryID(err, data.Data.QueryID)
+	}
+	return rows, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFetchResultByID(t *testing.T) {
+	const queryID = "01a2b3c4-0601-2c3d-0000-a1b2c3d4e5f6"
+	a, b := "a", "b"
+	var fetched []string
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncGetQueryResult = func(_ context.Context, _ *snowflakeRestful, id string) (*execResponse, error) {
+		fetched = append(fetched, id)
+		if id != queryID {
+			return &execResponse{Success: false, Code: "000709", Message: "Statement not found"}, nil
+		}
+		return &execResponse{Success: true, Data: execResponseData{
+			RowType: []execResponseRowType{{Name: "C1", Type: "text"}},
+			RowSet:  [][]*string{{&a}, {&b}},
+			Total:   2,
+		}}, nil
+	}
+	rows, err := sc.FetchResultByID(context.Background(), queryID)
+	if err != nil {
+		t.Fatalf("failed to fetch the result. err: %v", err)
+	}
+	defer rows.Close()
+	var got []driver.Value
+	dest := make([]driver.Value, 1)
+	for {
+		if err = rows.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read the rows. err: %v", err)
+		}
+		got = append(got, dest[0])
+	}
+	if !reflect.DeepEqual(got, []driver.Value{"a", "b"}) {
+		t.Errorf("failed to read the result. got: %v", got)
+	}
+	if id := rows.(*snowflakeRows).QueryID(); id != queryID {
+		t.Errorf("failed to set the query ID. got: %v", id)
+	}
+
+	_, err = sc.FetchResultByID(context.Background(), "01a2b3c4-0601-2c3d-0000-000000000000")
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != 709 || driverErr.QueryID == "" {
+		t.Errorf("should have failed with the error of the server. err: %v", err)
+	}
+	fetched = nil
+	for _, id := range []string{"", "../session", "01a2b3c4"} {
+		_, err = sc.FetchResultByID(context.Background(), id)
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidQueryID {
+			t.Errorf("should have failed with the invalid query ID. id: %q, err: %v", id, err)
+		}
+	}
+	if len(fetched) != 0 {
+		t.Errorf("should not have fetched the invalid query IDs. fetched: %v", fetched)
+	}
+}
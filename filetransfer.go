@@ -0,0 +1,613 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Commands of the PUT and GET responses
+const (
+	fileTransferUpload   = "UPLOAD"
+	fileTransferDownload = "DOWNLOAD"
+)
+
+// Status of the files in the PUT and GET results
+const (
+	fileStatusUploaded   = "UPLOADED"
+	fileStatusDownloaded = "DOWNLOADED"
+	fileStatusSkipped    = "SKIPPED"
+)
+
+// Compression types of the files. An empty type is no compression.
+const (
+	compressionAutoDetect = "AUTO_DETECT"
+	compressionNone       = "NONE"
+	compressionGzip       = "GZIP"
+	compressionBz2        = "BZ2"
+	compressionBrotli     = "BROTLI"
+	compressionZstd       = "ZSTD"
+	compressionDeflate    = "DEFLATE"
+	compressionRawDeflate = "RAW_DEFLATE"
+	compressionParquet    = "PARQUET"
+	compressionOrc        = "ORC"
+)
+
+const fileStreamKey contextKey = "fileStream"
+
+// WithFileStream returns a context that uploads the data read from the reader by the PUT run with it, instead of
+// the local file. The file name in the PUT command is the name of the file in the stage:
+//
+//	ctx := sf.WithFileStream(context.Background(), r)
+//	_, err = db.ExecContext(ctx, "PUT file://data.csv @mystage")
+//
+// The data are compressed if AUTO_COMPRESS is set, and encrypted if the stage requires, as read, and uploaded in
+// parts of uploadPartSize, so that only uploadPartParallel parts are held in memory. Unless OVERWRITE=TRUE, the PUT
+// skips the stream if a file of the name is in the stage, as the content of the stream can't be compared.
+func WithFileStream(ctx context.Context, r io.Reader) context.Context {
+	return context.WithValue(ctx, fileStreamKey, r)
+}
+
+// UploadToStage uploads the data read from r to the file of the name in the stage location, i.e., the stageInfo of a
+// PUT response, or the stage location given by the Snowflake REST APIs that upload files without a query, such as the
+// Snowpipe Streaming API. The data are uploaded as read, neither compressed nor encrypted on the client. The
+// transport is SnowflakeTransport if nil.
+func UploadToStage(ctx context.Context, transport http.RoundTripper, location []byte, name string, r io.Reader) error {
+	var stage execResponseStageInfo
+	if err := json.Unmarshal(location, &stage); err != nil {
+		return err
+	}
+	if transport == nil {
+		transport = SnowflakeTransport
+	}
+	client, err := newStorageClient(&http.Client{Transport: transport}, &stage)
+	if err != nil {
+		return err
+	}
+	return client.upload(ctx, name, &fileMetadata{srcFileName: name, dstFileName: name, uploadStream: r})
+}
+
+// fileMetadata is the state of a file in PUT or GET.
+type fileMetadata struct {
+	srcFileName        string
+	srcSize            int64
+	srcCompression     string
+	dstFileName        string
+	dstSize            int64
+	dstCompression     string
+	srcStream          io.Reader // the data of PUT given by WithFileStream instead of the source file
+	uploadFile         string    // the file uploaded to the stage, compressed and encrypted if required
+	uploadStream       io.Reader // the data uploaded to the stage instead of the upload file, read once
+	digest             string    // SHA-256 of the file in the stage before the encryption, in base64
+	encryptionMaterial *snowflakeFileEncryption
+	encryptMeta        *encryptMetadata
+	presignedURL       string // URL to download the file of GET from
+	status             string
+	message            string
+}
+
+// transferFiles runs the PUT or GET in the response on the client, and returns the result of the files.
+func (sc *snowflakeConn) transferFiles(ctx context.Context, data *execResponse) (*execResponse, error) {
+	d := &data.Data
+	glog.V(2).Infof("file transfer: %v, files: %v", d.Command, d.SrcLocations)
+	stage := d.StageInfo
+	if stage == nil {
+		stage = &execResponseStageInfo{}
+	}
+	client, err := newStorageClient(sc.rest.Client, stage)
+	if err != nil {
+		return nil, err
+	}
+	switch c := client.(type) {
+	case *s3Storage:
+		c.partSize, c.partParallel = sc.cfg.UploadPartSize, sc.cfg.UploadPartParallel
+	case *azureStorage:
+		c.partSize, c.partParallel = sc.cfg.UploadPartSize, sc.cfg.UploadPartParallel
+	case *gcsStorage:
+		c.partSize = sc.cfg.UploadPartSize
+	}
+	tmpDir, err := ioutil.TempDir("", "gosnowflake")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var files []*fileMetadata
+	var transfer func(context.Context, *fileMetadata) error
+	switch strings.ToUpper(d.Command) {
+	case fileTransferUpload:
+		if files, err = uploadFiles(ctx, d); err != nil {
+			return nil, err
+		}
+		transfer = func(ctx context.Context, meta *fileMetadata) error {
+			return uploadFile(ctx, client, d, tmpDir, meta)
+		}
+	case fileTransferDownload:
+		files = downloadFiles(d)
+		transfer = func(ctx context.Context, meta *fileMetadata) error {
+			return downloadFile(ctx, client, d, tmpDir, meta)
+		}
+	default:
+		return nil, &SnowflakeError{
+			Number:      ErrUnknownFileTransferCommand,
+			Message:     errMsgUnknownFileTransferCommand,
+			MessageArgs: []interface{}{d.Command},
+		}
+	}
+	if err = transferParallel(ctx, d.Parallel, files, transfer); err != nil {
+		return nil, err
+	}
+	return fileTransferResult(d, files), nil
+}
+
+// transferParallel transfers the files with the given number of workers, and returns the first error.
+func transferParallel(ctx context.Context, parallel int, files []*fileMetadata, transfer func(context.Context, *fileMetadata) error) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	queue := make(chan *fileMetadata)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for meta := range queue {
+				if err := transfer(ctx, meta); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, meta := range files {
+		queue <- meta
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+// uploadFiles returns the local files of the source locations of PUT, which may have wildcards, or the stream of
+// WithFileStream.
+func uploadFiles(ctx context.Context, d *execResponseData) ([]*fileMetadata, error) {
+	if r, ok := ctx.Value(fileStreamKey).(io.Reader); ok && r != nil {
+		if len(d.SrcLocations) != 1 {
+			return nil, &SnowflakeError{
+				Number:      ErrFileNotExists,
+				Message:     errMsgFileNotExists,
+				MessageArgs: []interface{}{strings.Join(d.SrcLocations, ", ")},
+			}
+		}
+		name := filepath.Base(strings.TrimPrefix(d.SrcLocations[0], "file://"))
+		return []*fileMetadata{{srcFileName: name, dstFileName: name, srcStream: r}}, nil
+	}
+	var files []*fileMetadata
+	for _, location := range d.SrcLocations {
+		pattern := expandUser(strings.TrimPrefix(location, "file://"))
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, &SnowflakeError{
+				Number:      ErrFileNotExists,
+				Message:     errMsgFileNotExists,
+				MessageArgs: []interface{}{location},
+			}
+		}
+		for _, name := range names {
+			fi, err := os.Stat(name)
+			if err != nil {
+				return nil, err
+			}
+			if fi.IsDir() {
+				continue
+			}
+			files = append(files, &fileMetadata{
+				srcFileName: name,
+				srcSize:     fi.Size(),
+				dstFileName: filepath.Base(name),
+			})
+		}
+	}
+	return files, nil
+}
+
+// downloadFiles returns the stage files of the source locations of GET.
+func downloadFiles(d *execResponseData) []*fileMetadata {
+	files := make([]*fileMetadata, len(d.SrcLocations))
+	for i, location := range d.SrcLocations {
+		meta := &fileMetadata{
+			srcFileName: location,
+			dstFileName: filepath.Base(location),
+		}
+		if i < len(d.EncryptionMaterial) {
+			meta.encryptionMaterial = d.EncryptionMaterial[i]
+		}
+		if i < len(d.PresignedURLs) {
+			meta.presignedURL = d.PresignedURLs[i]
+		}
+		files[i] = meta
+	}
+	return files
+}
+
+func uploadFile(ctx context.Context, client storageClient, d *execResponseData, tmpDir string, meta *fileMetadata) error {
+	var err error
+	if meta.srcStream != nil {
+		err = prepareStream(d, meta)
+	} else {
+		err = prepareFile(d, tmpDir, meta)
+	}
+	if err != nil {
+		return err
+	}
+	if !d.Overwrite {
+		h, err := client.fileHeader(ctx, meta.dstFileName)
+		if err != nil {
+			return err
+		}
+		// the digest of a stream is unknown until it's uploaded
+		if h != nil && (meta.srcStream != nil || h.digest == meta.digest) {
+			glog.V(2).Infof("skipping %v, which is in the stage", meta.srcFileName)
+			meta.status = fileStatusSkipped
+			return nil
+		}
+	}
+	var uploaded *countingReader
+	if meta.srcStream != nil {
+		r, stop := compressStream(meta)
+		defer stop()
+		uploaded = &countingReader{r: r}
+		meta.uploadStream = uploaded
+	}
+	if _, local := client.(*localStorage); !local && d.StageInfo.IsClientSideEncrypted && len(d.EncryptionMaterial) > 0 {
+		meta.encryptionMaterial = d.EncryptionMaterial[0]
+		encrypt := stageEncryptFunc(d.StageInfo)
+		if meta.uploadStream != nil {
+			if meta.encryptMeta, meta.uploadStream, err = encrypt(meta.encryptionMaterial, meta.uploadStream); err != nil {
+				return err
+			}
+			uploaded = &countingReader{r: meta.uploadStream}
+			meta.uploadStream = uploaded
+		} else {
+			encrypted := filepath.Join(tmpDir, meta.dstFileName+".enc")
+			if meta.encryptMeta, err = encryptFile(encrypt, meta.encryptionMaterial, meta.uploadFile, encrypted); err != nil {
+				return err
+			}
+			meta.uploadFile = encrypted
+		}
+	}
+	if err = client.upload(ctx, meta.dstFileName, meta); err != nil {
+		return err
+	}
+	meta.status = fileStatusUploaded
+	if uploaded != nil {
+		reportTransferProgress(ctx, TransferProgress{File: meta.dstFileName, Bytes: uploaded.n, Total: uploaded.n})
+	} else if fi, err := os.Stat(meta.uploadFile); err == nil {
+		reportTransferProgress(ctx, TransferProgress{File: meta.dstFileName, Bytes: fi.Size(), Total: fi.Size()})
+	}
+	return nil
+}
+
+// prepareFile compresses the source file into the temporary directory if required, and sets its digest.
+func prepareFile(d *execResponseData, tmpDir string, meta *fileMetadata) error {
+	var err error
+	if meta.srcCompression, err = sourceCompression(meta.srcFileName, d.SourceCompression); err != nil {
+		return err
+	}
+	meta.uploadFile = meta.srcFileName
+	meta.dstCompression = meta.srcCompression
+	if meta.srcCompression == "" && d.AutoCompress {
+		meta.dstFileName += ".gz"
+		meta.dstCompression = compressionGzip
+		meta.uploadFile = filepath.Join(tmpDir, meta.dstFileName)
+		if err = gzipFile(meta.srcFileName, meta.uploadFile); err != nil {
+			return err
+		}
+	}
+	meta.digest, meta.dstSize, err = fileDigest(meta.uploadFile)
+	return err
+}
+
+// prepareStream detects the compression of the source stream from its first bytes if required, and sets the
+// compression of the file in the stage. The stream is read by compressStream.
+func prepareStream(d *execResponseData, meta *fileMetadata) error {
+	r := bufio.NewReader(meta.srcStream)
+	meta.srcStream = r
+	var err error
+	if meta.srcCompression, err = streamCompression(r, meta.srcFileName, d.SourceCompression); err != nil {
+		return err
+	}
+	meta.dstCompression = meta.srcCompression
+	if meta.srcCompression == "" && d.AutoCompress {
+		meta.dstFileName += ".gz"
+		meta.dstCompression = compressionGzip
+	}
+	return nil
+}
+
+// compressStream returns the reader of the source stream compressed if required, which counts the sizes of the
+// source and the file in the stage. The stream is compressed by a goroutine, which is stopped by the function
+// returned.
+func compressStream(meta *fileMetadata) (io.Reader, func()) {
+	src := &countingReader{r: meta.srcStream}
+	if meta.dstCompression == meta.srcCompression {
+		dst := &countingReader{r: src}
+		return dst, func() { meta.srcSize, meta.dstSize = src.n, dst.n }
+	}
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := gzip.NewWriter(pw)
+		_, err := io.Copy(w, src)
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	dst := &countingReader{r: pr}
+	return dst, func() {
+		pr.Close()
+		<-done
+		meta.srcSize, meta.dstSize = src.n, dst.n
+	}
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func downloadFile(ctx context.Context, client storageClient, d *execResponseData, tmpDir string, meta *fileMetadata) error {
+	dir := expandUser(strings.TrimPrefix(d.LocalLocation, "file://"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(tmpDir, meta.dstFileName)
+	if err != nil {
+		return err
+	}
+	pw := &progressWriter{ctx: ctx, w: tmp, file: meta.srcFileName}
+	h, err := client.download(ctx, meta.srcFileName, meta, pw)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	reportTransferProgress(ctx, TransferProgress{File: meta.srcFileName, Download: true, Bytes: pw.written, Total: pw.written})
+	dst := filepath.Join(dir, meta.dstFileName)
+	if h.encryptMeta != nil && meta.encryptionMaterial != nil {
+		meta.encryptMeta = h.encryptMeta
+		if err = decryptFile(meta.encryptionMaterial, meta.encryptMeta, tmp.Name(), dst); err != nil {
+			return &SnowflakeError{
+				Number:      ErrInvalidEncryption,
+				Message:     errMsgInvalidEncryption,
+				MessageArgs: []interface{}{meta.srcFileName, err},
+				Err:         err,
+			}
+		}
+	} else if err = copyFile(tmp.Name(), dst); err != nil {
+		return err
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	meta.dstSize = fi.Size()
+	meta.status = fileStatusDownloaded
+	return nil
+}
+
+// sourceCompression returns the compression type of the file, detected from the content and the extension if
+// the SOURCE_COMPRESSION of PUT is AUTO_DETECT.
+func sourceCompression(name string, option string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return streamCompression(bufio.NewReader(f), name, option)
+}
+
+// streamCompression returns the compression type of the stream, detected from the first bytes and the file name if
+// the SOURCE_COMPRESSION of PUT is AUTO_DETECT. The stream isn't consumed.
+func streamCompression(r *bufio.Reader, name string, option string) (string, error) {
+	switch option = strings.ToUpper(option); option {
+	case compressionNone:
+		return "", nil
+	case "", compressionAutoDetect:
+	default:
+		return option, nil
+	}
+	head, err := r.Peek(4)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return compressionGzip, nil
+	case bytes.HasPrefix(head, []byte("BZh")):
+		return compressionBz2, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return compressionZstd, nil
+	case bytes.HasPrefix(head, []byte("PAR1")):
+		return compressionParquet, nil
+	case bytes.HasPrefix(head, []byte("ORC")):
+		return compressionOrc, nil
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".br":
+		return compressionBrotli, nil
+	case ".deflate":
+		return compressionDeflate, nil
+	case ".raw_deflate":
+		return compressionRawDeflate, nil
+	}
+	return "", nil
+}
+
+func gzipFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(out)
+	if _, err = io.Copy(w, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// fileDigest returns the SHA-256 of the file in base64, and the size of the file.
+func fileDigest(name string) (string, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func encryptFile(encrypt encryptFunc, material *snowflakeFileEncryption, src string, dst string) (*encryptMetadata, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	meta, r, err := encrypt(material, in)
+	if err == nil {
+		_, err = io.Copy(out, r)
+	}
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	return meta, out.Close()
+}
+
+// decryptFile decrypts src into a temporary file in the directory of dst, and renames it to dst only if the file
+// is decrypted and authenticated.
+func decryptFile(material *snowflakeFileEncryption, meta *encryptMetadata, src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if err = decryptStream(material, meta, in, out); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+// fileTransferResult returns the result set of PUT or GET, as returned by the other Snowflake drivers.
+func fileTransferResult(d *execResponseData, files []*fileMetadata) *execResponse {
+	text := func(name string) execResponseRowType {
+		return execResponseRowType{Name: name, Type: "text", Nullable: true}
+	}
+	fixed := func(name string) execResponseRowType {
+		return execResponseRowType{Name: name, Type: "fixed", Precision: 38, Nullable: true}
+	}
+	compression := func(c string) string {
+		if c == "" {
+			return compressionNone
+		}
+		return c
+	}
+	sp := func(s string) *string { return &s }
+
+	var rowType []execResponseRowType
+	rowSet := make([][]*string, 0, len(files))
+	if strings.ToUpper(d.Command) == fileTransferUpload {
+		rowType = []execResponseRowType{
+			text("source"), text("target"), fixed("source_size"), fixed("target_size"),
+			text("source_compression"), text("target_compression"), text("status"), text("message"),
+		}
+		for _, f := range files {
+			rowSet = append(rowSet, []*string{
+				sp(filepath.Base(f.srcFileName)), sp(f.dstFileName),
+				sp(strconv.FormatInt(f.srcSize, 10)), sp(strconv.FormatInt(f.dstSize, 10)),
+				sp(compression(f.srcCompression)), sp(compression(f.dstCompression)),
+				sp(f.status), sp(f.message),
+			})
+		}
+	} else {
+		rowType = []execResponseRowType{text("file"), fixed("size"), text("status"), text("message")}
+		for _, f := range files {
+			rowSet = append(rowSet, []*string{
+				sp(f.dstFileName), sp(strconv.FormatInt(f.dstSize, 10)), sp(f.status), sp(f.message),
+			})
+		}
+	}
+	return &execResponse{
+		Data: execResponseData{
+			RowType:         rowType,
+			RowSet:          rowSet,
+			Total:           int64(len(rowSet)),
+			Returned:        int64(len(rowSet)),
+			QueryID:         d.QueryID,
+			StatementTypeID: d.StatementTypeID,
+		},
+		Success: true,
+	}
+}
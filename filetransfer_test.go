@@ -0,0 +1,801 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeStageObject struct {
+	body   []byte
+	header http.Header
+}
+
+// fakeStage is a cloud storage in memory. The objects are keyed by the host and path of the URL. The multipart
+// uploads of S3, the blocks of Azure and the resumable uploads of GCS are kept until completed.
+type fakeStage struct {
+	received int64 // bytes of the request bodies read so far, updated atomically
+	mu       sync.Mutex
+	objects  map[string]*fakeStageObject
+	requests []*http.Request
+	uploads  map[string]*fakeMultipartUpload
+	failPart string // part number failing with 403
+	maxBody  int    // the largest request body
+}
+
+type fakeMultipartUpload struct {
+	header http.Header
+	parts  map[string][]byte
+}
+
+func metaHeader(h http.Header) http.Header {
+	meta := make(http.Header)
+	for k, v := range h {
+		if strings.Contains(strings.ToLower(k), "-meta-") {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+func (s *fakeStage) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		// read before locking, so that a stream is read as uploaded
+		var err error
+		if body, err = ioutil.ReadAll(&fakeStageBody{r: req.Body, s: s}); err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if len(body) > s.maxBody {
+		s.maxBody = len(body)
+	}
+	key := req.URL.Host + req.URL.Path
+	res := &http.Response{Request: req, Header: make(http.Header), Body: ioutil.NopCloser(bytes.NewReader(nil))}
+	q := req.URL.Query()
+	if _, ok := q["uploads"]; ok || q.Get("uploadId") != "" {
+		return s.multipart(req, res, key, q)
+	}
+	if q.Get("comp") != "" || req.Header.Get("x-goog-resumable") != "" || strings.HasPrefix(req.URL.Path, "/upload/") {
+		return s.blocks(req, res, key, q, body)
+	}
+	switch req.Method {
+	case "PUT":
+		s.objects[key] = &fakeStageObject{body: body, header: metaHeader(req.Header)}
+		res.StatusCode = http.StatusOK
+		if strings.HasSuffix(req.URL.Host, "blob.core.windows.net") {
+			res.StatusCode = http.StatusCreated
+		}
+	case "HEAD", "GET":
+		o, ok := s.objects[key]
+		if !ok {
+			res.StatusCode = http.StatusNotFound
+			break
+		}
+		res.StatusCode = http.StatusOK
+		res.Header = o.header
+		res.ContentLength = int64(len(o.body))
+		if req.Method == "GET" {
+			res.Body = ioutil.NopCloser(bytes.NewReader(o.body))
+		}
+	default:
+		res.StatusCode = http.StatusMethodNotAllowed
+	}
+	return res, nil
+}
+
+// fakeStageBody counts the bytes of a request body read.
+type fakeStageBody struct {
+	r io.Reader
+	s *fakeStage
+}
+
+func (b *fakeStageBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	atomic.AddInt64(&b.s.received, int64(n))
+	return n, err
+}
+
+// blocks handles the requests of the blocks of Azure and the resumable uploads of GCS. The blocks and the parts
+// are kept as the parts of the uploads keyed by the object.
+func (s *fakeStage) blocks(req *http.Request, res *http.Response, key string, q url.Values, body []byte) (*http.Response, error) {
+	if s.uploads == nil {
+		s.uploads = make(map[string]*fakeMultipartUpload)
+	}
+	u, ok := s.uploads[key]
+	if !ok {
+		u = &fakeMultipartUpload{parts: make(map[string][]byte)}
+		s.uploads[key] = u
+	}
+	res.StatusCode = http.StatusCreated
+	switch {
+	case q.Get("comp") == "block":
+		u.parts[q.Get("blockid")] = body
+	case q.Get("comp") == "blocklist":
+		var list azureBlockList
+		if err := xml.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+		var b []byte
+		for _, id := range list.Latest {
+			b = append(b, u.parts[id]...)
+		}
+		s.objects[key] = &fakeStageObject{body: b, header: metaHeader(req.Header)}
+		delete(s.uploads, key)
+	case req.Method == "POST":
+		u.header = metaHeader(req.Header)
+		res.Header.Set("Location", "https://"+req.URL.Host+"/upload/"+key)
+	default:
+		// the parts of GCS must be in order
+		var start, end int64
+		var total string
+		if _, err := fmt.Sscanf(req.Header.Get("Content-Range"), "bytes %d-%d/%s", &start, &end, &total); err == nil {
+			if start != int64(len(u.parts["0"])) || end-start+1 != int64(len(body)) {
+				res.StatusCode = http.StatusBadRequest
+				break
+			}
+			u.parts["0"] = append(u.parts["0"], body...)
+		} else if _, err = fmt.Sscanf(req.Header.Get("Content-Range"), "bytes */%s", &total); err != nil {
+			res.StatusCode = http.StatusBadRequest
+			break
+		}
+		if total == "*" {
+			res.StatusCode = http.StatusPermanentRedirect
+			break
+		}
+		if total != strconv.Itoa(len(u.parts["0"])) {
+			res.StatusCode = http.StatusBadRequest
+			break
+		}
+		object := strings.TrimPrefix(req.URL.Path, "/upload/")
+		s.objects[object] = &fakeStageObject{body: u.parts["0"], header: s.uploads[object].header}
+		delete(s.uploads, key)
+		delete(s.uploads, object)
+		res.StatusCode = http.StatusOK
+	}
+	return res, nil
+}
+
+// multipart handles the requests of the multipart uploads of S3.
+func (s *fakeStage) multipart(req *http.Request, res *http.Response, key string, q url.Values) (*http.Response, error) {
+	if s.uploads == nil {
+		s.uploads = make(map[string]*fakeMultipartUpload)
+	}
+	res.StatusCode = http.StatusOK
+	id := q.Get("uploadId")
+	u, ok := s.uploads[id]
+	switch {
+	case req.Method == "POST" && id == "":
+		id = strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = &fakeMultipartUpload{header: metaHeader(req.Header), parts: make(map[string][]byte)}
+		res.Body = ioutil.NopCloser(strings.NewReader(
+			"<InitiateMultipartUploadResult><UploadId>" + id + "</UploadId></InitiateMultipartUploadResult>"))
+	case !ok:
+		res.StatusCode = http.StatusNotFound
+	case req.Method == "PUT":
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if q.Get("partNumber") == s.failPart {
+			res.StatusCode = http.StatusForbidden
+			break
+		}
+		u.parts[q.Get("partNumber")] = b
+		res.Header.Set("ETag", `"etag-`+q.Get("partNumber")+`"`)
+	case req.Method == "POST":
+		var complete s3CompleteMultipartUpload
+		if err := xml.NewDecoder(req.Body).Decode(&complete); err != nil {
+			return nil, err
+		}
+		var body []byte
+		for i, p := range complete.Parts {
+			n := strconv.Itoa(p.PartNumber)
+			if p.PartNumber != i+1 || p.ETag != `"etag-`+n+`"` {
+				res.StatusCode = http.StatusBadRequest
+				return res, nil
+			}
+			body = append(body, u.parts[n]...)
+		}
+		s.objects[key] = &fakeStageObject{body: body, header: u.header}
+		delete(s.uploads, id)
+	case req.Method == "DELETE":
+		delete(s.uploads, id)
+	}
+	return res, nil
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %v. err: %v", name, err)
+		}
+	}
+}
+
+func gunzipFile(t *testing.T, name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("failed to open %v. err: %v", name, err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to read %v in gzip. err: %v", name, err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read %v in gzip. err: %v", name, err)
+	}
+	return string(b)
+}
+
+func TestUnitPutGet(t *testing.T) {
+	files := map[string]string{"a.csv": "1,2\n3,4\n", "b.csv": strings.Repeat("5,6\n", 100000)}
+	s3 := execResponseStageInfo{
+		LocationType: "S3", Location: "bucket/stage/path", Region: "us-west-2",
+		Creds: execResponseCredentials{AwsKeyID: "key", AwsSecretKey: "secret", AwsToken: "token"},
+	}
+	azure := execResponseStageInfo{
+		LocationType: "AZURE", Location: "container/stage/", StorageAccount: "account",
+		Creds: execResponseCredentials{AzureSasToken: "?sv=2018&sig=abc"},
+	}
+	gcs := execResponseStageInfo{
+		LocationType: "GCS", Location: "bucket/stage",
+		Creds: execResponseCredentials{GcsAccessToken: "token"},
+	}
+	withCiphers := func(stage execResponseStageInfo, ciphers string) execResponseStageInfo {
+		stage.Ciphers = ciphers
+		return stage
+	}
+	testcases := []struct {
+		name  string
+		stage execResponseStageInfo
+		sse   bool // not encrypted by the client
+	}{
+		{name: "local", stage: execResponseStageInfo{LocationType: "LOCAL_FS"}},
+		{name: "s3", stage: s3},
+		{name: "azure", stage: azure},
+		{name: "gcs", stage: gcs},
+		{name: "s3 gcm", stage: withCiphers(s3, "AES_GCM,AES_CBC")},
+		{name: "azure gcm", stage: withCiphers(azure, "AES_GCM,AES_CBC")},
+		{name: "gcs gcm", stage: withCiphers(gcs, "AES_GCM,AES_CBC")},
+		{name: "s3 sse", stage: s3, sse: true},
+	}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			location := strings.Fields(test.name)[0]
+			srcDir, err := ioutil.TempDir("", "gosnowflake_put")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(srcDir)
+			writeTestFiles(t, srcDir, files)
+			dstDir := filepath.Join(srcDir, "get")
+			if test.stage.LocationType == "LOCAL_FS" {
+				test.stage.Location = filepath.Join(srcDir, "stage")
+			}
+			test.stage.IsClientSideEncrypted = !test.sse
+			material := testEncryptionMaterial(t, 16)
+
+			stage := &fakeStage{objects: make(map[string]*fakeStageObject)}
+			sc := getDefaultSnowflakeConn()
+			sc.rest.Client = &http.Client{Transport: stage}
+			put := &execResponse{Data: execResponseData{
+				Command:            "UPLOAD",
+				SrcLocations:       []string{"file://" + filepath.Join(srcDir, "*.csv")},
+				Parallel:           2,
+				AutoCompress:       true,
+				SourceCompression:  "auto_detect",
+				StageInfo:          &test.stage,
+				EncryptionMaterial: encryptionMaterialList{material},
+			}}
+			result, err := sc.transferFiles(context.Background(), put)
+			if err != nil {
+				t.Fatalf("failed to put the files. err: %v", err)
+			}
+			if len(result.Data.RowSet) != 2 {
+				t.Fatalf("failed to get the result of the files. got: %v", len(result.Data.RowSet))
+			}
+			for i, name := range []string{"a.csv", "b.csv"} {
+				row := result.Data.RowSet[i]
+				if *row[0] != name || *row[1] != name+".gz" || *row[4] != "NONE" || *row[5] != "GZIP" || *row[6] != "UPLOADED" {
+					t.Errorf("failed to get the result of %v. got: %v, %v, %v, %v, %v", name, *row[0], *row[1], *row[4], *row[5], *row[6])
+				}
+			}
+			for key, o := range stage.objects {
+				if encrypted := !bytes.HasPrefix(o.body, []byte{0x1f, 0x8b}); encrypted == test.sse {
+					t.Errorf("failed to encrypt %v by the client. encrypted: %v", key, encrypted)
+				}
+				if gcm := o.header.Get(s3MetaKeyIV) != "" || strings.Contains(o.header.Get(azureMetaEncryptionData)+
+					o.header.Get(gcsMetaEncryptionData), "AES_GCM_256"); gcm != (test.stage.Ciphers != "") {
+					t.Errorf("failed to choose the cipher of %v. gcm: %v", key, gcm)
+				}
+			}
+			for _, req := range stage.requests {
+				if location == "s3" && !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+					t.Errorf("failed to sign the request to S3. got: %v", req.Header.Get("Authorization"))
+				}
+				if location == "s3" && req.Method == "PUT" && (req.Header.Get(s3ServerSideEncryption) == "AES256") != test.sse {
+					t.Errorf("failed to set the server-side encryption. got: %v", req.Header.Get(s3ServerSideEncryption))
+				}
+				if location == "azure" && req.URL.RawQuery != "sv=2018&sig=abc" {
+					t.Errorf("failed to set the SAS token. got: %v", req.URL.RawQuery)
+				}
+				if location == "gcs" && req.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("failed to set the access token. got: %v", req.Header.Get("Authorization"))
+				}
+			}
+
+			// the files in the stage are skipped unless OVERWRITE is set
+			if result, err = sc.transferFiles(context.Background(), put); err != nil {
+				t.Fatalf("failed to put the files again. err: %v", err)
+			}
+			for _, row := range result.Data.RowSet {
+				if *row[6] != "SKIPPED" {
+					t.Errorf("failed to skip %v. got: %v", *row[0], *row[6])
+				}
+			}
+
+			get := &execResponse{Data: execResponseData{
+				Command:            "DOWNLOAD",
+				SrcLocations:       []string{"a.csv.gz", "b.csv.gz"},
+				LocalLocation:      "file://" + dstDir,
+				StageInfo:          &test.stage,
+				EncryptionMaterial: encryptionMaterialList{material, material},
+			}}
+			if result, err = sc.transferFiles(context.Background(), get); err != nil {
+				t.Fatalf("failed to get the files. err: %v", err)
+			}
+			for i, name := range []string{"a.csv", "b.csv"} {
+				row := result.Data.RowSet[i]
+				if *row[0] != name+".gz" || *row[2] != "DOWNLOADED" {
+					t.Errorf("failed to get the result of %v. got: %v, %v", name, *row[0], *row[2])
+				}
+				if got := gunzipFile(t, filepath.Join(dstDir, name+".gz")); got != files[name] {
+					t.Errorf("failed to download %v. expected %v bytes, got %v bytes", name, len(files[name]), len(got))
+				}
+			}
+		})
+	}
+}
+
+func TestUnitPutMultipart(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	srcDir, err := ioutil.TempDir("", "gosnowflake_put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srcDir)
+	writeTestFiles(t, srcDir, map[string]string{"c.bin": string(data)})
+	stageInfo := &execResponseStageInfo{
+		LocationType: "S3", Location: "bucket/stage/", Region: "us-west-2", IsClientSideEncrypted: true,
+		Creds: execResponseCredentials{AwsKeyID: "key", AwsSecretKey: "secret"},
+	}
+	material := testEncryptionMaterial(t, 16)
+	put := &execResponse{Data: execResponseData{
+		Command:            "UPLOAD",
+		SrcLocations:       []string{"file://" + filepath.Join(srcDir, "c.bin")},
+		Parallel:           1,
+		SourceCompression:  "none",
+		StageInfo:          stageInfo,
+		EncryptionMaterial: encryptionMaterialList{material},
+	}}
+
+	stage := &fakeStage{objects: make(map[string]*fakeStageObject)}
+	sc := getDefaultSnowflakeConn()
+	sc.rest.Client = &http.Client{Transport: stage}
+	sc.cfg.UploadPartSize = 3000 // smaller than allowed in DSN
+	sc.cfg.UploadPartParallel = 2
+	var (
+		mu     sync.Mutex
+		events []TransferProgress
+	)
+	ctx := WithTransferProgress(context.Background(), func(p TransferProgress) {
+		mu.Lock()
+		events = append(events, p)
+		mu.Unlock()
+	})
+	result, err := sc.transferFiles(ctx, put)
+	if err != nil {
+		t.Fatalf("failed to put the file. err: %v", err)
+	}
+	if status := *result.Data.RowSet[0][6]; status != "UPLOADED" {
+		t.Fatalf("failed to upload the file. got: %v", status)
+	}
+	parts := 0
+	for _, req := range stage.requests {
+		if req.URL.Query().Get("partNumber") != "" {
+			parts++
+		}
+	}
+	// the file encrypted in AES-CBC is padded to 10016 bytes
+	if parts != 4 {
+		t.Errorf("failed to upload the file in parts. expected: 4, got: %v", parts)
+	}
+	if len(events) != 4 || events[3].Bytes != events[3].Total || events[3].File != "c.bin" {
+		t.Errorf("failed to report the progress. got: %v", events)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Bytes <= events[i-1].Bytes {
+			t.Errorf("failed to report the progress in order. got: %v", events)
+		}
+	}
+
+	get := &execResponse{Data: execResponseData{
+		Command:            "DOWNLOAD",
+		SrcLocations:       []string{"c.bin"},
+		LocalLocation:      "file://" + filepath.Join(srcDir, "get"),
+		StageInfo:          stageInfo,
+		EncryptionMaterial: encryptionMaterialList{material},
+	}}
+	events = nil
+	if _, err = sc.transferFiles(ctx, get); err != nil {
+		t.Fatalf("failed to get the file. err: %v", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(srcDir, "get", "c.bin")); err != nil || !bytes.Equal(b, data) {
+		t.Errorf("failed to download the file uploaded in parts. err: %v", err)
+	}
+	if len(events) != 1 || !events[0].Download || events[0].Bytes != 10016 || events[0].Total != 10016 {
+		t.Errorf("failed to report the progress of the download. got: %v", events)
+	}
+
+	// a part failed aborts the upload
+	stage = &fakeStage{objects: make(map[string]*fakeStageObject), failPart: "2"}
+	sc.rest.Client = &http.Client{Transport: stage}
+	if _, err = sc.transferFiles(context.Background(), put); err == nil {
+		t.Error("should have failed to upload the file")
+	}
+	if len(stage.objects) != 0 || len(stage.uploads) != 0 {
+		t.Errorf("failed to abort the upload. objects: %v, uploads: %v", len(stage.objects), len(stage.uploads))
+	}
+}
+
+func TestUnitPutStream(t *testing.T) {
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	w.Write([]byte("7,8\n"))
+	w.Close()
+	testcases := []struct {
+		name        string
+		src         []byte
+		stage       execResponseStageInfo
+		target      string
+		compression string
+	}{
+		{name: "local", src: []byte("1,2\n3,4\n"), stage: execResponseStageInfo{LocationType: "LOCAL_FS"}, target: "data.csv.gz", compression: "NONE"},
+		{name: "s3", src: bytes.Repeat([]byte("5,6\n"), 100000), stage: execResponseStageInfo{
+			LocationType: "S3", Location: "bucket/stage", IsClientSideEncrypted: true,
+			Creds: execResponseCredentials{AwsKeyID: "key", AwsSecretKey: "secret"},
+		}, target: "data.csv.gz", compression: "NONE"},
+		{name: "gzipped", src: gzipped.Bytes(), stage: execResponseStageInfo{
+			LocationType: "AZURE", Location: "container/stage", StorageAccount: "account", IsClientSideEncrypted: true,
+		}, target: "data.csv", compression: "GZIP"},
+	}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gosnowflake_put")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if test.stage.LocationType == "LOCAL_FS" {
+				test.stage.Location = filepath.Join(dir, "stage")
+			}
+			material := testEncryptionMaterial(t, 16)
+			sc := getDefaultSnowflakeConn()
+			sc.rest.Client = &http.Client{Transport: &fakeStage{objects: make(map[string]*fakeStageObject)}}
+			ctx := WithFileStream(context.Background(), bytes.NewReader(test.src))
+			result, err := sc.transferFiles(ctx, &execResponse{Data: execResponseData{
+				Command:            "UPLOAD",
+				SrcLocations:       []string{"data.csv"},
+				AutoCompress:       true,
+				StageInfo:          &test.stage,
+				EncryptionMaterial: encryptionMaterialList{material},
+			}})
+			if err != nil {
+				t.Fatalf("failed to put the stream. err: %v", err)
+			}
+			row := result.Data.RowSet[0]
+			if *row[0] != "data.csv" || *row[1] != test.target || *row[2] != strconv.Itoa(len(test.src)) || *row[4] != test.compression || *row[6] != "UPLOADED" {
+				t.Errorf("failed to get the result. got: %v, %v, %v, %v, %v", *row[0], *row[1], *row[2], *row[4], *row[6])
+			}
+
+			get := &execResponse{Data: execResponseData{
+				Command:            "DOWNLOAD",
+				SrcLocations:       []string{test.target},
+				LocalLocation:      dir,
+				StageInfo:          &test.stage,
+				EncryptionMaterial: encryptionMaterialList{material},
+			}}
+			if _, err = sc.transferFiles(context.Background(), get); err != nil {
+				t.Fatalf("failed to get the file. err: %v", err)
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, test.target))
+			if err != nil {
+				t.Fatalf("failed to read the file. err: %v", err)
+			}
+			if test.compression == "NONE" {
+				b = []byte(gunzipFile(t, filepath.Join(dir, test.target)))
+			}
+			if !bytes.Equal(b, test.src) {
+				t.Errorf("failed to download the stream. expected %v bytes, got %v bytes", len(test.src), len(b))
+			}
+		})
+	}
+
+	ctx := WithFileStream(context.Background(), bytes.NewReader(nil))
+	_, err := getDefaultSnowflakeConn().transferFiles(ctx, &execResponse{Data: execResponseData{
+		Command:      "UPLOAD",
+		SrcLocations: []string{"a.csv", "b.csv"},
+		StageInfo:    &execResponseStageInfo{LocationType: "LOCAL_FS", Location: os.TempDir()},
+	}})
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrFileNotExists {
+		t.Errorf("should have failed to put a stream to several files. err: %v", err)
+	}
+}
+
+func TestUnitUploadToStage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stage")
+	if err != nil {
+		t.Fatalf("failed to create the stage. err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	location, _ := json.Marshal(execResponseStageInfo{LocationType: "LOCAL_FS", Location: dir})
+	if err = UploadToStage(context.Background(), nil, location, "2018/1/a.bdec", strings.NewReader("data")); err != nil {
+		t.Fatalf("failed to upload the stream. err: %v", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "2018", "1", "a.bdec")); err != nil || string(b) != "data" {
+		t.Errorf("failed to upload the stream. data: %q, err: %v", b, err)
+	}
+	err = UploadToStage(context.Background(), nil, []byte(`{"locationType":"FTP"}`), "a", strings.NewReader(""))
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidStageLocation {
+		t.Errorf("should have failed with the invalid stage location. err: %v", err)
+	}
+}
+
+// observedReader calls observe once when the bytes read reach at.
+type observedReader struct {
+	r       io.Reader
+	n       int64
+	at      int64
+	observe func()
+}
+
+func (r *observedReader) Read(p []byte) (int, error) {
+	if r.observe != nil && r.n >= r.at {
+		r.observe()
+		r.observe = nil
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func TestUnitPutStreamParts(t *testing.T) {
+	const partSize = gcsChunkSize
+	const size = 8*partSize + 1000
+	testcases := []struct {
+		name    string
+		stage   execResponseStageInfo
+		urls    []string // presigned URLs
+		maxBody int
+	}{
+		{name: "s3", stage: execResponseStageInfo{
+			LocationType: "S3", Location: "bucket/stage", IsClientSideEncrypted: true,
+			Creds: execResponseCredentials{AwsKeyID: "key", AwsSecretKey: "secret"},
+		}, maxBody: partSize},
+		{name: "azure", stage: execResponseStageInfo{
+			LocationType: "AZURE", Location: "container/stage", StorageAccount: "account", IsClientSideEncrypted: true,
+			Ciphers: "AES_GCM", Creds: execResponseCredentials{AzureSasToken: "?sig=x"},
+		}, maxBody: partSize},
+		{name: "gcs", stage: execResponseStageInfo{
+			LocationType: "GCS", Location: "bucket/stage", IsClientSideEncrypted: true,
+			Creds: execResponseCredentials{GcsAccessToken: "token"},
+		}, maxBody: partSize},
+		{name: "gcs presigned", stage: execResponseStageInfo{
+			LocationType: "GCS", Location: "bucket/stage", IsClientSideEncrypted: true,
+			PresignedURL: "https://storage.googleapis.com/bucket/stage/data.csv.gz?X-Goog-Signature=s",
+		}, urls: []string{"https://storage.googleapis.com/bucket/stage/data.csv.gz?X-Goog-Signature=s"}, maxBody: size + size/10},
+	}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gosnowflake_put")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			material := testEncryptionMaterial(t, 16)
+			stage := &fakeStage{objects: make(map[string]*fakeStageObject)}
+			sc := getDefaultSnowflakeConn()
+			sc.rest.Client = &http.Client{Transport: stage}
+			sc.cfg.UploadPartSize = partSize
+			sc.cfg.UploadPartParallel = 2
+			var received int64 = -1
+			src := &observedReader{
+				r:       io.LimitReader(rand.New(rand.NewSource(1)), size),
+				at:      6 * partSize,
+				observe: func() { received = atomic.LoadInt64(&stage.received) },
+			}
+			var events []TransferProgress
+			var mu sync.Mutex
+			ctx := WithTransferProgress(WithFileStream(context.Background(), src), func(p TransferProgress) {
+				mu.Lock()
+				events = append(events, p)
+				mu.Unlock()
+			})
+			result, err := sc.transferFiles(ctx, &execResponse{Data: execResponseData{
+				Command:            "UPLOAD",
+				SrcLocations:       []string{"data.csv"},
+				AutoCompress:       true,
+				StageInfo:          &test.stage,
+				EncryptionMaterial: encryptionMaterialList{material},
+			}})
+			if err != nil {
+				t.Fatalf("failed to put the stream. err: %v", err)
+			}
+			if row := result.Data.RowSet[0]; *row[2] != strconv.Itoa(size) || *row[6] != "UPLOADED" {
+				t.Errorf("failed to get the result. got: %v, %v", *row[2], *row[6])
+			}
+			if received <= 0 {
+				t.Errorf("should have uploaded the stream as read. received: %v", received)
+			}
+			if stage.maxBody > test.maxBody {
+				t.Errorf("should have uploaded the stream in parts. max body: %v", stage.maxBody)
+			}
+			if last := events[len(events)-1]; last.Bytes != last.Total || last.Total < size {
+				t.Errorf("failed to report the progress. got: %v", last)
+			}
+
+			if _, err = sc.transferFiles(context.Background(), &execResponse{Data: execResponseData{
+				Command:            "DOWNLOAD",
+				SrcLocations:       []string{"data.csv.gz"},
+				LocalLocation:      dir,
+				StageInfo:          &test.stage,
+				EncryptionMaterial: encryptionMaterialList{material},
+				PresignedURLs:      test.urls,
+			}}); err != nil {
+				t.Fatalf("failed to get the file. err: %v", err)
+			}
+			expected, err := ioutil.ReadAll(io.LimitReader(rand.New(rand.NewSource(1)), size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := gunzipFile(t, filepath.Join(dir, "data.csv.gz")); got != string(expected) {
+				t.Errorf("failed to download the stream. expected %v bytes, got %v bytes", size, len(got))
+			}
+		})
+	}
+}
+
+func TestUnitPutSourceCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake_put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFiles(t, dir, map[string]string{
+		"a.gz":   "\x1f\x8b\x08\x00",
+		"b.bz2":  "BZh91AY",
+		"c.br":   "xyz",
+		"d.txt":  "plain",
+		"e.zst":  "\x28\xb5\x2f\xfd",
+		"f.parq": "PAR1",
+	})
+	testcases := []struct {
+		file     string
+		option   string
+		expected string
+	}{
+		{file: "a.gz", option: "AUTO_DETECT", expected: "GZIP"},
+		{file: "b.bz2", option: "", expected: "BZ2"},
+		{file: "c.br", option: "auto_detect", expected: "BROTLI"},
+		{file: "d.txt", option: "AUTO_DETECT", expected: ""},
+		{file: "e.zst", option: "AUTO_DETECT", expected: "ZSTD"},
+		{file: "f.parq", option: "AUTO_DETECT", expected: "PARQUET"},
+		{file: "a.gz", option: "none", expected: ""},
+		{file: "d.txt", option: "deflate", expected: "DEFLATE"},
+	}
+	for _, test := range testcases {
+		got, err := sourceCompression(filepath.Join(dir, test.file), test.option)
+		if err != nil {
+			t.Fatalf("failed to detect the compression of %v. err: %v", test.file, err)
+		}
+		if got != test.expected {
+			t.Errorf("failed to detect the compression of %v with %v. expected: %v, got: %v", test.file, test.option, test.expected, got)
+		}
+	}
+}
+
+func TestUnitFileTransferErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosnowflake_put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stage := execResponseStageInfo{LocationType: "LOCAL_FS", Location: dir}
+	testcases := []struct {
+		data   execResponseData
+		number int
+	}{
+		{data: execResponseData{Command: "UPLOAD", SrcLocations: []string{filepath.Join(dir, "none*.csv")}, StageInfo: &stage}, number: ErrFileNotExists},
+		{data: execResponseData{Command: "LIST", StageInfo: &stage}, number: ErrUnknownFileTransferCommand},
+		{data: execResponseData{Command: "UPLOAD", StageInfo: &execResponseStageInfo{LocationType: "FTP"}}, number: ErrInvalidStageLocation},
+	}
+	sc := getDefaultSnowflakeConn()
+	for _, test := range testcases {
+		_, err := sc.transferFiles(context.Background(), &execResponse{Data: test.data})
+		driverErr, ok := err.(*SnowflakeError)
+		if !ok {
+			t.Errorf("should have failed with a SnowflakeError. command: %v, err: %v", test.data.Command, err)
+			continue
+		}
+		if driverErr.Number != test.number {
+			t.Errorf("failed to get the error. expected: %v, got: %v", test.number, driverErr.Number)
+		}
+	}
+}
+
+// flakyStorageClient fails the requests with 503 until the given number of failures.
+type flakyStorageClient struct {
+	failures int
+	attempts int
+}
+
+func (c *flakyStorageClient) Do(req *http.Request) (*http.Response, error) {
+	c.attempts++
+	status := http.StatusOK
+	if c.attempts <= c.failures {
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{StatusCode: status, Request: req, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func TestUnitStorageRetry(t *testing.T) {
+	defer func(interval time.Duration) { storageRetryInterval = interval }(storageRetryInterval)
+	storageRetryInterval = time.Millisecond
+	testcases := []struct {
+		failures int
+		status   int
+		attempts int
+	}{
+		{failures: 0, status: http.StatusOK, attempts: 1},
+		{failures: 2, status: http.StatusOK, attempts: 3},
+		{failures: 10, status: http.StatusServiceUnavailable, attempts: maxStorageRetries + 1},
+	}
+	for _, test := range testcases {
+		client := &flakyStorageClient{failures: test.failures}
+		res, err := doStorageRequest(context.Background(), client, &storageRequest{method: "GET", url: "https://example.com/a"})
+		if err != nil {
+			t.Fatalf("failed to send the request. err: %v", err)
+		}
+		if res.StatusCode != test.status || client.attempts != test.attempts {
+			t.Errorf("failed to retry the request. failures: %v, status: %v, attempts: %v", test.failures, res.StatusCode, client.attempts)
+		}
+	}
+}
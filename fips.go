@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+)
+
+var (
+	fipsModeMutex = &sync.RWMutex{}
+	fipsMode      bool
+)
+
+// fipsCipherSuites are the FIPS-approved TLS 1.2 cipher suites: ECDHE or RSA key exchange with AES-GCM.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the FIPS-approved elliptic curves for ECDHE.
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+// EnableFIPSMode restricts the TLS settings of the builtin transports to FIPS-approved algorithms: TLS 1.2,
+// AES-GCM cipher suites and the NIST P-256 and P-384 curves. The stage files are encrypted and decrypted only by
+// crypto/aes and crypto/cipher as well. Call it before opening connections. It is called
+// automatically if the driver is built with the fips tag or the Go FIPS 140 module is enabled. It can't be
+// disabled.
+func EnableFIPSMode() {
+	fipsModeMutex.Lock()
+	defer fipsModeMutex.Unlock()
+	if fipsMode {
+		return
+	}
+	fipsMode = true
+	for _, t := range []*http.Transport{SnowflakeTransport, SnowflakeTransportSerial, snowflakeInsecureTransport} {
+		t.TLSClientConfig = fipsTLSConfig(t.TLSClientConfig)
+	}
+	glog.V(2).Info("FIPS mode is enabled")
+}
+
+// isFIPSMode returns true if the crypto usage is restricted to FIPS-approved algorithms.
+func isFIPSMode() bool {
+	fipsModeMutex.RLock()
+	defer fipsModeMutex.RUnlock()
+	return fipsMode
+}
+
+// fipsTLSConfig restricts the TLS configuration to the FIPS-approved settings. c may be nil.
+func fipsTLSConfig(c *tls.Config) *tls.Config {
+	if c == nil {
+		c = &tls.Config{}
+	}
+	c.MinVersion = tls.VersionTLS12
+	c.MaxVersion = tls.VersionTLS12
+	c.CipherSuites = fipsCipherSuites
+	c.CurvePreferences = fipsCurves
+	return c
+}
@@ -0,0 +1,14 @@
+// +build fips
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	// restricts crypto/tls to the FIPS-approved settings. Requires the BoringCrypto Go toolchain.
+	_ "crypto/tls/fipsonly"
+)
+
+func init() {
+	EnableFIPSMode()
+}
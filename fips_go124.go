@@ -0,0 +1,15 @@
+// +build go1.24
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"crypto/fips140"
+)
+
+func init() {
+	if fips140.Enabled() {
+		EnableFIPSMode()
+	}
+}
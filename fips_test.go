@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"crypto/tls"
+	"testing"
+)
+
+// setFIPSMode sets the FIPS mode, which EnableFIPSMode can't unset, and returns the function to restore it.
+func setFIPSMode(enabled bool) func() {
+	fipsModeMutex.Lock()
+	orig := fipsMode
+	fipsMode = enabled
+	fipsModeMutex.Unlock()
+	return func() {
+		fipsModeMutex.Lock()
+		fipsMode = orig
+		fipsModeMutex.Unlock()
+	}
+}
+
+func TestFIPSTLSConfig(t *testing.T) {
+	testcases := []*tls.Config{
+		nil,
+		{RootCAs: certPool, MinVersion: tls.VersionTLS10},
+	}
+	for _, c := range testcases {
+		rootCAs := certPool
+		if c == nil {
+			rootCAs = nil
+		}
+		fc := fipsTLSConfig(c)
+		if fc.MinVersion != tls.VersionTLS12 || fc.MaxVersion != tls.VersionTLS12 {
+			t.Errorf("failed to restrict the TLS version. min: %v, max: %v", fc.MinVersion, fc.MaxVersion)
+		}
+		if len(fc.CipherSuites) != len(fipsCipherSuites) || len(fc.CurvePreferences) != len(fipsCurves) {
+			t.Errorf("failed to restrict the cipher suites and curves. suites: %v, curves: %v",
+				fc.CipherSuites, fc.CurvePreferences)
+		}
+		if fc.RootCAs != rootCAs {
+			t.Errorf("failed to keep the root CAs")
+		}
+	}
+}
+
+func TestNewTransportFIPSMode(t *testing.T) {
+	defer setFIPSMode(true)()
+
+	tr := newTransport(nil)
+	if tr.TLSClientConfig == nil || tr.TLSClientConfig.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("failed to apply the FIPS TLS settings to the new transport. config: %v", tr.TLSClientConfig)
+	}
+}
+
+func TestStageEncryptionFIPSMode(t *testing.T) {
+	material := testEncryptionMaterial(t, 16)
+	src := []byte("hello")
+	var encrypted bytes.Buffer
+	gcmMeta, err := encryptTo(encryptStreamGCM, material, bytes.NewReader(src), &encrypted)
+	if err != nil {
+		t.Fatalf("failed to encrypt. err: %v", err)
+	}
+
+	defer setFIPSMode(true)()
+	meta, err := encryptTo(stageEncryptFunc(&execResponseStageInfo{Ciphers: "AES_GCM,AES_CBC"}), material,
+		bytes.NewReader(nil), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("failed to encrypt. err: %v", err)
+	}
+	if meta.keyIV != "" {
+		t.Error("should have encrypted in AES-CBC in FIPS mode")
+	}
+	var decrypted bytes.Buffer
+	if err = decryptStream(material, gcmMeta, bytes.NewReader(encrypted.Bytes()), &decrypted); err != nil {
+		t.Fatalf("failed to decrypt in FIPS mode. err: %v", err)
+	}
+	if !bytes.Equal(src, decrypted.Bytes()) {
+		t.Errorf("failed to decrypt the data in FIPS mode. got: %q", decrypted.Bytes())
+	}
+	tampered := append([]byte(nil), encrypted.Bytes()...)
+	tampered[0] ^= 1
+	if err = decryptStream(material, gcmMeta, bytes.NewReader(tampered), &bytes.Buffer{}); err != errGCMAuthentication {
+		t.Errorf("should have failed to authenticate the tampered data in FIPS mode. err: %v", err)
+	}
+}
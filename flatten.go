@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Flatten is a LATERAL FLATTEN of a VARIANT, OBJECT or ARRAY column. For example, the following query
+// decodes the items of the orders:
+//
+//	f := &sf.Flatten{Table: "orders", Column: "doc", Path: "items"}
+//	var items []Item
+//	err := f.Into(ctx, db, &items)
+type Flatten struct {
+	Table     string        // table or subquery in parentheses
+	Column    string        // VARIANT, OBJECT or ARRAY column or expression of Table
+	Path      string        // path to the element to flatten, e.g., items or a.b[0]. The column itself if empty.
+	Recursive bool          // flatten the sub-elements recursively
+	Where     string        // optional condition on the rows of Table
+	Args      []interface{} // binding parameters of Where
+	UseNumber bool          // decode the numbers in interface{} values as json.Number instead of float64
+}
+
+// FlattenRow is a row produced by FLATTEN.
+type FlattenRow struct {
+	Seq   int64           // sequence number of the input row
+	Key   sql.NullString  // key of the element if the input is an OBJECT
+	Path  string          // path to the element
+	Index sql.NullInt64   // index of the element if the input is an ARRAY
+	Value json.RawMessage // element in JSON
+
+	useNumber bool
+}
+
+// Decode decodes the value of the element into v with encoding/json.
+func (r *FlattenRow) Decode(v interface{}) error {
+	if r.useNumber {
+		return UnmarshalVariant(r.Value, v)
+	}
+	return json.Unmarshal(r.Value, v)
+}
+
+// query returns the FLATTEN query.
+func (f *Flatten) query() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "SELECT F.SEQ, F.KEY, F.PATH, F.INDEX, TO_JSON(F.VALUE) FROM %v T, LATERAL FLATTEN(INPUT => T.%v",
+		f.Table, f.Column)
+	if f.Path != "" {
+		fmt.Fprintf(&buf, ", PATH => %v", quoteSQLString(f.Path))
+	}
+	if f.Recursive {
+		buf.WriteString(", RECURSIVE => TRUE")
+	}
+	buf.WriteString(") F")
+	if f.Where != "" {
+		fmt.Fprintf(&buf, " WHERE %v", f.Where)
+	}
+	buf.WriteString(" ORDER BY F.SEQ, F.INDEX")
+	return buf.String()
+}
+
+// Rows runs the FLATTEN query and returns the elements.
+func (f *Flatten) Rows(ctx context.Context, db Queryer) ([]FlattenRow, error) {
+	rows, err := db.QueryContext(ctx, f.query(), f.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []FlattenRow
+	for rows.Next() {
+		r := FlattenRow{useNumber: f.UseNumber}
+		var value sql.NullString
+		if err = rows.Scan(&r.Seq, &r.Key, &r.Path, &r.Index, &value); err != nil {
+			return nil, err
+		}
+		if value.Valid {
+			r.Value = json.RawMessage(value.String)
+		} else {
+			r.Value = json.RawMessage("null")
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+// Into runs the FLATTEN query and decodes the values of the elements into dest, a pointer to a slice, with
+// encoding/json.
+func (f *Flatten) Into(ctx context.Context, db Queryer, dest interface{}) error {
+	rows, err := f.Rows(ctx, db)
+	if err != nil {
+		return err
+	}
+	values := make([]json.RawMessage, len(rows))
+	for i := range rows {
+		values[i] = rows[i].Value
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if f.UseNumber {
+		return UnmarshalVariant(b, dest)
+	}
+	return json.Unmarshal(b, dest)
+}
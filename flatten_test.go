@@ -0,0 +1,85 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func TestFlattenQuery(t *testing.T) {
+	testcases := []struct {
+		f   *Flatten
+		out string
+	}{
+		{
+			f:   &Flatten{Table: "orders", Column: "doc"},
+			out: "SELECT F.SEQ, F.KEY, F.PATH, F.INDEX, TO_JSON(F.VALUE) FROM orders T, LATERAL FLATTEN(INPUT => T.doc) F ORDER BY F.SEQ, F.INDEX",
+		},
+		{
+			f:   &Flatten{Table: "orders", Column: "doc", Path: "a.b[0]", Recursive: true, Where: "T.id = ?"},
+			out: "SELECT F.SEQ, F.KEY, F.PATH, F.INDEX, TO_JSON(F.VALUE) FROM orders T, LATERAL FLATTEN(INPUT => T.doc, PATH => 'a.b[0]', RECURSIVE => TRUE) F WHERE T.id = ? ORDER BY F.SEQ, F.INDEX",
+		},
+	}
+	for _, test := range testcases {
+		if q := test.f.query(); q != test.out {
+			t.Errorf("failed to build the query. expected: %v, got: %v", test.out, q)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	flattenRows := func() *sfmock.Rows {
+		return sfmock.NewRows(
+			sfmock.Column{Name: "SEQ", Type: "FIXED"},
+			sfmock.Column{Name: "KEY", Type: "TEXT", Nullable: true},
+			sfmock.Column{Name: "PATH", Type: "TEXT"},
+			sfmock.Column{Name: "INDEX", Type: "FIXED", Nullable: true},
+			sfmock.Column{Name: "TO_JSON(F.VALUE)", Type: "TEXT", Nullable: true}).
+			AddRow(int64(1), nil, "items[0]", int64(0), `{"sku":"a","qty":1}`).
+			AddRow(int64(1), nil, "items[1]", int64(1), `{"sku":"b","qty":2}`)
+	}
+	f := &Flatten{Table: "orders", Column: "doc", Path: "items", Where: "T.id = ?", Args: []interface{}{int64(7)}}
+	q := regexp.QuoteMeta(f.query())
+	mock.ExpectQuery(q).WithArgs(int64(7)).WillReturnRows(flattenRows())
+	mock.ExpectQuery(q).WithArgs(int64(7)).WillReturnRows(flattenRows())
+
+	rows, err := f.Rows(context.Background(), db)
+	if err != nil {
+		t.Fatalf("failed to flatten. err: %v", err)
+	}
+	if len(rows) != 2 || rows[1].Path != "items[1]" || rows[1].Index.Int64 != 1 || rows[1].Key.Valid {
+		t.Fatalf("failed to get the rows. rows: %v", rows)
+	}
+	var item struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	}
+	if err = rows[0].Decode(&item); err != nil || item.SKU != "a" || item.Qty != 1 {
+		t.Errorf("failed to decode the value. item: %v, err: %v", item, err)
+	}
+
+	var items []struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	}
+	if err = f.Into(context.Background(), db, &items); err != nil {
+		t.Fatalf("failed to flatten into items. err: %v", err)
+	}
+	if len(items) != 2 || items[1].SKU != "b" || items[1].Qty != 2 {
+		t.Errorf("failed to decode the items. items: %v", items)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// GeoOutputFormat is the format of the GEOGRAPHY and GEOMETRY values fetched, which is set to the session
+// parameters GEOGRAPHY_OUTPUT_FORMAT and GEOMETRY_OUTPUT_FORMAT at login.
+type GeoOutputFormat string
+
+const (
+	// GeoOutputGeoJSON returns the values as GeoJSON strings. This is the default of Snowflake.
+	GeoOutputGeoJSON GeoOutputFormat = "GeoJSON"
+	// GeoOutputWKT returns the values as Well-Known Text strings.
+	GeoOutputWKT GeoOutputFormat = "WKT"
+	// GeoOutputEWKT returns the values as Extended Well-Known Text strings with the SRID.
+	GeoOutputEWKT GeoOutputFormat = "EWKT"
+	// GeoOutputWKB returns the values as Well-Known Binary []byte.
+	GeoOutputWKB GeoOutputFormat = "WKB"
+	// GeoOutputEWKB returns the values as Extended Well-Known Binary []byte with the SRID.
+	GeoOutputEWKB GeoOutputFormat = "EWKB"
+)
+
+const (
+	sessionGeographyOutputFormat = "geography_output_format"
+	sessionGeometryOutputFormat  = "geometry_output_format"
+)
+
+func parseGeoOutputFormat(s string) (GeoOutputFormat, error) {
+	for _, f := range []GeoOutputFormat{GeoOutputGeoJSON, GeoOutputWKT, GeoOutputEWKT, GeoOutputWKB, GeoOutputEWKB} {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid geoOutputFormat: %v. must be GeoJSON, WKT, EWKT, WKB or EWKB", s)
+}
+
+// isBinary returns true if the values are fetched as []byte.
+func (f GeoOutputFormat) isBinary() bool {
+	return f == GeoOutputWKB || f == GeoOutputEWKB
+}
+
+// geoOutputFormat returns the format of the session parameter, GEOGRAPHY_OUTPUT_FORMAT or
+// GEOMETRY_OUTPUT_FORMAT, or GeoJSON if it's unknown.
+func (sc *snowflakeConn) geoOutputFormat(param string) GeoOutputFormat {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	v := ""
+	for k, p := range sc.cfg.Params {
+		// the parameter returned by the server is preferred to the one in DSN
+		if p != nil && (k == param || v == "" && strings.EqualFold(k, param)) {
+			v = *p
+		}
+	}
+	if v == "" {
+		v = string(sc.cfg.GeoOutputFormat)
+	}
+	f, err := parseGeoOutputFormat(v)
+	if err != nil {
+		return GeoOutputGeoJSON
+	}
+	return f
+}
+
+// geoColumnFormat returns the output format of the GEOGRAPHY or GEOMETRY column, or an empty string if the
+// column is not spatial.
+func (rows *snowflakeRows) geoColumnFormat(index int) GeoOutputFormat {
+	switch rows.RowType[index].Type {
+	case "geography":
+		return rows.geographyFormat
+	case "geometry":
+		return rows.geometryFormat
+	}
+	return ""
+}
+
+// geoScanType returns the scan type of the values in the format.
+func geoScanType(f GeoOutputFormat) reflect.Type {
+	if f.isBinary() {
+		return reflect.TypeOf([]byte{})
+	}
+	return reflect.TypeOf("")
+}
+
+// geoValue converts the value in the format to []byte for WKB and EWKB, which are returned in hex. The other
+// formats are returned as strings.
+func geoValue(f GeoOutputFormat, v driver.Value) driver.Value {
+	s, ok := v.(string)
+	if !ok || !f.isBinary() {
+		return v
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		// e.g., the format was changed by ALTER SESSION
+		glog.V(2).Infof("spatial value is not in hex. returning it as is. err: %v", err)
+		return s
+	}
+	return b
+}
+
+// spatialText is a GEOGRAPHY or GEOMETRY value bound as text and converted by the function.
+type spatialText struct {
+	text     string
+	function string
+}
+
+// Geography returns the value binding s, in WKT, EWKT, GeoJSON or hex WKB, as GEOGRAPHY. The placeholder is
+// replaced with TO_GEOGRAPHY(?):
+//
+//	_, err = db.Exec("INSERT INTO places (name, location) VALUES (?, ?)", "office", sf.Geography("POINT(-122.35 37.55)"))
+func Geography(s string) interface{} {
+	return spatialText{text: s, function: "TO_GEOGRAPHY"}
+}
+
+// Geometry returns the value binding s, in WKT, EWKT, GeoJSON or hex WKB, as GEOMETRY. The placeholder is
+// replaced with TO_GEOMETRY(?).
+func Geometry(s string) interface{} {
+	return spatialText{text: s, function: "TO_GEOMETRY"}
+}
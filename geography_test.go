@@ -0,0 +1,165 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGeoOutputFormat(t *testing.T) {
+	testcases := []struct {
+		in  string
+		out GeoOutputFormat
+		ok  bool
+	}{
+		{in: "GeoJSON", out: GeoOutputGeoJSON, ok: true},
+		{in: "geojson", out: GeoOutputGeoJSON, ok: true},
+		{in: "wkt", out: GeoOutputWKT, ok: true},
+		{in: "EWKB", out: GeoOutputEWKB, ok: true},
+		{in: "KML", ok: false},
+		{in: "", ok: false},
+	}
+	for _, test := range testcases {
+		f, err := parseGeoOutputFormat(test.in)
+		if (err == nil) != test.ok || f != test.out {
+			t.Errorf("failed to parse the format. in: %v, expected: %v, got: %v, err: %v", test.in, test.out, f, err)
+		}
+	}
+}
+
+func TestSessionGeoOutputFormat(t *testing.T) {
+	sc := getDefaultSnowflakeConn()
+	if f := sc.geoOutputFormat(sessionGeographyOutputFormat); f != GeoOutputGeoJSON {
+		t.Errorf("should have been GeoJSON by default. got: %v", f)
+	}
+	sc.cfg.GeoOutputFormat = GeoOutputWKT
+	if f := sc.geoOutputFormat(sessionGeographyOutputFormat); f != GeoOutputWKT {
+		t.Errorf("failed to get the format of the config. got: %v", f)
+	}
+	dsnFormat, serverFormat := "EWKT", "WKB"
+	sc.cfg.Params["GEOMETRY_OUTPUT_FORMAT"] = &dsnFormat
+	if f := sc.geoOutputFormat(sessionGeometryOutputFormat); f != GeoOutputEWKT {
+		t.Errorf("failed to get the format of DSN. got: %v", f)
+	}
+	sc.cfg.Params[sessionGeometryOutputFormat] = &serverFormat
+	if f := sc.geoOutputFormat(sessionGeometryOutputFormat); f != GeoOutputWKB {
+		t.Errorf("failed to get the format of the session. got: %v", f)
+	}
+	if f := sc.geoOutputFormat(sessionGeographyOutputFormat); f != GeoOutputWKT {
+		t.Errorf("should have been the format of the config. got: %v", f)
+	}
+}
+
+func TestUnitAuthenticateGeoOutputFormat(t *testing.T) {
+	var params map[string]string
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostAuth = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, jsonBody []byte, _ time.Duration) (*authResponse, error) {
+		var ar authRequest
+		if err := json.Unmarshal(jsonBody, &ar); err != nil {
+			return nil, err
+		}
+		params = ar.Data.SessionParameters
+		return &authResponse{Success: true, Data: authResponseMain{Token: "t", MasterToken: "m"}}, nil
+	}
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if _, ok := params["GEOGRAPHY_OUTPUT_FORMAT"]; ok {
+		t.Errorf("should not have set the format. params: %v", params)
+	}
+	sc.cfg.GeoOutputFormat = GeoOutputWKB
+	geometry := "WKT"
+	sc.cfg.Params["geometry_output_format"] = &geometry
+	if _, err := authenticate(context.Background(), sc, nil, nil); err != nil {
+		t.Fatalf("failed to authenticate. err: %v", err)
+	}
+	if params["GEOGRAPHY_OUTPUT_FORMAT"] != "WKB" || params["GEOMETRY_OUTPUT_FORMAT"] != "WKT" {
+		t.Errorf("failed to set the formats. params: %v", params)
+	}
+}
+
+func TestRowsGeo(t *testing.T) {
+	geoJSON := `{"coordinates":[-122.35,37.55],"type":"Point"}`
+	wkb := "0101000000666666666696"
+	rows := &snowflakeRows{
+		RowType:         []execResponseRowType{{Type: "geography"}, {Type: "geometry"}, {Type: "text"}},
+		geographyFormat: GeoOutputGeoJSON,
+		geometryFormat:  GeoOutputWKB,
+		ChunkDownloader: &snowflakeChunkDownloader{
+			CurrentChunk:  jsonChunk([][]*string{{&geoJSON, &wkb, &wkb}, {nil, nil, nil}}),
+			Total:         2,
+			TotalRowIndex: -1,
+		},
+	}
+	rows.ChunkDownloader.start()
+	if rows.ColumnTypeDatabaseTypeName(0) != "GEOGRAPHY" || rows.ColumnTypeDatabaseTypeName(1) != "GEOMETRY" {
+		t.Errorf("failed to get the database types. got: %v, %v", rows.ColumnTypeDatabaseTypeName(0), rows.ColumnTypeDatabaseTypeName(1))
+	}
+	if rows.ColumnTypeScanType(0) != reflect.TypeOf("") || rows.ColumnTypeScanType(1) != reflect.TypeOf([]byte{}) {
+		t.Errorf("failed to get the scan types. got: %v, %v", rows.ColumnTypeScanType(0), rows.ColumnTypeScanType(1))
+	}
+	dest := make([]driver.Value, 3)
+	if err := rows.Next(dest); err != nil {
+		t.Fatalf("failed to get the row. err: %v", err)
+	}
+	if dest[0] != geoJSON {
+		t.Errorf("failed to get GeoJSON. got: %v", dest[0])
+	}
+	if b, ok := dest[1].([]byte); !ok || !bytes.Equal(b, []byte{1, 1, 0, 0, 0, 0x66, 0x66, 0x66, 0x66, 0x66, 0x96}) {
+		t.Errorf("failed to get WKB. got: %v", dest[1])
+	}
+	if dest[2] != wkb {
+		t.Errorf("should not have converted the text. got: %v", dest[2])
+	}
+	if err := rows.Next(dest); err != nil || dest[0] != nil || dest[1] != nil {
+		t.Errorf("failed to get NULL. got: %v, err: %v", dest, err)
+	}
+	if v := geoValue(GeoOutputWKB, "POINT(1 2)"); v != "POINT(1 2)" {
+		t.Errorf("should have returned the value not in hex as is. got: %v", v)
+	}
+}
+
+func TestGeoBindExec(t *testing.T) {
+	var sent execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		if err := json.Unmarshal(body, &sent); err != nil {
+			return nil, err
+		}
+		return &execResponse{Success: true}, nil
+	}
+	point := Geography("POINT(-122.35 37.55)")
+	nv := driver.NamedValue{Ordinal: 1, Value: point}
+	if err := sc.CheckNamedValue(&nv); err != nil {
+		t.Fatalf("failed to accept the geography. err: %v", err)
+	}
+	args := []driver.NamedValue{nv, {Ordinal: 2, Value: Geometry("POINT(1 2)")}, {Ordinal: 3, Value: json.RawMessage(`{}`)}}
+	if _, err := sc.ExecContext(context.Background(), "INSERT INTO t VALUES (?, ?, ?)", args); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if sent.SQLText != "INSERT INTO t SELECT TO_GEOGRAPHY(column1), TO_GEOMETRY(column2), PARSE_JSON(column3) FROM VALUES (?, ?, ?)" {
+		t.Errorf("failed to rewrite the query. got: %v", sent.SQLText)
+	}
+	if b := sent.Bindings["1"]; b.Type != "TEXT" || b.Value != "POINT(-122.35 37.55)" {
+		t.Errorf("failed to bind the geography. got: %v", b)
+	}
+	args = []driver.NamedValue{{Ordinal: 1, Value: point}}
+	if _, err := sc.ExecContext(context.Background(), "SELECT ST_DISTANCE(location, ?) FROM t", args); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if sent.SQLText != "SELECT ST_DISTANCE(location, TO_GEOGRAPHY(?)) FROM t" {
+		t.Errorf("failed to rewrite the query. got: %v", sent.SQLText)
+	}
+	args = []driver.NamedValue{{Ordinal: 1, Value: point}, {Ordinal: 2, Value: json.RawMessage(`{}`)}}
+	_, err := sc.ExecContext(context.Background(), "INSERT INTO t VALUES (?), (?)", args)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidVariant {
+		t.Errorf("should have failed to bind a column by different functions. err: %v", err)
+	}
+}
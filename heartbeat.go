@@ -3,12 +3,15 @@
 package gosnowflake
 
 import (
+	"container/heap"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,64 +22,222 @@ const (
 	heartBeatInterval = 60 * time.Minute
 )
 
+var (
+	// heartbeatJitter is the fraction of heartBeatInterval over which the heartbeats of the connections opened
+	// at the same time are spread.
+	heartbeatJitter = 0.1
+	// maxConcurrentHeartbeats caps the heartbeats, including the token renewals by them, in flight in the process.
+	maxConcurrentHeartbeats = 4
+)
+
+// heartbeat keeps the session of a connection alive. The heartbeats of all connections are run by the shared
+// heartbeatScheduler, so idle pooled connections don't each run a timer.
 type heartbeat struct {
-	restful      *snowflakeRestful
-	ShutdownChan chan int
+	restful  *snowflakeRestful
+	interval time.Duration // heartBeatInterval if 0
+	next     time.Time     // time of the next heartbeat
+	index    int           // index in the scheduler queue. -1 if not scheduled
+
+	ctx    context.Context // canceled by stop
+	cancel context.CancelFunc
+}
+
+func (hc *heartbeat) start() {
+	hc.ctx, hc.cancel = context.WithCancel(context.Background())
+	defaultHeartbeatScheduler.add(hc)
+	glog.V(2).Infof("heartbeat started. interval: %v", hc.getInterval())
+}
+
+// stop unschedules the heartbeat and cancels the heartbeat in flight, if any.
+func (hc *heartbeat) stop() {
+	defaultHeartbeatScheduler.remove(hc)
+	if hc.cancel != nil {
+		hc.cancel()
+	}
+	glog.V(2).Info("heartbeat stopped")
+}
+
+func (hc *heartbeat) getInterval() time.Duration {
+	if hc.interval > 0 {
+		return hc.interval
+	}
+	return heartBeatInterval
+}
+
+// beat sends a heartbeat, which renews the session token if the session is expired.
+func (hc *heartbeat) beat() {
+	ctx := hc.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := hc.heartbeatMain(ctx); err != nil {
+		glog.V(2).Infof("failed to heartbeat. err: %v", err)
+	}
+}
+
+// heartbeatQueue is a min-heap of the heartbeats ordered by the next time.
+type heartbeatQueue []*heartbeat
+
+func (q heartbeatQueue) Len() int           { return len(q) }
+func (q heartbeatQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q heartbeatQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *heartbeatQueue) Push(x interface{}) {
+	hc := x.(*heartbeat)
+	hc.index = len(*q)
+	*q = append(*q, hc)
+}
+
+func (q *heartbeatQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	hc := old[n-1]
+	old[n-1] = nil
+	hc.index = -1
+	*q = old[:n-1]
+	return hc
+}
+
+// heartbeatScheduler runs the heartbeats of the connections in a single goroutine, which runs only while any
+// connection has the heartbeat enabled.
+type heartbeatScheduler struct {
+	mu      sync.Mutex
+	queue   heartbeatQueue
+	wakeup  chan struct{} // notifies the goroutine that the first heartbeat changed
+	running bool
+	sem     chan struct{} // limits the heartbeats in flight
+}
+
+var defaultHeartbeatScheduler = &heartbeatScheduler{}
+
+// add schedules the heartbeat of a connection after its interval less a random jitter.
+func (s *heartbeatScheduler) add(hc *heartbeat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wakeup == nil {
+		s.wakeup = make(chan struct{}, 1)
+		s.sem = make(chan struct{}, maxConcurrentHeartbeats)
+	}
+	interval := hc.getInterval()
+	jitter := time.Duration(heartbeatJitter * float64(interval) * rand.Float64())
+	hc.next = getClock().Now().Add(interval - jitter)
+	heap.Push(&s.queue, hc)
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	s.notify()
+}
+
+// remove unschedules the heartbeat of a connection.
+func (s *heartbeatScheduler) remove(hc *heartbeat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hc.index < 0 || hc.index >= len(s.queue) || s.queue[hc.index] != hc {
+		return
+	}
+	heap.Remove(&s.queue, hc.index)
+	s.notify()
 }
 
-func (hc *heartbeat) run() {
-	hbTimer := time.NewTimer(heartBeatInterval)
+func (s *heartbeatScheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *heartbeatScheduler) run() {
 	for {
-		go func() {
-			<-hbTimer.C
-			err := hc.heartbeatMain()
-			if err != nil {
-				glog.V(2).Info("failed to heartbeat")
-				return
-			}
-			hbTimer = time.NewTimer(heartBeatInterval)
-		}()
-		select {
-		case <-hc.ShutdownChan:
-			// stop
-			hbTimer.Stop()
-			glog.V(2).Info("stopping")
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.running = false
+			s.mu.Unlock()
 			return
-		default:
-			// no more download
-			glog.V(2).Info("no shutdown signal")
 		}
-		time.Sleep(1 * time.Second)
+		wait := s.queue[0].next.Sub(getClock().Now())
+		s.mu.Unlock()
+		if wait > 0 {
+			select {
+			case <-s.wakeup:
+				continue
+			case <-getClock().After(wait):
+			}
+		}
+		s.runDue()
 	}
 }
 
-func (hc *heartbeat) start() {
-	hc.ShutdownChan = make(chan int)
-	go hc.run()
-	glog.V(2).Info("heartbeat started")
+// runDue runs the heartbeats that are due and schedules their next heartbeats.
+func (s *heartbeatScheduler) runDue() {
+	s.mu.Lock()
+	now := getClock().Now()
+	var due []*heartbeat
+	for len(s.queue) > 0 && !s.queue[0].next.After(now) {
+		hc := s.queue[0]
+		due = append(due, hc)
+		hc.next = hc.next.Add(hc.getInterval())
+		if hc.next.Before(now) {
+			hc.next = now.Add(hc.getInterval())
+		}
+		heap.Fix(&s.queue, 0)
+	}
+	sem := s.sem
+	s.mu.Unlock()
+	for _, hc := range due {
+		sem <- struct{}{}
+		go func(hc *heartbeat) {
+			defer func() { <-sem }()
+			hc.beat()
+		}(hc)
+	}
 }
 
-func (hc *heartbeat) stop() {
-	hc.ShutdownChan <- 1
-	close(hc.ShutdownChan)
-	glog.V(2).Info("heartbeat stopped")
+// heartbeatMain sends a heartbeat. If the session token is expired, it's renewed by the master token and the
+// heartbeat is sent again, so that the session of an idle connection survives longer than the session token.
+func (hc *heartbeat) heartbeatMain(ctx context.Context) error {
+	if hc.restful.isTokenExpired() {
+		glog.V(2).Info("session token expired. renewing before heartbeating")
+		token, _, _ := hc.restful.getTokens()
+		if err := hc.restful.renewSession(ctx, token); err != nil {
+			return err
+		}
+	}
+	token, _, _ := hc.restful.getTokens()
+	expired, err := hc.sendHeartbeat(ctx, token)
+	if err != nil || !expired {
+		return err
+	}
+	// the queries of the session may renew the token at the same time
+	if err = hc.restful.renewSession(ctx, token); err != nil {
+		return err
+	}
+	glog.V(2).Info("renewed the session token. heartbeating again")
+	token, _, _ = hc.restful.getTokens()
+	_, err = hc.sendHeartbeat(ctx, token)
+	return err
 }
 
-func (hc *heartbeat) heartbeatMain() error {
+// sendHeartbeat sends a heartbeat and returns true if the session token is expired. The other failures, e.g.,
+// the session dropped by the server, are returned as the error.
+func (hc *heartbeat) sendHeartbeat(ctx context.Context, token string) (bool, error) {
 	glog.V(2).Info("Heartbeating!")
 	params := &url.Values{}
 	params.Add("requestId", uuid.New().String())
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", hc.restful.Protocol, hc.restful.Host, hc.restful.Port, "/session/heartbeat?"+params.Encode())
+	fullURL := hc.restful.getFullURL(endpointHeartbeat, params)
 	headers := make(map[string]string)
 	headers["Content-Type"] = headerContentTypeApplicationJSON
 	headers["accept"] = headerAcceptTypeApplicationSnowflake
 	headers["User-Agent"] = userAgent
-	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, hc.restful.Token)
+	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
 
-	resp, err := hc.restful.FuncPost(context.TODO(), hc.restful, fullURL, headers, nil, hc.restful.RequestTimeout, false)
+	resp, err := hc.restful.FuncPost(ctx, hc.restful, fullURL, headers, nil, hc.restful.RequestTimeout, false)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusOK {
@@ -86,25 +247,22 @@ func (hc *heartbeat) heartbeatMain() error {
 		if err != nil {
 			glog.V(1).Infof("failed to decode JSON. err: %v", err)
 			glog.Flush()
-			return err
+			return false, err
 		}
-		if respd.Code == sessionExpiredCode {
-			err = hc.restful.FuncRenewSession(context.TODO(), hc.restful)
-			if err != nil {
-				return err
-			}
+		if !respd.Success && respd.Code != sessionExpiredCode {
+			return false, execResponseError(&respd)
 		}
-		return nil
+		return respd.Code == sessionExpiredCode, nil
 	}
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		glog.V(1).Infof("failed to extract HTTP response body. err: %v", err)
-		return err
+		return false, err
 	}
 	glog.V(1).Infof("HTTP: %v, URL: %v, Body: %v", resp.StatusCode, fullURL, b)
 	glog.V(1).Infof("Header: %v", resp.Header)
 	glog.Flush()
-	return &SnowflakeError{
+	return false, &SnowflakeError{
 		Number:   ErrFailedToHeartbeat,
 		SQLState: SQLStateConnectionFailure,
 		Message:  "Failed to heartbeat.",
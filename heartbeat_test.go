@@ -0,0 +1,244 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatScheduler(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+	start := c.Now()
+
+	var wg sync.WaitGroup
+	var inFlight, maxInFlight int32
+	sr := &snowflakeRestful{
+		FuncPost: func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error) {
+			defer wg.Done()
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"success":true}`)),
+			}, nil
+		},
+	}
+	// the goroutine is not started so that the test runs the due heartbeats.
+	s := &heartbeatScheduler{running: true}
+	hcs := make([]*heartbeat, 20)
+	for i := range hcs {
+		hcs[i] = &heartbeat{restful: sr}
+		s.add(hcs[i])
+	}
+	distinct := make(map[time.Time]bool)
+	for _, hc := range hcs {
+		if hc.next.After(start.Add(heartBeatInterval)) ||
+			hc.next.Before(start.Add(heartBeatInterval-time.Duration(heartbeatJitter*float64(heartBeatInterval)))) {
+			t.Errorf("failed to schedule the heartbeat within the jitter. next: %v", hc.next.Sub(start))
+		}
+		distinct[hc.next] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("failed to spread the heartbeats")
+	}
+	s.remove(hcs[0])
+	s.remove(hcs[0])
+	if len(s.queue) != len(hcs)-1 {
+		t.Fatalf("failed to remove the heartbeat. queue: %v", len(s.queue))
+	}
+
+	c.Sleep(heartBeatInterval)
+	wg.Add(len(hcs) - 1)
+	s.runDue()
+	wg.Wait()
+	if maxInFlight > int32(maxConcurrentHeartbeats) {
+		t.Errorf("failed to cap the heartbeats in flight. expected: %v, got: %v", maxConcurrentHeartbeats, maxInFlight)
+	}
+	for _, hc := range s.queue {
+		if !hc.next.After(c.Now()) {
+			t.Errorf("failed to schedule the next heartbeat. next: %v", hc.next.Sub(c.Now()))
+		}
+	}
+}
+
+func TestHeartbeatInterval(t *testing.T) {
+	testcases := []struct {
+		healthCheckInterval time.Duration
+		keepAlive           string
+		frequency           string
+		masterValidity      time.Duration
+		disabled            bool
+		expected            time.Duration
+	}{
+		{expected: 0},
+		{keepAlive: "true", expected: heartBeatInterval},
+		{keepAlive: "true", masterValidity: 2 * time.Hour, expected: 30 * time.Minute},
+		{keepAlive: "true", frequency: "1200", expected: 20 * time.Minute},
+		{keepAlive: "true", frequency: "60", expected: minKeepAliveHeartbeatFrequency},
+		{keepAlive: "true", frequency: "7200", masterValidity: 4 * time.Hour, expected: time.Hour},
+		{keepAlive: "true", frequency: "abc", expected: heartBeatInterval},
+		{frequency: "1200", expected: 0},
+		{healthCheckInterval: 45 * time.Second, expected: 45 * time.Second},
+		{healthCheckInterval: 45 * time.Second, keepAlive: "true", expected: 45 * time.Second},
+		{healthCheckInterval: 45 * time.Second, keepAlive: "true", frequency: "1200", expected: 20 * time.Minute},
+		{healthCheckInterval: 45 * time.Second, keepAlive: "false", expected: 0},
+		{healthCheckInterval: 45 * time.Second, keepAlive: "true", disabled: true, expected: 0},
+	}
+	for i, test := range testcases {
+		sc := getDefaultSnowflakeConn()
+		sc.healthCheckInterval = test.healthCheckInterval
+		sc.cfg.DisableHeartbeat = test.disabled
+		sc.masterValidity = test.masterValidity
+		if test.keepAlive != "" {
+			keepAlive := test.keepAlive
+			sc.cfg.Params["CLIENT_SESSION_KEEP_ALIVE"] = &keepAlive
+		}
+		if test.frequency != "" {
+			frequency := test.frequency
+			sc.cfg.Params[sessionClientSessionKeepAliveHeartbeatFrequency] = &frequency
+		}
+		if got := sc.heartbeatInterval(); got != test.expected {
+			t.Errorf("%d: failed to get the heartbeat interval. expected: %v, got: %v", i, test.expected, got)
+		}
+	}
+
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+	s := &heartbeatScheduler{running: true}
+	hc := &heartbeat{interval: 45 * time.Second}
+	s.add(hc)
+	if d := hc.next.Sub(c.Now()); d > hc.interval || d < hc.interval-time.Duration(heartbeatJitter*float64(hc.interval)) {
+		t.Errorf("failed to schedule the heartbeat at the interval. next: %v", d)
+	}
+}
+
+func TestHeartbeatStopCancels(t *testing.T) {
+	started := make(chan struct{})
+	sr := &snowflakeRestful{
+		FuncPost: func(ctx context.Context, _ *snowflakeRestful, _ string, _ map[string]string, _ []byte, _ time.Duration, _ bool) (*http.Response, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	hc := &heartbeat{restful: sr}
+	hc.start()
+	done := make(chan error, 1)
+	go func() { done <- hc.heartbeatMain(hc.ctx) }()
+	<-started
+	hc.stop()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("failed to cancel the heartbeat in flight. err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed to cancel the heartbeat in flight")
+	}
+}
+
+func TestHeartbeatRestartedByParameters(t *testing.T) {
+	sc := getDefaultSnowflakeConn()
+	sc.startHeartBeat()
+	if sc.rest.HeartBeat != nil {
+		t.Fatal("should not have started the heartbeat")
+	}
+	keepAlive, frequency := "true", "1800"
+	sc.cfg.Params[sessionClientSessionKeepAlive] = &keepAlive
+	sc.startHeartBeat()
+	defer sc.stopHeartBeat()
+	first := sc.rest.HeartBeat
+	if first == nil || first.interval != heartBeatInterval {
+		t.Fatalf("failed to start the heartbeat. got: %v", first)
+	}
+	sc.startHeartBeat()
+	if sc.rest.HeartBeat != first {
+		t.Errorf("should have kept the heartbeat")
+	}
+	sc.cfg.Params[sessionClientSessionKeepAliveHeartbeatFrequency] = &frequency
+	sc.startHeartBeat()
+	if hb := sc.rest.HeartBeat; hb == first || hb == nil || hb.interval != 30*time.Minute {
+		t.Errorf("failed to restart the heartbeat at the frequency. got: %v", hb)
+	}
+	if first.index >= 0 {
+		t.Errorf("failed to stop the previous heartbeat")
+	}
+	keepAlive = "false"
+	sc.startHeartBeat()
+	if sc.rest.HeartBeat != nil {
+		t.Errorf("failed to stop the heartbeat")
+	}
+}
+
+func TestHeartbeatRenewsSession(t *testing.T) {
+	var beats, renewals int32
+	sr := &snowflakeRestful{
+		FuncPost: func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error) {
+			body := `{"success":true}`
+			if atomic.AddInt32(&beats, 1) == 1 {
+				body = `{"success":false,"code":"` + sessionExpiredCode + `"}`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+		},
+		FuncRenewSession: func(context.Context, *snowflakeRestful) error {
+			atomic.AddInt32(&renewals, 1)
+			return nil
+		},
+	}
+	hc := &heartbeat{restful: sr}
+	if err := hc.heartbeatMain(context.Background()); err != nil {
+		t.Fatalf("failed to heartbeat. err: %v", err)
+	}
+	if beats != 2 || renewals != 1 {
+		t.Errorf("failed to renew the session and heartbeat again. heartbeats: %v, renewals: %v", beats, renewals)
+	}
+}
+
+func TestHeartbeatSkipsRenewedSession(t *testing.T) {
+	var renewals int32
+	var tokens []string
+	sr := &snowflakeRestful{Token: "old"}
+	sr.FuncPost = func(_ context.Context, _ *snowflakeRestful, _ string, headers map[string]string, _ []byte, _ time.Duration, _ bool) (*http.Response, error) {
+		tokens = append(tokens, headers[headerAuthorizationKey])
+		body := `{"success":true}`
+		if len(tokens) == 1 {
+			// a query of the session renews the token while the heartbeat is sent
+			sr.setToken("new", "master", 0)
+			body = `{"success":false,"code":"` + sessionExpiredCode + `"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+	}
+	sr.FuncRenewSession = func(context.Context, *snowflakeRestful) error {
+		atomic.AddInt32(&renewals, 1)
+		return nil
+	}
+	hc := &heartbeat{restful: sr}
+	if err := hc.heartbeatMain(context.Background()); err != nil {
+		t.Fatalf("failed to heartbeat. err: %v", err)
+	}
+	if renewals != 0 {
+		t.Errorf("should not have renewed the session renewed by the query. renewals: %v", renewals)
+	}
+	expected := fmt.Sprint([]string{fmt.Sprintf(headerSnowflakeToken, "old"), fmt.Sprintf(headerSnowflakeToken, "new")})
+	if got := fmt.Sprint(tokens); got != expected {
+		t.Errorf("failed to heartbeat with the renewed token. expected: %v, got: %v", expected, got)
+	}
+}
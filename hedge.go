@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// hedgePercentile is the percentile of the chunk download latencies after which a hedged request is sent.
+	hedgePercentile = 0.95
+	// hedgeMinSamples is the number of latencies required to estimate the percentile.
+	hedgeMinSamples = 20
+	// hedgeDefaultDelay is the delay of the hedged request until enough latencies are observed.
+	hedgeDefaultDelay = 2 * time.Second
+	// hedgeMinDelay is the minimum delay of the hedged request, so that fast networks don't double the requests.
+	hedgeMinDelay = 50 * time.Millisecond
+)
+
+// latencyTracker keeps the recent latencies to estimate a percentile.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	size    int
+}
+
+func newLatencyTracker(size int) *latencyTracker {
+	return &latencyTracker{samples: make([]time.Duration, 0, size), size: size}
+}
+
+func (l *latencyTracker) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < l.size {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % l.size
+}
+
+// percentile returns the p-th percentile of the latencies, or false if there are too few latencies.
+func (l *latencyTracker) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+	if len(sorted) < hedgeMinSamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(len(sorted)-1))], true
+}
+
+// delay returns the delay of the hedged request.
+func (l *latencyTracker) delay() time.Duration {
+	d, ok := l.percentile(hedgePercentile)
+	if !ok {
+		return hedgeDefaultDelay
+	}
+	if d < hedgeMinDelay {
+		return hedgeMinDelay
+	}
+	return d
+}
+
+// chunkLatencies are the latencies of the chunk downloads, until the response headers arrive.
+var chunkLatencies = newLatencyTracker(200)
+
+type hedgeResult struct {
+	resp  *http.Response
+	err   error
+	index int
+}
+
+// cancelOnCloseBody cancels the context of the request when the response body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// hedgedGet calls get, and calls it again if the first call doesn't return within the latency percentile of
+// the previous calls. The first successful response is returned and the other request is cancelled.
+func hedgedGet(ctx context.Context, latencies *latencyTracker, get func(context.Context) (*http.Response, error)) (*http.Response, error) {
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func() {
+		c, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := get(c)
+			results <- hedgeResult{resp, err, index}
+		}()
+	}
+	cancelOthers := func(winner int) {
+		for i, cancel := range cancels {
+			if i != winner {
+				cancel()
+			}
+		}
+	}
+	start := time.Now()
+	launch()
+	pending := 1
+	hedge := getClock().After(latencies.delay())
+	for {
+		select {
+		case <-hedge:
+			glog.V(2).Info("chunk download is slow. sending a hedged request.")
+			hedge = nil
+			launch()
+			pending++
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				latencies.add(time.Since(start))
+				cancelOthers(r.index)
+				r.resp.Body = &cancelOnCloseBody{ReadCloser: r.resp.Body, cancel: cancels[r.index]}
+				go drainHedgeResults(results, pending)
+				return r.resp, nil
+			}
+			cancels[r.index]()
+			if pending == 0 {
+				return nil, r.err
+			}
+		case <-ctx.Done():
+			cancelOthers(-1)
+			go drainHedgeResults(results, pending)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// drainHedgeResults closes the responses of the cancelled requests.
+func drainHedgeResults(results chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		if r := <-results; r.resp != nil {
+			r.resp.Body.Close()
+		}
+	}
+}
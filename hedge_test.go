@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	l := newLatencyTracker(100)
+	if d := l.delay(); d != hedgeDefaultDelay {
+		t.Errorf("failed to use the default delay. got: %v", d)
+	}
+	for i := 1; i <= 200; i++ {
+		l.add(time.Duration(i) * time.Second)
+	}
+	// only the last 100 latencies, 101s to 200s, are kept
+	if d, ok := l.percentile(0.95); !ok || d != 195*time.Second {
+		t.Errorf("failed to get the percentile. got: %v", d)
+	}
+	l = newLatencyTracker(100)
+	for i := 0; i < hedgeMinSamples; i++ {
+		l.add(time.Millisecond)
+	}
+	if d := l.delay(); d != hedgeMinDelay {
+		t.Errorf("failed to use the minimum delay. got: %v", d)
+	}
+}
+
+func okResponse(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}
+}
+
+func TestHedgedGet(t *testing.T) {
+	orgDelay := hedgeDefaultDelay
+	hedgeDefaultDelay = 10 * time.Millisecond
+	defer func() {
+		hedgeDefaultDelay = orgDelay
+	}()
+
+	// the first request is stuck, so the hedged request wins and the first one is cancelled.
+	var calls int32
+	cancelled := make(chan struct{})
+	resp, err := hedgedGet(context.Background(), newLatencyTracker(10), func(ctx context.Context) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+		return okResponse("second"), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to get. err: %v", err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "second" {
+		t.Errorf("failed to get the hedged response. got: %v", string(b))
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("failed to cancel the slow request")
+	}
+
+	// a fast request is not hedged.
+	calls = 0
+	resp, err = hedgedGet(context.Background(), newLatencyTracker(10), func(ctx context.Context) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return okResponse("first"), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to get. err: %v", err)
+	}
+	resp.Body.Close()
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("should not have hedged. calls: %v", calls)
+	}
+
+	// an error before the hedge is returned as is.
+	_, err = hedgedGet(context.Background(), newLatencyTracker(10), func(ctx context.Context) (*http.Response, error) {
+		return nil, errors.New("failed")
+	})
+	if err == nil || err.Error() != "failed" {
+		t.Errorf("should have failed. err: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The transports with HTTP/2 enabled are created on the first use, and shared by the connections, so that the
+// requests of the connections are multiplexed over the same HTTP/2 connections.
+var (
+	snowflakeTransportHTTP2           *http.Transport
+	snowflakeTransportFailClosedHTTP2 *http.Transport
+	snowflakeInsecureTransportHTTP2   *http.Transport
+	http2TransportOnce                sync.Once
+)
+
+// getTransport returns the builtin transport for the proxy, insecure mode, OCSP fail-open mode and HTTP/2 setting
+// of the config. HTTP/1.1 is used unless HTTP/2 is enabled, because some corporate proxies break HTTP/2. In WebAssembly,
+// the Fetch API is always used.
+func getTransport(cfg *Config) http.RoundTripper {
+	if fetchTransport != nil {
+		return fetchTransport
+	}
+	if cfg.ProxyHost != "" {
+		return getProxyTransport(cfg)
+	}
+	failClosed := cfg.OCSPFailOpen == OCSPFailOpenFalse
+	if cfg.EnableHTTP2 {
+		http2TransportOnce.Do(func() {
+			snowflakeTransportHTTP2 = newTransport(&tls.Config{
+				RootCAs:               certPool,
+				VerifyPeerCertificate: verifyPeerCertificateParallel,
+			})
+			snowflakeTransportFailClosedHTTP2 = newTransport(&tls.Config{
+				RootCAs:               certPool,
+				VerifyPeerCertificate: verifyPeerCertificateParallelFailClosed,
+			})
+			snowflakeInsecureTransportHTTP2 = newTransport(nil)
+			if !configureHTTP2(snowflakeTransportHTTP2) || !configureHTTP2(snowflakeTransportFailClosedHTTP2) ||
+				!configureHTTP2(snowflakeInsecureTransportHTTP2) {
+				glog.V(1).Info("HTTP/2 requires Go 1.13 or later. HTTP/1.1 is used.")
+			}
+		})
+		switch {
+		case cfg.InsecureMode:
+			return snowflakeInsecureTransportHTTP2
+		case failClosed:
+			return snowflakeTransportFailClosedHTTP2
+		}
+		return snowflakeTransportHTTP2
+	}
+	switch {
+	case cfg.InsecureMode:
+		// no revocation check with OCSP. Think twice when you want to enable this option.
+		return snowflakeInsecureTransport
+	case failClosed:
+		return snowflakeTransportFailClosed
+	}
+	return SnowflakeTransport
+}
+
+// newTransport returns a transport with the same settings as SnowflakeTransport.
+func newTransport(tlsConfig *tls.Config) *http.Transport {
+	if isFIPSMode() {
+		tlsConfig = fipsTLSConfig(tlsConfig)
+	}
+	return &http.Transport{
+		TLSClientConfig: tlsConfig,
+		MaxIdleConns:    10,
+		IdleConnTimeout: 30 * time.Minute,
+		Proxy:           http.ProxyFromEnvironment,
+		DialContext:     dialContext,
+	}
+}
@@ -0,0 +1,15 @@
+// +build go1.13
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"net/http"
+)
+
+// configureHTTP2 enables HTTP/2 on the transport with a custom TLS configuration.
+func configureHTTP2(t *http.Transport) bool {
+	t.ForceAttemptHTTP2 = true
+	return true
+}
@@ -0,0 +1,15 @@
+// +build !go1.13
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"net/http"
+)
+
+// configureHTTP2 returns false because the transports with a custom TLS configuration cannot enable HTTP/2
+// without golang.org/x/net/http2 before Go 1.13.
+func configureHTTP2(t *http.Transport) bool {
+	return false
+}
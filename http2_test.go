@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestGetTransport(t *testing.T) {
+	if getTransport(&Config{}) != SnowflakeTransport {
+		t.Errorf("failed to get SnowflakeTransport")
+	}
+	if getTransport(&Config{OCSPFailOpen: OCSPFailOpenTrue}) != SnowflakeTransport {
+		t.Errorf("failed to get SnowflakeTransport in fail-open mode")
+	}
+	if getTransport(&Config{OCSPFailOpen: OCSPFailOpenFalse}) != snowflakeTransportFailClosed {
+		t.Errorf("failed to get the fail-closed transport")
+	}
+	if getTransport(&Config{InsecureMode: true}) != snowflakeInsecureTransport {
+		t.Errorf("failed to get the insecure transport")
+	}
+	st := getTransport(&Config{EnableHTTP2: true})
+	if st == SnowflakeTransport || st != getTransport(&Config{EnableHTTP2: true}) {
+		t.Errorf("failed to share the HTTP/2 transport")
+	}
+	if getTransport(&Config{EnableHTTP2: true, OCSPFailOpen: OCSPFailOpenFalse}) == st {
+		t.Errorf("failed to get the fail-closed HTTP/2 transport")
+	}
+	if getTransport(&Config{EnableHTTP2: true, InsecureMode: true}) == snowflakeInsecureTransport {
+		t.Errorf("failed to get the insecure HTTP/2 transport")
+	}
+}
+
+func TestHTTP2Multiplexing(t *testing.T) {
+	var mu sync.Mutex
+	protos := make(map[int]int)
+	conns := make(map[string]bool)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos[r.ProtoMajor]++
+		conns[r.RemoteAddr] = true
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	ts.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ts.StartTLS()
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	run := func(tr *http.Transport) {
+		client := &http.Client{Transport: tr}
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(ts.URL)
+				if err != nil {
+					t.Errorf("failed to get. err: %v", err)
+					return
+				}
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+
+	tr := newTransport(&tls.Config{RootCAs: pool})
+	if !configureHTTP2(tr) {
+		t.Skip("HTTP/2 is not supported by this Go version")
+	}
+	run(tr)
+	if protos[2] != 50 {
+		t.Errorf("failed to use HTTP/2. protocols: %v", protos)
+	}
+	if len(conns) != 1 {
+		t.Errorf("failed to multiplex the requests. connections: %v", len(conns))
+	}
+
+	protos = make(map[int]int)
+	run(newTransport(&tls.Config{RootCAs: pool}))
+	if protos[1] != 50 {
+		t.Errorf("failed to use HTTP/1.1 by default. protocols: %v", protos)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMaxStatementSize is the maximum size of a statement text accepted by Snowflake.
+	defaultMaxStatementSize = 1 << 20
+	// defaultMaxInsertRows is the maximum number of rows in a VALUES clause accepted by Snowflake.
+	defaultMaxInsertRows = 16384
+)
+
+// SQLExpr is a SQL expression inserted into a statement as is, e.g., SQLExpr("CURRENT_TIMESTAMP()").
+type SQLExpr string
+
+// Execer is the interface satisfied by *sql.DB, *sql.Conn and *sql.Tx to execute statements.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// InsertBuilder builds multi-row INSERT ... VALUES statements with the values formatted as SQL literals.
+// Use it instead of array binding if a row includes SQL expressions. The rows are split into multiple
+// statements so that each statement stays under the statement size and row count limits.
+type InsertBuilder struct {
+	Table            string
+	Columns          []string
+	MaxStatementSize int // maximum statement size in bytes. 1MB if 0.
+	MaxRows          int // maximum number of rows per statement. 16384 if 0.
+
+	rows []string
+}
+
+// NewInsertBuilder returns an InsertBuilder inserting into the columns of the table.
+func NewInsertBuilder(table string, columns ...string) *InsertBuilder {
+	return &InsertBuilder{Table: table, Columns: columns}
+}
+
+// AddRow formats the values as SQL literals and adds the row.
+func (b *InsertBuilder) AddRow(values ...interface{}) error {
+	if len(b.Columns) > 0 && len(values) != len(b.Columns) {
+		return &SnowflakeError{
+			Number:      ErrColumnCountMismatch,
+			Message:     errMsgColumnCountMismatch,
+			MessageArgs: []interface{}{len(b.Columns), len(values)},
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('(')
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		s, err := formatSQLLiteral(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteByte(')')
+	b.rows = append(b.rows, buf.String())
+	return nil
+}
+
+// Len returns the number of rows added.
+func (b *InsertBuilder) Len() int {
+	return len(b.rows)
+}
+
+// Reset removes all rows.
+func (b *InsertBuilder) Reset() {
+	b.rows = nil
+}
+
+// Statements returns the INSERT statements including all rows. It fails if a single row doesn't fit in
+// MaxStatementSize.
+func (b *InsertBuilder) Statements() ([]string, error) {
+	maxSize := b.MaxStatementSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxStatementSize
+	}
+	maxRows := b.MaxRows
+	if maxRows <= 0 {
+		maxRows = defaultMaxInsertRows
+	}
+	prefix := "INSERT INTO " + b.Table
+	if len(b.Columns) > 0 {
+		prefix += " (" + strings.Join(b.Columns, ", ") + ")"
+	}
+	prefix += " VALUES "
+
+	var ret []string
+	var buf bytes.Buffer
+	n := 0
+	for i, r := range b.rows {
+		if len(prefix)+len(r) > maxSize {
+			return nil, &SnowflakeError{
+				Number:      ErrRowTooLarge,
+				Message:     errMsgRowTooLarge,
+				MessageArgs: []interface{}{i, len(prefix) + len(r), maxSize},
+			}
+		}
+		if n > 0 && (n >= maxRows || buf.Len()+len(", ")+len(r) > maxSize) {
+			ret = append(ret, buf.String())
+			buf.Reset()
+			n = 0
+		}
+		if n == 0 {
+			buf.WriteString(prefix)
+		} else {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(r)
+		n++
+	}
+	if n > 0 {
+		ret = append(ret, buf.String())
+	}
+	return ret, nil
+}
+
+// Exec executes the INSERT statements in order and returns the total number of inserted rows. Run it in
+// a transaction to insert all rows atomically.
+func (b *InsertBuilder) Exec(ctx context.Context, db Execer) (int64, error) {
+	stmts, err := b.Statements()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, stmt := range stmts {
+		res, err := db.ExecContext(ctx, stmt)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// formatSQLLiteral formats a value as a SQL literal.
+func formatSQLLiteral(v interface{}) (string, error) {
+	if e, ok := v.(SQLExpr); ok {
+		return string(e), nil
+	}
+	if vr, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = vr.Value(); err != nil {
+			return "", err
+		}
+	}
+	switch t := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if t {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case string:
+		return quoteSQLString(t), nil
+	case []byte:
+		if t == nil {
+			return "NULL", nil
+		}
+		return "X'" + strings.ToUpper(hex.EncodeToString(t)) + "'", nil
+	case time.Time:
+		return "'" + t.Format("2006-01-02 15:04:05.999999999 -07:00") + "'::TIMESTAMP_TZ", nil
+	case int:
+		return strconv.FormatInt(int64(t), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(t), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(t), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(t), 10), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(t), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(t), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(t), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10), nil
+	case uint64:
+		return strconv.FormatUint(t, 10), nil
+	case float32:
+		return formatFloatLiteral(float64(t), 32), nil
+	case float64:
+		return formatFloatLiteral(t, 64), nil
+	}
+	return "", &SnowflakeError{
+		Number:      ErrUnsupportedLiteralType,
+		Message:     errMsgUnsupportedLiteralType,
+		MessageArgs: []interface{}{v},
+	}
+}
+
+// quoteSQLString quotes a string as a SQL string literal. Snowflake interprets backslash escape sequences
+// in string literals, so backslashes are escaped as well as single quotes.
+func quoteSQLString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `''`, -1)
+	return "'" + s + "'"
+}
+
+func formatFloatLiteral(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::FLOAT"
+	case math.IsInf(f, 1):
+		return "'inf'::FLOAT"
+	case math.IsInf(f, -1):
+		return "'-inf'::FLOAT"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
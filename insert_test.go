@@ -0,0 +1,103 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"database/sql"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type tcFormatSQLLiteral struct {
+	in  interface{}
+	out string
+}
+
+func TestFormatSQLLiteral(t *testing.T) {
+	testcases := []tcFormatSQLLiteral{
+		{in: nil, out: "NULL"},
+		{in: true, out: "TRUE"},
+		{in: 123, out: "123"},
+		{in: int64(-5), out: "-5"},
+		{in: uint8(7), out: "7"},
+		{in: 1.5, out: "1.5"},
+		{in: math.NaN(), out: "'NaN'::FLOAT"},
+		{in: "it's a \\ test", out: "'it''s a \\\\ test'"},
+		{in: []byte{0xab, 0x01}, out: "X'AB01'"},
+		{in: time.Date(2018, 3, 4, 5, 6, 7, 800, time.UTC), out: "'2018-03-04 05:06:07.0000008 +00:00'::TIMESTAMP_TZ"},
+		{in: SQLExpr("PARSE_JSON('{}')"), out: "PARSE_JSON('{}')"},
+		{in: sql.NullString{}, out: "NULL"},
+		{in: sql.NullInt64{Int64: 3, Valid: true}, out: "3"},
+	}
+	for _, test := range testcases {
+		out, err := formatSQLLiteral(test.in)
+		if err != nil {
+			t.Errorf("failed to format. in: %v, err: %v", test.in, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("failed to format. in: %v, expected: %v, got: %v", test.in, test.out, out)
+		}
+	}
+	_, err := formatSQLLiteral(struct{}{})
+	if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrUnsupportedLiteralType {
+		t.Errorf("should have failed with unsupported type. err: %v", err)
+	}
+}
+
+func TestInsertBuilderStatements(t *testing.T) {
+	b := NewInsertBuilder("t", "a", "b")
+	if err := b.AddRow(1); err == nil {
+		t.Error("should have failed with the column count mismatch")
+	}
+	for i := 0; i < 5; i++ {
+		if err := b.AddRow(i, "x"); err != nil {
+			t.Fatalf("failed to add a row. err: %v", err)
+		}
+	}
+	stmts, err := b.Statements()
+	if err != nil {
+		t.Fatalf("failed to build the statements. err: %v", err)
+	}
+	expected := []string{"INSERT INTO t (a, b) VALUES (0, 'x'), (1, 'x'), (2, 'x'), (3, 'x'), (4, 'x')"}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements. expected: %v, got: %v", expected, stmts)
+	}
+
+	b.MaxRows = 2
+	if stmts, err = b.Statements(); err != nil {
+		t.Fatalf("failed to build the statements. err: %v", err)
+	}
+	expected = []string{
+		"INSERT INTO t (a, b) VALUES (0, 'x'), (1, 'x')",
+		"INSERT INTO t (a, b) VALUES (2, 'x'), (3, 'x')",
+		"INSERT INTO t (a, b) VALUES (4, 'x')",
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements. expected: %v, got: %v", expected, stmts)
+	}
+
+	b.MaxRows = 0
+	b.MaxStatementSize = len("INSERT INTO t (a, b) VALUES (0, 'x'), (1, 'x'), (2, 'x')")
+	if stmts, err = b.Statements(); err != nil {
+		t.Fatalf("failed to build the statements. err: %v", err)
+	}
+	expected = []string{
+		"INSERT INTO t (a, b) VALUES (0, 'x'), (1, 'x'), (2, 'x')",
+		"INSERT INTO t (a, b) VALUES (3, 'x'), (4, 'x')",
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements. expected: %v, got: %v", expected, stmts)
+	}
+	// a row larger than the statement size fails naming the row
+	b.MaxStatementSize = len("INSERT INTO t (a, b) VALUES (0, 'x')")
+	if err = b.AddRow(5, "xx"); err != nil {
+		t.Fatalf("failed to add a row. err: %v", err)
+	}
+	stmts, err = b.Statements()
+	if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrRowTooLarge || se.MessageArgs[0] != 5 {
+		t.Errorf("should have failed with the row too large. stmts: %v, err: %v", stmts, err)
+	}
+}
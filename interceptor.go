@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+)
+
+// StatementInterceptor is called before every Exec and Query with the statement and the binding parameters, and
+// returns the statement and the parameters to run, e.g., with a comment injected. Return an error to block the
+// statement; the error is returned to the caller as is.
+type StatementInterceptor func(ctx context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error)
+
+var (
+	statementInterceptors      []StatementInterceptor
+	statementInterceptorsMutex = &sync.RWMutex{}
+)
+
+// RegisterStatementInterceptor registers an interceptor for the statements of the connections opened afterwards.
+// The interceptors are called in the registration order, each with the output of the previous one.
+func RegisterStatementInterceptor(interceptor StatementInterceptor) {
+	statementInterceptorsMutex.Lock()
+	defer statementInterceptorsMutex.Unlock()
+	statementInterceptors = append(statementInterceptors, interceptor)
+}
+
+// registeredStatementInterceptors returns a copy of the registered interceptors.
+func registeredStatementInterceptors() []StatementInterceptor {
+	statementInterceptorsMutex.RLock()
+	defer statementInterceptorsMutex.RUnlock()
+	return append([]StatementInterceptor(nil), statementInterceptors...)
+}
+
+// intercept runs the statement through the interceptors of the connection.
+func (sc *snowflakeConn) intercept(ctx context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+	var err error
+	for _, interceptor := range sc.interceptors {
+		query, args, err = interceptor(ctx, query, args)
+		if err != nil {
+			glog.V(2).Infof("statement blocked by interceptor. err: %v", err)
+			return "", nil, err
+		}
+	}
+	return query, args, nil
+}
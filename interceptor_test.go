@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatementInterceptor(t *testing.T) {
+	var sent []execRequest
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		sent = append(sent, req)
+		return &execResponse{Success: true}, nil
+	}
+	tag := func(_ context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+		return "/* team=etl */ " + query, args, nil
+	}
+	block := func(_ context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+		if strings.Contains(strings.ToUpper(query), "DROP DATABASE") {
+			return "", nil, fmt.Errorf("blocked: %v", query)
+		}
+		return query, append(args, driver.NamedValue{Ordinal: len(args) + 1, Value: int64(1)}), nil
+	}
+	sc := &snowflakeConn{
+		cfg:          &Config{},
+		rest:         &snowflakeRestful{FuncPostQuery: postQuery},
+		interceptors: []StatementInterceptor{block, tag},
+	}
+	if _, err := sc.ExecContext(context.Background(), "drop database db", nil); err == nil || err.Error() != "blocked: drop database db" {
+		t.Fatalf("should have blocked the statement. err: %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("should not have sent the blocked statement. sent: %v", sent)
+	}
+	if _, err := sc.ExecContext(context.Background(), "create table t(c int)", nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if len(sent) != 1 || sent[0].SQLText != "/* team=etl */ create table t(c int)" {
+		t.Fatalf("failed to intercept the statement. sent: %v", sent)
+	}
+	if b, ok := sent[0].Bindings["1"]; !ok || b.Value != "1" {
+		t.Errorf("failed to add the binding parameter. bindings: %v", sent[0].Bindings)
+	}
+}
+
+func TestRegisterStatementInterceptor(t *testing.T) {
+	statementInterceptorsMutex.Lock()
+	org := statementInterceptors
+	statementInterceptors = nil
+	statementInterceptorsMutex.Unlock()
+	defer func() {
+		statementInterceptorsMutex.Lock()
+		statementInterceptors = org
+		statementInterceptorsMutex.Unlock()
+	}()
+	noop := func(_ context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+		return query, args, nil
+	}
+	RegisterStatementInterceptor(noop)
+	RegisterStatementInterceptor(noop)
+	interceptors := registeredStatementInterceptors()
+	if len(interceptors) != 2 {
+		t.Fatalf("failed to register the interceptors. got: %v", len(interceptors))
+	}
+	RegisterStatementInterceptor(noop)
+	if len(interceptors) != 2 {
+		t.Errorf("failed to copy the interceptors. got: %v", len(interceptors))
+	}
+}
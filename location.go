@@ -3,13 +3,16 @@
 package gosnowflake
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 var timezones map[int]*time.Location
+var namedLocations = make(map[string]*time.Location)
 var updateTimezoneMutex *sync.Mutex
 
 // Location returns an offset (minutes) based Location object for Snowflake database.
@@ -62,6 +65,64 @@ func LocationWithOffsetString(offsets string) (loc *time.Location, err error) {
 	return
 }
 
+const locationKey contextKey = "location"
+
+// WithLocation returns a context that overrides Config.Location for the queries run with it.
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, locationKey, loc)
+}
+
+// queryLocation returns the location for the DATE and TIMESTAMP_NTZ values of the query.
+func queryLocation(ctx context.Context, cfg *Config) *time.Location {
+	if loc, ok := ctx.Value(locationKey).(*time.Location); ok && loc != nil {
+		return loc
+	}
+	return cfg.Location
+}
+
+// sessionLocation returns the location of the TIMEZONE parameter of the session, or time.Local if it's unknown.
+func (sc *snowflakeConn) sessionLocation() *time.Location {
+	sc.mu.Lock()
+	tz := ""
+	for k, v := range sc.cfg.Params {
+		// the parameter returned by the server is preferred to the one in DSN
+		if v != nil && (k == sessionTimezone || tz == "" && strings.EqualFold(k, sessionTimezone)) {
+			tz = *v
+		}
+	}
+	sc.mu.Unlock()
+	if tz == "" {
+		return time.Local
+	}
+	loc, err := loadLocation(tz)
+	if err != nil {
+		glog.V(1).Infof("unknown time zone of the session: %v. err: %v", tz, err)
+		return time.Local
+	}
+	return loc
+}
+
+// loadLocation returns the location of the IANA time zone name, which is cached.
+func loadLocation(name string) (*time.Location, error) {
+	updateTimezoneMutex.Lock()
+	defer updateTimezoneMutex.Unlock()
+	if loc, ok := namedLocations[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	namedLocations[name] = loc
+	return loc, nil
+}
+
+// wallClockIn returns the time with the same wall clock as the UTC time t in the location. DATE and
+// TIMESTAMP_NTZ values have no time zone, so they are scanned in UTC by default.
+func wallClockIn(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
 func genTimezone(offset int) *time.Location {
 	var offsetSign string
 	var toffset int
@@ -3,8 +3,11 @@
 package gosnowflake
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
 	"testing"
+	"time"
 )
 
 type tcLocation struct {
@@ -97,3 +100,87 @@ func TestWithOffsetString(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryLocation(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	ny := time.FixedZone("EST", -5*3600)
+	if loc := queryLocation(context.Background(), &Config{}); loc != nil {
+		t.Errorf("should not have a location. got: %v", loc)
+	}
+	if loc := queryLocation(context.Background(), &Config{Location: tokyo}); loc != tokyo {
+		t.Errorf("failed to get Config.Location. got: %v", loc)
+	}
+	if loc := queryLocation(WithLocation(context.Background(), ny), &Config{Location: tokyo}); loc != ny {
+		t.Errorf("failed to override the location. got: %v", loc)
+	}
+}
+
+func TestRowsLocation(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	date := "17532"               // 2018-01-01
+	ntz := "1514808000.000000000" // 2018-01-01 12:00:00
+	ltz := "1514808000.000000000"
+	rows := &snowflakeRows{
+		RowType:  []execResponseRowType{{Type: "date"}, {Type: "timestamp_ntz"}, {Type: "timestamp_ltz"}},
+		location: tokyo,
+		ChunkDownloader: &snowflakeChunkDownloader{
+			CurrentChunk:  jsonChunk([][]*string{{&date, &ntz, &ltz}}),
+			Total:         1,
+			TotalRowIndex: -1,
+		},
+	}
+	rows.ChunkDownloader.start()
+	dest := make([]driver.Value, 3)
+	if err := rows.Next(dest); err != nil {
+		t.Fatalf("failed to get the row. err: %v", err)
+	}
+	if d := dest[0].(time.Time); !d.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, tokyo)) || d.Location() != tokyo {
+		t.Errorf("failed to attach the location to DATE. got: %v", d)
+	}
+	if d := dest[1].(time.Time); !d.Equal(time.Date(2018, 1, 1, 12, 0, 0, 0, tokyo)) || d.Location() != tokyo {
+		t.Errorf("failed to attach the location to TIMESTAMP_NTZ. got: %v", d)
+	}
+	if d := dest[2].(time.Time); d.Location() == tokyo {
+		t.Errorf("should not have changed TIMESTAMP_LTZ. got: %v", d)
+	}
+}
+
+func TestSessionLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database. err: %v", err)
+	}
+	sc := getDefaultSnowflakeConn()
+	if loc := sc.sessionLocation(); loc != time.Local {
+		t.Errorf("should have been the local time zone. got: %v", loc)
+	}
+	dsnTZ, serverTZ, unknown := "Asia/Tokyo", "America/New_York", "Nowhere/Unknown"
+	sc.cfg.Params["TIMEZONE"] = &dsnTZ
+	sc.cfg.Params["timezone"] = &serverTZ
+	if loc := sc.sessionLocation(); loc.String() != ny.String() {
+		t.Errorf("failed to get the time zone of the session. got: %v", loc)
+	}
+	sc.cfg.Params["timezone"] = &unknown
+	if loc := sc.sessionLocation(); loc != time.Local {
+		t.Errorf("should have been the local time zone for the unknown time zone. got: %v", loc)
+	}
+
+	ltz := "1514808000.000000000" // 2018-01-01 12:00:00 UTC
+	rows := &snowflakeRows{
+		RowType:     []execResponseRowType{{Type: "timestamp_ltz"}},
+		ltzLocation: ny,
+		ChunkDownloader: &snowflakeChunkDownloader{
+			CurrentChunk:  jsonChunk([][]*string{{&ltz}}),
+			Total:         1,
+			TotalRowIndex: -1,
+		},
+	}
+	rows.ChunkDownloader.start()
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		t.Fatalf("failed to get the row. err: %v", err)
+	}
+	if d := dest[0].(time.Time); !d.Equal(time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)) || d.Hour() != 7 {
+		t.Errorf("failed to get TIMESTAMP_LTZ in the time zone of the session. got: %v", d)
+	}
+}
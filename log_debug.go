@@ -1,30 +1,54 @@
 // +build sfdebug
 
-// Wrapper for glog to replace direct use, so that glog usage remains optional.
-// This file contains the actual/operational glog wrapper.
+// This file contains the glog logger used by default with the sfdebug tag.
 
 package gosnowflake
 
-import logger "github.com/golang/glog"
+import (
+	"bytes"
+	"fmt"
 
-// glogWrapper wraps glog's Verbose type, enabling the use of glog.V().* methods directly
-type glogWrapper struct {
-	logger.Verbose
+	logger "github.com/golang/glog"
+)
+
+// the verbosity of the logs is selected by the glog flags, e.g., -vmodule
+const defaultLogLevel = LogLevelTrace
+
+func defaultLogger() Logger {
+	return glogLogger{}
 }
 
-// V provides a wrapper for the glog.V() call
-func (l *glogWrapper) V(level int32) glogWrapper {
-	return glogWrapper{logger.V(logger.Level(level))}
+// glogLogger sends the logs to glog at the V levels the driver used to log with.
+type glogLogger struct{}
+
+func (glogLogger) Log(level LogLevel, msg string, fields []LogField) {
+	msg = formatLogFields(msg, fields)
+	switch level {
+	case LogLevelError:
+		logger.ErrorDepth(3, msg)
+	case LogLevelWarn:
+		logger.InfoDepth(3, msg)
+	default:
+		if logger.V(logger.Level(level - LogLevelWarn)) {
+			logger.InfoDepth(3, msg)
+		}
+	}
 }
 
-func (l *glogWrapper) IsEnabled(level int32) bool {
-	return bool(logger.V(logger.Level(level)))
+// formatLogFields appends the fields to the message as key=value.
+func formatLogFields(msg string, fields []LogField) string {
+	if len(fields) == 0 {
+		return msg
+	}
+	var b bytes.Buffer
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %v=%v", f.Key, f.Value)
+	}
+	return b.String()
 }
 
 // Flush calls flush on the underlying logger
-func (l *glogWrapper) Flush() {
+func (glogLogger) Flush() {
 	logger.Flush()
 }
-
-// glog is our glog wrapper
-var glog = glogWrapper{}
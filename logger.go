@@ -0,0 +1,227 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LogLevel is the level of the driver logs.
+type LogLevel int32
+
+const (
+	// LogLevelOff disables the driver logs.
+	LogLevelOff LogLevel = iota
+	// LogLevelError logs the errors.
+	LogLevelError
+	// LogLevelWarn logs the unexpected responses and conditions the driver recovers from.
+	LogLevelWarn
+	// LogLevelInfo logs the retries, failovers and failed requests.
+	LogLevelInfo
+	// LogLevelDebug logs the requests and responses.
+	LogLevelDebug
+	// LogLevelTrace logs the driver API calls.
+	LogLevelTrace
+)
+
+var logLevelNames = []string{"off", "error", "warn", "info", "debug", "trace"}
+
+func (l LogLevel) String() string {
+	if l < 0 || int(l) >= len(logLevelNames) {
+		return fmt.Sprintf("LogLevel(%d)", int32(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel returns the log level of the name, e.g., debug. The name is case-insensitive.
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogLevelOff, fmt.Errorf("unknown log level: %v", s)
+}
+
+// LogField is a key and value of a structured log record.
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// The keys of the fields added to the logs of the queries.
+const (
+	LogFieldSessionID = "sessionId"
+	LogFieldRequestID = "requestId"
+	LogFieldQueryID   = "queryId"
+)
+
+// Logger receives the driver logs at or above the level set by SetLogLevel. The fields include the ones given by
+// WithLogFields and the session, request and query IDs of the queries. Log may be called concurrently.
+type Logger interface {
+	Log(level LogLevel, msg string, fields []LogField)
+}
+
+// flusher is implemented by the loggers buffering the logs, which are flushed by the driver, e.g., on errors.
+type flusher interface {
+	Flush()
+}
+
+// loggerHolder holds the Logger in atomic.Value, which can't store nil.
+type loggerHolder struct {
+	logger Logger
+}
+
+var (
+	currentLogger = newLoggerValue()
+	logLevel      = int32(defaultLogLevel)
+	setLoggerMu   sync.Mutex
+)
+
+// newLoggerValue returns the value holding the default logger. It's set by the variable declaration, not by an init
+// function, as the init functions of the other files may log before it would run.
+func newLoggerValue() *atomic.Value {
+	v := &atomic.Value{}
+	v.Store(loggerHolder{defaultLogger()})
+	return v
+}
+
+// SetLogger sets the logger of the driver logs. nil restores the default logger, which discards the logs unless
+// the driver is built with the sfdebug tag.
+func SetLogger(logger Logger) {
+	setLoggerMu.Lock()
+	defer setLoggerMu.Unlock()
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	currentLogger.Store(loggerHolder{logger})
+}
+
+// SetLogLevel sets the level of the driver logs. It can be changed while the driver is in use.
+func SetLogLevel(level LogLevel) {
+	atomic.StoreInt32(&logLevel, int32(level))
+}
+
+// GetLogLevel returns the level of the driver logs.
+func GetLogLevel() LogLevel {
+	return LogLevel(atomic.LoadInt32(&logLevel))
+}
+
+func getLogger() Logger {
+	return currentLogger.Load().(loggerHolder).logger
+}
+
+const logFieldsKey contextKey = "logFields"
+
+// WithLogFields returns the context adding the fields to the driver logs of the queries run with it, e.g., the
+// trace ID of the application.
+func WithLogFields(ctx context.Context, fields ...LogField) context.Context {
+	current, _ := ctx.Value(logFieldsKey).([]LogField)
+	merged := make([]LogField, 0, len(current)+len(fields))
+	merged = append(merged, current...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, logFieldsKey, merged)
+}
+
+// driverLogger sends the logs to the Logger. The call sites use the V levels of glog, which the driver used to
+// log with: V(0) is warn, V(1) info, V(2) debug and V(3) trace.
+type driverLogger struct {
+	fields []LogField
+}
+
+// glog is the logger of the driver.
+var glog = driverLogger{}
+
+// WithContext returns the logger adding the fields of the context.
+func (l driverLogger) WithContext(ctx context.Context) driverLogger {
+	fields, _ := ctx.Value(logFieldsKey).([]LogField)
+	return driverLogger{fields: fields}
+}
+
+// V returns the logger at the glog verbosity.
+func (l driverLogger) V(verbosity int32) verboseLogger {
+	level := verbosityLevel(verbosity)
+	return verboseLogger{level: level, fields: l.fields, enabled: isLogEnabled(level)}
+}
+
+// IsEnabled returns true if the logs at the glog verbosity are sent to the logger.
+func (l driverLogger) IsEnabled(verbosity int32) bool {
+	return isLogEnabled(verbosityLevel(verbosity))
+}
+
+// Flush flushes the logger if it buffers the logs.
+func (l driverLogger) Flush() {
+	if f, ok := getLogger().(flusher); ok {
+		f.Flush()
+	}
+}
+
+func verbosityLevel(verbosity int32) LogLevel {
+	if verbosity <= 0 {
+		return LogLevelWarn
+	}
+	if verbosity >= 3 {
+		return LogLevelTrace
+	}
+	return LogLevelWarn + LogLevel(verbosity)
+}
+
+func isLogEnabled(level LogLevel) bool {
+	return level <= GetLogLevel() && getLogger() != nil
+}
+
+// verboseLogger logs at a level like glog.Verbose.
+type verboseLogger struct {
+	level   LogLevel
+	fields  []LogField
+	enabled bool
+}
+
+// Info logs the arguments formatted like fmt.Sprint.
+func (v verboseLogger) Info(args ...interface{}) {
+	if v.enabled {
+		v.log(fmt.Sprint(args...))
+	}
+}
+
+// Infoln logs the arguments formatted like fmt.Sprintln.
+func (v verboseLogger) Infoln(args ...interface{}) {
+	if v.enabled {
+		v.log(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+	}
+}
+
+// Infof logs the arguments formatted like fmt.Sprintf.
+func (v verboseLogger) Infof(format string, args ...interface{}) {
+	if v.enabled {
+		v.log(fmt.Sprintf(format, args...))
+	}
+}
+
+// InfoDepth logs the arguments formatted like fmt.Sprint.
+func (v verboseLogger) InfoDepth(_ int, args ...interface{}) {
+	v.Info(args...)
+}
+
+// log sends the message to the logger with the secrets masked.
+func (v verboseLogger) log(msg string) {
+	logger := getLogger()
+	if logger == nil {
+		return
+	}
+	var fields []LogField
+	if len(v.fields) > 0 {
+		fields = make([]LogField, len(v.fields))
+		for i, f := range v.fields {
+			if s, ok := f.Value.(string); ok {
+				f.Value = maskSecrets(s)
+			}
+			fields[i] = f
+		}
+	}
+	logger.Log(v.level, maskSecrets(msg), fields)
+}
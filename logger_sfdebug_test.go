@@ -0,0 +1,20 @@
+// +build sfdebug
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import "testing"
+
+// The init functions logging at the trace level of the sfdebug builds must find the default logger.
+func TestUnitDefaultLoggerSfdebug(t *testing.T) {
+	if _, ok := getLogger().(glogLogger); !ok {
+		t.Fatalf("unexpected default logger: %T", getLogger())
+	}
+	if GetLogLevel() != LogLevelTrace {
+		t.Errorf("unexpected default log level: %v", GetLogLevel())
+	}
+	if len(timezones) == 0 {
+		t.Error("should have generated the timezones at init")
+	}
+}
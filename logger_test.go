@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type logRecord struct {
+	level  LogLevel
+	msg    string
+	fields []LogField
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	records []logRecord
+}
+
+func (l *recordingLogger) Log(level LogLevel, msg string, fields []LogField) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, logRecord{level, msg, fields})
+}
+
+func setTestLogger(level LogLevel) *recordingLogger {
+	l := &recordingLogger{}
+	SetLogger(l)
+	SetLogLevel(level)
+	return l
+}
+
+func resetTestLogger(prev LogLevel) {
+	SetLogger(nil)
+	SetLogLevel(prev)
+}
+
+func TestUnitLogLevel(t *testing.T) {
+	prev := GetLogLevel()
+	defer resetTestLogger(prev)
+	l := setTestLogger(LogLevelInfo)
+
+	glog.V(0).Infof("warn %v", 1)
+	glog.V(1).Infoln("info", 2)
+	glog.V(2).Info("debug")
+	glog.V(3).Info("trace")
+	if len(l.records) != 2 || l.records[0].level != LogLevelWarn || l.records[0].msg != "warn 1" ||
+		l.records[1].level != LogLevelInfo || l.records[1].msg != "info 2" {
+		t.Errorf("failed to filter the logs by the level. got: %v", l.records)
+	}
+	if !glog.IsEnabled(1) || glog.IsEnabled(2) {
+		t.Errorf("failed to check the level")
+	}
+
+	SetLogLevel(LogLevelTrace)
+	glog.V(3).Info("trace")
+	if len(l.records) != 3 || l.records[2].level != LogLevelTrace {
+		t.Errorf("failed to change the level. got: %v", l.records)
+	}
+	SetLogLevel(LogLevelOff)
+	glog.V(0).Info("warn")
+	if len(l.records) != 3 {
+		t.Errorf("should have disabled the logs. got: %v", l.records)
+	}
+}
+
+func TestUnitParseLogLevel(t *testing.T) {
+	for _, level := range []LogLevel{LogLevelOff, LogLevelError, LogLevelWarn, LogLevelInfo, LogLevelDebug, LogLevelTrace} {
+		parsed, err := ParseLogLevel(level.String())
+		if err != nil || parsed != level {
+			t.Errorf("failed to parse the log level. expected: %v, got: %v, err: %v", level, parsed, err)
+		}
+	}
+	if level, err := ParseLogLevel("DEBUG"); err != nil || level != LogLevelDebug {
+		t.Errorf("failed to parse the log level case-insensitively. got: %v, err: %v", level, err)
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Errorf("should have failed to parse the log level")
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	prev := GetLogLevel()
+	defer resetTestLogger(prev)
+	l := setTestLogger(LogLevelDebug)
+
+	sc := getDefaultSnowflakeConn()
+	sc.rest.SessionID = 123
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, _ []byte, _ time.Duration) (*execResponse, error) {
+		return &execResponse{Success: true, Data: execResponseData{QueryID: "01-q"}}, nil
+	}
+	ctx := WithLogFields(context.Background(), LogField{"traceId", "t1"})
+	if _, err := sc.ExecContext(ctx, "SELECT 1", []driver.NamedValue{}); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	found := false
+	for _, r := range l.records {
+		if r.msg != "Exec/Query SUCCESS" {
+			continue
+		}
+		found = true
+		fields := make(map[string]interface{})
+		for _, f := range r.fields {
+			fields[f.Key] = f.Value
+		}
+		if fields["traceId"] != "t1" || fields[LogFieldSessionID] != 123 || fields[LogFieldQueryID] != "01-q" {
+			t.Errorf("failed to add the fields of the context. got: %v", r.fields)
+		}
+	}
+	if !found {
+		t.Errorf("failed to log the query. got: %v", l.records)
+	}
+}
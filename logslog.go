@@ -0,0 +1,44 @@
+// +build go1.21
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns the Logger sending the driver logs to the slog logger. The trace logs are sent at
+// slog.LevelDebug-4.
+//
+//	sf.SetLogger(sf.NewSlogLogger(slog.Default().With("component", "snowflake")))
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Log(level LogLevel, msg string, fields []LogField) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	s.l.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelError:
+		return slog.LevelError
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelDebug:
+		return slog.LevelDebug
+	}
+	return slog.LevelDebug - 4
+}
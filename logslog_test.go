@@ -0,0 +1,26 @@
+// +build go1.21
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestUnitSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	l.Log(LogLevelInfo, "query", []LogField{{LogFieldQueryID, "01-q"}})
+	l.Log(LogLevelTrace, "trace", nil)
+	out := buf.String()
+	if !strings.Contains(out, "level=INFO msg=query queryId=01-q") {
+		t.Errorf("failed to log with the fields. got: %v", out)
+	}
+	if strings.Contains(out, "trace") {
+		t.Errorf("should have logged the trace logs below debug. got: %v", out)
+	}
+}
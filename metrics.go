@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The names of the driver metrics, following the Prometheus naming conventions.
+const (
+	// MetricQueries is the counter of the queries executed.
+	MetricQueries = "snowflake_queries_total"
+	// MetricQueryErrors is the counter of the queries failed.
+	MetricQueryErrors = "snowflake_query_errors_total"
+	// MetricQueryDuration is the histogram of the round trip seconds of the queries.
+	MetricQueryDuration = "snowflake_query_duration_seconds"
+	// MetricRowsFetched is the counter of the rows read from the results.
+	MetricRowsFetched = "snowflake_rows_fetched_total"
+	// MetricBytesDownloaded is the counter of the bytes of the result chunks downloaded.
+	MetricBytesDownloaded = "snowflake_downloaded_bytes_total"
+	// MetricRetries is the counter of the HTTP requests retried.
+	MetricRetries = "snowflake_retries_total"
+	// MetricAuthFailures is the counter of the failed logins.
+	MetricAuthFailures = "snowflake_auth_failures_total"
+	// MetricOpenSessions is the gauge of the open connections.
+	MetricOpenSessions = "snowflake_open_sessions"
+	// MetricChunkBufferBytes is the gauge of the bytes of the result chunks downloaded ahead of the rows read and
+	// held in memory.
+	MetricChunkBufferBytes = "snowflake_chunk_buffer_bytes"
+	// MetricChunksSpilled is the counter of the result chunks spilled to Config.ChunkSpillDir.
+	MetricChunksSpilled = "snowflake_chunks_spilled_total"
+)
+
+// MetricsCollector receives the driver metrics as they change, e.g., to update Prometheus counters, histograms
+// and gauges of the same names. The methods are called concurrently and must not block.
+type MetricsCollector interface {
+	// AddCounter adds the delta to the counter.
+	AddCounter(name string, delta float64)
+	// ObserveHistogram adds the value to the histogram.
+	ObserveHistogram(name string, value float64)
+	// AddGauge adds the delta, which may be negative, to the gauge.
+	AddGauge(name string, delta float64)
+}
+
+var (
+	metricsCollectors      atomic.Value // []MetricsCollector
+	metricsCollectorsMutex = &sync.Mutex{}
+)
+
+// RegisterMetricsCollector registers a collector of the metrics of all connections. For example:
+//
+//	type promCollector struct{ counters map[string]prometheus.Counter; ... }
+//
+//	sf.RegisterMetricsCollector(&promCollector{...})
+func RegisterMetricsCollector(collector MetricsCollector) {
+	metricsCollectorsMutex.Lock()
+	defer metricsCollectorsMutex.Unlock()
+	collectors := getMetricsCollectors()
+	metricsCollectors.Store(append(append([]MetricsCollector(nil), collectors...), collector))
+}
+
+// UnregisterMetricsCollector removes the collector registered by RegisterMetricsCollector.
+func UnregisterMetricsCollector(collector MetricsCollector) {
+	metricsCollectorsMutex.Lock()
+	defer metricsCollectorsMutex.Unlock()
+	var collectors []MetricsCollector
+	for _, c := range getMetricsCollectors() {
+		if c != collector {
+			collectors = append(collectors, c)
+		}
+	}
+	metricsCollectors.Store(collectors)
+}
+
+func getMetricsCollectors() []MetricsCollector {
+	collectors, _ := metricsCollectors.Load().([]MetricsCollector)
+	return collectors
+}
+
+func addMetricsCounter(name string, delta float64) {
+	for _, c := range getMetricsCollectors() {
+		c.AddCounter(name, delta)
+	}
+}
+
+func observeMetricsHistogram(name string, value float64) {
+	for _, c := range getMetricsCollectors() {
+		c.ObserveHistogram(name, value)
+	}
+}
+
+func addMetricsGauge(name string, delta float64) {
+	for _, c := range getMetricsCollectors() {
+		c.AddGauge(name, delta)
+	}
+}
+
+// recordQueryMetrics records the query executed in the round trip.
+func recordQueryMetrics(roundTrip time.Duration, failed bool) {
+	addMetricsCounter(MetricQueries, 1)
+	if failed {
+		addMetricsCounter(MetricQueryErrors, 1)
+	}
+	observeMetricsHistogram(MetricQueryDuration, roundTrip.Seconds())
+}
+
+// downloadCounter counts the bytes read from the chunk as MetricBytesDownloaded.
+type downloadCounter struct {
+	io.ReadCloser
+}
+
+func (c downloadCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		addMetricsCounter(MetricBytesDownloaded, float64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMetricsCollector struct {
+	mu         sync.Mutex
+	counters   map[string]float64
+	histograms map[string][]float64
+	gauges     map[string]float64
+}
+
+func newTestMetricsCollector() *testMetricsCollector {
+	return &testMetricsCollector{
+		counters:   make(map[string]float64),
+		histograms: make(map[string][]float64),
+		gauges:     make(map[string]float64),
+	}
+}
+
+func (c *testMetricsCollector) AddCounter(name string, delta float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[name] += delta
+}
+
+func (c *testMetricsCollector) ObserveHistogram(name string, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.histograms[name] = append(c.histograms[name], value)
+}
+
+func (c *testMetricsCollector) AddGauge(name string, delta float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gauges[name] += delta
+}
+
+func TestMetricsCollector(t *testing.T) {
+	c := newTestMetricsCollector()
+	RegisterMetricsCollector(c)
+	defer UnregisterMetricsCollector(c)
+
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, _ []byte, _ time.Duration) (*execResponse, error) {
+		return &execResponse{Success: true, Data: execResponseData{
+			RowType: []execResponseRowType{{Name: "C1", Type: "fixed"}},
+			RowSet:  [][]*string{{sp("1")}, {sp("2")}},
+			Total:   2,
+		}}, nil
+	}
+	rows, err := sc.QueryContext(context.Background(), "SELECT 1", []driver.NamedValue{})
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	dest := make([]driver.Value, 1)
+	for rows.Next(dest) == nil {
+	}
+	rows.Close()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, _ []byte, _ time.Duration) (*execResponse, error) {
+		return &execResponse{Success: false, Code: "1003", Message: "syntax error"}, nil
+	}
+	if _, err = sc.ExecContext(context.Background(), "SELEC 1", []driver.NamedValue{}); err == nil {
+		t.Fatalf("should have failed to exec")
+	}
+	recordConnOpened()
+	recordConnClosed(time.Minute)
+	recordConnOpened()
+	body := downloadCounter{ioutil.NopCloser(bytes.NewReader(make([]byte, 10)))}
+	if _, err = ioutil.ReadAll(body); err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counters[MetricQueries] != 2 || c.counters[MetricQueryErrors] != 1 || len(c.histograms[MetricQueryDuration]) != 2 {
+		t.Errorf("failed to record the queries. counters: %v, histograms: %v", c.counters, c.histograms)
+	}
+	if c.counters[MetricRowsFetched] != 2 {
+		t.Errorf("failed to record the rows fetched. got: %v", c.counters[MetricRowsFetched])
+	}
+	if c.counters[MetricBytesDownloaded] != 10 {
+		t.Errorf("failed to record the bytes downloaded. got: %v", c.counters[MetricBytesDownloaded])
+	}
+	if c.gauges[MetricOpenSessions] != 1 {
+		t.Errorf("failed to record the open sessions. got: %v", c.gauges[MetricOpenSessions])
+	}
+}
+
+func TestUnregisterMetricsCollector(t *testing.T) {
+	c := newTestMetricsCollector()
+	RegisterMetricsCollector(c)
+	UnregisterMetricsCollector(c)
+	addMetricsCounter(MetricRetries, 1)
+	if len(c.counters) != 0 {
+		t.Errorf("should have unregistered the collector. got: %v", c.counters)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package migrate provides the pieces schema migration tools need to run migrations on Snowflake with the
+// Go Snowflake Driver: splitting a migration script into statements, advisory locking with a lock table
+// and running DDL, which implicitly commits the current transaction in Snowflake, outside of transactions.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// SupportsTransactionalDDL is false because Snowflake commits the current transaction before and after
+// executing a DDL statement, so DDL cannot be rolled back.
+const SupportsTransactionalDDL = false
+
+// SplitStatements splits a script into statements. See gosnowflake.SplitStatements.
+func SplitStatements(script string) []string {
+	return sf.SplitStatements(script)
+}
+
+var ddlRegexp = regexp.MustCompile(`(?i)^\s*(CREATE|ALTER|DROP|UNDROP|RENAME|TRUNCATE|COMMENT|GRANT|REVOKE|USE)\b`)
+
+// IsDDL returns true if the statement is a DDL statement or any other statement that implicitly commits
+// the current transaction in Snowflake.
+func IsDDL(stmt string) bool {
+	return ddlRegexp.MatchString(stmt)
+}
+
+// Exec runs the statements of a migration script in order on one connection, so session state set by
+// statements like USE SCHEMA or ALTER SESSION applies to the rest of the script. Consecutive DML statements run
+// in a transaction, and DDL statements run on their own because they implicitly commit. If a statement fails,
+// the statements of the current transaction are rolled back, but the DDL statements executed so far remain.
+func Exec(ctx context.Context, db *sql.DB, script string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var tx *sql.Tx
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit()
+		tx = nil
+		return err
+	}
+	for _, stmt := range SplitStatements(script) {
+		if IsDDL(stmt) {
+			if err := commit(); err != nil {
+				return err
+			}
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to execute %q. err: %v", stmt, err)
+			}
+			continue
+		}
+		if tx == nil {
+			if tx, err = conn.BeginTx(ctx, nil); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to execute %q. err: %v", stmt, err)
+		}
+	}
+	return commit()
+}
+
+// DefaultLockTable is the table used by Locker if no table is specified.
+const DefaultLockTable = "MIGRATION_LOCK"
+
+// DefaultLockExpiry is the time after which Locker takes over a lock whose holder didn't release it.
+const DefaultLockExpiry = time.Hour
+
+// Locker is an advisory lock built on a lock table. Snowflake doesn't have advisory locks, and concurrent
+// INSERTs into a table don't block each other, but an UPDATE locks the table. So Init seeds one row per lock
+// and a conditional UPDATE of its owner acquires the lock for only one client.
+type Locker struct {
+	DB           *sql.DB
+	Table        string        // DefaultLockTable if empty
+	PollInterval time.Duration // interval to retry acquiring a held lock. 1 second if 0.
+	Expiry       time.Duration // a lock held longer than this is stale and can be taken over. DefaultLockExpiry if 0.
+	Owner        string        // identifies the holder of the lock. A random ID if empty.
+
+	ownerOnce sync.Once
+}
+
+// NewLocker returns a Locker using the lock table.
+func NewLocker(db *sql.DB, table string) *Locker {
+	return &Locker{DB: db, Table: table}
+}
+
+func (l *Locker) table() string {
+	if l.Table == "" {
+		return DefaultLockTable
+	}
+	return l.Table
+}
+
+func (l *Locker) expiry() time.Duration {
+	if l.Expiry == 0 {
+		return DefaultLockExpiry
+	}
+	return l.Expiry
+}
+
+func (l *Locker) owner() string {
+	l.ownerOnce.Do(func() {
+		if l.Owner == "" {
+			l.Owner = uuid.New().String()
+		}
+	})
+	return l.Owner
+}
+
+// Init creates the lock table if it doesn't exist and adds the row of the lock if it isn't there.
+func (l *Locker) Init(ctx context.Context, lockID string) error {
+	_, err := l.DB.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %v (LOCK_ID VARCHAR NOT NULL, OWNER VARCHAR, LOCKED_AT TIMESTAMP_LTZ)", l.table()))
+	if err != nil {
+		return err
+	}
+	_, err = l.DB.ExecContext(ctx, fmt.Sprintf(
+		"MERGE INTO %v t USING (SELECT ? AS LOCK_ID) s ON t.LOCK_ID = s.LOCK_ID WHEN NOT MATCHED THEN INSERT (LOCK_ID) VALUES (s.LOCK_ID)",
+		l.table()), lockID)
+	return err
+}
+
+// TryLock tries to acquire the lock once and returns true if it is acquired. The lock must be added by Init.
+func (l *Locker) TryLock(ctx context.Context, lockID string) (bool, error) {
+	res, err := l.DB.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %v SET OWNER = ?, LOCKED_AT = CURRENT_TIMESTAMP() WHERE LOCK_ID = ? AND (OWNER IS NULL OR LOCKED_AT < DATEADD(SECOND, ?, CURRENT_TIMESTAMP()))",
+		l.table()), l.owner(), lockID, -int64(l.expiry()/time.Second))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Lock acquires the lock, waiting until it is released by the other client, expires or the context is done.
+func (l *Locker) Lock(ctx context.Context, lockID string) error {
+	interval := l.PollInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	for {
+		ok, err := l.TryLock(ctx, lockID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Unlock releases the lock if this Locker holds it.
+func (l *Locker) Unlock(ctx context.Context, lockID string) error {
+	_, err := l.DB.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %v SET OWNER = NULL, LOCKED_AT = NULL WHERE LOCK_ID = ? AND OWNER = ?", l.table()), lockID, l.owner())
+	return err
+}
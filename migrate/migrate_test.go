@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+type tcSplitStatements struct {
+	script string
+	out    []string
+}
+
+func TestSplitStatements(t *testing.T) {
+	testcases := []tcSplitStatements{
+		{script: "", out: nil},
+		{script: "select 1", out: []string{"select 1"}},
+		{script: "select 1;select 2;", out: []string{"select 1", "select 2"}},
+		{script: "select ';' ; select \"a;b\"", out: []string{"select ';'", "select \"a;b\""}},
+		{script: "select 'it\\'s;'; select 2", out: []string{"select 'it\\'s;'", "select 2"}},
+		{script: "-- comment;\nselect 1; /* ; */ select 2 -- x;\nPUT file:///tmp/a.csv @~", out: []string{"select 1", "select 2 -- x;\nPUT file:///tmp/a.csv @~"}},
+	}
+	for _, test := range testcases {
+		out := SplitStatements(test.script)
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("failed to split statements. script: %q, expected: %q, got: %q", test.script, test.out, out)
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("CREATE TABLE t").WillReturnResult(0)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO t VALUES\\(1\\)").WillReturnResult(1)
+	mock.ExpectExec("INSERT INTO t VALUES\\(2\\)").WillReturnResult(1)
+	mock.ExpectCommit()
+	mock.ExpectExec("ALTER TABLE t").WillReturnResult(0)
+	err = Exec(context.Background(), db,
+		"CREATE TABLE t (c INT);\nINSERT INTO t VALUES(1);\nINSERT INTO t VALUES(2);\nALTER TABLE t ADD COLUMN d INT;")
+	if err != nil {
+		t.Fatalf("failed to execute the script. err: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLocker(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	l := NewLocker(db, "")
+	l.PollInterval = time.Millisecond
+	l.Owner = "me"
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS MIGRATION_LOCK").WillReturnResult(0)
+	mock.ExpectExec("MERGE INTO MIGRATION_LOCK").WithArgs("app").WillReturnResult(1)
+	mock.ExpectExec("UPDATE MIGRATION_LOCK SET OWNER = \\?").WithArgs("me", "app", int64(-3600)).WillReturnResult(0)
+	mock.ExpectExec("UPDATE MIGRATION_LOCK SET OWNER = \\?").WithArgs("me", "app", int64(-3600)).WillReturnResult(1)
+	mock.ExpectExec("UPDATE MIGRATION_LOCK SET OWNER = NULL").WithArgs("app", "me").WillReturnResult(1)
+	if err = l.Init(context.Background(), "app"); err != nil {
+		t.Fatalf("failed to create the lock table. err: %v", err)
+	}
+	if err = l.Lock(context.Background(), "app"); err != nil {
+		t.Fatalf("failed to lock. err: %v", err)
+	}
+	if err = l.Unlock(context.Background(), "app"); err != nil {
+		t.Fatalf("failed to unlock. err: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
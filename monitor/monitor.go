@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package monitor provides typed helpers to monitor the runs of tasks with INFORMATION_SCHEMA.TASK_HISTORY and
+// the status of pipes with SYSTEM$PIPE_STATUS, and to wait for them, e.g., in orchestration services. Unlike
+// the ACCOUNT_USAGE views, both have no latency. The task and pipe names are resolved in the current database
+// and schema of the session unless they are qualified.
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// pollInterval is the interval to check the task history or the pipe status while waiting.
+var pollInterval = 10 * time.Second
+
+// Task run states of TASK_HISTORY.
+const (
+	TaskStateScheduled = "SCHEDULED"
+	TaskStateExecuting = "EXECUTING"
+	TaskStateSucceeded = "SUCCEEDED"
+	TaskStateFailed    = "FAILED"
+	TaskStateCancelled = "CANCELLED"
+	TaskStateSkipped   = "SKIPPED"
+)
+
+// TaskRun is a run of a task in TASK_HISTORY. QueryStartTime and CompletedTime are nil until the run starts
+// and completes.
+type TaskRun struct {
+	Name           string
+	DatabaseName   string
+	SchemaName     string
+	State          string
+	QueryID        string
+	ErrorCode      string
+	ErrorMessage   string
+	ScheduledTime  time.Time
+	QueryStartTime *time.Time
+	CompletedTime  *time.Time
+	RunID          int64
+}
+
+// Done returns true if the run is in a final state.
+func (r *TaskRun) Done() bool {
+	switch r.State {
+	case TaskStateSucceeded, TaskStateFailed, TaskStateCancelled, TaskStateSkipped:
+		return true
+	}
+	return false
+}
+
+// TaskHistory returns the runs of the task scheduled at or after since, the latest first, up to limit runs.
+// If task is empty, the runs of all tasks are returned. TASK_HISTORY keeps the runs for 7 days.
+func TaskHistory(ctx context.Context, q sf.Queryer, task string, since time.Time, limit int) ([]TaskRun, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	args := []interface{}{sf.DataTypeTimestampLtz, since, limit}
+	taskArg := ""
+	if task != "" {
+		taskArg = "TASK_NAME => ?, "
+		args = append([]interface{}{task}, args...)
+	}
+	rows, err := q.QueryContext(ctx, `SELECT NAME, DATABASE_NAME, SCHEMA_NAME, STATE, COALESCE(QUERY_ID, ''),
+  COALESCE(ERROR_CODE, ''), COALESCE(ERROR_MESSAGE, ''), SCHEDULED_TIME, QUERY_START_TIME, COMPLETED_TIME,
+  COALESCE(RUN_ID, 0)
+FROM TABLE(INFORMATION_SCHEMA.TASK_HISTORY(`+taskArg+`SCHEDULED_TIME_RANGE_START => ?, RESULT_LIMIT => ?))
+ORDER BY SCHEDULED_TIME DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []TaskRun
+	for rows.Next() {
+		var r TaskRun
+		if err = rows.Scan(&r.Name, &r.DatabaseName, &r.SchemaName, &r.State, &r.QueryID, &r.ErrorCode,
+			&r.ErrorMessage, &r.ScheduledTime, &r.QueryStartTime, &r.CompletedTime, &r.RunID); err != nil {
+			return nil, err
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+// WaitForTaskRun waits until the first run of the task scheduled at or after since is done, and returns it.
+// Check the State of the run for the outcome. For example, to wait for a run triggered by EXECUTE TASK:
+//
+//	since := time.Now()
+//	db.ExecContext(ctx, "EXECUTE TASK load_orders")
+//	run, err := monitor.WaitForTaskRun(ctx, db, "load_orders", since)
+func WaitForTaskRun(ctx context.Context, q sf.Queryer, task string, since time.Time) (*TaskRun, error) {
+	for {
+		runs, err := TaskHistory(ctx, q, task, since, 0)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(runs); n > 0 && runs[n-1].Done() {
+			return &runs[n-1], nil
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Pipe execution states of SYSTEM$PIPE_STATUS.
+const (
+	PipeStateRunning = "RUNNING"
+	PipeStatePaused  = "PAUSED"
+)
+
+// PipeStatus is the status of a pipe returned by SYSTEM$PIPE_STATUS. The timestamps are zero if not returned.
+type PipeStatus struct {
+	ExecutionState                  string    `json:"executionState"`
+	PendingFileCount                int64     `json:"pendingFileCount"`
+	LastIngestedTimestamp           time.Time `json:"lastIngestedTimestamp"`
+	LastIngestedFilePath            string    `json:"lastIngestedFilePath"`
+	NotificationChannelName         string    `json:"notificationChannelName"`
+	NumOutstandingMessagesOnChannel int64     `json:"numOutstandingMessagesOnChannel"`
+	LastReceivedMessageTimestamp    time.Time `json:"lastReceivedMessageTimestamp"`
+	LastForwardedMessageTimestamp   time.Time `json:"lastForwardedMessageTimestamp"`
+	LastPulledFromChannelTimestamp  time.Time `json:"lastPulledFromChannelTimestamp"`
+	Error                           string    `json:"error"`
+	Fault                           string    `json:"fault"`
+}
+
+// GetPipeStatus returns the status of the pipe.
+func GetPipeStatus(ctx context.Context, q sf.Queryer, pipe string) (*PipeStatus, error) {
+	rows, err := q.QueryContext(ctx, "SELECT SYSTEM$PIPE_STATUS(?)", pipe)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var s string
+	if rows.Next() {
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return parsePipeStatus(s)
+}
+
+// parsePipeStatus parses the JSON string returned by SYSTEM$PIPE_STATUS.
+func parsePipeStatus(s string) (*PipeStatus, error) {
+	var st PipeStatus
+	if err := json.Unmarshal([]byte(s), &st); err != nil {
+		return nil, fmt.Errorf("failed to parse the pipe status. status: %v, err: %v", s, err)
+	}
+	return &st, nil
+}
+
+// WaitForPipeDrained waits until the pipe has no pending files, e.g., after the files are staged or
+// ALTER PIPE ... REFRESH, and returns the status. It fails if the pipe is not running.
+func WaitForPipeDrained(ctx context.Context, q sf.Queryer, pipe string) (*PipeStatus, error) {
+	for {
+		st, err := GetPipeStatus(ctx, q, pipe)
+		if err != nil {
+			return nil, err
+		}
+		if st.ExecutionState != PipeStateRunning {
+			return st, fmt.Errorf("pipe %v is not running. state: %v, error: %v", pipe, st.ExecutionState, st.Error)
+		}
+		if st.PendingFileCount == 0 {
+			return st, nil
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
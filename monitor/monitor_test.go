@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func taskHistoryRows(runs ...[]driver.Value) *sfmock.Rows {
+	rows := sfmock.NewRows(
+		sfmock.Column{Name: "NAME", Type: "TEXT"},
+		sfmock.Column{Name: "DATABASE_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "SCHEMA_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "STATE", Type: "TEXT"},
+		sfmock.Column{Name: "QUERY_ID", Type: "TEXT"},
+		sfmock.Column{Name: "ERROR_CODE", Type: "TEXT"},
+		sfmock.Column{Name: "ERROR_MESSAGE", Type: "TEXT"},
+		sfmock.Column{Name: "SCHEDULED_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "QUERY_START_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "COMPLETED_TIME", Type: "TIMESTAMP_LTZ"},
+		sfmock.Column{Name: "RUN_ID", Type: "FIXED"})
+	for _, r := range runs {
+		rows.AddRow(r...)
+	}
+	return rows
+}
+
+func TestTaskMonitoring(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	ts := time.Date(2018, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`TASK_HISTORY\(TASK_NAME => \?, SCHEDULED_TIME_RANGE_START`).WillReturnRows(taskHistoryRows(
+		[]driver.Value{"LOAD", "DB", "PUBLIC", TaskStateScheduled, "", "", "", ts.Add(time.Hour), nil, nil, "0"},
+		[]driver.Value{"LOAD", "DB", "PUBLIC", TaskStateFailed, "qid", "100038", "Numeric value is not recognized",
+			ts, ts.Add(time.Second), ts.Add(time.Minute), "1514862000000"}))
+	mock.ExpectQuery(`TASK_HISTORY\(SCHEDULED_TIME_RANGE_START`).WillReturnRows(taskHistoryRows())
+
+	ctx := context.Background()
+	runs, err := TaskHistory(ctx, db, "LOAD", ts, 10)
+	if err != nil {
+		t.Fatalf("failed to get the task history. err: %v", err)
+	}
+	if len(runs) != 2 || runs[0].QueryStartTime != nil || runs[0].Done() {
+		t.Errorf("unexpected scheduled run: %v", runs)
+	}
+	if r := runs[1]; !r.Done() || r.ErrorCode != "100038" || r.CompletedTime == nil || !r.CompletedTime.Equal(ts.Add(time.Minute)) {
+		t.Errorf("unexpected failed run: %v", r)
+	}
+	if runs, err = TaskHistory(ctx, db, "", ts, 0); err != nil || len(runs) != 0 {
+		t.Errorf("unexpected task history. runs: %v, err: %v", runs, err)
+	}
+
+	mock.ExpectQuery("TASK_HISTORY").WillReturnRows(taskHistoryRows())
+	mock.ExpectQuery("TASK_HISTORY").WillReturnRows(taskHistoryRows(
+		[]driver.Value{"LOAD", "DB", "PUBLIC", TaskStateExecuting, "qid", "", "", ts, ts, nil, "1"}))
+	mock.ExpectQuery("TASK_HISTORY").WillReturnRows(taskHistoryRows(
+		[]driver.Value{"LOAD", "DB", "PUBLIC", TaskStateScheduled, "", "", "", ts.Add(time.Hour), nil, nil, "0"},
+		[]driver.Value{"LOAD", "DB", "PUBLIC", TaskStateSucceeded, "qid", "", "", ts, ts, ts.Add(time.Minute), "1"}))
+	run, err := WaitForTaskRun(ctx, db, "LOAD", ts)
+	if err != nil {
+		t.Fatalf("failed to wait for the task run. err: %v", err)
+	}
+	if run.State != TaskStateSucceeded || run.QueryID != "qid" {
+		t.Errorf("unexpected task run: %v", run)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPipeMonitoring(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	status := func(s string) *sfmock.Rows {
+		return sfmock.NewRows(sfmock.Column{Name: "SYSTEM$PIPE_STATUS('P')", Type: "TEXT"}).AddRow(s)
+	}
+
+	mock.ExpectQuery(`SYSTEM\$PIPE_STATUS`).WithArgs("db.public.p").WillReturnRows(status(
+		`{"executionState":"RUNNING","pendingFileCount":2,"lastIngestedTimestamp":"2018-01-02T03:04:05.678Z","lastIngestedFilePath":"a.csv.gz"}`))
+	mock.ExpectQuery(`SYSTEM\$PIPE_STATUS`).WillReturnRows(status(`{"executionState":"RUNNING","pendingFileCount":0}`))
+	mock.ExpectQuery(`SYSTEM\$PIPE_STATUS`).WillReturnRows(status(`{"executionState":"STOPPED_STAGE_DROPPED","pendingFileCount":3}`))
+
+	ctx := context.Background()
+	st, err := GetPipeStatus(ctx, db, "db.public.p")
+	if err != nil {
+		t.Fatalf("failed to get the pipe status. err: %v", err)
+	}
+	if st.PendingFileCount != 2 || st.LastIngestedFilePath != "a.csv.gz" ||
+		!st.LastIngestedTimestamp.Equal(time.Date(2018, 1, 2, 3, 4, 5, 678000000, time.UTC)) {
+		t.Errorf("unexpected pipe status: %v", st)
+	}
+	if st, err = WaitForPipeDrained(ctx, db, "p"); err != nil || st.PendingFileCount != 0 {
+		t.Errorf("failed to wait for the pipe. status: %v, err: %v", st, err)
+	}
+	if _, err = WaitForPipeDrained(ctx, db, "p"); err == nil {
+		t.Errorf("should fail for the stopped pipe")
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if _, err = parsePipeStatus("Pipe not found"); err == nil {
+		t.Errorf("should fail to parse the invalid status")
+	}
+}
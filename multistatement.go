@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+const multiStatementCountKey contextKey = "multiStatementCount"
+
+// multiStatementCountParam is the query parameter of the number of statements in the query text.
+const multiStatementCountParam = "MULTI_STATEMENT_COUNT"
+
+// statementTypeIDMultiStatement is the statement type of a multi-statement query.
+const statementTypeIDMultiStatement = int64(0x1000)
+
+// WithMultiStatement returns a context that allows the queries run with it to have num statements separated by
+// semicolons. If num is 0, any number of statements is allowed. Snowflake fails the query if the number of
+// statements doesn't match. ExecContext returns the total rows affected by the DML statements, and
+// QueryContext returns the result of each statement in order, moved to by NextResultSet:
+//
+//	ctx := sf.WithMultiStatement(context.Background(), 0)
+//	rows, err := db.QueryContext(ctx, "SELECT 1; SELECT 2, 3")
+//	for {
+//		for rows.Next() {
+//			...
+//		}
+//		if !rows.NextResultSet() {
+//			break
+//		}
+//	}
+//
+// The statements run in order, and the query fails at the first failing statement. The statements before it are
+// not rolled back unless they run in a transaction.
+func WithMultiStatement(ctx context.Context, num int) context.Context {
+	return context.WithValue(ctx, multiStatementCountKey, num)
+}
+
+// multiStatementResultIDs returns the query IDs of the statements of the multi-statement query, or nil if the
+// response is not of a multi-statement query.
+func multiStatementResultIDs(data *execResponse) []string {
+	if data.Data.StatementTypeID != statementTypeIDMultiStatement || data.Data.ResultIDs == "" {
+		return nil
+	}
+	return strings.Split(data.Data.ResultIDs, ",")
+}
+
+// queryResult gets the result of the completed query.
+func (sc *snowflakeConn) queryResult(ctx context.Context, queryID string) (*execResponse, error) {
+	data, err := sc.rest.FuncGetQueryResult(ctx, sc.rest, queryID)
+	if err != nil {
+		return nil, err
+	}
+	if !data.Success {
+		return nil, execResponseError(data)
+	}
+	return data, nil
+}
+
+// multiStatementRowsAffected returns the total rows affected by the DML statements of the multi-statement query.
+func (sc *snowflakeConn) multiStatementRowsAffected(ctx context.Context, data *execResponse) (int64, error) {
+	ids := multiStatementResultIDs(data)
+	types := strings.Split(data.Data.ResultTypes, ",")
+	var total int64
+	for i, id := range ids {
+		if i < len(types) {
+			if t, err := strconv.ParseInt(types[i], 10, 64); err == nil && !sc.isDml(t) {
+				// no rows affected. skips fetching the result.
+				continue
+			}
+		}
+		res, err := sc.queryResult(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if !sc.isDml(res.Data.StatementTypeID) {
+			continue
+		}
+		n, err := updatedRows(res)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
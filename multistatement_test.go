@@ -0,0 +1,74 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestUnitMultiStatementResultIDs(t *testing.T) {
+	testcases := []struct {
+		typeID int64
+		ids    string
+		out    []string
+	}{
+		{typeID: statementTypeIDMultiStatement, ids: "01,02,03", out: []string{"01", "02", "03"}},
+		{typeID: statementTypeIDMultiStatement, ids: "", out: nil},
+		{typeID: statementTypeIDInsert, ids: "01,02", out: nil},
+	}
+	for _, test := range testcases {
+		data := &execResponse{Data: execResponseData{StatementTypeID: test.typeID, ResultIDs: test.ids}}
+		if ids := multiStatementResultIDs(data); !reflect.DeepEqual(ids, test.out) {
+			t.Errorf("failed to get the result IDs. type: %v, ids: %v, expected: %v, got: %v", test.typeID, test.ids, test.out, ids)
+		}
+	}
+}
+
+func TestUnitMultiStatementRowsAffected(t *testing.T) {
+	rowsAffected := func(n string) *execResponse {
+		return &execResponse{
+			Success: true,
+			Data: execResponseData{
+				StatementTypeID: statementTypeIDInsert,
+				RowType:         []execResponseRowType{{Name: "number of rows inserted"}},
+				RowSet:          [][]*string{{&n}},
+			},
+		}
+	}
+	results := map[string]*execResponse{
+		"01": rowsAffected("2"),
+		"03": rowsAffected("3"),
+		"04": {Success: false, Code: "100183", Message: "failed statement"},
+	}
+	var fetched []string
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncGetQueryResult = func(_ context.Context, _ *snowflakeRestful, queryID string) (*execResponse, error) {
+		fetched = append(fetched, queryID)
+		return results[queryID], nil
+	}
+	data := &execResponse{Data: execResponseData{
+		StatementTypeID: statementTypeIDMultiStatement,
+		ResultIDs:       "01,02,03",
+		ResultTypes:     "12544,4096,12544",
+	}}
+	n, err := sc.multiStatementRowsAffected(context.Background(), data)
+	if err != nil {
+		t.Fatalf("failed to get the rows affected. err: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("failed to sum the rows affected. expected: 5, got: %v", n)
+	}
+	if !reflect.DeepEqual(fetched, []string{"01", "03"}) {
+		t.Errorf("failed to skip the non-DML results. fetched: %v", fetched)
+	}
+
+	data.Data.ResultIDs = "01,04"
+	data.Data.ResultTypes = ""
+	if _, err = sc.multiStatementRowsAffected(context.Background(), data); err == nil {
+		t.Error("should have failed with the failed statement")
+	} else if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != 100183 {
+		t.Errorf("failed to return the error of the statement. err: %v", err)
+	}
+}
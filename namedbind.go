@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+)
+
+func invalidNamedParameterError(reason interface{}) error {
+	return &SnowflakeError{
+		Number:      ErrInvalidNamedParameter,
+		Message:     errMsgInvalidNamedParameter,
+		MessageArgs: []interface{}{reason},
+	}
+}
+
+// bindNamedParameters replaces the :name placeholders of the query with ? and orders the parameters given by
+// sql.Named accordingly. A name may be used more than once. The query and parameters are returned as is if no
+// parameter has a name. Placeholders in string literals, quoted identifiers, $$ delimited bodies and comments
+// are ignored, and so are the paths of semi-structured data, e.g., src:name, and casts, e.g., v::int.
+func bindNamedParameters(query string, parameters []driver.NamedValue) (string, []driver.NamedValue, error) {
+	named := make(map[string]driver.NamedValue)
+	for _, p := range parameters {
+		if p.Name != "" {
+			named[p.Name] = p
+		}
+	}
+	if len(named) == 0 {
+		return query, parameters, nil
+	}
+	if len(named) != len(parameters) {
+		return "", nil, invalidNamedParameterError("named and positional parameters can't be mixed")
+	}
+	var out bytes.Buffer
+	var bound []driver.NamedValue
+	used := make(map[string]bool)
+	code := sqlCodeMask(query)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case !code[i]:
+		case c == '?':
+			return "", nil, invalidNamedParameterError("positional placeholder ? can't be used with named parameters")
+		case c == ':' && isNamedPlaceholder(query, i):
+			j := i + 1
+			for j < len(query) && isIdentifierChar(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			p, ok := named[name]
+			if !ok {
+				return "", nil, invalidNamedParameterError(fmt.Sprintf("no value for :%v", name))
+			}
+			used[name] = true
+			p.Name = ""
+			p.Ordinal = len(bound) + 1
+			bound = append(bound, p)
+			out.WriteByte('?')
+			i = j - 1
+			continue
+		}
+		out.WriteByte(c)
+	}
+	for name := range named {
+		if !used[name] {
+			return "", nil, invalidNamedParameterError(fmt.Sprintf("no placeholder for %v", name))
+		}
+	}
+	return out.String(), bound, nil
+}
+
+// isNamedPlaceholder returns true if the colon at i starts a :name placeholder rather than a path of
+// semi-structured data or a cast.
+func isNamedPlaceholder(query string, i int) bool {
+	if i+1 >= len(query) || !isIdentifierStart(query[i+1]) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch p := query[i-1]; {
+	case isIdentifierChar(p), p == ':', p == '"', p == ']', p == ')':
+		return false
+	}
+	return true
+}
+
+func isIdentifierStart(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_'
+}
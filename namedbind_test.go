@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnitBindNamedParameters(t *testing.T) {
+	id := driver.NamedValue{Name: "id", Ordinal: 1, Value: int64(1)}
+	name := driver.NamedValue{Name: "name", Ordinal: 2, Value: "a"}
+	testcases := []struct {
+		query    string
+		params   []driver.NamedValue
+		outQuery string
+		outNames []string
+	}{
+		{
+			query:    "SELECT * FROM t WHERE id = :id AND name = :name",
+			params:   []driver.NamedValue{id, name},
+			outQuery: "SELECT * FROM t WHERE id = ? AND name = ?",
+			outNames: []string{"id", "name"},
+		},
+		{
+			query:    "INSERT INTO t VALUES (:name, :id, :name)",
+			params:   []driver.NamedValue{id, name},
+			outQuery: "INSERT INTO t VALUES (?, ?, ?)",
+			outNames: []string{"name", "id", "name"},
+		},
+		{
+			query:    "SELECT src:name, v::int, ':id', \":id\", $$ :id $$ /* :id */ FROM t WHERE id=:id -- :name\n AND name=:name",
+			params:   []driver.NamedValue{id, name},
+			outQuery: "SELECT src:name, v::int, ':id', \":id\", $$ :id $$ /* :id */ FROM t WHERE id=? -- :name\n AND name=?",
+			outNames: []string{"id", "name"},
+		},
+		{
+			query:    "SELECT :id // :name\n FROM t WHERE name=:name",
+			params:   []driver.NamedValue{id, name},
+			outQuery: "SELECT ? // :name\n FROM t WHERE name=?",
+			outNames: []string{"id", "name"},
+		},
+		{
+			query:    "SELECT ? FROM t",
+			params:   []driver.NamedValue{{Ordinal: 1, Value: int64(1)}},
+			outQuery: "SELECT ? FROM t",
+		},
+	}
+	values := map[string]driver.Value{"id": id.Value, "name": name.Value}
+	for _, test := range testcases {
+		query, params, err := bindNamedParameters(test.query, test.params)
+		if err != nil {
+			t.Errorf("failed to bind the named parameters. query: %v, err: %v", test.query, err)
+			continue
+		}
+		if query != test.outQuery {
+			t.Errorf("failed to replace the placeholders. expected: %v, got: %v", test.outQuery, query)
+		}
+		if test.outNames == nil {
+			if !reflect.DeepEqual(params, test.params) {
+				t.Errorf("should have returned the parameters as is. got: %v", params)
+			}
+			continue
+		}
+		if len(params) != len(test.outNames) {
+			t.Fatalf("failed to order the parameters. expected: %v, got: %v", test.outNames, params)
+		}
+		for i, p := range params {
+			if p.Name != "" || p.Ordinal != i+1 || p.Value != values[test.outNames[i]] {
+				t.Errorf("failed to order the parameters. expected: %v, got: %v", test.outNames[i], p)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		query  string
+		params []driver.NamedValue
+	}{
+		{query: "SELECT :id, ?", params: []driver.NamedValue{id}},
+		{query: "SELECT :id, :name", params: []driver.NamedValue{id, {Ordinal: 2, Value: "a"}}},
+		{query: "SELECT :id, :other", params: []driver.NamedValue{id}},
+		{query: "SELECT :id", params: []driver.NamedValue{id, name}},
+	} {
+		_, _, err := bindNamedParameters(test.query, test.params)
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidNamedParameter {
+			t.Errorf("should have failed to bind the named parameters. query: %v, err: %v", test.query, err)
+		}
+	}
+}
+
+func TestNamedBindExec(t *testing.T) {
+	var sent execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		if err := json.Unmarshal(body, &sent); err != nil {
+			return nil, err
+		}
+		return &execResponse{Success: true}, nil
+	}
+	args := []driver.NamedValue{{Name: "id", Ordinal: 1, Value: int64(7)}, {Name: "name", Ordinal: 2, Value: "a"}}
+	if _, err := sc.ExecContext(context.Background(), "UPDATE t SET name = :name WHERE id = :id", args); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if sent.SQLText != "UPDATE t SET name = ? WHERE id = ?" {
+		t.Errorf("failed to replace the placeholders. got: %v", sent.SQLText)
+	}
+	if b := sent.Bindings["1"]; b.Type != "TEXT" || b.Value != "a" {
+		t.Errorf("failed to bind :name. got: %v", b)
+	}
+	if b := sent.Bindings["2"]; b.Type != "FIXED" || b.Value != "7" {
+		t.Errorf("failed to bind :id. got: %v", b)
+	}
+}
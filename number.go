@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// NumberMode is the Go type of the NUMBER values fetched. NUMBER(38, x) doesn't fit int64 or float64, so the
+// values are rounded or fail to scan in the default mode.
+type NumberMode string
+
+const (
+	// NumberDefault reports int64 as the scan type for scale 0 and float64 otherwise, and returns the decimal
+	// strings converted to the scanned types by database/sql. This is the default.
+	NumberDefault NumberMode = "default"
+	// NumberBig returns *big.Int for scale 0 and *big.Rat otherwise, which hold the values without losing
+	// precision.
+	NumberBig NumberMode = "big"
+	// NumberString returns the decimal strings and reports string as the scan type, so that the values can be
+	// parsed by any decimal library.
+	NumberString NumberMode = "string"
+)
+
+const numberModeKey contextKey = "numberMode"
+
+func parseNumberMode(s string) (NumberMode, error) {
+	switch m := NumberMode(s); m {
+	case NumberDefault, NumberBig, NumberString:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid numberMode: %v. must be default, big or string", s)
+}
+
+// WithNumberMode returns a context that overrides Config.NumberMode for the queries run with it.
+func WithNumberMode(ctx context.Context, mode NumberMode) context.Context {
+	return context.WithValue(ctx, numberModeKey, mode)
+}
+
+// queryNumberMode returns the mode of the NUMBER values of the query.
+func queryNumberMode(ctx context.Context, cfg *Config) NumberMode {
+	if m, ok := ctx.Value(numberModeKey).(NumberMode); ok && m != "" {
+		return m
+	}
+	return cfg.NumberMode
+}
+
+// numberScanType returns the scan type of the NUMBER column in the mode.
+func numberScanType(mode NumberMode, scale int64) reflect.Type {
+	switch {
+	case mode == NumberString:
+		return reflect.TypeOf("")
+	case mode == NumberBig && scale == 0:
+		return reflect.TypeOf(&big.Int{})
+	case mode == NumberBig:
+		return reflect.TypeOf(&big.Rat{})
+	}
+	return snowflakeTypeToGo("fixed", scale)
+}
+
+// numberValue converts the decimal string of the NUMBER value to the type of the mode.
+func numberValue(mode NumberMode, scale int64, v driver.Value) (driver.Value, error) {
+	s, ok := v.(string)
+	if !ok || mode != NumberBig {
+		return v, nil
+	}
+	if scale == 0 {
+		if n, ok := new(big.Int).SetString(s, 10); ok {
+			return n, nil
+		}
+	} else if r, ok := new(big.Rat).SetString(s); ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("invalid NUMBER value: %v", s)
+}
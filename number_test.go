@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestQueryNumberMode(t *testing.T) {
+	cfg := &Config{}
+	ctx := context.Background()
+	if m := queryNumberMode(ctx, cfg); m != "" {
+		t.Errorf("should have been the default mode. got: %v", m)
+	}
+	cfg.NumberMode = NumberString
+	if m := queryNumberMode(ctx, cfg); m != NumberString {
+		t.Errorf("failed to get the mode of the config. got: %v", m)
+	}
+	if m := queryNumberMode(WithNumberMode(ctx, NumberBig), cfg); m != NumberBig {
+		t.Errorf("failed to override the mode by the context. got: %v", m)
+	}
+	if _, err := parseNumberMode("decimal"); err == nil {
+		t.Error("should have failed to parse the mode")
+	}
+}
+
+func TestRowsNumberMode(t *testing.T) {
+	large := "12345678901234567890123456789012345678"
+	scaled := "-1234567890123456789012345678901234.5678"
+	rowType := []execResponseRowType{
+		{Type: "fixed", Precision: 38, Scale: 0},
+		{Type: "fixed", Precision: 38, Scale: 4},
+		{Type: "real"},
+	}
+	newRows := func(mode NumberMode) *snowflakeRows {
+		rows := &snowflakeRows{
+			RowType:    rowType,
+			numberMode: mode,
+			ChunkDownloader: &snowflakeChunkDownloader{
+				CurrentChunk:  jsonChunk([][]*string{{&large, &scaled, &scaled}, {nil, nil, nil}}),
+				Total:         2,
+				TotalRowIndex: -1,
+			},
+		}
+		rows.ChunkDownloader.start()
+		return rows
+	}
+
+	testcases := []struct {
+		mode      NumberMode
+		scanTypes []reflect.Type
+		values    []driver.Value
+	}{
+		{
+			mode:      "",
+			scanTypes: []reflect.Type{reflect.TypeOf(int64(0)), reflect.TypeOf(float64(0)), reflect.TypeOf(float64(0))},
+			values:    []driver.Value{large, scaled, scaled},
+		},
+		{
+			mode:      NumberString,
+			scanTypes: []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(""), reflect.TypeOf(float64(0))},
+			values:    []driver.Value{large, scaled, scaled},
+		},
+		{
+			mode:      NumberBig,
+			scanTypes: []reflect.Type{reflect.TypeOf(&big.Int{}), reflect.TypeOf(&big.Rat{}), reflect.TypeOf(float64(0))},
+			values:    []driver.Value{bigInt(large), bigRat(scaled), scaled},
+		},
+	}
+	for _, test := range testcases {
+		rows := newRows(test.mode)
+		for i, st := range test.scanTypes {
+			if got := rows.ColumnTypeScanType(i); got != st {
+				t.Errorf("failed to get the scan type. mode: %v, column: %v, expected: %v, got: %v", test.mode, i, st, got)
+			}
+		}
+		dest := make([]driver.Value, 3)
+		if err := rows.Next(dest); err != nil {
+			t.Fatalf("failed to get the row. mode: %v, err: %v", test.mode, err)
+		}
+		if !reflect.DeepEqual(dest, test.values) {
+			t.Errorf("failed to get the values. mode: %v, expected: %v, got: %v", test.mode, test.values, dest)
+		}
+		if err := rows.Next(dest); err != nil || dest[0] != nil || dest[1] != nil {
+			t.Errorf("failed to get NULL. mode: %v, got: %v, err: %v", test.mode, dest, err)
+		}
+	}
+
+	rows := newRows(NumberBig)
+	if p, s, ok := rows.ColumnTypePrecisionScale(1); !ok || p != 38 || s != 4 {
+		t.Errorf("failed to get the precision and scale. got: %v, %v, %v", p, s, ok)
+	}
+	if _, _, ok := rows.ColumnTypePrecisionScale(3); ok {
+		t.Error("should have failed to get the precision and scale of no column")
+	}
+	if _, err := numberValue(NumberBig, 0, "1.5"); err == nil {
+		t.Error("should have failed to convert the decimal to an integer")
+	}
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func bigRat(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
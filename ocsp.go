@@ -20,6 +20,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -50,6 +52,40 @@ const (
 	cacheExpire = float64(24 * 60 * 60)
 )
 
+const (
+	// defaultOCSPCacheServerURL is the cache server of the OCSP responses of the certificates of Snowflake and
+	// the cloud storages, in the format of the cache file.
+	defaultOCSPCacheServerURL = "http://ocsp.snowflakecomputing.com/" + cacheFileBaseName + ".json"
+	// ocspCacheServerInterval is the minimum interval of the downloads from the cache server.
+	ocspCacheServerInterval = 10 * time.Minute
+)
+
+var (
+	// ocspCacheServerURL is set by SF_OCSP_RESPONSE_CACHE_SERVER_URL, or by the host of the connection.
+	ocspCacheServerURL = defaultOCSPCacheServerURL
+	// ocspCacheServerURLFromEnv is true if SF_OCSP_RESPONSE_CACHE_SERVER_URL is set.
+	ocspCacheServerURLFromEnv = false
+	// ocspCacheServerEnabled is set by SF_OCSP_RESPONSE_CACHE_SERVER_ENABLED.
+	ocspCacheServerEnabled = true
+	// ocspCacheServerDownloaded is the time of the last download, guarded by ocspCacheServerLock.
+	ocspCacheServerDownloaded time.Time
+	ocspCacheServerLock       sync.Mutex
+)
+
+// OCSPFailOpenMode is the behavior of the OCSP check when the revocation status of a certificate cannot be
+// determined, e.g., the OCSP responder is unavailable.
+type OCSPFailOpenMode uint8
+
+const (
+	// OCSPFailOpenNotSet is the default, fail-open.
+	OCSPFailOpenNotSet OCSPFailOpenMode = iota
+	// OCSPFailOpenTrue accepts the certificate if the revocation status cannot be determined. A revoked
+	// certificate is rejected.
+	OCSPFailOpenTrue
+	// OCSPFailOpenFalse rejects the certificate unless the revocation status is good.
+	OCSPFailOpenFalse
+)
+
 const (
 	tolerableValidityRatio = 100               // buffer for certificate revocation update time
 	maxClockSkew           = 900 * time.Second // buffer for clock skew
@@ -271,7 +307,7 @@ func retryOCSP(
 	sleepTime := time.Duration(0)
 	for {
 		sleepTime = defaultWaitAlgo.decorr(retryCounter, sleepTime)
-		res, err := retryHTTP(context.TODO(), client, req, "POST", ocspHost, headers, reqBody, httpTimeout, false)
+		res, err := retryHTTP(context.TODO(), client, nil, req, "POST", ocspHost, headers, reqBody, httpTimeout, false)
 		if err != nil {
 			if ok := retryRevocationStatusCheck(&totalTimeout, sleepTime); ok {
 				retryCounter++
@@ -326,8 +362,9 @@ func retryOCSP(
 	}
 }
 
-// getRevocationStatus checks the certificate revocation status for subject using issuer certificate.
-func getRevocationStatus(wg *sync.WaitGroup, ocspStatusChan chan<- *ocspStatus, subject, issuer *x509.Certificate) {
+// getRevocationStatus checks the certificate revocation status for subject using issuer certificate. The OCSP
+// requests are sent via transport.
+func getRevocationStatus(wg *sync.WaitGroup, ocspStatusChan chan<- *ocspStatus, subject, issuer *x509.Certificate, transport http.RoundTripper) {
 	defer wg.Done()
 	glog.V(2).Infof("Subject: %v\n", subject.Subject)
 	glog.V(2).Infof("Issuer:  %v\n", issuer.Subject)
@@ -369,11 +406,16 @@ func getRevocationStatus(wg *sync.WaitGroup, ocspStatusChan chan<- *ocspStatus,
 		return
 	}
 	glog.V(2).Infof("cache missed: %v\n", ocspValidatedWithCache.err)
+	downloadOCSPCacheServer(transport)
+	ocspValidatedWithCache = checkOCSPResponseCache(encodedCertID, subject, issuer)
+	if ocspValidatedWithCache.code == ocspSuccess {
+		ocspStatusChan <- ocspValidatedWithCache
+		return
+	}
 
-	st := snowflakeInsecureTransport
 	ocspClient := &http.Client{
 		Timeout:   30 * time.Second,
-		Transport: st,
+		Transport: transport,
 	}
 	headers := make(map[string]string)
 	headers["Content-Type"] = "application/ocsp-request"
@@ -393,8 +435,9 @@ func getRevocationStatus(wg *sync.WaitGroup, ocspStatusChan chan<- *ocspStatus,
 	ocspResponseCacheLock.Unlock()
 }
 
-// verifyPeerCertificate verifies all of certificate revocation status
-func verifyPeerCertificate(callback func(*sync.WaitGroup, []*x509.Certificate) []*ocspStatus, verifiedChains [][]*x509.Certificate) (err error) {
+// verifyPeerCertificate verifies all of certificate revocation status. In fail-open mode, only a revoked or
+// unknown certificate is an error, and the other failures are logged.
+func verifyPeerCertificate(callback func(*sync.WaitGroup, []*x509.Certificate) []*ocspStatus, verifiedChains [][]*x509.Certificate, failOpen bool) (err error) {
 	for i := 0; i < len(verifiedChains); i++ {
 		var wg sync.WaitGroup
 		n := len(verifiedChains[i]) - 1
@@ -411,104 +454,270 @@ func verifyPeerCertificate(callback func(*sync.WaitGroup, []*x509.Certificate) [
 		results := callback(&wg, verifiedChains[i])
 		wg.Wait()
 		for _, r := range results {
-			if r.err != nil {
-				return r.err
+			if r.err == nil {
+				continue
+			}
+			if failOpen && r.code != ocspRevokedOrUnknown {
+				glog.V(1).Infof("WARNING: failed to check the certificate revocation status. accepted in fail-open mode. err: %v", r.err)
+				addOCSPFailure(r.err, true)
+				continue
 			}
+			addOCSPFailure(r.err, false)
+			return r.err
 		}
 	}
-	writeOCSPCacheFile()
+	writeOCSPCache()
 	return nil
 }
 
-func getAllRevocationStatusParallel(wg *sync.WaitGroup, verifiedChains []*x509.Certificate) []*ocspStatus {
-	n := len(verifiedChains) - 1
-	ocspStatusChan := make(chan *ocspStatus, n)
-	for j := 0; j < n; j++ {
-		go getRevocationStatus(wg, ocspStatusChan, verifiedChains[j], verifiedChains[j+1])
-	}
-	results := make([]*ocspStatus, n)
-	for j := 0; j < n; j++ {
-		results[j] = <-ocspStatusChan // will wait for all results back
+// getAllRevocationStatusParallel returns the callback of verifyPeerCertificate that checks the revocation status in
+// parallel, sending the OCSP requests via transport.
+func getAllRevocationStatusParallel(transport http.RoundTripper) func(*sync.WaitGroup, []*x509.Certificate) []*ocspStatus {
+	return func(wg *sync.WaitGroup, verifiedChains []*x509.Certificate) []*ocspStatus {
+		n := len(verifiedChains) - 1
+		ocspStatusChan := make(chan *ocspStatus, n)
+		for j := 0; j < n; j++ {
+			go getRevocationStatus(wg, ocspStatusChan, verifiedChains[j], verifiedChains[j+1], transport)
+		}
+		results := make([]*ocspStatus, n)
+		for j := 0; j < n; j++ {
+			results[j] = <-ocspStatusChan // will wait for all results back
+		}
+		close(ocspStatusChan)
+		return results
 	}
-	close(ocspStatusChan)
-	return results
 }
 
-func getAllRevocationStatusSerial(wg *sync.WaitGroup, verifiedChains []*x509.Certificate) []*ocspStatus {
-	n := len(verifiedChains) - 1
-	results := make([]*ocspStatus, n)
-	for j := 0; j < n; j++ {
-		ocspStatusChan := make(chan *ocspStatus, 1)
-		getRevocationStatus(wg, ocspStatusChan, verifiedChains[j], verifiedChains[j+1])
-		results[j] = <-ocspStatusChan
-		close(ocspStatusChan)
+// getAllRevocationStatusSerial returns the callback of verifyPeerCertificate that checks the revocation status in
+// serial, sending the OCSP requests via transport.
+func getAllRevocationStatusSerial(transport http.RoundTripper) func(*sync.WaitGroup, []*x509.Certificate) []*ocspStatus {
+	return func(wg *sync.WaitGroup, verifiedChains []*x509.Certificate) []*ocspStatus {
+		n := len(verifiedChains) - 1
+		results := make([]*ocspStatus, n)
+		for j := 0; j < n; j++ {
+			ocspStatusChan := make(chan *ocspStatus, 1)
+			getRevocationStatus(wg, ocspStatusChan, verifiedChains[j], verifiedChains[j+1], transport)
+			results[j] = <-ocspStatusChan
+			close(ocspStatusChan)
+		}
+		return results
 	}
-	return results
 }
 
-// verifyPeerCertificateSerial verifies the certificate revocation status in serial.
+// verifyPeerCertificateSerial verifies the certificate revocation status in serial in fail-closed mode.
 // This is mainly used by tools that analyzes the OCSP output
 func verifyPeerCertificateSerial(_ [][]byte, verifiedChains [][]*x509.Certificate) (err error) {
-	return verifyPeerCertificate(getAllRevocationStatusSerial, verifiedChains)
+	return verifyPeerCertificate(getAllRevocationStatusSerial(snowflakeInsecureTransport), verifiedChains, false)
 }
 
-// verifyPeerCertificateParallel verifies the certificate revocation status in parallel.
+// verifyPeerCertificateParallel verifies the certificate revocation status in parallel in fail-open mode.
 // This is mainly used for general connection
 func verifyPeerCertificateParallel(_ [][]byte, verifiedChains [][]*x509.Certificate) (err error) {
-	return verifyPeerCertificate(getAllRevocationStatusParallel, verifiedChains)
+	return verifyPeerCertificate(getAllRevocationStatusParallel(snowflakeInsecureTransport), verifiedChains, true)
+}
+
+// verifyPeerCertificateParallelFailClosed verifies the certificate revocation status in parallel in fail-closed
+// mode.
+func verifyPeerCertificateParallelFailClosed(_ [][]byte, verifiedChains [][]*x509.Certificate) (err error) {
+	return verifyPeerCertificate(getAllRevocationStatusParallel(snowflakeInsecureTransport), verifiedChains, false)
 }
 
-// readOCSPCacheFile reads a OCSP Response cache file. This should be called in init().
-func readOCSPCacheFile() {
+// newVerifyPeerCertificate returns the certificate revocation check in parallel that sends the OCSP requests via
+// ocspTransport, e.g., with the proxy of the connection.
+func newVerifyPeerCertificate(ocspTransport http.RoundTripper, failOpen bool) func([][]byte, [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		return verifyPeerCertificate(getAllRevocationStatusParallel(ocspTransport), verifiedChains, failOpen)
+	}
+}
+
+// OCSPCacheStore persists the OCSP response cache, a JSON document, so that the revocation status is not checked
+// again for every process. By default, the cache is stored in a file in the user cache directory, or not
+// persisted in WebAssembly.
+type OCSPCacheStore interface {
+	Load() ([]byte, error)
+	Save(b []byte) error
+}
+
+// ocspCacheStore is guarded by ocspResponseCacheLock. nil if the cache is not persisted.
+var ocspCacheStore OCSPCacheStore
+
+// SetOCSPCacheStore replaces the store of the OCSP response cache and loads the cache from it. nil disables the
+// persistence. Call it before opening connections.
+func SetOCSPCacheStore(store OCSPCacheStore) {
+	ocspResponseCacheLock.Lock()
+	ocspCacheStore = store
+	ocspResponseCacheLock.Unlock()
+	readOCSPCache()
+}
+
+// readOCSPCache reads the OCSP Response cache from the store.
+func readOCSPCache() {
+	ocspResponseCacheLock.Lock()
+	defer ocspResponseCacheLock.Unlock()
 	ocspResponseCache = make(map[string][]interface{})
-	ocspResponseCacheLock = &sync.RWMutex{}
-	cacheFileName = filepath.Join(cacheDir, cacheFileBaseName)
-	glog.V(2).Infof("reading OCSP Response cache file. %v\n", cacheFileName)
-	raw, err := ioutil.ReadFile(cacheFileName)
+	if ocspCacheStore == nil {
+		return
+	}
+	raw, err := ocspCacheStore.Load()
 	if err != nil {
-		glog.V(2).Infof("failed to read OCSP cache file. %v. ignored.\n", err)
+		glog.V(2).Infof("failed to read OCSP cache. %v. ignored.\n", err)
+	}
+	if len(raw) == 0 {
+		return
 	}
 	err = json.Unmarshal(raw, &ocspResponseCache)
 	if err != nil {
-		glog.V(2).Infof("failed to read OCSP cache file. %v. ignored\n", err)
+		glog.V(2).Infof("failed to read OCSP cache. %v. ignored\n", err)
+	}
+}
+
+// writeOCSPCache writes the OCSP Response cache to the store. This is called if all revocation status is success.
+func writeOCSPCache() {
+	ocspResponseCacheLock.Lock()
+	store := ocspCacheStore
+	j, err := json.Marshal(ocspResponseCache)
+	ocspResponseCacheLock.Unlock()
+	if store == nil {
+		return
+	}
+	if err != nil {
+		glog.V(2).Info("failed to convert OCSP Response cache to JSON. ignored.")
+		return
 	}
+	if err = store.Save(j); err != nil {
+		glog.V(2).Infof("failed to write OCSP Response cache. err: %v. ignored.\n", err)
+	}
+}
+
+// fileOCSPCacheStore stores the OCSP Response cache in a file.
+type fileOCSPCacheStore struct {
+	fileName string
 }
 
-// writeOCSPCacheFile writes a OCSP Response cache file. This is called if all revocation status is success.
-// lock file is used to mitigate race condition with other process.
-func writeOCSPCacheFile() {
-	glog.V(2).Infof("writing OCSP Response cache file. %v\n", cacheFileName)
-	cacheLockFileName := cacheFileName + ".lck"
+func (s *fileOCSPCacheStore) Load() ([]byte, error) {
+	glog.V(2).Infof("reading OCSP Response cache file. %v\n", s.fileName)
+	return ioutil.ReadFile(s.fileName)
+}
+
+// Save writes the cache file. lock file is used to mitigate race condition with other process.
+func (s *fileOCSPCacheStore) Save(b []byte) error {
+	glog.V(2).Infof("writing OCSP Response cache file. %v\n", s.fileName)
+	cacheLockFileName := s.fileName + ".lck"
 	statinfo, err := os.Stat(cacheLockFileName)
 	switch {
 	case os.IsNotExist(err):
 		os.OpenFile(cacheLockFileName, os.O_RDONLY|os.O_CREATE, 0644)
 	case err != nil:
-		glog.V(2).Infof("failed to write OCSP response cache file. file: %v, err: %v. ignored.\n", cacheFileName, err)
-		return
+		return err
 	default:
 		if time.Since(statinfo.ModTime()) < time.Hour {
-			glog.V(2).Infof("other process locks the cache file. %v. ignored.\n", cacheFileName)
-			return
+			glog.V(2).Infof("other process locks the cache file. %v. ignored.\n", s.fileName)
+			return nil
 		}
 		err := os.Remove(cacheLockFileName)
 		if err != nil {
-			glog.V(2).Infof("failed to delete lock file. file: %v, err: %v. ignored.\n", cacheLockFileName, err)
-			return
+			return err
 		}
 		os.OpenFile(cacheLockFileName, os.O_RDONLY|os.O_CREATE, 0644)
 	}
 	defer os.Remove(cacheLockFileName)
+	return ioutil.WriteFile(s.fileName, b, 0644)
+}
+
+// downloadOCSPCacheServer merges the OCSP responses of the cache server into the cache, so that the OCSP responders
+// aren't queried for the certificates of Snowflake and the cloud storages. The cache server is downloaded at most
+// once in ocspCacheServerInterval via transport, and a failure is ignored.
+func downloadOCSPCacheServer(transport http.RoundTripper) {
+	ocspCacheServerLock.Lock()
+	defer ocspCacheServerLock.Unlock()
+	if !ocspCacheServerEnabled || time.Since(ocspCacheServerDownloaded) < ocspCacheServerInterval {
+		return
+	}
+	ocspCacheServerDownloaded = time.Now()
+	glog.V(2).Infof("downloading OCSP response cache from the server. %v\n", ocspCacheServerURL)
+	client := &http.Client{
+		Timeout:   retryOCSPHTTPTimeout,
+		Transport: transport,
+	}
+	res, err := client.Get(ocspCacheServerURL)
+	if err != nil {
+		glog.V(2).Infof("failed to download OCSP response cache from the server. err: %v. ignored.\n", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		glog.V(2).Infof("failed to download OCSP response cache from the server. HTTP: %v. ignored.\n", res.StatusCode)
+		return
+	}
+	var cache map[string][]interface{}
+	if err = json.NewDecoder(res.Body).Decode(&cache); err != nil {
+		glog.V(2).Infof("failed to parse OCSP response cache from the server. err: %v. ignored.\n", err)
+		return
+	}
 	ocspResponseCacheLock.Lock()
 	defer ocspResponseCacheLock.Unlock()
-	j, err := json.Marshal(ocspResponseCache)
-	if err != nil {
-		glog.V(2).Info("failed to convert OCSP Response cache to JSON. ignored.")
+	for k, v := range cache {
+		if len(v) != 2 {
+			continue
+		}
+		if cur, ok := ocspResponseCache[k]; ok && ocspCacheEntryTime(cur) >= ocspCacheEntryTime(v) {
+			continue
+		}
+		ocspResponseCache[k] = v
+	}
+}
+
+// ocspCacheEntryTime returns the epoch time of the cache entry, or 0 if it is invalid.
+func ocspCacheEntryTime(v []interface{}) float64 {
+	if len(v) != 2 {
+		return 0
+	}
+	epoch, _ := v[0].(float64)
+	return epoch
+}
+
+// ocspCacheServerURLForHost returns the cache server of the Snowflake domain of the host, e.g.,
+// ocsp.snowflakecomputing.cn in China. A private connectivity host has its own cache server, ocsp.<host>, because
+// the public one may not be reachable. The default cache server is returned for a custom DNS name.
+func ocspCacheServerURLForHost(host string) string {
+	host = strings.ToLower(host)
+	domain := hostDomain(host)
+	switch {
+	case domain == "":
+		return defaultOCSPCacheServerURL
+	case isPrivateLinkHost(host):
+		return "http://ocsp." + host + "/" + cacheFileBaseName + ".json"
+	default:
+		return "http://ocsp" + domain + "/" + cacheFileBaseName + ".json"
+	}
+}
+
+// setOCSPCacheServerHost sets the cache server of the host unless SF_OCSP_RESPONSE_CACHE_SERVER_URL is set. The
+// cache server is shared by the connections, so the host of the last connection opened is used.
+func setOCSPCacheServerHost(host string) {
+	ocspCacheServerLock.Lock()
+	defer ocspCacheServerLock.Unlock()
+	if ocspCacheServerURLFromEnv {
 		return
 	}
-	err = ioutil.WriteFile(cacheFileName, j, 0644)
-	if err != nil {
-		glog.V(2).Infof("failed to write OCSP Response cache. err: %v. ignored.\n", err)
+	if u := ocspCacheServerURLForHost(host); u != ocspCacheServerURL {
+		glog.V(2).Infof("OCSP response cache server: %v", u)
+		ocspCacheServerURL = u
+		ocspCacheServerDownloaded = time.Time{}
+	}
+}
+
+// readOCSPCacheServerEnv reads the cache server settings from the environment variables.
+func readOCSPCacheServerEnv() {
+	ocspCacheServerURL = defaultOCSPCacheServerURL
+	ocspCacheServerURLFromEnv = false
+	if u := os.Getenv("SF_OCSP_RESPONSE_CACHE_SERVER_URL"); u != "" {
+		ocspCacheServerURL = u
+		ocspCacheServerURLFromEnv = true
+	}
+	ocspCacheServerEnabled = true
+	if v, err := strconv.ParseBool(os.Getenv("SF_OCSP_RESPONSE_CACHE_SERVER_ENABLED")); err == nil {
+		ocspCacheServerEnabled = v
 	}
 }
 
@@ -566,8 +775,14 @@ func createOCSPCacheDir() {
 
 func init() {
 	readCACerts()
-	createOCSPCacheDir()
-	readOCSPCacheFile()
+	ocspResponseCacheLock = &sync.RWMutex{}
+	if hasFileSystem {
+		createOCSPCacheDir()
+		cacheFileName = filepath.Join(cacheDir, cacheFileBaseName)
+		ocspCacheStore = &fileOCSPCacheStore{fileName: cacheFileName}
+	}
+	readOCSPCache()
+	readOCSPCacheServerEnv()
 }
 
 // snowflakeInsecureTransport is the default tranport object that doesn't do certificate revocation check.
@@ -575,10 +790,13 @@ var snowflakeInsecureTransport = &http.Transport{
 	MaxIdleConns:    10,
 	IdleConnTimeout: 30 * time.Minute,
 	Proxy:           http.ProxyFromEnvironment,
+	DialContext:     dialContext,
+	// HTTP/2 is enabled only by the http2 parameter like SnowflakeTransport.
+	TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
 }
 
-// SnowflakeTransport includes the certificate revocation check with OCSP in parallel. By default, the driver uses
-// this transport object.
+// SnowflakeTransport includes the certificate revocation check with OCSP in parallel in fail-open mode. By default,
+// the driver uses this transport object.
 var SnowflakeTransport = &http.Transport{
 	TLSClientConfig: &tls.Config{
 		RootCAs:               certPool,
@@ -587,9 +805,22 @@ var SnowflakeTransport = &http.Transport{
 	MaxIdleConns:    10,
 	IdleConnTimeout: 30 * time.Minute,
 	Proxy:           http.ProxyFromEnvironment,
+	DialContext:     dialContext,
+}
+
+// snowflakeTransportFailClosed includes the certificate revocation check with OCSP in parallel in fail-closed mode.
+var snowflakeTransportFailClosed = &http.Transport{
+	TLSClientConfig: &tls.Config{
+		RootCAs:               certPool,
+		VerifyPeerCertificate: verifyPeerCertificateParallelFailClosed,
+	},
+	MaxIdleConns:    10,
+	IdleConnTimeout: 30 * time.Minute,
+	Proxy:           http.ProxyFromEnvironment,
+	DialContext:     dialContext,
 }
 
-// SnowflakeTransportSerial includes the certificate revocation check with OCSP in serial.
+// SnowflakeTransportSerial includes the certificate revocation check with OCSP in serial in fail-closed mode.
 var SnowflakeTransportSerial = &http.Transport{
 	TLSClientConfig: &tls.Config{
 		RootCAs:               certPool,
@@ -598,6 +829,7 @@ var SnowflakeTransportSerial = &http.Transport{
 	MaxIdleConns:    10,
 	IdleConnTimeout: 30 * time.Minute,
 	Proxy:           http.ProxyFromEnvironment,
+	DialContext:     dialContext,
 }
 
 // SnowflakeTransportTest includes the certificate revocation check in parallel
@@ -11,7 +11,9 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -204,6 +206,43 @@ func TestUnitCheckOCSPResponseCache(t *testing.T) {
 	}
 }
 
+type memoryOCSPCacheStore struct {
+	b []byte
+}
+
+func (s *memoryOCSPCacheStore) Load() ([]byte, error) {
+	return s.b, nil
+}
+
+func (s *memoryOCSPCacheStore) Save(b []byte) error {
+	s.b = b
+	return nil
+}
+
+func TestUnitOCSPCacheStore(t *testing.T) {
+	ocspResponseCacheLock.RLock()
+	orig := ocspCacheStore
+	ocspResponseCacheLock.RUnlock()
+	defer SetOCSPCacheStore(orig)
+
+	store := &memoryOCSPCacheStore{b: []byte(`{"RFVNTVlfS0VZ":[1595054952,"DUMMY_VALUE"]}`)}
+	SetOCSPCacheStore(store)
+	if _, ok := ocspResponseCache["RFVNTVlfS0VZ"]; !ok {
+		t.Fatalf("failed to load the OCSP response cache from the store. cache: %v", ocspResponseCache)
+	}
+	ocspResponseCache["QU5PVEhFUl9LRVk="] = []interface{}{float64(1595054952), "DUMMY_VALUE"}
+	writeOCSPCache()
+	if !bytes.Contains(store.b, []byte("QU5PVEhFUl9LRVk=")) {
+		t.Errorf("failed to save the OCSP response cache to the store. saved: %v", string(store.b))
+	}
+
+	SetOCSPCacheStore(nil)
+	if len(ocspResponseCache) != 0 {
+		t.Errorf("should start with an empty cache without a store. cache: %v", ocspResponseCache)
+	}
+	writeOCSPCache()
+}
+
 func TestUnitValidateOCSP(t *testing.T) {
 	subject := &x509.Certificate{}
 	ocspRes := &ocsp.Response{}
@@ -226,6 +265,134 @@ func TestUnitValidateOCSP(t *testing.T) {
 	}
 }
 
+func TestUnitVerifyPeerCertificateFailOpen(t *testing.T) {
+	root := &x509.Certificate{IsCA: true, RawIssuer: []byte("root"), RawSubject: []byte("root")}
+	testcases := []struct {
+		status   *ocspStatus
+		failOpen bool
+		err      bool
+	}{
+		{status: &ocspStatus{code: ocspSuccess}, failOpen: true, err: false},
+		{status: &ocspStatus{code: ocspSuccess}, failOpen: false, err: false},
+		{status: &ocspStatus{code: ocspFailedSubmit, err: fmt.Errorf("timeout")}, failOpen: true, err: false},
+		{status: &ocspStatus{code: ocspFailedSubmit, err: fmt.Errorf("timeout")}, failOpen: false, err: true},
+		{status: &ocspStatus{code: ocspNoServer, err: fmt.Errorf("no server")}, failOpen: true, err: false},
+		{status: &ocspStatus{code: ocspNoServer, err: fmt.Errorf("no server")}, failOpen: false, err: true},
+		{status: &ocspStatus{code: ocspRevokedOrUnknown, err: fmt.Errorf("revoked")}, failOpen: true, err: true},
+		{status: &ocspStatus{code: ocspRevokedOrUnknown, err: fmt.Errorf("revoked")}, failOpen: false, err: true},
+	}
+	for _, tc := range testcases {
+		callback := func(wg *sync.WaitGroup, chain []*x509.Certificate) []*ocspStatus {
+			wg.Done()
+			return []*ocspStatus{tc.status}
+		}
+		err := verifyPeerCertificate(callback, [][]*x509.Certificate{{{}, root}}, tc.failOpen)
+		if (err != nil) != tc.err {
+			t.Errorf("failed to verify. code: %v, failOpen: %v, err: %v", tc.status.code, tc.failOpen, err)
+		}
+	}
+}
+
+func TestUnitDownloadOCSPCacheServer(t *testing.T) {
+	var cnt int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnt++
+		w.Write([]byte(`{"U0VSVkVSX0tFWQ==":[1595054952,"SERVER_VALUE"],"T0xEX0tFWQ==":[1395054952,"SERVER_VALUE"],"QkFEX0tFWQ==":[1]}`))
+	}))
+	defer ts.Close()
+	origURL, origEnabled := ocspCacheServerURL, ocspCacheServerEnabled
+	defer func() {
+		ocspCacheServerURL, ocspCacheServerEnabled = origURL, origEnabled
+		ocspCacheServerDownloaded = time.Time{}
+	}()
+	ocspCacheServerURL, ocspCacheServerEnabled = ts.URL, true
+	ocspCacheServerDownloaded = time.Time{}
+	ocspResponseCacheLock.Lock()
+	ocspResponseCache["T0xEX0tFWQ=="] = []interface{}{float64(1595054952), "LOCAL_VALUE"}
+	ocspResponseCacheLock.Unlock()
+
+	downloadOCSPCacheServer(snowflakeInsecureTransport)
+	downloadOCSPCacheServer(snowflakeInsecureTransport)
+	if cnt != 1 {
+		t.Errorf("failed to download the cache server once. downloads: %v", cnt)
+	}
+	ocspResponseCacheLock.Lock()
+	defer ocspResponseCacheLock.Unlock()
+	if v := ocspResponseCache["U0VSVkVSX0tFWQ=="]; len(v) != 2 || v[1] != "SERVER_VALUE" {
+		t.Errorf("failed to merge the response of the cache server. got: %v", v)
+	}
+	if v := ocspResponseCache["T0xEX0tFWQ=="]; len(v) != 2 || v[1] != "LOCAL_VALUE" {
+		t.Errorf("failed to keep the newer response in the cache. got: %v", v)
+	}
+	if _, ok := ocspResponseCache["QkFEX0tFWQ=="]; ok {
+		t.Errorf("failed to skip the invalid response of the cache server")
+	}
+	delete(ocspResponseCache, "U0VSVkVSX0tFWQ==")
+	delete(ocspResponseCache, "T0xEX0tFWQ==")
+}
+
+func TestUnitReadOCSPCacheServerEnv(t *testing.T) {
+	origURL, origEnabled := ocspCacheServerURL, ocspCacheServerEnabled
+	defer func() {
+		ocspCacheServerURL, ocspCacheServerEnabled = origURL, origEnabled
+	}()
+	testcases := []struct {
+		url     string
+		enabled string
+		expURL  string
+		expOn   bool
+	}{
+		{url: "", enabled: "", expURL: defaultOCSPCacheServerURL, expOn: true},
+		{url: "http://localhost:8080/cache.json", enabled: "", expURL: "http://localhost:8080/cache.json", expOn: true},
+		{url: "", enabled: "false", expURL: defaultOCSPCacheServerURL, expOn: false},
+		{url: "", enabled: "invalid", expURL: defaultOCSPCacheServerURL, expOn: true},
+	}
+	for _, tc := range testcases {
+		os.Setenv("SF_OCSP_RESPONSE_CACHE_SERVER_URL", tc.url)
+		os.Setenv("SF_OCSP_RESPONSE_CACHE_SERVER_ENABLED", tc.enabled)
+		readOCSPCacheServerEnv()
+		if ocspCacheServerURL != tc.expURL || ocspCacheServerEnabled != tc.expOn {
+			t.Errorf("failed to read the cache server settings. expected: %v, %v, got: %v, %v",
+				tc.expURL, tc.expOn, ocspCacheServerURL, ocspCacheServerEnabled)
+		}
+	}
+	os.Unsetenv("SF_OCSP_RESPONSE_CACHE_SERVER_URL")
+	os.Unsetenv("SF_OCSP_RESPONSE_CACHE_SERVER_ENABLED")
+}
+
+func TestUnitOCSPCacheServerURLForHost(t *testing.T) {
+	testcases := []struct {
+		host string
+		url  string
+	}{
+		{host: "xy12345.snowflakecomputing.com", url: defaultOCSPCacheServerURL},
+		{host: "xy12345.cn-northwest-1.snowflakecomputing.cn", url: "http://ocsp.snowflakecomputing.cn/ocsp_response_cache.json"},
+		{host: "xy12345.us-east-1.privatelink.snowflakecomputing.com",
+			url: "http://ocsp.xy12345.us-east-1.privatelink.snowflakecomputing.com/ocsp_response_cache.json"},
+		{host: "snowflake.internal.example.com", url: defaultOCSPCacheServerURL},
+	}
+	for _, tc := range testcases {
+		if u := ocspCacheServerURLForHost(tc.host); u != tc.url {
+			t.Errorf("failed to get the cache server. host: %v, expected: %v, got: %v", tc.host, tc.url, u)
+		}
+	}
+
+	origURL, origFromEnv := ocspCacheServerURL, ocspCacheServerURLFromEnv
+	defer func() {
+		ocspCacheServerURL, ocspCacheServerURLFromEnv = origURL, origFromEnv
+	}()
+	ocspCacheServerURLFromEnv = false
+	setOCSPCacheServerHost("xy12345.cn-northwest-1.snowflakecomputing.cn")
+	if ocspCacheServerURL != "http://ocsp.snowflakecomputing.cn/ocsp_response_cache.json" {
+		t.Errorf("failed to set the cache server of the host. got: %v", ocspCacheServerURL)
+	}
+	ocspCacheServerURL, ocspCacheServerURLFromEnv = "http://localhost:8080/cache.json", true
+	setOCSPCacheServerHost("xy12345.us-east-1.privatelink.snowflakecomputing.com")
+	if ocspCacheServerURL != "http://localhost:8080/cache.json" {
+		t.Errorf("should not have overridden SF_OCSP_RESPONSE_CACHE_SERVER_URL. got: %v", ocspCacheServerURL)
+	}
+}
+
 func TestUnitEncodeCertID(t *testing.T) {
 	var st *ocspStatus
 	_, st = encodeCertID([]byte{0x1, 0x2})
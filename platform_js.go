@@ -0,0 +1,17 @@
+// +build js,wasm
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"net/http"
+)
+
+// hasFileSystem is false in the browsers and edge runtimes, so the OCSP response cache is not persisted and
+// no credentials are read from files by default.
+const hasFileSystem = false
+
+// fetchTransport sends the requests with the Fetch API of the runtime, which also verifies the certificates.
+// It must not set Dial or DialContext, or net/http falls back to the sockets unavailable in WebAssembly.
+var fetchTransport http.RoundTripper = &http.Transport{}
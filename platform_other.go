@@ -0,0 +1,14 @@
+// +build !js
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"net/http"
+)
+
+const hasFileSystem = true
+
+// fetchTransport is used only in WebAssembly.
+var fetchTransport http.RoundTripper
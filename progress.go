@@ -0,0 +1,143 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const progressKey contextKey = "progress"
+
+// Statuses of QueryProgress.
+const (
+	QueryStatusRunning = "RUNNING"
+	QueryStatusSuccess = "SUCCESS"
+	QueryStatusFailed  = "FAILED_WITH_ERROR"
+)
+
+// Progress is the callbacks of the progress of the long-running operations, so that CLIs can render progress bars.
+// The nil callbacks are ignored. The files of PUT and GET, and the chunks of a result, are transferred in parallel,
+// so the callbacks must be safe for concurrent use. Set it in Config.Progress for all statements of the
+// connections, or by WithProgress for the statements run with a context.
+type Progress struct {
+	Transfer func(TransferProgress) // a file, or a part of a file uploaded to S3 in parts, is transferred by PUT or GET
+	Chunks   func(ChunkProgress)    // a chunk of a large result set is downloaded
+	Query    func(QueryProgress)    // the status of a query is polled while the query runs
+}
+
+// TransferProgress is the progress of a file transferred by PUT or GET.
+type TransferProgress struct {
+	File     string // name of the file in the stage
+	Download bool   // GET instead of PUT
+	Bytes    int64  // bytes transferred so far
+	Total    int64  // bytes of the file in the stage, compressed and encrypted if required. 0 if unknown yet.
+}
+
+// ChunkProgress is the progress of the chunks of a result downloaded ahead of the rows read.
+type ChunkProgress struct {
+	QueryID string
+	Chunks  int // chunks downloaded so far
+	Total   int // chunks of the result, not including the rows returned with the query response
+	Rows    int // rows of the chunk downloaded
+}
+
+// QueryProgress is the status of a query polled while the query runs longer than the query response waits.
+type QueryProgress struct {
+	QueryID string
+	Status  string        // QueryStatusRunning until the query completes
+	Polls   int           // status requests sent so far
+	Elapsed time.Duration // since the query was submitted
+}
+
+// WithProgress returns a context that calls the callbacks of p with the progress of the statements run with it,
+// instead of the callbacks of the context and Config.Progress:
+//
+//	ctx := sf.WithProgress(context.Background(), sf.Progress{
+//		Transfer: func(p sf.TransferProgress) {
+//			log.Printf("%v: %v/%v bytes", p.File, p.Bytes, p.Total)
+//		},
+//		Query: func(p sf.QueryProgress) {
+//			log.Printf("%v: %v for %v", p.QueryID, p.Status, p.Elapsed)
+//		},
+//	})
+//	_, err = db.ExecContext(ctx, "PUT file:///tmp/data/large.csv @mystage")
+func WithProgress(ctx context.Context, p Progress) context.Context {
+	return context.WithValue(ctx, progressKey, mergeProgress(p, ctx.Value(progressKey)))
+}
+
+// WithTransferProgress returns a context that calls f with the progress of the files transferred by the PUT or GET
+// run with it. It's a shorthand of WithProgress with Progress.Transfer.
+func WithTransferProgress(ctx context.Context, f func(TransferProgress)) context.Context {
+	return WithProgress(ctx, Progress{Transfer: f})
+}
+
+// withConfigProgress returns a context with the callbacks of the config not given by the context.
+func withConfigProgress(ctx context.Context, cfg *Config) context.Context {
+	if cfg == nil || cfg.Progress == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey, mergeProgress(progressOf(ctx), *cfg.Progress))
+}
+
+// mergeProgress returns the callbacks of p, or of fallback, which is a Progress or nil, if missing in p.
+func mergeProgress(p Progress, fallback interface{}) Progress {
+	if f, ok := fallback.(Progress); ok {
+		if p.Transfer == nil {
+			p.Transfer = f.Transfer
+		}
+		if p.Chunks == nil {
+			p.Chunks = f.Chunks
+		}
+		if p.Query == nil {
+			p.Query = f.Query
+		}
+	}
+	return p
+}
+
+func progressOf(ctx context.Context) Progress {
+	p, _ := ctx.Value(progressKey).(Progress)
+	return p
+}
+
+func reportTransferProgress(ctx context.Context, p TransferProgress) {
+	if f := progressOf(ctx).Transfer; f != nil {
+		f(p)
+	}
+}
+
+func reportChunkProgress(ctx context.Context, p ChunkProgress) {
+	if f := progressOf(ctx).Chunks; f != nil {
+		f(p)
+	}
+}
+
+func reportQueryProgress(ctx context.Context, p QueryProgress) {
+	if f := progressOf(ctx).Query; f != nil {
+		f(p)
+	}
+}
+
+// downloadProgressThreshold is the bytes of a file downloaded between the progress reported.
+const downloadProgressThreshold = 1 << 20
+
+// progressWriter reports the progress of a file downloaded as the bytes are written.
+type progressWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	file     string
+	written  int64
+	reported int64
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.written += int64(n)
+	if pw.written-pw.reported >= downloadProgressThreshold {
+		pw.reported = pw.written
+		reportTransferProgress(pw.ctx, TransferProgress{File: pw.file, Download: true, Bytes: pw.written})
+	}
+	return n, err
+}
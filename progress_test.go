@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnitWithProgress(t *testing.T) {
+	var called []string
+	cfg := &Config{Progress: &Progress{
+		Transfer: func(TransferProgress) { called = append(called, "config transfer") },
+		Query:    func(QueryProgress) { called = append(called, "config query") },
+	}}
+	ctx := WithProgress(context.Background(), Progress{
+		Chunks: func(ChunkProgress) { called = append(called, "context chunks") },
+		Query:  func(QueryProgress) { called = append(called, "context query") },
+	})
+	ctx = WithTransferProgress(ctx, func(TransferProgress) { called = append(called, "context transfer") })
+	ctx = withConfigProgress(ctx, cfg)
+	reportTransferProgress(ctx, TransferProgress{})
+	reportChunkProgress(ctx, ChunkProgress{})
+	reportQueryProgress(ctx, QueryProgress{})
+	expected := "[context transfer context chunks context query]"
+	if got := fmt.Sprint(called); got != expected {
+		t.Errorf("failed to merge the callbacks. expected: %v, got: %v", expected, got)
+	}
+
+	called = nil
+	ctx = withConfigProgress(context.Background(), cfg)
+	reportTransferProgress(ctx, TransferProgress{})
+	reportChunkProgress(ctx, ChunkProgress{})
+	reportQueryProgress(ctx, QueryProgress{})
+	expected = "[config transfer config query]"
+	if got := fmt.Sprint(called); got != expected {
+		t.Errorf("failed to use the callbacks of the config. expected: %v, got: %v", expected, got)
+	}
+	// no callbacks
+	reportQueryProgress(withConfigProgress(context.Background(), &Config{}), QueryProgress{})
+}
+
+func TestUnitQueryProgress(t *testing.T) {
+	response := func(code string, success bool) *http.Response {
+		b, err := json.Marshal(&execResponse{
+			Data:    execResponseData{QueryID: "01a2", GetResultURL: "/queries/01a2/result"},
+			Code:    code,
+			Success: success,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(b))}
+	}
+	testcases := []struct {
+		success  bool
+		expected string
+	}{
+		{success: true, expected: "[RUNNING/0 RUNNING/1 SUCCESS/2]"},
+		{success: false, expected: "[RUNNING/0 RUNNING/1 FAILED_WITH_ERROR/2]"},
+	}
+	for _, test := range testcases {
+		gets := 0
+		sr := &snowflakeRestful{
+			Token: "token",
+			FuncPost: func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error) {
+				return response(queryInProgressAsyncCode, true), nil
+			},
+			FuncGet: func(context.Context, *snowflakeRestful, string, map[string]string, time.Duration) (*http.Response, error) {
+				if gets++; gets == 1 {
+					return response(queryInProgressCode, true), nil
+				}
+				return response("", test.success), nil
+			},
+		}
+		var events []string
+		ctx := WithProgress(context.Background(), Progress{Query: func(p QueryProgress) {
+			if p.QueryID != "01a2" || p.Elapsed < 0 {
+				t.Errorf("failed to report the query. got: %+v", p)
+			}
+			events = append(events, fmt.Sprintf("%v/%v", p.Status, p.Polls))
+		}})
+		if _, err := postRestfulQueryHelper(ctx, sr, &url.Values{}, make(map[string]string), nil, 0, "abcdefg"); err != nil {
+			t.Fatalf("failed to post the query. err: %v", err)
+		}
+		if got := fmt.Sprint(events); got != test.expected {
+			t.Errorf("failed to report the query status. expected: %v, got: %v", test.expected, got)
+		}
+	}
+}
+
+func TestUnitChunkProgress(t *testing.T) {
+	numChunks := 3
+	cm := make([]execResponseChunk, numChunks)
+	for i := range cm {
+		cm[i] = execResponseChunk{URL: fmt.Sprintf("dummyURL%v", i+1), RowCount: 2}
+	}
+	var (
+		mu     sync.Mutex
+		events []ChunkProgress
+	)
+	ctx := WithProgress(context.Background(), Progress{Chunks: func(p ChunkProgress) {
+		mu.Lock()
+		events = append(events, p)
+		mu.Unlock()
+	}})
+	scd := &snowflakeChunkDownloader{
+		ctx:        ctx,
+		queryID:    "01a2",
+		ChunkMetas: cm,
+		FuncGet: func(context.Context, *snowflakeChunkDownloader, string, map[string]string, time.Duration) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`["1","a"],["2","b"]`))}, nil
+		},
+	}
+	scd.ChunksMutex = &sync.Mutex{}
+	scd.Chunks = make(map[int]*chunkRows)
+	scd.ChunksError = make(chan *chunkError, numChunks)
+	scd.ChunksReady = make(chan int, numChunks)
+	var wg sync.WaitGroup
+	for i := 0; i < numChunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			downloadChunkHelper(ctx, scd, i)
+		}(i)
+	}
+	wg.Wait()
+	if len(events) != numChunks {
+		t.Fatalf("failed to report the chunks. got: %v", events)
+	}
+	seen := make(map[int]bool)
+	for _, p := range events {
+		if p.QueryID != "01a2" || p.Total != numChunks || p.Rows != 2 || seen[p.Chunks] {
+			t.Errorf("failed to report the chunk. got: %+v", p)
+		}
+		seen[p.Chunks] = true
+	}
+}
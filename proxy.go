@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// proxyTransportKey identifies the builtin transports with a proxy. The connections with the same proxy settings
+// share the transport and its idle connections.
+type proxyTransportKey struct {
+	proxy         string // proxy URL including the credentials
+	nonProxyHosts string
+	insecureMode  bool
+	failClosed    bool
+	http2         bool
+}
+
+var (
+	proxyTransports     = make(map[proxyTransportKey]*http.Transport)
+	proxyTransportsLock sync.Mutex
+)
+
+// getProxyTransport returns the builtin transport with the proxy of the config. The OCSP requests of the
+// certificate revocation check are also sent via the proxy.
+func getProxyTransport(cfg *Config) http.RoundTripper {
+	proxy := proxyURL(cfg)
+	key := proxyTransportKey{
+		proxy:         proxy.String(),
+		nonProxyHosts: cfg.NonProxyHosts,
+		insecureMode:  cfg.InsecureMode,
+		failClosed:    cfg.OCSPFailOpen == OCSPFailOpenFalse,
+		http2:         cfg.EnableHTTP2,
+	}
+	proxyTransportsLock.Lock()
+	defer proxyTransportsLock.Unlock()
+	if t, ok := proxyTransports[key]; ok {
+		return t
+	}
+	proxyFunc := newProxyFunc(proxy, cfg.NonProxyHosts)
+	var tlsConfig *tls.Config
+	if !key.insecureMode {
+		ocspTransport := newTransport(nil)
+		ocspTransport.Proxy = proxyFunc
+		tlsConfig = &tls.Config{
+			RootCAs:               certPool,
+			VerifyPeerCertificate: newVerifyPeerCertificate(ocspTransport, !key.failClosed),
+		}
+	}
+	t := newTransport(tlsConfig)
+	t.Proxy = proxyFunc
+	if key.http2 && !configureHTTP2(t) {
+		glog.V(1).Info("HTTP/2 requires Go 1.13 or later. HTTP/1.1 is used.")
+	}
+	proxyTransports[key] = t
+	return t
+}
+
+// proxyURL returns the URL of the proxy of the config with the credentials.
+func proxyURL(cfg *Config) *url.URL {
+	u := &url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(cfg.ProxyHost, strconv.Itoa(cfg.ProxyPort)),
+	}
+	if cfg.ProxyUser != "" {
+		u.User = url.UserPassword(cfg.ProxyUser, cfg.ProxyPassword)
+	}
+	return u
+}
+
+// newProxyFunc returns the Proxy function of http.Transport that sends the requests via proxy except the hosts
+// matching nonProxyHosts.
+func newProxyFunc(proxy *url.URL, nonProxyHosts string) func(*http.Request) (*url.URL, error) {
+	patterns := parseNonProxyHosts(nonProxyHosts)
+	return func(req *http.Request) (*url.URL, error) {
+		if isNonProxyHost(req.URL.Hostname(), patterns) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// parseNonProxyHosts splits the host patterns separated by | or comma, e.g., localhost|*.internal.example.com.
+func parseNonProxyHosts(nonProxyHosts string) []string {
+	var patterns []string
+	for _, p := range strings.FieldsFunc(nonProxyHosts, func(r rune) bool { return r == '|' || r == ',' }) {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// isNonProxyHost returns true if the host matches any of the patterns. * matches any characters, and a
+// pattern starting with a dot matches the subdomains.
+func isNonProxyHost(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, p := range patterns {
+		if p == "*" || p == host {
+			return true
+		}
+		if strings.HasPrefix(p, ".") && strings.HasSuffix(host, p) {
+			return true
+		}
+		if ok, _ := path.Match(p, host); ok {
+			return true
+		}
+	}
+	return false
+}
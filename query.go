@@ -3,12 +3,13 @@
 package gosnowflake
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type execBindParameter struct {
-	Type  string  `json:"type"`
-	Value *string `json:"value"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"` // *string, or []*string of an array binding
 }
 
 type execRequest struct {
@@ -18,6 +19,11 @@ type execRequest struct {
 	IsInternal bool                         `json:"isInternal"`
 	Parameters map[string]string            `json:"parameters,omitempty"`
 	Bindings   map[string]execBindParameter `json:"bindings,omitempty"`
+	BindStage  string                       `json:"bindStage,omitempty"` // stage location of the array bindings
+
+	DescribeOnly bool `json:"describeOnly,omitempty"` // describe the result and binds without running the statement
+
+	QueryContext *queryContextRequest `json:"queryContextDTO,omitempty"` // query context of the session kept by the client
 }
 type execResponseRowType struct {
 	Name       string `json:"name"`
@@ -30,8 +36,9 @@ type execResponseRowType struct {
 }
 
 type execResponseChunk struct {
-	URL      string `json:"url"`
-	RowCount int    `json:"rowCount"`
+	URL              string `json:"url"`
+	RowCount         int    `json:"rowCount"`
+	UncompressedSize int64  `json:"uncompressedSize"`
 }
 
 // make all data field optional
@@ -55,11 +62,79 @@ type execResponseData struct {
 	Chunks             []execResponseChunk   `json:"chunks,omitempty"`
 	Qrmk               string                `json:"qrmk,omitempty"`
 	ChunkHeaders       map[string]string     `json:"chunkHeaders,omitempty"`
+	ResultIDs          string                `json:"resultIds,omitempty"`   // query IDs of a multi-statement query
+	ResultTypes        string                `json:"resultTypes,omitempty"` // statement type IDs of a multi-statement query
+	QueryResultFormat  string                `json:"queryResultFormat,omitempty"`
+	RowSetBase64       string                `json:"rowsetBase64,omitempty"`
+	MetaDataOfBinds    []execResponseRowType `json:"metaDataOfBinds,omitempty"` // types of the binds of a describe-only request
+	QueryContext       *queryContext         `json:"queryContext,omitempty"`    // query context of the session to send back
 
 	// ping pong response data
 	GetResultURL         string        `json:"getResultUrl,omitempty"`
 	ProgressDesc         string        `json:"progressDesc,omitempty"`
 	QueryAbortsAfterSecs time.Duration `json:"queryAbortsAfterSecs,omitempty"`
+
+	// PUT/GET response data
+	Command            string                 `json:"command,omitempty"` // UPLOAD or DOWNLOAD
+	SrcLocations       []string               `json:"src_locations,omitempty"`
+	Parallel           int                    `json:"parallel,omitempty"`
+	AutoCompress       bool                   `json:"autoCompress,omitempty"`
+	Overwrite          bool                   `json:"overwrite,omitempty"`
+	SourceCompression  string                 `json:"sourceCompression,omitempty"`
+	StageInfo          *execResponseStageInfo `json:"stageInfo,omitempty"`
+	EncryptionMaterial encryptionMaterialList `json:"encryptionMaterial,omitempty"`
+	PresignedURLs      []string               `json:"presignedUrls,omitempty"`
+	LocalLocation      string                 `json:"localLocation,omitempty"`
+}
+
+// execResponseStageInfo is the stage of PUT and GET.
+type execResponseStageInfo struct {
+	LocationType          string                  `json:"locationType,omitempty"` // S3, AZURE, GCS or LOCAL_FS
+	Location              string                  `json:"location,omitempty"`     // bucket or container, and path
+	Path                  string                  `json:"path,omitempty"`
+	Region                string                  `json:"region,omitempty"`
+	StorageAccount        string                  `json:"storageAccount,omitempty"`
+	IsClientSideEncrypted bool                    `json:"isClientSideEncrypted,omitempty"`
+	Creds                 execResponseCredentials `json:"creds,omitempty"`
+	PresignedURL          string                  `json:"presignedUrl,omitempty"`
+	EndPoint              string                  `json:"endPoint,omitempty"`
+	Ciphers               string                  `json:"ciphers,omitempty"`               // accepted by the stage, e.g., AES_GCM,AES_CBC
+	UseAccelerateEndpoint bool                    `json:"useAccelerateEndpoint,omitempty"` // S3 Transfer Acceleration
+}
+
+// execResponseCredentials is the temporary credentials of the stage.
+type execResponseCredentials struct {
+	AwsKeyID       string `json:"AWS_KEY_ID,omitempty"`
+	AwsSecretKey   string `json:"AWS_SECRET_KEY,omitempty"`
+	AwsToken       string `json:"AWS_TOKEN,omitempty"`
+	AzureSasToken  string `json:"AZURE_SAS_TOKEN,omitempty"`
+	GcsAccessToken string `json:"GCS_ACCESS_TOKEN,omitempty"`
+}
+
+// snowflakeFileEncryption is the key to encrypt the files in the stage with.
+type snowflakeFileEncryption struct {
+	QueryStageMasterKey string `json:"queryStageMasterKey,omitempty"`
+	QueryID             string `json:"queryId,omitempty"`
+	SMKID               int64  `json:"smkId,omitempty"`
+}
+
+// encryptionMaterialList is the encryption material of PUT, or of each file of GET.
+type encryptionMaterialList []*snowflakeFileEncryption
+
+// UnmarshalJSON accepts an object for PUT and an array for GET.
+func (l *encryptionMaterialList) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, (*[]*snowflakeFileEncryption)(l))
+	}
+	var m *snowflakeFileEncryption
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*l = nil
+	if m != nil {
+		*l = encryptionMaterialList{m}
+	}
+	return nil
 }
 
 type execResponse struct {
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	queryContextCacheSizeParam   = "query_context_cache_size"
+	defaultQueryContextCacheSize = 5
+)
+
+// queryContext is the query context of the session returned by the server. The entries are opaque to the client,
+// which sends them back with the next queries, so that any instance of the cloud services serving the queries
+// reads the writes of the session, e.g., to hybrid tables.
+type queryContext struct {
+	Entries []queryContextEntry `json:"entries,omitempty"`
+}
+
+type queryContextEntry struct {
+	ID        int    `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	Priority  int    `json:"priority"`
+	Context   string `json:"context,omitempty"` // base64
+}
+
+// queryContextRequest is the query context sent with a query.
+type queryContextRequest struct {
+	Entries []queryContextRequestEntry `json:"entries"`
+}
+
+type queryContextRequestEntry struct {
+	ID        int                `json:"id"`
+	Timestamp int64              `json:"timestamp"`
+	Priority  int                `json:"priority"`
+	Context   queryContextBase64 `json:"context"`
+}
+
+type queryContextBase64 struct {
+	Base64Data string `json:"base64Data,omitempty"`
+}
+
+// queryContextCache keeps the entries of the query context of a session by priority, the highest priority first.
+// An entry replaces the entry of the same ID if newer, or the entry of the same priority. The entries of the
+// lowest priorities over the size are dropped.
+type queryContextCache struct {
+	entries []queryContextEntry
+}
+
+func (qcc *queryContextCache) merge(qc *queryContext, size int) {
+	if qc == nil {
+		return
+	}
+	for _, e := range qc.Entries {
+		qcc.add(e)
+	}
+	sort.SliceStable(qcc.entries, func(i, j int) bool {
+		return qcc.entries[i].Priority < qcc.entries[j].Priority
+	})
+	if len(qcc.entries) > size {
+		qcc.entries = qcc.entries[:size]
+	}
+}
+
+func (qcc *queryContextCache) add(e queryContextEntry) {
+	for i, old := range qcc.entries {
+		if old.ID == e.ID {
+			if e.Timestamp > old.Timestamp || e.Timestamp == old.Timestamp && e.Priority != old.Priority {
+				qcc.entries[i] = e
+				qcc.removePriority(e.Priority, e.ID)
+			}
+			return
+		}
+	}
+	qcc.removePriority(e.Priority, e.ID)
+	qcc.entries = append(qcc.entries, e)
+}
+
+// removePriority removes the entries of the priority other than the ID.
+func (qcc *queryContextCache) removePriority(priority, id int) {
+	kept := qcc.entries[:0]
+	for _, e := range qcc.entries {
+		if e.Priority != priority || e.ID == id {
+			kept = append(kept, e)
+		}
+	}
+	qcc.entries = kept
+}
+
+// request returns the entries to send with a query, or nil if none.
+func (qcc *queryContextCache) request() *queryContextRequest {
+	if len(qcc.entries) == 0 {
+		return nil
+	}
+	req := &queryContextRequest{Entries: make([]queryContextRequestEntry, len(qcc.entries))}
+	for i, e := range qcc.entries {
+		req.Entries[i] = queryContextRequestEntry{
+			ID:        e.ID,
+			Timestamp: e.Timestamp,
+			Priority:  e.Priority,
+			Context:   queryContextBase64{Base64Data: e.Context},
+		}
+	}
+	return req
+}
+
+// queryContextCacheSize returns the size of the query context cache by the session parameter. sc.mu must be held.
+func (sc *snowflakeConn) queryContextCacheSize() int {
+	for k, v := range sc.cfg.Params {
+		if strings.EqualFold(k, queryContextCacheSizeParam) && v != nil {
+			if n, err := strconv.Atoi(*v); err == nil && n >= 0 {
+				return n
+			}
+		}
+	}
+	return defaultQueryContextCacheSize
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestUnitQueryContextCacheMerge(t *testing.T) {
+	entry := func(id int, ts int64, priority int) queryContextEntry {
+		return queryContextEntry{ID: id, Timestamp: ts, Priority: priority, Context: fmt.Sprintf("c%v-%v", id, ts)}
+	}
+	testcases := []struct {
+		name     string
+		cached   []queryContextEntry
+		merged   []queryContextEntry
+		size     int
+		expected string // ID/timestamp/priority by priority
+	}{
+		{
+			name:     "add",
+			merged:   []queryContextEntry{entry(2, 1, 2), entry(0, 1, 0), entry(1, 1, 1)},
+			size:     5,
+			expected: "[0/1/0 1/1/1 2/1/2]",
+		},
+		{
+			name:     "newer",
+			cached:   []queryContextEntry{entry(0, 1, 0), entry(1, 1, 1)},
+			merged:   []queryContextEntry{entry(1, 2, 1)},
+			size:     5,
+			expected: "[0/1/0 1/2/1]",
+		},
+		{
+			name:     "older",
+			cached:   []queryContextEntry{entry(0, 1, 0), entry(1, 2, 1)},
+			merged:   []queryContextEntry{entry(1, 1, 3)},
+			size:     5,
+			expected: "[0/1/0 1/2/1]",
+		},
+		{
+			name:     "priority changed",
+			cached:   []queryContextEntry{entry(0, 1, 0), entry(1, 1, 1)},
+			merged:   []queryContextEntry{entry(1, 1, 4)},
+			size:     5,
+			expected: "[0/1/0 1/1/4]",
+		},
+		{
+			name:     "same priority",
+			cached:   []queryContextEntry{entry(0, 1, 0), entry(1, 1, 1)},
+			merged:   []queryContextEntry{entry(2, 1, 1)},
+			size:     5,
+			expected: "[0/1/0 2/1/1]",
+		},
+		{
+			name:     "over size",
+			cached:   []queryContextEntry{entry(0, 1, 0), entry(1, 1, 1), entry(3, 1, 3)},
+			merged:   []queryContextEntry{entry(2, 1, 2)},
+			size:     3,
+			expected: "[0/1/0 1/1/1 2/1/2]",
+		},
+		{
+			name:     "disabled by size",
+			merged:   []queryContextEntry{entry(0, 1, 0)},
+			size:     0,
+			expected: "[]",
+		},
+	}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			qcc := &queryContextCache{entries: test.cached}
+			qcc.merge(&queryContext{Entries: test.merged}, test.size)
+			got := make([]string, len(qcc.entries))
+			for i, e := range qcc.entries {
+				got[i] = fmt.Sprintf("%v/%v/%v", e.ID, e.Timestamp, e.Priority)
+				if e.Context != fmt.Sprintf("c%v-%v", e.ID, e.Timestamp) {
+					t.Errorf("failed to keep the context of %v. got: %v", e.ID, e.Context)
+				}
+			}
+			if fmt.Sprint(got) != test.expected {
+				t.Errorf("failed to merge the entries. expected: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestQueryContextExec(t *testing.T) {
+	var sent []string
+	responses := []string{
+		`{"entries":[{"id":0,"timestamp":10,"priority":0,"context":"YQ=="}]}`,
+		`{"entries":[{"id":0,"timestamp":11,"priority":0,"context":"Yg=="},{"id":7,"timestamp":11,"priority":1}]}`,
+		"",
+	}
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req map[string]json.RawMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		sent = append(sent, string(req["queryContextDTO"]))
+		res := &execResponse{Success: len(sent) != 2, Code: "100132", Message: "failed"}
+		if r := responses[len(sent)-1]; r != "" {
+			res.Data.QueryContext = &queryContext{}
+			if err := json.Unmarshal([]byte(r), res.Data.QueryContext); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+	for i := 0; i < 3; i++ {
+		// the failed query updates the query context too
+		sc.ExecContext(context.Background(), "INSERT INTO hybrid_t VALUES (1)", nil)
+	}
+	expected := []string{
+		"",
+		`{"entries":[{"id":0,"timestamp":10,"priority":0,"context":{"base64Data":"YQ=="}}]}`,
+		`{"entries":[{"id":0,"timestamp":11,"priority":0,"context":{"base64Data":"Yg=="}},{"id":7,"timestamp":11,"priority":1,"context":{}}]}`,
+	}
+	if fmt.Sprint(sent) != fmt.Sprint(expected) {
+		t.Errorf("failed to send the query context.\nexpected: %v\n     got: %v", expected, sent)
+	}
+
+	sent = nil
+	sc.cfg.DisableQueryContextCache = true
+	if _, err := sc.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "" {
+		t.Errorf("should have sent no query context. got: %v", sent)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// queryLimiter caps the number of in-flight queries of a connection. The waiting queries are admitted in the
+// order of arrival. A nil queryLimiter doesn't limit.
+type queryLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	waiters *list.List // chan struct{} closed to admit the waiter
+}
+
+// newQueryLimiter returns a limiter of the limit in-flight queries, or nil if limit is not positive.
+func newQueryLimiter(limit int) *queryLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &queryLimiter{limit: limit, waiters: list.New()}
+}
+
+// acquire waits until the query can run, or returns the error of ctx.
+func (l *queryLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.running < l.limit && l.waiters.Len() == 0 {
+		l.running++
+		l.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	e := l.waiters.PushBack(ch)
+	glog.V(2).Infof("waiting for a query slot. running: %v, waiting: %v", l.running, l.waiters.Len())
+	l.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		select {
+		case <-ch:
+			// admitted while canceling. hands the slot over to the next one.
+			l.mu.Unlock()
+			l.release()
+		default:
+			l.waiters.Remove(e)
+			l.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// release ends the query and admits the longest waiting one, if any.
+func (l *queryLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e := l.waiters.Front(); e != nil {
+		l.waiters.Remove(e)
+		close(e.Value.(chan struct{}))
+		return
+	}
+	l.running--
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueryLimiterOrder(t *testing.T) {
+	l := newQueryLimiter(1)
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("failed to acquire. err: %v", err)
+	}
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := l.acquire(context.Background()); err != nil {
+				t.Errorf("failed to acquire. err: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			l.release()
+		}(i)
+		// waits until the goroutine is queued so that the arrival order is deterministic.
+		for {
+			l.mu.Lock()
+			n := l.waiters.Len()
+			l.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	l.release()
+	wg.Wait()
+	for i, v := range order {
+		if i != v {
+			t.Fatalf("failed to admit in the order of arrival. order: %v", order)
+		}
+	}
+	if l.running != 0 {
+		t.Errorf("failed to release all slots. running: %v", l.running)
+	}
+}
+
+func TestQueryLimiterCancel(t *testing.T) {
+	l := newQueryLimiter(1)
+	l.acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("should have timed out. err: %v", err)
+	}
+	if l.waiters.Len() != 0 {
+		t.Errorf("failed to remove the canceled waiter")
+	}
+	l.release()
+	if err := l.acquire(context.Background()); err != nil {
+		t.Errorf("failed to acquire after the cancel. err: %v", err)
+	}
+
+	var nilLimiter *queryLimiter
+	if err := nilLimiter.acquire(context.Background()); err != nil {
+		t.Errorf("failed to acquire without limit. err: %v", err)
+	}
+	nilLimiter.release()
+}
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	var running, maxRunning int32
+	sc := &snowflakeConn{
+		cfg:     &Config{},
+		limiter: newQueryLimiter(2),
+		rest: &snowflakeRestful{
+			FuncPostQuery: func(context.Context, *snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*execResponse, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return &execResponse{Success: true}, nil
+			},
+		},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sc.exec(context.Background(), "select 1", false, false, nil)
+		}()
+	}
+	wg.Wait()
+	if maxRunning != 2 {
+		t.Errorf("failed to cap the in-flight queries. expected: 2, got: %v", maxRunning)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	queryTagKey     contextKey = "queryTag"
+	queryCommentKey contextKey = "queryComment"
+)
+
+// queryTagParam is the query parameter of the tag of the statement.
+const queryTagParam = "QUERY_TAG"
+
+// WithQueryTag returns a context setting QUERY_TAG of the statements run with it instead of the tag of the
+// session, so that the statements are found by the tag in the query history:
+//
+//	_, err = db.ExecContext(sf.WithQueryTag(ctx, "etl-job-42"), "INSERT INTO t SELECT * FROM s")
+func WithQueryTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, queryTagKey, tag)
+}
+
+// WithQueryComment returns a context adding the fields to the comment prepended to the statements run with it,
+// e.g., the trace ID of the request. The fields of the parent context are kept unless overridden.
+func WithQueryComment(ctx context.Context, fields map[string]string) context.Context {
+	merged := make(map[string]string)
+	if parent, ok := ctx.Value(queryCommentKey).(map[string]string); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, queryCommentKey, merged)
+}
+
+// queryTag returns the tag of the statement set by WithQueryTag.
+func queryTag(ctx context.Context) (string, bool) {
+	tag, ok := ctx.Value(queryTagKey).(string)
+	return tag, ok
+}
+
+// commentQuery prepends the comment of the statement, the application name and the fields of WithQueryComment in
+// JSON, e.g., /* {"application":"etl","trace_id":"4bf92f35"} */. The query is returned as is unless
+// Config.QueryComment is set or the context has the fields.
+func commentQuery(ctx context.Context, cfg *Config, query string) string {
+	fields, ok := ctx.Value(queryCommentKey).(map[string]string)
+	if !ok && !cfg.QueryComment {
+		return query
+	}
+	comment := map[string]string{"application": cfg.Application}
+	for k, v := range fields {
+		comment[k] = v
+	}
+	b, err := json.Marshal(comment)
+	if err != nil {
+		return query
+	}
+	// the JSON must not end the comment
+	return "/* " + strings.Replace(string(b), "*/", `*\/`, -1) + " */ " + query
+}
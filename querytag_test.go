@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestUnitCommentQuery(t *testing.T) {
+	cfg := &Config{Application: "etl"}
+	ctx := context.Background()
+	if q := commentQuery(ctx, cfg, "SELECT 1"); q != "SELECT 1" {
+		t.Errorf("should have returned the query as is. got: %v", q)
+	}
+	ctx = WithQueryComment(ctx, map[string]string{"trace_id": "t1", "job": "a"})
+	ctx = WithQueryComment(ctx, map[string]string{"job": "b*/"})
+	expected := `/* {"application":"etl","job":"b*\/","trace_id":"t1"} */ SELECT 1`
+	if q := commentQuery(ctx, cfg, "SELECT 1"); q != expected {
+		t.Errorf("failed to comment the query. expected: %v, got: %v", expected, q)
+	}
+	cfg.QueryComment = true
+	if q := commentQuery(context.Background(), cfg, "SELECT 1"); q != `/* {"application":"etl"} */ SELECT 1` {
+		t.Errorf("failed to comment the query. got: %v", q)
+	}
+}
+
+func TestQueryTagExec(t *testing.T) {
+	var sent []execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		sent = append(sent, req)
+		return &execResponse{Success: true}, nil
+	}
+	ctx := WithQueryTag(context.Background(), "etl-job-42")
+	ctx = WithQueryComment(ctx, map[string]string{"trace_id": "t1"})
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}
+	if _, err := sc.ExecContext(WithMultiStatement(ctx, 2), "INSERT INTO t VALUES (?); SELECT 1", args); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if _, err := sc.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("failed to send the queries. got: %v", sent)
+	}
+	if sent[0].Parameters[queryTagParam] != "etl-job-42" || sent[0].Parameters[multiStatementCountParam] != "2" {
+		t.Errorf("failed to set the query tag. got: %v", sent[0].Parameters)
+	}
+	if sent[0].SQLText != `/* {"application":"testapp","trace_id":"t1"} */ INSERT INTO t VALUES (?); SELECT 1` {
+		t.Errorf("failed to comment the query. got: %v", sent[0].SQLText)
+	}
+	if sent[1].Parameters != nil || sent[1].SQLText != "SELECT 1" {
+		t.Errorf("should have sent the query as is. got: %v, %v", sent[1].SQLText, sent[1].Parameters)
+	}
+}
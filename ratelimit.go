@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// pacerMinInterval is the interval between requests right after the first throttling response.
+	pacerMinInterval = 50 * time.Millisecond
+	// pacerMaxInterval is the maximum interval between requests.
+	pacerMaxInterval = 5 * time.Second
+	// maxRetryAfter caps the wait time requested by Retry-After.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// pacer spaces out the requests of a session while the server throttles it. The interval is doubled every
+// time a request is throttled and halved every time a request succeeds, so the session converges to the
+// rate the server accepts.
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration // wait time between the requests. 0 if not throttled
+	next     time.Time     // earliest time the next request can be sent
+}
+
+// wait blocks until the next request can be sent, or returns the error of ctx once ctx is done. A nil pacer
+// never blocks.
+func (p *pacer) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	now := getClock().Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+	if d := at.Sub(now); d > 0 {
+		glog.V(2).Infof("pacing the request. sleeping %v", d)
+		return sleepContext(ctx, d)
+	}
+	return nil
+}
+
+// throttled slows down the requests and holds all of them until retryAfter elapses.
+func (p *pacer) throttled(retryAfter time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval = durationMin(pacerMaxInterval, 2*p.interval)
+	if p.interval < pacerMinInterval {
+		p.interval = pacerMinInterval
+	}
+	if until := getClock().Now().Add(retryAfter); until.After(p.next) {
+		p.next = until
+	}
+}
+
+// succeeded speeds up the requests.
+func (p *pacer) succeeded() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval /= 2
+	if p.interval < pacerMinInterval {
+		p.interval = 0
+	}
+}
+
+// isThrottled returns true if the response tells the client to slow down: HTTP 429, or HTTP 503 with
+// Retry-After.
+func isThrottled(res *http.Response) bool {
+	if res == nil {
+		return false
+	}
+	return res.StatusCode == http.StatusTooManyRequests ||
+		res.StatusCode == http.StatusServiceUnavailable && res.Header.Get("Retry-After") != ""
+}
+
+// parseRetryAfter parses the Retry-After header value, either delay seconds or an HTTP date. The second
+// return value is false if the value is missing or invalid.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	var d time.Duration
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+	} else {
+		return 0, false
+	}
+	return durationMin(d, maxRetryAfter), true
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sleepRecordingClock is a Clock which advances the time on Sleep instead of sleeping.
+type sleepRecordingClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *sleepRecordingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *sleepRecordingClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now().Add(d)
+	return ch
+}
+
+func (c *sleepRecordingClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+type throttlingHTTPClient struct {
+	statuses   []int  // status codes returned in order. 200 after all
+	retryAfter string // Retry-After of the throttling responses
+}
+
+func (c *throttlingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	if len(c.statuses) > 0 {
+		status, c.statuses = c.statuses[0], c.statuses[1:]
+	}
+	res := &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       &fakeResponseBody{body: []byte("{}")},
+	}
+	if status != http.StatusOK && c.retryAfter != "" {
+		res.Header.Set("Retry-After", c.retryAfter)
+	}
+	return res, nil
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", ok: false},
+		{value: "3", expected: 3 * time.Second, ok: true},
+		{value: " 0 ", expected: 0, ok: true},
+		{value: "-1", ok: false},
+		{value: "abc", ok: false},
+		{value: "Fri, 01 Jun 2018 12:00:10 GMT", expected: 10 * time.Second, ok: true},
+		{value: "Fri, 01 Jun 2018 11:59:00 GMT", expected: 0, ok: true},
+		{value: "86400", expected: maxRetryAfter, ok: true},
+	}
+	for _, test := range testcases {
+		d, ok := parseRetryAfter(test.value, now)
+		if ok != test.ok || d != test.expected {
+			t.Errorf("failed to parse Retry-After. value: %q, expected: %v, %v, got: %v, %v",
+				test.value, test.expected, test.ok, d, ok)
+		}
+	}
+}
+
+func TestPacer(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	p := &pacer{}
+	p.wait(context.Background())
+	if len(c.sleeps) != 0 {
+		t.Errorf("failed to send the request without throttling. sleeps: %v", c.sleeps)
+	}
+	p.throttled(time.Second)
+	p.wait(context.Background())
+	p.wait(context.Background())
+	if len(c.sleeps) != 2 || c.sleeps[0] != time.Second || c.sleeps[1] != pacerMinInterval {
+		t.Errorf("failed to pace the requests. sleeps: %v", c.sleeps)
+	}
+	p.throttled(0)
+	if p.interval != 2*pacerMinInterval {
+		t.Errorf("failed to slow down. interval: %v", p.interval)
+	}
+	p.succeeded()
+	p.succeeded()
+	if p.interval != 0 {
+		t.Errorf("failed to speed up. interval: %v", p.interval)
+	}
+	var nilPacer *pacer
+	nilPacer.throttled(time.Second)
+	nilPacer.wait(context.Background())
+	nilPacer.succeeded()
+}
+
+func TestRetryTooManyRequests(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	testcases := []struct {
+		client   *throttlingHTTPClient
+		raise4XX bool
+		sleeps   []time.Duration
+	}{
+		{
+			client:   &throttlingHTTPClient{statuses: []int{429, 429}, retryAfter: "2"},
+			raise4XX: false,
+			sleeps:   []time.Duration{2 * time.Second, 2 * time.Second},
+		},
+		{
+			client:   &throttlingHTTPClient{statuses: []int{429}, retryAfter: "1"},
+			raise4XX: true,
+			sleeps:   []time.Duration{time.Second},
+		},
+		{
+			client:   &throttlingHTTPClient{statuses: []int{503}, retryAfter: "3"},
+			raise4XX: false,
+			sleeps:   []time.Duration{3 * time.Second},
+		},
+	}
+	for _, test := range testcases {
+		c.sleeps = nil
+		p := &pacer{}
+		res, err := retryHTTP(context.TODO(), test.client, p,
+			fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 60*time.Second, test.raise4XX)
+		if err != nil {
+			t.Fatalf("failed to retry the throttled requests. err: %v", err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("failed to get the response. HTTP: %v", res.StatusCode)
+		}
+		// the retry sleeps Retry-After, after which the pacer lets the retry through.
+		var total time.Duration
+		for _, d := range c.sleeps {
+			total += d
+		}
+		var expected time.Duration
+		for _, d := range test.sleeps {
+			expected += d
+		}
+		if total != expected {
+			t.Errorf("failed to honor Retry-After. expected: %v, got: %v", expected, c.sleeps)
+		}
+	}
+}
+
+func TestRetryTooManyRequestsTimeout(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	client := &throttlingHTTPClient{statuses: []int{429, 429, 429, 429}, retryAfter: "30"}
+	_, err := retryHTTP(context.TODO(), client, &pacer{},
+		fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 60*time.Second, false)
+	if err == nil {
+		t.Fatal("should fail to run retry")
+	}
+	se, ok := err.(*SnowflakeError)
+	if !ok || se.Number != ErrTooManyRequests {
+		t.Errorf("failed to get the throttling error. err: %v", err)
+	}
+}
+
+func TestRetryTooManyRequestsCanceled(t *testing.T) {
+	p := &pacer{}
+	p.throttled(maxRetryAfter)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := retryHTTP(ctx, &throttlingHTTPClient{}, p,
+		fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 0, false)
+	if err != context.Canceled {
+		t.Errorf("should have failed with the cancel. err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("failed to stop waiting for the throttle on the cancel. elapsed: %v", elapsed)
+	}
+}
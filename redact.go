@@ -0,0 +1,39 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"regexp"
+)
+
+const maskedSecret = "****"
+
+// secretKey matches the names of the parameters, headers and JSON fields holding secrets, e.g., PASSWORD,
+// masterToken, proxyPassword, AWS_SECRET_KEY, AZURE_SAS_TOKEN, X-Amz-Signature or sig.
+const secretKey = `[\w.-]*(?:passw(?:or)?d|passcode|token|secret|private_?key|signature|credential|saml_response|aws_key_id)[\w.-]*|pwd|sig`
+
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// private keys in PEM
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`), maskedSecret},
+	// Authorization headers other than Snowflake Token="...", which is masked as a parameter
+	{regexp.MustCompile(`(?i)\b(Bearer|Basic) [\w.~+/=-]+`), `$1 ` + maskedSecret},
+	// JSON fields, e.g., "PASSWORD":"secret"
+	{regexp.MustCompile(`(?i)("(?:` + secretKey + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`), `$1"` + maskedSecret + `"`},
+	// query strings, DSN parameters, printed maps and structs, e.g., token=secret, map[password:[secret]]
+	{regexp.MustCompile(`(?i)\b((?:` + secretKey + `)\s*[:=]\s*)(?:\[[^\]]*\]|'[^']*'|"[^"]*"|[^\s&,;"'}\])]+)`), `${1}` + maskedSecret},
+	// passwords of the DSN and URLs, e.g., user:secret@account
+	{regexp.MustCompile(`((?:^|[\s/"'(\[])[\w.%+-]+):[^\s:@/"']+@`), `$1:` + maskedSecret + `@`},
+}
+
+// maskSecrets returns the text with the secrets masked: the passwords, tokens, passcodes, private keys and cloud
+// storage credentials in JSON, query strings, DSNs, URLs and HTTP headers. All driver logs and the messages of
+// SnowflakeError are masked.
+func maskSecrets(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
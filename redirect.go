@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// Client Redirect connection URLs, e.g., myorg-myconnection.snowflakecomputing.com, are CNAMEs pointing to the
+// primary account of the connection. The resolved account host is cached per connection URL, and resolved
+// again when the login fails, so that the connection follows a failover to another region.
+var (
+	clientRedirectCache = make(map[string]string)
+	clientRedirectMutex = &sync.Mutex{}
+	lookupCNAME         = net.LookupCNAME
+)
+
+// resolveClientRedirect returns the account host the connection URL host currently points to. If refresh is
+// true, the cached host is discarded. The connection URL host is returned as is if it cannot be resolved to a
+// Snowflake host.
+func resolveClientRedirect(host string, refresh bool) string {
+	clientRedirectMutex.Lock()
+	defer clientRedirectMutex.Unlock()
+	if target, ok := clientRedirectCache[host]; ok && !refresh {
+		return target
+	}
+	target, err := lookupCNAME(host)
+	if err != nil {
+		glog.V(1).Infof("failed to resolve Client Redirect connection URL %v. err: %v", host, err)
+		glog.Flush()
+		delete(clientRedirectCache, host)
+		return host
+	}
+	target = strings.TrimSuffix(strings.ToLower(target), ".")
+	if hostDomain(target) == "" {
+		// not a Snowflake host, e.g., a CDN or load balancer. The certificate wouldn't match.
+		target = host
+	}
+	if prev, ok := clientRedirectCache[host]; ok && prev != target {
+		glog.V(1).Infof("Client Redirect connection URL %v moved from %v to %v", host, prev, target)
+	}
+	clientRedirectCache[host] = target
+	return target
+}
+
+// isConnectionFailure returns true if the error indicates the login request didn't reach an available account.
+// Network failures surface as plain errors once the retries time out.
+func isConnectionFailure(err error) bool {
+	if e, ok := err.(*SnowflakeError); ok {
+		return e.Number == ErrCodeServiceUnavailable || e.Number == ErrCodeFailedToConnect || e.Number == ErrRetryTimeout
+	}
+	return err != nil
+}
+
+// closeIdleConnections closes the keep-alive connections, so that the next requests resolve the host again.
+func closeIdleConnections(sr *snowflakeRestful) {
+	if c, ok := sr.Client.Transport.(interface {
+		CloseIdleConnections()
+	}); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveClientRedirect(t *testing.T) {
+	orgLookupCNAME := lookupCNAME
+	defer func() {
+		lookupCNAME = orgLookupCNAME
+	}()
+	target := "acct1.us-east-1.snowflakecomputing.com."
+	lookups := 0
+	lookupCNAME = func(host string) (string, error) {
+		lookups++
+		return target, nil
+	}
+	host := "org-conn.snowflakecomputing.com"
+	if h := resolveClientRedirect(host, false); h != "acct1.us-east-1.snowflakecomputing.com" {
+		t.Errorf("failed to resolve. got: %v", h)
+	}
+	target = "acct2.us-west-2.snowflakecomputing.com."
+	if h := resolveClientRedirect(host, false); h != "acct1.us-east-1.snowflakecomputing.com" || lookups != 1 {
+		t.Errorf("should have returned the cached host. got: %v, lookups: %v", h, lookups)
+	}
+	if h := resolveClientRedirect(host, true); h != "acct2.us-west-2.snowflakecomputing.com" {
+		t.Errorf("failed to resolve again. got: %v", h)
+	}
+	target = "cdn.example.com."
+	if h := resolveClientRedirect(host, true); h != host {
+		t.Errorf("should have kept the connection URL for a non Snowflake host. got: %v", h)
+	}
+	lookupCNAME = func(host string) (string, error) {
+		return "", errors.New("no such host")
+	}
+	if h := resolveClientRedirect(host, true); h != host {
+		t.Errorf("should have kept the connection URL if not resolved. got: %v", h)
+	}
+}
+
+func TestIsConnectionFailure(t *testing.T) {
+	if !isConnectionFailure(&SnowflakeError{Number: ErrCodeServiceUnavailable}) {
+		t.Error("service unavailable should be a connection failure")
+	}
+	if !isConnectionFailure(errors.New("timeout. Hanging?")) {
+		t.Error("network error should be a connection failure")
+	}
+	if isConnectionFailure(&SnowflakeError{Number: 390100}) {
+		t.Error("incorrect password should not be a connection failure")
+	}
+	if isConnectionFailure(nil) {
+		t.Error("nil should not be a connection failure")
+	}
+}
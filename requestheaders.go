@@ -0,0 +1,45 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/http"
+)
+
+const requestHeadersKey contextKey = "requestHeaders"
+
+// reservedRequestHeaders are set by the driver and can't be overridden by WithRequestHeaders.
+var reservedRequestHeaders = map[string]bool{
+	"Accept":           true,
+	"Authorization":    true,
+	"Content-Encoding": true,
+	"Content-Type":     true,
+	"User-Agent":       true,
+}
+
+// WithRequestHeaders returns a context that adds the HTTP headers to the query requests of the statements run
+// with it, e.g., the routing headers required by an API gateway in front of PrivateLink. The headers the driver
+// sets, e.g., Authorization and Content-Type, are not overridden.
+func WithRequestHeaders(ctx context.Context, headers map[string]string) context.Context {
+	h := make(map[string]string, len(headers))
+	for k, v := range headers {
+		h[k] = v
+	}
+	return context.WithValue(ctx, requestHeadersKey, h)
+}
+
+// addRequestHeaders adds the headers in ctx, if any, to headers.
+func addRequestHeaders(ctx context.Context, headers map[string]string) {
+	h, ok := ctx.Value(requestHeadersKey).(map[string]string)
+	if !ok {
+		return
+	}
+	for k, v := range h {
+		if reservedRequestHeaders[http.CanonicalHeaderKey(k)] {
+			glog.V(2).Infof("ignored the reserved request header: %v", k)
+			continue
+		}
+		headers[k] = v
+	}
+}
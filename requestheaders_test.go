@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWithRequestHeaders(t *testing.T) {
+	var got map[string]string
+	sc := &snowflakeConn{
+		cfg: &Config{},
+		rest: &snowflakeRestful{
+			FuncPostQuery: func(_ context.Context, _ *snowflakeRestful, _ *url.Values, headers map[string]string, _ []byte, _ time.Duration) (*execResponse, error) {
+				got = headers
+				return nil, errors.New("stop")
+			},
+		},
+	}
+	headers := map[string]string{
+		"X-Route-Target": "pl-east",
+		"content-type":   "text/plain",
+		"Authorization":  "Bearer x",
+	}
+	ctx := WithRequestHeaders(context.Background(), headers)
+	headers["X-Route-Target"] = "changed"
+	sc.ExecContext(ctx, "select 1", nil)
+	if got["X-Route-Target"] != "pl-east" {
+		t.Errorf("failed to add the request header. headers: %v", got)
+	}
+	if _, ok := got["content-type"]; ok {
+		t.Errorf("should not override the reserved header. headers: %v", got)
+	}
+	if _, ok := got["Authorization"]; ok {
+		t.Errorf("should not override the reserved header. headers: %v", got)
+	}
+
+	sc.ExecContext(context.Background(), "select 1", nil)
+	if _, ok := got["X-Route-Target"]; ok {
+		t.Errorf("should not add the request header without the context. headers: %v", got)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// REST API endpoints.
+const (
+	endpointLogin         = "login"
+	endpointAuthenticator = "authenticator"
+	endpointTokenRequest  = "token"
+	endpointHeartbeat     = "heartbeat"
+	endpointSession       = "session"
+	endpointQuery         = "query"
+	endpointAbort         = "abort"
+	endpointQueryResult   = "queryResult"
+	endpointTelemetry     = "telemetry"
+)
+
+// Capabilities of the server a protocol version may have.
+const (
+	capabilityQueryAbort = "queryAbort"
+	capabilityHeartbeat  = "heartbeat"
+	capabilityTokenRenew = "tokenRenew"
+)
+
+// restAPI is a version of the REST API: the paths of the endpoints and the capabilities of the servers
+// supporting it. A new protocol version is added in front of restAPIVersions with the minimum server version, so
+// that the accounts on older servers keep using the previous version.
+type restAPI struct {
+	version          int
+	minServerVersion string // minimum server version supporting the protocol. any if empty.
+	paths            map[string]string
+	capabilities     map[string]bool
+}
+
+var restAPIV1 = &restAPI{
+	version: 1,
+	paths: map[string]string{
+		endpointLogin:         "/session/v1/login-request",
+		endpointAuthenticator: "/session/authenticator-request",
+		endpointTokenRequest:  "/session/token-request",
+		endpointHeartbeat:     "/session/heartbeat",
+		endpointSession:       "/session",
+		endpointQuery:         "/queries/v1/query-request",
+		endpointAbort:         "/queries/v1/abort-request",
+		endpointQueryResult:   "/queries/{queryId}/result",
+		endpointTelemetry:     "/telemetry/send",
+	},
+	capabilities: map[string]bool{
+		capabilityQueryAbort: true,
+		capabilityHeartbeat:  true,
+		capabilityTokenRenew: true,
+	},
+}
+
+// restAPIVersions are the supported protocol versions, newest first. The last one is used until the login
+// response tells the server version.
+var restAPIVersions = []*restAPI{restAPIV1}
+
+// negotiateRESTAPI returns the newest protocol version supported by the server.
+func negotiateRESTAPI(serverVersion string) *restAPI {
+	for _, p := range restAPIVersions {
+		if p.minServerVersion == "" || compareVersions(serverVersion, p.minServerVersion) >= 0 {
+			return p
+		}
+	}
+	return restAPIVersions[len(restAPIVersions)-1]
+}
+
+// compareVersions compares dot separated numeric versions, e.g., 2.10.1 > 2.9. Non-numeric parts are
+// compared as 0, so an unknown server version is the oldest.
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// getRESTAPI returns the negotiated REST API version of the session.
+func (sr *snowflakeRestful) getRESTAPI() *restAPI {
+	if sr.api == nil {
+		return restAPIVersions[len(restAPIVersions)-1]
+	}
+	return sr.api
+}
+
+// hasCapability returns true if the server supports the capability in the negotiated protocol version.
+func (sr *snowflakeRestful) hasCapability(name string) bool {
+	return sr.getRESTAPI().capabilities[name]
+}
+
+// getFullURL returns the URL of the endpoint in the negotiated protocol version.
+func (sr *snowflakeRestful) getFullURL(endpoint string, params *url.Values) string {
+	path := sr.getRESTAPI().paths[endpoint]
+	if path == "" {
+		// every protocol version must define all endpoints
+		panic(fmt.Sprintf("endpoint %v is not defined in the protocol version %v", endpoint, sr.getRESTAPI().version))
+	}
+	ret := fmt.Sprintf("%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port, path)
+	if params != nil {
+		ret += "?" + params.Encode()
+	}
+	return ret
+}
+
+// getQueryResultURL returns the URL of the result of the query.
+func (sr *snowflakeRestful) getQueryResultURL(queryID string) string {
+	return strings.Replace(sr.getFullURL(endpointQueryResult, nil), "{queryId}", url.PathEscape(queryID), 1)
+}
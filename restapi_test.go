@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	testcases := []struct {
+		a   string
+		b   string
+		out int
+	}{
+		{a: "2.10.1", b: "2.9", out: 1},
+		{a: "2.9", b: "2.10.1", out: -1},
+		{a: "2.9.0", b: "2.9", out: 0},
+		{a: "", b: "1.0", out: -1},
+		{a: "3.x", b: "3.0", out: 0},
+	}
+	for _, test := range testcases {
+		if out := compareVersions(test.a, test.b); out != test.out {
+			t.Errorf("failed to compare %v and %v. expected: %v, got: %v", test.a, test.b, test.out, out)
+		}
+	}
+}
+
+func TestNegotiateRESTAPI(t *testing.T) {
+	capabilityV2 := "v2"
+	v2 := &restAPI{
+		version:          2,
+		minServerVersion: "3.0",
+		paths:            map[string]string{endpointQuery: "/queries/v2/query-request"},
+		capabilities:     map[string]bool{capabilityV2: true},
+	}
+	org := restAPIVersions
+	restAPIVersions = []*restAPI{v2, restAPIV1}
+	defer func() {
+		restAPIVersions = org
+	}()
+	testcases := []struct {
+		serverVersion string
+		version       int
+	}{
+		{serverVersion: "3.1.0", version: 2},
+		{serverVersion: "3.0", version: 2},
+		{serverVersion: "2.99.1", version: 1},
+		{serverVersion: "", version: 1},
+	}
+	for _, test := range testcases {
+		if api := negotiateRESTAPI(test.serverVersion); api.version != test.version {
+			t.Errorf("failed to negotiate for %v. expected: %v, got: %v", test.serverVersion, test.version, api.version)
+		}
+	}
+	sr := &snowflakeRestful{Protocol: "https", Host: "a.snowflakecomputing.com", Port: 443}
+	if sr.getRESTAPI() != restAPIV1 || sr.hasCapability(capabilityV2) {
+		t.Errorf("should have used the oldest version before login")
+	}
+	sr.api = negotiateRESTAPI("3.0")
+	params := &url.Values{}
+	params.Add("requestId", "1")
+	if u := sr.getFullURL(endpointQuery, params); u != "https://a.snowflakecomputing.com:443/queries/v2/query-request?requestId=1" {
+		t.Errorf("failed to get the URL. got: %v", u)
+	}
+	if !sr.hasCapability(capabilityV2) {
+		t.Errorf("failed to get the capability")
+	}
+}
+
+func TestRESTAPIV1Endpoints(t *testing.T) {
+	sr := &snowflakeRestful{Protocol: "https", Host: "a.snowflakecomputing.com", Port: 443}
+	for _, endpoint := range []string{endpointLogin, endpointAuthenticator, endpointTokenRequest,
+		endpointHeartbeat, endpointSession, endpointQuery, endpointAbort} {
+		if restAPIV1.paths[endpoint] == "" {
+			t.Errorf("failed to define %v", endpoint)
+		}
+	}
+	if u := sr.getFullURL(endpointSession, nil); u != "https://a.snowflakecomputing.com:443/session" {
+		t.Errorf("failed to get the URL. got: %v", u)
+	}
+}
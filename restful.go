@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,12 +22,19 @@ const (
 
 	headerContentTypeApplicationJSON     = "application/json"
 	headerAcceptTypeApplicationSnowflake = "application/snowflake"
+	headerContentEncoding                = "Content-Encoding"
+	headerContentEncodingGzip            = "gzip"
 
-	sessionExpiredCode       = "390112"
 	queryInProgressCode      = "333333"
 	queryInProgressAsyncCode = "333334"
 )
 
+// sessionExpiredCode is ErrCodeSessionExpired in the code field of the responses.
+var sessionExpiredCode = strconv.Itoa(ErrCodeSessionExpired)
+
+// cancelQueryTimeout bounds aborting a query, which runs after the context of the query is done.
+var cancelQueryTimeout = 5 * time.Second
+
 type snowflakeRestful struct {
 	Host           string
 	Port           int
@@ -35,11 +43,19 @@ type snowflakeRestful struct {
 	RequestTimeout time.Duration // request timeout
 	Authenticator  string
 
-	Client      *http.Client
-	Token       string
-	MasterToken string
-	SessionID   int
-	HeartBeat   *heartbeat
+	MaxRetryDuration time.Duration // caps the timeout of the retries of each request. unlimited if 0.
+
+	Client          *http.Client
+	Token           string
+	TokenValidUntil time.Time // zero if unknown
+	MasterToken     string
+	SessionID       int
+	HeartBeat       *heartbeat
+	api             *restAPI        // negotiated protocol version of the REST API
+	pacer           *pacer          // paces the requests while the server throttles the session
+	breaker         *circuitBreaker // fails the login and query requests fast while the host is unavailable. nil if disabled.
+	renewMu         sync.Mutex      // serializes the session renewals of the concurrent requests
+	tokenMu         sync.RWMutex    // guards Token, TokenValidUntil and MasterToken
 
 	Connection          *snowflakeConn
 	FuncPostQuery       func(context.Context, *snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*execResponse, error)
@@ -47,13 +63,61 @@ type snowflakeRestful struct {
 	FuncPost            func(context.Context, *snowflakeRestful, string, map[string]string, []byte, time.Duration, bool) (*http.Response, error)
 	FuncGet             func(context.Context, *snowflakeRestful, string, map[string]string, time.Duration) (*http.Response, error)
 	FuncRenewSession    func(context.Context, *snowflakeRestful) error
-	FuncPostAuth        func(*snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*authResponse, error)
+	FuncPostAuth        func(context.Context, *snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*authResponse, error)
 	FuncCloseSession    func(*snowflakeRestful) error
 	FuncCancelQuery     func(*snowflakeRestful, string) error
+	FuncGetQueryResult  func(context.Context, *snowflakeRestful, string) (*execResponse, error)
+
+	FuncPostAuthSAML func(context.Context, *snowflakeRestful, map[string]string, []byte, time.Duration) (*authResponse, error)
+	FuncPostAuthOKTA func(context.Context, *snowflakeRestful, map[string]string, []byte, string, time.Duration) (*authOKTAResponse, error)
+	FuncGetSSO       func(context.Context, *snowflakeRestful, *url.Values, map[string]string, string, time.Duration) ([]byte, error)
+}
+
+// setToken sets the session tokens and calculates the session token expiration.
+func (sr *snowflakeRestful) setToken(token, masterToken string, validity time.Duration) {
+	sr.tokenMu.Lock()
+	defer sr.tokenMu.Unlock()
+	sr.Token = token
+	sr.MasterToken = masterToken
+	sr.TokenValidUntil = time.Time{}
+	if validity > 0 {
+		sr.TokenValidUntil = getClock().Now().Add(validity)
+	}
+}
+
+// getTokens returns the session token, the master token and the session token expiration set together.
+func (sr *snowflakeRestful) getTokens() (token, masterToken string, validUntil time.Time) {
+	sr.tokenMu.RLock()
+	defer sr.tokenMu.RUnlock()
+	return sr.Token, sr.MasterToken, sr.TokenValidUntil
+}
 
-	FuncPostAuthSAML func(*snowflakeRestful, map[string]string, []byte, time.Duration) (*authResponse, error)
-	FuncPostAuthOKTA func(*snowflakeRestful, map[string]string, []byte, string, time.Duration) (*authOKTAResponse, error)
-	FuncGetSSO       func(*snowflakeRestful, *url.Values, map[string]string, string, time.Duration) ([]byte, error)
+// renewSession renews the session token with the master token unless another request of the session already
+// renewed it since token was sent. It fails if the negotiated protocol version doesn't renew tokens.
+func (sr *snowflakeRestful) renewSession(ctx context.Context, token string) error {
+	if !sr.hasCapability(capabilityTokenRenew) {
+		return &SnowflakeError{
+			Number:      ErrFailedToRenewSession,
+			SQLState:    SQLStateConnectionFailure,
+			Message:     errMsgTokenRenewNotSupported,
+			MessageArgs: []interface{}{sr.getRESTAPI().version},
+		}
+	}
+	sr.renewMu.Lock()
+	defer sr.renewMu.Unlock()
+	if current, _, _ := sr.getTokens(); current != token {
+		return nil
+	}
+	return sr.FuncRenewSession(ctx, sr)
+}
+
+// sessionRenewedKey marks the retry of a query after the session renewal.
+const sessionRenewedKey contextKey = "sessionRenewed"
+
+// isTokenExpired returns true if the session token is known to be expired.
+func (sr *snowflakeRestful) isTokenExpired() bool {
+	_, _, validUntil := sr.getTokens()
+	return !validUntil.IsZero() && !getClock().Now().Before(validUntil)
 }
 
 type renewSessionResponse struct {
@@ -87,7 +151,8 @@ func postRestful(
 	timeout time.Duration,
 	raise4XX bool) (
 	*http.Response, error) {
-	return retryHTTP(ctx, sr.Client, http.NewRequest, "POST", fullURL, headers, body, timeout, raise4XX)
+	return retryHTTP(ctx, &restRetryClient{client: sr.Client}, sr.pacer, http.NewRequest, "POST", fullURL, headers, body,
+		retryTimeout(timeout, sr.MaxRetryDuration), raise4XX)
 }
 
 func getRestful(
@@ -97,7 +162,8 @@ func getRestful(
 	headers map[string]string,
 	timeout time.Duration) (
 	*http.Response, error) {
-	return retryHTTP(ctx, sr.Client, http.NewRequest, "GET", fullURL, headers, nil, timeout, false)
+	return retryHTTP(ctx, &restRetryClient{client: sr.Client}, sr.pacer, http.NewRequest, "GET", fullURL, headers, nil,
+		retryTimeout(timeout, sr.MaxRetryDuration), false)
 }
 
 type execResponseAndErr struct {
@@ -115,7 +181,9 @@ func postRestfulQuery(
 	data *execResponse, err error) {
 
 	requestID := uuid.New().String()
-	execResponseChan := make(chan execResponseAndErr)
+	ctx = WithLogFields(ctx, LogField{LogFieldRequestID, requestID})
+	// buffered, so that the helper doesn't block after ctx is done and nobody receives the response
+	execResponseChan := make(chan execResponseAndErr, 1)
 
 	go func() {
 		data, err := sr.FuncPostQueryHelper(ctx, sr, params, headers, body, timeout, requestID)
@@ -126,9 +194,11 @@ func postRestfulQuery(
 
 	select {
 	case <-ctx.Done():
-		err := sr.FuncCancelQuery(sr, requestID)
-		if err != nil {
-			return nil, err
+		// aborts the query running in the warehouse. the application gets the context error regardless.
+		if sr.hasCapability(capabilityQueryAbort) {
+			if err := sr.FuncCancelQuery(sr, requestID); err != nil {
+				glog.WithContext(ctx).V(1).Infof("failed to cancel the query. requestId: %v, err: %v", requestID, err)
+			}
 		}
 		return nil, ctx.Err()
 	case respAndErr := <-execResponseChan:
@@ -145,62 +215,87 @@ func postRestfulQueryHelper(
 	timeout time.Duration,
 	requestID string) (
 	data *execResponse, err error) {
-	glog.V(2).Infof("params: %v", params)
-	params.Add("requestId", requestID)
-	if sr.Token != "" {
-		headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, sr.Token)
+	glog.WithContext(ctx).V(2).Infof("params: %v", params)
+	params.Set("requestId", requestID)
+	token, _, _ := sr.getTokens()
+	if sr.isTokenExpired() {
+		glog.WithContext(ctx).V(2).Info("session token expired. renewing")
+		if err = sr.renewSession(ctx, token); err != nil {
+			return nil, err
+		}
+		token, _, _ = sr.getTokens()
+	}
+	if token != "" {
+		headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
 	}
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port,
-		"/queries/v1/query-request?"+params.Encode())
-	resp, err := sr.FuncPost(ctx, sr, fullURL, headers, body, timeout, false)
+	fullURL := sr.getFullURL(endpointQuery, params)
+	resp, err := postWithCircuitBreaker(ctx, sr, fullURL, headers, body, timeout, false)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusOK {
-		glog.V(2).Infof("postQuery: resp: %v", resp)
+		glog.WithContext(ctx).V(2).Infof("postQuery: resp: %v", resp)
 		var respd execResponse
 		err = json.NewDecoder(resp.Body).Decode(&respd)
 		if err != nil {
-			glog.V(1).Infof("failed to decode JSON. err: %v", err)
+			glog.WithContext(ctx).V(1).Infof("failed to decode JSON. err: %v", err)
 			glog.Flush()
 			return nil, err
 		}
 		if respd.Code == sessionExpiredCode {
-			err = sr.FuncRenewSession(ctx, sr)
-			if err != nil {
+			// the query was rejected without running. renews the session and retries it once.
+			if ctx.Value(sessionRenewedKey) != nil {
+				return nil, &SnowflakeError{
+					Number:      ErrCodeSessionExpired,
+					SQLState:    SQLStateConnectionFailure,
+					Message:     errMsgSessionExpired,
+					MessageArgs: []interface{}{respd.Message},
+				}
+			}
+			glog.WithContext(ctx).V(2).Info("session expired. renewing and retrying the query")
+			if err = sr.renewSession(ctx, token); err != nil {
 				return nil, err
 			}
-			return sr.FuncPostQuery(ctx, sr, params, headers, body, timeout)
+			return sr.FuncPostQueryHelper(context.WithValue(ctx, sessionRenewedKey, true), sr, params, headers, body, timeout, requestID)
 		}
 
 		var resultURL string
 		isSessionRenewed := false
+		queryID := respd.Data.QueryID
+		start := getClock().Now()
+		polls := 0
 
 		for isSessionRenewed || respd.Code == queryInProgressCode ||
 			respd.Code == queryInProgressAsyncCode {
 			if !isSessionRenewed {
 				resultURL = respd.Data.GetResultURL
+				reportQueryProgress(ctx, QueryProgress{
+					QueryID: queryID, Status: QueryStatusRunning, Polls: polls, Elapsed: getClock().Now().Sub(start)})
 			}
 
-			glog.V(2).Info("ping pong")
+			glog.WithContext(ctx).V(2).Info("ping pong")
 			glog.Flush()
-			headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, sr.Token)
+			token, _, _ = sr.getTokens()
+			headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
 			fullURL := fmt.Sprintf(
 				"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port, resultURL)
 
 			resp, err = sr.FuncGet(ctx, sr, fullURL, headers, 0)
+			if err != nil {
+				return nil, err
+			}
+			polls++
 			respd = execResponse{} // reset the response
 			err = json.NewDecoder(resp.Body).Decode(&respd)
 			resp.Body.Close()
 			if err != nil {
-				glog.V(1).Infof("failed to decode JSON. err: %v", err)
+				glog.WithContext(ctx).V(1).Infof("failed to decode JSON. err: %v", err)
 				glog.Flush()
 				return nil, err
 			}
 			if respd.Code == sessionExpiredCode {
-				err = sr.FuncRenewSession(ctx, sr)
+				err = sr.renewSession(ctx, token)
 				if err != nil {
 					return nil, err
 				}
@@ -209,15 +304,23 @@ func postRestfulQueryHelper(
 				isSessionRenewed = false
 			}
 		}
+		if polls > 0 {
+			status := QueryStatusSuccess
+			if !respd.Success {
+				status = QueryStatusFailed
+			}
+			reportQueryProgress(ctx, QueryProgress{
+				QueryID: queryID, Status: status, Polls: polls, Elapsed: getClock().Now().Sub(start)})
+		}
 		return &respd, nil
 	}
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		glog.V(1).Infof("failed to extract HTTP response body. err: %v", err)
+		glog.WithContext(ctx).V(1).Infof("failed to extract HTTP response body. err: %v", err)
 		return nil, err
 	}
-	glog.V(1).Infof("HTTP: %v, URL: %v, Body: %v", resp.StatusCode, fullURL, b)
-	glog.V(1).Infof("Header: %v", resp.Header)
+	glog.WithContext(ctx).V(1).Infof("HTTP: %v, URL: %v, Body: %v", resp.StatusCode, fullURL, b)
+	glog.WithContext(ctx).V(1).Infof("Header: %v", resp.Header)
 	glog.Flush()
 	return nil, &SnowflakeError{
 		Number:      ErrFailedToPostQuery,
@@ -232,14 +335,14 @@ func closeSession(sr *snowflakeRestful) error {
 	params := &url.Values{}
 	params.Add("delete", "true")
 	params.Add("requestId", uuid.New().String())
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port, "/session?"+params.Encode())
+	fullURL := sr.getFullURL(endpointSession, params)
 
 	headers := make(map[string]string)
 	headers["Content-Type"] = headerContentTypeApplicationJSON
 	headers["accept"] = headerAcceptTypeApplicationSnowflake
 	headers["User-Agent"] = userAgent
-	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, sr.Token)
+	token, _, _ := sr.getTokens()
+	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
 
 	resp, err := sr.FuncPost(context.TODO(), sr, fullURL, headers, nil, 5*time.Second, false)
 	if err != nil {
@@ -287,17 +390,17 @@ func renewRestfulSession(ctx context.Context, sr *snowflakeRestful) error {
 	glog.V(2).Info("start renew session")
 	params := &url.Values{}
 	params.Add("requestId", uuid.New().String())
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port, "/session/token-request?"+params.Encode())
+	fullURL := sr.getFullURL(endpointTokenRequest, params)
 
 	headers := make(map[string]string)
 	headers["Content-Type"] = headerContentTypeApplicationJSON
 	headers["accept"] = headerAcceptTypeApplicationSnowflake
 	headers["User-Agent"] = userAgent
-	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, sr.MasterToken)
+	token, masterToken, _ := sr.getTokens()
+	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, masterToken)
 
 	body := make(map[string]string)
-	body["oldSessionToken"] = sr.Token
+	body["oldSessionToken"] = token
 	body["requestType"] = "RENEW"
 
 	var reqBody []byte
@@ -329,8 +432,7 @@ func renewRestfulSession(ctx context.Context, sr *snowflakeRestful) error {
 				Message: respd.Message,
 			}
 		}
-		sr.Token = respd.Data.SessionToken
-		sr.MasterToken = respd.Data.MasterToken
+		sr.setToken(respd.Data.SessionToken, respd.Data.MasterToken, respd.Data.ValidityInSecondsST*time.Second)
 		return nil
 	}
 	b, err := ioutil.ReadAll(resp.Body)
@@ -354,14 +456,14 @@ func cancelQuery(sr *snowflakeRestful, requestID string) error {
 	glog.V(2).Info("cancel query")
 	params := &url.Values{}
 	params.Add("requestId", uuid.New().String())
-	fullURL := fmt.Sprintf(
-		"%s://%s:%d%s", sr.Protocol, sr.Host, sr.Port, "/queries/v1/abort-request?"+params.Encode())
+	fullURL := sr.getFullURL(endpointAbort, params)
 
 	headers := make(map[string]string)
 	headers["Content-Type"] = headerContentTypeApplicationJSON
 	headers["accept"] = headerAcceptTypeApplicationSnowflake
 	headers["User-Agent"] = userAgent
-	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, sr.Token)
+	token, _, _ := sr.getTokens()
+	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
 
 	req := make(map[string]string)
 	req["requestId"] = requestID
@@ -371,7 +473,10 @@ func cancelQuery(sr *snowflakeRestful, requestID string) error {
 		return err
 	}
 
-	resp, err := sr.FuncPost(context.TODO(), sr, fullURL, headers, reqByte, 0, false)
+	// the context of the query is already done, so the abort has its own
+	ctx, cancel := context.WithTimeout(context.Background(), cancelQueryTimeout)
+	defer cancel()
+	resp, err := sr.FuncPost(ctx, sr, fullURL, headers, reqByte, cancelQueryTimeout, false)
 	if err != nil {
 		return err
 	}
@@ -385,7 +490,7 @@ func cancelQuery(sr *snowflakeRestful, requestID string) error {
 			return err
 		}
 		if !respd.Success && respd.Code == sessionExpiredCode {
-			err := sr.FuncRenewSession(context.TODO(), sr)
+			err := sr.renewSession(ctx, token)
 			if err != nil {
 				return err
 			}
@@ -419,3 +524,51 @@ func cancelQuery(sr *snowflakeRestful, requestID string) error {
 		MessageArgs: []interface{}{resp.StatusCode, fullURL},
 	}
 }
+
+// getQueryResult gets the result of the completed query, e.g., a statement of a multi-statement query.
+func getQueryResult(ctx context.Context, sr *snowflakeRestful, queryID string) (*execResponse, error) {
+	fullURL := sr.getQueryResultURL(queryID)
+	token, _, _ := sr.getTokens()
+	headers := make(map[string]string)
+	headers["Content-Type"] = headerContentTypeApplicationJSON
+	headers["accept"] = headerAcceptTypeApplicationSnowflake
+	headers["User-Agent"] = userAgent
+	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
+	addRequestHeaders(ctx, headers)
+
+	resp, err := sr.FuncGet(ctx, sr, fullURL, headers, sr.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		var respd execResponse
+		if err = json.NewDecoder(resp.Body).Decode(&respd); err != nil {
+			glog.V(1).Infof("failed to decode JSON. err: %v", err)
+			glog.Flush()
+			return nil, err
+		}
+		if respd.Code == sessionExpiredCode && ctx.Value(sessionRenewedKey) == nil {
+			if err = sr.renewSession(ctx, token); err != nil {
+				return nil, err
+			}
+			return sr.FuncGetQueryResult(context.WithValue(ctx, sessionRenewedKey, true), sr, queryID)
+		}
+		return &respd, nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		glog.V(1).Infof("failed to extract HTTP response body. err: %v", err)
+		glog.Flush()
+		return nil, err
+	}
+	glog.V(1).Infof("HTTP: %v, URL: %v, Body: %v", resp.StatusCode, fullURL, b)
+	glog.V(1).Infof("Header: %v", resp.Header)
+	glog.Flush()
+	return nil, &SnowflakeError{
+		Number:      ErrFailedToGetQueryResult,
+		SQLState:    SQLStateConnectionFailure,
+		Message:     errMsgFailedToGetQueryResult,
+		MessageArgs: []interface{}{resp.StatusCode, fullURL},
+	}
+}
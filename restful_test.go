@@ -5,8 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -127,17 +132,33 @@ func renewSessionTestError(_ context.Context, _ *snowflakeRestful) error {
 }
 
 func TestUnitPostQueryHelperRenewSession(t *testing.T) {
+	posts := 0
 	sr := &snowflakeRestful{
-		Token:            "token",
-		FuncPost:         postTestRenew,
-		FuncPostQuery:    postQueryTest,
-		FuncRenewSession: renewSessionTest,
+		Token: "token",
+		FuncPost: func(ctx context.Context, sr *snowflakeRestful, fullURL string, headers map[string]string, body []byte, timeout time.Duration, raise4XX bool) (*http.Response, error) {
+			posts++
+			if posts == 1 {
+				return postTestRenew(ctx, sr, fullURL, headers, body, timeout, raise4XX)
+			}
+			return postTestAfterRenew(ctx, sr, fullURL, headers, body, timeout, raise4XX)
+		},
+		FuncPostQueryHelper: postRestfulQueryHelper,
+		FuncRenewSession:    renewSessionTest,
 	}
 	var err error
 	_, err = postRestfulQueryHelper(context.Background(), sr, &url.Values{}, make(map[string]string), []byte{0x12, 0x34}, 0, "abcdefg")
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
+	if posts != 2 {
+		t.Fatalf("failed to retry the query once. posts: %v", posts)
+	}
+	// the session expires again after the renewal
+	sr.FuncPost = postTestRenew
+	_, err = postRestfulQueryHelper(context.Background(), sr, &url.Values{}, make(map[string]string), []byte{0x12, 0x34}, 0, "abcdefg")
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrCodeSessionExpired || driverErr.SQLState != SQLStateConnectionFailure {
+		t.Fatalf("should have failed after one retry. err: %v", err)
+	}
 	sr.FuncRenewSession = renewSessionTestError
 	_, err = postRestfulQueryHelper(context.Background(), sr, &url.Values{}, make(map[string]string), []byte{0x12, 0x34}, 0, "abcdefg")
 	if err == nil {
@@ -145,6 +166,87 @@ func TestUnitPostQueryHelperRenewSession(t *testing.T) {
 	}
 }
 
+func TestUnitRenewSessionOnce(t *testing.T) {
+	var renewals int32
+	sr := &snowflakeRestful{Token: "token"}
+	sr.FuncRenewSession = func(context.Context, *snowflakeRestful) error {
+		atomic.AddInt32(&renewals, 1)
+		time.Sleep(10 * time.Millisecond)
+		sr.setToken("renewed", "mtoken", 0)
+		return nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sr.renewSession(context.Background(), "token"); err != nil {
+				t.Errorf("failed to renew the session. err: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if renewals != 1 {
+		t.Errorf("failed to renew the session once for the concurrent requests. renewals: %v", renewals)
+	}
+}
+
+func TestUnitRenewSessionNotSupported(t *testing.T) {
+	sr := &snowflakeRestful{Token: "token", api: &restAPI{version: 2}}
+	sr.FuncRenewSession = func(context.Context, *snowflakeRestful) error {
+		t.Error("should not have renewed the session")
+		return nil
+	}
+	err := sr.renewSession(context.Background(), "token")
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrFailedToRenewSession {
+		t.Errorf("should have failed to renew the session. err: %v", err)
+	}
+}
+
+func TestUnitRenewSessionWhileQuerying(t *testing.T) {
+	sr := &snowflakeRestful{
+		FuncPost: func(ctx context.Context, sr *snowflakeRestful, fullURL string, headers map[string]string, body []byte, timeout time.Duration, raise4XX bool) (*http.Response, error) {
+			if !strings.HasPrefix(headers[headerAuthorizationKey], `Snowflake Token="token`) {
+				t.Errorf("failed to send the session token. got: %v", headers[headerAuthorizationKey])
+			}
+			return postTestAfterRenew(ctx, sr, fullURL, headers, body, timeout, raise4XX)
+		},
+		FuncPostQueryHelper: postRestfulQueryHelper,
+	}
+	sr.setToken("token0", "mtoken", time.Hour)
+	var renewals int32
+	sr.FuncRenewSession = func(context.Context, *snowflakeRestful) error {
+		n := atomic.AddInt32(&renewals, 1)
+		sr.setToken(fmt.Sprintf("token%v", n), "mtoken", time.Hour)
+		return nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				token, _, _ := sr.getTokens()
+				if err := sr.renewSession(context.Background(), token); err != nil {
+					t.Errorf("failed to renew the session. err: %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := postRestfulQueryHelper(context.Background(), sr, &url.Values{}, make(map[string]string), nil, 0, "abcdefg"); err != nil {
+					t.Errorf("failed to post the query. err: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if token, _, _ := sr.getTokens(); token != fmt.Sprintf("token%v", renewals) {
+		t.Errorf("failed to keep the last token. token: %v, renewals: %v", token, renewals)
+	}
+}
+
 func TestUnitRenewRestfulSession(t *testing.T) {
 	sr := &snowflakeRestful{
 		MasterToken: "mtoken",
@@ -221,3 +323,61 @@ func TestUnitCancelQuery(t *testing.T) {
 		t.Fatal("should have failed to close session")
 	}
 }
+
+func TestUnitCancelQueryTimeout(t *testing.T) {
+	defer func(d time.Duration) { cancelQueryTimeout = d }(cancelQueryTimeout)
+	cancelQueryTimeout = 10 * time.Millisecond
+	sr := &snowflakeRestful{
+		FuncPost: func(ctx context.Context, _ *snowflakeRestful, _ string, _ map[string]string, _ []byte, timeout time.Duration, _ bool) (*http.Response, error) {
+			if _, ok := ctx.Deadline(); !ok || timeout != cancelQueryTimeout {
+				t.Errorf("the abort should have been bounded. timeout: %v", timeout)
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	if err := cancelQuery(sr, "abcdefg"); err != context.DeadlineExceeded {
+		t.Fatalf("should have timed out. err: %v", err)
+	}
+}
+
+func TestUnitPostRestfulQueryCancel(t *testing.T) {
+	var helperRequestID, canceledRequestID string
+	started := make(chan struct{})
+	sr := &snowflakeRestful{
+		FuncPostQueryHelper: func(ctx context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, _ []byte, _ time.Duration, requestID string) (*execResponse, error) {
+			helperRequestID = requestID
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		FuncCancelQuery: func(_ *snowflakeRestful, requestID string) error {
+			canceledRequestID = requestID
+			return errors.New("failed to cancel")
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := postRestfulQuery(ctx, sr, &url.Values{}, make(map[string]string), []byte{0x12, 0x34}, 0)
+	if err != context.Canceled {
+		t.Fatalf("should have returned the context error. err: %v", err)
+	}
+	if canceledRequestID == "" || canceledRequestID != helperRequestID {
+		t.Errorf("failed to cancel the query. expected: %v, got: %v", helperRequestID, canceledRequestID)
+	}
+	// the helper returning after the cancel exits without the receiver
+	deadline := time.Now().Add(time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		if !strings.Contains(string(buf[:runtime.Stack(buf, true)]), "gosnowflake.postRestfulQuery.func") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the helper of the query is still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -2,9 +2,24 @@
 
 package gosnowflake
 
+import "database/sql/driver"
+
+// SnowflakeResult is the interface of the results of the driver beyond database/sql/driver. With Go 1.13 or
+// later, the result of the driver connection is available in sql.Conn.Raw:
+//
+//	res, err := dc.(driver.ExecerContext).ExecContext(ctx, "INSERT INTO t VALUES (1)", nil)
+//	...
+//	fmt.Println(res.(sf.SnowflakeResult).QueryID())
+type SnowflakeResult interface {
+	driver.Result
+	// QueryID returns the ID of the query.
+	QueryID() string
+}
+
 type snowflakeResult struct {
 	affectedRows int64
 	insertID     int64 // Snowflake doesn't support last insert id
+	queryID      string
 }
 
 func (res *snowflakeResult) LastInsertId() (int64, error) {
@@ -14,3 +29,17 @@ func (res *snowflakeResult) LastInsertId() (int64, error) {
 func (res *snowflakeResult) RowsAffected() (int64, error) {
 	return res.affectedRows, nil
 }
+
+func (res *snowflakeResult) QueryID() string {
+	return res.queryID
+}
+
+// snowflakeResultNoRows is driver.ResultNoRows with the query ID, returned by DDL, PUT and GET.
+type snowflakeResultNoRows struct {
+	driver.Result
+	queryID string
+}
+
+func (res *snowflakeResultNoRows) QueryID() string {
+	return res.queryID
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestQueryID(t *testing.T) {
+	one := "1"
+	responses := map[string]*execResponse{
+		"insert": {Success: true, Data: execResponseData{
+			QueryID:         "01-insert",
+			StatementTypeID: statementTypeIDInsert,
+			RowType:         []execResponseRowType{{Name: "number of rows inserted"}},
+			RowSet:          [][]*string{{&one}},
+		}},
+		"create": {Success: true, Data: execResponseData{QueryID: "02-create", StatementTypeID: 0x6000}},
+		"select": {Success: true, Data: execResponseData{
+			QueryID: "03-select",
+			RowType: []execResponseRowType{{Name: "C1", Type: "fixed"}},
+			RowSet:  [][]*string{{&one}},
+		}},
+		"fail": {Success: false, Code: "2003", Message: "object does not exist", Data: execResponseData{
+			QueryID:  "04-fail",
+			SQLState: "02000",
+		}},
+	}
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		return responses[req.SQLText], nil
+	}
+	ctx := context.Background()
+
+	testcases := []struct {
+		query        string
+		queryID      string
+		rowsAffected int64
+		noRows       bool
+	}{
+		{query: "insert", queryID: "01-insert", rowsAffected: 1},
+		{query: "create", queryID: "02-create", noRows: true},
+	}
+	for _, test := range testcases {
+		res, err := sc.ExecContext(ctx, test.query, nil)
+		if err != nil {
+			t.Fatalf("failed to exec. query: %v, err: %v", test.query, err)
+		}
+		if id := res.(SnowflakeResult).QueryID(); id != test.queryID {
+			t.Errorf("failed to get the query ID of the result. expected: %v, got: %v", test.queryID, id)
+		}
+		n, err := res.RowsAffected()
+		if test.noRows && err == nil {
+			t.Errorf("should have failed to get the rows affected of %v", test.query)
+		}
+		if !test.noRows && n != test.rowsAffected {
+			t.Errorf("failed to get the rows affected. expected: %v, got: %v", test.rowsAffected, n)
+		}
+		if id := sc.QueryID(); id != test.queryID {
+			t.Errorf("failed to get the last query ID. expected: %v, got: %v", test.queryID, id)
+		}
+	}
+
+	rows, err := sc.QueryContext(ctx, "select", nil)
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	if id := rows.(SnowflakeRows).QueryID(); id != "03-select" {
+		t.Errorf("failed to get the query ID of the rows. got: %v", id)
+	}
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		t.Errorf("failed to get the row. err: %v", err)
+	}
+	rows.Close()
+
+	_, err = sc.ExecContext(ctx, "fail", nil)
+	se, ok := AsSnowflakeError(err)
+	if !ok || se.QueryID != "04-fail" || se.Number != 2003 {
+		t.Errorf("failed to get the query ID of the error. err: %#v", err)
+	}
+	if id := sc.QueryID(); id != "04-fail" {
+		t.Errorf("failed to get the query ID of the failed query. got: %v", id)
+	}
+}
+
+func TestUnitWithQueryID(t *testing.T) {
+	se := &SnowflakeError{Number: ErrInvalidArrowData}
+	if withQueryID(se, "abc"); se.QueryID != "abc" {
+		t.Errorf("failed to set the query ID. got: %v", se.QueryID)
+	}
+	if withQueryID(se, "def"); se.QueryID != "abc" {
+		t.Errorf("should have kept the query ID. got: %v", se.QueryID)
+	}
+	if err := withQueryID(context.Canceled, "abc"); err != context.Canceled {
+		t.Errorf("should have returned the error as is. got: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResultCacheEntries is the maximum number of queries in the result cache.
+var maxResultCacheEntries = 1000
+
+// resultCacheEntry is the query ID of a result and its expiration.
+type resultCacheEntry struct {
+	queryID string
+	expires time.Time
+}
+
+// resultCache maps the read-only queries to the query IDs of their results, shared by the connections with the
+// result cache enabled.
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]resultCacheEntry
+}
+
+var defaultResultCache = &resultCache{entries: make(map[string]resultCacheEntry)}
+
+func (c *resultCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !getClock().Now().Before(e.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return e.queryID, true
+}
+
+func (c *resultCache) put(key string, queryID string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := getClock().Now()
+	if len(c.entries) >= maxResultCacheEntries {
+		// drops the expired entries, then the entry expiring first
+		oldest := ""
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			} else if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= maxResultCacheEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = resultCacheEntry{queryID: queryID, expires: now.Add(ttl)}
+}
+
+func (c *resultCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// isCacheableQuery returns true if the query is a single SELECT statement.
+func isCacheableQuery(query string) bool {
+	stmts := SplitStatements(query)
+	if len(stmts) != 1 {
+		return false
+	}
+	s := strings.ToUpper(stmts[0])
+	if strings.Contains(s, "RESULT_SCAN") {
+		return false
+	}
+	for _, keyword := range []string{"SELECT", "WITH"} {
+		if strings.HasPrefix(s, keyword) && (len(s) == len(keyword) || !isIdentifierChar(s[len(keyword)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '$'
+}
+
+// resultCacheKey returns the key of the query in the session context. The results are reused only by the same
+// user and role in the same database and schema.
+func (sc *snowflakeConn) resultCacheKey(query string, args []driver.NamedValue) string {
+	h := sha256.New()
+	sc.mu.Lock()
+	for _, s := range []string{sc.cfg.Host, sc.cfg.Account, sc.cfg.User, sc.cfg.Role, sc.cfg.Database, sc.cfg.Schema, query} {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	sc.mu.Unlock()
+	for _, arg := range args {
+		fmt.Fprintf(h, "%v:%v:%T:%v;", arg.Name, arg.Ordinal, arg.Value, arg.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// queryWithResultCache runs the query, or reads the result of the same query run within the TTL of the result
+// cache with RESULT_SCAN, which doesn't execute the query again. If the result is no longer available, e.g.,
+// it's purged after 24 hours, the query is run again.
+func (sc *snowflakeConn) queryWithResultCache(ctx context.Context, query string, args []driver.NamedValue) (*execResponse, error) {
+	ttl := sc.cfg.ResultCacheTTL
+	if ttl <= 0 || !isCacheableQuery(query) || isDescribeOnly(ctx) {
+		return sc.exec(ctx, query, false, false, args)
+	}
+	key := sc.resultCacheKey(query, args)
+	if queryID, ok := defaultResultCache.get(key); ok {
+		glog.V(2).Infof("reusing the result of %v", queryID)
+		data, err := sc.exec(ctx, fmt.Sprintf("SELECT * FROM TABLE(RESULT_SCAN('%v'))", queryID), false, false, nil)
+		if err == nil || ctx.Err() != nil {
+			return data, err
+		}
+		glog.V(1).Infof("failed to reuse the result of %v. running the query. err: %v", queryID, err)
+		defaultResultCache.remove(key)
+	}
+	data, err := sc.exec(ctx, query, false, false, args)
+	if err == nil && data.Data.QueryID != "" {
+		defaultResultCache.put(key, data.Data.QueryID, ttl)
+	}
+	return data, err
+}
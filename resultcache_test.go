@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsCacheableQuery(t *testing.T) {
+	testcases := []struct {
+		query     string
+		cacheable bool
+	}{
+		{query: "SELECT 1", cacheable: true},
+		{query: "  select * from t where id = ?", cacheable: true},
+		{query: "-- daily\nWITH x AS (SELECT 1) SELECT * FROM x", cacheable: true},
+		{query: "/* dashboard */ select 1;", cacheable: true},
+		{query: "SELECTED", cacheable: false},
+		{query: "INSERT INTO t SELECT 1", cacheable: false},
+		{query: "SELECT 1; DELETE FROM t", cacheable: false},
+		{query: "SELECT * FROM TABLE(RESULT_SCAN(LAST_QUERY_ID()))", cacheable: false},
+	}
+	for _, test := range testcases {
+		if isCacheableQuery(test.query) != test.cacheable {
+			t.Errorf("failed to detect the cacheable query. query: %v, expected: %v", test.query, test.cacheable)
+		}
+	}
+}
+
+func TestQueryWithResultCache(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+	defaultResultCache.entries = make(map[string]resultCacheEntry)
+
+	var sent []string
+	purged := false
+	sc := &snowflakeConn{
+		cfg: &Config{User: "u", Database: "DB", Schema: "PUBLIC", ResultCacheTTL: time.Minute},
+		rest: &snowflakeRestful{
+			FuncPostQuery: func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+				var req execRequest
+				if err := json.Unmarshal(body, &req); err != nil {
+					return nil, err
+				}
+				sent = append(sent, req.SQLText)
+				if purged && strings.Contains(req.SQLText, "RESULT_SCAN") {
+					return &execResponse{Success: false, Code: "2003", Message: "result no longer available"}, nil
+				}
+				resp := &execResponse{Success: true}
+				resp.Data.QueryID = fmt.Sprintf("q%v", len(sent))
+				resp.Data.FinalDatabaseName = "DB"
+				resp.Data.FinalSchemaName = "PUBLIC"
+				return resp, nil
+			},
+		},
+	}
+	query := func(q string, args ...driver.NamedValue) {
+		if _, err := sc.queryWithResultCache(context.Background(), q, args); err != nil {
+			t.Fatalf("failed to query. err: %v", err)
+		}
+	}
+	arg := func(v interface{}) driver.NamedValue {
+		return driver.NamedValue{Ordinal: 1, Value: v}
+	}
+	query("SELECT * FROM t WHERE id = ?", arg(int64(1)))
+	query("SELECT * FROM t WHERE id = ?", arg(int64(1)))
+	query("SELECT * FROM t WHERE id = ?", arg(int64(2)))
+	query("DELETE FROM t")
+	query("DELETE FROM t")
+	c.Sleep(time.Minute)
+	query("SELECT * FROM t WHERE id = ?", arg(int64(1)))
+	purged = true
+	query("SELECT * FROM t WHERE id = ?", arg(int64(1)))
+
+	expected := []string{
+		"SELECT * FROM t WHERE id = ?",
+		"SELECT * FROM TABLE(RESULT_SCAN('q1'))",
+		"SELECT * FROM t WHERE id = ?",
+		"DELETE FROM t",
+		"DELETE FROM t",
+		"SELECT * FROM t WHERE id = ?",
+		"SELECT * FROM TABLE(RESULT_SCAN('q6'))",
+		"SELECT * FROM t WHERE id = ?",
+	}
+	if strings.Join(sent, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("failed to reuse the results. expected: %v, got: %v", expected, sent)
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	defer func(n int) { maxResultCacheEntries = n }(maxResultCacheEntries)
+	maxResultCacheEntries = 2
+	c := &resultCache{entries: make(map[string]resultCacheEntry)}
+	c.put("a", "q1", time.Minute)
+	c.put("b", "q2", time.Hour)
+	c.put("c", "q3", time.Hour)
+	if _, ok := c.get("a"); ok {
+		t.Errorf("should have evicted the entry expiring first")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, ok := c.get(k); !ok {
+			t.Errorf("failed to keep the entry. key: %v", k)
+		}
+	}
+}
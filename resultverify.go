@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// queryResultFormatKey overrides the result format of a query by the query parameter.
+const queryResultFormatKey contextKey = "queryResultFormat"
+
+// verifyResult reads the result in Arrow and the same result in JSON by RESULT_SCAN, and returns
+// ErrResultFormatMismatch at the first value converted differently, if Config.ResultFormat is ResultFormatVerify.
+// The rows of the query are read from the result again after the verification.
+func (rows *snowflakeRows) verifyResult(data *execResponse) error {
+	sc := rows.sc
+	if sc == nil || sc.cfg == nil || sc.cfg.ResultFormat != ResultFormatVerify || rows.verifying || rows.arrowBatches ||
+		data.Data.QueryResultFormat != queryResultFormatArrow || isDescribeOnly(rows.ctx) {
+		return nil
+	}
+	queryID := data.Data.QueryID
+	if _, err := uuid.Parse(queryID); err != nil {
+		glog.V(1).Infof("skipped verifying the result of the invalid query ID: %v", queryID)
+		return nil
+	}
+	ctx := rows.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	arrowValues, err := sc.readResult(ctx, data)
+	if err != nil {
+		return err
+	}
+	jsonData, err := sc.exec(context.WithValue(ctx, queryResultFormatKey, "JSON"),
+		fmt.Sprintf("SELECT * FROM TABLE(RESULT_SCAN('%v'))", queryID), false, true, nil)
+	// the query ID of the connection is the query of the rows, not RESULT_SCAN
+	sc.mu.Lock()
+	sc.queryID = queryID
+	sc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	jsonValues, err := sc.readResult(ctx, jsonData)
+	if err != nil {
+		return err
+	}
+	mismatch := func(format string, args ...interface{}) error {
+		return &SnowflakeError{
+			Number:      ErrResultFormatMismatch,
+			QueryID:     queryID,
+			Message:     errMsgResultFormatMismatch,
+			MessageArgs: []interface{}{fmt.Sprintf(format, args...)},
+		}
+	}
+	if len(arrowValues) != len(jsonValues) {
+		return mismatch("rows in Arrow: %v, JSON: %v", len(arrowValues), len(jsonValues))
+	}
+	for i := range arrowValues {
+		for j, v := range arrowValues[i] {
+			if !sameValue(v, jsonValues[i][j]) {
+				return mismatch("row: %v, column: %v, Arrow: %v (%T), JSON: %v (%T)",
+					i+1, data.Data.RowType[j].Name, v, v, jsonValues[i][j], jsonValues[i][j])
+			}
+		}
+	}
+	glog.V(2).Infof("verified the result of %v rows in Arrow and JSON. query ID: %v", len(arrowValues), queryID)
+	return nil
+}
+
+// readResult reads all rows of the result with the settings of the connection.
+func (sc *snowflakeConn) readResult(ctx context.Context, data *execResponse) ([][]driver.Value, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	rows := sc.newRows(ctx, cancel)
+	rows.verifying = true
+	defer rows.Close()
+	if err := rows.setResult(data); err != nil {
+		return nil, err
+	}
+	var values [][]driver.Value
+	for {
+		dest := make([]driver.Value, len(data.Data.RowType))
+		if err := rows.Next(dest); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, dest)
+	}
+}
+
+// sameValue returns true if the values converted from the results in Arrow and JSON are the same.
+func sameValue(x, y driver.Value) bool {
+	if reflect.TypeOf(x) != reflect.TypeOf(y) {
+		return false
+	}
+	if t, ok := x.(time.Time); ok {
+		return t.Equal(y.(time.Time)) && t.Location().String() == y.(time.Time).Location().String()
+	}
+	return fmt.Sprint(x) == fmt.Sprint(y)
+}
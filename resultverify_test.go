@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultFormatVerify(t *testing.T) {
+	const queryID = "01a2b3c4-0000-0001-0000-000000000001"
+	rowType := []execResponseRowType{{Name: "C1", Type: "fixed"}, {Name: "C2", Type: "text", Nullable: true}}
+	arrowData := execResponseData{
+		QueryID:           queryID,
+		RowType:           rowType,
+		QueryResultFormat: queryResultFormatArrow,
+		RowSetBase64: base64.StdEncoding.EncodeToString(testArrowStream([]testArrowColumn{
+			arrowIntColumn("C1", 64, []int64{1, 2}),
+			arrowStringColumn("C2", arrowTypeUtf8, []string{"a", ""}, 1),
+		})),
+		Total: 2,
+	}
+	testcases := []struct {
+		name     string
+		format   ResultFormat
+		jsonRows [][]*string
+		queries  int
+		mismatch bool
+	}{
+		{name: "same", format: ResultFormatVerify, jsonRows: [][]*string{{sp("1"), sp("a")}, {sp("2"), nil}}, queries: 2},
+		{name: "value", format: ResultFormatVerify, jsonRows: [][]*string{{sp("1"), sp("a")}, {sp("2"), sp("b")}}, queries: 2, mismatch: true},
+		{name: "rows", format: ResultFormatVerify, jsonRows: [][]*string{{sp("1"), sp("a")}}, queries: 2, mismatch: true},
+		{name: "arrow", format: ResultFormatArrow, queries: 1},
+	}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			var queries []execRequest
+			sc := getDefaultSnowflakeConn()
+			sc.cfg.ResultFormat = test.format
+			sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+				var req execRequest
+				if err := json.Unmarshal(body, &req); err != nil {
+					return nil, err
+				}
+				queries = append(queries, req)
+				if strings.Contains(req.SQLText, "RESULT_SCAN") {
+					return &execResponse{Success: true, Data: execResponseData{
+						QueryID: "01a2b3c4-0000-0001-0000-000000000002",
+						RowType: rowType,
+						RowSet:  test.jsonRows,
+						Total:   int64(len(test.jsonRows)),
+					}}, nil
+				}
+				return &execResponse{Success: true, Data: arrowData}, nil
+			}
+			rows, err := sc.QueryContext(context.Background(), "SELECT c1, c2 FROM t", nil)
+			if len(queries) != test.queries {
+				t.Fatalf("failed to run the queries. expected: %v, got: %v", test.queries, len(queries))
+			}
+			if test.queries > 1 {
+				if q := queries[1]; q.SQLText != "SELECT * FROM TABLE(RESULT_SCAN('"+queryID+"'))" || q.Parameters[queryResultFormatParam] != "JSON" {
+					t.Errorf("failed to fetch the result in JSON. query: %v, parameters: %v", q.SQLText, q.Parameters)
+				}
+			}
+			if test.mismatch {
+				if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrResultFormatMismatch || driverErr.QueryID != queryID {
+					t.Fatalf("should have failed with the mismatch. err: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to query. err: %v", err)
+			}
+			defer rows.Close()
+			if sc.QueryID() != queryID {
+				t.Errorf("failed to keep the query ID of the query. got: %v", sc.QueryID())
+			}
+			dest := make([]driver.Value, 2)
+			n := 0
+			for rows.Next(dest) == nil {
+				n++
+			}
+			if n != 2 {
+				t.Errorf("failed to read the rows after the verification. rows: %v", n)
+			}
+		})
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	tokyo := time.FixedZone("Asia/Tokyo", 9*3600)
+	now := time.Now()
+	testcases := []struct {
+		x, y driver.Value
+		same bool
+	}{
+		{x: nil, y: nil, same: true},
+		{x: "1", y: "1", same: true},
+		{x: "1", y: int64(1), same: false},
+		{x: 1.5, y: 1.5, same: true},
+		{x: []byte{1}, y: []byte{1}, same: true},
+		{x: []byte{1}, y: []byte{2}, same: false},
+		{x: now, y: now.Round(0), same: true},
+		{x: now, y: now.In(tokyo), same: false},
+		{x: "a", y: nil, same: false},
+	}
+	for _, test := range testcases {
+		if sameValue(test.x, test.y) != test.same {
+			t.Errorf("failed to compare %v and %v. expected: %v", test.x, test.y, test.same)
+		}
+	}
+}
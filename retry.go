@@ -4,16 +4,19 @@ package gosnowflake
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
-	"context"
-
-	"sync"
+	"github.com/google/uuid"
 )
 
 var random *rand.Rand
@@ -58,9 +61,86 @@ type clientInterface interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// isRetryableHTTPCode returns true if the request failed with the HTTP status may succeed if retried. The other
+// 4XX are returned without retries.
+func isRetryableHTTPCode(code int) bool {
+	if code < 400 || code >= 500 {
+		return true
+	}
+	switch code {
+	case http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// isPermanentNetworkError returns true if the request failed with the network error that won't succeed if retried,
+// i.e., the host name isn't found or the certificate of the host is rejected.
+func isPermanentNetworkError(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *net.DNSError:
+			return !e.Temporary()
+		case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError,
+			*x509.UnknownAuthorityError, *x509.HostnameError, *x509.CertificateInvalidError:
+			return true
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// restRetryClient sends the requests of the Snowflake REST API with a new request_guid for every attempt, and the
+// number of the retries and the HTTP status of the last attempt, so that the server can tell a retry from a new
+// request. The requestId is kept, so a retried query is not run twice.
+type restRetryClient struct {
+	client      clientInterface
+	retryCount  int
+	retryReason int // HTTP status of the last attempt, or 0 if no response was returned
+}
+
+func (c *restRetryClient) Do(req *http.Request) (*http.Response, error) {
+	if req != nil {
+		// req can be nil in tests
+		q := req.URL.Query()
+		q.Set("request_guid", uuid.New().String())
+		if c.retryCount > 0 {
+			q.Set("retryCount", strconv.Itoa(c.retryCount))
+			q.Set("retryReason", strconv.Itoa(c.retryReason))
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	res, err := c.client.Do(req)
+	c.retryCount++
+	c.retryReason = 0
+	if err == nil {
+		c.retryReason = res.StatusCode
+	}
+	return res, err
+}
+
+// retryTimeout returns the timeout of the retries of a request capped by maxRetryDuration. 0 is unlimited.
+func retryTimeout(timeout time.Duration, maxRetryDuration time.Duration) time.Duration {
+	if maxRetryDuration > 0 && (timeout <= 0 || maxRetryDuration < timeout) {
+		return maxRetryDuration
+	}
+	return timeout
+}
+
+// retryHTTP sends the request, and retries it on the network errors and the retryable HTTP status with the
+// decorrelated jitter backoff until the timeout since the first attempt. 0 timeout is unlimited. The retries stop
+// with the error of ctx once ctx is done.
 func retryHTTP(
 	ctx context.Context,
 	client clientInterface,
+	pc *pacer,
 	req requestFunc,
 	method string,
 	fullURL string,
@@ -68,11 +148,14 @@ func retryHTTP(
 	body []byte,
 	timeout time.Duration,
 	raise4XX bool) (res *http.Response, err error) {
-	totalTimeout := timeout
-	glog.V(2).Infof("retryHTTP.totalTimeout: %v", totalTimeout)
+	glog.V(2).Infof("retryHTTP.totalTimeout: %v", timeout)
+	start := getClock().Now()
 	retryCounter := 0
 	sleepTime := time.Duration(0)
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		req, err := req(method, fullURL, bytes.NewReader(body))
 		if err != nil {
 			return nil, err
@@ -84,17 +167,33 @@ func retryHTTP(
 		for k, v := range headers {
 			req.Header.Set(k, v)
 		}
+		if err = pc.wait(ctx); err != nil {
+			return nil, err
+		}
 		res, err = client.Do(req)
-		if err == nil && res.StatusCode == http.StatusOK || err == context.Canceled {
-			// exit if success or canceled
+		if err == nil && res.StatusCode == http.StatusOK {
+			pc.succeeded()
 			break
 		}
-		if raise4XX && res != nil && res.StatusCode >= 400 && res.StatusCode < 500 {
+		if err != nil && isPermanentNetworkError(err) {
+			glog.V(2).Infof("failed http connection. err: %v. not retrying", err)
+			return nil, err
+		}
+		if cerr := ctx.Err(); cerr != nil {
+			// the client returns the error of ctx wrapped in *url.Error
+			if res != nil {
+				res.Body.Close()
+			}
+			return nil, cerr
+		}
+		throttled := err == nil && isThrottled(res)
+		if err == nil && !throttled && res.StatusCode >= 400 && res.StatusCode < 500 &&
+			(raise4XX || !isRetryableHTTPCode(res.StatusCode)) {
 			// abort connection if raise4XX flag is enabled and the range of HTTP status code are 4XX.
 			// This is currently used for Snowflake login. The caller must generate an error object based on HTTP status.
 			break
 		}
-		// cannot just return 4xx and 5xx status as the error can be sporadic. retry often helps.
+		// 5XX and some 4XX can be sporadic. retry often helps.
 		if err != nil {
 			glog.V(2).Infof(
 				"failed http connection. no response is returned. err: %v. retrying...\n", err)
@@ -104,24 +203,60 @@ func retryHTTP(
 		}
 		// uses decorrelated jitter backoff
 		sleepTime = defaultWaitAlgo.decorr(retryCounter, sleepTime)
+		if throttled {
+			// honor Retry-After and slow down the other requests of the session
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), getClock().Now()); ok {
+				sleepTime = retryAfter
+			}
+			glog.V(2).Infof("throttled. HTTP Status: %v. retry after: %v", res.StatusCode, sleepTime)
+			pc.throttled(sleepTime)
+		}
 
-		if totalTimeout > 0 {
-			glog.V(2).Infof("to timeout: %v", totalTimeout)
+		if timeout > 0 {
 			// if any timeout is set
-			totalTimeout -= sleepTime
-			if totalTimeout <= 0 {
-				if err != nil {
-					return nil, fmt.Errorf("timeout. err: %v. Hanging?", err)
-				}
-				if res != nil {
-					return nil, fmt.Errorf("timeout. HTTP Status: %v. Hanging?", res.StatusCode)
-				}
-				return nil, errors.New("timeout. Hanging?")
+			elapsed := getClock().Now().Sub(start)
+			glog.V(2).Infof("to timeout: %v", timeout-elapsed)
+			if elapsed+sleepTime >= timeout {
+				return nil, retryTimeoutError(res, err, retryCounter+1, elapsed, fullURL)
 			}
 		}
+		if res != nil {
+			res.Body.Close()
+		}
 		retryCounter++
-		glog.V(2).Infof("sleeping %v. to timeout: %v. retrying", sleepTime, totalTimeout)
-		time.Sleep(sleepTime)
+		addMetricsCounter(MetricRetries, 1)
+		glog.V(2).Infof("sleeping %v. retrying", sleepTime)
+		if err = sleepContext(ctx, sleepTime); err != nil {
+			return nil, err
+		}
 	}
 	return res, err
 }
+
+// retryTimeoutError returns the error of the request that failed the attempts until the timeout.
+func retryTimeoutError(res *http.Response, err error, attempts int, elapsed time.Duration, fullURL string) error {
+	if res != nil {
+		res.Body.Close()
+	}
+	if err == nil && isThrottled(res) {
+		return &SnowflakeError{
+			Number:      ErrTooManyRequests,
+			SQLState:    SQLStateConnectionFailure,
+			Message:     errMsgTooManyRequests,
+			MessageArgs: []interface{}{res.StatusCode, fullURL},
+			Attempts:    attempts,
+		}
+	}
+	reason := fmt.Sprintf("%v", err)
+	if err == nil {
+		reason = fmt.Sprintf("HTTP Status: %v", res.StatusCode)
+	}
+	return &SnowflakeError{
+		Number:      ErrRetryTimeout,
+		SQLState:    SQLStateConnectionFailure,
+		Message:     errMsgRetryTimeout,
+		MessageArgs: []interface{}{attempts, elapsed, reason},
+		Attempts:    attempts,
+		Err:         err,
+	}
+}
@@ -2,8 +2,13 @@ package gosnowflake
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -83,7 +88,7 @@ func TestRetry(t *testing.T) {
 		success: true,
 	}
 	_, err := retryHTTP(context.TODO(),
-		client,
+		client, nil,
 		fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 60*time.Second, false)
 	if err != nil {
 		t.Fatal("failed to run retry")
@@ -95,7 +100,7 @@ func TestRetry(t *testing.T) {
 		success: false,
 	}
 	_, err = retryHTTP(context.TODO(),
-		client,
+		client, nil,
 		fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 10*time.Second, false)
 	if err == nil {
 		t.Fatal("should fail to run retry")
@@ -108,7 +113,7 @@ func TestRetry(t *testing.T) {
 		timeout: true,
 	}
 	_, err = retryHTTP(context.TODO(),
-		client,
+		client, nil,
 		fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 60*time.Second, false)
 	if err != nil {
 		t.Fatal("failed to run retry")
@@ -120,9 +125,231 @@ func TestRetry(t *testing.T) {
 		timeout: true,
 	}
 	_, err = retryHTTP(context.TODO(),
-		client,
+		client, nil,
 		fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 10*time.Second, false)
 	if err == nil {
 		t.Fatal("should fail to run retry")
 	}
 }
+
+func TestUnitIsRetryableHTTPCode(t *testing.T) {
+	testcases := []struct {
+		code int
+		out  bool
+	}{
+		{code: 0, out: true},
+		{code: http.StatusBadRequest, out: false},
+		{code: http.StatusUnauthorized, out: false},
+		{code: http.StatusForbidden, out: true},
+		{code: http.StatusNotFound, out: false},
+		{code: http.StatusRequestTimeout, out: true},
+		{code: http.StatusTooManyRequests, out: true},
+		{code: http.StatusInternalServerError, out: true},
+		{code: http.StatusServiceUnavailable, out: true},
+	}
+	for _, test := range testcases {
+		if isRetryableHTTPCode(test.code) != test.out {
+			t.Errorf("failed to check retryable HTTP code. code: %v, expected: %v", test.code, test.out)
+		}
+	}
+}
+
+func TestUnitRetryTimeout(t *testing.T) {
+	testcases := []struct {
+		timeout  time.Duration
+		max      time.Duration
+		expected time.Duration
+	}{
+		{timeout: 0, max: 0, expected: 0},
+		{timeout: 60 * time.Second, max: 0, expected: 60 * time.Second},
+		{timeout: 0, max: 30 * time.Second, expected: 30 * time.Second},
+		{timeout: 60 * time.Second, max: 30 * time.Second, expected: 30 * time.Second},
+		{timeout: 10 * time.Second, max: 30 * time.Second, expected: 10 * time.Second},
+	}
+	for _, test := range testcases {
+		if d := retryTimeout(test.timeout, test.max); d != test.expected {
+			t.Errorf("failed to get the retry timeout. timeout: %v, max: %v, expected: %v, got: %v",
+				test.timeout, test.max, test.expected, d)
+		}
+	}
+}
+
+// urlRecordingHTTPClient records the URLs of the requests sent via client.
+type urlRecordingHTTPClient struct {
+	client clientInterface
+	urls   []*url.URL
+}
+
+func (c *urlRecordingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	c.urls = append(c.urls, &u)
+	return c.client.Do(req)
+}
+
+func TestRetryNonRetryable4XX(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	client := &urlRecordingHTTPClient{client: &throttlingHTTPClient{statuses: []int{404, 404}}}
+	res, err := retryHTTP(context.TODO(), client, nil,
+		http.NewRequest, "POST", "https://a.snowflakecomputing.com/queries/v1/query-request", nil, nil, 0, false)
+	if err != nil {
+		t.Fatalf("failed to return the response. err: %v", err)
+	}
+	if res.StatusCode != http.StatusNotFound || len(client.urls) != 1 {
+		t.Errorf("should have returned 404 without retries. HTTP: %v, attempts: %v", res.StatusCode, len(client.urls))
+	}
+}
+
+func TestRetryTimeoutAttempts(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	testcases := []struct {
+		client clientInterface
+		number int
+	}{
+		{client: &throttlingHTTPClient{statuses: []int{500, 500, 500, 500, 500, 500, 500, 500, 500, 500}}, number: ErrRetryTimeout},
+		{client: &fakeHTTPClient{cnt: 100}, number: ErrRetryTimeout},
+		{client: &throttlingHTTPClient{statuses: []int{429, 429, 429, 429}, retryAfter: "10"}, number: ErrTooManyRequests},
+	}
+	for _, test := range testcases {
+		c.sleeps = nil
+		_, err := retryHTTP(context.TODO(), test.client, &pacer{},
+			fakeRequestFunc, "POST", "", make(map[string]string), []byte{0}, 30*time.Second, false)
+		se, ok := err.(*SnowflakeError)
+		if !ok || se.Number != test.number {
+			t.Fatalf("failed to get the timeout error. expected: %v, err: %v", test.number, err)
+		}
+		if se.Attempts != len(c.sleeps)+1 {
+			t.Errorf("failed to get the attempts. expected: %v, got: %v", len(c.sleeps)+1, se.Attempts)
+		}
+		var total time.Duration
+		for _, d := range c.sleeps {
+			total += d
+		}
+		if total >= 30*time.Second {
+			t.Errorf("should have stopped retrying before the timeout. slept: %v", total)
+		}
+	}
+}
+
+func TestRestRetryClient(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	recorder := &urlRecordingHTTPClient{client: &throttlingHTTPClient{statuses: []int{503, 500}}}
+	res, err := retryHTTP(context.TODO(), &restRetryClient{client: recorder}, nil,
+		http.NewRequest, "POST", "https://a.snowflakecomputing.com/queries/v1/query-request?requestId=abc", nil, nil, 0, false)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("failed to retry. err: %v", err)
+	}
+	if len(recorder.urls) != 3 {
+		t.Fatalf("failed to retry. attempts: %v", len(recorder.urls))
+	}
+	guids := make(map[string]bool)
+	for i, u := range recorder.urls {
+		q := u.Query()
+		if q.Get("requestId") != "abc" {
+			t.Errorf("failed to keep the requestId. attempt: %v, got: %v", i, q.Get("requestId"))
+		}
+		guids[q.Get("request_guid")] = true
+		expectedCount, expectedReason := "", ""
+		if i > 0 {
+			expectedCount, expectedReason = strconv.Itoa(i), []string{"", "503", "500"}[i]
+		}
+		if q.Get("retryCount") != expectedCount || q.Get("retryReason") != expectedReason {
+			t.Errorf("failed to set the retry. attempt: %v, retryCount: %v, retryReason: %v",
+				i, q.Get("retryCount"), q.Get("retryReason"))
+		}
+	}
+	if len(guids) != 3 || guids[""] {
+		t.Errorf("failed to set a new request_guid for every attempt. got: %v", guids)
+	}
+}
+
+// cancelingHTTPClient cancels the context of the request, and fails as http.Client does.
+type cancelingHTTPClient struct {
+	cancel   context.CancelFunc
+	attempts int
+}
+
+func (c *cancelingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.attempts++
+	c.cancel()
+	return nil, &url.Error{Op: "Post", URL: req.URL.String(), Err: context.Canceled}
+}
+
+func TestRetryContextDone(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := &fakeHTTPClient{cnt: 1000}
+	if _, err := retryHTTP(canceled, client, nil,
+		fakeRequestFunc, "POST", "", nil, nil, 0, false); err != context.Canceled || client.cnt != 1000 {
+		t.Errorf("should have failed without sending the request. err: %v, attempts: %v", err, 1000-client.cnt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cc := &cancelingHTTPClient{cancel: cancel}
+	if _, err := retryHTTP(ctx, cc, nil, http.NewRequest, "POST", "https://a.snowflakecomputing.com/session",
+		nil, nil, 0, false); err != context.Canceled || cc.attempts != 1 {
+		t.Errorf("should have stopped retrying on the cancel. err: %v, attempts: %v", err, cc.attempts)
+	}
+
+	// the backoff of the system clock is interrupted by the deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := retryHTTP(ctx, &fakeHTTPClient{cnt: 1000}, nil,
+		fakeRequestFunc, "POST", "", nil, nil, 0, false); err != context.DeadlineExceeded {
+		t.Errorf("should have failed with the deadline. err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("should have stopped retrying at the deadline. elapsed: %v", elapsed)
+	}
+}
+
+func TestUnitIsPermanentNetworkError(t *testing.T) {
+	testcases := []struct {
+		err       error
+		permanent bool
+	}{
+		{err: &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "a.invalid", IsNotFound: true}}}, permanent: true},
+		{err: &url.Error{Op: "Post", Err: &net.DNSError{Err: "server misbehaving", Name: "a", IsTemporary: true}}, permanent: false},
+		{err: &url.Error{Op: "Post", Err: x509.UnknownAuthorityError{}}, permanent: true},
+		{err: &url.Error{Op: "Post", Err: x509.HostnameError{Host: "a"}}, permanent: true},
+		{err: &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}, permanent: false},
+		{err: &fakeHTTPError{err: "timeout", timeout: true}, permanent: false},
+	}
+	for _, test := range testcases {
+		if permanent := isPermanentNetworkError(test.err); permanent != test.permanent {
+			t.Errorf("failed to classify %v. expected: %v, got: %v", test.err, test.permanent, permanent)
+		}
+	}
+}
+
+type errorHTTPClient struct {
+	err      error
+	attempts int
+}
+
+func (c *errorHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.attempts++
+	return nil, c.err
+}
+
+func TestRetryPermanentNetworkError(t *testing.T) {
+	c := &sleepRecordingClock{now: time.Now()}
+	SetClock(c)
+	defer SetClock(nil)
+
+	client := &errorHTTPClient{err: &url.Error{Op: "Post", Err: &net.DNSError{Err: "no such host", Name: "a.invalid", IsNotFound: true}}}
+	if _, err := retryHTTP(context.Background(), client, nil,
+		fakeRequestFunc, "POST", "", nil, nil, 0, false); err != client.err || client.attempts != 1 {
+		t.Errorf("should have failed without retries. err: %v, attempts: %v", err, client.attempts)
+	}
+}
@@ -28,15 +28,146 @@ var (
 
 type snowflakeRows struct {
 	sc              *snowflakeConn
+	ctx             context.Context
 	RowType         []execResponseRowType
 	ChunkDownloader *snowflakeChunkDownloader
+	cancel          context.CancelFunc // releases the statement timeout, if any
+	invalidUTF8     InvalidUTF8Policy
+	location        *time.Location // location of DATE and TIMESTAMP_NTZ
+	ltzLocation     *time.Location // time zone of the session for TIMESTAMP_LTZ
+	geographyFormat GeoOutputFormat
+	geometryFormat  GeoOutputFormat
+	numberMode      NumberMode
+
+	columnNameCase              ColumnNameCase
+	quotedIdentifiersIgnoreCase bool
+
+	queryID   string   // query ID of the current result
+	fetched   int64    // rows read, recorded as MetricRowsFetched on Close
+	resultIDs []string // query IDs of the next results of a multi-statement query
+
+	arrowBatches bool          // the result is read by GetArrowBatches
+	verifying    bool          // the result is read to verify the result formats
+	result       *execResponse // current result in the Arrow batches mode
+}
+
+// setResult sets the result of the query to read the rows from.
+func (rows *snowflakeRows) setResult(data *execResponse) error {
+	if err := rows.verifyResult(data); err != nil {
+		return err
+	}
+	if rows.ChunkDownloader != nil {
+		rows.ChunkDownloader.close()
+	}
+	rows.queryID = data.Data.QueryID
+	rows.RowType = data.Data.RowType
+	ctx := rows.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	rows.ChunkDownloader = &snowflakeChunkDownloader{
+		sc:                 rows.sc,
+		ctx:                ctx,
+		cancel:             cancel,
+		queryID:            data.Data.QueryID,
+		ChunkMetas:         data.Data.Chunks,
+		Total:              int64(data.Data.Total),
+		TotalRowIndex:      int64(-1),
+		Qrmk:               data.Data.Qrmk,
+		ChunkHeader:        data.Data.ChunkHeaders,
+		RowType:            data.Data.RowType,
+		QueryResultFormat:  data.Data.QueryResultFormat,
+		FuncDownload:       downloadChunk,
+		FuncDownloadHelper: downloadChunkHelper,
+		FuncGet:            getChunk,
+	}
+	if rows.sc != nil && rows.sc.cfg != nil {
+		rows.ChunkDownloader.MaxWorkers = rows.sc.cfg.MaxChunkDownloadWorkers
+		rows.ChunkDownloader.MaxBufferBytes = rows.sc.cfg.MaxChunkBufferBytes
+		rows.ChunkDownloader.SpillDir = rows.sc.cfg.ChunkSpillDir
+	}
+	if rows.arrowBatches {
+		// the chunks are downloaded by ArrowBatch.Fetch
+		rows.result = data
+		return nil
+	}
+	chunk, err := firstChunk(data)
+	if err != nil {
+		return err
+	}
+	rows.ChunkDownloader.CurrentChunk = chunk
+	rows.ChunkDownloader.firstChunk = chunk
+	return rows.ChunkDownloader.start()
 }
 
 func (rows *snowflakeRows) Close() (err error) {
 	glog.V(2).Infoln("Rows.Close")
+	if rows.cancel != nil {
+		rows.cancel()
+	}
+	if rows.ChunkDownloader != nil {
+		rows.ChunkDownloader.close()
+	}
+	if rows.fetched > 0 {
+		addMetricsCounter(MetricRowsFetched, float64(rows.fetched))
+		rows.fetched = 0
+	}
 	return nil
 }
 
+// chunkRows is the rows of a chunk of the result set. The strings of a JSON chunk are converted by stringToValue as
+// the rows are read, while an Arrow chunk is decoded into the values of the rows.
+type chunkRows struct {
+	strings [][]*string
+	values  [][]driver.Value
+	arrow   []byte // Arrow stream of the values, kept until the chunk is stored to spill it
+}
+
+// jsonChunk returns the chunk of the rows in JSON.
+func jsonChunk(rows [][]*string) *chunkRows {
+	return &chunkRows{strings: rows}
+}
+
+func (c *chunkRows) len() int {
+	if c == nil {
+		return 0
+	}
+	if c.values != nil {
+		return len(c.values)
+	}
+	return len(c.strings)
+}
+
+func (c *chunkRows) row(i int) chunkRow {
+	if c.values != nil {
+		return chunkRow{values: c.values[i]}
+	}
+	return chunkRow{strings: c.strings[i]}
+}
+
+// chunkRow is a row of a chunk, either the strings of a JSON chunk or the values of an Arrow chunk.
+type chunkRow struct {
+	strings []*string
+	values  []driver.Value
+}
+
+func (r chunkRow) len() int {
+	if r.values != nil {
+		return len(r.values)
+	}
+	return len(r.strings)
+}
+
+// value sets the value of the column, converted from the string of a JSON chunk.
+func (r chunkRow) value(dest *driver.Value, i int, rowType execResponseRowType) error {
+	if r.values != nil {
+		*dest = r.values[i]
+		return nil
+	}
+	return stringToValue(dest, rowType, r.strings[i])
+}
+
 type chunkError struct {
 	Index int
 	Error error
@@ -45,34 +176,53 @@ type chunkError struct {
 type snowflakeChunkDownloader struct {
 	sc                 *snowflakeConn
 	ctx                context.Context
+	cancel             context.CancelFunc // cancels the downloads when the downloader is replaced by SeekRow
+	queryID            string
+	firstChunk         *chunkRows // rows returned in the query response, kept for SeekRow
+	skip               int        // rows skipped in the next chunk to read, set by SeekRow
 	Total              int64
 	TotalRowIndex      int64
-	CurrentChunk       [][]*string
+	CurrentChunk       *chunkRows
 	CurrentChunkIndex  int
 	CurrentChunkSize   int
 	ChunksMutex        *sync.Mutex
 	ChunkMetas         []execResponseChunk
-	Chunks             map[int][][]*string
+	Chunks             map[int]*chunkRows
 	ChunksChan         chan int
 	ChunksError        chan *chunkError
+	ChunksReady        chan int // indexes of the downloaded chunks
 	ChunksErrorCounter int
 	ChunksFinalErrors  []*chunkError
 	Qrmk               string
 	ChunkHeader        map[string]string
+	RowType            []execResponseRowType
+	QueryResultFormat  string
 	CurrentIndex       int
+	MaxWorkers         int    // chunks downloaded ahead of the current one. maxChunkDownloadWorkers if 0.
+	MaxBufferBytes     int64  // bytes of the chunks downloaded ahead held in memory. unlimited if 0.
+	SpillDir           string // directory where the chunks over MaxBufferBytes are spilled instead of deferred
 	FuncDownload       func(*snowflakeChunkDownloader, int)
 	FuncDownloadHelper func(context.Context, *snowflakeChunkDownloader, int)
 	FuncGet            func(context.Context, *snowflakeChunkDownloader, string, map[string]string, time.Duration) (*http.Response, error)
+
+	ahead         int            // chunks scheduled and not read
+	nextChunk     int            // index of the chunk scheduled next
+	bufferedBytes int64          // bytes of the chunks downloaded ahead, guarded by ChunksMutex
+	chunkBytes    map[int]int64  // bytes of each chunk counted in bufferedBytes, guarded by ChunksMutex
+	spilled       map[int]string // files of the spilled chunks, guarded by ChunksMutex
+	closed        bool           // the rows are closed or moved by SeekRow, guarded by ChunksMutex
+	downloaded    int            // chunks downloaded, guarded by ChunksMutex
 }
 
-// ColumnTypeDatabaseTypeName returns the database column name.
+// ColumnTypeDatabaseTypeName returns the Snowflake type of the column, e.g., FIXED, TEXT or TIMESTAMP_NTZ.
 func (rows *snowflakeRows) ColumnTypeDatabaseTypeName(index int) string {
 	return strings.ToUpper(rows.RowType[index].Type)
 }
 
-// ColumnTypeLength returns the length of the column
+// ColumnTypeLength returns the maximum length of the TEXT, BINARY and semi-structured columns, in characters for
+// TEXT and in bytes for the others.
 func (rows *snowflakeRows) ColumnTypeLength(index int) (length int64, ok bool) {
-	if index < 0 || index > len(rows.RowType) {
+	if index < 0 || index >= len(rows.RowType) {
 		return 0, false
 	}
 	switch rows.RowType[index].Type {
@@ -82,15 +232,17 @@ func (rows *snowflakeRows) ColumnTypeLength(index int) (length int64, ok bool) {
 	return 0, false
 }
 
+// ColumnTypeNullable returns true if the column may have NULL.
 func (rows *snowflakeRows) ColumnTypeNullable(index int) (nullable, ok bool) {
-	if index < 0 || index > len(rows.RowType) {
+	if index < 0 || index >= len(rows.RowType) {
 		return false, false
 	}
 	return rows.RowType[index].Nullable, true
 }
 
+// ColumnTypePrecisionScale returns the precision and scale of the NUMBER columns.
 func (rows *snowflakeRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
-	if index < 0 || index > len(rows.RowType) {
+	if index < 0 || index >= len(rows.RowType) {
 		return 0, 0, false
 	}
 	switch rows.RowType[index].Type {
@@ -104,81 +256,131 @@ func (rows *snowflakeRows) Columns() []string {
 	glog.V(3).Infoln("Rows.Columns")
 	ret := make([]string, len(rows.RowType))
 	for i, n := 0, len(rows.RowType); i < n; i++ {
-		ret[i] = rows.RowType[i].Name
+		ret[i] = convertColumnName(rows.columnNameCase, rows.RowType[i].Name, rows.quotedIdentifiersIgnoreCase)
 	}
 	return ret
 }
 
+// ColumnTypeScanType returns the Go type of the values of the column.
 func (rows *snowflakeRows) ColumnTypeScanType(index int) reflect.Type {
+	if f := rows.geoColumnFormat(index); f != "" {
+		return geoScanType(f)
+	}
+	if rows.RowType[index].Type == "fixed" {
+		return numberScanType(rows.numberMode, rows.RowType[index].Scale)
+	}
 	return snowflakeTypeToGo(rows.RowType[index].Type, rows.RowType[index].Scale)
 }
 
 func (rows *snowflakeRows) Next(dest []driver.Value) (err error) {
+	if rows.arrowBatches {
+		return &SnowflakeError{
+			Number:  ErrArrowBatchesOnly,
+			Message: errMsgArrowBatchesOnly,
+		}
+	}
 	row, err := rows.ChunkDownloader.Next()
 	if err != nil {
 		// includes io.EOF
 		if err == io.EOF {
 			rows.ChunkDownloader.Chunks = nil // detach all chunks. No way to go backward without reinitialize it.
+			return err
 		}
-		return err
+		if rows.ctx != nil {
+			err = queryTimeoutError(rows.ctx, err)
+		}
+		return withQueryID(err, rows.queryID)
 	}
-	for i, n := 0, len(row); i < n; i++ {
+	for i, n := 0, row.len(); i < n; i++ {
 		// could move to chunk downloader so that each go routine
 		// can convert data
-		err := stringToValue(&dest[i], rows.RowType[i], row[i])
+		err := row.value(&dest[i], i, rows.RowType[i])
 		if err != nil {
-			return err
+			return withQueryID(err, rows.queryID)
+		}
+		if tm, ok := dest[i].(time.Time); ok {
+			switch rows.RowType[i].Type {
+			case "date", "timestamp_ntz":
+				if rows.location != nil {
+					dest[i] = wallClockIn(tm, rows.location)
+				}
+			case "timestamp_ltz":
+				if rows.ltzLocation != nil {
+					dest[i] = tm.In(rows.ltzLocation)
+				}
+			}
+		}
+		if f := rows.geoColumnFormat(i); f != "" {
+			dest[i] = geoValue(f, dest[i])
+		}
+		if rows.RowType[i].Type == "fixed" {
+			if dest[i], err = numberValue(rows.numberMode, rows.RowType[i].Scale, dest[i]); err != nil {
+				return withQueryID(err, rows.queryID)
+			}
+		}
+		if v, ok := dest[i].(string); ok && rows.invalidUTF8 != "" {
+			if dest[i], err = applyInvalidUTF8Policy(rows.invalidUTF8, rows.RowType[i].Name, v); err != nil {
+				return withQueryID(err, rows.queryID)
+			}
 		}
 	}
+	rows.fetched++
 	return err
 }
 
+// QueryID returns the ID of the query of the current result. In a multi-statement query, it's the ID of the
+// statement whose result is read.
+func (rows *snowflakeRows) QueryID() string {
+	return rows.queryID
+}
+
+// HasNextResultSet returns true if the multi-statement query has the results of more statements.
 func (rows *snowflakeRows) HasNextResultSet() bool {
-	if len(rows.ChunkDownloader.ChunkMetas) == 0 {
-		return false // no extra chunk
-	}
-	return rows.ChunkDownloader.hasNextResultSet()
+	return len(rows.resultIDs) > 0
 }
 
+// NextResultSet moves to the result of the next statement of the multi-statement query. The chunks of a result
+// are read by Next.
 func (rows *snowflakeRows) NextResultSet() error {
-	if len(rows.ChunkDownloader.ChunkMetas) == 0 {
+	if len(rows.resultIDs) == 0 {
 		return io.EOF
 	}
-	return rows.ChunkDownloader.nextResultSet()
-}
-
-func (scd *snowflakeChunkDownloader) hasNextResultSet() bool {
-	return scd.CurrentChunkIndex < len(scd.ChunkMetas)
-}
-
-func (scd *snowflakeChunkDownloader) nextResultSet() error {
-	// no error at all times as the next chunk/resultset is automatically read
-	if scd.CurrentChunkIndex < len(scd.ChunkMetas) {
-		return nil
+	data, err := rows.sc.queryResult(rows.ctx, rows.resultIDs[0])
+	if err != nil {
+		return err
 	}
-	return io.EOF
+	rows.resultIDs = rows.resultIDs[1:]
+	return rows.setResult(data)
 }
 
 func (scd *snowflakeChunkDownloader) start() error {
-	scd.CurrentChunkSize = len(scd.CurrentChunk) // cache the size
-	scd.CurrentIndex = -1                        // initial chunks idx
-	scd.CurrentChunkIndex = -1                   // initial chunk
+	return scd.startAt(0)
+}
+
+// startAt starts downloading the chunks from the chunk of the index. The rows of CurrentChunk, if any, are read
+// before the chunk.
+func (scd *snowflakeChunkDownloader) startAt(first int) error {
+	scd.CurrentChunkSize = scd.CurrentChunk.len() // cache the size
+	scd.CurrentIndex = -1                         // initial chunks idx
+	scd.CurrentChunkIndex = first - 1             // initial chunk
 
 	// start downloading chunks if exists
 	chunkMetaLen := len(scd.ChunkMetas)
 	if chunkMetaLen > 0 {
-		glog.V(2).Infof("chunks: %v", chunkMetaLen)
+		glog.V(2).Infof("chunks: %v, workers: %v", chunkMetaLen, scd.workers())
 		scd.ChunksMutex = &sync.Mutex{}
-		scd.Chunks = make(map[int][][]*string)
+		scd.Chunks = make(map[int]*chunkRows)
 		scd.ChunksChan = make(chan int, chunkMetaLen)
-		scd.ChunksError = make(chan *chunkError, maxChunkDownloadWorkers)
-		for i := 0; i < chunkMetaLen; i++ {
+		scd.ChunksError = make(chan *chunkError, scd.workers())
+		scd.ChunksReady = make(chan int, chunkMetaLen)
+		for i := first; i < chunkMetaLen; i++ {
 			glog.V(2).Infof("add chunk to channel ChunksChan: %v", i+1)
 			scd.ChunksChan <- i
 		}
-		for i := 0; i < intMin(maxChunkDownloadWorkers, chunkMetaLen); i++ {
-			scd.schedule()
-		}
+		scd.chunkBytes = make(map[int]int64)
+		scd.nextChunk = first
+		// the other chunks are scheduled as the chunks are read, which bounds the chunks in memory.
+		scd.fill()
 	}
 	return nil
 }
@@ -187,6 +389,7 @@ func (scd *snowflakeChunkDownloader) schedule() {
 	select {
 	case nextIdx := <-scd.ChunksChan:
 		glog.V(2).Infof("schedule chunk: %v", nextIdx+1)
+		scd.reserve(nextIdx)
 		go scd.FuncDownload(scd, nextIdx)
 	default:
 		// no more download
@@ -194,30 +397,61 @@ func (scd *snowflakeChunkDownloader) schedule() {
 	}
 }
 
+// chunkReady notifies the reader waiting for a chunk that the chunk is downloaded.
+func (scd *snowflakeChunkDownloader) chunkReady(idx int) {
+	select {
+	case scd.ChunksReady <- idx:
+	default:
+		// the reader has notifications to check already
+	}
+}
+
 func (scd *snowflakeChunkDownloader) checkErrorRetry() (err error) {
 	select {
 	case errc := <-scd.ChunksError:
-		if scd.ChunksErrorCounter < maxChunkDownloaderErrorCounter && errc.Error != context.Canceled {
-			// add the index to the chunks channel so that the download will be retried.
-			go scd.FuncDownload(scd, errc.Index)
-			scd.ChunksErrorCounter++
-			glog.V(2).Infof("chunk idx: %v, err: %v. retrying (%v/%v)...",
-				errc.Index, errc.Error, scd.ChunksErrorCounter, maxChunkDownloaderErrorCounter)
-		} else {
-			scd.ChunksFinalErrors = append(scd.ChunksFinalErrors, errc)
-			glog.V(2).Infof("chunk idx: %v, err: %v. no further retry", errc.Index, errc.Error)
-			return errc.Error
-		}
+		return scd.retryChunk(errc)
 	default:
 		glog.V(2).Info("no error is detected.")
 	}
 	return nil
 }
-func (scd *snowflakeChunkDownloader) Next() ([]*string, error) {
+
+// retryChunk downloads the failed chunk again. It returns the error if the query is canceled, or if the retries
+// are exhausted and the rows before the chunk are read.
+func (scd *snowflakeChunkDownloader) retryChunk(errc *chunkError) error {
+	if errc.Error == context.Canceled || errc.Error == context.DeadlineExceeded {
+		scd.ChunksFinalErrors = append(scd.ChunksFinalErrors, errc)
+		return errc.Error
+	}
+	if scd.sc != nil {
+		scd.sc.telemetry.addChunkDownloadFailure(scd.queryID, errc.Index, errc.Error)
+	}
+	if scd.ChunksErrorCounter < maxChunkDownloaderErrorCounter {
+		go scd.FuncDownload(scd, errc.Index)
+		scd.ChunksErrorCounter++
+		glog.V(2).Infof("chunk idx: %v, err: %v. retrying (%v/%v)...",
+			errc.Index, errc.Error, scd.ChunksErrorCounter, maxChunkDownloaderErrorCounter)
+		return nil
+	}
+	scd.ChunksFinalErrors = append(scd.ChunksFinalErrors, errc)
+	glog.V(2).Infof("chunk idx: %v, err: %v. no further retry", errc.Index, errc.Error)
+	return scd.chunkError()
+}
+
+// chunkError returns the error of the chunk to read, or of a chunk before it, which failed for good.
+func (scd *snowflakeChunkDownloader) chunkError() error {
+	for _, errc := range scd.ChunksFinalErrors {
+		if errc.Index <= scd.CurrentChunkIndex {
+			return errc.Error
+		}
+	}
+	return nil
+}
+func (scd *snowflakeChunkDownloader) Next() (chunkRow, error) {
 	for {
 		scd.CurrentIndex++
 		if scd.CurrentIndex < scd.CurrentChunkSize {
-			return scd.CurrentChunk[scd.CurrentIndex], nil
+			return scd.CurrentChunk.row(scd.CurrentIndex), nil
 		}
 		scd.CurrentChunkIndex++ // next chunk
 		scd.CurrentIndex = -1   // reset
@@ -226,33 +460,43 @@ func (scd *snowflakeChunkDownloader) Next() ([]*string, error) {
 		}
 
 		scd.ChunksMutex.Lock()
-		if scd.CurrentChunkIndex > 1 {
-			scd.Chunks[scd.CurrentChunkIndex-1] = nil // detach the previously used chunk
+		err := scd.checkErrorRetry()
+		if err == nil {
+			err = scd.chunkError()
 		}
+		if err != nil {
+			scd.ChunksMutex.Unlock()
+			return chunkRow{}, err
+		}
+		delete(scd.Chunks, scd.CurrentChunkIndex-1) // detach the previously used chunk
 		scd.ChunksMutex.Unlock()
-
-		ticker := time.NewTicker(time.Second)
-		for range ticker.C {
-			scd.ChunksMutex.Lock()
-			err := scd.checkErrorRetry()
-			if err != nil {
-				scd.ChunksMutex.Unlock()
-				ticker.Stop()
-				return nil, err
-			}
+		if scd.CurrentChunk, err = scd.takeChunk(scd.CurrentChunkIndex); err != nil {
+			return chunkRow{}, err
+		}
+		for scd.CurrentChunk == nil {
 			glog.V(2).Infof("waiting for chunk idx: %v/%v",
 				scd.CurrentChunkIndex+1, len(scd.ChunkMetas))
-			scd.CurrentChunk = scd.Chunks[scd.CurrentChunkIndex]
-			scd.ChunksMutex.Unlock()
-			if scd.CurrentChunk != nil {
-				ticker.Stop()
-				// kick off the next download
-				glog.V(2).Infof("ready: chunk %v", scd.CurrentChunkIndex)
-				scd.CurrentChunkSize = len(scd.CurrentChunk)
-				scd.schedule()
-				break
+			select {
+			case errc := <-scd.ChunksError:
+				scd.ChunksMutex.Lock()
+				err := scd.retryChunk(errc)
+				scd.ChunksMutex.Unlock()
+				if err != nil {
+					return chunkRow{}, err
+				}
+			case <-scd.ChunksReady:
+			}
+			if scd.CurrentChunk, err = scd.takeChunk(scd.CurrentChunkIndex); err != nil {
+				return chunkRow{}, err
 			}
 		}
+		// kick off the next download
+		glog.V(2).Infof("ready: chunk %v", scd.CurrentChunkIndex)
+		scd.CurrentChunkSize = scd.CurrentChunk.len()
+		scd.CurrentIndex += scd.skip
+		scd.skip = 0
+		scd.release(scd.CurrentChunkIndex)
+		scd.fill()
 	}
 
 	glog.V(2).Infof("no more data")
@@ -260,7 +504,7 @@ func (scd *snowflakeChunkDownloader) Next() ([]*string, error) {
 		close(scd.ChunksError)
 		close(scd.ChunksChan)
 	}
-	return nil, io.EOF
+	return chunkRow{}, io.EOF
 }
 
 func getChunk(
@@ -270,9 +514,15 @@ func getChunk(
 	headers map[string]string,
 	timeout time.Duration) (
 	*http.Response, error) {
-	return retryHTTP(
-		ctx, scd.sc.rest.Client, http.NewRequest,
-		"GET", fullURL, headers, nil, timeout, false)
+	get := func(ctx context.Context) (*http.Response, error) {
+		return retryHTTP(
+			ctx, scd.sc.rest.Client, scd.sc.rest.pacer, http.NewRequest,
+			"GET", fullURL, headers, nil, timeout, false)
+	}
+	if scd.sc.cfg != nil && scd.sc.cfg.HedgeChunkDownloads {
+		return hedgedGet(ctx, chunkLatencies, get)
+	}
+	return get(ctx)
 }
 
 /* largeResultSetReader is a reader that wraps the large result set with leading and tailing brackets. */
@@ -308,6 +558,42 @@ func (r *largeResultSetReader) Read(p []byte) (n int, err error) {
 	return 0, io.EOF
 }
 
+// decodeJSONChunk decodes the chunk of result set in JSON, which is the rows without the enclosing brackets.
+func decodeJSONChunk(body io.Reader) ([][]*string, error) {
+	var respd [][]*string
+	st := &largeResultSetReader{
+		status: 0,
+		body:   body,
+	}
+	dec := json.NewDecoder(st)
+	for {
+		if err := dec.Decode(&respd); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return respd, nil
+}
+
+// sendError queues the error of the chunk download for the reader. The reader gets an error queued before if the
+// queue is full, so the error is dropped once the downloader is closed, instead of blocking the download forever.
+func (scd *snowflakeChunkDownloader) sendError(errc *chunkError) {
+	select {
+	case scd.ChunksError <- errc:
+		return
+	default:
+	}
+	if scd.ctx == nil {
+		scd.ChunksError <- errc
+		return
+	}
+	select {
+	case scd.ChunksError <- errc:
+	case <-scd.ctx.Done():
+	}
+}
+
 func downloadChunk(scd *snowflakeChunkDownloader, idx int) {
 	glog.V(2).Infof("download start chunk: %v", idx+1)
 
@@ -320,13 +606,48 @@ func downloadChunk(scd *snowflakeChunkDownloader, idx int) {
 
 	select {
 	case <-scd.ctx.Done():
-		scd.ChunksError <- &chunkError{Index: idx, Error: scd.ctx.Err()}
+		scd.sendError(&chunkError{Index: idx, Error: scd.ctx.Err()})
 	case <-execDownloadChan:
 	}
 }
 
 func downloadChunkHelper(ctx context.Context, scd *snowflakeChunkDownloader, idx int) {
+	body, err := scd.getChunkBody(ctx, idx)
+	if err != nil {
+		scd.sendError(&chunkError{Index: idx, Error: err})
+		return
+	}
+	defer body.Close()
+	var respd *chunkRows
+	if scd.QueryResultFormat == queryResultFormatArrow {
+		respd, err = readArrowChunk(body, scd.RowType)
+	} else {
+		var rowSet [][]*string
+		rowSet, err = decodeJSONChunk(body)
+		respd = jsonChunk(rowSet)
+	}
+	if err != nil {
+		glog.V(1).Infof(
+			"failed to extract HTTP response body. URL: %v, err: %v", scd.ChunkMetas[idx].URL, err)
+		glog.Flush()
+		scd.sendError(&chunkError{Index: idx, Error: err})
+		return
+	}
+	if err = scd.storeChunk(idx, respd); err != nil {
+		glog.V(1).Infof("failed to spill chunk: %v, err: %v", idx+1, err)
+		scd.sendError(&chunkError{Index: idx, Error: err})
+		return
+	}
+	scd.ChunksMutex.Lock()
+	scd.downloaded++
+	downloaded := scd.downloaded
+	scd.ChunksMutex.Unlock()
+	reportChunkProgress(ctx, ChunkProgress{
+		QueryID: scd.queryID, Chunks: downloaded, Total: len(scd.ChunkMetas), Rows: respd.len()})
+}
 
+// getChunkBody downloads the chunk and returns the response body.
+func (scd *snowflakeChunkDownloader) getChunkBody(ctx context.Context, idx int) (io.ReadCloser, error) {
 	headers := make(map[string]string)
 	if len(scd.ChunkHeader) > 0 {
 		glog.V(2).Info("chunk header is provided.")
@@ -340,51 +661,27 @@ func downloadChunkHelper(ctx context.Context, scd *snowflakeChunkDownloader, idx
 
 	resp, err := scd.FuncGet(ctx, scd, scd.ChunkMetas[idx].URL, headers, 0)
 	if err != nil {
-		scd.ChunksError <- &chunkError{Index: idx, Error: err}
-		return
+		return nil, err
 	}
-	defer resp.Body.Close()
 	glog.V(2).Infof("download finish chunk: %v, resp: %v", idx+1, resp)
 	if resp.StatusCode == http.StatusOK {
-		var respd [][]*string
-		st := &largeResultSetReader{
-			status: 0,
-			body:   resp.Body,
-		}
-		dec := json.NewDecoder(st)
-		for {
-			if err := dec.Decode(&respd); err == io.EOF {
-				break
-			} else if err != nil {
-				glog.V(1).Infof(
-					"failed to extract HTTP response body. URL: %v, err: %v", scd.ChunkMetas[idx].URL, err)
-				glog.Flush()
-				scd.ChunksError <- &chunkError{Index: idx, Error: err}
-				return
-			}
-		}
-		scd.ChunksMutex.Lock()
-		scd.Chunks[idx] = respd
-		scd.ChunksMutex.Unlock()
-	} else {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			glog.V(1).Infof(
-				"failed to extract HTTP response body. URL: %v, err: %v", scd.ChunkMetas[idx].URL, err)
-			glog.Flush()
-			scd.ChunksError <- &chunkError{Index: idx, Error: err}
-			return
-		}
-		glog.V(1).Infof("HTTP: %v, URL: %v, Body: %v", resp.StatusCode, scd.ChunkMetas[idx].URL, b)
-		glog.V(1).Infof("Header: %v", resp.Header)
+		return downloadCounter{resp.Body}, nil
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		glog.V(1).Infof(
+			"failed to extract HTTP response body. URL: %v, err: %v", scd.ChunkMetas[idx].URL, err)
 		glog.Flush()
-		scd.ChunksError <- &chunkError{
-			Index: idx,
-			Error: &SnowflakeError{
-				Number:      ErrFailedToGetChunk,
-				SQLState:    SQLStateConnectionFailure,
-				Message:     errMsgFailedToGetChunk,
-				MessageArgs: []interface{}{idx},
-			}}
+		return nil, err
+	}
+	glog.V(1).Infof("HTTP: %v, URL: %v, Body: %v", resp.StatusCode, scd.ChunkMetas[idx].URL, b)
+	glog.V(1).Infof("Header: %v", resp.Header)
+	glog.Flush()
+	return nil, &SnowflakeError{
+		Number:      ErrFailedToGetChunk,
+		SQLState:    SQLStateConnectionFailure,
+		Message:     errMsgFailedToGetChunk,
+		MessageArgs: []interface{}{idx},
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -37,7 +38,7 @@ func TestRowsWithoutChunkDownloader(t *testing.T) {
 	rows.ChunkDownloader = &snowflakeChunkDownloader{
 		sc:                 nil,
 		ctx:                context.Background(),
-		CurrentChunk:       cc,
+		CurrentChunk:       jsonChunk(cc),
 		Total:              int64(len(cc)),
 		ChunkMetas:         cm,
 		TotalRowIndex:      int64(-1),
@@ -76,7 +77,8 @@ func downloadChunkTest(scd *snowflakeChunkDownloader, idx int) {
 		d = append(d, []*string{&v1, &v2})
 	}
 	scd.ChunksMutex.Lock()
-	scd.Chunks[idx] = d
+	scd.Chunks[idx] = jsonChunk(d)
+	scd.chunkReady(idx)
 	scd.ChunksMutex.Unlock()
 }
 
@@ -107,7 +109,7 @@ func TestRowsWithChunkDownloader(t *testing.T) {
 	rows.ChunkDownloader = &snowflakeChunkDownloader{
 		sc:            nil,
 		ctx:           context.Background(),
-		CurrentChunk:  cc,
+		CurrentChunk:  jsonChunk(cc),
 		Total:         int64(len(cc) + numChunks*rowsInChunk),
 		ChunkMetas:    cm,
 		TotalRowIndex: int64(-1),
@@ -155,7 +157,8 @@ func downloadChunkTestError(scd *snowflakeChunkDownloader, idx int) {
 		v2 := fmt.Sprintf("testchunk%v", idx*1000+i)
 		d = append(d, []*string{&v1, &v2})
 	}
-	scd.Chunks[idx] = d
+	scd.Chunks[idx] = jsonChunk(d)
+	scd.chunkReady(idx)
 }
 
 func TestRowsWithChunkDownloaderError(t *testing.T) {
@@ -185,7 +188,7 @@ func TestRowsWithChunkDownloaderError(t *testing.T) {
 	rows.ChunkDownloader = &snowflakeChunkDownloader{
 		sc:            nil,
 		ctx:           context.Background(),
-		CurrentChunk:  cc,
+		CurrentChunk:  jsonChunk(cc),
 		Total:         int64(len(cc) + numChunks*rowsInChunk),
 		ChunkMetas:    cm,
 		TotalRowIndex: int64(-1),
@@ -233,7 +236,8 @@ func downloadChunkTestErrorFail(scd *snowflakeChunkDownloader, idx int) {
 		v2 := fmt.Sprintf("testchunk%v", idx*1000+i)
 		d = append(d, []*string{&v1, &v2})
 	}
-	scd.Chunks[idx] = d
+	scd.Chunks[idx] = jsonChunk(d)
+	scd.chunkReady(idx)
 }
 
 func TestRowsWithChunkDownloaderErrorFail(t *testing.T) {
@@ -261,7 +265,7 @@ func TestRowsWithChunkDownloaderErrorFail(t *testing.T) {
 	rows.ChunkDownloader = &snowflakeChunkDownloader{
 		sc:            nil,
 		ctx:           context.Background(),
-		CurrentChunk:  cc,
+		CurrentChunk:  jsonChunk(cc),
 		Total:         int64(len(cc) + numChunks*rowsInChunk),
 		ChunkMetas:    cm,
 		TotalRowIndex: int64(-1),
@@ -278,11 +282,10 @@ func TestRowsWithChunkDownloaderErrorFail(t *testing.T) {
 			break
 		}
 		if err != nil {
-			if cnt != 715 {
+			// the rows before the failed chunk are returned
+			if cnt != len(cc)+6*rowsInChunk {
 				t.Fatalf("failed to get value. cnt: %v, err: %v", cnt, err)
 			}
-			glog.V(2).Infof(
-				"failure was expected by the number of rows is wrong. expected: %v, got: %v", 715, cnt)
 			break
 		}
 		// fmt.Printf("data: %v\n", dest)
@@ -315,7 +318,7 @@ func TestDownloadChunkInvalidResponseBody(t *testing.T) {
 		FuncGet:            getChunkTestInvalidResponseBody,
 	}
 	scd.ChunksMutex = &sync.Mutex{}
-	scd.Chunks = make(map[int][][]*string)
+	scd.Chunks = make(map[int]*chunkRows)
 	scd.ChunksError = make(chan *chunkError, 1)
 	scd.FuncDownload(scd, 1)
 	select {
@@ -353,7 +356,7 @@ func TestDownloadChunkErrorStatus(t *testing.T) {
 		FuncGet:            getChunkTestErrorStatus,
 	}
 	scd.ChunksMutex = &sync.Mutex{}
-	scd.Chunks = make(map[int][][]*string)
+	scd.Chunks = make(map[int]*chunkRows)
 	scd.ChunksError = make(chan *chunkError, 1)
 	scd.FuncDownload(scd, 1)
 	select {
@@ -372,3 +375,120 @@ func TestDownloadChunkErrorStatus(t *testing.T) {
 		t.Fatal("should have caused an error and queued in scd.ChunksError")
 	}
 }
+
+func TestRowsWithChunkDownloaderMaxWorkers(t *testing.T) {
+	numChunks := 12
+	cm := make([]execResponseChunk, 0)
+	for i := 0; i < numChunks; i++ {
+		cm = append(cm, execResponseChunk{URL: fmt.Sprintf("dummyURL%v", i+1), RowCount: rowsInChunk})
+	}
+	started := make(chan int, numChunks)
+	rows := new(snowflakeRows)
+	rows.RowType = []execResponseRowType{
+		{Name: "c1", ByteLength: 10, Length: 10, Type: "FIXED", Scale: 0, Nullable: true},
+		{Name: "c2", ByteLength: 100000, Length: 100000, Type: "TEXT", Scale: 0, Nullable: false},
+	}
+	rows.ChunkDownloader = &snowflakeChunkDownloader{
+		ctx:           context.Background(),
+		Total:         int64(numChunks * rowsInChunk),
+		ChunkMetas:    cm,
+		TotalRowIndex: int64(-1),
+		MaxWorkers:    3,
+		FuncDownload: func(scd *snowflakeChunkDownloader, idx int) {
+			started <- idx
+			downloadChunkTest(scd, idx)
+		},
+	}
+	rows.ChunkDownloader.start()
+	time.Sleep(50 * time.Millisecond)
+	if len(started) != 3 {
+		t.Fatalf("failed to limit the chunks downloaded ahead. expected: 3, got: %v", len(started))
+	}
+	cnt := 0
+	dest := make([]driver.Value, 2)
+	for {
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to get value. err: %v", err)
+		}
+		cnt++
+	}
+	if cnt != numChunks*rowsInChunk {
+		t.Fatalf("failed to get all results. expected: %v, got: %v", numChunks*rowsInChunk, cnt)
+	}
+	if len(started) != numChunks {
+		t.Fatalf("failed to download all chunks. expected: %v, got: %v", numChunks, len(started))
+	}
+
+	sc := getDefaultSnowflakeConn()
+	sc.cfg.MaxChunkDownloadWorkers = 7
+	rows = &snowflakeRows{sc: sc, ctx: context.Background()}
+	if err := rows.setResult(&execResponse{}); err != nil {
+		t.Fatalf("failed to set the result. err: %v", err)
+	}
+	if rows.ChunkDownloader.MaxWorkers != 7 {
+		t.Errorf("failed to set the workers of the connection. expected: 7, got: %v", rows.ChunkDownloader.MaxWorkers)
+	}
+}
+
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = &snowflakeRows{}
+	_ driver.RowsColumnTypeLength           = &snowflakeRows{}
+	_ driver.RowsColumnTypeNullable         = &snowflakeRows{}
+	_ driver.RowsColumnTypePrecisionScale   = &snowflakeRows{}
+	_ driver.RowsColumnTypeScanType         = &snowflakeRows{}
+)
+
+func TestUnitColumnTypes(t *testing.T) {
+	rows := &snowflakeRows{RowType: []execResponseRowType{
+		{Name: "ID", Type: "fixed", Precision: 38, Scale: 0},
+		{Name: "NAME", Type: "text", Length: 16777216, ByteLength: 16777216, Nullable: true},
+		{Name: "DOC", Type: "variant", Length: 16777216, Nullable: true},
+		{Name: "TS", Type: "timestamp_ntz", Scale: 9},
+		{Name: "B", Type: "binary", Length: 8388608},
+	}}
+	testcases := []struct {
+		typ       string
+		scanType  reflect.Type
+		length    int64
+		lengthOK  bool
+		nullable  bool
+		precision int64
+		scale     int64
+		decimalOK bool
+	}{
+		{typ: "FIXED", scanType: reflect.TypeOf(int64(0)), precision: 38, decimalOK: true},
+		{typ: "TEXT", scanType: reflect.TypeOf(""), length: 16777216, lengthOK: true, nullable: true},
+		{typ: "VARIANT", scanType: reflect.TypeOf(""), length: 16777216, lengthOK: true, nullable: true},
+		{typ: "TIMESTAMP_NTZ", scanType: reflect.TypeOf(time.Time{})},
+		{typ: "BINARY", scanType: reflect.TypeOf([]byte{}), length: 8388608, lengthOK: true},
+	}
+	for i, test := range testcases {
+		if typ := rows.ColumnTypeDatabaseTypeName(i); typ != test.typ {
+			t.Errorf("failed to get the database type. column: %v, expected: %v, got: %v", i, test.typ, typ)
+		}
+		if st := rows.ColumnTypeScanType(i); st != test.scanType {
+			t.Errorf("failed to get the scan type. column: %v, expected: %v, got: %v", i, test.scanType, st)
+		}
+		if l, ok := rows.ColumnTypeLength(i); l != test.length || ok != test.lengthOK {
+			t.Errorf("failed to get the length. column: %v, expected: %v %v, got: %v %v", i, test.length, test.lengthOK, l, ok)
+		}
+		if n, ok := rows.ColumnTypeNullable(i); n != test.nullable || !ok {
+			t.Errorf("failed to get the nullability. column: %v, expected: %v, got: %v %v", i, test.nullable, n, ok)
+		}
+		if p, s, ok := rows.ColumnTypePrecisionScale(i); p != test.precision || s != test.scale || ok != test.decimalOK {
+			t.Errorf("failed to get the precision and scale. column: %v, expected: %v %v %v, got: %v %v %v",
+				i, test.precision, test.scale, test.decimalOK, p, s, ok)
+		}
+	}
+	n := len(testcases)
+	if _, ok := rows.ColumnTypeLength(n); ok {
+		t.Error("should have failed to get the length of no column")
+	}
+	if _, ok := rows.ColumnTypeNullable(n); ok {
+		t.Error("should have failed to get the nullability of no column")
+	}
+}
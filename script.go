@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// SplitStatements splits a SQL script into statements separated by semicolons. Semicolons in string literals,
+// quoted identifiers, $$ delimited bodies of stored procedures and UDFs, and --, // and /* */ comments don't
+// separate statements. Comments outside of the statements are removed, while the comments in the statements, e.g.,
+// optimizer hints, are kept.
+func SplitStatements(script string) []string {
+	var ret []string
+	kinds := scanSQL(script)
+	start := 0
+	flush := func(end int) {
+		first, last := -1, -1
+		for i := start; i < end; i++ {
+			if kinds[i] != sqlComment && !isSpace(script[i]) {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first >= 0 {
+			ret = append(ret, strings.TrimSpace(script[first:last+1]))
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(script); i++ {
+		if kinds[i] == sqlCode && script[i] == ';' {
+			flush(i)
+		}
+	}
+	flush(len(script))
+	return ret
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// ScriptError is the error of a statement in a script.
+type ScriptError struct {
+	Index     int // zero based index of the statement in the script
+	Statement string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement #%v failed: %v. statement: %v", e.Index+1, e.Err, e.Statement)
+}
+
+// ScriptErrors are the errors of the statements in a script run with ContinueOnError.
+type ScriptErrors []*ScriptError
+
+func (e ScriptErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, se := range e {
+		msgs[i] = se.Error()
+	}
+	return fmt.Sprintf("%v statement(s) failed: %v", len(e), strings.Join(msgs, "; "))
+}
+
+// ScriptRunner executes the statements of a SQL script sequentially.
+type ScriptRunner struct {
+	DB              Execer
+	ContinueOnError bool // if true, run the remaining statements after a failure
+	// OnStatement is called after each statement is executed, if not nil.
+	OnStatement func(index int, stmt string, result sql.Result, err error)
+}
+
+// Run executes the statements read from r. If a statement fails, Run stops and returns a *ScriptError,
+// or, with ContinueOnError, runs all statements and returns ScriptErrors.
+func (r *ScriptRunner) Run(ctx context.Context, in io.Reader) error {
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	var errs ScriptErrors
+	for i, stmt := range SplitStatements(string(b)) {
+		res, err := r.DB.ExecContext(ctx, stmt)
+		if r.OnStatement != nil {
+			r.OnStatement(i, stmt, res, err)
+		}
+		if err == nil {
+			continue
+		}
+		se := &ScriptError{Index: i, Statement: stmt, Err: err}
+		if !r.ContinueOnError || ctx.Err() != nil {
+			return se
+		}
+		errs = append(errs, se)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// RunFile executes the statements in the SQL file.
+func (r *ScriptRunner) RunFile(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Run(ctx, f)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+type tcSplitStatements struct {
+	script string
+	out    []string
+}
+
+func TestSplitStatements(t *testing.T) {
+	testcases := []tcSplitStatements{
+		{script: "", out: nil},
+		{script: "select 1;select 2;", out: []string{"select 1", "select 2"}},
+		{script: "select ';'; select \"a;b\"", out: []string{"select ';'", "select \"a;b\""}},
+		{script: "select 'it\\'s;'", out: []string{"select 'it\\'s;'"}},
+		{script: "-- c;\nselect 1; /* ; */ select 2", out: []string{"select 1", "select 2"}},
+		{script: "// c;\nselect 1 // x;\n;put file:///tmp/a;b.csv @~", out: []string{"select 1", "put file:///tmp/a", "b.csv @~"}},
+		{script: "SELECT a/* c */FROM t; SELECT 2", out: []string{"SELECT a/* c */FROM t", "SELECT 2"}},
+		{script: "/* c */ select /*+ hint */ 1 -- x\n, 2 -- y\n; -- z", out: []string{"select /*+ hint */ 1 -- x\n, 2"}},
+		{
+			script: "create function f() returns int language javascript as $$ var a = 1; return a; $$;\nselect f();",
+			out:    []string{"create function f() returns int language javascript as $$ var a = 1; return a; $$", "select f()"},
+		},
+	}
+	for _, test := range testcases {
+		out := SplitStatements(test.script)
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("failed to split statements. script: %q, expected: %q, got: %q", test.script, test.out, out)
+		}
+	}
+}
+
+func TestScriptRunner(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	script := "insert into t values(1); insert into t values(2); insert into t values(3);"
+
+	mock.ExpectExec("values\\(1\\)").WillReturnResult(1)
+	mock.ExpectExec("values\\(2\\)").WillReturnError(errors.New("boom"))
+	r := &ScriptRunner{DB: db}
+	err = r.Run(context.Background(), strings.NewReader(script))
+	if se, ok := err.(*ScriptError); !ok || se.Index != 1 || se.Err.Error() != "boom" {
+		t.Errorf("should have stopped at the second statement. err: %v", err)
+	}
+
+	mock.ExpectExec("values\\(1\\)").WillReturnResult(1)
+	mock.ExpectExec("values\\(2\\)").WillReturnError(errors.New("boom"))
+	mock.ExpectExec("values\\(3\\)").WillReturnResult(1)
+	executed := 0
+	r = &ScriptRunner{
+		DB:              db,
+		ContinueOnError: true,
+		OnStatement: func(index int, stmt string, result sql.Result, err error) {
+			executed++
+		},
+	}
+	err = r.Run(context.Background(), strings.NewReader(script))
+	if errs, ok := err.(ScriptErrors); !ok || len(errs) != 1 || errs[0].Index != 1 {
+		t.Errorf("should have one failed statement. err: %v", err)
+	}
+	if executed != 3 {
+		t.Errorf("all statements should have been executed. executed: %v", executed)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/internal/sigv4"
+)
+
+// AWSSecretsManager provides the credentials in a secret of AWS Secrets Manager. The request is signed with the
+// AWS credentials in the environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// unless they are set in the fields.
+type AWSSecretsManager struct {
+	SecretID        string        // name or ARN of the secret
+	VersionStage    string        // AWSCURRENT if empty
+	Region          string        // AWS_REGION or AWS_DEFAULT_REGION if empty
+	Endpoint        string        // https://secretsmanager.<region>.amazonaws.com if empty
+	AccessKeyID     string        // AWS_ACCESS_KEY_ID if empty
+	SecretAccessKey string        // AWS_SECRET_ACCESS_KEY if empty
+	SessionToken    string        // AWS_SESSION_TOKEN if empty and AccessKeyID is empty
+	RefreshInterval time.Duration // DefaultRefreshInterval if 0
+	Client          *http.Client  // http.Client with 30 seconds timeout if nil
+
+	cache cache
+}
+
+// Name returns the provider name.
+func (p *AWSSecretsManager) Name() string {
+	return "aws-secrets-manager"
+}
+
+// Retrieve returns the cached credentials or gets the secret value.
+func (p *AWSSecretsManager) Retrieve(ctx context.Context, cfg *sf.Config) (*sf.Credentials, error) {
+	return p.cache.get(ctx, p.RefreshInterval, p.fetch)
+}
+
+// InvalidateCredentials drops the cached credentials.
+func (p *AWSSecretsManager) InvalidateCredentials() {
+	p.cache.invalidate()
+}
+
+func (p *AWSSecretsManager) fetch(ctx context.Context) (*sf.Credentials, time.Duration, error) {
+	accessKey, secretKey, sessionToken := p.AccessKeyID, p.SecretAccessKey, p.SessionToken
+	if accessKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, 0, fmt.Errorf("AWS credentials are not set")
+	}
+	region := p.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, 0, fmt.Errorf("AWS_REGION is not set")
+	}
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%v.amazonaws.com", region)
+	}
+	stage := p.VersionStage
+	if stage == "" {
+		stage = "AWSCURRENT"
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": p.SecretID, "VersionStage": stage})
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequest("POST", endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awsCreds := sigv4.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey, SessionToken: sessionToken}
+	sigv4.Sign(req, awsCreds, region, "secretsmanager", sigv4.PayloadHash(body), now())
+	var respd struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err = doJSON(p.Client, req, &respd); err != nil {
+		return nil, 0, err
+	}
+	creds, err := parseSecretJSON([]byte(respd.SecretString))
+	return creds, 0, err
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// GCPSecretManager provides the credentials in a secret version of GCP Secret Manager. The request is authorized
+// with the access token of the service account attached to the GCP resource, from the metadata server, unless
+// TokenSource is set.
+type GCPSecretManager struct {
+	Project         string                                    // project ID or number
+	Secret          string                                    // secret ID
+	Version         string                                    // latest if empty
+	Endpoint        string                                    // https://secretmanager.googleapis.com if empty
+	TokenSource     func(ctx context.Context) (string, error) // access token of the metadata server if nil
+	RefreshInterval time.Duration                             // DefaultRefreshInterval if 0
+	Client          *http.Client                              // http.Client with 30 seconds timeout if nil
+
+	cache cache
+}
+
+// Name returns the provider name.
+func (p *GCPSecretManager) Name() string {
+	return "gcp-secret-manager"
+}
+
+// Retrieve returns the cached credentials or accesses the secret version.
+func (p *GCPSecretManager) Retrieve(ctx context.Context, cfg *sf.Config) (*sf.Credentials, error) {
+	return p.cache.get(ctx, p.RefreshInterval, p.fetch)
+}
+
+// InvalidateCredentials drops the cached credentials.
+func (p *GCPSecretManager) InvalidateCredentials() {
+	p.cache.invalidate()
+}
+
+func (p *GCPSecretManager) fetch(ctx context.Context) (*sf.Credentials, time.Duration, error) {
+	tokenSource := p.TokenSource
+	if tokenSource == nil {
+		tokenSource = p.metadataToken
+	}
+	token, err := tokenSource(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretmanager.googleapis.com"
+	}
+	version := p.Version
+	if version == "" {
+		version = "latest"
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%v/v1/projects/%v/secrets/%v/versions/%v:access",
+		endpoint, p.Project, p.Secret, version), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	var respd struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err = doJSON(p.Client, req, &respd); err != nil {
+		return nil, 0, err
+	}
+	data, err := base64.StdEncoding.DecodeString(respd.Payload.Data)
+	if err != nil {
+		return nil, 0, err
+	}
+	creds, err := parseSecretJSON(data)
+	return creds, 0, err
+}
+
+// metadataToken gets the access token of the default service account from the metadata server.
+func (p *GCPSecretManager) metadataToken(ctx context.Context) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequest("GET",
+		"http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata-Flavor", "Google")
+	var respd struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = doJSON(p.Client, req, &respd); err != nil {
+		return "", err
+	}
+	return respd.AccessToken, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package secrets provides gosnowflake.CredentialProviders that read the credentials from AWS Secrets Manager,
+// GCP Secret Manager and HashiCorp Vault over their REST APIs, without the cloud SDKs. The secret is a JSON object
+// with the keys authenticator, user (or username), password and token:
+//
+//	{"user": "etl", "password": "..."}
+//
+// The credentials are cached and retrieved again after RefreshInterval. If the login with the cached credentials
+// is rejected, e.g., because the secret was rotated, the driver invalidates the cache and retries the login once
+// with the current secret. For example:
+//
+//	sql.Register("snowflake-secrets", &sf.SnowflakeDriver{
+//		CredentialProviders: []sf.CredentialProvider{
+//			&secrets.AWSSecretsManager{SecretID: "prod/snowflake/etl"},
+//		},
+//	})
+//	db, err := sql.Open("snowflake-secrets", "myaccount.snowflakecomputing.com?authenticator=auto")
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// DefaultRefreshInterval is the time the credentials are cached if RefreshInterval is not set.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// defaultClient is used if the Client of a provider is nil.
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// now is replaced in tests.
+var now = time.Now
+
+// fetchFunc gets the credentials from the secret store. ttl is the validity of the secret, e.g., a Vault lease,
+// or 0 if unknown.
+type fetchFunc func(ctx context.Context) (creds *sf.Credentials, ttl time.Duration, err error)
+
+// cache caches the credentials of a provider.
+type cache struct {
+	mu        sync.Mutex
+	creds     *sf.Credentials
+	expiresAt time.Time
+}
+
+// get returns the cached credentials, or fetches them if they are expired. If the fetch fails, the expired
+// credentials are returned, so that an outage of the secret store doesn't fail the logins.
+func (c *cache) get(ctx context.Context, refresh time.Duration, fetch fetchFunc) (*sf.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.creds != nil && now().Before(c.expiresAt) {
+		creds := *c.creds
+		return &creds, nil
+	}
+	creds, ttl, err := fetch(ctx)
+	if err != nil {
+		if c.creds != nil {
+			stale := *c.creds
+			return &stale, nil
+		}
+		return nil, err
+	}
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+	if ttl > 0 && ttl < refresh {
+		refresh = ttl
+	}
+	c.creds = creds
+	c.expiresAt = now().Add(refresh)
+	ret := *creds
+	return &ret, nil
+}
+
+// invalidate drops the cached credentials.
+func (c *cache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.creds = nil
+}
+
+// parseSecret converts the key-value pairs of a secret to the credentials.
+func parseSecret(m map[string]interface{}) (*sf.Credentials, error) {
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			if v, ok := m[k].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	creds := &sf.Credentials{
+		Authenticator: str("authenticator"),
+		User:          str("user", "username"),
+		Password:      str("password"),
+		Token:         str("token"),
+	}
+	if creds.Password == "" && creds.Token == "" {
+		return nil, fmt.Errorf("secret has neither password nor token")
+	}
+	return creds, nil
+}
+
+// parseSecretJSON converts a secret string with a JSON object to the credentials.
+func parseSecretJSON(s []byte) (*sf.Credentials, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(s, &m); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object. err: %v", err)
+	}
+	return parseSecret(m)
+}
+
+// doJSON sends the request and decodes the JSON response into v.
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to get the secret. HTTP: %v, URL: %v, body: %v", resp.StatusCode, req.URL, string(b))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/sftest"
+)
+
+var (
+	_ sf.CredentialInvalidator = &AWSSecretsManager{}
+	_ sf.CredentialInvalidator = &GCPSecretManager{}
+	_ sf.CredentialInvalidator = &Vault{}
+)
+
+func TestCache(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	var c cache
+	calls := 0
+	password := "p1"
+	var fetchErr error
+	fetch := func(context.Context) (*sf.Credentials, time.Duration, error) {
+		calls++
+		if fetchErr != nil {
+			return nil, 0, fetchErr
+		}
+		return &sf.Credentials{User: "u", Password: password}, 0, nil
+	}
+	get := func() *sf.Credentials {
+		creds, err := c.get(context.Background(), time.Minute, fetch)
+		if err != nil {
+			t.Fatalf("failed to get the credentials. err: %v", err)
+		}
+		return creds
+	}
+	get()
+	get()
+	if calls != 1 {
+		t.Errorf("failed to cache the credentials. calls: %v", calls)
+	}
+
+	password = "p2"
+	current = current.Add(time.Minute)
+	if creds := get(); creds.Password != "p2" || calls != 2 {
+		t.Errorf("failed to refresh the credentials. password: %v, calls: %v", creds.Password, calls)
+	}
+
+	fetchErr = errors.New("unavailable")
+	current = current.Add(time.Minute)
+	if creds := get(); creds.Password != "p2" {
+		t.Errorf("failed to return the stale credentials. password: %v", creds.Password)
+	}
+
+	c.invalidate()
+	if _, err := c.get(context.Background(), time.Minute, fetch); err == nil {
+		t.Errorf("should fail without the cached credentials")
+	}
+	fetchErr = nil
+	password = "p3"
+	if creds := get(); creds.Password != "p3" {
+		t.Errorf("failed to get the rotated credentials. password: %v", creds.Password)
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	testcases := []struct {
+		secret string
+		creds  *sf.Credentials
+	}{
+		{secret: `{"user":"u","password":"p"}`, creds: &sf.Credentials{User: "u", Password: "p"}},
+		{secret: `{"username":"u","password":"p","engine":"snowflake"}`, creds: &sf.Credentials{User: "u", Password: "p"}},
+		{secret: `{"authenticator":"oauth","token":"t"}`, creds: &sf.Credentials{Authenticator: "oauth", Token: "t"}},
+		{secret: `{"user":"u"}`},
+		{secret: `password`},
+	}
+	for _, test := range testcases {
+		creds, err := parseSecretJSON([]byte(test.secret))
+		if test.creds == nil {
+			if err == nil {
+				t.Errorf("should fail to parse the secret. secret: %v", test.secret)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to parse the secret. secret: %v, err: %v", test.secret, err)
+			continue
+		}
+		if *creds != *test.creds {
+			t.Errorf("failed to parse the secret. expected: %v, got: %v", test.creds, creds)
+		}
+	}
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("failed to set the target. got: %v", r.Header.Get("X-Amz-Target"))
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(auth, "/us-west-2/secretsmanager/aws4_request") ||
+			!strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target") {
+			t.Errorf("failed to sign the request. got: %v", auth)
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["SecretId"] != "prod/snowflake" || req["VersionStage"] != "AWSCURRENT" {
+			t.Errorf("failed to request the secret. got: %v", req)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"username":"u","password":"p"}`})
+	}))
+	defer srv.Close()
+	p := &AWSSecretsManager{
+		SecretID: "prod/snowflake", Region: "us-west-2", Endpoint: srv.URL,
+		AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session",
+	}
+	creds, err := p.Retrieve(context.Background(), &sf.Config{})
+	if err != nil {
+		t.Fatalf("failed to retrieve the credentials. err: %v", err)
+	}
+	if creds.User != "u" || creds.Password != "p" {
+		t.Errorf("failed to get the credentials. got: %v", creds)
+	}
+}
+
+func TestGCPSecretManager(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/proj/secrets/snowflake/versions/latest:access" {
+			t.Errorf("failed to access the secret version. path: %v", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("failed to authorize. got: %v", r.Header.Get("Authorization"))
+		}
+		data := base64.StdEncoding.EncodeToString([]byte(`{"user":"u","password":"p"}`))
+		w.Write([]byte(`{"name":"v","payload":{"data":"` + data + `"}}`))
+	}))
+	defer srv.Close()
+	p := &GCPSecretManager{
+		Project: "proj", Secret: "snowflake", Endpoint: srv.URL,
+		TokenSource: func(context.Context) (string, error) { return "tok", nil },
+	}
+	creds, err := p.Retrieve(context.Background(), &sf.Config{})
+	if err != nil {
+		t.Fatalf("failed to retrieve the credentials. err: %v", err)
+	}
+	if creds.User != "u" || creds.Password != "p" {
+		t.Errorf("failed to get the credentials. got: %v", creds)
+	}
+}
+
+func TestVault(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("X-Vault-Token") != "vt" {
+			t.Errorf("failed to set the token. got: %v", r.Header.Get("X-Vault-Token"))
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/snowflake/etl":
+			w.Write([]byte(`{"lease_duration":0,"data":{"data":{"user":"u","password":"p"},"metadata":{"version":3}}}`))
+		case "/v1/kv/snowflake/etl":
+			w.Write([]byte(`{"lease_duration":60,"data":{"user":"u1","password":"p1"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := &Vault{Address: srv.URL, Token: "vt", Path: "snowflake/etl"}
+	creds, err := p.Retrieve(context.Background(), &sf.Config{})
+	if err != nil {
+		t.Fatalf("failed to retrieve the credentials. err: %v", err)
+	}
+	if creds.User != "u" || creds.Password != "p" {
+		t.Errorf("failed to get the credentials. got: %v", creds)
+	}
+
+	p1 := &Vault{Address: srv.URL, Token: "vt", Mount: "kv", Path: "snowflake/etl", KVVersion: 1}
+	if creds, err = p1.Retrieve(context.Background(), &sf.Config{}); err != nil || creds.User != "u1" {
+		t.Fatalf("failed to retrieve the credentials. creds: %v, err: %v", creds, err)
+	}
+	calls = 0
+	current = current.Add(61 * time.Second)
+	p1.Retrieve(context.Background(), &sf.Config{})
+	if calls != 1 {
+		t.Errorf("failed to refresh the credentials at the end of the lease. calls: %v", calls)
+	}
+
+	p2 := &Vault{Address: srv.URL, Token: "vt", Path: "missing"}
+	if _, err = p2.Retrieve(context.Background(), &sf.Config{}); err == nil {
+		t.Errorf("should fail to retrieve the missing secret")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetUser("u", "p1")
+	password := "p1"
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"data": map[string]string{"user": "u", "password": password}},
+		})
+	}))
+	defer vault.Close()
+
+	d := &sf.SnowflakeDriver{CredentialProviders: []sf.CredentialProvider{
+		&Vault{Address: vault.URL, Token: "vt", Path: "snowflake", RefreshInterval: time.Hour},
+	}}
+	dsn := srv.DSN("", "") + "&authenticator=auto"
+	conn, err := d.Open(dsn)
+	if err != nil {
+		t.Fatalf("failed to open a connection. err: %v", err)
+	}
+	conn.Close()
+
+	// rotates the password. the cached password is rejected and the login is retried with the new one.
+	srv.SetUser("u", "p2")
+	password = "p2"
+	conn, err = d.Open(dsn)
+	if err != nil {
+		t.Fatalf("failed to open a connection after the rotation. err: %v", err)
+	}
+	conn.Close()
+	if n := srv.RequestCount("/session/v1/login-request"); n != 3 {
+		t.Errorf("failed to retry the login once. logins: %v", n)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// Vault provides the credentials in a secret of the HashiCorp Vault KV secrets engine. If Vault returns a lease
+// for the secret, the credentials are retrieved again when the lease expires if it is shorter than
+// RefreshInterval.
+type Vault struct {
+	Address         string        // VAULT_ADDR if empty
+	Token           string        // VAULT_TOKEN if empty
+	Namespace       string        // VAULT_NAMESPACE if empty. Vault Enterprise only.
+	Mount           string        // mount path of the KV secrets engine. secret if empty.
+	Path            string        // path of the secret in the engine
+	KVVersion       int           // version of the KV secrets engine. 2 if 0.
+	RefreshInterval time.Duration // DefaultRefreshInterval if 0
+	Client          *http.Client  // http.Client with 30 seconds timeout if nil
+
+	cache cache
+}
+
+// Name returns the provider name.
+func (p *Vault) Name() string {
+	return "vault"
+}
+
+// Retrieve returns the cached credentials or reads the secret.
+func (p *Vault) Retrieve(ctx context.Context, cfg *sf.Config) (*sf.Credentials, error) {
+	return p.cache.get(ctx, p.RefreshInterval, p.fetch)
+}
+
+// InvalidateCredentials drops the cached credentials.
+func (p *Vault) InvalidateCredentials() {
+	p.cache.invalidate()
+}
+
+func (p *Vault) fetch(ctx context.Context) (*sf.Credentials, time.Duration, error) {
+	addr := p.Address
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return nil, 0, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := p.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	mount := strings.Trim(p.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	path := strings.Trim(p.Path, "/")
+	u := fmt.Sprintf("%v/v1/%v/%v", strings.TrimRight(addr, "/"), mount, path)
+	kv2 := p.KVVersion != 1
+	if kv2 {
+		u = fmt.Sprintf("%v/v1/%v/data/%v", strings.TrimRight(addr, "/"), mount, path)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", token)
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	var respd struct {
+		LeaseDuration int64                  `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err = doJSON(p.Client, req, &respd); err != nil {
+		return nil, 0, err
+	}
+	data := respd.Data
+	if kv2 {
+		// KV version 2 nests the secret in data.data.
+		data, _ = data["data"].(map[string]interface{})
+		if data == nil {
+			return nil, 0, fmt.Errorf("secret %v/%v is deleted or empty", mount, path)
+		}
+	}
+	ttl := time.Duration(respd.LeaseDuration) * time.Second
+	if v, ok := respd.Data["ttl"].(string); ok && !kv2 {
+		// KV version 1 returns the ttl key of the secret as the refresh interval.
+		if d, err := time.ParseDuration(v); err == nil {
+			ttl = d
+		}
+	}
+	creds, err := parseSecret(data)
+	return creds, ttl, err
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+)
+
+// SeekRow moves to the row at the offset, from 0, of the current result, so that Next returns the row, or io.EOF if
+// the offset is beyond the last row. The chunk of the row is located by the row counts of the chunks, and the
+// chunks are downloaded from it, so that a page far into a large result is read without downloading the chunks
+// before it. Seeking backward downloads the chunks again. With Go 1.13 or later, the rows are available in
+// sql.Conn.Raw:
+//
+//	err = conn.Raw(func(dc interface{}) error {
+//		rows, err := dc.(driver.QueryerContext).QueryContext(ctx, query, nil)
+//		if err != nil {
+//			return err
+//		}
+//		defer rows.Close()
+//		if err = rows.(sf.SnowflakeRows).SeekRow(int64(page) * pageSize); err != nil {
+//			return err
+//		}
+//		...
+//	})
+func (rows *snowflakeRows) SeekRow(offset int64) error {
+	if rows.arrowBatches {
+		return &SnowflakeError{
+			Number:  ErrArrowBatchesOnly,
+			Message: errMsgArrowBatchesOnly,
+		}
+	}
+	if offset < 0 {
+		return &SnowflakeError{
+			Number:      ErrInvalidRowOffset,
+			SQLState:    SQLStateSyntaxError,
+			Message:     errMsgInvalidRowOffset,
+			MessageArgs: []interface{}{offset},
+		}
+	}
+	ctx := rows.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	scd, err := rows.ChunkDownloader.seek(ctx, offset)
+	if err != nil {
+		return withQueryID(err, rows.queryID)
+	}
+	rows.ChunkDownloader = scd
+	return nil
+}
+
+// seek returns the downloader of the same result positioned at the row of the offset, which downloads the chunks
+// with the context, and closes this downloader.
+func (scd *snowflakeChunkDownloader) seek(ctx context.Context, offset int64) (*snowflakeChunkDownloader, error) {
+	scd.close()
+	ctx, cancel := context.WithCancel(ctx)
+	next := &snowflakeChunkDownloader{
+		sc:                 scd.sc,
+		ctx:                ctx,
+		cancel:             cancel,
+		queryID:            scd.queryID,
+		firstChunk:         scd.firstChunk,
+		ChunkMetas:         scd.ChunkMetas,
+		Total:              scd.Total,
+		TotalRowIndex:      int64(-1),
+		Qrmk:               scd.Qrmk,
+		ChunkHeader:        scd.ChunkHeader,
+		RowType:            scd.RowType,
+		QueryResultFormat:  scd.QueryResultFormat,
+		MaxWorkers:         scd.MaxWorkers,
+		MaxBufferBytes:     scd.MaxBufferBytes,
+		SpillDir:           scd.SpillDir,
+		FuncDownload:       scd.FuncDownload,
+		FuncDownloadHelper: scd.FuncDownloadHelper,
+		FuncGet:            scd.FuncGet,
+	}
+	if offset < int64(scd.firstChunk.len()) {
+		next.CurrentChunk = scd.firstChunk
+		if err := next.startAt(0); err != nil {
+			return nil, err
+		}
+		next.CurrentIndex = int(offset) - 1
+		return next, nil
+	}
+	// the chunk of the row, or the end if the offset is beyond the last row
+	first := len(scd.ChunkMetas)
+	start := int64(scd.firstChunk.len())
+	for i, meta := range scd.ChunkMetas {
+		if offset < start+int64(meta.RowCount) {
+			first = i
+			next.skip = int(offset - start)
+			break
+		}
+		start += int64(meta.RowCount)
+	}
+	glog.V(2).Infof("seeking to row %v in chunk %v/%v", offset, first+1, len(scd.ChunkMetas))
+	if err := next.startAt(first); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// seekTestRows returns the rows of a result of the first chunk of 3 rows and 5 chunks of 4 rows, whose values
+// are the row numbers, and records the chunks downloaded by each downloader.
+func seekTestRows() (*snowflakeRows, map[*snowflakeChunkDownloader][]int, *sync.Mutex) {
+	const first, numChunks, rowCount = 3, 5, 4
+	var mu sync.Mutex
+	downloaded := make(map[*snowflakeChunkDownloader][]int)
+	chunk := func(start, n int) [][]*string {
+		rows := make([][]*string, n)
+		for i := range rows {
+			v := strconv.Itoa(start + i)
+			rows[i] = []*string{&v}
+		}
+		return rows
+	}
+	metas := make([]execResponseChunk, numChunks)
+	for i := range metas {
+		metas[i] = execResponseChunk{URL: "dummyURL" + strconv.Itoa(i), RowCount: rowCount}
+	}
+	rows := &snowflakeRows{
+		ctx:     context.Background(),
+		RowType: []execResponseRowType{{Name: "C1", Type: "fixed"}},
+	}
+	rows.ChunkDownloader = &snowflakeChunkDownloader{
+		ctx:           context.Background(),
+		Total:         first + numChunks*rowCount,
+		ChunkMetas:    metas,
+		TotalRowIndex: int64(-1),
+		CurrentChunk:  jsonChunk(chunk(0, first)),
+		firstChunk:    jsonChunk(chunk(0, first)),
+		MaxWorkers:    2,
+		FuncDownload: func(scd *snowflakeChunkDownloader, idx int) {
+			mu.Lock()
+			downloaded[scd] = append(downloaded[scd], idx)
+			mu.Unlock()
+			scd.ChunksMutex.Lock()
+			scd.Chunks[idx] = jsonChunk(chunk(first+idx*rowCount, rowCount))
+			scd.ChunksMutex.Unlock()
+			scd.chunkReady(idx)
+		},
+	}
+	rows.ChunkDownloader.start()
+	return rows, downloaded, &mu
+}
+
+func TestSeekRow(t *testing.T) {
+	dest := make([]driver.Value, 1)
+	next := func(rows *snowflakeRows) string {
+		if err := rows.Next(dest); err == io.EOF {
+			return "EOF"
+		} else if err != nil {
+			t.Fatalf("failed to read the row. err: %v", err)
+		}
+		return dest[0].(string)
+	}
+	testcases := []struct {
+		offset int64
+		rows   []string
+	}{
+		{offset: 0, rows: []string{"0", "1", "2", "3"}},
+		{offset: 2, rows: []string{"2", "3"}},
+		{offset: 3, rows: []string{"3", "4"}},
+		{offset: 13, rows: []string{"13", "14", "15", "16"}},
+		{offset: 22, rows: []string{"22", "EOF"}},
+		{offset: 23, rows: []string{"EOF"}},
+		{offset: 100, rows: []string{"EOF"}},
+	}
+	for _, test := range testcases {
+		rows, _, _ := seekTestRows()
+		next(rows)
+		if err := rows.SeekRow(test.offset); err != nil {
+			t.Errorf("failed to seek. offset: %v, err: %v", test.offset, err)
+			continue
+		}
+		for _, expected := range test.rows {
+			if got := next(rows); got != expected {
+				t.Errorf("failed to read the row after seeking. offset: %v, expected: %v, got: %v", test.offset, expected, got)
+			}
+		}
+	}
+
+	rows, downloaded, mu := seekTestRows()
+	if err := rows.SeekRow(19); err != nil {
+		t.Fatalf("failed to seek. err: %v", err)
+	}
+	if got := next(rows); got != "19" {
+		t.Fatalf("failed to read the row after seeking. expected: 19, got: %v", got)
+	}
+	mu.Lock()
+	for _, idx := range downloaded[rows.ChunkDownloader] {
+		if idx < 4 {
+			t.Errorf("should not have downloaded the chunks before the row. chunk: %v", idx)
+		}
+	}
+	mu.Unlock()
+	if err := rows.SeekRow(1); err != nil {
+		t.Fatalf("failed to seek backward. err: %v", err)
+	}
+	if got := next(rows); got != "1" {
+		t.Errorf("failed to read the row after seeking backward. expected: 1, got: %v", got)
+	}
+
+	err := rows.SeekRow(-1)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidRowOffset {
+		t.Errorf("should have failed to seek to the negative offset. err: %v", err)
+	}
+	rows.arrowBatches = true
+	err = rows.SeekRow(0)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrArrowBatchesOnly {
+		t.Errorf("should have failed to seek in the Arrow batches mode. err: %v", err)
+	}
+}
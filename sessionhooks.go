@@ -0,0 +1,55 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+)
+
+// SessionHook is called with the session of a connection by Config.OnSessionOpen and Config.OnSessionClose.
+type SessionHook func(ctx context.Context, info SessionInfo) error
+
+// SessionInfo is the session of a connection given to the session hooks.
+type SessionInfo struct {
+	SessionID     int64
+	ServerVersion string
+	Account       string
+	User          string
+	Role          string
+	Warehouse     string
+	Database      string
+	Schema        string
+	// Conn is the connection of the session, e.g., to run statements with ExecContext. It must not be closed
+	// by the hooks.
+	Conn SnowflakeConn
+}
+
+// SessionInfo returns the session of the connection.
+func (sc *snowflakeConn) SessionInfo() SessionInfo {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return SessionInfo{
+		SessionID:     int64(sc.rest.SessionID),
+		ServerVersion: sc.serverVersion,
+		Account:       sc.cfg.Account,
+		User:          sc.cfg.User,
+		Role:          sc.cfg.Role,
+		Warehouse:     sc.cfg.Warehouse,
+		Database:      sc.cfg.Database,
+		Schema:        sc.cfg.Schema,
+		Conn:          sc,
+	}
+}
+
+// runSessionHook calls the hook with the session of the connection if it's set.
+func (sc *snowflakeConn) runSessionHook(ctx context.Context, name string, hook SessionHook) error {
+	if hook == nil {
+		return nil
+	}
+	glog.V(2).Infof("running %v. session: %v", name, sc.rest.SessionID)
+	if err := hook(ctx, sc.SessionInfo()); err != nil {
+		glog.V(1).Infof("%v failed. err: %v", name, err)
+		return err
+	}
+	return nil
+}
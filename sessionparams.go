@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// SessionParameter returns the value of the session parameter. The parameters are updated from the responses
+// of the queries, so the value is the one last returned by the server or set by SetSessionParameter.
+func (sc *snowflakeConn) SessionParameter(name string) (string, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for k, v := range sc.cfg.Params {
+		if strings.EqualFold(k, name) && v != nil {
+			return *v, true
+		}
+	}
+	return "", false
+}
+
+// SessionParameters returns a copy of the session parameters by upper case names.
+func (sc *snowflakeConn) SessionParameters() map[string]string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	params := make(map[string]string, len(sc.cfg.Params))
+	for k, v := range sc.cfg.Params {
+		if v != nil {
+			params[strings.ToUpper(k)] = *v
+		}
+	}
+	return params
+}
+
+// SetSessionParameter runs ALTER SESSION SET name = value, and updates the parameter of the connection. The
+// value is a string, bool or number formatted as a SQL literal, and the name must be an unquoted identifier, so
+// neither can inject SQL.
+func (sc *snowflakeConn) SetSessionParameter(ctx context.Context, name string, value interface{}) error {
+	if err := sc.checkConn(); err != nil {
+		return err
+	}
+	if err := checkSessionParameterName(name); err != nil {
+		return err
+	}
+	literal, err := formatSQLLiteral(value)
+	if err != nil {
+		return err
+	}
+	if _, err = sc.exec(ctx, "ALTER SESSION SET "+name+" = "+literal, false, true, nil); err != nil {
+		return err
+	}
+	v := literal
+	switch t := value.(type) {
+	case string:
+		v = t
+	case bool:
+		v = strconv.FormatBool(t)
+	}
+	sc.mu.Lock()
+	sc.setSessionParameter(name, &v)
+	sc.mu.Unlock()
+	return nil
+}
+
+// UnsetSessionParameter runs ALTER SESSION UNSET name, and removes the parameter from the connection until the
+// server returns it again.
+func (sc *snowflakeConn) UnsetSessionParameter(ctx context.Context, name string) error {
+	if err := sc.checkConn(); err != nil {
+		return err
+	}
+	if err := checkSessionParameterName(name); err != nil {
+		return err
+	}
+	if _, err := sc.exec(ctx, "ALTER SESSION UNSET "+name, false, true, nil); err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	sc.setSessionParameter(name, nil)
+	sc.mu.Unlock()
+	return nil
+}
+
+// setSessionParameter stores the parameter by the lower case name as the server returns, replacing the names in
+// other cases, e.g., given in DSN. A nil value removes the parameter. sc.mu must be held.
+func (sc *snowflakeConn) setSessionParameter(name string, v *string) {
+	if sc.cfg.Params == nil {
+		sc.cfg.Params = make(map[string]*string)
+	}
+	for k := range sc.cfg.Params {
+		if strings.EqualFold(k, name) {
+			delete(sc.cfg.Params, k)
+		}
+	}
+	if v != nil {
+		sc.cfg.Params[strings.ToLower(name)] = v
+	}
+}
+
+func checkSessionParameterName(name string) error {
+	if !isUpperCaseIdentifier(strings.ToUpper(name)) {
+		return &SnowflakeError{
+			Number:      ErrInvalidIdentifier,
+			SQLState:    SQLStateSyntaxError,
+			Message:     errMsgInvalidIdentifier,
+			MessageArgs: []interface{}{name},
+		}
+	}
+	return nil
+}
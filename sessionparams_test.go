@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetSessionParameter(t *testing.T) {
+	testcases := []struct {
+		name  string
+		value interface{}
+		sql   string
+		local string
+	}{
+		{name: "TIMEZONE", value: "America/Los_Angeles", sql: "ALTER SESSION SET TIMEZONE = 'America/Los_Angeles'", local: "America/Los_Angeles"},
+		{name: "query_tag", value: "it's", sql: "ALTER SESSION SET query_tag = 'it''s'", local: "it's"},
+		{name: "ABORT_DETACHED_QUERY", value: true, sql: "ALTER SESSION SET ABORT_DETACHED_QUERY = TRUE", local: "true"},
+		{name: "LOCK_TIMEOUT", value: 60, sql: "ALTER SESSION SET LOCK_TIMEOUT = 60", local: "60"},
+	}
+	for _, test := range testcases {
+		sc, s := fakeSessionConn()
+		if err := sc.SetSessionParameter(context.Background(), test.name, test.value); err != nil {
+			t.Errorf("failed to set the parameter. name: %v, err: %v", test.name, err)
+			continue
+		}
+		if len(s.sent) != 1 || s.sent[0] != test.sql {
+			t.Errorf("failed to run ALTER SESSION. expected: %v, got: %v", test.sql, s.sent)
+		}
+		if v, ok := sc.SessionParameter(test.name); !ok || v != test.local {
+			t.Errorf("failed to update the parameter. expected: %v, got: %v", test.local, v)
+		}
+	}
+
+	sc, s := fakeSessionConn()
+	for _, name := range []string{"TIMEZONE = 'UTC'; DROP TABLE t; --", `"TIMEZONE"`, ""} {
+		err := sc.SetSessionParameter(context.Background(), name, "UTC")
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidIdentifier {
+			t.Errorf("should have failed to set the parameter. name: %q, err: %v", name, err)
+		}
+	}
+	err := sc.SetSessionParameter(context.Background(), "TIMEZONE", []string{"UTC"})
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrUnsupportedLiteralType {
+		t.Errorf("should have failed to format the value. err: %v", err)
+	}
+	if len(s.sent) != 0 {
+		t.Errorf("should not have run the statements. sent: %v", s.sent)
+	}
+}
+
+func TestUnsetSessionParameter(t *testing.T) {
+	sc, s := fakeSessionConn()
+	tag := "etl"
+	sc.cfg.Params["QUERY_TAG"] = &tag
+	if err := sc.UnsetSessionParameter(context.Background(), "query_tag"); err != nil {
+		t.Fatalf("failed to unset the parameter. err: %v", err)
+	}
+	if len(s.sent) != 1 || s.sent[0] != "ALTER SESSION UNSET query_tag" {
+		t.Errorf("failed to run ALTER SESSION UNSET. got: %v", s.sent)
+	}
+	if v, ok := sc.SessionParameter("QUERY_TAG"); ok {
+		t.Errorf("failed to remove the parameter. got: %v", v)
+	}
+}
+
+func TestSessionParametersFromResponse(t *testing.T) {
+	sc, s := fakeSessionConn()
+	s.respParams = []nameValueParameter{
+		{Name: "TIMEZONE", Value: "UTC"},
+		{Name: "CLIENT_RESULT_PREFETCH_THREADS", Value: int64(8)},
+		{Name: "CLIENT_SESSION_KEEP_ALIVE", Value: false},
+	}
+	s.state = map[string]string{"ROLE": "R2", "WAREHOUSE": "W2", "DATABASE": "DB2", "SCHEMA": "S2"}
+	tz := "America/New_York"
+	sc.cfg.Params["TIMEZONE"] = &tz
+	if _, err := sc.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	params := sc.SessionParameters()
+	expected := map[string]string{"TIMEZONE": "UTC", "CLIENT_RESULT_PREFETCH_THREADS": "8", "CLIENT_SESSION_KEEP_ALIVE": "false"}
+	if len(params) != len(expected) {
+		t.Errorf("failed to replace the parameters. expected: %v, got: %v", expected, params)
+	}
+	for k, v := range expected {
+		if params[k] != v {
+			t.Errorf("failed to update the parameter %v. expected: %v, got: %v", k, v, params[k])
+		}
+	}
+	if v, ok := sc.SessionParameter("timezone"); !ok || v != "UTC" {
+		t.Errorf("failed to get the parameter case-insensitively. got: %v", v)
+	}
+	info := sc.SessionInfo()
+	if info.Database != "DB2" || info.Schema != "S2" || info.Role != "R2" || info.Warehouse != "W2" {
+		t.Errorf("failed to update the session context. got: %+v", info)
+	}
+}
@@ -0,0 +1,163 @@
+// +build go1.13
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// SessionState is the context of a session. Empty fields mean any value is acceptable. Each field is a single
+// identifier, unquoted or double-quoted, as in UseOptions.
+type SessionState struct {
+	Role      string
+	Warehouse string
+	Database  string
+	Schema    string
+}
+
+// distance returns the number of fields to switch to satisfy the requested state.
+func (s SessionState) distance(requested SessionState) int {
+	n := 0
+	for _, f := range [][2]string{
+		{requested.Role, s.Role},
+		{requested.Warehouse, s.Warehouse},
+		{requested.Database, s.Database},
+		{requested.Schema, s.Schema},
+	} {
+		if f[0] == "" {
+			continue
+		}
+		if name, ok := resolveIdentifier(f[0], false); !ok || name != f[1] {
+			n++
+		}
+	}
+	return n
+}
+
+// SessionPool is an optional pool of connections above database/sql that tracks the role, warehouse, database
+// and schema of each session. Get hands out an idle connection already in the requested context, or switches
+// the context of another connection with USE statements, so multi-tenant services don't run USE statements on
+// every checkout.
+//
+// The pool reads the context of a connection from the session when it's handed out, and chooses the idle
+// connections by it. Don't run USE statements on the connections directly, and
+// don't share the sql.DB with code outside of the pool, because the connections are returned to the sql.DB
+// pool with the switched context when closed.
+type SessionPool struct {
+	DB      *sql.DB
+	MaxIdle int // maximum number of idle connections kept by the pool. 2 if 0.
+
+	mu     sync.Mutex
+	idle   []*PooledConn
+	closed bool
+}
+
+// PooledConn is a connection checked out from a SessionPool. Call Release to return it to the pool.
+type PooledConn struct {
+	*sql.Conn
+	pool  *SessionPool
+	state SessionState
+}
+
+// NewSessionPool returns a SessionPool of the connections of db.
+func NewSessionPool(db *sql.DB) *SessionPool {
+	return &SessionPool{DB: db}
+}
+
+// Get returns a connection in the requested session context.
+func (p *SessionPool) Get(ctx context.Context, requested SessionState) (*PooledConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("session pool is closed")
+	}
+	// take the idle connection requiring the fewest USE statements
+	var pc *PooledConn
+	best := -1
+	for i, c := range p.idle {
+		if best < 0 || c.state.distance(requested) < p.idle[best].state.distance(requested) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		pc = p.idle[best]
+		p.idle = append(p.idle[:best], p.idle[best+1:]...)
+	}
+	p.mu.Unlock()
+
+	if pc == nil {
+		conn, err := p.DB.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pc = &PooledConn{Conn: conn, pool: p}
+	}
+	if err := pc.switchState(ctx, requested); err != nil {
+		pc.Conn.Close()
+		return nil, err
+	}
+	return pc, nil
+}
+
+// switchState switches the session context with SnowflakeConn.Use, which validates the identifiers and runs USE
+// statements only for the fields different from the session, and tracks the context of the session afterwards.
+func (c *PooledConn) switchState(ctx context.Context, requested SessionState) error {
+	return c.Raw(func(dc interface{}) error {
+		sc, ok := dc.(SnowflakeConn)
+		if !ok {
+			return fmt.Errorf("session pool requires the connections of the Snowflake driver. got: %T", dc)
+		}
+		err := sc.Use(ctx, UseOptions{
+			Role:      requested.Role,
+			Warehouse: requested.Warehouse,
+			Database:  requested.Database,
+			Schema:    requested.Schema,
+		})
+		info := sc.SessionInfo()
+		c.state = SessionState{Role: info.Role, Warehouse: info.Warehouse, Database: info.Database, Schema: info.Schema}
+		return err
+	})
+}
+
+// State returns the session context tracked by the pool, by the names resolved by Snowflake.
+func (c *PooledConn) State() SessionState {
+	return c.state
+}
+
+// Release returns the connection to the pool. The connection must not be used after Release.
+func (c *PooledConn) Release() error {
+	p := c.pool
+	maxIdle := p.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = 2
+	}
+	p.mu.Lock()
+	if !p.closed && len(p.idle) < maxIdle {
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+		return nil
+	}
+	p.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// Close closes the idle connections. The connections checked out are closed when released.
+func (p *SessionPool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	var err error
+	for _, c := range idle {
+		if e := c.Conn.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
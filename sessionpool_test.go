@@ -0,0 +1,157 @@
+// +build go1.13
+
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tcSessionPoolConnector connects to fake sessions that run USE statements, and records the statements.
+type tcSessionPoolConnector struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (c *tcSessionPoolConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	sc := &snowflakeConn{cfg: &Config{Role: "PUBLIC", Warehouse: "WH0", Database: "DB0", Schema: "PUBLIC"}}
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		resp := &execResponse{Success: true}
+		resp.Data.FinalRoleName = sc.cfg.Role
+		resp.Data.FinalWarehouseName = sc.cfg.Warehouse
+		resp.Data.FinalDatabaseName = sc.cfg.Database
+		resp.Data.FinalSchemaName = sc.cfg.Schema
+		f := strings.SplitN(req.SQLText, " ", 3)
+		if f[0] != "USE" {
+			return resp, nil
+		}
+		c.mu.Lock()
+		c.sent = append(c.sent, req.SQLText)
+		c.mu.Unlock()
+		name, _ := resolveIdentifier(f[2], false)
+		switch f[1] {
+		case "ROLE":
+			resp.Data.FinalRoleName = name
+		case "WAREHOUSE":
+			resp.Data.FinalWarehouseName = name
+		case "DATABASE":
+			resp.Data.FinalDatabaseName = name
+			resp.Data.FinalSchemaName = "PUBLIC"
+		case "SCHEMA":
+			resp.Data.FinalSchemaName = name
+		}
+		return resp, nil
+	}
+	sc.rest = &snowflakeRestful{
+		FuncPostQuery:    postQuery,
+		FuncCloseSession: func(*snowflakeRestful) error { return nil },
+	}
+	return sc, nil
+}
+
+func (c *tcSessionPoolConnector) Driver() driver.Driver {
+	return SnowflakeDriver{}
+}
+
+// takeSent returns the USE statements run since the last call.
+func (c *tcSessionPoolConnector) takeSent() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sent := c.sent
+	c.sent = nil
+	return sent
+}
+
+func TestSessionPool(t *testing.T) {
+	connector := &tcSessionPoolConnector{}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	p := NewSessionPool(db)
+	defer p.Close()
+	ctx := context.Background()
+	expectSent := func(expected ...string) {
+		t.Helper()
+		if sent := connector.takeSent(); strings.Join(sent, ";") != strings.Join(expected, ";") {
+			t.Errorf("unexpected statements. expected: %v, got: %v", expected, sent)
+		}
+	}
+
+	c1, err := p.Get(ctx, SessionState{Role: "R1", Warehouse: "WH"})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	expectSent("USE ROLE R1", "USE WAREHOUSE WH")
+	c2, err := p.Get(ctx, SessionState{Role: "R2"})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	expectSent("USE ROLE R2")
+	c1.Release()
+	c2.Release()
+
+	// matching idle connections are reused without USE statements
+	c, err := p.Get(ctx, SessionState{Role: "r1"})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	expectSent()
+	if c.State().Warehouse != "WH" {
+		t.Errorf("should have got the connection with the warehouse. state: %v", c.State())
+	}
+	c.Release()
+
+	// the state is switched if no idle connection matches
+	c1, err = p.Get(ctx, SessionState{Role: "R2", Database: "DB", Schema: "S"})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	expectSent("USE DATABASE DB", "USE SCHEMA S")
+	if st := c1.State(); st.Role != "R2" || st.Database != "DB" || st.Schema != "S" {
+		t.Errorf("unexpected state: %v", st)
+	}
+
+	// a new connection starts from the context of the session
+	c2, err = p.Get(ctx, SessionState{Role: "R1"})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	c, err = p.Get(ctx, SessionState{Database: "DB0", Schema: "PUBLIC"})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	expectSent()
+	if st := c.State(); st.Role != "PUBLIC" || st.Warehouse != "WH0" {
+		t.Errorf("failed to read the state of the session. state: %v", st)
+	}
+	c.Release()
+	c1.Release()
+	c2.Release()
+
+	// the names are validated
+	_, err = p.Get(ctx, SessionState{Role: "R1; DROP DATABASE DB"})
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidIdentifier {
+		t.Errorf("should have failed with an invalid identifier. err: %v", err)
+	}
+	c, err = p.Get(ctx, SessionState{Role: `"My Role"`})
+	if err != nil {
+		t.Fatalf("failed to get a connection. err: %v", err)
+	}
+	expectSent(`USE ROLE "My Role"`)
+	if c.State().Role != "My Role" {
+		t.Errorf("failed to switch to the quoted role. state: %v", c.State())
+	}
+	c.Release()
+}
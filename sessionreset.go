@@ -0,0 +1,233 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SessionResetMode is how the session of a connection is reset before database/sql hands the connection out
+// again, so that the state left by a borrower doesn't leak to the next one.
+type SessionResetMode string
+
+const (
+	// SessionResetNone doesn't reset the session. This is the default.
+	SessionResetNone SessionResetMode = "none"
+	// SessionResetVariables unsets the session variables.
+	SessionResetVariables SessionResetMode = "variables"
+	// SessionResetFull unsets the session variables, drops the temporary tables created by the statements of
+	// the connection, restores the session parameters to the ones at the login with ALTER SESSION, and switches
+	// the role, warehouse, database and schema back to the ones at the login.
+	SessionResetFull SessionResetMode = "full"
+)
+
+func parseSessionResetMode(s string) (SessionResetMode, error) {
+	switch m := SessionResetMode(s); m {
+	case SessionResetNone, SessionResetVariables, SessionResetFull:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid sessionReset: %v. must be none, variables or full", s)
+}
+
+// identifierPart is an unquoted or double-quoted part of an object name.
+const identifierPart = `(?:"(?:[^"]|"")+"|[^\s(."]+)`
+
+var (
+	// createTempTableRegexp matches CREATE TEMPORARY TABLE and captures the table name.
+	createTempTableRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:LOCAL\s+|GLOBAL\s+)?` +
+		`(?:TEMP|TEMPORARY|VOLATILE)\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + identifierPart + `(?:\.` + identifierPart + `)*)`)
+	identifierPartRegexp = regexp.MustCompile(identifierPart)
+)
+
+// trackTempTable records the temporary table created by the statement, qualified by the database and schema of
+// the session if unqualified, so that SessionResetFull drops it. sc.mu must be held.
+func (sc *snowflakeConn) trackTempTable(query string) {
+	if sc.cfg.SessionReset != SessionResetFull {
+		return
+	}
+	m := createTempTableRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return
+	}
+	name := m[1]
+	if parts := len(identifierPartRegexp.FindAllString(name, -1)); parts < 3 && sc.cfg.Database != "" && sc.cfg.Schema != "" {
+		if parts == 1 {
+			name = quoteIdentifier(sc.cfg.Schema) + "." + name
+		}
+		name = quoteIdentifier(sc.cfg.Database) + "." + name
+	}
+	for _, t := range sc.tempTables {
+		if t == name {
+			return
+		}
+	}
+	sc.tempTables = append(sc.tempTables, name)
+}
+
+// ResetSession implements driver.SessionResetter. It resets the session by Config.SessionReset. If the reset
+// fails, the connection is marked bad and driver.ErrBadConn is returned, so that database/sql discards it
+// instead of handing out the session with the state left.
+func (sc *snowflakeConn) ResetSession(ctx context.Context) error {
+	if err := sc.checkConn(); err != nil {
+		return err
+	}
+	var err error
+	switch sc.cfg.SessionReset {
+	case SessionResetVariables:
+		err = sc.resetVariables(ctx)
+	case SessionResetFull:
+		err = sc.resetFull(ctx)
+	}
+	if err != nil {
+		glog.V(1).Infof("failed to reset the session. err: %v", err)
+		sc.markBad(BadConnResetFailed)
+		return sc.checkConn()
+	}
+	return nil
+}
+
+// resetVariables unsets all session variables.
+func (sc *snowflakeConn) resetVariables(ctx context.Context) error {
+	vars, err := sc.queryInternal(ctx, "SHOW VARIABLES")
+	if err != nil {
+		return err
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	names := make([]string, len(vars))
+	for i, v := range vars {
+		names[i] = quoteIdentifier(v["name"])
+	}
+	_, err = sc.exec(ctx, "UNSET ("+strings.Join(names, ", ")+")", false, true, nil)
+	return err
+}
+
+// resetFull drops the temporary tables, unsets the variables and restores the session parameters and context
+// of the login.
+func (sc *snowflakeConn) resetFull(ctx context.Context) error {
+	sc.mu.Lock()
+	tables := sc.tempTables
+	sc.tempTables = nil
+	sc.mu.Unlock()
+	for _, t := range tables {
+		if _, err := sc.exec(ctx, "DROP TABLE IF EXISTS "+t, false, true, nil); err != nil {
+			return err
+		}
+	}
+	if err := sc.resetVariables(ctx); err != nil {
+		return err
+	}
+	if err := sc.resetParameters(ctx); err != nil {
+		return err
+	}
+	return sc.resetContext(ctx)
+}
+
+// resetParameters unsets the session parameters set in the session, except those set by the login, which are
+// set back to the values of the login.
+func (sc *snowflakeConn) resetParameters(ctx context.Context) error {
+	params, err := sc.queryInternal(ctx, "SHOW PARAMETERS IN SESSION")
+	if err != nil {
+		return err
+	}
+	for _, p := range params {
+		if !strings.EqualFold(p["level"], "SESSION") {
+			continue
+		}
+		name := strings.ToUpper(p["key"])
+		login, ok := sc.loginParams[name]
+		switch {
+		case !ok:
+			err = sc.UnsetSessionParameter(ctx, name)
+		case login != p["value"]:
+			err = sc.SetSessionParameter(ctx, name, parameterValue(login, p["type"]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parameterValue returns the value of the parameter of the type shown by SHOW PARAMETERS for
+// SetSessionParameter.
+func parameterValue(s, typ string) interface{} {
+	switch strings.ToUpper(typ) {
+	case "BOOLEAN":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "NUMBER":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	}
+	return s
+}
+
+// resetContext switches the role, warehouse, database and schema back to the ones at the login. The database
+// and schema can't be unset, so the session can't be reset if the login had none and one is used now.
+func (sc *snowflakeConn) resetContext(ctx context.Context) error {
+	sc.mu.Lock()
+	current := []string{sc.cfg.Role, sc.cfg.Warehouse, sc.cfg.Database, sc.cfg.Schema}
+	sc.mu.Unlock()
+	login := []string{sc.loginContext.Role, sc.loginContext.Warehouse, sc.loginContext.Database, sc.loginContext.Schema}
+	var opts UseOptions
+	fields := []*string{&opts.Role, &opts.Warehouse, &opts.Database, &opts.Schema}
+	for i := range login {
+		if login[i] == current[i] {
+			continue
+		}
+		if login[i] == "" {
+			return fmt.Errorf("failed to reset the session context. %v is not set at the login", []string{
+				"role", "warehouse", "database", "schema"}[i])
+		}
+		*fields[i] = quoteIdentifier(login[i])
+	}
+	if opts == (UseOptions{}) {
+		return nil
+	}
+	if opts.Database != "" && opts.Schema == "" && sc.loginContext.Schema != "" {
+		// USE DATABASE switches the schema to PUBLIC
+		opts.Schema = quoteIdentifier(sc.loginContext.Schema)
+	}
+	return sc.Use(ctx, opts)
+}
+
+// queryInternal runs the internal query and returns the rows by lower case column names.
+func (sc *snowflakeConn) queryInternal(ctx context.Context, query string) ([]map[string]string, error) {
+	data, err := sc.exec(ctx, query, false, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows := sc.newRows(ctx, nil)
+	rows.columnNameCase = ColumnNameLower
+	if err = rows.setResult(data); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := rows.Columns()
+	dest := make([]driver.Value, len(cols))
+	var ret []map[string]string
+	for {
+		if err = rows.Next(dest); err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, c := range cols {
+			if dest[i] != nil {
+				row[c] = fmt.Sprint(dest[i])
+			}
+		}
+		ret = append(ret, row)
+	}
+}
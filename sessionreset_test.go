@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+// resetTestConn returns a connection to a fake session resetting the session by the mode.
+func resetTestConn(mode SessionResetMode) (*snowflakeConn, *fakeSession) {
+	sc, s := fakeSessionConn()
+	sc.cfg.SessionReset = mode
+	sc.loginParams = map[string]string{"QUERY_RESULT_FORMAT": "ARROW", "TIMEZONE": "UTC"}
+	return sc, s
+}
+
+func TestResetSession(t *testing.T) {
+	testcases := []struct {
+		mode SessionResetMode
+		sent []string
+	}{
+		{mode: "", sent: nil},
+		{mode: SessionResetNone, sent: nil},
+		{mode: SessionResetVariables, sent: []string{"SHOW VARIABLES", `UNSET ("A", "b")`}},
+		{mode: SessionResetFull, sent: []string{
+			`DROP TABLE IF EXISTS "DB"."PUBLIC".t1`,
+			`DROP TABLE IF EXISTS "X"."Y"."t2"`,
+			`DROP TABLE IF EXISTS "OTHER".s.t3`,
+			"SHOW VARIABLES",
+			`UNSET ("A", "b")`,
+			"SHOW PARAMETERS IN SESSION",
+			"ALTER SESSION SET TIMEZONE = 'UTC'",
+			"ALTER SESSION UNSET AUTOCOMMIT",
+			`USE DATABASE "DB"`,
+			`USE SCHEMA "PUBLIC"`,
+		}},
+	}
+	ctx := context.Background()
+	for _, test := range testcases {
+		sc, s := resetTestConn(test.mode)
+		for _, q := range []string{
+			"create temporary table t1 (id int)",
+			`CREATE OR REPLACE TEMP TABLE "X"."Y"."t2"(id int)`,
+			"USE DATABASE other",
+			"create temp table if not exists s.t3 as select 1",
+			"create table t4 (id int)",
+		} {
+			if _, err := sc.ExecContext(ctx, q, nil); err != nil {
+				t.Fatalf("failed to exec. err: %v", err)
+			}
+		}
+		s.vars = []string{"A", "b"}
+		s.params = [][4]string{
+			{"QUERY_RESULT_FORMAT", "ARROW", "SESSION", "STRING"},
+			{"TIMEZONE", "America/Los_Angeles", "SESSION", "STRING"},
+			{"AUTOCOMMIT", "false", "SESSION", "BOOLEAN"},
+			{"WEEK_START", "0", "", "NUMBER"},
+		}
+		s.sent = nil
+		if err := sc.ResetSession(ctx); err != nil {
+			t.Errorf("failed to reset the session. mode: %v, err: %v", test.mode, err)
+			continue
+		}
+		if strings.Join(s.sent, ";") != strings.Join(test.sent, ";") {
+			t.Errorf("failed to reset the session. mode: %v, expected: %v, got: %v", test.mode, test.sent, s.sent)
+		}
+		if test.mode == SessionResetFull && (sc.cfg.Database != "DB" || sc.cfg.Schema != "PUBLIC" || len(sc.tempTables) != 0) {
+			t.Errorf("failed to reset the context. database: %v, schema: %v, tables: %v", sc.cfg.Database, sc.cfg.Schema, sc.tempTables)
+		}
+		if !sc.IsValid() {
+			t.Errorf("should have been valid after the reset. mode: %v", test.mode)
+		}
+	}
+}
+
+func TestResetSessionFailure(t *testing.T) {
+	ctx := context.Background()
+	sc, s := resetTestConn(SessionResetFull)
+	s.fail = "SHOW VARIABLES"
+	if err := sc.ResetSession(ctx); err != driver.ErrBadConn {
+		t.Errorf("should have returned ErrBadConn. err: %v", err)
+	}
+	if sc.IsValid() {
+		t.Errorf("should have marked the connection bad")
+	}
+
+	// the database of the login can't be restored if none
+	sc, _ = resetTestConn(SessionResetFull)
+	sc.loginContext.Database = ""
+	sc.loginContext.Schema = ""
+	if err := sc.ResetSession(ctx); err != driver.ErrBadConn {
+		t.Errorf("should have returned ErrBadConn. err: %v", err)
+	}
+}
+
+func TestParseSessionResetMode(t *testing.T) {
+	for _, s := range []string{"none", "variables", "full"} {
+		if m, err := parseSessionResetMode(s); err != nil || string(m) != s {
+			t.Errorf("failed to parse %v. err: %v", s, err)
+		}
+	}
+	if _, err := parseSessionResetMode("all"); err == nil {
+		t.Errorf("should have failed to parse all")
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sfmock
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type mockConn struct {
+	mock *Mock
+}
+
+func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *mockConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &mockStmt{conn: c, query: query}, nil
+}
+
+func (c *mockConn) Close() error {
+	return nil
+}
+
+func (c *mockConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *mockConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.run(ctx, expectBegin, "BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return &mockTx{conn: c}, nil
+}
+
+func (c *mockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.run(ctx, expectExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &mockResult{rowsAffected: e.rowsAffected, queryID: e.queryID}, nil
+}
+
+func (c *mockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.run(ctx, expectQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	rows := e.rows
+	if len(rows) == 0 {
+		rows = []*Rows{NewRows()}
+	}
+	return &mockRows{queryID: e.queryID, resultSets: rows}, nil
+}
+
+// CheckNamedValue accepts any value, so that the expectations can verify the Snowflake specific
+// binding values as they are.
+func (c *mockConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err == nil {
+		nv.Value = v
+	}
+	return nil
+}
+
+// run matches the statement with the next expectation and applies its delay. The returned
+// expectation is a copy carrying the query ID assigned to the statement.
+func (c *mockConn) run(ctx context.Context, kind expectationKind, query string, args []driver.NamedValue) (*Expectation, error) {
+	e, queryID, err := c.mock.next(kind, query, args)
+	if e == nil {
+		return nil, err
+	}
+	if e.delay > 0 {
+		select {
+		case <-time.After(e.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := *e
+	ret.queryID = queryID
+	return &ret, nil
+}
+
+type mockStmt struct {
+	conn  *mockConn
+	query string
+}
+
+func (s *mockStmt) Close() error {
+	return nil
+}
+
+func (s *mockStmt) NumInput() int {
+	return -1
+}
+
+func (s *mockStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), toNamedValues(args))
+}
+
+func (s *mockStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *mockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), toNamedValues(args))
+}
+
+func (s *mockStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func toNamedValues(args []driver.Value) []driver.NamedValue {
+	ret := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		ret[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return ret
+}
+
+type mockTx struct {
+	conn *mockConn
+}
+
+func (tx *mockTx) Commit() error {
+	_, err := tx.conn.run(context.Background(), expectCommit, "COMMIT", nil)
+	return err
+}
+
+func (tx *mockTx) Rollback() error {
+	_, err := tx.conn.run(context.Background(), expectRollback, "ROLLBACK", nil)
+	return err
+}
+
+type mockResult struct {
+	rowsAffected int64
+	queryID      string
+}
+
+func (r *mockResult) LastInsertId() (int64, error) {
+	return -1, nil
+}
+
+func (r *mockResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// QueryID returns the query ID of the statement.
+func (r *mockResult) QueryID() string {
+	return r.queryID
+}
+
+type mockRows struct {
+	queryID    string
+	resultSets []*Rows
+	current    int
+	pos        int
+}
+
+func (r *mockRows) set() *Rows {
+	return r.resultSets[r.current]
+}
+
+// QueryID returns the query ID of the statement.
+func (r *mockRows) QueryID() string {
+	return r.queryID
+}
+
+func (r *mockRows) Columns() []string {
+	cols := r.set().columns
+	ret := make([]string, len(cols))
+	for i, c := range cols {
+		ret[i] = c.Name
+	}
+	return ret
+}
+
+func (r *mockRows) Close() error {
+	return nil
+}
+
+func (r *mockRows) Next(dest []driver.Value) error {
+	s := r.set()
+	if r.pos >= len(s.values) {
+		return io.EOF
+	}
+	copy(dest, s.values[r.pos])
+	r.pos++
+	return nil
+}
+
+func (r *mockRows) HasNextResultSet() bool {
+	return r.current+1 < len(r.resultSets)
+}
+
+func (r *mockRows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.current++
+	r.pos = 0
+	return nil
+}
+
+func (r *mockRows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.set().columns[index].Type)
+}
+
+func (r *mockRows) ColumnTypeLength(index int) (int64, bool) {
+	c := r.set().columns[index]
+	switch strings.ToUpper(c.Type) {
+	case "TEXT", "VARIANT", "OBJECT", "ARRAY", "BINARY":
+		return c.Length, true
+	}
+	return 0, false
+}
+
+func (r *mockRows) ColumnTypeNullable(index int) (bool, bool) {
+	return r.set().columns[index].Nullable, true
+}
+
+func (r *mockRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	c := r.set().columns[index]
+	switch strings.ToUpper(c.Type) {
+	case "FIXED":
+		return c.Precision, c.Scale, true
+	}
+	return 0, 0, false
+}
+
+// ColumnTypeScanType returns the Go type the Go Snowflake Driver scans the column into.
+func (r *mockRows) ColumnTypeScanType(index int) reflect.Type {
+	c := r.set().columns[index]
+	switch strings.ToUpper(c.Type) {
+	case "FIXED":
+		if c.Scale == 0 {
+			return reflect.TypeOf(int64(0))
+		}
+		return reflect.TypeOf(float64(0))
+	case "REAL":
+		return reflect.TypeOf(float64(0))
+	case "DATE", "TIME", "TIMESTAMP_LTZ", "TIMESTAMP_NTZ", "TIMESTAMP_TZ":
+		return reflect.TypeOf(time.Time{})
+	case "BINARY":
+		return reflect.TypeOf([]byte{})
+	case "BOOLEAN":
+		return reflect.TypeOf(true)
+	}
+	return reflect.TypeOf("")
+}
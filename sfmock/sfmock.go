@@ -0,0 +1,331 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package sfmock provides a mock database/sql driver registered as "snowflake-mock" that behaves
+// like the Go Snowflake Driver from the application's point of view: every statement has a query
+// ID, columns carry Snowflake data types, a statement may return multiple result sets and PUT/GET
+// return the same columns as Snowflake does.
+//
+//	dsn, mock := sfmock.New()
+//	db, _ := sql.Open("snowflake-mock", dsn)
+//	mock.ExpectQuery("SELECT id FROM t").WillReturnRows(
+//		sfmock.NewRows(sfmock.Column{Name: "ID", Type: "FIXED"}).AddRow(int64(1)))
+//	...
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+package sfmock
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DriverName is the name the mock driver is registered with.
+const DriverName = "snowflake-mock"
+
+var (
+	mocksMutex = &sync.Mutex{}
+	mocks      = make(map[string]*Mock)
+	mockSeq    = 0
+)
+
+func init() {
+	sql.Register(DriverName, &mockDriver{})
+}
+
+// New creates a Mock and returns the DSN to open it with sql.Open("snowflake-mock", dsn).
+func New() (string, *Mock) {
+	mocksMutex.Lock()
+	defer mocksMutex.Unlock()
+	mockSeq++
+	dsn := fmt.Sprintf("sfmock-%d", mockSeq)
+	m := &Mock{}
+	mocks[dsn] = m
+	return dsn, m
+}
+
+type mockDriver struct{}
+
+func (d *mockDriver) Open(dsn string) (driver.Conn, error) {
+	mocksMutex.Lock()
+	defer mocksMutex.Unlock()
+	m, ok := mocks[dsn]
+	if !ok {
+		return nil, fmt.Errorf("sfmock: no mock is registered for DSN: %v", dsn)
+	}
+	return &mockConn{mock: m}, nil
+}
+
+// Column is a result set column. Type is the Snowflake data type, e.g., FIXED, TEXT, TIMESTAMP_NTZ.
+type Column struct {
+	Name      string
+	Type      string
+	Nullable  bool
+	Length    int64
+	Precision int64
+	Scale     int64
+}
+
+// Rows is a result set returned by an expectation.
+type Rows struct {
+	columns []Column
+	values  [][]driver.Value
+}
+
+// NewRows creates a result set with the given columns.
+func NewRows(columns ...Column) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row. The values are returned as is, so use the types the Go Snowflake Driver
+// returns for the column type, e.g., string for FIXED and TEXT, time.Time for TIMESTAMP_NTZ.
+func (r *Rows) AddRow(values ...driver.Value) *Rows {
+	r.values = append(r.values, values)
+	return r
+}
+
+// PutGetColumns are the columns of the result set returned by PUT.
+var PutGetColumns = []Column{
+	{Name: "source", Type: "TEXT"},
+	{Name: "target", Type: "TEXT"},
+	{Name: "source_size", Type: "FIXED"},
+	{Name: "target_size", Type: "FIXED"},
+	{Name: "source_compression", Type: "TEXT"},
+	{Name: "target_compression", Type: "TEXT"},
+	{Name: "status", Type: "TEXT"},
+	{Name: "message", Type: "TEXT"},
+}
+
+// getColumns are the columns of the result set returned by GET.
+var getColumns = []Column{
+	{Name: "file", Type: "TEXT"},
+	{Name: "size", Type: "FIXED"},
+	{Name: "status", Type: "TEXT"},
+	{Name: "message", Type: "TEXT"},
+}
+
+// PutFile describes a file in a PUT result.
+type PutFile struct {
+	Source            string
+	Target            string
+	SourceSize        int64
+	TargetSize        int64
+	SourceCompression string
+	TargetCompression string
+	Status            string // UPLOADED or SKIPPED
+	Message           string
+}
+
+// PutResult returns the result set of a PUT command uploading the files.
+func PutResult(files ...PutFile) *Rows {
+	r := NewRows(PutGetColumns...)
+	for _, f := range files {
+		r.AddRow(f.Source, f.Target, strconv.FormatInt(f.SourceSize, 10), strconv.FormatInt(f.TargetSize, 10),
+			f.SourceCompression, f.TargetCompression, f.Status, f.Message)
+	}
+	return r
+}
+
+// GetFile describes a file in a GET result.
+type GetFile struct {
+	File    string
+	Size    int64
+	Status  string // DOWNLOADED
+	Message string
+}
+
+// GetResult returns the result set of a GET command downloading the files.
+func GetResult(files ...GetFile) *Rows {
+	r := NewRows(getColumns...)
+	for _, f := range files {
+		r.AddRow(f.File, strconv.FormatInt(f.Size, 10), f.Status, f.Message)
+	}
+	return r
+}
+
+type expectationKind int
+
+const (
+	expectQuery expectationKind = iota
+	expectExec
+	expectBegin
+	expectCommit
+	expectRollback
+)
+
+func (k expectationKind) String() string {
+	switch k {
+	case expectQuery:
+		return "Query"
+	case expectExec:
+		return "Exec"
+	case expectBegin:
+		return "Begin"
+	case expectCommit:
+		return "Commit"
+	case expectRollback:
+		return "Rollback"
+	}
+	return "Unknown"
+}
+
+// Expectation is an expected statement.
+type Expectation struct {
+	kind         expectationKind
+	sqlRegexp    *regexp.Regexp
+	args         []driver.Value
+	checkArgs    bool
+	queryID      string
+	rows         []*Rows
+	rowsAffected int64
+	err          error
+	delay        time.Duration
+	triggered    bool
+}
+
+// WithArgs sets the expected binding parameters.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.checkArgs = true
+	e.args = make([]driver.Value, len(args))
+	for i, a := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(a)
+		if err != nil {
+			v = a
+		}
+		e.args[i] = v
+	}
+	return e
+}
+
+// WithQueryID sets the query ID of the statement. By default a unique query ID is generated.
+func (e *Expectation) WithQueryID(queryID string) *Expectation {
+	e.queryID = queryID
+	return e
+}
+
+// WillReturnRows sets the result sets. More than one result set emulates a multi statement query.
+func (e *Expectation) WillReturnRows(rows ...*Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult sets the number of affected rows returned by Exec.
+func (e *Expectation) WillReturnResult(rowsAffected int64) *Expectation {
+	e.rowsAffected = rowsAffected
+	return e
+}
+
+// WillReturnError makes the statement fail with the error.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelayFor delays the statement. The statement fails if the context is canceled meanwhile.
+func (e *Expectation) WillDelayFor(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// Mock holds the expected statements in order.
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	querySeq     int
+}
+
+func (m *Mock) expect(kind expectationKind, sqlRegexp string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{kind: kind}
+	if sqlRegexp != "" {
+		e.sqlRegexp = regexp.MustCompile(sqlRegexp)
+	}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// ExpectQuery expects a query matching the regular expression.
+func (m *Mock) ExpectQuery(sqlRegexp string) *Expectation {
+	return m.expect(expectQuery, sqlRegexp)
+}
+
+// ExpectExec expects a statement matching the regular expression to be executed.
+func (m *Mock) ExpectExec(sqlRegexp string) *Expectation {
+	return m.expect(expectExec, sqlRegexp)
+}
+
+// ExpectBegin expects a transaction to begin.
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(expectBegin, "")
+}
+
+// ExpectCommit expects a transaction to commit.
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(expectCommit, "")
+}
+
+// ExpectRollback expects a transaction to roll back.
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(expectRollback, "")
+}
+
+// ExpectationsWereMet returns an error if any expectation was not triggered.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expectations {
+		if !e.triggered {
+			if e.sqlRegexp != nil {
+				return fmt.Errorf("sfmock: expected %v %q was not triggered", e.kind, e.sqlRegexp)
+			}
+			return fmt.Errorf("sfmock: expected %v was not triggered", e.kind)
+		}
+	}
+	return nil
+}
+
+// next finds the next expectation and verifies it matches the statement.
+func (m *Mock) next(kind expectationKind, query string, args []driver.NamedValue) (*Expectation, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var e *Expectation
+	for _, ee := range m.expectations {
+		if !ee.triggered {
+			e = ee
+			break
+		}
+	}
+	if e == nil {
+		return nil, "", fmt.Errorf("sfmock: unexpected %v: %q", kind, query)
+	}
+	if e.kind != kind {
+		return nil, "", fmt.Errorf("sfmock: expected %v, but got %v: %q", e.kind, kind, query)
+	}
+	if e.sqlRegexp != nil && !e.sqlRegexp.MatchString(query) {
+		return nil, "", fmt.Errorf("sfmock: query %q doesn't match the expected %q", query, e.sqlRegexp)
+	}
+	if e.checkArgs {
+		if len(args) != len(e.args) {
+			return nil, "", fmt.Errorf("sfmock: expected %v arguments, but got %v", len(e.args), len(args))
+		}
+		for i, a := range args {
+			if !reflect.DeepEqual(a.Value, e.args[i]) {
+				return nil, "", fmt.Errorf("sfmock: argument %v doesn't match. expected: %v, got: %v", i+1, e.args[i], a.Value)
+			}
+		}
+	}
+	e.triggered = true
+	m.querySeq++
+	queryID := e.queryID
+	if queryID == "" {
+		queryID = fmt.Sprintf("01000000-0000-0000-0000-%012d", m.querySeq)
+	}
+	return e, queryID, e.err
+}
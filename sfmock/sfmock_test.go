@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sfmock_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func TestMockQuery(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name FROM t WHERE id = \\?").WithArgs(1).WithQueryID("qid-1").WillReturnRows(
+		sfmock.NewRows(
+			sfmock.Column{Name: "ID", Type: "FIXED", Precision: 38},
+			sfmock.Column{Name: "NAME", Type: "TEXT", Length: 16777216, Nullable: true}).
+			AddRow("1", "foo"))
+	rows, err := db.QueryContext(context.Background(), "SELECT id, name FROM t WHERE id = ?", 1)
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("failed to get column types. err: %v", err)
+	}
+	if types[0].DatabaseTypeName() != "FIXED" || types[0].ScanType().Kind().String() != "int64" {
+		t.Errorf("unexpected column type. name: %v, scan type: %v", types[0].DatabaseTypeName(), types[0].ScanType())
+	}
+	if l, ok := types[1].Length(); !ok || l != 16777216 {
+		t.Errorf("unexpected column length. length: %v, ok: %v", l, ok)
+	}
+	var id int64
+	var name string
+	if !rows.Next() {
+		t.Fatal("no row is returned")
+	}
+	if err = rows.Scan(&id, &name); err != nil {
+		t.Fatalf("failed to scan. err: %v", err)
+	}
+	if id != 1 || name != "foo" {
+		t.Errorf("unexpected row. id: %v, name: %v", id, name)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMockMultiStatementAndPut(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT 1; SELECT 2").WillReturnRows(
+		sfmock.NewRows(sfmock.Column{Name: "1", Type: "FIXED"}).AddRow("1"),
+		sfmock.NewRows(sfmock.Column{Name: "2", Type: "FIXED"}).AddRow("2"))
+	mock.ExpectQuery("PUT file://").WillReturnRows(sfmock.PutResult(sfmock.PutFile{
+		Source: "data.csv", Target: "data.csv.gz", SourceSize: 100, TargetSize: 64,
+		SourceCompression: "NONE", TargetCompression: "GZIP", Status: "UPLOADED",
+	}))
+	mock.ExpectExec("INSERT").WillReturnError(errors.New("insert failed"))
+
+	rows, err := db.Query("SELECT 1; SELECT 2")
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	var got []int64
+	for {
+		for rows.Next() {
+			var v int64
+			if err = rows.Scan(&v); err != nil {
+				t.Fatalf("failed to scan. err: %v", err)
+			}
+			got = append(got, v)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	rows.Close()
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("unexpected results: %v", got)
+	}
+
+	rows, err = db.Query("PUT file:///tmp/data.csv @~")
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	cols, _ := rows.Columns()
+	if len(cols) != 8 || cols[6] != "status" {
+		t.Errorf("unexpected PUT columns: %v", cols)
+	}
+	for rows.Next() {
+		var src, tgt, status, msg, srcComp, tgtComp string
+		var srcSize, tgtSize int64
+		if err = rows.Scan(&src, &tgt, &srcSize, &tgtSize, &srcComp, &tgtComp, &status, &msg); err != nil {
+			t.Fatalf("failed to scan. err: %v", err)
+		}
+		if status != "UPLOADED" || tgtSize != 64 {
+			t.Errorf("unexpected PUT result. status: %v, target size: %v", status, tgtSize)
+		}
+	}
+	rows.Close()
+
+	if _, err = db.Exec("INSERT INTO t VALUES(1)"); err == nil || err.Error() != "insert failed" {
+		t.Errorf("should have failed with the expected error. err: %v", err)
+	}
+	if _, err = db.Exec("DELETE FROM t"); err == nil {
+		t.Error("should have failed with an unexpected statement")
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
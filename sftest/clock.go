@@ -0,0 +1,80 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock that satisfies gosnowflake.Clock. The time moves only by Advance and Sleep,
+// so tests can fast-forward through token expiration, heartbeats and retry backoff:
+//
+//	c := sftest.NewClock(time.Now())
+//	gosnowflake.SetClock(c)
+//	defer gosnowflake.SetClock(nil)
+//	c.Advance(time.Hour)
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*clockWaiter
+}
+
+type clockWaiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+// NewClock returns a fake clock starting at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current fake time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the fake time once the clock is advanced by d or more.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &clockWaiter{deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- c.now
+		return w.c
+	}
+	c.waiters = append(c.waiters, w)
+	return w.c
+}
+
+// Sleep advances the clock by d and returns immediately.
+func (c *Clock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// Advance moves the clock forward by d and fires the expired timers.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !c.now.Before(w.deadline) {
+			w.c <- c.now
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	c.waiters = remaining
+}
+
+// Waiters returns the number of pending timers. Tests may poll it to know a goroutine is
+// waiting on the clock before advancing it.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/sftest"
+)
+
+func waitForCondition(t *testing.T, cond func() bool) {
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for a condition")
+}
+
+func TestClockTokenExpiration(t *testing.T) {
+	c := sftest.NewClock(time.Now())
+	sf.SetClock(c)
+	defer sf.SetClock(nil)
+
+	srv := sftest.NewServer()
+	defer srv.Close()
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping. err: %v", err)
+	}
+	c.Advance(2 * time.Hour)
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping. err: %v", err)
+	}
+	if n := srv.RequestCount("/session/token-request"); n != 1 {
+		t.Fatalf("expired token should have been renewed. got: %v", n)
+	}
+}
+
+func TestClockHeartbeat(t *testing.T) {
+	c := sftest.NewClock(time.Now())
+	sf.SetClock(c)
+	defer sf.SetClock(nil)
+
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetSessionParameter("CLIENT_SESSION_KEEP_ALIVE", true)
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping. err: %v", err)
+	}
+	// the ping is a heartbeat
+	n := srv.RequestCount("/session/heartbeat")
+	waitForCondition(t, func() bool { return c.Waiters() > 0 })
+	c.Advance(time.Hour)
+	waitForCondition(t, func() bool { return srv.RequestCount("/session/heartbeat") == n+1 })
+}
+
+func TestClockHealthCheckHeartbeat(t *testing.T) {
+	c := sftest.NewClock(time.Now())
+	sf.SetClock(c)
+	defer sf.SetClock(nil)
+
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetHealthCheckInterval(45 * time.Second)
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping. err: %v", err)
+	}
+	n := srv.RequestCount("/session/heartbeat")
+	waitForCondition(t, func() bool { return c.Waiters() > 0 })
+	c.Advance(45 * time.Second)
+	waitForCondition(t, func() bool { return srv.RequestCount("/session/heartbeat") == n+1 })
+	db.Close()
+	c.Advance(time.Hour)
+	if got := srv.RequestCount("/session/heartbeat"); got != n+1 {
+		t.Errorf("should have stopped the heartbeats of the closed connection. got: %v", got)
+	}
+
+	// no heartbeats if disabled
+	db, err = sql.Open("snowflake", srv.DSN("testuser", "testpassword")+"&disableHeartbeat=true")
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping. err: %v", err)
+	}
+	n = srv.RequestCount("/session/heartbeat")
+	c.Advance(time.Hour)
+	if got := srv.RequestCount("/session/heartbeat"); got != n {
+		t.Errorf("should not have sent the heartbeats. got: %v", got-n)
+	}
+}
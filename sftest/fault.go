@@ -0,0 +1,177 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FaultPoint identifies where in the request pipeline a Fault is injected.
+type FaultPoint string
+
+const (
+	// FaultPointLogin is the login request.
+	FaultPointLogin FaultPoint = "login"
+	// FaultPointQuery is the query request and the subsequent result polling.
+	FaultPointQuery FaultPoint = "query"
+	// FaultPointRenew is the session token renewal.
+	FaultPointRenew FaultPoint = "renew"
+	// FaultPointHeartbeat is the heartbeat request.
+	FaultPointHeartbeat FaultPoint = "heartbeat"
+	// FaultPointChunk is the download of the result set chunks.
+	FaultPointChunk FaultPoint = "chunk"
+	// FaultPointOther is any other request, e.g., close session or abort query.
+	FaultPointOther FaultPoint = "other"
+)
+
+// Fault describes a failure injected by FaultTransport.
+type Fault struct {
+	Point        FaultPoint
+	StatusCode   int           // if set, respond with the HTTP status without sending the request
+	Err          error         // if set, fail the request with the transport error
+	Delay        time.Duration // if set, delay the request
+	TruncateBody int           // if set, truncate the response body to the number of bytes
+	ExpireToken  bool          // if set, respond with the session expired error
+	Times        int           // number of times the fault is injected. 0 means every time.
+
+	injected int
+}
+
+// FaultTransport is a http.RoundTripper that injects failures into the requests sent via Base.
+// Use it with SnowflakeDriver.Transport to validate the retry and error handling configuration:
+//
+//	ft := sftest.NewFaultTransport(sf.SnowflakeTransport, &sftest.Fault{
+//		Point:      sftest.FaultPointQuery,
+//		StatusCode: http.StatusServiceUnavailable,
+//		Times:      2,
+//	})
+//	sql.Register("snowflake-faults", &sf.SnowflakeDriver{Transport: ft})
+type FaultTransport struct {
+	Base http.RoundTripper // http.DefaultTransport if nil
+
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+// NewFaultTransport returns a FaultTransport sending requests via base.
+func NewFaultTransport(base http.RoundTripper, faults ...*Fault) *FaultTransport {
+	return &FaultTransport{Base: base, faults: faults}
+}
+
+// Add adds a fault.
+func (t *FaultTransport) Add(f *Fault) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.faults = append(t.faults, f)
+}
+
+// Injected returns the number of times faults were injected at the given point.
+func (t *FaultTransport) Injected(p FaultPoint) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, f := range t.faults {
+		if f.Point == p {
+			n += f.injected
+		}
+	}
+	return n
+}
+
+// faultPointOf classifies the request.
+func faultPointOf(req *http.Request) FaultPoint {
+	p := req.URL.Path
+	switch {
+	case p == "/session/v1/login-request":
+		return FaultPointLogin
+	case p == "/queries/v1/query-request" || strings.HasPrefix(p, "/queries/") && strings.HasSuffix(p, "/result"):
+		return FaultPointQuery
+	case p == "/session/token-request":
+		return FaultPointRenew
+	case p == "/session/heartbeat":
+		return FaultPointHeartbeat
+	case strings.HasPrefix(p, "/session") || strings.HasPrefix(p, "/queries/"):
+		return FaultPointOther
+	case req.Method == "GET":
+		return FaultPointChunk
+	}
+	return FaultPointOther
+}
+
+// nextFault returns the fault to be injected for the request, if any.
+func (t *FaultTransport) nextFault(req *http.Request) *Fault {
+	p := faultPointOf(req)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, f := range t.faults {
+		if f.Point != p || f.Times > 0 && f.injected >= f.Times {
+			continue
+		}
+		f.injected++
+		return f
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := t.nextFault(req)
+	if f != nil && f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if f != nil && f.Err != nil {
+		return nil, f.Err
+	}
+	if f != nil && f.StatusCode != 0 {
+		return newResponse(req, f.StatusCode, http.StatusText(f.StatusCode)), nil
+	}
+	if f != nil && f.ExpireToken {
+		return newResponse(req, http.StatusOK, fmt.Sprintf(
+			`{"data":null,"code":"%v","message":"Session token has expired.","success":false}`,
+			sessionExpiredCode)), nil
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || f == nil || f.TruncateBody <= 0 {
+		return resp, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > f.TruncateBody {
+		b = b[:f.TruncateBody]
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	resp.ContentLength = int64(len(b))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func newResponse(req *http.Request, statusCode int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
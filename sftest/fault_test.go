@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest_test
+
+import (
+	"database/sql"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/sftest"
+)
+
+func TestFaultTransport(t *testing.T) {
+	c := sftest.NewClock(time.Now())
+	sf.SetClock(c)
+	defer sf.SetClock(nil)
+
+	srv := sftest.NewServer()
+	defer srv.Close()
+	ft := sftest.NewFaultTransport(nil,
+		&sftest.Fault{Point: sftest.FaultPointQuery, StatusCode: http.StatusServiceUnavailable, Times: 2},
+		&sftest.Fault{Point: sftest.FaultPointQuery, ExpireToken: true, Times: 1},
+	)
+	sql.Register("snowflake-faults", &sf.SnowflakeDriver{Transport: ft})
+	db, err := sql.Open("snowflake-faults", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("SELECT 1"); err != nil {
+		t.Fatalf("failed to run a query. err: %v", err)
+	}
+	if n := ft.Injected(sftest.FaultPointQuery); n != 3 {
+		t.Fatalf("wrong number of injected faults. expected: 3, got: %v", n)
+	}
+	if n := srv.RequestCount("/session/token-request"); n != 1 {
+		t.Fatalf("session should have been renewed once. got: %v", n)
+	}
+}
+
+func TestCloseRowsStopsChunkDownloads(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	chunks := make([][][]interface{}, 6)
+	for i := range chunks {
+		chunks[i] = [][]interface{}{{i + 2}}
+	}
+	srv.AddQuery("SELECT id FROM t", &sftest.Result{
+		Columns: []sftest.Column{{Name: "ID", Type: "fixed"}},
+		Rows:    [][]interface{}{{1}},
+		Chunks:  chunks,
+	})
+	// the chunk downloads keep failing, and are retried with the backoff of seconds
+	ft := sftest.NewFaultTransport(nil, &sftest.Fault{Point: sftest.FaultPointChunk, StatusCode: http.StatusServiceUnavailable})
+	sql.Register("snowflake-close-rows", &sf.SnowflakeDriver{Transport: ft})
+	db, err := sql.Open("snowflake-close-rows", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id FROM t")
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	if !rows.Next() {
+		t.Fatalf("failed to read the first row. err: %v", rows.Err())
+	}
+	for ft.Injected(sftest.FaultPointChunk) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatalf("failed to close the rows. err: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		if !strings.Contains(stacks, "gosnowflake.retryHTTP") && !strings.Contains(stacks, "gosnowflake.downloadChunk") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the chunk downloads are still running after the rows are closed:\n%v", stacks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in recorded fixtures.
+const redacted = "REDACTED"
+
+// sensitiveKeys are the JSON field names, in lower case, whose values are redacted.
+var sensitiveKeys = map[string]bool{
+	"password":          true,
+	"passcode":          true,
+	"token":             true,
+	"mastertoken":       true,
+	"sessiontoken":      true,
+	"oldsessiontoken":   true,
+	"idtoken":           true,
+	"remmetoken":        true,
+	"raw_saml_response": true,
+	"proof_key":         true,
+	"proofkey":          true,
+	"qrmk":              true,
+	"cookietoken":       true,
+	// the temporary credentials and the encryption key of the stage in the PUT and GET responses
+	"aws_key_id":          true,
+	"aws_secret_key":      true,
+	"aws_token":           true,
+	"azure_sas_token":     true,
+	"gcs_access_token":    true,
+	"querystagemasterkey": true,
+}
+
+// sensitiveHeaders are the HTTP headers, in canonical form, whose values are redacted.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Amz-Server-Side-Encryption-Customer-Key",
+	"X-Amz-Server-Side-Encryption-Customer-Key-Md5",
+}
+
+// sensitiveQueryParams are the URL query parameters whose values are redacted, e.g., presigned URL signatures.
+var sensitiveQueryParams = []string{
+	"X-Amz-Signature",
+	"X-Amz-Credential",
+	"X-Amz-Security-Token",
+	"Signature",
+	"sig",
+	"token",
+}
+
+// ignoredQueryParams are the URL query parameters that change on every run and are not used to match requests.
+var ignoredQueryParams = []string{
+	"requestId",
+	"request_guid",
+	"retryCount",
+	"retryReason",
+}
+
+// Interaction is a recorded HTTP request and response pair.
+type Interaction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeader  http.Header `json:"requestHeader,omitempty"`
+	RequestBody    string      `json:"requestBody,omitempty"`
+	StatusCode     int         `json:"statusCode"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   string      `json:"responseBody,omitempty"`
+}
+
+// key returns the value used to match a request with recorded interactions.
+func (i *Interaction) key() string {
+	return interactionKey(i.Method, i.URL)
+}
+
+func interactionKey(method, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return method + " " + rawURL
+	}
+	return fmt.Sprintf("%v %v://%v%v", method, u.Scheme, u.Host, u.Path)
+}
+
+// RecordingTransport is a http.RoundTripper that sends requests via Base and records the interactions
+// with all secrets redacted. Use it with SnowflakeDriver.Transport and call Save to write a fixture file.
+type RecordingTransport struct {
+	Base http.RoundTripper // http.DefaultTransport if nil
+
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+// NewRecordingTransport returns a RecordingTransport sending requests via base.
+func NewRecordingTransport(base http.RoundTripper) *RecordingTransport {
+	return &RecordingTransport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	recordedBody := reqBody
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		// record the compressed query requests in plain JSON, so they can be redacted and reviewed
+		if gr, err := gzip.NewReader(bytes.NewReader(reqBody)); err == nil {
+			if b, err := ioutil.ReadAll(gr); err == nil {
+				recordedBody = b
+			}
+		}
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interactions = append(t.interactions, &Interaction{
+		Method:         req.Method,
+		URL:            redactURL(req.URL.String()),
+		RequestHeader:  redactHeader(req.Header),
+		RequestBody:    redactBody(recordedBody),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: redactHeader(resp.Header),
+		ResponseBody:   redactBody(respBody),
+	})
+	return resp, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (t *RecordingTransport) Interactions() []*Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := make([]*Interaction, len(t.interactions))
+	copy(ret, t.interactions)
+	return ret
+}
+
+// Save writes the recorded interactions to a fixture file.
+func (t *RecordingTransport) Save(path string) error {
+	b, err := json.MarshalIndent(t.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// ReplayTransport is a http.RoundTripper that serves recorded interactions without network access.
+// Requests are matched by method, scheme, host and path, and the recorded responses for the same
+// request are returned in the recorded order.
+type ReplayTransport struct {
+	mu    sync.Mutex
+	queue map[string][]*Interaction
+}
+
+// NewReplayTransport returns a ReplayTransport serving the given interactions.
+func NewReplayTransport(interactions []*Interaction) *ReplayTransport {
+	t := &ReplayTransport{
+		queue: make(map[string][]*Interaction),
+	}
+	for _, i := range interactions {
+		k := i.key()
+		t.queue[k] = append(t.queue[k], i)
+	}
+	return t
+}
+
+// LoadReplayTransport reads a fixture file written by RecordingTransport.Save.
+func LoadReplayTransport(path string) (*ReplayTransport, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []*Interaction
+	if err = json.Unmarshal(b, &interactions); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture file %v. err: %v", path, err)
+	}
+	return NewReplayTransport(interactions), nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	k := interactionKey(req.Method, req.URL.String())
+	t.mu.Lock()
+	q := t.queue[k]
+	if len(q) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("sftest: no recorded interaction for %v", k)
+	}
+	i := q[0]
+	t.queue[k] = q[1:]
+	t.mu.Unlock()
+
+	header := http.Header{}
+	for k, v := range i.ResponseHeader {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.StatusCode, http.StatusText(i.StatusCode)),
+		StatusCode:    i.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(i.ResponseBody)),
+		ContentLength: int64(len(i.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the number of recorded interactions not replayed yet.
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, q := range t.queue {
+		n += len(q)
+	}
+	return n
+}
+
+func redactHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	ret := http.Header{}
+	for k, v := range h {
+		ret[k] = append([]string(nil), v...)
+	}
+	for _, k := range sensitiveHeaders {
+		if _, ok := ret[k]; ok {
+			ret.Set(k, redacted)
+		}
+	}
+	return ret
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for _, k := range ignoredQueryParams {
+		q.Del(k)
+	}
+	for _, k := range sensitiveQueryParams {
+		if _, ok := q[k]; ok {
+			q.Set(k, redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// redactBody redacts secrets in a JSON body. Non JSON bodies, e.g., chunks, are returned as is.
+func redactBody(b []byte) string {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return string(b)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// multiple JSON values, e.g., chunk data
+		return string(b)
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, e := range vv {
+			switch {
+			case sensitiveKeys[strings.ToLower(k)]:
+				if e != nil {
+					vv[k] = redacted
+				}
+			case strings.ToLower(k) == "chunkheaders":
+				if m, ok := e.(map[string]interface{}); ok {
+					for hk := range m {
+						m[hk] = redacted
+					}
+				}
+			case strings.ToLower(k) == "url":
+				if s, ok := e.(string); ok {
+					vv[k] = redactURL(s)
+				}
+			default:
+				vv[k] = redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range vv {
+			vv[i] = redactValue(e)
+		}
+	}
+	return v
+}
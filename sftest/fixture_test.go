@@ -0,0 +1,159 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/sftest"
+)
+
+func runFixtureQuery(t *testing.T, driverName, dsn string) []string {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT name FROM t")
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	defer rows.Close()
+	var ret []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatalf("failed to scan. err: %v", err)
+		}
+		ret = append(ret, name)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("failed to fetch rows. err: %v", err)
+	}
+	return ret
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftest")
+	if err != nil {
+		t.Fatalf("failed to create a temp dir. err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.json")
+
+	srv := sftest.NewServer()
+	srv.AddQuery("SELECT name FROM t", &sftest.Result{
+		Columns: []sftest.Column{{Name: "NAME", Type: "text"}},
+		Rows:    [][]interface{}{{"a"}},
+		Chunks:  [][][]interface{}{{{"b"}, {"c"}}},
+	})
+	dsn := srv.DSN("testuser", "secretpassword")
+
+	recorder := sftest.NewRecordingTransport(nil)
+	sql.Register("snowflake-record", &sf.SnowflakeDriver{Transport: recorder})
+	recorded := runFixtureQuery(t, "snowflake-record", dsn)
+	if err = recorder.Save(fixture); err != nil {
+		t.Fatalf("failed to save fixture. err: %v", err)
+	}
+	srv.Close()
+
+	b, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("failed to read fixture. err: %v", err)
+	}
+	for _, secret := range []string{"secretpassword", "session-token-1", "master-token-1", "fakeqrmk"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("fixture includes a secret: %v", secret)
+		}
+	}
+
+	replayer, err := sftest.LoadReplayTransport(fixture)
+	if err != nil {
+		t.Fatalf("failed to load fixture. err: %v", err)
+	}
+	sql.Register("snowflake-replay", &sf.SnowflakeDriver{Transport: replayer})
+	replayed := runFixtureQuery(t, "snowflake-replay", dsn)
+	if strings.Join(recorded, ",") != "a,b,c" || strings.Join(replayed, ",") != "a,b,c" {
+		t.Fatalf("unexpected result. recorded: %v, replayed: %v", recorded, replayed)
+	}
+}
+
+func TestOpenWithConfigTransport(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	cfg, err := sf.ParseDSN(srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to parse DSN. err: %v", err)
+	}
+	cfgTransport := sftest.NewRecordingTransport(nil)
+	driverTransport := sftest.NewRecordingTransport(nil)
+	cfg.Transport = cfgTransport
+	conn, err := sf.SnowflakeDriver{Transport: driverTransport}.OpenWithConfig(context.Background(), *cfg)
+	if err != nil {
+		t.Fatalf("failed to open a connection. err: %v", err)
+	}
+	conn.Close()
+	if len(cfgTransport.Interactions()) == 0 {
+		t.Errorf("failed to send the requests via Config.Transport")
+	}
+	if len(driverTransport.Interactions()) != 0 {
+		t.Errorf("should have sent no requests via SnowflakeDriver.Transport. got: %v", len(driverTransport.Interactions()))
+	}
+
+	cfg.Transport = nil
+	conn, err = sf.SnowflakeDriver{Transport: driverTransport}.OpenWithConfig(context.Background(), *cfg)
+	if err != nil {
+		t.Fatalf("failed to open a connection. err: %v", err)
+	}
+	conn.Close()
+	if len(driverTransport.Interactions()) == 0 {
+		t.Errorf("failed to send the requests via SnowflakeDriver.Transport")
+	}
+}
+
+const putResponse = `{"success":true,"data":{"command":"UPLOAD","src_locations":["/tmp/data.csv"],
+"stageInfo":{"locationType":"S3","location":"sfc-stage/tmp/","region":"us-west-2",
+"creds":{"AWS_KEY_ID":"AKIAFAKEKEYID","AWS_SECRET_KEY":"fakesecretkey","AWS_TOKEN":"fakeawstoken",
+"AZURE_SAS_TOKEN":"fakesastoken","GCS_ACCESS_TOKEN":"fakegcstoken"}},
+"encryptionMaterial":{"queryStageMasterKey":"fakestagemasterkey","queryId":"01a2","smkId":42}}}`
+
+func TestRecordPutResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(putResponse))
+	}))
+	defer srv.Close()
+	recorder := sftest.NewRecordingTransport(nil)
+	client := &http.Client{Transport: recorder}
+	resp, err := client.Post(srv.URL+"/queries/v1/query-request", "application/json",
+		strings.NewReader(`{"sqlText":"PUT file:///tmp/data.csv @~"}`))
+	if err != nil {
+		t.Fatalf("failed to send the request. err: %v", err)
+	}
+	resp.Body.Close()
+
+	interactions := recorder.Interactions()
+	if len(interactions) != 1 {
+		t.Fatalf("unexpected interactions: %v", len(interactions))
+	}
+	body := interactions[0].ResponseBody
+	for _, secret := range []string{"AKIAFAKEKEYID", "fakesecretkey", "fakeawstoken", "fakesastoken", "fakegcstoken", "fakestagemasterkey"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("fixture includes a secret: %v", secret)
+		}
+	}
+	for _, kept := range []string{"sfc-stage/tmp/", "us-west-2", "01a2"} {
+		if !strings.Contains(body, kept) {
+			t.Errorf("fixture should have kept %v. body: %v", kept, body)
+		}
+	}
+}
@@ -0,0 +1,696 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package sftest provides an in-process fake of the Snowflake REST endpoints used by the
+// Go Snowflake Driver. It is intended for application tests that cannot reach a real account:
+//
+//	srv := sftest.NewServer()
+//	defer srv.Close()
+//	srv.AddQuery("SELECT 1", &sftest.Result{
+//		Columns: []sftest.Column{{Name: "1", Type: "fixed"}},
+//		Rows:    [][]interface{}{{1}},
+//	})
+//	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+//
+// Values in Result.Rows are sent in the Snowflake wire format, that is, as strings. For example,
+// DATE is the number of days since the epoch and TIMESTAMP_NTZ is the number of seconds with the
+// fraction, e.g., "1514764800.123456789".
+package sftest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+const (
+	sessionExpiredCode = "390112"
+	authFailedCode     = "390100"
+	oauthExpiredCode   = "390318"
+	jwtInvalidCode     = "390144"
+
+	statementCountMismatchCode = "000008"
+	queryNotFoundCode          = "000709"
+	multiStatementCountParam   = "MULTI_STATEMENT_COUNT"
+	multiStatementTypeID       = 0x1000
+
+	defaultAccount = "testaccount"
+)
+
+// Column is a column definition in a Result.
+type Column struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"` // Snowflake internal type name, e.g., fixed, text, timestamp_ntz
+	Length    int64  `json:"length"`
+	Scale     int64  `json:"scale"`
+	Precision int64  `json:"precision"`
+	Nullable  bool   `json:"nullable"`
+}
+
+// Error is a server side error returned instead of a result.
+type Error struct {
+	Code     string // Snowflake error number, e.g., "002003"
+	SQLState string
+	Message  string
+}
+
+// Result is a scripted response for a query.
+type Result struct {
+	QueryID         string
+	Columns         []Column
+	Rows            [][]interface{}   // first rowset returned with the query response
+	Chunks          [][][]interface{} // additional rowsets downloaded via the chunk endpoint
+	StatementTypeID int64             // e.g., 0x3100 for INSERT
+	Error           *Error
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// QueryRequest is the decoded body of a query request.
+type QueryRequest struct {
+	SQLText    string                       `json:"sqlText"`
+	AsyncExec  bool                         `json:"asyncExec"`
+	SequenceID uint64                       `json:"sequenceId"`
+	IsInternal bool                         `json:"isInternal"`
+	Parameters map[string]interface{}       `json:"parameters,omitempty"`
+	Bindings   map[string]QueryBindingValue `json:"bindings,omitempty"`
+
+	DescribeOnly bool `json:"describeOnly,omitempty"`
+}
+
+// QueryBindingValue is a binding parameter in a query request.
+type QueryBindingValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Server is a fake Snowflake server.
+type Server struct {
+	*httptest.Server
+
+	// QueryHandler is called for queries that don't have a scripted result. If nil, a query
+	// without a scripted result fails with a SQL compilation error.
+	QueryHandler func(req *QueryRequest) *Result
+
+	mu             sync.Mutex
+	users          map[string]string
+	oauthTokens    map[string]bool
+	publicKeys     map[string]*rsa.PublicKey // by fingerprint
+	queries        map[string]*Result
+	results        map[string]*Result
+	requests       []*Request
+	sessionParams  map[string]interface{}
+	token          string
+	masterToken    string
+	tokenCounter   int
+	queryCounter   int
+	expireNext     bool
+	closedSessions int
+
+	healthCheckInterval time.Duration
+}
+
+// NewServer starts and returns a new fake server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		users:         make(map[string]string),
+		oauthTokens:   make(map[string]bool),
+		publicKeys:    make(map[string]*rsa.PublicKey),
+		queries:       make(map[string]*Result),
+		results:       make(map[string]*Result),
+		sessionParams: make(map[string]interface{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/session/v1/login-request", s.handleLogin)
+	mux.HandleFunc("/session/token-request", s.handleTokenRequest)
+	mux.HandleFunc("/session/heartbeat", s.handleHeartbeat)
+	mux.HandleFunc("/session", s.handleSession)
+	mux.HandleFunc("/queries/v1/query-request", s.handleQuery)
+	mux.HandleFunc("/queries/v1/abort-request", s.handleAbort)
+	mux.HandleFunc("/queries/", s.handleQueryResult)
+	mux.HandleFunc("/chunks/", s.handleChunk)
+	mux.HandleFunc("/telemetry/send", s.handleTelemetry)
+	s.Server = httptest.NewServer(s.record(mux))
+
+	// statements the driver issues by itself
+	for _, q := range []string{"BEGIN", "COMMIT", "ROLLBACK"} {
+		s.queries[q] = &Result{}
+	}
+	s.queries["SELECT 1"] = &Result{
+		Columns: []Column{{Name: "1", Type: "fixed"}},
+		Rows:    [][]interface{}{{1}},
+	}
+	return s
+}
+
+// Host returns the host name of the server.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port number of the server.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// DSN returns a DSN connecting to the server with the given credentials.
+func (s *Server) DSN(user, password string) string {
+	return fmt.Sprintf("%v:%v@%v:%v?account=%v&protocol=http",
+		url.QueryEscape(user), url.QueryEscape(password), s.Host(), s.Port(), defaultAccount)
+}
+
+// SetUser registers a user. If no user is registered, any credential is accepted.
+func (s *Server) SetUser(user, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user] = password
+}
+
+// SetOAuthTokens sets the OAuth access tokens accepted by the OAUTH authenticator. The other tokens are
+// rejected as expired. If no token is set, any token is accepted.
+func (s *Server) SetOAuthTokens(tokens ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oauthTokens = make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		s.oauthTokens[t] = true
+	}
+}
+
+// SetPublicKeys sets the public keys accepted by the SNOWFLAKE_JWT authenticator, like RSA_PUBLIC_KEY and
+// RSA_PUBLIC_KEY_2 of the user. The JWT signed by the other keys is rejected as invalid. If no key is set, any JWT
+// is accepted.
+func (s *Server) SetPublicKeys(keys ...*rsa.PublicKey) error {
+	publicKeys := make(map[string]*rsa.PublicKey, len(keys))
+	for _, k := range keys {
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(der)
+		publicKeys["SHA256:"+base64.StdEncoding.EncodeToString(sum[:])] = k
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publicKeys = publicKeys
+	return nil
+}
+
+// verifyJWT returns true if the JWT is signed by the public key of its issuer.
+func (s *Server) verifyJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err = json.Unmarshal(b, &claims); err != nil {
+		return false
+	}
+	i := strings.Index(claims.Issuer, ".SHA256:")
+	if i < 0 {
+		return false
+	}
+	key, ok := s.publicKeys[claims.Issuer[i+1:]]
+	if !ok {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+}
+
+// SetSessionParameter sets a session parameter returned by the login response.
+func (s *Server) SetSessionParameter(name string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionParams[name] = value
+}
+
+// SetHealthCheckInterval sets the health check interval returned by the login response, at which the connections
+// send the heartbeats. No interval is returned by default.
+func (s *Server) SetHealthCheckInterval(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthCheckInterval = d
+}
+
+// AddQuery scripts the result of a query. The SQL text must match exactly after trimming spaces.
+func (s *Server) AddQuery(sqlText string, r *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries[strings.TrimSpace(sqlText)] = r
+}
+
+// ExpireSession makes the next authenticated request fail with a session expired error so that
+// the client renews the session token.
+func (s *Server) ExpireSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireNext = true
+}
+
+// Requests returns the requests received by the server so far.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]*Request, len(s.requests))
+	copy(ret, s.requests)
+	return ret
+}
+
+// RequestCount returns the number of requests received for the given path.
+func (s *Server) RequestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// ClosedSessions returns the number of sessions closed by the client.
+func (s *Server) ClosedSessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closedSessions
+}
+
+// Queries returns the query requests received by the server so far.
+func (s *Server) Queries() []*QueryRequest {
+	var ret []*QueryRequest
+	for _, r := range s.Requests() {
+		if r.Path != "/queries/v1/query-request" {
+			continue
+		}
+		var qr QueryRequest
+		if err := json.Unmarshal(r.Body, &qr); err == nil {
+			ret = append(ret, &qr)
+		}
+	}
+	return ret
+}
+
+func (s *Server) record(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			// the driver compresses large query requests
+			if gr, err := gzip.NewReader(bytes.NewReader(b)); err == nil {
+				b, _ = ioutil.ReadAll(gr)
+			}
+			r.Header.Del("Content-Encoding")
+		}
+		r.Body = ioutil.NopCloser(strings.NewReader(string(b)))
+		s.mu.Lock()
+		s.requests = append(s.requests, &Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Header: r.Header,
+			Body:   b,
+		})
+		s.mu.Unlock()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func failure(code, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"data":    nil,
+		"code":    code,
+		"message": message,
+		"success": false,
+	}
+}
+
+// newTokens issues a new pair of session and master tokens. Caller must hold the lock.
+func (s *Server) newTokens() {
+	s.tokenCounter++
+	s.token = fmt.Sprintf("session-token-%v", s.tokenCounter)
+	s.masterToken = fmt.Sprintf("master-token-%v", s.tokenCounter)
+}
+
+// checkToken verifies the Authorization header has the current session token.
+func (s *Server) checkToken(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expireNext {
+		s.expireNext = false
+		writeJSON(w, failure(sessionExpiredCode, "Session token has expired."))
+		return false
+	}
+	if r.Header.Get("Authorization") != fmt.Sprintf("Snowflake Token=\"%v\"", s.token) {
+		writeJSON(w, failure(sessionExpiredCode, "Session token is invalid."))
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Data struct {
+			LoginName     string `json:"LOGIN_NAME"`
+			Password      string `json:"PASSWORD"`
+			Authenticator string `json:"AUTHENTICATOR"`
+			Token         string `json:"TOKEN"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.EqualFold(req.Data.Authenticator, "OAUTH") {
+		if len(s.oauthTokens) > 0 && !s.oauthTokens[req.Data.Token] {
+			writeJSON(w, failure(oauthExpiredCode, "OAuth access token expired."))
+			return
+		}
+	} else if strings.EqualFold(req.Data.Authenticator, "SNOWFLAKE_JWT") {
+		if len(s.publicKeys) > 0 && !s.verifyJWT(req.Data.Token) {
+			writeJSON(w, failure(jwtInvalidCode, "JWT token is invalid."))
+			return
+		}
+	} else if len(s.users) > 0 {
+		if p, ok := s.users[req.Data.LoginName]; !ok || p != req.Data.Password {
+			writeJSON(w, failure(authFailedCode, "Incorrect username or password was specified."))
+			return
+		}
+	}
+	s.newTokens()
+	params := make([]map[string]interface{}, 0, len(s.sessionParams))
+	for k, v := range s.sessionParams {
+		params = append(params, map[string]interface{}{"name": k, "value": v})
+	}
+	q := r.URL.Query()
+	data := map[string]interface{}{
+		"token":                   s.token,
+		"validityInSeconds":       3600,
+		"masterToken":             s.masterToken,
+		"masterValidityInSeconds": 14400,
+		"sessionId":               s.tokenCounter,
+		"parameters":              params,
+		"sessionInfo": map[string]interface{}{
+			"databaseName":  q.Get("databaseName"),
+			"schemaName":    q.Get("schemaName"),
+			"warehouseName": q.Get("warehouse"),
+			"roleName":      q.Get("roleName"),
+		},
+	}
+	if s.healthCheckInterval > 0 {
+		data["healthCheckInterval"] = int64(s.healthCheckInterval / time.Second)
+	}
+	writeJSON(w, map[string]interface{}{
+		"data":    data,
+		"success": true,
+	})
+}
+
+func (s *Server) handleTokenRequest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Authorization") != fmt.Sprintf("Snowflake Token=\"%v\"", s.masterToken) {
+		writeJSON(w, failure("390114", "Master token is invalid."))
+		return
+	}
+	s.newTokens()
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"sessionToken":        s.token,
+			"validityInSecondsST": 3600,
+			"masterToken":         s.masterToken,
+			"validityInSecondsMT": 14400,
+			"sessionId":           s.tokenCounter,
+		},
+		"success": true,
+	})
+}
+
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true})
+}
+
+// handleTelemetry accepts the client telemetry events, which are found in Requests.
+func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true})
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("delete") == "true" {
+		s.mu.Lock()
+		s.closedSessions++
+		s.mu.Unlock()
+	}
+	writeJSON(w, map[string]interface{}{"success": true})
+}
+
+func (s *Server) handleAbort(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true})
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+	var req QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if count, ok := req.Parameters[multiStatementCountParam]; ok {
+		writeJSON(w, s.runMultiStatement(&req, count))
+		return
+	}
+	if req.DescribeOnly {
+		// the statement is described by the columns of its result without the rows
+		queryID, res := s.runQuery(&req)
+		desc := *res
+		desc.Rows, desc.Chunks = nil, nil
+		writeJSON(w, s.resultResponse(queryID, &desc))
+		return
+	}
+	writeJSON(w, s.resultResponse(s.runQuery(&req)))
+}
+
+// runQuery looks up the result of the statement and registers it with the query ID.
+func (s *Server) runQuery(req *QueryRequest) (string, *Result) {
+	s.mu.Lock()
+	res, ok := s.queries[strings.TrimSpace(req.SQLText)]
+	handler := s.QueryHandler
+	s.mu.Unlock()
+	if !ok && handler != nil {
+		res = handler(req)
+	}
+	if res == nil {
+		res = &Result{
+			Error: &Error{
+				Code:     "001003",
+				SQLState: "42000",
+				Message:  fmt.Sprintf("SQL compilation error: no scripted result for %q", req.SQLText),
+			},
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queryCounter++
+	queryID := res.QueryID
+	if queryID == "" {
+		queryID = fmt.Sprintf("01000000-0000-0000-0000-%012d", s.queryCounter)
+	}
+	s.results[queryID] = res
+	return queryID, res
+}
+
+// runMultiStatement runs the statements of the query in order, and returns the response with their query IDs.
+// It fails at the first failing statement.
+func (s *Server) runMultiStatement(req *QueryRequest, count interface{}) map[string]interface{} {
+	stmts := sf.SplitStatements(req.SQLText)
+	if n, _ := strconv.Atoi(fmt.Sprint(count)); n != 0 && n != len(stmts) {
+		return failure(statementCountMismatchCode, fmt.Sprintf(
+			"Actual statement count %v did not match the desired statement count %v.", len(stmts), n))
+	}
+	var ids, types []string
+	for _, stmt := range stmts {
+		sub := *req
+		sub.SQLText = stmt
+		queryID, res := s.runQuery(&sub)
+		if res.Error != nil {
+			return s.resultResponse(queryID, res)
+		}
+		ids = append(ids, queryID)
+		types = append(types, strconv.FormatInt(res.StatementTypeID, 10))
+	}
+	s.mu.Lock()
+	s.queryCounter++
+	queryID := fmt.Sprintf("01000000-0000-0000-0000-%012d", s.queryCounter)
+	s.mu.Unlock()
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"queryId":         queryID,
+			"rowtype":         []Column{{Name: "multiple statement execution", Type: "text"}},
+			"rowset":          toRowSet([][]interface{}{{"Multiple statements executed successfully."}}),
+			"total":           1,
+			"returned":        1,
+			"statementTypeId": multiStatementTypeID,
+			"resultIds":       strings.Join(ids, ","),
+			"resultTypes":     strings.Join(types, ","),
+		},
+		"success": true,
+	}
+}
+
+// resultResponse returns the query response of the result.
+func (s *Server) resultResponse(queryID string, res *Result) map[string]interface{} {
+	if res.Error != nil {
+		return map[string]interface{}{
+			"data": map[string]interface{}{
+				"queryId":  queryID,
+				"sqlState": res.Error.SQLState,
+			},
+			"code":    res.Error.Code,
+			"message": res.Error.Message,
+			"success": false,
+		}
+	}
+	total := len(res.Rows)
+	chunks := make([]map[string]interface{}, len(res.Chunks))
+	for i, c := range res.Chunks {
+		total += len(c)
+		chunks[i] = map[string]interface{}{
+			"url":      fmt.Sprintf("%v/chunks/%v/%v", s.URL, queryID, i),
+			"rowCount": len(c),
+		}
+	}
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"queryId":         queryID,
+			"rowtype":         res.Columns,
+			"rowset":          toRowSet(res.Rows),
+			"total":           total,
+			"returned":        len(res.Rows),
+			"statementTypeId": res.StatementTypeID,
+			"chunks":          chunks,
+			"qrmk":            "fakeqrmk",
+		},
+		"success": true,
+	}
+}
+
+// handleQueryResult returns the result of a query by the query ID, e.g., of a statement of a multi-statement
+// query.
+func (s *Server) handleQueryResult(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/queries/")
+	if !strings.HasSuffix(path, "/result") {
+		http.NotFound(w, r)
+		return
+	}
+	queryID := strings.TrimSuffix(path, "/result")
+	s.mu.Lock()
+	res, ok := s.results[queryID]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, failure(queryNotFoundCode, fmt.Sprintf("Query %v not found.", queryID)))
+		return
+	}
+	writeJSON(w, s.resultResponse(queryID, res))
+}
+
+func (s *Server) handleChunk(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/chunks/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	res, ok := s.results[parts[0]]
+	s.mu.Unlock()
+	if !ok || idx < 0 || idx >= len(res.Chunks) {
+		http.NotFound(w, r)
+		return
+	}
+	// chunks are a sequence of JSON arrays without the enclosing brackets
+	rows := toRowSet(res.Chunks[idx])
+	encoded := make([]string, len(rows))
+	for i, row := range rows {
+		b, _ := json.Marshal(row)
+		encoded[i] = string(b)
+	}
+	w.Write([]byte(strings.Join(encoded, ",")))
+}
+
+// toRowSet converts the scripted values to the string based wire format.
+func toRowSet(rows [][]interface{}) [][]*string {
+	ret := make([][]*string, len(rows))
+	for i, row := range rows {
+		ret[i] = make([]*string, len(row))
+		for j, v := range row {
+			if v == nil {
+				continue
+			}
+			s := fmt.Sprint(v)
+			ret[i][j] = &s
+		}
+	}
+	return ret
+}
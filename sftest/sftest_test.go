@@ -0,0 +1,460 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package sftest_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake/sftest"
+)
+
+func TestServerQuery(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetUser("testuser", "testpassword")
+	srv.AddQuery("SELECT id, name FROM t", &sftest.Result{
+		Columns: []sftest.Column{{Name: "ID", Type: "fixed"}, {Name: "NAME", Type: "text", Nullable: true}},
+		Rows:    [][]interface{}{{1, "a"}, {2, nil}},
+		Chunks: [][][]interface{}{
+			{{3, "c"}, {4, "d"}},
+			{{5, "e"}},
+		},
+	})
+
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id, name FROM t")
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	defer rows.Close()
+	cnt := 0
+	for rows.Next() {
+		var id int
+		var name sql.NullString
+		if err = rows.Scan(&id, &name); err != nil {
+			t.Fatalf("failed to scan. err: %v", err)
+		}
+		cnt++
+		if id != cnt {
+			t.Errorf("wrong id. expected: %v, got: %v", cnt, id)
+		}
+		if id == 2 && name.Valid {
+			t.Errorf("should be NULL. got: %v", name.String)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("failed to fetch rows. err: %v", err)
+	}
+	if cnt != 5 {
+		t.Fatalf("wrong number of rows. expected: 5, got: %v", cnt)
+	}
+}
+
+func TestServerAuthFailure(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetUser("testuser", "testpassword")
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "wrong"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err == nil {
+		t.Fatal("should have failed to authenticate")
+	}
+}
+
+func TestServerSessionRenewal(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping. err: %v", err)
+	}
+	srv.ExpireSession()
+	if err = db.Ping(); err != nil {
+		t.Fatalf("failed to ping after session expiration. err: %v", err)
+	}
+	if n := srv.RequestCount("/session/token-request"); n != 1 {
+		t.Fatalf("session should have been renewed once. got: %v", n)
+	}
+}
+
+func TestServerOAuthTokenProvider(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetOAuthTokens("fresh")
+	dsn := srv.DSN("testuser", "") + "&authenticator=oauth&token=stale"
+
+	testcases := []struct {
+		tokens []string
+		calls  int
+		ok     bool
+	}{
+		{tokens: []string{"fresh"}, calls: 1, ok: true},
+		{tokens: []string{"stale", "fresh"}, calls: 2, ok: true},
+		{tokens: []string{"stale", "stale"}, calls: 2, ok: false},
+		{tokens: nil, calls: 1, ok: false},
+	}
+	for _, test := range testcases {
+		calls := 0
+		d := &sf.SnowflakeDriver{TokenProvider: func(ctx context.Context) (string, error) {
+			calls++
+			if calls > len(test.tokens) {
+				return "", errors.New("no token")
+			}
+			return test.tokens[calls-1], nil
+		}}
+		conn, err := d.Open(dsn)
+		if test.ok && err != nil {
+			t.Errorf("failed to login with tokens %v. err: %v", test.tokens, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("should have failed to login with tokens %v", test.tokens)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+		if calls != test.calls {
+			t.Errorf("token provider should have been called %v times. got: %v", test.calls, calls)
+		}
+	}
+}
+
+func TestServerKeyRotation(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	keys := make([]*rsa.PrivateKey, 3)
+	for i := range keys {
+		var err error
+		if keys[i], err = rsa.GenerateKey(rand.Reader, 1024); err != nil {
+			t.Fatalf("failed to generate a key. err: %v", err)
+		}
+	}
+	// the first key is rotated to the second one
+	if err := srv.SetPublicKeys(&keys[1].PublicKey); err != nil {
+		t.Fatalf("failed to set the public keys. err: %v", err)
+	}
+
+	testcases := []struct {
+		key    *rsa.PrivateKey
+		keys   []*rsa.PrivateKey
+		logins int
+		ok     bool
+	}{
+		{key: keys[1], logins: 1, ok: true},
+		{key: keys[0], keys: []*rsa.PrivateKey{keys[1]}, logins: 2, ok: true},
+		{keys: []*rsa.PrivateKey{keys[0], keys[2], keys[1]}, logins: 3, ok: true},
+		{key: keys[0], keys: []*rsa.PrivateKey{keys[2]}, logins: 2, ok: false},
+	}
+	logins := 0
+	for i, test := range testcases {
+		cfg := sf.Config{
+			Account:       "testaccount",
+			User:          "testuser",
+			Host:          srv.Host(),
+			Port:          srv.Port(),
+			Protocol:      "http",
+			Authenticator: "snowflake_jwt",
+			PrivateKey:    test.key,
+			PrivateKeys:   test.keys,
+		}
+		conn, err := sf.SnowflakeDriver{}.OpenWithConfig(context.Background(), cfg)
+		if test.ok && err != nil {
+			t.Errorf("failed to login. test: %v, err: %v", i, err)
+		}
+		if !test.ok {
+			if driverErr, ok := err.(*sf.SnowflakeError); !ok || driverErr.Number != 390144 {
+				t.Errorf("should have failed to login with the JWT. test: %v, err: %v", i, err)
+			}
+		}
+		if conn != nil {
+			conn.Close()
+		}
+		n := srv.RequestCount("/session/v1/login-request")
+		if n-logins != test.logins {
+			t.Errorf("failed to try the keys in order. test: %v, logins: %v", i, n-logins)
+		}
+		logins = n
+	}
+}
+
+func TestServerQueryError(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("DROP TABLE unknown"); err == nil {
+		t.Fatal("should have failed to run an unscripted query")
+	}
+	qs := srv.Queries()
+	if len(qs) == 0 || qs[len(qs)-1].SQLText != "DROP TABLE unknown" {
+		t.Fatalf("query was not recorded. %v", qs)
+	}
+}
+
+func TestServerDescribeOnly(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.AddQuery("SELECT id FROM described", &sftest.Result{
+		Columns: []sftest.Column{{Name: "ID", Type: "fixed"}},
+		Rows:    [][]interface{}{{1}},
+	})
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	stmt, err := db.Prepare("SELECT id FROM described")
+	if err != nil {
+		t.Fatalf("failed to prepare. err: %v", err)
+	}
+	defer stmt.Close()
+	var id int
+	if err = stmt.QueryRow().Scan(&id); err != nil || id != 1 {
+		t.Fatalf("failed to query. id: %v, err: %v", id, err)
+	}
+	qs := srv.Queries()
+	if len(qs) != 2 || !qs[0].DescribeOnly || qs[1].DescribeOnly {
+		t.Errorf("should have described the statement at Prepare and run it once. got: %v", qs)
+	}
+	if _, err = db.Prepare("SELECT id FROM missing"); err == nil {
+		t.Errorf("should have failed to prepare an unscripted query")
+	}
+}
+
+func TestServerMultiStatement(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.AddQuery("SELECT 2, 3", &sftest.Result{
+		Columns: []sftest.Column{{Name: "2", Type: "fixed"}, {Name: "3", Type: "fixed"}},
+		Rows:    [][]interface{}{{2, 3}},
+	})
+	srv.AddQuery("INSERT INTO t VALUES (1), (2)", &sftest.Result{
+		Columns:         []sftest.Column{{Name: "number of rows inserted", Type: "fixed"}},
+		Rows:            [][]interface{}{{2}},
+		StatementTypeID: 0x3100,
+	})
+	srv.AddQuery("CREATE TABLE t (c INT)", &sftest.Result{
+		Columns: []sftest.Column{{Name: "status", Type: "text"}},
+		Rows:    [][]interface{}{{"Table T successfully created."}},
+	})
+	db, err := sql.Open("snowflake", srv.DSN("testuser", "testpassword"))
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+
+	ctx := sf.WithMultiStatement(context.Background(), 0)
+	rows, err := db.QueryContext(ctx, "SELECT 1; SELECT 2, 3;")
+	if err != nil {
+		t.Fatalf("failed to query. err: %v", err)
+	}
+	defer rows.Close()
+	var got [][]int
+	for {
+		for rows.Next() {
+			cols, _ := rows.Columns()
+			vals := make([]int, len(cols))
+			dest := make([]interface{}, len(cols))
+			for i := range vals {
+				dest[i] = &vals[i]
+			}
+			if err = rows.Scan(dest...); err != nil {
+				t.Fatalf("failed to scan. err: %v", err)
+			}
+			got = append(got, vals)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("failed to read the results. err: %v", err)
+	}
+	if len(got) != 2 || len(got[0]) != 1 || got[0][0] != 1 || len(got[1]) != 2 || got[1][1] != 3 {
+		t.Errorf("failed to read the result of each statement. got: %v", got)
+	}
+
+	res, err := db.ExecContext(ctx, "CREATE TABLE t (c INT); INSERT INTO t VALUES (1), (2)")
+	if err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 2 {
+		t.Errorf("failed to get the rows affected. expected: 2, got: %v, err: %v", n, err)
+	}
+
+	if _, err = db.ExecContext(sf.WithMultiStatement(context.Background(), 3), "SELECT 1; SELECT 2, 3"); err == nil {
+		t.Error("should have failed with the statement count mismatch")
+	}
+	if _, err = db.ExecContext(ctx, "SELECT 1; DROP TABLE unknown; SELECT 2, 3"); err == nil {
+		t.Error("should have failed at the unscripted statement")
+	}
+}
+
+func TestServerConnector(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetUser("testuser", "testpassword")
+	srv.SetSessionParameter("TIMEZONE", "UTC")
+	srv.AddQuery("SELECT 1", &sftest.Result{
+		Columns: []sftest.Column{{Name: "1", Type: "fixed"}},
+		Rows:    [][]interface{}{{1}},
+	})
+
+	cfg := &sf.Config{
+		Account:  "testaccount",
+		User:     "testuser",
+		Password: "testpassword",
+		Protocol: "http",
+		Host:     srv.Host(),
+		Port:     srv.Port(),
+	}
+	var mu sync.Mutex
+	var queries []string
+	connector := sf.NewConnector(cfg, sf.WithInterceptor(func(ctx context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, query)
+		return query, args, nil
+	}))
+	cfg.Password = "changed"
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, ok := db.Driver().(sf.SnowflakeDriver); !ok {
+		t.Errorf("should have been the Snowflake driver. got: %T", db.Driver())
+	}
+
+	for i := 0; i < 2; i++ {
+		var v int
+		if err := db.QueryRow("SELECT 1").Scan(&v); err != nil || v != 1 {
+			t.Errorf("failed to query. v: %v, err: %v", v, err)
+		}
+	}
+	if len(queries) != 2 {
+		t.Errorf("failed to intercept the queries. got: %v", queries)
+	}
+	if cfg.Params != nil {
+		t.Errorf("should not have modified the config. params: %v", cfg.Params)
+	}
+}
+
+func TestServerSessionHooks(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetUser("testuser", "testpassword")
+	srv.AddQuery("USE SECONDARY ROLES ALL", &sftest.Result{
+		Columns: []sftest.Column{{Name: "status", Type: "text"}},
+		Rows:    [][]interface{}{{"Statement executed successfully."}},
+	})
+
+	var opened, closed []sf.SessionInfo
+	cfg := &sf.Config{
+		Account:  "testaccount",
+		User:     "testuser",
+		Password: "testpassword",
+		Protocol: "http",
+		Host:     srv.Host(),
+		Port:     srv.Port(),
+		OnSessionOpen: func(ctx context.Context, info sf.SessionInfo) error {
+			opened = append(opened, info)
+			_, err := info.Conn.ExecContext(ctx, "USE SECONDARY ROLES ALL", nil)
+			return err
+		},
+		OnSessionClose: func(ctx context.Context, info sf.SessionInfo) error {
+			closed = append(closed, info)
+			return nil
+		},
+	}
+	db := sql.OpenDB(sf.NewConnector(cfg))
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect. err: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("failed to close. err: %v", err)
+	}
+	if len(opened) != 1 || opened[0].SessionID == 0 || opened[0].User != "testuser" || opened[0].Account != "testaccount" {
+		t.Errorf("failed to run OnSessionOpen. got: %+v", opened)
+	}
+	if len(closed) != 1 || len(opened) == 1 && closed[0].SessionID != opened[0].SessionID {
+		t.Errorf("failed to run OnSessionClose. got: %+v", closed)
+	}
+	found := false
+	for _, q := range srv.Queries() {
+		if q.SQLText == "USE SECONDARY ROLES ALL" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("failed to run the statement of OnSessionOpen")
+	}
+
+	hookErr := errors.New("hook failed")
+	closed = nil
+	cfg.OnSessionOpen = func(ctx context.Context, info sf.SessionInfo) error {
+		return hookErr
+	}
+	db = sql.OpenDB(sf.NewConnector(cfg))
+	defer db.Close()
+	if err := db.Ping(); err != hookErr {
+		t.Errorf("should have failed to connect. err: %v", err)
+	}
+	if len(closed) != 0 {
+		t.Errorf("should not have run OnSessionClose for the failed session. got: %+v", closed)
+	}
+	if srv.ClosedSessions() != 2 {
+		t.Errorf("failed to close the sessions. got: %v", srv.ClosedSessions())
+	}
+}
+
+func TestServerTelemetry(t *testing.T) {
+	srv := sftest.NewServer()
+	defer srv.Close()
+	srv.SetUser("testuser", "testpassword")
+	srv.AddQuery("SELECT 1", &sftest.Result{
+		Columns: []sftest.Column{{Name: "1", Type: "fixed"}},
+		Rows:    [][]interface{}{{1}},
+	})
+
+	for _, disabled := range []bool{false, true} {
+		before := srv.RequestCount("/telemetry/send")
+		dsn := srv.DSN("testuser", "testpassword")
+		if disabled {
+			dsn += "&disableTelemetry=true"
+		}
+		db, err := sql.Open("snowflake", dsn)
+		if err != nil {
+			t.Fatalf("failed to open. err: %v", err)
+		}
+		var v int
+		if err = db.QueryRow("SELECT 1").Scan(&v); err != nil {
+			t.Fatalf("failed to query. err: %v", err)
+		}
+		if err = db.Close(); err != nil {
+			t.Fatalf("failed to close. err: %v", err)
+		}
+		sent := srv.RequestCount("/telemetry/send") - before
+		if disabled && sent != 0 || !disabled && sent != 1 {
+			t.Errorf("failed to send the telemetry on close. disabled: %v, sent: %v", disabled, sent)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package snowpipe is a client of the Snowpipe REST API, which queues staged files for loading by a pipe and
+// reports the files loaded. The client authenticates with the key pair of the user of a gosnowflake.Config,
+// i.e., Config.PrivateKey or Config.PrivateKeyFile, and the role of the user must have the OPERATE privilege
+// on the pipe. The pipe names are fully qualified, i.e., database.schema.pipe.
+//
+//	client, err := snowpipe.NewClient(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	_, err = client.InsertFiles(ctx, "db.public.orders_pipe", []snowpipe.StagedFile{{Path: "2018/01/orders.csv.gz"}})
+package snowpipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// File load statuses of the insert report and the load history.
+const (
+	StatusLoaded          = "LOADED"
+	StatusLoadInProgress  = "LOAD_IN_PROGRESS"
+	StatusLoadFailed      = "LOAD_FAILED"
+	StatusPartiallyLoaded = "PARTIALLY_LOADED"
+)
+
+// Client calls the Snowpipe REST API of the account.
+type Client struct {
+	cfg        sf.Config
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client with the account, user and private key of the config. The host, protocol, port
+// and transport of the config are used if set. Otherwise the host is derived from the account and region as the
+// connections of the driver do, by gosnowflake.ConfigHost.
+func NewClient(cfg *sf.Config) (*Client, error) {
+	if _, err := sf.KeyPairJWT(cfg); err != nil {
+		return nil, err
+	}
+	host, err := sf.ConfigHost(cfg)
+	if err != nil {
+		return nil, err
+	}
+	protocol := cfg.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	if cfg.Port != 0 {
+		host += ":" + strconv.Itoa(cfg.Port)
+	}
+	transport := cfg.Transport
+	if transport == nil {
+		transport = sf.SnowflakeTransport
+	}
+	return &Client{
+		cfg:        *cfg,
+		baseURL:    protocol + "://" + host,
+		httpClient: &http.Client{Transport: transport, Timeout: 60 * time.Second},
+	}, nil
+}
+
+// StagedFile is a file in the stage of the pipe. Path is relative to the stage location of the pipe. Size is
+// optional and helps Snowflake plan the load.
+type StagedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// InsertFilesResponse is the response of InsertFiles. ResponseCode is SUCCESS if the files are queued.
+type InsertFilesResponse struct {
+	RequestID    string `json:"requestId"`
+	ResponseCode string `json:"responseCode"`
+}
+
+// InsertFiles queues the staged files for loading by the pipe. The files are loaded asynchronously, so check
+// InsertReport or LoadHistoryScan for the outcome. Up to 5000 files may be queued in a request.
+func (c *Client) InsertFiles(ctx context.Context, pipe string, files []StagedFile) (*InsertFilesResponse, error) {
+	body, err := json.Marshal(map[string][]StagedFile{"files": files})
+	if err != nil {
+		return nil, err
+	}
+	var resp InsertFilesResponse
+	if err = c.do(ctx, "POST", pipe, "insertFiles", url.Values{}, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// FileEntry is the load status of a file.
+type FileEntry struct {
+	Path                   string    `json:"path"`
+	StageLocation          string    `json:"stageLocation"`
+	FileSize               int64     `json:"fileSize"`
+	TimeReceived           time.Time `json:"timeReceived"`
+	LastInsertTime         time.Time `json:"lastInsertTime"`
+	RowsInserted           int64     `json:"rowsInserted"`
+	RowsParsed             int64     `json:"rowsParsed"`
+	ErrorsSeen             int64     `json:"errorsSeen"`
+	ErrorLimit             int64     `json:"errorLimit"`
+	FirstError             string    `json:"firstError"`
+	FirstErrorLineNum      int64     `json:"firstErrorLineNum"`
+	FirstErrorCharacterPos int64     `json:"firstErrorCharacterPos"`
+	FirstErrorColumnName   string    `json:"firstErrorColumnName"`
+	SystemError            string    `json:"systemError"`
+	Complete               bool      `json:"complete"`
+	Status                 string    `json:"status"`
+}
+
+// InsertReport is the response of InsertReport.
+type InsertReport struct {
+	Pipe           string      `json:"pipe"`
+	CompleteResult bool        `json:"completeResult"`
+	NextBeginMark  string      `json:"nextBeginMark"`
+	Files          []FileEntry `json:"files"`
+	Statistics     struct {
+		ActiveFilesCount int64 `json:"activeFilesCount"`
+	} `json:"statistics"`
+}
+
+// InsertReport returns the files loaded by the pipe recently, up to 10000 files of the last 10 minutes. Pass
+// NextBeginMark of the previous report as beginMark to get the files loaded since, or an empty string.
+func (c *Client) InsertReport(ctx context.Context, pipe string, beginMark string) (*InsertReport, error) {
+	params := url.Values{}
+	if beginMark != "" {
+		params.Set("beginMark", beginMark)
+	}
+	var report InsertReport
+	if err := c.do(ctx, "GET", pipe, "insertReport", params, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// LoadHistory is the response of LoadHistoryScan.
+type LoadHistory struct {
+	Pipe               string      `json:"pipe"`
+	CompleteResult     bool        `json:"completeResult"`
+	StartTimeInclusive time.Time   `json:"startTimeInclusive"`
+	EndTimeExclusive   time.Time   `json:"endTimeExclusive"`
+	RangeStartTime     time.Time   `json:"rangeStartTime"`
+	RangeEndTime       time.Time   `json:"rangeEndTime"`
+	Files              []FileEntry `json:"files"`
+}
+
+// LoadHistoryScan returns the files loaded by the pipe between start and end, up to 10000 files. If end is zero,
+// the files loaded until now are returned. If CompleteResult is false, scan again from RangeEndTime.
+func (c *Client) LoadHistoryScan(ctx context.Context, pipe string, start, end time.Time) (*LoadHistory, error) {
+	params := url.Values{}
+	params.Set("startTimeInclusive", start.UTC().Format(time.RFC3339Nano))
+	if !end.IsZero() {
+		params.Set("endTimeExclusive", end.UTC().Format(time.RFC3339Nano))
+	}
+	var history LoadHistory
+	if err := c.do(ctx, "GET", pipe, "loadHistoryScan", params, nil, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// errorResponse is the response of a failed request.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// do sends the request to the endpoint of the pipe with a new JWT and a request ID, and decodes the response.
+func (c *Client) do(ctx context.Context, method, pipe, endpoint string, params url.Values, body []byte, v interface{}) error {
+	token, err := sf.KeyPairJWT(&c.cfg)
+	if err != nil {
+		return err
+	}
+	params.Set("requestId", uuid.New().String())
+	u := c.baseURL + "/v1/data/pipes/" + url.PathEscape(pipe) + "/" + endpoint + "?" + params.Encode()
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.Unmarshal(b, &e) != nil || e.Message == "" {
+			return fmt.Errorf("failed to call %v of pipe %v. HTTP: %v, body: %s", endpoint, pipe, resp.StatusCode, b)
+		}
+		code, _ := strconv.Atoi(e.Code)
+		return &sf.SnowflakeError{
+			Number:  code,
+			Message: e.Message,
+		}
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to parse the response of %v of pipe %v. err: %v", endpoint, pipe, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package snowpipe
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+func TestClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate a key. err: %v", err)
+	}
+	var body []byte
+	var query url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") ||
+			r.Header.Get("X-Snowflake-Authorization-Token-Type") != "KEYPAIR_JWT" {
+			t.Errorf("failed to authorize the request. header: %v", r.Header)
+		}
+		query = r.URL.Query()
+		if query.Get("requestId") == "" {
+			t.Errorf("failed to set the request ID. url: %v", r.URL)
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/v1/data/pipes/db.s.p/insertFiles":
+			w.Write([]byte(`{"requestId":"` + query.Get("requestId") + `","responseCode":"SUCCESS"}`))
+		case "/v1/data/pipes/db.s.p/insertReport":
+			w.Write([]byte(`{"pipe":"DB.S.P","completeResult":true,"nextBeginMark":"1_2","files":[
+{"path":"a.csv","stageLocation":"s3://b/","fileSize":10,"timeReceived":"2018-01-02T03:04:05.678Z",
+"rowsInserted":1,"rowsParsed":2,"errorsSeen":1,"errorLimit":2,"firstError":"Numeric value 'x' is not recognized",
+"firstErrorLineNum":2,"firstErrorCharacterPos":1,"firstErrorColumnName":"\"T\"[\"ID\":1]","complete":true,
+"status":"PARTIALLY_LOADED"}],"statistics":{"activeFilesCount":3}}`))
+		case "/v1/data/pipes/db.s.p/loadHistoryScan":
+			w.Write([]byte(`{"pipe":"DB.S.P","completeResult":false,"rangeStartTime":"2018-01-02T03:00:00Z",
+"rangeEndTime":"2018-01-02T04:00:00Z","files":[{"path":"a.csv","status":"LOADED","complete":true}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"data":null,"code":"390404","message":"Specified object does not exist","success":false}`))
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := &sf.Config{Account: "a", User: "u", PrivateKey: key, Protocol: "http", Host: u.Hostname(), Port: port}
+
+	if _, err = NewClient(&sf.Config{Account: "a", User: "u"}); err != sf.ErrEmptyPrivateKey {
+		t.Errorf("should have failed without a private key. err: %v", err)
+	}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create the client. err: %v", err)
+	}
+	ctx := context.Background()
+	resp, err := client.InsertFiles(ctx, "db.s.p", []StagedFile{{Path: "a.csv", Size: 10}, {Path: "b.csv"}})
+	if err != nil {
+		t.Fatalf("failed to insert the files. err: %v", err)
+	}
+	if resp.ResponseCode != "SUCCESS" || resp.RequestID == "" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	var files map[string][]StagedFile
+	if err = json.Unmarshal(body, &files); err != nil || len(files["files"]) != 2 || files["files"][0].Size != 10 {
+		t.Errorf("failed to send the files. body: %s", body)
+	}
+
+	report, err := client.InsertReport(ctx, "db.s.p", "1_1")
+	if err != nil {
+		t.Fatalf("failed to get the insert report. err: %v", err)
+	}
+	if query.Get("beginMark") != "1_1" {
+		t.Errorf("failed to send the begin mark. query: %v", query)
+	}
+	if report.NextBeginMark != "1_2" || report.Statistics.ActiveFilesCount != 3 || len(report.Files) != 1 {
+		t.Fatalf("unexpected report: %v", report)
+	}
+	if f := report.Files[0]; f.Status != StatusPartiallyLoaded || f.FirstErrorLineNum != 2 ||
+		!f.TimeReceived.Equal(time.Date(2018, 1, 2, 3, 4, 5, 678000000, time.UTC)) {
+		t.Errorf("unexpected file: %v", f)
+	}
+
+	start := time.Date(2018, 1, 2, 3, 0, 0, 0, time.UTC)
+	history, err := client.LoadHistoryScan(ctx, "db.s.p", start, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to scan the load history. err: %v", err)
+	}
+	if query.Get("startTimeInclusive") != "2018-01-02T03:00:00Z" || query.Get("endTimeExclusive") != "" {
+		t.Errorf("failed to send the time range. query: %v", query)
+	}
+	if history.CompleteResult || !history.RangeEndTime.Equal(start.Add(time.Hour)) || len(history.Files) != 1 {
+		t.Errorf("unexpected history: %v", history)
+	}
+
+	_, err = client.InsertReport(ctx, "db.s.missing", "")
+	if driverErr, ok := err.(*sf.SnowflakeError); !ok || driverErr.Number != 390404 {
+		t.Errorf("should have failed for the missing pipe. err: %v", err)
+	}
+}
+
+func TestNewClientHost(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate a key. err: %v", err)
+	}
+	testcases := []struct {
+		account string
+		region  string
+		baseURL string
+	}{
+		{account: "xy12345", baseURL: "https://xy12345.snowflakecomputing.com"},
+		{account: "xy12345", region: "us-west-2", baseURL: "https://xy12345.snowflakecomputing.com"},
+		{account: "xy12345", region: "east-us-2", baseURL: "https://xy12345.east-us-2.azure.snowflakecomputing.com"},
+		{account: "xy12345.us-central1.gcp", baseURL: "https://xy12345.us-central1.gcp.snowflakecomputing.com"},
+		{account: "xy12345", region: "us-east-1.privatelink", baseURL: "https://xy12345.us-east-1.privatelink.snowflakecomputing.com"},
+		{account: "myorg-my_account", baseURL: "https://myorg-my-account.snowflakecomputing.com"},
+	}
+	for _, test := range testcases {
+		client, err := NewClient(&sf.Config{Account: test.account, Region: test.region, User: "u", PrivateKey: key})
+		if err != nil {
+			t.Errorf("failed to create the client. account: %v, err: %v", test.account, err)
+			continue
+		}
+		if client.baseURL != test.baseURL {
+			t.Errorf("failed to get the host. account: %v, region: %v, expected: %v, got: %v",
+				test.account, test.region, test.baseURL, client.baseURL)
+		}
+	}
+	if _, err = NewClient(&sf.Config{Account: "myorg-myaccount", Region: "us-east-1", User: "u", PrivateKey: key}); err == nil {
+		t.Error("should have failed with the region of an organization account")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+// sqlByteKind is the lexical context of a byte of SQL text.
+type sqlByteKind uint8
+
+const (
+	// sqlCode is SQL code, where statements are separated and placeholders are found.
+	sqlCode sqlByteKind = iota
+	// sqlQuoted is a string literal or quoted identifier, including the quotes.
+	sqlQuoted
+	// sqlDollarBody is a $$ delimited body of a stored procedure or UDF, including the $$.
+	sqlDollarBody
+	// sqlComment is a --, // or /* */ comment, including the delimiters but not the newline ending a line comment.
+	sqlComment
+)
+
+// scanSQL returns the lexical context of each byte of the SQL text. It's shared by SplitStatements and the named
+// parameters, so that both agree on what is SQL code. The slashes following a colon start no comment, so that the
+// file:// URLs of PUT and GET are kept.
+func scanSQL(text string) []sqlByteKind {
+	kinds := make([]sqlByteKind, len(text))
+	state := sqlCode
+	var quote byte
+	lineComment := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		var next byte
+		if i+1 < len(text) {
+			next = text[i+1]
+		}
+		switch state {
+		case sqlComment:
+			switch {
+			case lineComment && c == '\n':
+				state = sqlCode
+				kinds[i] = sqlCode
+				continue
+			case !lineComment && c == '*' && next == '/':
+				state = sqlCode
+				kinds[i+1] = sqlComment
+				kinds[i] = sqlComment
+				i++
+				continue
+			}
+		case sqlDollarBody:
+			if c == '$' && next == '$' {
+				state = sqlCode
+				kinds[i+1] = sqlDollarBody
+				kinds[i] = sqlDollarBody
+				i++
+				continue
+			}
+		case sqlQuoted:
+			if c == quote {
+				state = sqlCode
+			} else if c == '\\' && quote == '\'' && i+1 < len(text) {
+				kinds[i+1] = sqlQuoted
+				kinds[i] = sqlQuoted
+				i++
+				continue
+			}
+			kinds[i] = sqlQuoted
+			continue
+		default:
+			switch {
+			case c == '\'' || c == '"':
+				state, quote = sqlQuoted, c
+			case c == '$' && next == '$':
+				state = sqlDollarBody
+				kinds[i+1] = sqlDollarBody
+				kinds[i] = sqlDollarBody
+				i++
+				continue
+			case c == '-' && next == '-', c == '/' && next == '/' && (i == 0 || text[i-1] != ':' && text[i-1] != '/'):
+				state, lineComment = sqlComment, true
+			case c == '/' && next == '*':
+				state, lineComment = sqlComment, false
+				kinds[i+1] = sqlComment
+				kinds[i] = sqlComment
+				i++
+				continue
+			}
+		}
+		kinds[i] = state
+	}
+	return kinds
+}
+
+// sqlCodeMask returns whether each byte of the query is SQL code, where placeholders are found.
+func sqlCodeMask(query string) []bool {
+	kinds := scanSQL(query)
+	code := make([]bool, len(kinds))
+	for i, k := range kinds {
+		code[i] = k == sqlCode
+	}
+	return code
+}
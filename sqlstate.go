@@ -15,4 +15,10 @@ const (
 	SQLStateConnectionFailure = "08006"
 	// SQLStateFeatureNotSupported is a SQL State code indicating the feature is not enabled.
 	SQLStateFeatureNotSupported = "0A000"
+	// SQLStateSyntaxError is a SQL State code indicating a syntax error or an access rule violation.
+	SQLStateSyntaxError = "42000"
+	// SQLStateQueryCanceled is a SQL State code indicating the query was canceled.
+	SQLStateQueryCanceled = "57014"
+	// SQLStateGeneralError is a SQL State code indicating a general error.
+	SQLStateGeneralError = "HY000"
 )
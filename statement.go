@@ -5,11 +5,49 @@ package gosnowflake
 import (
 	"context"
 	"database/sql/driver"
+	"strings"
 )
 
+// SnowflakeStmt is the interface of the prepared statements of the driver beyond database/sql/driver. The
+// statement is described at Prepare, so the result columns are known before the first execution. With Go 1.13
+// or later, prepare the statement in sql.Conn.Raw:
+//
+//	err = conn.Raw(func(dc interface{}) error {
+//		stmt, err := dc.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT * FROM orders")
+//		if err != nil {
+//			return err
+//		}
+//		defer stmt.Close()
+//		columns = stmt.(sf.SnowflakeStmt).ColumnTypes()
+//		return nil
+//	})
+type SnowflakeStmt interface {
+	driver.Stmt
+	// ColumnTypes returns the columns of the result set. It's empty if the statement returns no result set, and
+	// nil if the statement is not described, i.e., PUT, GET and multi-statement queries.
+	ColumnTypes() []ColumnType
+	// NumBinds returns the number of the bind parameters, or -1 if the statement is not described.
+	NumBinds() int
+	// BindTypes returns the Snowflake types of the bind parameters if returned by the server.
+	BindTypes() []string
+}
+
+// ColumnType is a column of the result set of a prepared statement.
+type ColumnType struct {
+	Name             string // name in the case of Config.ColumnNameCase
+	DatabaseTypeName string // Snowflake type, e.g., FIXED, TEXT or TIMESTAMP_NTZ
+	Length           int64  // maximum length of TEXT, BINARY and semi-structured columns
+	Precision        int64  // precision of FIXED columns
+	Scale            int64  // scale of FIXED and time columns
+	Nullable         bool
+}
+
+// snowflakeStmt is immutable, so it's safe to run concurrently. The state of each execution is kept in its
+// result or rows.
 type snowflakeStmt struct {
 	sc    *snowflakeConn
 	query string
+	desc  *statementDescription // nil if not described
 }
 
 func (stmt *snowflakeStmt) Close() error {
@@ -20,10 +58,39 @@ func (stmt *snowflakeStmt) Close() error {
 
 func (stmt *snowflakeStmt) NumInput() int {
 	glog.V(2).Infoln("Stmt.NumInput")
-	// Go Snowflake doesn't know the number of binding parameters.
+	// The binds described don't match the arguments for named and array bindings, so database/sql doesn't
+	// check the number of the arguments.
 	return -1
 }
 
+// ColumnTypes returns the columns of the result set described at Prepare.
+func (stmt *snowflakeStmt) ColumnTypes() []ColumnType {
+	if stmt.desc == nil {
+		return nil
+	}
+	ignoreCase := stmt.sc.isQuotedIdentifiersIgnoreCase()
+	ret := make([]ColumnType, len(stmt.desc.rowType))
+	for i, c := range stmt.desc.rowType {
+		ret[i] = ColumnType{
+			Name:             convertColumnName(stmt.sc.cfg.ColumnNameCase, c.Name, ignoreCase),
+			DatabaseTypeName: strings.ToUpper(c.Type),
+			Length:           c.Length,
+			Precision:        c.Precision,
+			Scale:            c.Scale,
+			Nullable:         c.Nullable,
+		}
+	}
+	return ret
+}
+
+// NumBinds returns the number of the bind parameters described at Prepare.
+func (stmt *snowflakeStmt) NumBinds() int {
+	if stmt.desc == nil {
+		return -1
+	}
+	return stmt.desc.numBinds
+}
+
 func (stmt *snowflakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
 	glog.V(2).Infoln("Stmt.ExecContext")
 	return stmt.sc.ExecContext(ctx, stmt.query, args)
@@ -43,3 +110,15 @@ func (stmt *snowflakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 	glog.V(2).Infoln("Stmt.Query")
 	return stmt.sc.Query(stmt.query, args)
 }
+
+// BindTypes returns the types of the bind parameters described at Prepare.
+func (stmt *snowflakeStmt) BindTypes() []string {
+	if stmt.desc == nil || stmt.desc.bindTypes == nil {
+		return nil
+	}
+	ret := make([]string, len(stmt.desc.bindTypes))
+	for i, b := range stmt.desc.bindTypes {
+		ret[i] = strings.ToUpper(b.Type)
+	}
+	return ret
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStmtConcurrentUse(t *testing.T) {
+	var n int64
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		if req.DescribeOnly {
+			return &execResponse{Success: true}, nil
+		}
+		atomic.AddInt64(&n, 1)
+		return &execResponse{
+			Success: true,
+			Data: execResponseData{
+				QueryID:    req.Bindings["1"].Value.(string),
+				Parameters: []nameValueParameter{{Name: "CLIENT_SESSION_KEEP_ALIVE", Value: false}, {Name: "TIMEZONE", Value: "UTC"}},
+			},
+		}, nil
+	}
+	sc := &snowflakeConn{
+		cfg:  &Config{Params: map[string]*string{}},
+		rest: &snowflakeRestful{FuncPostQuery: postQuery},
+	}
+	stmt, err := sc.PrepareContext(context.Background(), "select ? -- concurrent")
+	if err != nil {
+		t.Fatalf("failed to prepare. err: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rows, err := stmt.(driver.StmtQueryContext).QueryContext(context.Background(),
+				[]driver.NamedValue{{Ordinal: 1, Value: int64(i)}})
+			if err != nil {
+				errs <- err
+				return
+			}
+			rows.Close()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("failed to run concurrently. err: %v", err)
+	}
+	if n != 50 {
+		t.Errorf("failed to run all statements. got: %v", n)
+	}
+	if v := sc.cfg.Params["timezone"]; v == nil || *v != "UTC" {
+		t.Errorf("failed to update the session parameters. got: %v", v)
+	}
+}
+
+func TestStmtDescribe(t *testing.T) {
+	var described, executed int
+	schema := "PUBLIC"
+	postQuery := func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		var req execRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		if !req.DescribeOnly {
+			executed++
+			return &execResponse{Success: true}, nil
+		}
+		described++
+		if req.SQLText == "select * from missing" {
+			return &execResponse{Success: false, Code: "002003", Message: "Object 'MISSING' does not exist"}, nil
+		}
+		return &execResponse{Success: true, Data: execResponseData{
+			FinalDatabaseName: "DB",
+			FinalSchemaName:   schema,
+			RowType: []execResponseRowType{
+				{Name: "ID", Type: "fixed", Precision: 38, Scale: 0},
+				{Name: "Name", Type: "text", Length: 100, Nullable: true},
+			},
+			NumberOfBinds:   1,
+			MetaDataOfBinds: []execResponseRowType{{Name: "1", Type: "fixed"}},
+		}}, nil
+	}
+	sc := &snowflakeConn{
+		cfg:  &Config{Database: "DB", Schema: "PUBLIC", ColumnNameCase: ColumnNameNormalize},
+		rest: &snowflakeRestful{FuncPostQuery: postQuery},
+	}
+	ctx := context.Background()
+	query := "select id, \"Name\" from t where id = ? -- describe"
+	for i := 0; i < 2; i++ {
+		stmt, err := sc.PrepareContext(ctx, query)
+		if err != nil {
+			t.Fatalf("failed to prepare. err: %v", err)
+		}
+		st := stmt.(SnowflakeStmt)
+		cols := st.ColumnTypes()
+		if len(cols) != 2 || cols[0].Name != "id" || cols[0].DatabaseTypeName != "FIXED" || cols[0].Precision != 38 ||
+			cols[1].Name != "Name" || cols[1].Length != 100 || !cols[1].Nullable {
+			t.Errorf("unexpected columns: %+v", cols)
+		}
+		if st.NumBinds() != 1 || len(st.BindTypes()) != 1 || st.BindTypes()[0] != "FIXED" {
+			t.Errorf("unexpected binds. num: %v, types: %v", st.NumBinds(), st.BindTypes())
+		}
+	}
+	if described != 1 || executed != 0 {
+		t.Errorf("should have described the statement once without running it. described: %v, executed: %v", described, executed)
+	}
+
+	// the description depends on the session context
+	schema = "OTHER"
+	sc.cfg.Schema = schema
+	if _, err := sc.PrepareContext(ctx, query); err != nil {
+		t.Fatalf("failed to prepare. err: %v", err)
+	}
+	if described != 2 {
+		t.Errorf("should have described the statement in the other schema. described: %v", described)
+	}
+
+	if _, err := sc.PrepareContext(ctx, "select * from missing"); err == nil {
+		t.Errorf("should have failed to prepare the statement of the missing table")
+	}
+	stmt, err := sc.PrepareContext(ctx, "PUT file:///tmp/a.csv @~")
+	if err != nil {
+		t.Fatalf("failed to prepare. err: %v", err)
+	}
+	if st := stmt.(SnowflakeStmt); st.ColumnTypes() != nil || st.NumBinds() != -1 {
+		t.Errorf("should not have described PUT")
+	}
+	if described != 3 {
+		t.Errorf("unexpected describe requests. got: %v", described)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	// maxStatementCacheEntries is the maximum number of statements in the statement cache.
+	maxStatementCacheEntries = 1000
+	// statementCacheTTL is the time a description is reused, after which the statement is described again in case
+	// the objects it refers to are altered.
+	statementCacheTTL = 10 * time.Minute
+)
+
+// statementDescription is the result columns and binds of a statement returned by the describe-only request.
+type statementDescription struct {
+	rowType         []execResponseRowType
+	bindTypes       []execResponseRowType
+	numBinds        int
+	statementTypeID int64
+}
+
+type statementCacheEntry struct {
+	desc    *statementDescription
+	expires time.Time
+}
+
+// statementCache maps the SQL texts in the session contexts to their descriptions, shared by the connections,
+// so that the statements prepared again, e.g., by an ORM for every execution, are not described again.
+type statementCache struct {
+	mu      sync.Mutex
+	entries map[string]statementCacheEntry
+}
+
+var defaultStatementCache = &statementCache{entries: make(map[string]statementCacheEntry)}
+
+func (c *statementCache) get(key string) (*statementDescription, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !getClock().Now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.desc, true
+}
+
+func (c *statementCache) put(key string, desc *statementDescription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := getClock().Now()
+	if len(c.entries) >= maxStatementCacheEntries {
+		// drops the expired entries, then the entry expiring first
+		oldest := ""
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			} else if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= maxStatementCacheEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = statementCacheEntry{desc: desc, expires: now.Add(statementCacheTTL)}
+}
+
+// fileTransferRegexp matches PUT and GET, whose files are transferred by the client.
+var fileTransferRegexp = regexp.MustCompile(`(?i)^\s*(PUT|GET)\s`)
+
+// isDescribable returns true if the statement can be described at Prepare. PUT, GET and multi-statement
+// queries are described when run.
+func isDescribable(ctx context.Context, query string) bool {
+	if ctx.Value(multiStatementCountKey) != nil {
+		return false
+	}
+	return !fileTransferRegexp.MatchString(query)
+}
+
+// describe returns the description of the statement in the session context, by the describe-only request
+// unless cached.
+func (sc *snowflakeConn) describe(ctx context.Context, query string) (*statementDescription, error) {
+	key := sc.resultCacheKey(query, nil)
+	if desc, ok := defaultStatementCache.get(key); ok {
+		glog.V(2).Info("statement description found in the cache")
+		return desc, nil
+	}
+	data, err := sc.exec(WithDescribeOnly(ctx), query, false, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	desc := &statementDescription{
+		rowType:         data.Data.RowType,
+		bindTypes:       data.Data.MetaDataOfBinds,
+		numBinds:        data.Data.NumberOfBinds,
+		statementTypeID: data.Data.StatementTypeID,
+	}
+	defaultStatementCache.put(key, desc)
+	return desc, nil
+}
@@ -0,0 +1,471 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stage location types of the PUT and GET responses
+const (
+	stageLocationS3    = "S3"
+	stageLocationAzure = "AZURE"
+	stageLocationGCS   = "GCS"
+	stageLocationLocal = "LOCAL_FS"
+)
+
+var (
+	// maxStorageRetries is the number of retries of a request to the cloud storage.
+	maxStorageRetries = 5
+	// storageRetryInterval is the wait before the first retry, doubled for each retry.
+	storageRetryInterval = time.Second
+)
+
+// fileHeader is the metadata of a file in the stage.
+type fileHeader struct {
+	digest        string // SHA-256 of the file before the encryption, in base64
+	contentLength int64
+	encryptMeta   *encryptMetadata
+}
+
+// storageClient transfers the files between the local file system and the stage.
+type storageClient interface {
+	// fileHeader returns the metadata of the file in the stage, or nil if the file doesn't exist.
+	fileHeader(ctx context.Context, name string) (*fileHeader, error)
+	// upload uploads the upload file of the metadata to the stage with its digest and encryption metadata.
+	upload(ctx context.Context, name string, meta *fileMetadata) error
+	// download writes the file in the stage to dst, and returns its metadata.
+	download(ctx context.Context, name string, meta *fileMetadata, dst io.Writer) (*fileHeader, error)
+}
+
+// newStorageClient returns the client of the stage in the PUT or GET response.
+func newStorageClient(client clientInterface, stage *execResponseStageInfo) (storageClient, error) {
+	switch strings.ToUpper(stage.LocationType) {
+	case stageLocationS3:
+		return newS3Storage(client, stage), nil
+	case stageLocationAzure:
+		return newAzureStorage(client, stage), nil
+	case stageLocationGCS:
+		return newGCSStorage(client, stage), nil
+	case stageLocationLocal:
+		return &localStorage{dir: expandUser(stage.Location)}, nil
+	}
+	return nil, &SnowflakeError{
+		Number:      ErrInvalidStageLocation,
+		Message:     errMsgInvalidStageLocation,
+		MessageArgs: []interface{}{stage.LocationType},
+	}
+}
+
+// splitStageLocation returns the bucket or container and the path prefix of the stage location.
+func splitStageLocation(location string) (string, string) {
+	i := strings.Index(location, "/")
+	if i < 0 {
+		return location, ""
+	}
+	path := location[i+1:]
+	if path != "" && !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return location[:i], path
+}
+
+// escapeObjectPath escapes each segment of the object path, keeping the slashes.
+func escapeObjectPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+	}
+	return strings.Join(segments, "/")
+}
+
+// storageRequest is a request to the cloud storage.
+type storageRequest struct {
+	method  string
+	url     string
+	headers map[string]string
+	file    string                    // the file of the request body, if any
+	offset  int64                     // the offset of the section of the file in the request body
+	length  int64                     // the length of the section of the file in the request body. the whole file if 0.
+	data    []byte                    // the request body in memory, if any
+	stream  io.Reader                 // the request body of unknown length read once, if any. not retried.
+	sign    func(*http.Request) error // signs the request, if required
+}
+
+// bodySize returns the size of the request body.
+func (r *storageRequest) bodySize() int64 {
+	if r.data != nil {
+		return int64(len(r.data))
+	}
+	return r.length
+}
+
+// doStorageRequest sends the request, and retries it on the network errors, throttling and server errors. The
+// body is read again for each attempt.
+func doStorageRequest(ctx context.Context, client clientInterface, r *storageRequest) (*http.Response, error) {
+	wait := storageRetryInterval
+	for attempt := 0; ; attempt++ {
+		var body io.ReadCloser
+		var size int64
+		if r.file != "" {
+			f, err := os.Open(r.file)
+			if err != nil {
+				return nil, err
+			}
+			fi, err := f.Stat()
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			body, size = f, fi.Size()
+			if r.length > 0 {
+				body = struct {
+					io.Reader
+					io.Closer
+				}{io.NewSectionReader(f, r.offset, r.length), f}
+				size = r.length
+			}
+		} else if r.data != nil {
+			body, size = ioutil.NopCloser(bytes.NewReader(r.data)), int64(len(r.data))
+		} else if r.stream != nil {
+			body, size = ioutil.NopCloser(r.stream), -1
+		}
+		req, err := http.NewRequest(r.method, r.url, body)
+		if err != nil {
+			if body != nil {
+				body.Close()
+			}
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		if body != nil {
+			req.ContentLength = size
+		}
+		for k, v := range r.headers {
+			req.Header.Set(k, v)
+		}
+		if r.sign != nil {
+			if err = r.sign(req); err != nil {
+				if body != nil {
+					body.Close()
+				}
+				return nil, err
+			}
+		}
+		res, err := client.Do(req)
+		retryable := (err != nil || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500) && r.stream == nil
+		if !retryable || attempt >= maxStorageRetries || ctx.Err() != nil {
+			if err != nil && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if ue, ok := err.(*url.Error); ok {
+				// the presigned URLs and SAS tokens of the stage are credentials
+				ue.URL = maskSecrets(ue.URL)
+			}
+			return res, err
+		}
+		if err != nil {
+			glog.V(2).Infof("failed to send the request to the stage. retrying. URL: %v, err: %v", r.url, err)
+		} else {
+			glog.V(2).Infof("HTTP: %v. retrying. URL: %v", res.StatusCode, r.url)
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-getClock().After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// readPart reads a part of the stream up to size bytes. The part is shorter only at the end of the stream. The
+// buffer grows as the data are read, so that a small stream doesn't take a whole part of memory.
+func readPart(r io.Reader, size int64) ([]byte, error) {
+	n := int64(encryptionBufferSize)
+	if n > size {
+		n = size
+	}
+	buf := make([]byte, 0, n)
+	for int64(len(buf)) < size {
+		if len(buf) == cap(buf) {
+			n = 2 * int64(cap(buf))
+			if n > size {
+				n = size
+			}
+			b := make([]byte, len(buf), n)
+			copy(b, buf)
+			buf = b
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// streamParts returns the function returning the parts of the stream of partSize in the request bodies, or nil
+// after the last part. first is the first part read already. It fails if the stream exceeds maxParts.
+func streamParts(name string, r io.Reader, first []byte, partSize int64, maxParts int) func() (*storageRequest, error) {
+	parts := 0
+	return func() (*storageRequest, error) {
+		part := first
+		if part != nil {
+			first = nil
+		} else {
+			var err error
+			if part, err = readPart(r, partSize); err != nil {
+				return nil, err
+			}
+		}
+		if len(part) == 0 {
+			return nil, nil
+		}
+		if parts++; parts > maxParts {
+			return nil, &SnowflakeError{
+				Number:      ErrStreamTooLarge,
+				Message:     errMsgStreamTooLarge,
+				MessageArgs: []interface{}{name, maxParts, partSize},
+			}
+		}
+		return &storageRequest{data: part}, nil
+	}
+}
+
+// uploadParts uploads the parts returned by next by put with the part numbers from 0, up to parallel parts at
+// once. next is called only when a part can be uploaded, so that at most parallel parts of a stream are held in
+// memory, and returns nil after the last part. The progress of the parts but the last is reported with the total
+// size, which is 0 if unknown. It returns the number of the parts uploaded.
+func uploadParts(ctx context.Context, name string, total int64, parallel int, next func() (*storageRequest, error), put func(context.Context, int, *storageRequest) error) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		uploaded int64
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	sem := make(chan struct{}, parallel)
+	parts := 0
+	for ctx.Err() == nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		r, err := next()
+		if err != nil || r == nil {
+			<-sem
+			if err != nil {
+				fail(err)
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int, r *storageRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := put(ctx, i, r); err != nil {
+				fail(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// the completion of the last part is reported by uploadFile
+			if uploaded += r.bodySize(); total == 0 || uploaded < total {
+				reportTransferProgress(ctx, TransferProgress{File: name, Bytes: uploaded, Total: total})
+			}
+		}(parts, r)
+		parts++
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return parts, firstErr
+}
+
+// azureEncryptionData is the encryption metadata of the files in Azure and GCS.
+type azureEncryptionData struct {
+	EncryptionMode      string                   `json:"EncryptionMode"`
+	WrappedContentKey   azureWrappedContentKey   `json:"WrappedContentKey"`
+	EncryptionAgent     azureEncryptionAgent     `json:"EncryptionAgent"`
+	ContentEncryptionIV string                   `json:"ContentEncryptionIV"`
+	KeyWrappingMetadata azureKeyWrappingMetadata `json:"KeyWrappingMetadata"`
+	KeyEncryptionIV     string                   `json:"KeyEncryptionIV,omitempty"` // AES-GCM only
+	KeyAAD              string                   `json:"KeyAad,omitempty"`
+	DataAAD             string                   `json:"DataAad,omitempty"`
+}
+
+type azureWrappedContentKey struct {
+	KeyID        string `json:"KeyId"`
+	EncryptedKey string `json:"EncryptedKey"`
+	Algorithm    string `json:"Algorithm"`
+}
+
+type azureEncryptionAgent struct {
+	Protocol            string `json:"Protocol"`
+	EncryptionAlgorithm string `json:"EncryptionAlgorithm"`
+}
+
+type azureKeyWrappingMetadata struct {
+	EncryptionLibrary string `json:"EncryptionLibrary"`
+}
+
+// marshalEncryptionData returns the encryption metadata in the format of Azure and GCS.
+func marshalEncryptionData(meta *encryptMetadata) (string, error) {
+	algorithm := cipherAESCBC + "_256"
+	if meta.keyIV != "" {
+		algorithm = cipherAESGCM + "_256"
+	}
+	b, err := json.Marshal(azureEncryptionData{
+		EncryptionMode: "FullBlob",
+		WrappedContentKey: azureWrappedContentKey{
+			KeyID:        "symmKey1",
+			EncryptedKey: meta.key,
+			Algorithm:    algorithm,
+		},
+		EncryptionAgent: azureEncryptionAgent{
+			Protocol:            "1.0",
+			EncryptionAlgorithm: algorithm,
+		},
+		ContentEncryptionIV: meta.iv,
+		KeyWrappingMetadata: azureKeyWrappingMetadata{EncryptionLibrary: "Java 5.3.0"},
+		KeyEncryptionIV:     meta.keyIV,
+		KeyAAD:              meta.keyAAD,
+		DataAAD:             meta.dataAAD,
+	})
+	return string(b), err
+}
+
+// unmarshalEncryptionData returns the encryption metadata of the file in Azure or GCS, or nil if the file is not
+// encrypted.
+func unmarshalEncryptionData(data string, matdesc string) (*encryptMetadata, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var ed azureEncryptionData
+	if err := json.Unmarshal([]byte(data), &ed); err != nil {
+		return nil, err
+	}
+	return &encryptMetadata{
+		key:     ed.WrappedContentKey.EncryptedKey,
+		iv:      ed.ContentEncryptionIV,
+		matdesc: matdesc,
+		keyIV:   ed.KeyEncryptionIV,
+		keyAAD:  ed.KeyAAD,
+		dataAAD: ed.DataAAD,
+	}, nil
+}
+
+// localStorage is the stage in the local file system, used by the tests of Snowflake.
+type localStorage struct {
+	dir string
+}
+
+func (s *localStorage) fileHeader(_ context.Context, name string) (*fileHeader, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &fileHeader{digest: base64.StdEncoding.EncodeToString(h.Sum(nil)), contentLength: n}, nil
+}
+
+func (s *localStorage) upload(_ context.Context, name string, meta *fileMetadata) error {
+	dst := filepath.Join(s.dir, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	if meta.uploadStream == nil {
+		return copyFile(meta.uploadFile, dst)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, meta.uploadStream); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (s *localStorage) download(_ context.Context, name string, _ *fileMetadata, dst io.Writer) (*fileHeader, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := io.Copy(dst, f)
+	if err != nil {
+		return nil, err
+	}
+	return &fileHeader{contentLength: n}, nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// expandUser replaces the leading ~ of the path with the home directory.
+func expandUser(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.Getenv("USERPROFILE")
+	}
+	return filepath.Join(home, path[1:])
+}
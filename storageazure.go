@@ -0,0 +1,217 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Metadata headers of the files in Azure. The metadata names must be C# identifiers.
+const (
+	azureMetaDigest         = "x-ms-meta-sfcdigest"
+	azureMetaEncryptionData = "x-ms-meta-encryptiondata"
+	azureMetaMatdesc        = "x-ms-meta-matdesc"
+)
+
+// maxAzureBlocks is the maximum number of the blocks of a blob.
+const maxAzureBlocks = 50000
+
+// azureStorage is the stage in Azure Blob Storage, accessed with the SAS token in the response.
+type azureStorage struct {
+	client    clientInterface
+	host      string
+	container string
+	path      string
+	sasToken  string
+
+	partSize     int64 // the streams larger are uploaded in blocks of the size. defaultUploadPartSize if 0.
+	partParallel int   // blocks of a stream uploaded at once. defaultUploadPartParallel if 0.
+}
+
+func newAzureStorage(client clientInterface, stage *execResponseStageInfo) *azureStorage {
+	container, path := splitStageLocation(stage.Location)
+	endPoint := stage.EndPoint
+	if endPoint == "" {
+		endPoint = "blob.core.windows.net"
+	}
+	return &azureStorage{
+		client:    client,
+		host:      stage.StorageAccount + "." + endPoint,
+		container: container,
+		path:      path,
+		sasToken:  strings.TrimPrefix(stage.Creds.AzureSasToken, "?"),
+	}
+}
+
+func (s *azureStorage) url(name string) string {
+	u := "https://" + s.host + "/" + s.container + "/" + escapeObjectPath(s.path+name)
+	if s.sasToken != "" {
+		u += "?" + s.sasToken
+	}
+	return u
+}
+
+// queryURL returns the URL of the blob with the query of the operation.
+func (s *azureStorage) queryURL(name string, query string) string {
+	if s.sasToken != "" {
+		return s.url(name) + "&" + query
+	}
+	return s.url(name) + "?" + query
+}
+
+func azureFileHeader(res *http.Response) (*fileHeader, error) {
+	meta, err := unmarshalEncryptionData(res.Header.Get(azureMetaEncryptionData), res.Header.Get(azureMetaMatdesc))
+	if err != nil {
+		return nil, err
+	}
+	return &fileHeader{
+		digest:        res.Header.Get(azureMetaDigest),
+		contentLength: res.ContentLength,
+		encryptMeta:   meta,
+	}, nil
+}
+
+func (s *azureStorage) fileHeader(ctx context.Context, name string) (*fileHeader, error) {
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "HEAD", url: s.url(name)})
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return azureFileHeader(res)
+}
+
+func (s *azureStorage) upload(ctx context.Context, name string, meta *fileMetadata) error {
+	headers := map[string]string{
+		"Content-Type":   "application/octet-stream",
+		"x-ms-blob-type": "BlockBlob",
+	}
+	if meta.digest != "" {
+		headers[azureMetaDigest] = meta.digest
+	}
+	if meta.encryptMeta != nil {
+		data, err := marshalEncryptionData(meta.encryptMeta)
+		if err != nil {
+			return err
+		}
+		headers[azureMetaEncryptionData] = data
+		headers[azureMetaMatdesc] = meta.encryptMeta.matdesc
+	}
+	r := &storageRequest{method: "PUT", url: s.url(name), headers: headers, file: meta.uploadFile}
+	if meta.uploadStream != nil {
+		// a stream of a part is uploaded at once
+		partSize := s.partSize
+		if partSize <= 0 {
+			partSize = defaultUploadPartSize
+		}
+		part, err := readPart(meta.uploadStream, partSize)
+		if err != nil {
+			return err
+		}
+		if int64(len(part)) == partSize {
+			return s.uploadBlocks(ctx, name, headers, streamParts(name, meta.uploadStream, part, partSize, maxAzureBlocks))
+		}
+		r.file, r.data = "", part
+	}
+	res, err := doStorageRequest(ctx, s.client, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return nil
+}
+
+type azureBlockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// uploadBlocks uploads the parts returned by next as the blocks of the blob, up to partParallel blocks at once, and
+// commits the blob with the metadata in the headers. The blocks not committed are deleted by Azure.
+func (s *azureStorage) uploadBlocks(ctx context.Context, name string, headers map[string]string, next func() (*storageRequest, error)) error {
+	parallel := s.partParallel
+	if parallel <= 0 {
+		parallel = defaultUploadPartParallel
+	}
+	glog.V(2).Infof("uploading %v in blocks", name)
+	// the IDs of the blocks of a blob must be of the same length
+	blockID := func(i int) string {
+		return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", i)))
+	}
+	blocks, err := uploadParts(ctx, name, 0, parallel, next, func(ctx context.Context, i int, r *storageRequest) error {
+		r.method = "PUT"
+		r.url = s.queryURL(name, "comp=block&blockid="+url.QueryEscape(blockID(i)))
+		res, err := doStorageRequest(ctx, s.client, r)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	list := azureBlockList{Latest: make([]string, blocks)}
+	for i := range list.Latest {
+		list.Latest[i] = blockID(i)
+	}
+	body, err := xml.Marshal(list)
+	if err != nil {
+		return err
+	}
+	commit := make(map[string]string)
+	for k, v := range headers {
+		if k != "Content-Type" && k != "x-ms-blob-type" {
+			commit[k] = v
+		}
+	}
+	commit["x-ms-blob-content-type"] = headers["Content-Type"]
+	commit["Content-Type"] = "application/xml"
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{
+		method:  "PUT",
+		url:     s.queryURL(name, "comp=blocklist"),
+		headers: commit,
+		data:    body,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return nil
+}
+
+func (s *azureStorage) download(ctx context.Context, name string, _ *fileMetadata, dst io.Writer) (*fileHeader, error) {
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "GET", url: s.url(name)})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, storageError(ErrFailedToDownloadFromStage, errMsgFailedToDownloadFromStage, name, res)
+	}
+	if _, err = io.Copy(dst, res.Body); err != nil {
+		return nil, err
+	}
+	return azureFileHeader(res)
+}
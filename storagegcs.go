@@ -0,0 +1,216 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Metadata headers of the files in GCS
+const (
+	gcsMetaDigest         = "x-goog-meta-sfc-digest"
+	gcsMetaEncryptionData = "x-goog-meta-encryptiondata"
+	gcsMetaMatdesc        = "x-goog-meta-matdesc"
+)
+
+// gcsChunkSize is the unit of the parts of a resumable upload.
+const gcsChunkSize = 256 << 10
+
+// gcsStorage is the stage in Google Cloud Storage, accessed with the access token in the response, or with the
+// presigned URLs if no token is given.
+type gcsStorage struct {
+	client       clientInterface
+	endPoint     string
+	bucket       string
+	path         string
+	accessToken  string
+	presignedURL string // URL to upload the file of PUT to
+
+	partSize int64 // the streams larger are uploaded in parts of the size. defaultUploadPartSize if 0.
+}
+
+func newGCSStorage(client clientInterface, stage *execResponseStageInfo) *gcsStorage {
+	bucket, path := splitStageLocation(stage.Location)
+	endPoint := stage.EndPoint
+	if endPoint == "" {
+		endPoint = "storage.googleapis.com"
+	}
+	return &gcsStorage{
+		client:       client,
+		endPoint:     endPoint,
+		bucket:       bucket,
+		path:         path,
+		accessToken:  stage.Creds.GcsAccessToken,
+		presignedURL: stage.PresignedURL,
+	}
+}
+
+func (s *gcsStorage) url(name string) string {
+	return "https://" + s.endPoint + "/" + s.bucket + "/" + escapeObjectPath(s.path+name)
+}
+
+func (s *gcsStorage) headers() map[string]string {
+	headers := make(map[string]string)
+	if s.accessToken != "" {
+		headers["Authorization"] = "Bearer " + s.accessToken
+	}
+	return headers
+}
+
+func gcsFileHeader(res *http.Response) (*fileHeader, error) {
+	meta, err := unmarshalEncryptionData(res.Header.Get(gcsMetaEncryptionData), res.Header.Get(gcsMetaMatdesc))
+	if err != nil {
+		return nil, err
+	}
+	return &fileHeader{
+		digest:        res.Header.Get(gcsMetaDigest),
+		contentLength: res.ContentLength,
+		encryptMeta:   meta,
+	}, nil
+}
+
+func (s *gcsStorage) fileHeader(ctx context.Context, name string) (*fileHeader, error) {
+	if s.accessToken == "" {
+		// the presigned URLs don't allow to get the metadata
+		return nil, nil
+	}
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "HEAD", url: s.url(name), headers: s.headers()})
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return gcsFileHeader(res)
+}
+
+func (s *gcsStorage) upload(ctx context.Context, name string, meta *fileMetadata) error {
+	u := s.url(name)
+	if s.accessToken == "" && s.presignedURL != "" {
+		u = s.presignedURL
+	}
+	headers := s.headers()
+	headers["Content-Type"] = "application/octet-stream"
+	if meta.digest != "" {
+		headers[gcsMetaDigest] = meta.digest
+	}
+	if meta.encryptMeta != nil {
+		data, err := marshalEncryptionData(meta.encryptMeta)
+		if err != nil {
+			return err
+		}
+		headers[gcsMetaEncryptionData] = data
+		headers[gcsMetaMatdesc] = meta.encryptMeta.matdesc
+	}
+	r := &storageRequest{method: "PUT", url: u, headers: headers, file: meta.uploadFile}
+	if meta.uploadStream != nil {
+		// a stream of a part is uploaded at once
+		partSize := s.partSize
+		if partSize <= 0 {
+			partSize = defaultUploadPartSize
+		}
+		// the parts of a resumable upload but the last are multiples of 256 KiB
+		partSize = (partSize + gcsChunkSize - 1) / gcsChunkSize * gcsChunkSize
+		part, err := readPart(meta.uploadStream, partSize)
+		if err != nil {
+			return err
+		}
+		r.file, r.data = "", part
+		if int64(len(part)) == partSize {
+			if s.accessToken != "" {
+				return s.uploadResumable(ctx, name, headers, meta.uploadStream, part, partSize)
+			}
+			// the presigned URL allows only a PUT, which isn't retried with a stream
+			r.data, r.stream = nil, io.MultiReader(bytes.NewReader(part), meta.uploadStream)
+		}
+	}
+	res, err := doStorageRequest(ctx, s.client, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return nil
+}
+
+// uploadResumable uploads the stream in parts of partSize by the resumable upload of GCS, starting with the first
+// part read already. The parts are uploaded in order, and the size of the file is given with the last part.
+func (s *gcsStorage) uploadResumable(ctx context.Context, name string, headers map[string]string, r io.Reader, first []byte, partSize int64) error {
+	headers["x-goog-resumable"] = "start"
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "POST", url: s.url(name), headers: headers})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	session := res.Header.Get("Location")
+	glog.V(2).Infof("uploading %v in parts of %v bytes", name, partSize)
+	var offset int64
+	for part := first; ; {
+		n := int64(len(part))
+		last := n < partSize
+		req := &storageRequest{method: "PUT", url: session, headers: s.headers()}
+		switch {
+		case !last:
+			req.headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/*", offset, offset+n-1)
+		case n == 0:
+			req.headers["Content-Range"] = fmt.Sprintf("bytes */%d", offset)
+		default:
+			req.headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, offset+n)
+		}
+		if n > 0 {
+			req.data = part
+		}
+		res, err := doStorageRequest(ctx, s.client, req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if last {
+			if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+				return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+			}
+			return nil
+		}
+		// 308 Resume Incomplete
+		if res.StatusCode != http.StatusPermanentRedirect {
+			return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+		}
+		offset += n
+		reportTransferProgress(ctx, TransferProgress{File: name, Bytes: offset})
+		if part, err = readPart(r, partSize); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *gcsStorage) download(ctx context.Context, name string, meta *fileMetadata, dst io.Writer) (*fileHeader, error) {
+	u := s.url(name)
+	if s.accessToken == "" && meta.presignedURL != "" {
+		u = meta.presignedURL
+	}
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "GET", url: u, headers: s.headers()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, storageError(ErrFailedToDownloadFromStage, errMsgFailedToDownloadFromStage, name, res)
+	}
+	if _, err = io.Copy(dst, res.Body); err != nil {
+		return nil, err
+	}
+	return gcsFileHeader(res)
+}
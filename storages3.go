@@ -0,0 +1,352 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/internal/sigv4"
+)
+
+// Metadata headers of the files in S3
+const (
+	s3MetaDigest  = "x-amz-meta-sfc-digest"
+	s3MetaKey     = "x-amz-meta-x-amz-key"
+	s3MetaIV      = "x-amz-meta-x-amz-iv"
+	s3MetaMatdesc = "x-amz-meta-x-amz-matdesc"
+	s3MetaKeyIV   = "x-amz-meta-x-amz-key-iv"
+	s3MetaKeyAAD  = "x-amz-meta-x-amz-key-aad"
+	s3MetaDataAAD = "x-amz-meta-x-amz-data-aad"
+)
+
+// s3ServerSideEncryption is the header to encrypt the files in S3 by S3 with AES-256, set for the stages without
+// the client-side encryption.
+const s3ServerSideEncryption = "x-amz-server-side-encryption"
+
+// s3UnsignedPayload is the payload hash of the requests signed without hashing the body, allowed over HTTPS.
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+const (
+	// defaultUploadPartSize is the size of the parts of a multipart upload if Config.UploadPartSize is 0.
+	defaultUploadPartSize = 64 << 20
+	// minUploadPartSize is the minimum size of the parts of a multipart upload but the last.
+	minUploadPartSize = 5 << 20
+	// maxUploadParts is the maximum number of the parts of a multipart upload. The part size is raised for the
+	// files over it.
+	maxUploadParts = 10000
+	// defaultUploadPartParallel is the number of the parts uploaded at once if Config.UploadPartParallel is 0.
+	defaultUploadPartParallel = 4
+)
+
+// s3Storage is the stage in S3, accessed with the temporary credentials in the response.
+type s3Storage struct {
+	client clientInterface
+	host   string
+	path   string
+	region string
+	creds  execResponseCredentials
+	sse    bool // encrypt the files by S3
+
+	partSize     int64 // the files larger are uploaded in parts of the size. defaultUploadPartSize if 0.
+	partParallel int   // parts of a file uploaded at once. defaultUploadPartParallel if 0.
+}
+
+func newS3Storage(client clientInterface, stage *execResponseStageInfo) *s3Storage {
+	bucket, path := splitStageLocation(stage.Location)
+	region := stage.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	host := fmt.Sprintf("%v.s3.%v.amazonaws.com", bucket, region)
+	if strings.HasPrefix(region, "cn-") {
+		// the regions in China have their own domain
+		host += ".cn"
+	}
+	if stage.EndPoint != "" {
+		host = bucket + "." + stage.EndPoint
+	} else if stage.UseAccelerateEndpoint && !strings.HasPrefix(region, "cn-") {
+		// S3 Transfer Acceleration isn't available in China
+		host = bucket + ".s3-accelerate.amazonaws.com"
+	}
+	return &s3Storage{client: client, host: host, path: path, region: region, creds: stage.Creds, sse: !stage.IsClientSideEncrypted}
+}
+
+func (s *s3Storage) url(name string) string {
+	return "https://" + s.host + "/" + escapeObjectPath(s.path+name)
+}
+
+func (s *s3Storage) sign(req *http.Request) error {
+	signV4(req, s.creds, s.region, "s3", s3UnsignedPayload, getClock().Now())
+	return nil
+}
+
+func (s *s3Storage) fileHeader(ctx context.Context, name string) (*fileHeader, error) {
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "HEAD", url: s.url(name), sign: s.sign})
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return s3FileHeader(res), nil
+}
+
+func s3FileHeader(res *http.Response) *fileHeader {
+	h := &fileHeader{digest: res.Header.Get(s3MetaDigest), contentLength: res.ContentLength}
+	if key := res.Header.Get(s3MetaKey); key != "" {
+		h.encryptMeta = &encryptMetadata{
+			key:     key,
+			iv:      res.Header.Get(s3MetaIV),
+			matdesc: res.Header.Get(s3MetaMatdesc),
+			keyIV:   res.Header.Get(s3MetaKeyIV),
+			keyAAD:  res.Header.Get(s3MetaKeyAAD),
+			dataAAD: res.Header.Get(s3MetaDataAAD),
+		}
+	}
+	return h
+}
+
+func (s *s3Storage) upload(ctx context.Context, name string, meta *fileMetadata) error {
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if meta.digest != "" {
+		headers[s3MetaDigest] = meta.digest
+	}
+	if meta.encryptMeta != nil {
+		headers[s3MetaKey] = meta.encryptMeta.key
+		headers[s3MetaIV] = meta.encryptMeta.iv
+		headers[s3MetaMatdesc] = meta.encryptMeta.matdesc
+		for k, v := range map[string]string{
+			s3MetaKeyIV:   meta.encryptMeta.keyIV,
+			s3MetaKeyAAD:  meta.encryptMeta.keyAAD,
+			s3MetaDataAAD: meta.encryptMeta.dataAAD,
+		} {
+			if v != "" {
+				headers[k] = v
+			}
+		}
+	} else if s.sse {
+		headers[s3ServerSideEncryption] = "AES256"
+	}
+	partSize := s.partSize
+	if partSize <= 0 {
+		partSize = defaultUploadPartSize
+	}
+	r := &storageRequest{method: "PUT", url: s.url(name), headers: headers, file: meta.uploadFile, sign: s.sign}
+	if meta.uploadStream != nil {
+		// a stream of a part is uploaded at once
+		part, err := readPart(meta.uploadStream, partSize)
+		if err != nil {
+			return err
+		}
+		if int64(len(part)) == partSize {
+			return s.uploadParts(ctx, name, headers, 0, streamParts(name, meta.uploadStream, part, partSize, maxUploadParts))
+		}
+		r.file, r.data = "", part
+	} else {
+		fi, err := os.Stat(meta.uploadFile)
+		if err != nil {
+			return err
+		}
+		if size := fi.Size(); size > partSize {
+			return s.uploadParts(ctx, name, headers, size, fileParts(meta.uploadFile, size, partSize))
+		}
+	}
+	res, err := doStorageRequest(ctx, s.client, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return nil
+}
+
+// fileParts returns the function returning the sections of the file of partSize in the request bodies, or nil
+// after the last part. The part size is raised for the files over maxUploadParts.
+func fileParts(file string, size int64, partSize int64) func() (*storageRequest, error) {
+	if size > partSize*maxUploadParts {
+		partSize = (size + maxUploadParts - 1) / maxUploadParts
+	}
+	var offset int64
+	return func() (*storageRequest, error) {
+		if offset >= size {
+			return nil, nil
+		}
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		r := &storageRequest{file: file, offset: offset, length: length}
+		offset += length
+		return r, nil
+	}
+}
+
+type s3InitiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// uploadParts uploads the parts returned by next, up to partParallel parts at once, by the multipart upload of
+// S3, so that a large file is uploaded over multiple connections, and a stream is uploaded as read. The upload is
+// aborted on a failure, so that S3 doesn't keep the parts uploaded. size is 0 if unknown.
+func (s *s3Storage) uploadParts(ctx context.Context, name string, headers map[string]string, size int64, next func() (*storageRequest, error)) error {
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{
+		method:  "POST",
+		url:     s.url(name) + "?uploads",
+		headers: headers,
+		sign:    s.sign,
+	})
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	var initiated s3InitiateMultipartUploadResult
+	err = xml.NewDecoder(res.Body).Decode(&initiated)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	uploadURL := s.url(name) + "?uploadId=" + url.QueryEscape(initiated.UploadID)
+	glog.V(2).Infof("uploading %v in parts", name)
+
+	parallel := s.partParallel
+	if parallel <= 0 {
+		parallel = defaultUploadPartParallel
+	}
+	var mu sync.Mutex
+	etags := make(map[int]string)
+	parts, err := uploadParts(ctx, name, size, parallel, next, func(ctx context.Context, i int, r *storageRequest) error {
+		r.method = "PUT"
+		r.url = fmt.Sprintf("%v&partNumber=%d", uploadURL, i+1)
+		r.sign = s.sign
+		res, err := doStorageRequest(ctx, s.client, r)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+		}
+		mu.Lock()
+		etags[i] = res.Header.Get("ETag")
+		mu.Unlock()
+		return nil
+	})
+	if err == nil {
+		completed := make([]string, parts)
+		for i := range completed {
+			completed[i] = etags[i]
+		}
+		err = s.completeUpload(ctx, name, uploadURL, completed)
+	}
+	if err != nil {
+		// even if ctx is canceled
+		if res, err := doStorageRequest(context.Background(), s.client, &storageRequest{
+			method: "DELETE", url: uploadURL, sign: s.sign}); err == nil {
+			res.Body.Close()
+		}
+	}
+	return err
+}
+
+// completeUpload completes the multipart upload with the ETags of the parts in order.
+func (s *s3Storage) completeUpload(ctx context.Context, name string, uploadURL string, etags []string) error {
+	complete := s3CompleteMultipartUpload{Parts: make([]s3CompletedPart, len(etags))}
+	for i, etag := range etags {
+		complete.Parts[i] = s3CompletedPart{PartNumber: i + 1, ETag: etag}
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{
+		method:  "POST",
+		url:     uploadURL,
+		headers: map[string]string{"Content-Type": "application/xml"},
+		data:    body,
+		sign:    s.sign,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	// S3 may return an error in the body of 200 OK after the response starts
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(b, []byte("<Error>")) {
+		res.Body = ioutil.NopCloser(bytes.NewReader(b))
+		return storageError(ErrFailedToUploadToStage, errMsgFailedToUploadToStage, name, res)
+	}
+	return nil
+}
+
+func (s *s3Storage) download(ctx context.Context, name string, _ *fileMetadata, dst io.Writer) (*fileHeader, error) {
+	res, err := doStorageRequest(ctx, s.client, &storageRequest{method: "GET", url: s.url(name), sign: s.sign})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, storageError(ErrFailedToDownloadFromStage, errMsgFailedToDownloadFromStage, name, res)
+	}
+	if _, err = io.Copy(dst, res.Body); err != nil {
+		return nil, err
+	}
+	return s3FileHeader(res), nil
+}
+
+// storageError returns the error of the failed response of the stage.
+func storageError(number int, message string, name string, res *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	glog.V(1).Infof("HTTP: %v, URL: %v, Body: %v", res.StatusCode, res.Request.URL, string(b))
+	return &SnowflakeError{
+		Number:      number,
+		SQLState:    SQLStateConnectionFailure,
+		Message:     message,
+		MessageArgs: []interface{}{name, res.StatusCode, res.Request.URL},
+	}
+}
+
+// signV4 signs the request with AWS Signature Version 4. The host and the headers set in the request are signed.
+func signV4(req *http.Request, creds execResponseCredentials, region string, service string, payloadHash string, now time.Time) {
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	sigv4.Sign(req, sigv4.Credentials{
+		AccessKeyID:     creds.AwsKeyID,
+		SecretAccessKey: creds.AwsSecretKey,
+		SessionToken:    creds.AwsToken,
+	}, region, service, payloadHash, now)
+}
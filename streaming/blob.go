@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package streaming
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// bdecVersion is the version of the blob format: the encrypted Parquet files of the chunks, without a header.
+const bdecVersion = 3
+
+// chunk is the rows of a channel in a blob.
+type chunk struct {
+	channel          *Channel
+	rows             [][]interface{}
+	stats            []*columnStats
+	size             int64 // the size of the values inserted
+	rowSequencer     int64
+	startOffsetToken *string
+	endOffsetToken   *string
+	first, last      time.Time
+}
+
+type channelMetadata struct {
+	ChannelName      string  `json:"channel_name"`
+	ClientSequencer  int64   `json:"client_sequencer"`
+	RowSequencer     int64   `json:"row_sequencer"`
+	OffsetToken      *string `json:"offset_token"`
+	StartOffsetToken *string `json:"start_offset_token"`
+	EndOffsetToken   *string `json:"end_offset_token"`
+}
+
+type epInfo struct {
+	Rows    int64                           `json:"rows"`
+	Columns map[string]fileColumnProperties `json:"columns"`
+}
+
+type chunkMetadata struct {
+	Database                string            `json:"database"`
+	Schema                  string            `json:"schema"`
+	Table                   string            `json:"table"`
+	ChunkStartOffset        int64             `json:"chunk_start_offset"`
+	ChunkLength             int               `json:"chunk_length"`
+	ChunkLengthUncompressed int64             `json:"chunk_length_uncompressed"`
+	Channels                []channelMetadata `json:"channels"`
+	ChunkMD5                string            `json:"chunk_md5"`
+	EPS                     epInfo            `json:"eps"`
+	EncryptionKeyID         int64             `json:"encryption_key_id"`
+	FirstInsertTimeInMs     int64             `json:"first_insert_time_in_ms"`
+	LastInsertTimeInMs      int64             `json:"last_insert_time_in_ms"`
+}
+
+type blobStats struct {
+	FlushStartMs     int64 `json:"flush_start_ms"`
+	BuildDurationMs  int64 `json:"build_duration_ms"`
+	UploadDurationMs int64 `json:"upload_duration_ms"`
+}
+
+type blobMetadata struct {
+	Path        string          `json:"path"`
+	MD5         string          `json:"md5"`
+	BDECVersion int             `json:"bdec_version"`
+	Chunks      []chunkMetadata `json:"chunks"`
+	BlobStats   blobStats       `json:"blob_stats"`
+}
+
+// blobPath returns the path of a blob in the stage of the client, by the minute it is built.
+func blobPath(now time.Time, prefix string, counter int64) string {
+	now = now.UTC()
+	name := fmt.Sprintf("%v_%v_%v_%v.bdec", strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 36), prefix, os.Getpid(), counter)
+	return path.Join(strconv.Itoa(now.Year()), strconv.Itoa(int(now.Month())), strconv.Itoa(now.Day()),
+		strconv.Itoa(now.Hour()), strconv.Itoa(now.Minute()), name)
+}
+
+// buildBlob returns the blob of the chunks and its metadata. The Parquet file of a chunk is padded to the AES
+// block size and encrypted in AES-CTR by the key of the channel derived for the blob, i.e., SHA-256 of the key
+// and the blob path, from the counter of the offset of the chunk in the blob.
+func buildBlob(blobPath string, chunks []*chunk) ([]byte, *blobMetadata, error) {
+	meta := &blobMetadata{Path: blobPath, BDECVersion: bdecVersion}
+	var blob []byte
+	for _, k := range chunks {
+		ch := k.channel
+		data := writeParquet(ch.parquet, k.rows, map[string]string{"primaryFileId": path.Base(blobPath)})
+		length := len(data)
+		if n := length % aes.BlockSize; n > 0 {
+			data = append(data, make([]byte, aes.BlockSize-n)...)
+		}
+		offset := int64(len(blob))
+		if err := encryptChunk(data, ch.encryptionKey, blobPath, offset); err != nil {
+			return nil, nil, err
+		}
+		blob = append(blob, data...)
+		// the length and MD5 of a chunk are of the Parquet file encrypted, without the padding
+		sum := md5.Sum(data[:length])
+
+		eps := epInfo{Rows: int64(len(k.rows)), Columns: make(map[string]fileColumnProperties, len(ch.columns))}
+		for i := range ch.columns {
+			eps.Columns[ch.columns[i].Name] = k.stats[i].properties(&ch.columns[i])
+		}
+		meta.Chunks = append(meta.Chunks, chunkMetadata{
+			Database:                ch.database,
+			Schema:                  ch.schema,
+			Table:                   ch.table,
+			ChunkStartOffset:        offset,
+			ChunkLength:             length,
+			ChunkLengthUncompressed: k.size,
+			Channels: []channelMetadata{{
+				ChannelName:      ch.name,
+				ClientSequencer:  ch.clientSequencer,
+				RowSequencer:     k.rowSequencer,
+				OffsetToken:      k.endOffsetToken,
+				StartOffsetToken: k.startOffsetToken,
+				EndOffsetToken:   k.endOffsetToken,
+			}},
+			ChunkMD5:            hex.EncodeToString(sum[:]),
+			EPS:                 eps,
+			EncryptionKeyID:     ch.encryptionKeyID,
+			FirstInsertTimeInMs: k.first.UnixNano() / int64(time.Millisecond),
+			LastInsertTimeInMs:  k.last.UnixNano() / int64(time.Millisecond),
+		})
+	}
+	sum := md5.Sum(blob)
+	meta.MD5 = hex.EncodeToString(sum[:])
+	return blob, meta, nil
+}
+
+// encryptChunk encrypts the chunk at the offset of the blob in place.
+func encryptChunk(data []byte, encryptionKey string, diversifier string, offset int64) error {
+	key, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key of the channel. err: %v", err)
+	}
+	derived := sha256.Sum256(append(key, diversifier...))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return err
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(offset/aes.BlockSize))
+	cipher.NewCTR(block, iv).XORKeyStream(data, data)
+	return nil
+}
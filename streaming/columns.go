@@ -0,0 +1,536 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package streaming
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLOBSize is the largest value of a TEXT, BINARY or semi-structured column in bytes.
+const maxLOBSize = 16 << 20
+
+// maxStatsLength is the length of the min and max strings in the column stats.
+const maxStatsLength = 32
+
+// timestampFormats are the formats of the time strings accepted for the DATE, TIME and TIMESTAMP columns.
+var timestampFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+// columnMetadata is a column of the table of a channel.
+type columnMetadata struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	LogicalType  string `json:"logical_type"`
+	PhysicalType string `json:"physical_type"`
+	Precision    int    `json:"precision"`
+	Scale        int    `json:"scale"`
+	ByteLength   int    `json:"byte_length"`
+	Length       int    `json:"length"`
+	Nullable     bool   `json:"nullable"`
+	Collation    string `json:"collation"`
+	Ordinal      int32  `json:"ordinal"`
+}
+
+// parquet returns the column of the Parquet files of the rows.
+func (c *columnMetadata) parquet() (parquetColumn, error) {
+	col := parquetColumn{name: c.Name, id: c.Ordinal, optional: c.Nullable, converted: convertedNone}
+	decimal := func(precision int) {
+		col.converted, col.scale, col.precision = convertedDecimal, int32(c.Scale), int32(precision)
+		switch c.PhysicalType {
+		case "SB1", "SB2", "SB4":
+			col.physical = parquetInt32
+		case "SB8":
+			col.physical = parquetInt64
+		default:
+			col.physical, col.typeLength = parquetFixedLenByteArray, 16
+		}
+	}
+	switch c.LogicalType {
+	case "FIXED":
+		decimal(c.Precision)
+	case "TIME":
+		if c.PhysicalType == "SB4" {
+			decimal(9)
+		} else {
+			decimal(18)
+		}
+	case "TIMESTAMP_NTZ", "TIMESTAMP_LTZ", "TIMESTAMP_TZ":
+		if c.PhysicalType == "SB8" {
+			decimal(18)
+		} else {
+			decimal(38)
+		}
+	case "DATE":
+		col.physical, col.converted = parquetInt32, convertedDate
+	case "REAL":
+		col.physical = parquetDouble
+	case "BOOLEAN":
+		col.physical = parquetBoolean
+	case "TEXT", "CHAR", "VARIANT", "OBJECT", "ARRAY":
+		col.physical, col.converted = parquetByteArray, convertedUTF8
+	case "BINARY":
+		col.physical = parquetByteArray
+	default:
+		return col, fmt.Errorf("unsupported type of column %v: %v", c.Name, c.LogicalType)
+	}
+	return col, nil
+}
+
+// convert returns the value of the Parquet files and the value of the stats, i.e., *big.Int, float64 or []byte,
+// of the value inserted to the column.
+func (c *columnMetadata) convert(v interface{}) (interface{}, interface{}, error) {
+	if v == nil {
+		if !c.Nullable {
+			return nil, nil, fmt.Errorf("null of the not null column %v", c.Name)
+		}
+		return nil, nil, nil
+	}
+	switch c.LogicalType {
+	case "FIXED":
+		r, err := toRat(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		x := roundHalfUp(r, c.Scale)
+		if new(big.Int).Abs(x).Cmp(pow10(c.Precision)) >= 0 {
+			return nil, nil, fmt.Errorf("%v out of the range of NUMBER(%v,%v)", v, c.Precision, c.Scale)
+		}
+		p, err := c.integer(x)
+		return p, x, err
+	case "REAL":
+		f, err := toFloat(v)
+		return f, f, err
+	case "BOOLEAN":
+		b, err := toBool(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if b {
+			return true, big.NewInt(1), nil
+		}
+		return false, big.NewInt(0), nil
+	case "TEXT", "CHAR":
+		s, err := toText(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n := utf8.RuneCountInString(s); c.Length > 0 && n > c.Length {
+			return nil, nil, fmt.Errorf("the string of %v characters longer than the column %v of %v", n, c.Name, c.Length)
+		}
+		return c.lob([]byte(s))
+	case "BINARY":
+		b, err := toBinary(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.lob(b)
+	case "VARIANT", "OBJECT", "ARRAY":
+		b, err := toJSON(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case c.LogicalType == "OBJECT" && b[0] != '{':
+			return nil, nil, fmt.Errorf("%s isn't an object", b)
+		case c.LogicalType == "ARRAY" && b[0] != '[':
+			b = append(append([]byte{'['}, b...), ']')
+		}
+		return c.lob(b)
+	case "DATE":
+		t, err := toTime(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		days := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+		return int32(days), big.NewInt(days), nil
+	case "TIME":
+		t, err := toTime(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		ns := int64(t.Hour())*int64(time.Hour) + int64(t.Minute())*int64(time.Minute) + int64(t.Second())*int64(time.Second) + int64(t.Nanosecond())
+		x := new(big.Int).Div(big.NewInt(ns), pow10(9-c.Scale))
+		p, err := c.integer(x)
+		return p, x, err
+	case "TIMESTAMP_NTZ", "TIMESTAMP_LTZ", "TIMESTAMP_TZ":
+		t, err := toTime(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.LogicalType == "TIMESTAMP_NTZ" {
+			// the wall clock of the time
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+		x := new(big.Int).Mul(big.NewInt(t.Unix()), big.NewInt(int64(time.Second)))
+		x.Add(x, big.NewInt(int64(t.Nanosecond())))
+		x.Div(x, pow10(9-c.Scale))
+		if c.LogicalType != "TIMESTAMP_TZ" {
+			p, err := c.integer(x)
+			return p, x, err
+		}
+		// the time zone offset in minutes, biased by 1440, is in the lowest 14 bits
+		_, offset := t.Zone()
+		tz := new(big.Int).Lsh(x, 14)
+		tz.Add(tz, big.NewInt(int64(offset/60+1440)))
+		p, err := c.integer(tz)
+		return p, x, err
+	}
+	return nil, nil, fmt.Errorf("unsupported type of column %v: %v", c.Name, c.LogicalType)
+}
+
+// integer returns the integer in the physical type of the column: int32, int64 or 16 bytes in big endian.
+func (c *columnMetadata) integer(x *big.Int) (interface{}, error) {
+	switch c.PhysicalType {
+	case "SB1", "SB2", "SB4":
+		if x.IsInt64() && x.Int64() >= math.MinInt32 && x.Int64() <= math.MaxInt32 {
+			return int32(x.Int64()), nil
+		}
+	case "SB8":
+		if x.IsInt64() {
+			return x.Int64(), nil
+		}
+	default:
+		if x.BitLen() < 128 {
+			return int128Bytes(x), nil
+		}
+	}
+	return nil, fmt.Errorf("%v out of the range of column %v", x, c.Name)
+}
+
+func (c *columnMetadata) lob(b []byte) (interface{}, interface{}, error) {
+	max := maxLOBSize
+	if c.ByteLength > 0 && c.ByteLength < max {
+		max = c.ByteLength
+	}
+	if len(b) > max {
+		return nil, nil, fmt.Errorf("the value of %v bytes longer than the column %v of %v bytes", len(b), c.Name, max)
+	}
+	return b, b, nil
+}
+
+// int128Bytes returns the 128-bit two's complement of x in big endian.
+func int128Bytes(x *big.Int) []byte {
+	b := make([]byte, 16)
+	y := x
+	if x.Sign() < 0 {
+		y = new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 128), x)
+	}
+	v := y.Bytes()
+	copy(b[16-len(v):], v)
+	return b
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundHalfUp returns r scaled by 10^scale and rounded half away from zero.
+func roundHalfUp(r *big.Rat, scale int) *big.Int {
+	x := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(scale)))
+	num := new(big.Int).Abs(x.Num())
+	q, m := new(big.Int).QuoRem(num, x.Denom(), new(big.Int))
+	if m.Lsh(m, 1).Cmp(x.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if x.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q
+}
+
+func toRat(v interface{}) (*big.Rat, error) {
+	switch x := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case int8:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case int16:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case int32:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case int64:
+		return new(big.Rat).SetInt64(x), nil
+	case uint:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(x))), nil
+	case uint8:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case uint16:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case uint32:
+		return new(big.Rat).SetInt64(int64(x)), nil
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(x)), nil
+	case float32:
+		return toRat(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	case float64:
+		return toRat(strconv.FormatFloat(x, 'g', -1, 64))
+	case *big.Int:
+		return new(big.Rat).SetInt(x), nil
+	case *big.Rat:
+		return x, nil
+	case json.Number:
+		return toRat(string(x))
+	case string:
+		if r, ok := new(big.Rat).SetString(strings.TrimSpace(x)); ok {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%v (%T) isn't a number", v, v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	case json.Number:
+		return x.Float64()
+	}
+	r, err := toRat(v)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := r.Float64()
+	return f, nil
+}
+
+func toBool(v interface{}) (bool, error) {
+	if s, ok := v.(string); ok {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "yes", "y", "on":
+			return true, nil
+		case "no", "n", "off":
+			return false, nil
+		}
+		return strconv.ParseBool(strings.TrimSpace(s))
+	}
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	r, err := toRat(v)
+	if err != nil {
+		return false, fmt.Errorf("%v (%T) isn't a boolean", v, v)
+	}
+	return r.Sign() != 0, nil
+}
+
+func toText(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case []byte:
+		if !utf8.Valid(x) {
+			return "", fmt.Errorf("invalid UTF-8 string: %x", x)
+		}
+		return string(x), nil
+	case fmt.Stringer:
+		return x.String(), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(x), nil
+	}
+	return "", fmt.Errorf("%v (%T) isn't a string", v, v)
+}
+
+func toBinary(v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, nil
+	case string:
+		return hex.DecodeString(x)
+	}
+	return nil, fmt.Errorf("%v (%T) isn't a binary", v, v)
+}
+
+// toJSON returns the JSON of the value, or the string if a valid JSON.
+func toJSON(v interface{}) ([]byte, error) {
+	var b []byte
+	switch x := v.(type) {
+	case string:
+		b = []byte(strings.TrimSpace(x))
+	case []byte:
+		b = x
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("invalid JSON: %.100s", b)
+	}
+	return b, nil
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, nil
+	case string:
+		for _, format := range timestampFormats {
+			if t, err := time.Parse(format, strings.TrimSpace(x)); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("%v (%T) isn't a time", v, v)
+}
+
+// columnStats is the stats of the values of a column in a chunk, which Snowflake prunes the chunks by.
+type columnStats struct {
+	minInt, maxInt   *big.Int
+	minReal, maxReal *float64
+	minStr, maxStr   []byte
+	maxLength        int64
+	nullCount        int64
+}
+
+func (s *columnStats) add(v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		s.nullCount++
+	case *big.Int:
+		if s.minInt == nil || x.Cmp(s.minInt) < 0 {
+			s.minInt = x
+		}
+		if s.maxInt == nil || x.Cmp(s.maxInt) > 0 {
+			s.maxInt = x
+		}
+	case float64:
+		// NaN is larger than any number
+		if s.minReal == nil || x < *s.minReal || math.IsNaN(*s.minReal) {
+			s.minReal = &x
+		}
+		if s.maxReal == nil || x > *s.maxReal || math.IsNaN(x) {
+			s.maxReal = &x
+		}
+	case []byte:
+		if s.minStr == nil || string(x) < string(s.minStr) {
+			s.minStr = x
+		}
+		if s.maxStr == nil || string(x) > string(s.maxStr) {
+			s.maxStr = x
+		}
+		if n := int64(len(x)); n > s.maxLength {
+			s.maxLength = n
+		}
+	}
+}
+
+// fileColumnProperties is the stats of a column in the chunk metadata.
+type fileColumnProperties struct {
+	ColumnID          int32      `json:"columnId"`
+	MinStrValue       *string    `json:"minStrValue"`
+	MaxStrValue       *string    `json:"maxStrValue"`
+	MinStrNonCollated *string    `json:"minStrNonCollated"`
+	MaxStrNonCollated *string    `json:"maxStrNonCollated"`
+	MinIntValue       *big.Int   `json:"minIntValue"`
+	MaxIntValue       *big.Int   `json:"maxIntValue"`
+	MinRealValue      statsFloat `json:"minRealValue"`
+	MaxRealValue      statsFloat `json:"maxRealValue"`
+	DistinctValues    int64      `json:"distinctValues"`
+	NullCount         int64      `json:"nullCount"`
+	MaxLength         int64      `json:"maxLength"`
+	Collation         *string    `json:"collation"`
+}
+
+func (s *columnStats) properties(c *columnMetadata) fileColumnProperties {
+	p := fileColumnProperties{
+		ColumnID:       c.Ordinal,
+		MinIntValue:    big.NewInt(0),
+		MaxIntValue:    big.NewInt(0),
+		DistinctValues: -1,
+		NullCount:      s.nullCount,
+		MaxLength:      s.maxLength,
+	}
+	if s.minInt != nil {
+		p.MinIntValue, p.MaxIntValue = s.minInt, s.maxInt
+	}
+	if s.minReal != nil {
+		p.MinRealValue, p.MaxRealValue = statsFloat(*s.minReal), statsFloat(*s.maxReal)
+	}
+	if s.minStr != nil {
+		min := hex.EncodeToString(truncateDown(s.minStr))
+		max := hex.EncodeToString(truncateUp(s.maxStr))
+		p.MinStrValue, p.MaxStrValue = &min, &max
+	}
+	if c.Collation != "" {
+		collation := c.Collation
+		p.Collation = &collation
+	}
+	return p
+}
+
+// truncateDown returns the prefix of maxStatsLength bytes, which isn't larger than b.
+func truncateDown(b []byte) []byte {
+	if len(b) <= maxStatsLength {
+		return b
+	}
+	return b[:maxStatsLength]
+}
+
+// truncateUp returns the prefix of maxStatsLength bytes incremented, which isn't smaller than b, or b if the prefix
+// can't be incremented.
+func truncateUp(b []byte) []byte {
+	if len(b) <= maxStatsLength {
+		return b
+	}
+	p := append([]byte(nil), b[:maxStatsLength]...)
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i] < 0xff {
+			p[i]++
+			return p[:i+1]
+		}
+	}
+	return b
+}
+
+// statsFloat is a float of the stats, which is a string if not finite, e.g., "NaN".
+type statsFloat float64
+
+func (f statsFloat) MarshalJSON() ([]byte, error) {
+	switch x := float64(f); {
+	case math.IsNaN(x):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(x, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(x, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+// columnKey returns the name of the column of a key of the rows: the key in upper case, or the quoted key as is.
+func columnKey(key string) string {
+	if len(key) >= 2 && strings.HasPrefix(key, `"`) && strings.HasSuffix(key, `"`) {
+		return strings.Replace(key[1:len(key)-1], `""`, `"`, -1)
+	}
+	return strings.ToUpper(key)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
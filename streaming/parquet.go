@@ -0,0 +1,329 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package streaming
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+)
+
+// Parquet physical types
+const (
+	parquetBoolean           = 0
+	parquetInt32             = 1
+	parquetInt64             = 2
+	parquetDouble            = 5
+	parquetByteArray         = 6
+	parquetFixedLenByteArray = 7
+)
+
+// Parquet converted types, the legacy logical types
+const (
+	convertedNone    = -1
+	convertedUTF8    = 0
+	convertedDecimal = 5
+	convertedDate    = 6
+)
+
+// Parquet encodings and compression codecs
+const (
+	encodingPlain = 0
+	encodingRLE   = 3
+	codecGzip     = 2
+)
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn is a column of a Parquet file. The values of a column are int32, int64, float64, bool, []byte
+// or nil, by the physical type.
+type parquetColumn struct {
+	name       string
+	id         int32 // the field ID, i.e., the ordinal of the column in the table
+	physical   int32
+	typeLength int32 // of FIXED_LEN_BYTE_ARRAY
+	optional   bool
+	converted  int32
+	scale      int32
+	precision  int32
+}
+
+// writeParquet writes the rows to a Parquet file of a row group, in a GZIP compressed data page of the PLAIN values
+// per column.
+func writeParquet(cols []parquetColumn, rows [][]interface{}, metadata map[string]string) []byte {
+	var out bytes.Buffer
+	out.Write(parquetMagic)
+	chunks := make([]*thriftWriter, len(cols))
+	var total int64
+	for i, col := range cols {
+		offset := int64(out.Len())
+		page := encodePage(col, i, rows)
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(page)
+		zw.Close()
+
+		header := &thriftWriter{}
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(compressed.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.stop()
+		out.Write(header.buf.Bytes())
+		out.Write(compressed.Bytes())
+
+		uncompressedSize := int64(header.buf.Len() + len(page))
+		compressedSize := int64(header.buf.Len() + compressed.Len())
+		total += uncompressedSize
+		w := &thriftWriter{}
+		w.i64(2, offset)
+		w.beginStruct(3)
+		w.i32(1, col.physical)
+		w.beginList(2, thriftI32, 2)
+		w.varint(zigzag(encodingPlain))
+		w.varint(zigzag(encodingRLE))
+		w.beginList(3, thriftBinary, 1)
+		w.varint(uint64(len(col.name)))
+		w.buf.WriteString(col.name)
+		w.i32(4, codecGzip)
+		w.i64(5, int64(len(rows)))
+		w.i64(6, uncompressedSize)
+		w.i64(7, compressedSize)
+		w.i64(9, offset)
+		w.endStruct()
+		chunks[i] = w
+	}
+
+	footer := &thriftWriter{}
+	footer.i32(1, 1)
+	footer.beginList(2, thriftStruct, len(cols)+1)
+	footer.beginElement()
+	footer.binary(4, []byte("bdec"))
+	footer.i32(5, int32(len(cols)))
+	footer.endStruct()
+	for _, col := range cols {
+		footer.beginElement()
+		footer.i32(1, col.physical)
+		if col.physical == parquetFixedLenByteArray {
+			footer.i32(2, col.typeLength)
+		}
+		if col.optional {
+			footer.i32(3, 1)
+		} else {
+			footer.i32(3, 0)
+		}
+		footer.binary(4, []byte(col.name))
+		if col.converted != convertedNone {
+			footer.i32(6, col.converted)
+		}
+		if col.converted == convertedDecimal {
+			footer.i32(7, col.scale)
+			footer.i32(8, col.precision)
+		}
+		footer.i32(9, col.id)
+		switch col.converted {
+		case convertedUTF8:
+			footer.beginStruct(10)
+			footer.beginStruct(1) // STRING
+			footer.endStruct()
+			footer.endStruct()
+		case convertedDecimal:
+			footer.beginStruct(10)
+			footer.beginStruct(5) // DECIMAL
+			footer.i32(1, col.scale)
+			footer.i32(2, col.precision)
+			footer.endStruct()
+			footer.endStruct()
+		case convertedDate:
+			footer.beginStruct(10)
+			footer.beginStruct(6) // DATE
+			footer.endStruct()
+			footer.endStruct()
+		}
+		footer.endStruct()
+	}
+	footer.i64(3, int64(len(rows)))
+	footer.beginList(4, thriftStruct, 1)
+	footer.beginElement()
+	footer.beginList(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		footer.beginElement()
+		footer.buf.Write(chunk.buf.Bytes())
+		footer.endStruct()
+	}
+	footer.i64(2, total)
+	footer.i64(3, int64(len(rows)))
+	footer.endStruct()
+	if len(metadata) > 0 {
+		footer.beginList(5, thriftStruct, len(metadata))
+		for _, k := range sortedKeys(metadata) {
+			footer.beginElement()
+			footer.binary(1, []byte(k))
+			footer.binary(2, []byte(metadata[k]))
+			footer.endStruct()
+		}
+	}
+	footer.binary(6, []byte("gosnowflake streaming"))
+	footer.stop()
+
+	out.Write(footer.buf.Bytes())
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(footer.buf.Len()))
+	out.Write(n[:])
+	out.Write(parquetMagic)
+	return out.Bytes()
+}
+
+// encodePage returns the data page of the column i of the rows: the definition levels of an optional column in
+// the RLE hybrid encoding, and the PLAIN values but the nulls.
+func encodePage(col parquetColumn, i int, rows [][]interface{}) []byte {
+	var page bytes.Buffer
+	if col.optional {
+		var levels bytes.Buffer
+		for start := 0; start < len(rows); {
+			defined := rows[start][i] != nil
+			end := start + 1
+			for end < len(rows) && (rows[end][i] != nil) == defined {
+				end++
+			}
+			levels.Write(uvarint(uint64(end-start) << 1))
+			if defined {
+				levels.WriteByte(1)
+			} else {
+				levels.WriteByte(0)
+			}
+			start = end
+		}
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(levels.Len()))
+		page.Write(n[:])
+		page.Write(levels.Bytes())
+	}
+	var bits byte
+	var nbits uint
+	var b [8]byte
+	for _, row := range rows {
+		switch v := row[i].(type) {
+		case nil:
+		case bool:
+			if v {
+				bits |= 1 << nbits
+			}
+			if nbits++; nbits == 8 {
+				page.WriteByte(bits)
+				bits, nbits = 0, 0
+			}
+		case int32:
+			binary.LittleEndian.PutUint32(b[:4], uint32(v))
+			page.Write(b[:4])
+		case int64:
+			binary.LittleEndian.PutUint64(b[:], uint64(v))
+			page.Write(b[:])
+		case float64:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+			page.Write(b[:])
+		case []byte:
+			if col.physical == parquetByteArray {
+				binary.LittleEndian.PutUint32(b[:4], uint32(len(v)))
+				page.Write(b[:4])
+			}
+			page.Write(v)
+		}
+	}
+	if nbits > 0 {
+		page.WriteByte(bits)
+	}
+	return page.Bytes()
+}
+
+// thriftWriter writes the structs of the Thrift compact protocol, which the Parquet metadata are serialized in.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // the last field IDs of the structs written
+	id   int16
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.id; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(zigzag(int64(id)))
+	}
+	w.id = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(zigzag(v))
+}
+
+func (w *thriftWriter) binary(id int16, v []byte) {
+	w.field(id, thriftBinary)
+	w.varint(uint64(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *thriftWriter) beginList(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		w.buf.WriteByte(0xf0 | elem)
+		w.varint(uint64(n))
+	}
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, thriftStruct)
+	w.beginElement()
+}
+
+// beginElement begins a struct of a list.
+func (w *thriftWriter) beginElement() {
+	w.last = append(w.last, w.id)
+	w.id = 0
+}
+
+func (w *thriftWriter) endStruct() {
+	w.stop()
+	w.id = w.last[len(w.last)-1]
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) stop() {
+	w.buf.WriteByte(0)
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf.Write(uvarint(v))
+}
+
+func uvarint(v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return b[:binary.PutUvarint(b[:], v)]
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package streaming
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// thriftReader reads the structs of the Thrift compact protocol as maps of the field IDs.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) int() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2, 3:
+		r.pos++
+		return r.b[r.pos-1] == 1
+	case 4, 5, 6:
+		return r.int()
+	case 7:
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos-8:]))
+	case 8:
+		n := int(r.uvarint())
+		r.pos += n
+		return r.b[r.pos-n : r.pos]
+	case 9:
+		h := r.b[r.pos]
+		r.pos++
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(h & 0x0f)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic("unsupported type")
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	m := make(map[int16]interface{})
+	var id int16
+	for {
+		b := r.b[r.pos]
+		r.pos++
+		if b == 0 {
+			return m
+		}
+		if delta := int16(b >> 4); delta == 0 {
+			id = int16(r.int())
+		} else {
+			id += delta
+		}
+		if typ := b & 0x0f; typ == 1 || typ == 2 {
+			m[id] = typ == 1
+		} else {
+			m[id] = r.value(typ)
+		}
+	}
+}
+
+// readParquet returns the metadata of the Parquet file and the values of the columns.
+func readParquet(t *testing.T, data []byte) (map[int16]interface{}, [][]interface{}) {
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatalf("invalid Parquet file: %x", data)
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{b: data[len(data)-8-n : len(data)-8]}
+	meta := footer.readStruct()
+	schema := meta[2].([]interface{})[1:]
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	rows := int(meta[3].(int64))
+	columns := make([][]interface{}, len(chunks))
+	for i, c := range chunks {
+		element := schema[i].(map[int16]interface{})
+		colMeta := c.(map[int16]interface{})[3].(map[int16]interface{})
+		r := &thriftReader{b: data, pos: int(colMeta[9].(int64))}
+		header := r.readStruct()
+		zr, err := gzip.NewReader(bytes.NewReader(data[r.pos : r.pos+int(header[3].(int64))]))
+		if err != nil {
+			t.Fatalf("failed to decompress the page. err: %v", err)
+		}
+		page, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("failed to decompress the page. err: %v", err)
+		}
+		defined := make([]bool, rows)
+		if element[3].(int64) == 1 {
+			n := int(binary.LittleEndian.Uint32(page))
+			levels := &thriftReader{b: page[4 : 4+n]}
+			for j := 0; levels.pos < n; {
+				run := int(levels.uvarint() >> 1)
+				v := levels.b[levels.pos] == 1
+				levels.pos++
+				for k := 0; k < run; k++ {
+					defined[j] = v
+					j++
+				}
+			}
+			page = page[4+n:]
+		} else {
+			for j := range defined {
+				defined[j] = true
+			}
+		}
+		values := make([]interface{}, rows)
+		bit := 0
+		for j := range values {
+			if !defined[j] {
+				continue
+			}
+			switch element[1].(int64) {
+			case parquetBoolean:
+				values[j] = page[bit/8]&(1<<uint(bit%8)) != 0
+				bit++
+			case parquetInt32:
+				values[j] = int32(binary.LittleEndian.Uint32(page))
+				page = page[4:]
+			case parquetInt64:
+				values[j] = int64(binary.LittleEndian.Uint64(page))
+				page = page[8:]
+			case parquetDouble:
+				values[j] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+				page = page[8:]
+			case parquetByteArray:
+				n := int(binary.LittleEndian.Uint32(page))
+				values[j] = page[4 : 4+n]
+				page = page[4+n:]
+			case parquetFixedLenByteArray:
+				n := int(element[2].(int64))
+				values[j] = page[:n]
+				page = page[n:]
+			}
+		}
+		columns[i] = values
+	}
+	return meta, columns
+}
+
+func TestWriteParquet(t *testing.T) {
+	cols := []parquetColumn{
+		{name: "ID", id: 1, physical: parquetInt32, converted: convertedDecimal, precision: 9},
+		{name: "PRICE", id: 2, physical: parquetInt64, optional: true, converted: convertedDecimal, scale: 2, precision: 10},
+		{name: "BIG", id: 3, physical: parquetFixedLenByteArray, typeLength: 16, optional: true, converted: convertedDecimal, precision: 38},
+		{name: "NAME", id: 4, physical: parquetByteArray, optional: true, converted: convertedUTF8},
+		{name: "SCORE", id: 5, physical: parquetDouble, optional: true, converted: convertedNone},
+		{name: "OK", id: 6, physical: parquetBoolean, optional: true, converted: convertedNone},
+		{name: "D", id: 7, physical: parquetInt32, optional: true, converted: convertedDate},
+	}
+	var rows [][]interface{}
+	for i := 0; i < 20; i++ {
+		row := []interface{}{int32(i), nil, nil, nil, nil, nil, nil}
+		if i%3 != 0 {
+			row[1], row[2], row[3], row[4], row[5], row[6] = int64(-i), int128Bytes(pow10(30)), []byte("n"), float64(i)/2, i%2 == 0, int32(i)
+		}
+		rows = append(rows, row)
+	}
+	meta, columns := readParquet(t, writeParquet(cols, rows, map[string]string{"primaryFileId": "a.bdec"}))
+	if meta[3].(int64) != 20 {
+		t.Errorf("failed to write the number of rows: %v", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if root := schema[0].(map[int16]interface{}); string(root[4].([]byte)) != "bdec" || root[5].(int64) != int64(len(cols)) {
+		t.Errorf("failed to write the root of the schema: %v", root)
+	}
+	for i, col := range cols {
+		element := schema[i+1].(map[int16]interface{})
+		if string(element[4].([]byte)) != col.name || element[9].(int64) != int64(col.id) {
+			t.Errorf("failed to write the column %v: %v", col.name, element)
+		}
+		if col.converted == convertedDecimal && (element[7].(int64) != int64(col.scale) || element[8].(int64) != int64(col.precision)) {
+			t.Errorf("failed to write the decimal of the column %v: %v", col.name, element)
+		}
+		for j, row := range rows {
+			if !reflect.DeepEqual(row[i], columns[i][j]) {
+				t.Errorf("failed to write the value of column %v in row %v. expected: %v, got: %v", col.name, j, row[i], columns[i][j])
+			}
+		}
+	}
+	kv := meta[5].([]interface{})[0].(map[int16]interface{})
+	if string(kv[1].([]byte)) != "primaryFileId" || string(kv[2].([]byte)) != "a.bdec" {
+		t.Errorf("failed to write the metadata: %v", kv)
+	}
+}
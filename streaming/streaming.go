@@ -0,0 +1,773 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package streaming is a client of the Snowpipe Streaming API, which inserts rows into tables through channels,
+// without staging files or running a warehouse. The rows inserted into the channels are flushed in the background
+// every second, as blobs of encrypted Parquet files uploaded to the stage of the client and registered to the
+// tables. The client authenticates with the key pair of the user of a gosnowflake.Config, i.e.,
+// Config.PrivateKey or Config.PrivateKeyFile, and Config.Role must have the INSERT privilege on the tables.
+//
+// A channel is a stream of rows into a table, in which the rows are committed in the order inserted. The offset
+// token given with the rows is committed with them, so a producer resumes from the offset token of the channel
+// reopened after a failure:
+//
+//	client, err := streaming.NewClient(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close(ctx)
+//	ch, err := client.OpenChannel(ctx, streaming.ChannelOptions{Database: "DB", Schema: "PUBLIC", Table: "ORDERS", Name: "orders-1"})
+//	if err != nil {
+//		return err
+//	}
+//	resume := ch.OffsetToken() // the last offset token committed
+//	err = ch.InsertRows([]map[string]interface{}{{"ID": 1, "ITEM": "book"}}, "1")
+//
+// A channel is invalidated if its rows fail to be committed, e.g., because the channel was reopened by another
+// client, and the rows inserted since the last commit must be inserted again into the channel reopened.
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// responseSuccess is the status code of the successful responses and channels.
+const responseSuccess = 0
+
+// maxChannelBufferSize is the size of the rows buffered in a channel that flushes the channels immediately.
+const maxChannelBufferSize = 32 << 20
+
+// flushTimeout is the timeout of the flushes in the background.
+const flushTimeout = 5 * time.Minute
+
+// maxClientLag is the interval of the flushes in the background.
+var maxClientLag = time.Second
+
+// commitPollInterval is the interval of the channel status requests waiting for the commit of a channel.
+var commitPollInterval = 100 * time.Millisecond
+
+// Client calls the Snowpipe Streaming API of the account, and flushes the rows of its channels.
+type Client struct {
+	cfg        sf.Config
+	baseURL    string
+	httpClient *http.Client
+	transport  http.RoundTripper
+
+	mu       sync.Mutex
+	stage    json.RawMessage // the stage location of the blobs
+	prefix   string          // the prefix of the blob names
+	counter  int64
+	channels map[string]*Channel
+	closed   bool
+
+	flushMu sync.Mutex // a flush at a time
+	kick    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewClient returns a client with the account, user, role and private key of the config. The host, protocol,
+// port and transport of the config are used if set, and the host is derived by gosnowflake.ConfigHost otherwise.
+// Close the client to flush the rows and stop the flushes in the background.
+func NewClient(cfg *sf.Config) (*Client, error) {
+	if _, err := sf.KeyPairJWT(cfg); err != nil {
+		return nil, err
+	}
+	host, err := sf.ConfigHost(cfg)
+	if err != nil {
+		return nil, err
+	}
+	protocol := cfg.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	if cfg.Port != 0 {
+		host += ":" + strconv.Itoa(cfg.Port)
+	}
+	transport := cfg.Transport
+	if transport == nil {
+		transport = sf.SnowflakeTransport
+	}
+	c := &Client{
+		cfg:        *cfg,
+		baseURL:    protocol + "://" + host,
+		httpClient: &http.Client{Transport: transport, Timeout: 60 * time.Second},
+		transport:  transport,
+		channels:   make(map[string]*Channel),
+		kick:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c, nil
+}
+
+// ChannelOptions is the channel to open. The database, schema and table names are resolved as in SQL, i.e.,
+// in upper case unless quoted.
+type ChannelOptions struct {
+	Database string
+	Schema   string
+	Table    string
+	Name     string
+	// OffsetToken, if not empty, replaces the offset token committed of the channel.
+	OffsetToken string
+}
+
+// Channel inserts rows into a table. A channel is safe for concurrent use, but the rows are committed in the
+// order inserted only by a producer.
+type Channel struct {
+	client          *Client
+	database        string
+	schema          string
+	table           string
+	name            string
+	clientSequencer int64
+	encryptionKey   string
+	encryptionKeyID int64
+	columns         []columnMetadata
+	parquet         []parquetColumn
+	index           map[string]int // the columns by name
+	offsetToken     *string        // committed when opened
+
+	mu               sync.Mutex
+	rowSequencer     int64
+	rows             [][]interface{}
+	stats            []*columnStats
+	size             int64
+	startOffsetToken *string
+	endOffsetToken   *string
+	latest           *string // the offset token of the last rows inserted
+	first, last      time.Time
+	err              error // the channel is invalid
+	closed           bool
+}
+
+type openChannelRequest struct {
+	RequestID   string  `json:"request_id"`
+	Role        string  `json:"role"`
+	Channel     string  `json:"channel"`
+	Table       string  `json:"table"`
+	Database    string  `json:"database"`
+	Schema      string  `json:"schema"`
+	WriteMode   string  `json:"write_mode"`
+	OffsetToken *string `json:"offset_token,omitempty"`
+}
+
+type openChannelResponse struct {
+	StatusCode      int64            `json:"status_code"`
+	Message         string           `json:"message"`
+	Database        string           `json:"database"`
+	Schema          string           `json:"schema"`
+	Table           string           `json:"table"`
+	Channel         string           `json:"channel"`
+	ClientSequencer int64            `json:"client_sequencer"`
+	RowSequencer    int64            `json:"row_sequencer"`
+	OffsetToken     *string          `json:"offset_token"`
+	TableColumns    []columnMetadata `json:"table_columns"`
+	EncryptionKey   string           `json:"encryption_key"`
+	EncryptionKeyID int64            `json:"encryption_key_id"`
+}
+
+// OpenChannel opens the channel of the table, or reopens it, which invalidates the channel opened before,
+// in this or another client.
+func (c *Client) OpenChannel(ctx context.Context, opts ChannelOptions) (*Channel, error) {
+	req := openChannelRequest{
+		RequestID: uuid.New().String(),
+		Role:      c.cfg.Role,
+		Channel:   opts.Name,
+		Table:     opts.Table,
+		Database:  opts.Database,
+		Schema:    opts.Schema,
+		WriteMode: "CLOUD_STORAGE",
+	}
+	if opts.OffsetToken != "" {
+		req.OffsetToken = &opts.OffsetToken
+	}
+	var resp openChannelResponse
+	if err := c.post(ctx, "/v1/streaming/channels/open/", req.RequestID, req, &resp); err != nil {
+		return nil, err
+	}
+	ch := &Channel{
+		client:          c,
+		database:        resp.Database,
+		schema:          resp.Schema,
+		table:           resp.Table,
+		name:            resp.Channel,
+		clientSequencer: resp.ClientSequencer,
+		rowSequencer:    resp.RowSequencer,
+		encryptionKey:   resp.EncryptionKey,
+		encryptionKeyID: resp.EncryptionKeyID,
+		columns:         resp.TableColumns,
+		index:           make(map[string]int, len(resp.TableColumns)),
+		offsetToken:     resp.OffsetToken,
+		latest:          resp.OffsetToken,
+	}
+	for i := range ch.columns {
+		col, err := ch.columns[i].parquet()
+		if err != nil {
+			return nil, err
+		}
+		ch.parquet = append(ch.parquet, col)
+		ch.index[ch.columns[i].Name] = i
+	}
+	ch.resetStats()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, fmt.Errorf("the client is closed")
+	}
+	if old, ok := c.channels[ch.FullyQualifiedName()]; ok {
+		old.invalidate(fmt.Errorf("the channel was reopened"))
+	}
+	c.channels[ch.FullyQualifiedName()] = ch
+	return ch, nil
+}
+
+// FullyQualifiedName returns the name of the channel qualified by the table, i.e., database.schema.table.channel.
+func (ch *Channel) FullyQualifiedName() string {
+	return ch.database + "." + ch.schema + "." + ch.table + "." + ch.name
+}
+
+// OffsetToken returns the offset token committed when the channel was opened, or an empty string if none.
+func (ch *Channel) OffsetToken() string {
+	if ch.offsetToken == nil {
+		return ""
+	}
+	return *ch.offsetToken
+}
+
+// InsertRow inserts a row into the channel. See InsertRows.
+func (ch *Channel) InsertRow(row map[string]interface{}, offsetToken string) error {
+	return ch.InsertRows([]map[string]interface{}{row}, offsetToken)
+}
+
+// InsertRows inserts the rows into the channel with the offset token of the rows, which is committed with the
+// rows if not empty. The keys of a row are the column names, in upper case unless quoted, and the missing
+// columns are null. None of the rows is inserted if any of them is invalid for the table.
+func (ch *Channel) InsertRows(rows []map[string]interface{}, offsetToken string) error {
+	values := make([][]interface{}, len(rows))
+	stats := make([][]interface{}, len(rows))
+	var size int64
+	for i, row := range rows {
+		values[i] = make([]interface{}, len(ch.columns))
+		stats[i] = make([]interface{}, len(ch.columns))
+		for k, v := range row {
+			j, ok := ch.index[columnKey(k)]
+			if !ok {
+				return fmt.Errorf("invalid row %v: the column %v isn't in the table %v", i, k, ch.table)
+			}
+			p, s, err := ch.columns[j].convert(v)
+			if err != nil {
+				return fmt.Errorf("invalid row %v: %v", i, err)
+			}
+			values[i][j], stats[i][j] = p, s
+			if b, ok := p.([]byte); ok {
+				size += int64(len(b))
+			} else {
+				size += 8
+			}
+		}
+		for j := range ch.columns {
+			if values[i][j] == nil && !ch.columns[j].Nullable {
+				return fmt.Errorf("invalid row %v: the not null column %v is missing", i, ch.columns[j].Name)
+			}
+		}
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if err := ch.valid(); err != nil {
+		return err
+	}
+	now := time.Now()
+	if len(ch.rows) == 0 {
+		ch.first = now
+	}
+	ch.last = now
+	ch.rows = append(ch.rows, values...)
+	for _, row := range stats {
+		for j, v := range row {
+			ch.stats[j].add(v)
+		}
+	}
+	ch.size += size
+	if offsetToken != "" {
+		token := offsetToken
+		if ch.startOffsetToken == nil {
+			ch.startOffsetToken = &token
+		}
+		ch.endOffsetToken, ch.latest = &token, &token
+	}
+	if ch.size >= maxChannelBufferSize {
+		select {
+		case ch.client.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush uploads and registers the rows inserted into the channel. The rows are committed asynchronously after,
+// so check LatestCommittedOffsetToken for the outcome, or Close the channel to wait for the commit.
+func (ch *Channel) Flush(ctx context.Context) error {
+	if err := ch.client.flush(ctx, []*Channel{ch}); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.valid()
+}
+
+type channelStatusRequest struct {
+	RequestID string          `json:"request_id"`
+	Role      string          `json:"role"`
+	Channels  []channelStatus `json:"channels"`
+}
+
+type channelStatus struct {
+	Database        string `json:"database"`
+	Schema          string `json:"schema"`
+	Table           string `json:"table"`
+	ChannelName     string `json:"channel_name"`
+	ClientSequencer int64  `json:"client_sequencer"`
+}
+
+type channelStatusResponse struct {
+	StatusCode int64  `json:"status_code"`
+	Message    string `json:"message"`
+	Channels   []struct {
+		StatusCode               int64   `json:"status_code"`
+		PersistedOffsetToken     *string `json:"persisted_offset_token"`
+		PersistedClientSequencer int64   `json:"persisted_client_sequencer"`
+		PersistedRowSequencer    int64   `json:"persisted_row_sequencer"`
+	} `json:"channels"`
+}
+
+// LatestCommittedOffsetToken returns the offset token of the last rows committed in the channel, or an empty
+// string if none.
+func (ch *Channel) LatestCommittedOffsetToken(ctx context.Context) (string, error) {
+	req := channelStatusRequest{
+		RequestID: uuid.New().String(),
+		Role:      ch.client.cfg.Role,
+		Channels: []channelStatus{{
+			Database:        ch.database,
+			Schema:          ch.schema,
+			Table:           ch.table,
+			ChannelName:     ch.name,
+			ClientSequencer: ch.clientSequencer,
+		}},
+	}
+	var resp channelStatusResponse
+	if err := ch.client.post(ctx, "/v1/streaming/channels/status/", req.RequestID, req, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Channels) != 1 {
+		return "", fmt.Errorf("failed to get the status of channel %v. channels: %v", ch.name, len(resp.Channels))
+	}
+	status := resp.Channels[0]
+	if status.StatusCode != responseSuccess {
+		return "", &sf.SnowflakeError{
+			Number:  int(status.StatusCode),
+			Message: fmt.Sprintf("failed to get the status of channel %v", ch.FullyQualifiedName()),
+		}
+	}
+	if status.PersistedClientSequencer > ch.clientSequencer {
+		err := fmt.Errorf("the channel was reopened")
+		ch.invalidate(err)
+		return "", err
+	}
+	if status.PersistedOffsetToken == nil {
+		return "", nil
+	}
+	return *status.PersistedOffsetToken, nil
+}
+
+// Close flushes the rows inserted into the channel and waits until they are committed. The channel isn't dropped,
+// so it may be reopened with the offset token committed.
+func (ch *Channel) Close(ctx context.Context) error {
+	if err := ch.Flush(ctx); err != nil {
+		return err
+	}
+	if err := ch.waitForCommit(ctx); err != nil {
+		return err
+	}
+	ch.close()
+	return nil
+}
+
+type dropChannelRequest struct {
+	RequestID       string `json:"request_id"`
+	Role            string `json:"role"`
+	Channel         string `json:"channel"`
+	Table           string `json:"table"`
+	Database        string `json:"database"`
+	Schema          string `json:"schema"`
+	ClientSequencer int64  `json:"client_sequencer"`
+}
+
+// Drop drops the channel and its offset token, discarding the rows not flushed yet. The rows inserted into the
+// table aren't deleted.
+func (ch *Channel) Drop(ctx context.Context) error {
+	ch.close()
+	req := dropChannelRequest{
+		RequestID:       uuid.New().String(),
+		Role:            ch.client.cfg.Role,
+		Channel:         ch.name,
+		Table:           ch.table,
+		Database:        ch.database,
+		Schema:          ch.schema,
+		ClientSequencer: ch.clientSequencer,
+	}
+	var resp struct{}
+	return ch.client.post(ctx, "/v1/streaming/channels/drop/", req.RequestID, req, &resp)
+}
+
+// waitForCommit waits until the last offset token inserted is committed, unless the channel is invalid.
+func (ch *Channel) waitForCommit(ctx context.Context) error {
+	ch.mu.Lock()
+	latest, err := ch.latest, ch.err
+	ch.mu.Unlock()
+	if latest == nil || err != nil {
+		return nil
+	}
+	for {
+		token, err := ch.LatestCommittedOffsetToken(ctx)
+		if err != nil {
+			return err
+		}
+		if token == *latest {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(commitPollInterval):
+		}
+	}
+}
+
+func (ch *Channel) close() {
+	ch.mu.Lock()
+	ch.closed = true
+	ch.rows = nil
+	ch.mu.Unlock()
+	c := ch.client
+	c.mu.Lock()
+	if c.channels[ch.FullyQualifiedName()] == ch {
+		delete(c.channels, ch.FullyQualifiedName())
+	}
+	c.mu.Unlock()
+}
+
+// invalidate discards the rows of the channel, which fail to insert any rows after.
+func (ch *Channel) invalidate(err error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.err == nil {
+		ch.err = fmt.Errorf("the channel %v is invalid. reopen the channel. err: %v", ch.FullyQualifiedName(), err)
+	}
+	ch.rows = nil
+}
+
+// valid returns the error if the channel is invalid or closed. The channel must be locked.
+func (ch *Channel) valid() error {
+	if ch.err != nil {
+		return ch.err
+	}
+	if ch.closed {
+		return fmt.Errorf("the channel %v is closed", ch.FullyQualifiedName())
+	}
+	return nil
+}
+
+func (ch *Channel) resetStats() {
+	ch.stats = make([]*columnStats, len(ch.columns))
+	for i := range ch.stats {
+		ch.stats[i] = &columnStats{}
+	}
+}
+
+// take returns the chunk of the rows inserted since the last flush, or nil if none.
+func (ch *Channel) take() *chunk {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if len(ch.rows) == 0 || ch.err != nil {
+		return nil
+	}
+	ch.rowSequencer++
+	k := &chunk{
+		channel:          ch,
+		rows:             ch.rows,
+		stats:            ch.stats,
+		size:             ch.size,
+		rowSequencer:     ch.rowSequencer,
+		startOffsetToken: ch.startOffsetToken,
+		endOffsetToken:   ch.endOffsetToken,
+		first:            ch.first,
+		last:             ch.last,
+	}
+	ch.rows, ch.size, ch.startOffsetToken, ch.endOffsetToken = nil, 0, nil, nil
+	ch.resetStats()
+	return k
+}
+
+// Flush uploads and registers the rows inserted into all channels of the client.
+func (c *Client) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	channels := make([]*Channel, 0, len(c.channels))
+	for _, ch := range c.channels {
+		channels = append(channels, ch)
+	}
+	c.mu.Unlock()
+	return c.flush(ctx, channels)
+}
+
+// Close flushes the rows of all channels, waits until they are committed, and stops the flushes in the background.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	close(c.done)
+	c.wg.Wait()
+	if err := c.Flush(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	channels := make([]*Channel, 0, len(c.channels))
+	for _, ch := range c.channels {
+		channels = append(channels, ch)
+	}
+	c.mu.Unlock()
+	for _, ch := range channels {
+		if err := ch.waitForCommit(ctx); err != nil {
+			return err
+		}
+		ch.close()
+	}
+	return nil
+}
+
+// run flushes the channels every maxClientLag, or when a channel is full, until the client is closed. The
+// channels failed to flush are invalidated.
+func (c *Client) run() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(maxClientLag)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		case <-c.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		c.Flush(ctx)
+		cancel()
+	}
+}
+
+// flush uploads the rows of the channels in a blob, and registers it. The channels are invalidated if the rows
+// fail to be uploaded or registered.
+func (c *Client) flush(ctx context.Context, channels []*Channel) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	var chunks []*chunk
+	for _, ch := range channels {
+		if k := ch.take(); k != nil {
+			chunks = append(chunks, k)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	if err := c.uploadBlob(ctx, chunks); err != nil {
+		for _, k := range chunks {
+			k.channel.invalidate(err)
+		}
+		return err
+	}
+	return nil
+}
+
+type configureRequest struct {
+	RequestID string `json:"request_id"`
+	Role      string `json:"role"`
+}
+
+type configureResponse struct {
+	StatusCode    int64           `json:"status_code"`
+	Message       string          `json:"message"`
+	Prefix        string          `json:"prefix"`
+	StageLocation json.RawMessage `json:"stage_location"`
+	DeploymentID  int64           `json:"deployment_id"`
+}
+
+// configure returns the stage location of the blobs and the prefix of their names, and gets them again with new
+// credentials of the stage if refresh is true.
+func (c *Client) configure(ctx context.Context, refresh bool) (json.RawMessage, string, error) {
+	c.mu.Lock()
+	stage, prefix := c.stage, c.prefix
+	c.mu.Unlock()
+	if stage != nil && !refresh {
+		return stage, prefix, nil
+	}
+	req := configureRequest{RequestID: uuid.New().String(), Role: c.cfg.Role}
+	var resp configureResponse
+	if err := c.post(ctx, "/v1/streaming/client/configure/", req.RequestID, req, &resp); err != nil {
+		return nil, "", err
+	}
+	prefix = resp.Prefix + "_" + strconv.FormatInt(resp.DeploymentID, 10)
+	c.mu.Lock()
+	c.stage, c.prefix = resp.StageLocation, prefix
+	c.mu.Unlock()
+	return resp.StageLocation, prefix, nil
+}
+
+type registerBlobRequest struct {
+	RequestID string          `json:"request_id"`
+	Role      string          `json:"role"`
+	Blobs     []*blobMetadata `json:"blobs"`
+}
+
+type registerBlobResponse struct {
+	StatusCode int64  `json:"status_code"`
+	Message    string `json:"message"`
+	Blobs      []struct {
+		Chunks []struct {
+			Database string `json:"database"`
+			Schema   string `json:"schema"`
+			Table    string `json:"table"`
+			Channels []struct {
+				StatusCode      int64  `json:"status_code"`
+				Channel         string `json:"channel"`
+				ClientSequencer int64  `json:"client_sequencer"`
+			} `json:"channels"`
+		} `json:"chunks"`
+	} `json:"blobs"`
+}
+
+// uploadBlob uploads the blob of the chunks to the stage, and registers it to the tables. The channels of the
+// chunks registered with errors are invalidated.
+func (c *Client) uploadBlob(ctx context.Context, chunks []*chunk) error {
+	start := time.Now()
+	stage, prefix, err := c.configure(ctx, false)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.counter++
+	name := blobPath(start, prefix, c.counter)
+	c.mu.Unlock()
+	blob, meta, err := buildBlob(name, chunks)
+	if err != nil {
+		return err
+	}
+	uploadStart := time.Now()
+	if err = sf.UploadToStage(ctx, c.transport, stage, name, bytes.NewReader(blob)); err != nil {
+		// the credentials of the stage may have expired
+		if stage, _, err = c.configure(ctx, true); err != nil {
+			return err
+		}
+		if err = sf.UploadToStage(ctx, c.transport, stage, name, bytes.NewReader(blob)); err != nil {
+			return err
+		}
+	}
+	meta.BlobStats = blobStats{
+		FlushStartMs:     start.UnixNano() / int64(time.Millisecond),
+		BuildDurationMs:  int64(uploadStart.Sub(start) / time.Millisecond),
+		UploadDurationMs: int64(time.Since(uploadStart) / time.Millisecond),
+	}
+
+	req := registerBlobRequest{RequestID: uuid.New().String(), Role: c.cfg.Role, Blobs: []*blobMetadata{meta}}
+	var resp registerBlobResponse
+	if err = c.post(ctx, "/v1/streaming/channels/write/blobs/", req.RequestID, req, &resp); err != nil {
+		return err
+	}
+	for _, b := range resp.Blobs {
+		for _, k := range b.Chunks {
+			for _, status := range k.Channels {
+				if status.StatusCode == responseSuccess {
+					continue
+				}
+				for _, chunk := range chunks {
+					ch := chunk.channel
+					if ch.database == k.Database && ch.schema == k.Schema && ch.table == k.Table && ch.name == status.Channel {
+						ch.invalidate(&sf.SnowflakeError{
+							Number:  int(status.StatusCode),
+							Message: "failed to register the rows of the channel",
+						})
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// response is the status of a response.
+type response struct {
+	StatusCode int64  `json:"status_code"`
+	Message    string `json:"message"`
+}
+
+// post sends the request to the endpoint with a new JWT and the request ID, and decodes the response.
+func (c *Client) post(ctx context.Context, endpoint string, requestID string, body interface{}, v interface{}) error {
+	token, err := sf.KeyPairJWT(&c.cfg)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.baseURL+endpoint+"?"+url.Values{"requestId": {requestID}}.Encode(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if b, err = ioutil.ReadAll(resp.Body); err != nil {
+		return err
+	}
+	var status response
+	if resp.StatusCode != http.StatusOK {
+		if json.Unmarshal(b, &status) != nil || status.Message == "" {
+			return fmt.Errorf("failed to call %v. HTTP: %v, body: %s", endpoint, resp.StatusCode, b)
+		}
+		return &sf.SnowflakeError{Number: int(status.StatusCode), Message: status.Message}
+	}
+	if err = json.Unmarshal(b, &status); err != nil {
+		return fmt.Errorf("failed to parse the response of %v. err: %v", endpoint, err)
+	}
+	if status.StatusCode != responseSuccess {
+		return &sf.SnowflakeError{Number: int(status.StatusCode), Message: status.Message}
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to parse the response of %v. err: %v", endpoint, err)
+	}
+	return nil
+}
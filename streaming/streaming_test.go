@@ -0,0 +1,444 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package streaming
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+const testColumns = `[
+{"name":"ID","type":"NUMBER(9,0)","logical_type":"FIXED","physical_type":"SB4","precision":9,"scale":0,"nullable":false,"ordinal":1},
+{"name":"PRICE","type":"NUMBER(10,2)","logical_type":"FIXED","physical_type":"SB8","precision":10,"scale":2,"nullable":true,"ordinal":2},
+{"name":"BIG","type":"NUMBER(38,0)","logical_type":"FIXED","physical_type":"SB16","precision":38,"scale":0,"nullable":true,"ordinal":3},
+{"name":"NAME","type":"VARCHAR(10)","logical_type":"TEXT","physical_type":"LOB","length":10,"byte_length":40,"nullable":true,"ordinal":4},
+{"name":"SCORE","type":"FLOAT","logical_type":"REAL","physical_type":"DOUBLE","nullable":true,"ordinal":5},
+{"name":"OK","type":"BOOLEAN","logical_type":"BOOLEAN","physical_type":"SB1","nullable":true,"ordinal":6},
+{"name":"TS","type":"TIMESTAMP_NTZ(6)","logical_type":"TIMESTAMP_NTZ","physical_type":"SB8","scale":6,"nullable":true,"ordinal":7},
+{"name":"D","type":"DATE","logical_type":"DATE","physical_type":"SB4","nullable":true,"ordinal":8},
+{"name":"V","type":"VARIANT","logical_type":"VARIANT","physical_type":"LOB","nullable":true,"ordinal":9},
+{"name":"Lower","type":"VARCHAR(10)","logical_type":"TEXT","physical_type":"LOB","length":10,"nullable":true,"ordinal":10}]`
+
+// fakeService is the Snowpipe Streaming API of a table with the stage in a local directory.
+type fakeService struct {
+	t     *testing.T
+	dir   string
+	key   string
+	mu    sync.Mutex
+	calls map[string]int
+	blobs []blobMetadata
+	// the status of the channels registered, which commits the offset token if 0
+	registerStatus int64
+	committed      *string
+	// the status requests before the offset token registered is committed
+	commitDelay int
+}
+
+func (s *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") ||
+		r.Header.Get("X-Snowflake-Authorization-Token-Type") != "KEYPAIR_JWT" {
+		s.t.Errorf("failed to authorize the request. header: %v", r.Header)
+	}
+	if r.URL.Query().Get("requestId") == "" {
+		s.t.Errorf("failed to set the request ID. url: %v", r.URL)
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[r.URL.Path]++
+	switch r.URL.Path {
+	case "/v1/streaming/client/configure/":
+		location, _ := json.Marshal(map[string]string{"locationType": "LOCAL_FS", "location": s.dir})
+		w.Write([]byte(`{"status_code":0,"message":"Success","prefix":"abc","deployment_id":1,"stage_location":` + string(location) + `}`))
+	case "/v1/streaming/channels/open/":
+		var req openChannelRequest
+		json.Unmarshal(body, &req)
+		if req.WriteMode != "CLOUD_STORAGE" || req.Role != "R" {
+			s.t.Errorf("invalid request to open a channel: %s", body)
+		}
+		if req.Table == "MISSING" {
+			w.Write([]byte(`{"status_code":4,"message":"The supplied table does not exist or is not authorized."}`))
+			return
+		}
+		if s.committed == nil {
+			token := "7"
+			s.committed = &token
+		}
+		w.Write([]byte(`{"status_code":0,"message":"Success","database":"` + req.Database + `","schema":"` + req.Schema +
+			`","table":"` + req.Table + `","channel":"` + req.Channel + `","client_sequencer":3,"row_sequencer":5,
+"offset_token":"7","encryption_key":"` + s.key + `","encryption_key_id":11,"table_columns":` + testColumns + `}`))
+	case "/v1/streaming/channels/write/blobs/":
+		var req struct {
+			Blobs []blobMetadata `json:"blobs"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.t.Errorf("invalid request to register the blobs: %s", body)
+		}
+		s.blobs = append(s.blobs, req.Blobs...)
+		status := `{"status_code":0,"message":"Success","blobs":[`
+		for i, b := range req.Blobs {
+			if i > 0 {
+				status += ","
+			}
+			status += `{"chunks":[`
+			for j, k := range b.Chunks {
+				if j > 0 {
+					status += ","
+				}
+				ch := k.Channels[0]
+				status += `{"database":"` + k.Database + `","schema":"` + k.Schema + `","table":"` + k.Table +
+					`","channels":[{"status_code":` + strconv.FormatInt(s.registerStatus, 10) + `,"channel":"` + ch.ChannelName + `","client_sequencer":3}]}`
+				if s.registerStatus == 0 {
+					s.committed = ch.OffsetToken
+				}
+			}
+			status += `]}`
+		}
+		w.Write([]byte(status + `]}`))
+	case "/v1/streaming/channels/status/":
+		token := s.committed
+		if s.commitDelay > 0 {
+			s.commitDelay--
+			token = nil
+		}
+		b, _ := json.Marshal(token)
+		w.Write([]byte(`{"status_code":0,"message":"Success","channels":[{"status_code":0,"persisted_offset_token":` + string(b) +
+			`,"persisted_client_sequencer":3,"persisted_row_sequencer":6}]}`))
+	case "/v1/streaming/channels/drop/":
+		w.Write([]byte(`{"status_code":0,"message":"Success"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status_code":404,"message":"Not found"}`))
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeService, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate a key. err: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "streaming")
+	if err != nil {
+		t.Fatalf("failed to create the stage. err: %v", err)
+	}
+	encryptionKey := make([]byte, 32)
+	rand.Read(encryptionKey)
+	s := &fakeService{t: t, dir: dir, key: base64.StdEncoding.EncodeToString(encryptionKey), calls: make(map[string]int)}
+	ts := httptest.NewServer(s)
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := &sf.Config{Account: "a", User: "u", Role: "R", PrivateKey: key, Protocol: "http", Host: u.Hostname(), Port: port}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create the client. err: %v", err)
+	}
+	return client, s, func() {
+		client.Close(context.Background())
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestInsertRows(t *testing.T) {
+	client, s, done := newTestClient(t)
+	defer done()
+	ctx := context.Background()
+	if _, err := NewClient(&sf.Config{Account: "a", User: "u"}); err != sf.ErrEmptyPrivateKey {
+		t.Errorf("should have failed without a private key. err: %v", err)
+	}
+	if _, err := client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "MISSING", Name: "C"}); err == nil ||
+		err.(*sf.SnowflakeError).Number != 4 {
+		t.Errorf("should have failed to open the channel of the missing table. err: %v", err)
+	}
+	ch, err := client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to open the channel. err: %v", err)
+	}
+	if ch.OffsetToken() != "7" || ch.FullyQualifiedName() != "DB.S.T.C" {
+		t.Errorf("failed to open the channel. offset token: %v, name: %v", ch.OffsetToken(), ch.FullyQualifiedName())
+	}
+	if err = ch.InsertRow(map[string]interface{}{
+		"id": 1, "price": "12.345", "big": "-123456789012345678901234567890", "name": "abc", "score": 1.5, "ok": true,
+		"ts": "2018-01-02 03:04:05.123456789", "d": "2018-01-02", "v": map[string]int{"a": 1}, `"Lower"`: "x",
+	}, "1"); err != nil {
+		t.Fatalf("failed to insert the row. err: %v", err)
+	}
+	if err = ch.InsertRows([]map[string]interface{}{{"ID": 2}, {"ID": 3, "NAME": "ab"}}, "3"); err != nil {
+		t.Fatalf("failed to insert the rows. err: %v", err)
+	}
+	if err = ch.Flush(ctx); err != nil {
+		t.Fatalf("failed to flush the channel. err: %v", err)
+	}
+	if len(s.blobs) != 1 || len(s.blobs[0].Chunks) != 1 {
+		t.Fatalf("failed to register the blob: %v", s.blobs)
+	}
+	b := s.blobs[0]
+	blob, err := ioutil.ReadFile(filepath.Join(s.dir, b.Path))
+	if err != nil {
+		t.Fatalf("failed to upload the blob. err: %v", err)
+	}
+	if sum := md5.Sum(blob); hex.EncodeToString(sum[:]) != b.MD5 || b.BDECVersion != bdecVersion || !strings.HasSuffix(b.Path, ".bdec") {
+		t.Errorf("invalid blob: %v, %v, %v", b.Path, b.MD5, b.BDECVersion)
+	}
+	k := b.Chunks[0]
+	data := append([]byte(nil), blob[k.ChunkStartOffset:k.ChunkStartOffset+int64(k.ChunkLength)]...)
+	if sum := md5.Sum(data); hex.EncodeToString(sum[:]) != k.ChunkMD5 {
+		t.Errorf("invalid MD5 of the chunk: %v", k.ChunkMD5)
+	}
+	if err = encryptChunk(data, s.key, b.Path, k.ChunkStartOffset); err != nil {
+		t.Fatalf("failed to decrypt the chunk. err: %v", err)
+	}
+	_, columns := readParquet(t, data)
+	bigValue, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	expected := [][]interface{}{
+		{int32(1), int32(2), int32(3)},
+		{int64(1235), nil, nil},
+		{int128Bytes(bigValue), nil, nil},
+		{[]byte("abc"), nil, []byte("ab")},
+		{1.5, nil, nil},
+		{true, nil, nil},
+		{int64(1514862245123456), nil, nil},
+		{int32(17533), nil, nil},
+		{[]byte(`{"a":1}`), nil, nil},
+		{[]byte("x"), nil, nil},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("failed to write the rows.\nexpected: %v\ngot:      %v", expected, columns)
+	}
+	channel := k.Channels[0]
+	if k.Database != "DB" || k.Table != "T" || channel.ChannelName != "C" || channel.ClientSequencer != 3 || channel.RowSequencer != 6 ||
+		*channel.StartOffsetToken != "1" || *channel.EndOffsetToken != "3" || *channel.OffsetToken != "3" || k.EncryptionKeyID != 11 {
+		t.Errorf("invalid chunk metadata: %+v, channel: %+v", k, channel)
+	}
+	if id := k.EPS.Columns["ID"]; k.EPS.Rows != 3 || id.MinIntValue.Int64() != 1 || id.MaxIntValue.Int64() != 3 || id.NullCount != 0 {
+		t.Errorf("invalid stats of ID: %+v", id)
+	}
+	if name := k.EPS.Columns["NAME"]; *name.MinStrValue != hex.EncodeToString([]byte("ab")) ||
+		*name.MaxStrValue != hex.EncodeToString([]byte("abc")) || name.NullCount != 1 || name.MaxLength != 3 {
+		t.Errorf("invalid stats of NAME: %+v", name)
+	}
+	if score := k.EPS.Columns["SCORE"]; score.MinRealValue != 1.5 || score.NullCount != 2 {
+		t.Errorf("invalid stats of SCORE: %+v", score)
+	}
+
+	token, err := ch.LatestCommittedOffsetToken(ctx)
+	if err != nil || token != "3" {
+		t.Errorf("failed to get the offset token committed. token: %v, err: %v", token, err)
+	}
+	if err = ch.Flush(ctx); err != nil || len(s.blobs) != 1 {
+		t.Errorf("should have flushed nothing. err: %v, blobs: %v", err, len(s.blobs))
+	}
+	if s.calls["/v1/streaming/client/configure/"] != 1 {
+		t.Errorf("failed to configure the client once: %v", s.calls)
+	}
+}
+
+func TestInsertRowsInvalid(t *testing.T) {
+	client, s, done := newTestClient(t)
+	defer done()
+	ch, err := client.OpenChannel(context.Background(), ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to open the channel. err: %v", err)
+	}
+	testcases := []map[string]interface{}{
+		{"NAME": "a"},
+		{"ID": nil},
+		{"ID": 1, "X": 1},
+		{"ID": "a"},
+		{"ID": 1000000000},
+		{"ID": 1, "NAME": "12345678901"},
+		{"ID": 1, "OK": "maybe"},
+		{"ID": 1, "TS": "yesterday"},
+		{"ID": 1, "V": "{"},
+		{"ID": 1, "Lower": "x"},
+	}
+	for _, row := range testcases {
+		if err = ch.InsertRows([]map[string]interface{}{{"ID": 1}, row}, "1"); err == nil || !strings.Contains(err.Error(), "invalid row 1") {
+			t.Errorf("should have failed to insert %v. err: %v", row, err)
+		}
+	}
+	if err = ch.Flush(context.Background()); err != nil || len(s.blobs) != 0 {
+		t.Errorf("should have inserted none of the rows. err: %v, blobs: %v", err, len(s.blobs))
+	}
+}
+
+func TestChannelInvalidated(t *testing.T) {
+	client, s, done := newTestClient(t)
+	defer done()
+	ctx := context.Background()
+	ch, err := client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to open the channel. err: %v", err)
+	}
+	s.registerStatus = 19
+	if err = ch.InsertRow(map[string]interface{}{"ID": 1}, "1"); err != nil {
+		t.Fatalf("failed to insert the row. err: %v", err)
+	}
+	if err = ch.Flush(ctx); err == nil || !strings.Contains(err.Error(), "reopen the channel") {
+		t.Errorf("should have invalidated the channel. err: %v", err)
+	}
+	if err = ch.InsertRow(map[string]interface{}{"ID": 2}, "2"); err == nil {
+		t.Error("should have failed to insert into the invalid channel")
+	}
+
+	s.registerStatus = 0
+	reopened, err := client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to reopen the channel. err: %v", err)
+	}
+	if err = reopened.InsertRow(map[string]interface{}{"ID": 2}, "2"); err != nil {
+		t.Errorf("failed to insert into the channel reopened. err: %v", err)
+	}
+	other, err := client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to reopen the channel. err: %v", err)
+	}
+	if err = reopened.InsertRow(map[string]interface{}{"ID": 3}, "3"); err == nil || !strings.Contains(err.Error(), "reopened") {
+		t.Errorf("should have invalidated the channel reopened again. err: %v", err)
+	}
+	if err = other.Drop(ctx); err != nil || s.calls["/v1/streaming/channels/drop/"] != 1 {
+		t.Errorf("failed to drop the channel. err: %v", err)
+	}
+	if err = other.InsertRow(map[string]interface{}{"ID": 4}, "4"); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("should have failed to insert into the channel dropped. err: %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	interval := commitPollInterval
+	commitPollInterval = time.Millisecond
+	defer func() { commitPollInterval = interval }()
+	client, s, done := newTestClient(t)
+	defer done()
+	ctx := context.Background()
+	ch, err := client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to open the channel. err: %v", err)
+	}
+	if err = ch.InsertRow(map[string]interface{}{"ID": 1}, "1"); err != nil {
+		t.Fatalf("failed to insert the row. err: %v", err)
+	}
+	s.commitDelay = 3
+	if err = client.Close(ctx); err != nil {
+		t.Fatalf("failed to close the client. err: %v", err)
+	}
+	if len(s.blobs) != 1 || s.calls["/v1/streaming/channels/status/"] != 4 {
+		t.Errorf("failed to wait for the commit. blobs: %v, calls: %v", len(s.blobs), s.calls)
+	}
+	if err = ch.InsertRow(map[string]interface{}{"ID": 2}, "2"); err == nil {
+		t.Error("should have failed to insert into the channel closed")
+	}
+	if _, err = client.OpenChannel(ctx, ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"}); err == nil {
+		t.Error("should have failed to open a channel of the client closed")
+	}
+}
+
+func TestFlushInBackground(t *testing.T) {
+	lag := maxClientLag
+	maxClientLag = 10 * time.Millisecond
+	defer func() { maxClientLag = lag }()
+	client, s, done := newTestClient(t)
+	defer done()
+	ch, err := client.OpenChannel(context.Background(), ChannelOptions{Database: "DB", Schema: "S", Table: "T", Name: "C"})
+	if err != nil {
+		t.Fatalf("failed to open the channel. err: %v", err)
+	}
+	if err = ch.InsertRow(map[string]interface{}{"ID": 1}, "1"); err != nil {
+		t.Fatalf("failed to insert the row. err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		n := len(s.blobs)
+		s.mu.Unlock()
+		if n == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("failed to flush the channel in the background")
+}
+
+func TestConvert(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	ts := time.Date(2018, 1, 2, 3, 4, 5, 123456789, tokyo)
+	testcases := []struct {
+		col      columnMetadata
+		value    interface{}
+		expected interface{}
+		stats    interface{}
+	}{
+		{col: columnMetadata{LogicalType: "FIXED", PhysicalType: "SB4", Precision: 5, Scale: 2}, value: -1.005, expected: int32(-101), stats: big.NewInt(-101)},
+		{col: columnMetadata{LogicalType: "FIXED", PhysicalType: "SB8", Precision: 18, Scale: 1}, value: json.Number("2.25"), expected: int64(23), stats: big.NewInt(23)},
+		{col: columnMetadata{LogicalType: "REAL", PhysicalType: "DOUBLE"}, value: "NaN", stats: "NaN"},
+		{col: columnMetadata{LogicalType: "BOOLEAN", PhysicalType: "SB1"}, value: "off", expected: false, stats: big.NewInt(0)},
+		{col: columnMetadata{LogicalType: "TIME", PhysicalType: "SB4", Scale: 3}, value: ts, expected: int32(11045123), stats: big.NewInt(11045123)},
+		{col: columnMetadata{LogicalType: "TIMESTAMP_LTZ", PhysicalType: "SB8", Scale: 3}, value: ts, expected: int64(1514829845123), stats: big.NewInt(1514829845123)},
+		{col: columnMetadata{LogicalType: "TIMESTAMP_NTZ", PhysicalType: "SB8", Scale: 0}, value: ts, expected: int64(1514862245), stats: big.NewInt(1514862245)},
+		{col: columnMetadata{LogicalType: "TIMESTAMP_TZ", PhysicalType: "SB8", Scale: 0}, value: ts, expected: int64(1514829845<<14 | (540 + 1440)), stats: big.NewInt(1514829845)},
+		{col: columnMetadata{LogicalType: "TIMESTAMP_TZ", PhysicalType: "SB16", Scale: 9}, value: ts,
+			expected: int128Bytes(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1514829845123456789), 14), big.NewInt(1980))), stats: big.NewInt(1514829845123456789)},
+		{col: columnMetadata{LogicalType: "ARRAY", PhysicalType: "LOB"}, value: 1, expected: []byte("[1]"), stats: []byte("[1]")},
+		{col: columnMetadata{LogicalType: "BINARY", PhysicalType: "LOB"}, value: "0aff", expected: []byte{0x0a, 0xff}, stats: []byte{0x0a, 0xff}},
+	}
+	for _, test := range testcases {
+		p, s, err := test.col.convert(test.value)
+		if err != nil {
+			t.Errorf("failed to convert %v to %v. err: %v", test.value, test.col.LogicalType, err)
+			continue
+		}
+		if f, ok := p.(float64); ok {
+			if b, _ := statsFloat(f).MarshalJSON(); string(b) != `"`+test.stats.(string)+`"` {
+				t.Errorf("failed to convert %v to %v. got: %s", test.value, test.col.LogicalType, b)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(p, test.expected) || !reflect.DeepEqual(s, test.stats) {
+			t.Errorf("failed to convert %v to %v. expected: %v, %v, got: %v, %v", test.value, test.col.LogicalType, test.expected, test.stats, p, s)
+		}
+	}
+	long := []byte(strings.Repeat("a", maxStatsLength) + "\xff")
+	if max := truncateUp(long); string(max) != strings.Repeat("a", maxStatsLength-1)+"b" {
+		t.Errorf("failed to truncate the max string up: %q", max)
+	}
+	if min := truncateDown(long); len(min) != maxStatsLength {
+		t.Errorf("failed to truncate the min string down: %q", min)
+	}
+}
+
+func TestNewClientHost(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate a key. err: %v", err)
+	}
+	client, err := NewClient(&sf.Config{Account: "xy12345", Region: "east-us-2", User: "u", PrivateKey: key})
+	if err != nil {
+		t.Fatalf("failed to create the client. err: %v", err)
+	}
+	defer client.Close(context.Background())
+	if expected := "https://xy12345.east-us-2.azure.snowflakecomputing.com"; client.baseURL != expected {
+		t.Errorf("failed to get the host. expected: %v, got: %v", expected, client.baseURL)
+	}
+	if _, err = NewClient(&sf.Config{Account: "myorg-myaccount", Region: "us-east-1", User: "u", PrivateKey: key}); err == nil {
+		t.Error("should have failed with the region of an organization account")
+	}
+}
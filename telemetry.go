@@ -0,0 +1,173 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionClientTelemetryEnabled = "client_telemetry_enabled"
+	// telemetryFlushSize is the number of events sent in a batch.
+	telemetryFlushSize = 100
+	// maxPendingOCSPTelemetry is the number of OCSP events kept until a connection sends them.
+	maxPendingOCSPTelemetry = 100
+)
+
+// The types of the telemetry events.
+const (
+	telemetryAuthLatency          = "client_authentication_latency"
+	telemetryQueryRoundTrip       = "client_query_round_trip"
+	telemetryChunkDownloadFailure = "client_chunk_download_failure"
+	telemetryOCSPFailure          = "client_ocsp_failure"
+)
+
+// telemetryData is an event in the batch sent to /telemetry/send.
+type telemetryData struct {
+	Timestamp int64             `json:"timestamp"`
+	Message   map[string]string `json:"message"`
+}
+
+func newTelemetryData(typ string, message map[string]string) *telemetryData {
+	message["type"] = typ
+	message["source"] = "GoDriver"
+	message["driver_version"] = SnowflakeGoDriverVersion
+	return &telemetryData{Timestamp: getClock().Now().UnixNano() / int64(time.Millisecond), Message: message}
+}
+
+// snowflakeTelemetry buffers the telemetry events of a connection and sends them in batches. The methods do
+// nothing if the telemetry is disabled or nil.
+type snowflakeTelemetry struct {
+	mu      sync.Mutex
+	sr      *snowflakeRestful
+	logs    []*telemetryData
+	enabled bool
+}
+
+// newTelemetry returns the telemetry of the connection, enabled unless Config.DisableTelemetry is set or the
+// session sets CLIENT_TELEMETRY_ENABLED to false.
+func newTelemetry(sc *snowflakeConn) *snowflakeTelemetry {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	enabled := !sc.cfg.DisableTelemetry
+	for k, v := range sc.cfg.Params {
+		if strings.EqualFold(k, sessionClientTelemetryEnabled) && v != nil {
+			if b, err := strconv.ParseBool(*v); err == nil && !b {
+				enabled = false
+			}
+		}
+	}
+	return &snowflakeTelemetry{sr: sc.rest, enabled: enabled}
+}
+
+// addLog buffers the event, and sends the batch in the background once it's full.
+func (st *snowflakeTelemetry) addLog(data *telemetryData) {
+	if st == nil || !st.enabled {
+		return
+	}
+	st.mu.Lock()
+	st.logs = append(st.logs, takePendingOCSPTelemetry()...)
+	st.logs = append(st.logs, data)
+	full := len(st.logs) >= telemetryFlushSize
+	st.mu.Unlock()
+	if full {
+		go func() {
+			if err := st.sendBatch(); err != nil {
+				glog.V(2).Infof("failed to send the telemetry. err: %v", err)
+			}
+		}()
+	}
+}
+
+// sendBatch sends the buffered events. The events are dropped if they fail to be sent.
+func (st *snowflakeTelemetry) sendBatch() error {
+	if st == nil || !st.enabled {
+		return nil
+	}
+	st.mu.Lock()
+	logs := append(st.logs, takePendingOCSPTelemetry()...)
+	st.logs = nil
+	st.mu.Unlock()
+	if len(logs) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"logs": logs})
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string)
+	headers["Content-Type"] = headerContentTypeApplicationJSON
+	headers["accept"] = headerAcceptTypeApplicationSnowflake
+	headers["User-Agent"] = userAgent
+	token, _, _ := st.sr.getTokens()
+	headers[headerAuthorizationKey] = fmt.Sprintf(headerSnowflakeToken, token)
+	resp, err := st.sr.FuncPost(context.Background(), st.sr, st.sr.getFullURL(endpointTelemetry, nil), headers, body, st.sr.RequestTimeout, false)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP: %v", resp.StatusCode)
+	}
+	glog.V(2).Infof("sent %v telemetry events", len(logs))
+	return nil
+}
+
+func (st *snowflakeTelemetry) addAuthLatency(authenticator string, latency time.Duration) {
+	st.addLog(newTelemetryData(telemetryAuthLatency, map[string]string{
+		"authenticator": authenticator,
+		"latency_ms":    strconv.FormatInt(int64(latency/time.Millisecond), 10),
+	}))
+}
+
+func (st *snowflakeTelemetry) addQueryRoundTrip(queryID string, roundTrip time.Duration) {
+	st.addLog(newTelemetryData(telemetryQueryRoundTrip, map[string]string{
+		"query_id":      queryID,
+		"round_trip_ms": strconv.FormatInt(int64(roundTrip/time.Millisecond), 10),
+	}))
+}
+
+func (st *snowflakeTelemetry) addChunkDownloadFailure(queryID string, index int, err error) {
+	st.addLog(newTelemetryData(telemetryChunkDownloadFailure, map[string]string{
+		"query_id":    queryID,
+		"chunk_index": strconv.Itoa(index),
+		"error":       maskSecrets(err.Error()),
+	}))
+}
+
+var (
+	pendingOCSPTelemetry   []*telemetryData
+	pendingOCSPTelemetryMu sync.Mutex
+)
+
+// addOCSPFailure keeps the failed OCSP check to be sent by a connection, as the checks are done by the
+// transports shared by the connections.
+func addOCSPFailure(err error, failOpen bool) {
+	data := newTelemetryData(telemetryOCSPFailure, map[string]string{
+		"error":     maskSecrets(err.Error()),
+		"fail_open": strconv.FormatBool(failOpen),
+	})
+	pendingOCSPTelemetryMu.Lock()
+	defer pendingOCSPTelemetryMu.Unlock()
+	if len(pendingOCSPTelemetry) < maxPendingOCSPTelemetry {
+		pendingOCSPTelemetry = append(pendingOCSPTelemetry, data)
+	}
+}
+
+func takePendingOCSPTelemetry() []*telemetryData {
+	pendingOCSPTelemetryMu.Lock()
+	defer pendingOCSPTelemetryMu.Unlock()
+	logs := pendingOCSPTelemetry
+	pendingOCSPTelemetry = nil
+	return logs
+}
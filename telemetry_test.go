@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type telemetryRecorder struct {
+	mu      sync.Mutex
+	urls    []string
+	batches [][]*telemetryData
+}
+
+func (r *telemetryRecorder) post(_ context.Context, _ *snowflakeRestful, fullURL string, headers map[string]string, body []byte, _ time.Duration, _ bool) (*http.Response, error) {
+	var req struct {
+		Logs []*telemetryData `json:"logs"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = append(r.urls, fullURL)
+	r.batches = append(r.batches, req.Logs)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{"success":true}`)))}, nil
+}
+
+func TestUnitTelemetry(t *testing.T) {
+	takePendingOCSPTelemetry()
+	r := &telemetryRecorder{}
+	sc := getDefaultSnowflakeConn()
+	sc.rest = &snowflakeRestful{Protocol: "https", Host: "a.snowflakecomputing.com", Port: 443, Token: "t", FuncPost: r.post}
+	sc.telemetry = newTelemetry(sc)
+
+	sc.telemetry.addAuthLatency(authenticatorSnowflake, 1500*time.Millisecond)
+	sc.telemetry.addQueryRoundTrip("01-q", 20*time.Millisecond)
+	sc.telemetry.addChunkDownloadFailure("01-q", 3, errors.New("HTTP: 403, URL: https://b?X-Amz-Signature=secret"))
+	addOCSPFailure(errors.New("OCSP responder timed out"), true)
+	if len(r.batches) != 0 {
+		t.Fatalf("should have buffered the events. got: %v", r.batches)
+	}
+	if err := sc.telemetry.sendBatch(); err != nil {
+		t.Fatalf("failed to send the telemetry. err: %v", err)
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 4 || r.urls[0] != "https://a.snowflakecomputing.com:443/telemetry/send" {
+		t.Fatalf("failed to send the events in a batch. urls: %v, got: %v", r.urls, r.batches)
+	}
+	expected := []map[string]string{
+		{"type": telemetryAuthLatency, "authenticator": authenticatorSnowflake, "latency_ms": "1500"},
+		{"type": telemetryQueryRoundTrip, "query_id": "01-q", "round_trip_ms": "20"},
+		{"type": telemetryChunkDownloadFailure, "query_id": "01-q", "chunk_index": "3"},
+		{"type": telemetryOCSPFailure, "fail_open": "true", "error": "OCSP responder timed out"},
+	}
+	for i, e := range expected {
+		m := r.batches[0][i].Message
+		for k, v := range e {
+			if m[k] != v {
+				t.Errorf("failed to send %v of the event %v. expected: %v, got: %v", k, i, v, m[k])
+			}
+		}
+		if m["driver_version"] != SnowflakeGoDriverVersion || r.batches[0][i].Timestamp == 0 {
+			t.Errorf("failed to add the driver version and timestamp. got: %v", r.batches[0][i])
+		}
+	}
+	if strings.Contains(r.batches[0][2].Message["error"], "secret") {
+		t.Errorf("failed to mask the secret. got: %v", r.batches[0][2].Message["error"])
+	}
+	if err := sc.telemetry.sendBatch(); err != nil || len(r.batches) != 1 {
+		t.Errorf("should have sent no empty batch. err: %v, got: %v", err, r.batches)
+	}
+
+	for i := 0; i < telemetryFlushSize; i++ {
+		sc.telemetry.addQueryRoundTrip("01-q", time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		r.mu.Lock()
+		n := len(r.batches)
+		r.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.mu.Lock()
+	if len(r.batches) != 2 || len(r.batches[1]) != telemetryFlushSize {
+		t.Errorf("failed to send the full batch. got: %v", len(r.batches))
+	}
+	r.mu.Unlock()
+}
+
+func TestUnitTelemetryDisabled(t *testing.T) {
+	r := &telemetryRecorder{}
+	byConfig := getDefaultSnowflakeConn()
+	byConfig.cfg.DisableTelemetry = true
+	bySession := getDefaultSnowflakeConn()
+	disabled := "false"
+	bySession.cfg.Params["CLIENT_TELEMETRY_ENABLED"] = &disabled
+	for _, sc := range []*snowflakeConn{byConfig, bySession} {
+		sc.rest = &snowflakeRestful{FuncPost: r.post}
+		sc.telemetry = newTelemetry(sc)
+		sc.telemetry.addQueryRoundTrip("01-q", time.Millisecond)
+		if err := sc.telemetry.sendBatch(); err != nil {
+			t.Errorf("failed to skip the telemetry. err: %v", err)
+		}
+	}
+	var st *snowflakeTelemetry
+	st.addQueryRoundTrip("01-q", time.Millisecond)
+	if err := st.sendBatch(); err != nil {
+		t.Errorf("failed to skip the telemetry. err: %v", err)
+	}
+	if len(r.batches) != 0 {
+		t.Errorf("should have sent no telemetry. got: %v", r.batches)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey string
+
+const (
+	statementTimeoutKey  contextKey = "statementTimeout"
+	statementDeadlineKey contextKey = "statementDeadline"
+)
+
+// statementDeadline is the statement timeout of the context of a statement.
+type statementDeadline struct {
+	parent  context.Context
+	timeout time.Duration
+}
+
+// WithStatementTimeout returns a context that bounds the total time of each statement run with it, including
+// fetching the result, to d. The query is cancelled in Snowflake if the timeout fires before it completes.
+// Unlike context.WithTimeout, the timer starts when the statement starts, so the context may be used for
+// several statements. The statement fails with ErrQueryTimeout after the timeout. It overrides
+// Config.QueryTimeout, and 0 disables it.
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey, d)
+}
+
+// withStatementDeadline derives the context of a statement bounded by the statement timeout in ctx, or by
+// defaultTimeout, i.e., Config.QueryTimeout, if ctx has none.
+func withStatementDeadline(ctx context.Context, defaultTimeout time.Duration) (context.Context, context.CancelFunc) {
+	d, ok := ctx.Value(statementTimeoutKey).(time.Duration)
+	if !ok {
+		d = defaultTimeout
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	sctx, cancel := context.WithTimeout(ctx, d)
+	return context.WithValue(sctx, statementDeadlineKey, &statementDeadline{parent: ctx, timeout: d}), cancel
+}
+
+// queryTimeoutError returns ErrQueryTimeout if err is the expiry of the statement timeout of ctx, or err otherwise,
+// e.g., context.DeadlineExceeded if the deadline of the context of the application expired first.
+func queryTimeoutError(ctx context.Context, err error) error {
+	sd, ok := ctx.Value(statementDeadlineKey).(*statementDeadline)
+	if !ok || err != context.DeadlineExceeded || sd.parent.Err() != nil {
+		return err
+	}
+	return &SnowflakeError{
+		Number:      ErrQueryTimeout,
+		SQLState:    SQLStateQueryCanceled,
+		Message:     errMsgQueryTimeout,
+		MessageArgs: []interface{}{sd.timeout},
+		Err:         err,
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// timeoutTestConn returns a connection whose queries run until the context is done, and records the requests
+// cancelled.
+func timeoutTestConn(cfg *Config) (*snowflakeConn, chan string) {
+	cancelled := make(chan string, 2)
+	sc := &snowflakeConn{
+		cfg: cfg,
+		rest: &snowflakeRestful{
+			FuncPostQuery: postRestfulQuery,
+			FuncPostQueryHelper: func(ctx context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, _ []byte, _ time.Duration, _ string) (*execResponse, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			FuncCancelQuery: func(_ *snowflakeRestful, requestID string) error {
+				cancelled <- requestID
+				return nil
+			},
+		},
+	}
+	return sc, cancelled
+}
+
+func TestWithStatementTimeout(t *testing.T) {
+	sc, cancelled := timeoutTestConn(&Config{})
+	ctx := WithStatementTimeout(context.Background(), 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		// the timer starts per statement, so the context is reusable.
+		start := time.Now()
+		_, err := sc.ExecContext(ctx, "select system$wait(10)", nil)
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrQueryTimeout || driverErr.Err != context.DeadlineExceeded {
+			t.Fatalf("should have timed out. err: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("failed to time out in time. elapsed: %v", elapsed)
+		}
+		select {
+		case <-cancelled:
+		default:
+			t.Errorf("failed to cancel the query")
+		}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("should not have set the deadline on the parent context")
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	sc, cancelled := timeoutTestConn(&Config{QueryTimeout: 10 * time.Millisecond})
+	_, err := sc.QueryContext(context.Background(), "select system$wait(10)", nil)
+	if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrQueryTimeout || driverErr.SQLState != SQLStateQueryCanceled {
+		t.Fatalf("should have timed out. err: %v", err)
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Errorf("failed to cancel the query")
+	}
+
+	// the deadline of the application is not a statement timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	sc.cfg.QueryTimeout = time.Minute
+	if _, err = sc.ExecContext(ctx, "select system$wait(10)", nil); err != context.DeadlineExceeded {
+		t.Errorf("should have returned the context error. err: %v", err)
+	}
+}
+
+func TestWithStatementDeadline(t *testing.T) {
+	ctx, cancel := withStatementDeadline(context.Background(), 0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("should not have set a deadline without the statement timeout")
+	}
+	cancel()
+	ctx, cancel = withStatementDeadline(WithStatementTimeout(context.Background(), time.Minute), time.Hour)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > time.Minute {
+		t.Errorf("failed to set the deadline. deadline: %v", d)
+	}
+	ctx, cancel = withStatementDeadline(context.Background(), time.Minute)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > time.Minute {
+		t.Errorf("failed to set the deadline of the query timeout. deadline: %v", d)
+	}
+	ctx, cancel = withStatementDeadline(WithStatementTimeout(context.Background(), 0), time.Minute)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("should have disabled the query timeout")
+	}
+}
@@ -12,9 +12,12 @@ type snowflakeTx struct {
 }
 
 func (tx *snowflakeTx) Commit() (err error) {
-	if tx.sc == nil || tx.sc.rest == nil {
+	if tx.sc == nil {
 		return driver.ErrBadConn
 	}
+	if err = tx.sc.checkConn(); err != nil {
+		return err
+	}
 	_, err = tx.sc.exec(context.TODO(), "COMMIT", false, false, nil)
 	if err != nil {
 		return
@@ -24,9 +27,12 @@ func (tx *snowflakeTx) Commit() (err error) {
 }
 
 func (tx *snowflakeTx) Rollback() (err error) {
-	if tx.sc == nil || tx.sc.rest == nil {
+	if tx.sc == nil {
 		return driver.ErrBadConn
 	}
+	if err = tx.sc.checkConn(); err != nil {
+		return err
+	}
 	_, err = tx.sc.exec(context.TODO(), "ROLLBACK", false, false, nil)
 	if err != nil {
 		return
@@ -0,0 +1,273 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+// Package unload exports the result of a query, or a table, to local CSV or Parquet files. The rows are unloaded
+// by COPY INTO <location> to a stage, the files are downloaded by GET in parallel, retrying the files failed, and
+// the files are removed from the stage.
+//
+//	result, err := unload.Query(ctx, db, "SELECT * FROM orders WHERE ordered_at >= '2018-01-01'", "/tmp/orders",
+//		&unload.Options{Format: unload.FormatParquet})
+//	if err != nil {
+//		return err
+//	}
+//	for _, f := range result.Files {
+//		log.Printf("%v: %v rows", f.Path, f.Rows)
+//	}
+//
+// Unless Options.Stage is given, the files are unloaded to a temporary stage, which exists only in the session.
+// So, given a *sql.DB, the export runs on a connection taken from the pool for the export.
+package unload
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// Format is the type of the files.
+type Format string
+
+// Formats of the files.
+const (
+	FormatCSV     Format = "CSV"
+	FormatParquet Format = "PARQUET"
+)
+
+// defaultRetries is the number of the retries of GET if Options.Retries is 0.
+const defaultRetries = 2
+
+// statusDownloaded is the status of a file downloaded by GET.
+const statusDownloaded = "DOWNLOADED"
+
+// Options are the options of an export.
+type Options struct {
+	Format      Format // CSV if empty
+	Compression string // COMPRESSION of the file format, e.g., NONE, GZIP or SNAPPY. AUTO if empty.
+	Header      bool   // write the column names in the first line of the CSV files
+	Single      bool   // unload to a single file instead of the files unloaded in parallel
+	MaxFileSize int64  // MAX_FILE_SIZE of COPY INTO in bytes. 16MB if 0.
+	Stage       string // stage, and optionally the path in it, to unload the files to. A temporary stage if empty.
+	Parallel    int    // PARALLEL of GET. The default of GET if 0.
+	Retries     int    // retries of GET of a file. 2 if 0. No retry if negative.
+}
+
+// File is a file exported.
+type File struct {
+	Path string // local path
+	Size int64  // size in the stage
+	Rows int64
+}
+
+// Result is the outcome of an export.
+type Result struct {
+	Files []File
+}
+
+// Rows returns the rows exported to all files.
+func (r *Result) Rows() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.Rows
+	}
+	return n
+}
+
+// Query exports the result of the query to the files in the local directory, which is created if missing. The
+// files of the same names in the directory are overwritten. opts may be nil.
+func Query(ctx context.Context, db sf.ExecQueryer, query string, dir string, opts *Options) (*Result, error) {
+	return export(ctx, db, "("+strings.TrimRight(strings.TrimSpace(query), ";")+")", dir, opts)
+}
+
+// Table exports the rows of the table to the files in the local directory, as Query does.
+func Table(ctx context.Context, db sf.ExecQueryer, table string, dir string, opts *Options) (*Result, error) {
+	return export(ctx, db, table, dir, opts)
+}
+
+// conner is implemented by *sql.DB.
+type conner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+func export(ctx context.Context, db sf.ExecQueryer, source string, dir string, opts *Options) (*Result, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == "" {
+		o.Format = FormatCSV
+	}
+	if o.Format != FormatCSV && o.Format != FormatParquet {
+		return nil, fmt.Errorf("invalid format: %v. must be CSV or PARQUET", o.Format)
+	}
+	if o.Retries == 0 {
+		o.Retries = defaultRetries
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if c, ok := db.(conner); ok {
+		conn, err := c.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		db = conn
+	}
+
+	id := "unload_" + strings.Replace(uuid.New().String(), "-", "", -1)
+	var location string
+	if stage := strings.TrimPrefix(o.Stage, "@"); stage != "" {
+		location = "@" + strings.TrimSuffix(stage, "/") + "/" + id + "/"
+		// even if the export failed or was canceled
+		defer db.ExecContext(context.Background(), "REMOVE "+location)
+	} else {
+		if _, err = db.ExecContext(ctx, "CREATE TEMPORARY STAGE "+id); err != nil {
+			return nil, err
+		}
+		location = "@" + id + "/"
+		// the stage is dropped at the end of the session anyway if this fails
+		defer db.ExecContext(context.Background(), "DROP STAGE IF EXISTS "+id)
+	}
+
+	result, err := unload(ctx, db, source, location, &o)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]bool, len(result.Files))
+	for i, f := range result.Files {
+		pending[f.Path] = true
+		result.Files[i].Path = filepath.Join(dir, f.Path)
+	}
+	if err = download(ctx, db, location, dir, &o, pending); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// unload runs COPY INTO the location, and returns the files unloaded with the names in the location as the paths.
+func unload(ctx context.Context, db sf.ExecQueryer, source, location string, o *Options) (*Result, error) {
+	stmt := "COPY INTO " + location + " FROM " + source + " FILE_FORMAT = (TYPE = " + string(o.Format)
+	if o.Compression != "" {
+		stmt += " COMPRESSION = " + o.Compression
+	}
+	stmt += ")"
+	if o.Header || o.Format == FormatParquet {
+		// the columns of Parquet are named _COL_0, _COL_1, ... without the header
+		stmt += " HEADER = TRUE"
+	}
+	if o.Single {
+		stmt += " SINGLE = TRUE"
+	}
+	if o.MaxFileSize > 0 {
+		stmt += " MAX_FILE_SIZE = " + strconv.FormatInt(o.MaxFileSize, 10)
+	}
+	stmt += " DETAILED_OUTPUT = TRUE"
+	rows, err := query(ctx, db, stmt)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for _, row := range rows {
+		f := File{Path: path.Base(row["file_name"])}
+		if f.Size, err = parseInt(row, "file_size"); err != nil {
+			return nil, err
+		}
+		if f.Rows, err = parseInt(row, "row_count"); err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, f)
+	}
+	return result, nil
+}
+
+// download downloads the files in the location to the directory by GET, and retries the files failed one by one.
+func download(ctx context.Context, db sf.ExecQueryer, location, dir string, o *Options, pending map[string]bool) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	get := func(src string) error {
+		stmt := "GET " + src + " 'file://" + filepath.ToSlash(dir) + "/'"
+		if o.Parallel > 0 {
+			stmt += " PARALLEL = " + strconv.Itoa(o.Parallel)
+		}
+		rows, err := query(ctx, db, stmt)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if row["status"] == statusDownloaded {
+				delete(pending, path.Base(row["file"]))
+			}
+		}
+		return nil
+	}
+	err := get(location)
+	for retry := 0; retry < o.Retries && len(pending) > 0; retry++ {
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
+		for name := range pending {
+			err = get(location + name)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if err == nil {
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		err = fmt.Errorf("failed to download %v", strings.Join(names, ", "))
+	}
+	return err
+}
+
+// query runs the query, and returns the rows by lower case column names.
+func query(ctx context.Context, db sf.ExecQueryer, stmt string) ([]map[string]string, error) {
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var ret []map[string]string
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, c := range cols {
+			row[strings.ToLower(c)] = values[i].String
+		}
+		ret = append(ret, row)
+	}
+	return ret, rows.Err()
+}
+
+func parseInt(row map[string]string, col string) (int64, error) {
+	n, err := strconv.ParseInt(row[col], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse column %v of the COPY INTO result. value: %v, err: %v", col, row[col], err)
+	}
+	return n, nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package unload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func copyResult(files ...[3]string) *sfmock.Rows {
+	rows := sfmock.NewRows(
+		sfmock.Column{Name: "FILE_NAME", Type: "TEXT"},
+		sfmock.Column{Name: "FILE_SIZE", Type: "FIXED"},
+		sfmock.Column{Name: "ROW_COUNT", Type: "FIXED"})
+	for _, f := range files {
+		rows.AddRow(f[0], f[1], f[2])
+	}
+	return rows
+}
+
+func TestQuery(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	dir, err := ioutil.TempDir("", "unload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	local := regexp.QuoteMeta("'file://" + filepath.ToSlash(filepath.Join(dir, "out")) + "/'")
+	mock.ExpectExec(`^CREATE TEMPORARY STAGE unload_[0-9a-f]{32}$`)
+	mock.ExpectQuery(`^COPY INTO @unload_[0-9a-f]{32}/ FROM \(SELECT \* FROM orders\) ` +
+		`FILE_FORMAT = \(TYPE = PARQUET COMPRESSION = SNAPPY\) HEADER = TRUE MAX_FILE_SIZE = 1024 DETAILED_OUTPUT = TRUE$`).
+		WillReturnRows(copyResult(
+			[3]string{"data_0_0_0.snappy.parquet", "900", "10"},
+			[3]string{"data_0_0_1.snappy.parquet", "300", "3"}))
+	mock.ExpectQuery(`^GET @unload_[0-9a-f]{32}/ ` + local + ` PARALLEL = 4$`).WillReturnRows(sfmock.GetResult(
+		sfmock.GetFile{File: "data_0_0_0.snappy.parquet", Size: 900, Status: statusDownloaded},
+		sfmock.GetFile{File: "data_0_0_1.snappy.parquet", Status: "ERROR", Message: "connection reset"}))
+	mock.ExpectQuery(`^GET @unload_[0-9a-f]{32}/data_0_0_1\.snappy\.parquet ` + local + ` PARALLEL = 4$`).
+		WillReturnRows(sfmock.GetResult(
+			sfmock.GetFile{File: "data_0_0_1.snappy.parquet", Size: 300, Status: statusDownloaded}))
+	mock.ExpectExec(`^DROP STAGE IF EXISTS unload_[0-9a-f]{32}$`)
+
+	result, err := Query(context.Background(), db, "SELECT * FROM orders;", filepath.Join(dir, "out"),
+		&Options{Format: FormatParquet, Compression: "SNAPPY", MaxFileSize: 1024, Parallel: 4})
+	if err != nil {
+		t.Fatalf("failed to export. err: %v", err)
+	}
+	if len(result.Files) != 2 || result.Rows() != 13 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if f := result.Files[1]; f.Path != filepath.Join(dir, "out", "data_0_0_1.snappy.parquet") || f.Size != 300 || f.Rows != 3 {
+		t.Errorf("unexpected file: %v", f)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTable(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	dir, err := ioutil.TempDir("", "unload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mock.ExpectQuery(`^COPY INTO @s/path/unload_[0-9a-f]{32}/ FROM orders FILE_FORMAT = \(TYPE = CSV\) ` +
+		`HEADER = TRUE SINGLE = TRUE DETAILED_OUTPUT = TRUE$`).
+		WillReturnRows(copyResult([3]string{"data_0_0_0.csv.gz", "100", "5"}))
+	mock.ExpectQuery(`^GET @s/path/unload_[0-9a-f]{32}/ 'file://`).WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery(`^GET @s/path/unload_[0-9a-f]{32}/data_0_0_0\.csv\.gz 'file://`).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec(`^REMOVE @s/path/unload_[0-9a-f]{32}/$`)
+
+	if _, err = Table(context.Background(), db, "orders", dir,
+		&Options{Header: true, Single: true, Stage: "@s/path/", Retries: 1}); err == nil ||
+		!strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("should have failed to download. err: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = Table(context.Background(), db, "orders", dir, &Options{Format: "JSON"}); err == nil {
+		t.Error("should have failed with the invalid format")
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+)
+
+// SnowflakeConn is the interface of the connections of the driver beyond database/sql/driver. With Go 1.13 or
+// later, the connection is available in sql.Conn.Raw:
+//
+//	err = conn.Raw(func(dc interface{}) error {
+//		return dc.(sf.SnowflakeConn).Use(ctx, sf.UseOptions{Database: "TENANT_42", Schema: "APP"})
+//	})
+type SnowflakeConn interface {
+	driver.Conn
+	driver.ExecerContext
+	driver.QueryerContext
+	// Use switches the session context. See UseOptions.
+	Use(ctx context.Context, opts UseOptions) error
+	// QueryID returns the ID of the last query run by the connection.
+	QueryID() string
+	// SessionInfo returns the session of the connection, including the current role, warehouse, database and
+	// schema.
+	SessionInfo() SessionInfo
+	// SessionParameter returns the value of the session parameter, case-insensitively, as last returned by the
+	// server or set by SetSessionParameter.
+	SessionParameter(name string) (string, bool)
+	// SessionParameters returns the session parameters known to the connection by upper case names.
+	SessionParameters() map[string]string
+	// SetSessionParameter sets the session parameter with ALTER SESSION SET.
+	SetSessionParameter(ctx context.Context, name string, value interface{}) error
+	// UnsetSessionParameter resets the session parameter to the default with ALTER SESSION UNSET.
+	UnsetSessionParameter(ctx context.Context, name string) error
+	// FetchResultByID returns the rows of the persisted result of a query run before without running it again.
+	FetchResultByID(ctx context.Context, queryID string) (driver.Rows, error)
+	// ExecBatch executes the independent statements of the batch in the session in parallel.
+	ExecBatch(ctx context.Context, b *Batch) []BatchResult
+}
+
+// UseOptions is the session context to switch to with SnowflakeConn.Use. Empty fields are left unchanged. Each
+// field is a single identifier, unquoted or double-quoted. Unquoted identifiers are resolved to upper case as
+// Snowflake does.
+type UseOptions struct {
+	Database  string
+	Schema    string
+	Warehouse string
+	Role      string
+}
+
+// useStep is a USE statement of Use.
+type useStep struct {
+	kind     string  // ROLE, WAREHOUSE, DATABASE or SCHEMA
+	name     string  // identifier given to Use
+	resolved string  // name resolved by Snowflake
+	field    *string // field of Config updated by the response
+}
+
+// Use switches the session context with the minimal USE statements, in the order of the role, warehouse,
+// database and schema, and verifies the context in the responses. The identifiers are validated, so they can't
+// inject SQL. If a statement fails, the fields already switched are switched back on a best-effort basis.
+func (sc *snowflakeConn) Use(ctx context.Context, opts UseOptions) error {
+	if err := sc.checkConn(); err != nil {
+		return err
+	}
+	ignoreCase := sc.isQuotedIdentifiersIgnoreCase()
+	sc.mu.Lock()
+	candidates := []useStep{
+		{kind: "ROLE", name: opts.Role, field: &sc.cfg.Role},
+		{kind: "WAREHOUSE", name: opts.Warehouse, field: &sc.cfg.Warehouse},
+		{kind: "DATABASE", name: opts.Database, field: &sc.cfg.Database},
+		{kind: "SCHEMA", name: opts.Schema, field: &sc.cfg.Schema},
+	}
+	var steps []useStep
+	var previous []string
+	for _, s := range candidates {
+		if s.name == "" {
+			continue
+		}
+		resolved, ok := resolveIdentifier(s.name, ignoreCase)
+		if !ok {
+			sc.mu.Unlock()
+			return &SnowflakeError{
+				Number:      ErrInvalidIdentifier,
+				SQLState:    SQLStateSyntaxError,
+				Message:     errMsgInvalidIdentifier,
+				MessageArgs: []interface{}{s.name},
+			}
+		}
+		if resolved == *s.field && (s.kind != "SCHEMA" || len(steps) == 0 || steps[len(steps)-1].kind != "DATABASE") {
+			// already in the context. USE DATABASE resets the schema, so the schema is switched again after it.
+			continue
+		}
+		s.resolved = resolved
+		steps = append(steps, s)
+		previous = append(previous, *s.field)
+	}
+	sc.mu.Unlock()
+
+	for i, s := range steps {
+		if err := sc.useStep(ctx, s); err != nil {
+			for j := 0; j < i; j++ {
+				if previous[j] == "" {
+					continue
+				}
+				if _, e := sc.exec(ctx, "USE "+steps[j].kind+" "+quoteIdentifier(previous[j]), false, true, nil); e != nil {
+					glog.V(1).Infof("failed to switch back the %v to %v. err: %v", steps[j].kind, previous[j], e)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// useStep runs the USE statement and verifies the context in the response.
+func (sc *snowflakeConn) useStep(ctx context.Context, s useStep) error {
+	if _, err := sc.exec(ctx, "USE "+s.kind+" "+s.name, false, true, nil); err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	got := *s.field
+	sc.mu.Unlock()
+	if got != s.resolved {
+		return &SnowflakeError{
+			Number:      ErrSessionContextMismatch,
+			SQLState:    SQLStateGeneralError,
+			Message:     errMsgSessionContextMismatch,
+			MessageArgs: []interface{}{strings.ToLower(s.kind), s.resolved, got},
+		}
+	}
+	return nil
+}
+
+// resolveIdentifier returns the name of the identifier resolved by Snowflake. It returns false if the
+// identifier is not a single unquoted or double-quoted identifier.
+func resolveIdentifier(id string, ignoreCase bool) (string, bool) {
+	if len(id) >= 2 && id[0] == '"' && id[len(id)-1] == '"' {
+		inner := id[1 : len(id)-1]
+		if inner == "" || strings.Count(inner, `"`) != 2*strings.Count(inner, `""`) {
+			return "", false
+		}
+		name := unquoteIdentifier(id)
+		if ignoreCase {
+			name = strings.ToUpper(name)
+		}
+		return name, true
+	}
+	name := strings.ToUpper(id)
+	if !isUpperCaseIdentifier(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// quoteIdentifier returns the double-quoted identifier of the name.
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
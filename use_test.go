@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ SnowflakeConn = &snowflakeConn{}
+
+func TestUse(t *testing.T) {
+	testcases := []struct {
+		opts UseOptions
+		sent []string
+	}{
+		{opts: UseOptions{Database: "db", Schema: "public"}, sent: nil},
+		{opts: UseOptions{Database: "tenant_1"}, sent: []string{"USE DATABASE tenant_1"}},
+		{opts: UseOptions{Database: "tenant_1", Schema: "PUBLIC"}, sent: []string{"USE DATABASE tenant_1", "USE SCHEMA PUBLIC"}},
+		{opts: UseOptions{Role: "ANALYST", Schema: `"My Schema"`}, sent: []string{"USE ROLE ANALYST", `USE SCHEMA "My Schema"`}},
+	}
+	for _, test := range testcases {
+		sc, s := fakeSessionConn()
+		if err := sc.Use(context.Background(), test.opts); err != nil {
+			t.Errorf("failed to switch the context. opts: %v, err: %v", test.opts, err)
+			continue
+		}
+		if strings.Join(s.sent, ";") != strings.Join(test.sent, ";") {
+			t.Errorf("failed to run the minimal statements. opts: %v, expected: %v, got: %v", test.opts, test.sent, s.sent)
+		}
+	}
+
+	sc, _ := fakeSessionConn()
+	if err := sc.Use(context.Background(), UseOptions{Database: "tenant_1", Schema: `"My Schema"`}); err != nil {
+		t.Fatalf("failed to switch the context. err: %v", err)
+	}
+	if sc.cfg.Database != "TENANT_1" || sc.cfg.Schema != "My Schema" {
+		t.Errorf("failed to update the config. database: %v, schema: %v", sc.cfg.Database, sc.cfg.Schema)
+	}
+}
+
+func TestUseFailure(t *testing.T) {
+	sc, s := fakeSessionConn()
+	s.fail = "USE SCHEMA missing"
+	err := sc.Use(context.Background(), UseOptions{Role: "ANALYST", Database: "tenant_1", Schema: "missing"})
+	if se, ok := err.(*SnowflakeError); !ok || se.Number != 2043 {
+		t.Fatalf("should have failed to switch the schema. err: %v", err)
+	}
+	expected := []string{
+		"USE ROLE ANALYST", "USE DATABASE tenant_1", "USE SCHEMA missing", `USE ROLE "PUBLIC"`, `USE DATABASE "DB"`,
+	}
+	if strings.Join(s.sent, ";") != strings.Join(expected, ";") {
+		t.Errorf("failed to switch back the context. expected: %v, got: %v", expected, s.sent)
+	}
+	if sc.cfg.Role != "PUBLIC" || sc.cfg.Database != "DB" {
+		t.Errorf("failed to restore the config. role: %v, database: %v", sc.cfg.Role, sc.cfg.Database)
+	}
+
+	for _, name := range []string{"db; DROP TABLE t", `"a"b"`, `""`, "1db", "a.b"} {
+		sc, s = fakeSessionConn()
+		err = sc.Use(context.Background(), UseOptions{Database: name})
+		if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrInvalidIdentifier || len(s.sent) != 0 {
+			t.Errorf("should have rejected the identifier. name: %v, err: %v", name, err)
+		}
+	}
+}
+
+func TestUseContextMismatch(t *testing.T) {
+	sc, _ := fakeSessionConn()
+	sc.rest.FuncPostQuery = func(context.Context, *snowflakeRestful, *url.Values, map[string]string, []byte, time.Duration) (*execResponse, error) {
+		resp := &execResponse{Success: true}
+		resp.Data.FinalDatabaseName = "OTHER"
+		return resp, nil
+	}
+	err := sc.Use(context.Background(), UseOptions{Database: "tenant_1"})
+	if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrSessionContextMismatch {
+		t.Errorf("should have failed to verify the context. err: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Policy is the handling of invalid UTF-8 byte sequences in the string values of result sets.
+type InvalidUTF8Policy string
+
+const (
+	// InvalidUTF8PassThrough returns the string values as is. This is the default.
+	InvalidUTF8PassThrough InvalidUTF8Policy = "passthrough"
+	// InvalidUTF8Replace replaces each invalid byte sequence with the Unicode replacement character U+FFFD.
+	InvalidUTF8Replace InvalidUTF8Policy = "replace"
+	// InvalidUTF8Error fails Rows.Next with ErrInvalidUTF8.
+	InvalidUTF8Error InvalidUTF8Policy = "error"
+)
+
+func parseInvalidUTF8Policy(s string) (InvalidUTF8Policy, error) {
+	switch p := InvalidUTF8Policy(s); p {
+	case InvalidUTF8PassThrough, InvalidUTF8Replace, InvalidUTF8Error:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid invalidUTF8 policy: %v. must be passthrough, replace or error", s)
+}
+
+// applyInvalidUTF8Policy checks a string value of the column against the policy.
+func applyInvalidUTF8Policy(policy InvalidUTF8Policy, column string, s string) (string, error) {
+	if policy == "" || policy == InvalidUTF8PassThrough || utf8.ValidString(s) {
+		return s, nil
+	}
+	if policy == InvalidUTF8Error {
+		return "", &SnowflakeError{
+			Number:      ErrInvalidUTF8,
+			Message:     errMsgInvalidUTF8,
+			MessageArgs: []interface{}{column},
+		}
+	}
+	return toValidUTF8(s), nil
+}
+
+// toValidUTF8 replaces each run of invalid UTF-8 bytes with U+FFFD.
+func toValidUTF8(s string) string {
+	var buf bytes.Buffer
+	invalid := false
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if !invalid {
+				buf.WriteRune(utf8.RuneError)
+				invalid = true
+			}
+			i++
+			continue
+		}
+		invalid = false
+		buf.WriteString(s[i : i+size])
+		i += size
+	}
+	return buf.String()
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestApplyInvalidUTF8Policy(t *testing.T) {
+	testcases := []struct {
+		policy InvalidUTF8Policy
+		in     string
+		out    string
+		err    bool
+	}{
+		{policy: "", in: "a\xffb", out: "a\xffb"},
+		{policy: InvalidUTF8PassThrough, in: "a\xffb", out: "a\xffb"},
+		{policy: InvalidUTF8Replace, in: "a\xff\xfeb\xc3", out: "a�b�"},
+		{policy: InvalidUTF8Replace, in: "日本語", out: "日本語"},
+		{policy: InvalidUTF8Error, in: "日本語", out: "日本語"},
+		{policy: InvalidUTF8Error, in: "a\xffb", err: true},
+	}
+	for _, test := range testcases {
+		out, err := applyInvalidUTF8Policy(test.policy, "C1", test.in)
+		if test.err {
+			if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidUTF8 {
+				t.Errorf("should have failed with ErrInvalidUTF8. in: %q, err: %v", test.in, err)
+			}
+			continue
+		}
+		if err != nil || out != test.out {
+			t.Errorf("failed to apply %v. in: %q, expected: %q, got: %q, err: %v", test.policy, test.in, test.out, out, err)
+		}
+	}
+}
+
+func TestRowsInvalidUTF8(t *testing.T) {
+	v := "a\xffb"
+	rows := &snowflakeRows{
+		RowType:     []execResponseRowType{{Name: "C1", Type: "text"}},
+		invalidUTF8: InvalidUTF8Replace,
+		ChunkDownloader: &snowflakeChunkDownloader{
+			CurrentChunk:  jsonChunk([][]*string{{&v}}),
+			Total:         1,
+			TotalRowIndex: -1,
+		},
+	}
+	rows.ChunkDownloader.start()
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		t.Fatalf("failed to get the row. err: %v", err)
+	}
+	if dest[0] != "a�b" {
+		t.Errorf("failed to replace invalid UTF-8. got: %q", dest[0])
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Variant is a VARIANT, OBJECT or ARRAY column value decoded when scanned. Numbers are decoded as json.Number,
+// so that large integers in the semi-structured data are not rounded to float64. For example:
+//
+//	var v sf.Variant
+//	err := db.QueryRow("SELECT PARSE_JSON('{\"id\": 12345678901234567890}')").Scan(&v)
+//	id := v.Value.(map[string]interface{})["id"].(json.Number).String()
+//
+// Variant is also bound as the JSON of Value, or NULL if not Valid. The placeholder is replaced with
+// PARSE_JSON(?), and INSERT ... VALUES is run as INSERT ... SELECT ... FROM VALUES since Snowflake doesn't accept
+// PARSE_JSON in VALUES. json.RawMessage, structs and maps are bound as JSON in the same way:
+//
+//	_, err = db.Exec("INSERT INTO orders (id, doc) VALUES (?, ?)", 1, order)
+type Variant struct {
+	Value interface{} // nil, bool, json.Number, string, []interface{} or map[string]interface{}
+	Valid bool        // Valid is true if the value is not SQL NULL
+}
+
+// Scan implements the sql.Scanner interface.
+func (v *Variant) Scan(src interface{}) error {
+	v.Value = nil
+	v.Valid = false
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(s)
+	case []byte:
+		data = s
+	default:
+		return fmt.Errorf("unsupported type for Variant: %T", src)
+	}
+	if err := UnmarshalVariant(data, &v.Value); err != nil {
+		return err
+	}
+	v.Valid = true
+	return nil
+}
+
+// UnmarshalVariant decodes a VARIANT, OBJECT or ARRAY value into v like json.Unmarshal, except that the numbers
+// decoded into interface{} values are json.Number instead of float64.
+func UnmarshalVariant(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// isVariant returns true if the value is bound as JSON, i.e., Variant, json.RawMessage, structs other than
+// time.Time and maps. The values implementing driver.Valuer are not.
+func isVariant(v interface{}) bool {
+	switch v.(type) {
+	case Variant, *Variant, json.RawMessage:
+		return true
+	case driver.Valuer, time.Time, typedTime, spatialText:
+		return false
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// variantJSON returns the JSON text of the value bound as JSON, or nil for NULL. json.RawMessage is bound as is,
+// and the others are encoded by encoding/json.
+func variantJSON(v interface{}) (*string, error) {
+	switch t := v.(type) {
+	case *Variant:
+		if t == nil {
+			return nil, nil
+		}
+		return variantJSON(*t)
+	case Variant:
+		if !t.Valid {
+			return nil, nil
+		}
+		v = t.Value
+	case json.RawMessage:
+		if t == nil {
+			return nil, nil
+		}
+		s := string(t)
+		return &s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, invalidVariantError(err)
+	}
+	s := string(b)
+	return &s, nil
+}
+
+func invalidVariantError(reason interface{}) error {
+	return &SnowflakeError{
+		Number:      ErrInvalidVariant,
+		Message:     errMsgInvalidVariant,
+		MessageArgs: []interface{}{reason},
+	}
+}
+
+// bindFunction returns the function converting the text bound for the value, PARSE_JSON for the values bound as
+// JSON and TO_GEOGRAPHY or TO_GEOMETRY for the spatial values, or an empty string if the value is bound as is.
+func bindFunction(v interface{}) string {
+	if st, ok := v.(spatialText); ok {
+		return st.function
+	}
+	if isVariant(v) {
+		return "PARSE_JSON"
+	}
+	return ""
+}
+
+// bindConvertedValues replaces the values converted by functions with their texts, and their placeholders with
+// the function calls, e.g., PARSE_JSON(?). The query and parameters are returned as is if no value is converted.
+func bindConvertedValues(query string, parameters []driver.NamedValue) (string, []driver.NamedValue, error) {
+	found := false
+	for _, p := range parameters {
+		if bindFunction(p.Value) != "" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return query, parameters, nil
+	}
+	bound := make([]driver.NamedValue, len(parameters))
+	copy(bound, parameters)
+	// the DataType flags don't have placeholders
+	var functions []string
+	for i, p := range bound {
+		if goTypeToSnowflake(p.Value, "") == "CHANGE_TYPE" {
+			continue
+		}
+		function := bindFunction(p.Value)
+		switch {
+		case function == "":
+		case function == "PARSE_JSON":
+			s, err := variantJSON(p.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			if s == nil {
+				bound[i].Value = nil
+			} else {
+				bound[i].Value = *s
+			}
+		default:
+			bound[i].Value = p.Value.(spatialText).text
+		}
+		functions = append(functions, function)
+	}
+	code := sqlCodeMask(query)
+	var placeholders []int
+	for i := 0; i < len(query); i++ {
+		if code[i] && query[i] == '?' {
+			placeholders = append(placeholders, i)
+		}
+	}
+	if len(placeholders) != len(functions) {
+		return "", nil, invalidVariantError(fmt.Sprintf("%v placeholders for %v parameters", len(placeholders), len(functions)))
+	}
+	if q, ok, err := insertSelectConverted(query, code, placeholders, functions); ok || err != nil {
+		return q, bound, err
+	}
+	var out bytes.Buffer
+	k := 0
+	for i := 0; i < len(query); i++ {
+		if k < len(placeholders) && i == placeholders[k] {
+			if functions[k] != "" {
+				out.WriteString(functions[k] + "(?)")
+			} else {
+				out.WriteByte('?')
+			}
+			k++
+			continue
+		}
+		out.WriteByte(query[i])
+	}
+	return out.String(), bound, nil
+}
+
+// insertSelectConverted rewrites INSERT ... VALUES (...), ... to INSERT ... SELECT ..., PARSE_JSON(columnN) FROM
+// VALUES (...), ... if the query is an INSERT with VALUES, where the columns of the converted values are passed to
+// the functions. It returns false if the query is not an INSERT with VALUES.
+func insertSelectConverted(query string, code []bool, placeholders []int, functions []string) (string, bool, error) {
+	if !hasKeywordAt(query, code, skipSpaces(query, 0), "INSERT") {
+		return "", false, nil
+	}
+	// VALUES at the top level followed by the rows
+	values := -1
+	depth := 0
+	for i := 0; i < len(query) && values < 0; i++ {
+		switch {
+		case !code[i]:
+		case query[i] == '(':
+			depth++
+		case query[i] == ')':
+			depth--
+		case depth == 0 && hasKeywordAt(query, code, i, "VALUES"):
+			values = i
+		}
+	}
+	if values < 0 {
+		return "", false, nil
+	}
+	// the column of each placeholder in the rows
+	columns := make(map[int]int) // index of the query -> column
+	nColumns := 0
+	column := 0
+	depth = 0
+	i := values + len("VALUES")
+	for ; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case !code[i]:
+		case c == '(':
+			depth++
+			if depth == 1 {
+				column = 1
+			}
+		case c == ')':
+			depth--
+			if depth == 0 && column > nColumns {
+				nColumns = column
+			}
+		case c == ',' && depth == 1:
+			column++
+		case c == '?' && depth >= 1:
+			columns[i] = column
+		case depth == 0 && c != ',' && c != ';' && c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			// not a plain VALUES list, e.g., followed by another clause
+			return "", false, nil
+		}
+	}
+	converted := make(map[int]string)
+	for k, p := range placeholders {
+		if col, ok := columns[p]; ok && functions[k] != "" {
+			converted[col] = functions[k]
+		}
+	}
+	for k, p := range placeholders {
+		if col, ok := columns[p]; ok && converted[col] != "" && functions[k] != converted[col] {
+			return "", false, invalidVariantError(fmt.Sprintf("column %v mixes values bound by %v with other values", col, converted[col]))
+		}
+	}
+	exprs := make([]string, nColumns)
+	for col := 1; col <= nColumns; col++ {
+		exprs[col-1] = fmt.Sprintf("column%v", col)
+		if converted[col] != "" {
+			exprs[col-1] = fmt.Sprintf("%v(column%v)", converted[col], col)
+		}
+	}
+	return query[:values] + "SELECT " + strings.Join(exprs, ", ") + " FROM " + query[values:], true, nil
+}
+
+// hasKeywordAt returns true if the keyword starts at i of the query as SQL code.
+func hasKeywordAt(query string, code []bool, i int, keyword string) bool {
+	if i < 0 || i+len(keyword) > len(query) || !code[i] || !strings.EqualFold(query[i:i+len(keyword)], keyword) {
+		return false
+	}
+	if i > 0 && isIdentifierChar(query[i-1]) {
+		return false
+	}
+	return i+len(keyword) == len(query) || !isIdentifierChar(query[i+len(keyword)])
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// ScanVariant returns the sql.Scanner decoding a VARIANT, OBJECT or ARRAY column into v, e.g., a pointer to a
+// struct, by UnmarshalVariant. NULL is decoded as JSON null. For example:
+//
+//	var order Order
+//	err := db.QueryRow("SELECT doc FROM orders WHERE id = ?", 1).Scan(sf.ScanVariant(&order))
+func ScanVariant(v interface{}) sql.Scanner {
+	return &variantScanner{v: v}
+}
+
+type variantScanner struct {
+	v interface{}
+}
+
+func (s *variantScanner) Scan(src interface{}) error {
+	switch t := src.(type) {
+	case nil:
+		return UnmarshalVariant([]byte("null"), s.v)
+	case string:
+		return UnmarshalVariant([]byte(t), s.v)
+	case []byte:
+		return UnmarshalVariant(t, s.v)
+	}
+	return fmt.Errorf("unsupported type for ScanVariant: %T", src)
+}
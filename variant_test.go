@@ -0,0 +1,218 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVariantScan(t *testing.T) {
+	var v Variant
+	if err := v.Scan(`{"id": 12345678901234567890, "tags": ["a", 1.5]}`); err != nil {
+		t.Fatalf("failed to scan. err: %v", err)
+	}
+	m, ok := v.Value.(map[string]interface{})
+	if !v.Valid || !ok {
+		t.Fatalf("failed to decode an object. value: %v", v.Value)
+	}
+	if id, ok := m["id"].(json.Number); !ok || id.String() != "12345678901234567890" {
+		t.Errorf("failed to keep the large integer. id: %v", m["id"])
+	}
+	if tags, ok := m["tags"].([]interface{}); !ok || tags[1] != json.Number("1.5") {
+		t.Errorf("failed to decode the array. tags: %v", m["tags"])
+	}
+	if err := v.Scan(nil); err != nil || v.Valid || v.Value != nil {
+		t.Errorf("failed to scan NULL. value: %v, err: %v", v, err)
+	}
+	if err := v.Scan([]byte(`"text"`)); err != nil || v.Value != "text" {
+		t.Errorf("failed to scan bytes. value: %v, err: %v", v, err)
+	}
+	if err := v.Scan(int64(1)); err == nil {
+		t.Errorf("should have failed to scan int64")
+	}
+}
+
+func TestUnmarshalVariant(t *testing.T) {
+	testcases := []struct {
+		in  string
+		out interface{}
+		err bool
+	}{
+		{in: `9007199254740993`, out: json.Number("9007199254740993")},
+		{in: `[1, 2]`, out: []interface{}{json.Number("1"), json.Number("2")}},
+		{in: `null`, out: nil},
+		{in: `{"a": 1} x`, err: true},
+		{in: `{`, err: true},
+	}
+	for _, test := range testcases {
+		var v interface{}
+		err := UnmarshalVariant([]byte(test.in), &v)
+		if test.err {
+			if err == nil {
+				t.Errorf("should have failed. in: %v", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to unmarshal. in: %v, err: %v", test.in, err)
+			continue
+		}
+		expected, _ := json.Marshal(test.out)
+		got, _ := json.Marshal(v)
+		if string(expected) != string(got) {
+			t.Errorf("failed to unmarshal. in: %v, expected: %v, got: %v", test.in, test.out, v)
+		}
+		if n, ok := v.(json.Number); ok && n != test.out {
+			t.Errorf("failed to keep json.Number. expected: %v, got: %v", test.out, n)
+		}
+	}
+}
+
+func TestUnitBindVariants(t *testing.T) {
+	type order struct {
+		ID    int      `json:"id"`
+		Items []string `json:"items"`
+	}
+	doc := order{ID: 1, Items: []string{"a"}}
+	testcases := []struct {
+		query    string
+		params   []interface{}
+		outQuery string
+		outJSON  []interface{}
+	}{
+		{
+			query:    "INSERT INTO t (id, doc) VALUES (?, ?)",
+			params:   []interface{}{int64(1), doc},
+			outQuery: "INSERT INTO t (id, doc) SELECT column1, PARSE_JSON(column2) FROM VALUES (?, ?)",
+			outJSON:  []interface{}{int64(1), `{"id":1,"items":["a"]}`},
+		},
+		{
+			query:    "insert into t values (?, 'x', ?), (?, 'y', ?);",
+			params:   []interface{}{json.RawMessage(`[1]`), int64(1), Variant{Value: "s", Valid: true}, int64(2)},
+			outQuery: "insert into t SELECT PARSE_JSON(column1), column2, column3 FROM values (?, 'x', ?), (?, 'y', ?);",
+			outJSON:  []interface{}{`[1]`, int64(1), `"s"`, int64(2)},
+		},
+		{
+			query:    "INSERT INTO t VALUES (?, OBJECT_CONSTRUCT('a', ?)) /* ? */",
+			params:   []interface{}{map[string]int{"a": 1}, int64(2)},
+			outQuery: "INSERT INTO t SELECT PARSE_JSON(column1), column2 FROM VALUES (?, OBJECT_CONSTRUCT('a', ?)) /* ? */",
+			outJSON:  []interface{}{`{"a":1}`, int64(2)},
+		},
+		{
+			query:    "UPDATE t SET doc = ? WHERE id = ? AND note = '?'",
+			params:   []interface{}{&Variant{}, int64(1)},
+			outQuery: "UPDATE t SET doc = PARSE_JSON(?) WHERE id = ? AND note = '?'",
+			outJSON:  []interface{}{nil, int64(1)},
+		},
+		{
+			query:    "INSERT INTO t SELECT ?, ?",
+			params:   []interface{}{DataTypeTimestampTz, time.Unix(0, 0), json.RawMessage(`{}`)},
+			outQuery: "INSERT INTO t SELECT ?, PARSE_JSON(?)",
+			outJSON:  []interface{}{DataTypeTimestampTz, time.Unix(0, 0), `{}`},
+		},
+		{
+			query:    "SELECT ?",
+			params:   []interface{}{int64(1)},
+			outQuery: "SELECT ?",
+			outJSON:  []interface{}{int64(1)},
+		},
+	}
+	for _, test := range testcases {
+		params := make([]driver.NamedValue, len(test.params))
+		for i, v := range test.params {
+			params[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+		}
+		query, bound, err := bindConvertedValues(test.query, params)
+		if err != nil {
+			t.Errorf("failed to bind the variants. query: %v, err: %v", test.query, err)
+			continue
+		}
+		if query != test.outQuery {
+			t.Errorf("failed to rewrite the query. expected: %v, got: %v", test.outQuery, query)
+		}
+		for i, v := range test.outJSON {
+			if !reflect.DeepEqual(bound[i].Value, v) {
+				t.Errorf("failed to bind the value. query: %v, expected: %v, got: %v", test.query, v, bound[i].Value)
+			}
+		}
+		if !reflect.DeepEqual(params[len(params)-1].Value, test.params[len(params)-1]) {
+			t.Errorf("should not have changed the parameters")
+		}
+	}
+
+	for _, test := range []struct {
+		query  string
+		params []interface{}
+	}{
+		{query: "SELECT ?, ?", params: []interface{}{json.RawMessage(`{}`)}},
+		{query: "INSERT INTO t VALUES (?), (?)", params: []interface{}{json.RawMessage(`{}`), "text"}},
+		{query: "SELECT ?", params: []interface{}{map[string]interface{}{"f": func() {}}}},
+	} {
+		params := make([]driver.NamedValue, len(test.params))
+		for i, v := range test.params {
+			params[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+		}
+		_, _, err := bindConvertedValues(test.query, params)
+		if driverErr, ok := err.(*SnowflakeError); !ok || driverErr.Number != ErrInvalidVariant {
+			t.Errorf("should have failed to bind the variants. query: %v, err: %v", test.query, err)
+		}
+	}
+}
+
+func TestVariantExec(t *testing.T) {
+	var sent execRequest
+	sc := getDefaultSnowflakeConn()
+	sc.rest.FuncPostQuery = func(_ context.Context, _ *snowflakeRestful, _ *url.Values, _ map[string]string, body []byte, _ time.Duration) (*execResponse, error) {
+		if err := json.Unmarshal(body, &sent); err != nil {
+			return nil, err
+		}
+		return &execResponse{Success: true}, nil
+	}
+	doc := map[string]interface{}{"a": []int{1, 2}}
+	nv := driver.NamedValue{Name: "doc", Ordinal: 2, Value: doc}
+	if err := sc.CheckNamedValue(&nv); err != nil {
+		t.Fatalf("failed to accept the map. err: %v", err)
+	}
+	args := []driver.NamedValue{{Name: "id", Ordinal: 1, Value: int64(1)}, nv}
+	if _, err := sc.ExecContext(context.Background(), "INSERT INTO t VALUES (:id, :doc)", args); err != nil {
+		t.Fatalf("failed to exec. err: %v", err)
+	}
+	if sent.SQLText != "INSERT INTO t SELECT column1, PARSE_JSON(column2) FROM VALUES (?, ?)" {
+		t.Errorf("failed to rewrite the query. got: %v", sent.SQLText)
+	}
+	if b := sent.Bindings["2"]; b.Type != "TEXT" || b.Value != `{"a":[1,2]}` {
+		t.Errorf("failed to bind the JSON. got: %v", b)
+	}
+	for _, v := range []interface{}{time.Now(), sql.NullString{}, int64(1), "s"} {
+		if isVariant(v) {
+			t.Errorf("should not have bound %T as JSON", v)
+		}
+	}
+}
+
+func TestScanVariant(t *testing.T) {
+	var doc struct {
+		ID   json.Number `json:"id"`
+		Tags []string    `json:"tags"`
+	}
+	if err := ScanVariant(&doc).Scan(`{"id": 12345678901234567890, "tags": ["a"]}`); err != nil {
+		t.Fatalf("failed to scan. err: %v", err)
+	}
+	if doc.ID.String() != "12345678901234567890" || len(doc.Tags) != 1 {
+		t.Errorf("failed to decode the struct. got: %v", doc)
+	}
+	var p *struct{}
+	if err := ScanVariant(&p).Scan(nil); err != nil || p != nil {
+		t.Errorf("failed to scan NULL. err: %v", err)
+	}
+	if err := ScanVariant(&doc).Scan(int64(1)); err == nil {
+		t.Errorf("should have failed to scan int64")
+	}
+}
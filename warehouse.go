@@ -0,0 +1,200 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// WarehouseStateStarted is the state of a running warehouse.
+	WarehouseStateStarted = "STARTED"
+	// WarehouseStateSuspended is the state of a suspended warehouse.
+	WarehouseStateSuspended = "SUSPENDED"
+)
+
+// warehousePollInterval is the interval to check the warehouse state while waiting for a change.
+var warehousePollInterval = time.Second
+
+// Queryer is the interface satisfied by *sql.DB, *sql.Conn and *sql.Tx to run queries.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ExecQueryer is the interface satisfied by *sql.DB, *sql.Conn and *sql.Tx to execute statements and run queries.
+type ExecQueryer interface {
+	Execer
+	Queryer
+}
+
+// WarehouseStatus is the state and size of a warehouse returned by SHOW WAREHOUSES.
+type WarehouseStatus struct {
+	Name  string
+	State string // STARTED, SUSPENDED, RESIZING, etc.
+	Size  string // X-Small, Small, Medium, etc.
+}
+
+// GetWarehouseStatus returns the state and size of the warehouse. The name is a single identifier, unquoted or
+// double-quoted.
+func GetWarehouseStatus(ctx context.Context, db Queryer, name string) (*WarehouseStatus, error) {
+	resolved, err := resolveWarehouseName(name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SHOW WAREHOUSES LIKE %v", quoteSQLString(escapeLikePattern(resolved))))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		ws := &WarehouseStatus{}
+		for i, c := range cols {
+			switch strings.ToLower(c) {
+			case "name":
+				ws.Name = values[i].String
+			case "state":
+				ws.State = values[i].String
+			case "size":
+				ws.Size = values[i].String
+			}
+		}
+		// LIKE is case-insensitive, so only the warehouse of the exact name is returned
+		if ws.Name == resolved {
+			return ws, nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return nil, &SnowflakeError{
+		Number:      ErrCodeObjectNotExists,
+		Message:     errMsgObjectNotExists,
+		MessageArgs: []interface{}{name},
+	}
+}
+
+// ResumeWarehouse resumes the warehouse if suspended and waits until it is started or the context is done.
+func ResumeWarehouse(ctx context.Context, db ExecQueryer, name string) error {
+	if _, err := resolveWarehouseName(name); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER WAREHOUSE %v RESUME IF SUSPENDED", name)); err != nil {
+		return err
+	}
+	return waitForWarehouse(ctx, db, name, func(ws *WarehouseStatus) bool {
+		return ws.State == WarehouseStateStarted
+	})
+}
+
+// SuspendWarehouse suspends the warehouse if started and waits until it is suspended or the context is done.
+func SuspendWarehouse(ctx context.Context, db ExecQueryer, name string) error {
+	ws, err := GetWarehouseStatus(ctx, db, name)
+	if err != nil {
+		return err
+	}
+	if ws.State == WarehouseStateSuspended {
+		return nil
+	}
+	if _, err = db.ExecContext(ctx, fmt.Sprintf("ALTER WAREHOUSE %v SUSPEND", name)); err != nil {
+		return err
+	}
+	return waitForWarehouse(ctx, db, name, func(ws *WarehouseStatus) bool {
+		return ws.State == WarehouseStateSuspended
+	})
+}
+
+// AlterWarehouseSize changes the size of the warehouse, e.g., XSMALL, MEDIUM, X4LARGE, and waits until the
+// resize completes or the context is done. A suspended warehouse remains suspended.
+func AlterWarehouseSize(ctx context.Context, db ExecQueryer, name string, size string) error {
+	if _, err := resolveWarehouseName(name); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(
+		"ALTER WAREHOUSE %v SET WAREHOUSE_SIZE = %v WAIT_FOR_COMPLETION = TRUE", name, quoteSQLString(size))); err != nil {
+		return err
+	}
+	expected := normalizeWarehouseSize(size)
+	return waitForWarehouse(ctx, db, name, func(ws *WarehouseStatus) bool {
+		return normalizeWarehouseSize(ws.Size) == expected && ws.State != "RESIZING"
+	})
+}
+
+// waitForWarehouse polls the warehouse status until ready returns true.
+func waitForWarehouse(ctx context.Context, db Queryer, name string, ready func(*WarehouseStatus) bool) error {
+	for {
+		ws, err := GetWarehouseStatus(ctx, db, name)
+		if err != nil {
+			return err
+		}
+		if ready(ws) {
+			return nil
+		}
+		select {
+		case <-getClock().After(warehousePollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// normalizeWarehouseSize normalizes the size names, e.g., X-Small and XSMALL, 2X-Large and XXLARGE.
+func normalizeWarehouseSize(size string) string {
+	s := strings.ToUpper(strings.Replace(size, "-", "", -1))
+	switch s {
+	case "XXLARGE":
+		return "2XLARGE"
+	case "XXXLARGE":
+		return "3XLARGE"
+	}
+	if strings.HasPrefix(s, "X") && len(s) > 2 && s[1] >= '2' && s[1] <= '9' {
+		// X4LARGE
+		return s[1:2] + "X" + s[2:]
+	}
+	return s
+}
+
+// resolveWarehouseName returns the name of the warehouse resolved by Snowflake. The name is validated as a single
+// identifier, so it can't inject SQL.
+func resolveWarehouseName(name string) (string, error) {
+	resolved, ok := resolveIdentifier(name, false)
+	if !ok {
+		return "", &SnowflakeError{
+			Number:      ErrInvalidIdentifier,
+			SQLState:    SQLStateSyntaxError,
+			Message:     errMsgInvalidIdentifier,
+			MessageArgs: []interface{}{name},
+		}
+	}
+	return resolved, nil
+}
+
+// escapeLikePattern escapes the wildcards of LIKE, so the pattern matches the string only.
+func escapeLikePattern(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "_", `\_`, -1)
+	return strings.Replace(s, "%", `\%`, -1)
+}
+
+// unquoteIdentifier removes the double quotes of a quoted identifier.
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		return strings.Replace(name[1:len(name)-1], `""`, `"`, -1)
+	}
+	return name
+}
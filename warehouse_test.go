@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/sfmock"
+)
+
+func showWarehousesRows(state, size string) *sfmock.Rows {
+	return sfmock.NewRows(
+		sfmock.Column{Name: "name", Type: "TEXT"},
+		sfmock.Column{Name: "state", Type: "TEXT"},
+		sfmock.Column{Name: "type", Type: "TEXT"},
+		sfmock.Column{Name: "size", Type: "TEXT"}).
+		AddRow("WH", state, "STANDARD", size)
+}
+
+func TestWarehouseHelpers(t *testing.T) {
+	orgInterval := warehousePollInterval
+	warehousePollInterval = time.Millisecond
+	defer func() {
+		warehousePollInterval = orgInterval
+	}()
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	mock.ExpectExec("ALTER WAREHOUSE WH RESUME IF SUSPENDED").WillReturnResult(0)
+	mock.ExpectQuery("SHOW WAREHOUSES LIKE 'WH'").WillReturnRows(showWarehousesRows("RESUMING", "X-Small"))
+	mock.ExpectQuery("SHOW WAREHOUSES LIKE 'WH'").WillReturnRows(showWarehousesRows("STARTED", "X-Small"))
+	if err = ResumeWarehouse(ctx, db, "WH"); err != nil {
+		t.Fatalf("failed to resume the warehouse. err: %v", err)
+	}
+
+	mock.ExpectExec("ALTER WAREHOUSE WH SET WAREHOUSE_SIZE = 'X4LARGE'").WillReturnResult(0)
+	mock.ExpectQuery("SHOW WAREHOUSES").WillReturnRows(showWarehousesRows("STARTED", "4X-Large"))
+	if err = AlterWarehouseSize(ctx, db, "WH", "X4LARGE"); err != nil {
+		t.Fatalf("failed to resize the warehouse. err: %v", err)
+	}
+
+	mock.ExpectQuery("SHOW WAREHOUSES").WillReturnRows(showWarehousesRows("STARTED", "4X-Large"))
+	mock.ExpectExec("ALTER WAREHOUSE WH SUSPEND").WillReturnResult(0)
+	mock.ExpectQuery("SHOW WAREHOUSES").WillReturnRows(showWarehousesRows("SUSPENDED", "4X-Large"))
+	if err = SuspendWarehouse(ctx, db, "WH"); err != nil {
+		t.Fatalf("failed to suspend the warehouse. err: %v", err)
+	}
+
+	mock.ExpectQuery("SHOW WAREHOUSES LIKE 'NOWH'").WillReturnRows(sfmock.NewRows(sfmock.Column{Name: "name", Type: "TEXT"}))
+	_, err = GetWarehouseStatus(ctx, db, "NOWH")
+	if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrCodeObjectNotExists {
+		t.Errorf("should have failed with object not exists. err: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWarehouseName(t *testing.T) {
+	dsn, mock := sfmock.New()
+	db, err := sql.Open(sfmock.DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open db. err: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, name := range []string{"WH; DROP TABLE T", `"WH"" RESUME`, "DB.WH", ""} {
+		if err = ResumeWarehouse(ctx, db, name); err == nil {
+			t.Errorf("should have failed with the invalid identifier: %v", name)
+		} else if se, ok := err.(*SnowflakeError); !ok || se.Number != ErrInvalidIdentifier {
+			t.Errorf("should have failed with the invalid identifier: %v. err: %v", name, err)
+		}
+	}
+
+	// the wildcards are escaped, and the warehouse of the exact name is returned from the case-insensitive LIKE
+	mock.ExpectQuery(regexp.QuoteMeta(`SHOW WAREHOUSES LIKE 'my\\_wh'`)).WillReturnRows(sfmock.NewRows(
+		sfmock.Column{Name: "name", Type: "TEXT"},
+		sfmock.Column{Name: "state", Type: "TEXT"},
+		sfmock.Column{Name: "size", Type: "TEXT"}).
+		AddRow("MY_WH", "SUSPENDED", "Small").
+		AddRow("my_wh", "STARTED", "Large"))
+	ws, err := GetWarehouseStatus(ctx, db, `"my_wh"`)
+	if err != nil {
+		t.Fatalf("failed to get the warehouse status. err: %v", err)
+	}
+	if ws.Name != "my_wh" || ws.State != WarehouseStateStarted {
+		t.Errorf("failed to get the warehouse of the exact name: %+v", ws)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+type tcNormalizeWarehouseSize struct {
+	in  string
+	out string
+}
+
+func TestNormalizeWarehouseSize(t *testing.T) {
+	testcases := []tcNormalizeWarehouseSize{
+		{in: "X-Small", out: "XSMALL"},
+		{in: "xsmall", out: "XSMALL"},
+		{in: "2X-Large", out: "2XLARGE"},
+		{in: "XXLARGE", out: "2XLARGE"},
+		{in: "X4LARGE", out: "4XLARGE"},
+		{in: "Medium", out: "MEDIUM"},
+	}
+	for _, test := range testcases {
+		if out := normalizeWarehouseSize(test.in); out != test.out {
+			t.Errorf("failed to normalize. in: %v, expected: %v, got: %v", test.in, test.out, out)
+		}
+	}
+}
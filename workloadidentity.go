@@ -0,0 +1,383 @@
+// Copyright (c) 2018 Snowflake Computing Inc. All right reserved.
+
+package gosnowflake
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake/internal/sigv4"
+)
+
+const (
+	// workloadIdentityAudience is the audience of the workload identity attestations accepted by Snowflake.
+	workloadIdentityAudience = "snowflakecomputing.com"
+	// defaultAzureEntraResource is the Entra ID application of Snowflake used as the resource of Azure tokens.
+	defaultAzureEntraResource = "api://fd3f753b-eed3-462c-b6a7-a4b5bb650aad"
+)
+
+// metadataClient is used to get the identity tokens from the cloud metadata services.
+var metadataClient = &http.Client{Timeout: 5 * time.Second}
+
+// readDMI returns the content of a DMI file, e.g., sys_vendor, which identifies the cloud on Linux.
+var readDMI = func(name string) string {
+	b, err := ioutil.ReadFile("/sys/class/dmi/id/" + name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// AWSWorkloadIdentityProvider provides an attestation of the AWS identity of the environment. The attestation
+// is a GetCallerIdentity request signed with Signature Version 4, which Snowflake sends to AWS STS to verify the
+// identity. The credentials are taken from the first of:
+//
+//   - the environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//   - the web identity token file of AWS_WEB_IDENTITY_TOKEN_FILE exchanged for the role of AWS_ROLE_ARN, e.g., in EKS
+//   - the container credentials endpoint of AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or
+//     AWS_CONTAINER_CREDENTIALS_FULL_URI, e.g., in ECS and with EKS Pod Identity
+//   - the role of the EC2 instance by the instance metadata service (IMDSv2)
+//
+// The region is AWS_REGION or AWS_DEFAULT_REGION, or the region of the EC2 instance.
+type AWSWorkloadIdentityProvider struct {
+	MetadataEndpoint string // AWS_EC2_METADATA_SERVICE_ENDPOINT or the instance metadata service if empty
+	STSEndpoint      string // https://sts.<region>.amazonaws.com if empty, to exchange the web identity token
+}
+
+// Name returns the provider name.
+func (p *AWSWorkloadIdentityProvider) Name() string {
+	return "aws"
+}
+
+// Retrieve signs the attestation if the AWS credentials and region are found.
+func (p *AWSWorkloadIdentityProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	hasEnvKeys := os.Getenv("AWS_ACCESS_KEY_ID") != "" && os.Getenv("AWS_SECRET_ACCESS_KEY") != ""
+	hasWebIdentity := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != ""
+	hasContainer := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" ||
+		os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != ""
+	var metadataEndpoint string
+	if !hasEnvKeys && !hasWebIdentity && !hasContainer {
+		if metadataEndpoint = p.ec2MetadataEndpoint(); metadataEndpoint == "" {
+			return nil, nil
+		}
+	} else if region == "" {
+		// the variables may be set for other tools, so let the rest of the chain provide the credentials
+		glog.V(1).Infof("AWS workload identity is skipped as neither AWS_REGION nor AWS_DEFAULT_REGION is set")
+		return nil, nil
+	}
+	var creds *sigv4.Credentials
+	var err error
+	switch {
+	case hasEnvKeys:
+		creds = &sigv4.Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	case hasWebIdentity:
+		creds, err = p.webIdentityCredentials(ctx, region)
+	case hasContainer:
+		creds, err = containerCredentials(ctx)
+	default:
+		creds, region, err = ec2Credentials(ctx, metadataEndpoint, region)
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := awsAttestation(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, region, getClock().Now())
+	return &Credentials{Authenticator: authenticatorWorkloadIdentity, Provider: "AWS", Token: token}, nil
+}
+
+// awsCredentialsResponse is the credentials returned by the container credentials endpoint and the instance
+// metadata service.
+type awsCredentialsResponse struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+}
+
+func (r *awsCredentialsResponse) credentials(source string) (*sigv4.Credentials, error) {
+	if r.AccessKeyID == "" || r.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no AWS credentials are returned by %v", source)
+	}
+	return &sigv4.Credentials{AccessKeyID: r.AccessKeyID, SecretAccessKey: r.SecretAccessKey, SessionToken: r.Token}, nil
+}
+
+// webIdentityCredentials exchanges the web identity token for the temporary credentials of the role by STS
+// AssumeRoleWithWebIdentity, which needs no AWS credentials.
+func (p *AWSWorkloadIdentityProvider) webIdentityCredentials(ctx context.Context, region string) (*sigv4.Credentials, error) {
+	path := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the web identity token file %v. err: %v", path, err)
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("gosnowflake-%v", getClock().Now().UnixNano())
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(b))},
+	}
+	endpoint := p.STSEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://sts.%v.amazonaws.com", region)
+	}
+	body, err := getMetadata(ctx, "POST", endpoint+"/", map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+	}, form.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var respd struct {
+		AccessKeyID     string `xml:"AssumeRoleWithWebIdentityResult>Credentials>AccessKeyId"`
+		SecretAccessKey string `xml:"AssumeRoleWithWebIdentityResult>Credentials>SecretAccessKey"`
+		SessionToken    string `xml:"AssumeRoleWithWebIdentityResult>Credentials>SessionToken"`
+	}
+	if err = xml.Unmarshal(body, &respd); err != nil {
+		return nil, err
+	}
+	r := awsCredentialsResponse{AccessKeyID: respd.AccessKeyID, SecretAccessKey: respd.SecretAccessKey, Token: respd.SessionToken}
+	return r.credentials("AssumeRoleWithWebIdentity")
+}
+
+// containerCredentials gets the credentials of the task role from the container credentials endpoint. The full
+// URI is authorized by the token of AWS_CONTAINER_AUTHORIZATION_TOKEN or AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE.
+func containerCredentials(ctx context.Context) (*sigv4.Credentials, error) {
+	u := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	headers := map[string]string{}
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		u = "http://169.254.170.2" + relative
+	} else if path := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the container authorization token file %v. err: %v", path, err)
+		}
+		headers["Authorization"] = strings.TrimSpace(string(b))
+	} else if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		headers["Authorization"] = token
+	}
+	body, err := getMetadata(ctx, "GET", u, headers, "")
+	if err != nil {
+		return nil, err
+	}
+	var respd awsCredentialsResponse
+	if err = json.Unmarshal(body, &respd); err != nil {
+		return nil, err
+	}
+	return respd.credentials("the container credentials endpoint")
+}
+
+// ec2MetadataEndpoint returns the endpoint of the instance metadata service, or an empty string if the machine is
+// not an EC2 instance or the service is disabled.
+func (p *AWSWorkloadIdentityProvider) ec2MetadataEndpoint() string {
+	if p.MetadataEndpoint != "" {
+		return p.MetadataEndpoint
+	}
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return ""
+	}
+	if e := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); e != "" {
+		return strings.TrimSuffix(e, "/")
+	}
+	// the Nitro instances have the vendor, and the Xen instances the BIOS version, e.g., 4.11.amazon
+	if readDMI("sys_vendor") != "Amazon EC2" && !strings.Contains(readDMI("bios_version"), "amazon") {
+		return ""
+	}
+	return "http://169.254.169.254"
+}
+
+// ec2Credentials gets the credentials of the instance profile by IMDSv2, which requires a session token. The
+// region of the instance is returned if no region is given.
+func ec2Credentials(ctx context.Context, endpoint, region string) (*sigv4.Credentials, string, error) {
+	b, err := getMetadata(ctx, "PUT", endpoint+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "300",
+	}, "")
+	if err != nil {
+		return nil, "", err
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": strings.TrimSpace(string(b))}
+	if region == "" {
+		if b, err = getMetadata(ctx, "GET", endpoint+"/latest/meta-data/placement/region", headers, ""); err != nil {
+			return nil, "", err
+		}
+		region = strings.TrimSpace(string(b))
+	}
+	credentialsURL := endpoint + "/latest/meta-data/iam/security-credentials/"
+	if b, err = getMetadata(ctx, "GET", credentialsURL, headers, ""); err != nil {
+		return nil, "", err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+	if role == "" {
+		return nil, "", errors.New("no instance profile is attached to the EC2 instance")
+	}
+	if b, err = getMetadata(ctx, "GET", credentialsURL+url.PathEscape(role), headers, ""); err != nil {
+		return nil, "", err
+	}
+	var respd awsCredentialsResponse
+	if err = json.Unmarshal(b, &respd); err != nil {
+		return nil, "", err
+	}
+	creds, err := respd.credentials("the instance metadata service")
+	return creds, region, err
+}
+
+// awsAttestation returns the base64 encoded JSON of a signed STS GetCallerIdentity request.
+func awsAttestation(accessKey, secretKey, sessionToken, region string, now time.Time) string {
+	host := fmt.Sprintf("sts.%v.amazonaws.com", region)
+	u := "https://" + host + "/?Action=GetCallerIdentity&Version=2011-06-15"
+	req, _ := http.NewRequest("POST", u, nil)
+	req.Header.Set("X-Snowflake-Audience", workloadIdentityAudience)
+	creds := sigv4.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey, SessionToken: sessionToken}
+	sigv4.Sign(req, creds, region, "sts", sigv4.PayloadHash(nil), now)
+
+	attestationHeaders := map[string]string{"Host": host}
+	for k := range req.Header {
+		attestationHeaders[k] = req.Header.Get(k)
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"url":     u,
+		"method":  "POST",
+		"headers": attestationHeaders,
+	})
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// GCPWorkloadIdentityProvider provides an identity token of the service account attached to the GCP
+// resource. It is used if GCE_METADATA_HOST is set or the machine is a Google Compute Engine instance.
+type GCPWorkloadIdentityProvider struct {
+	MetadataHost string // GCE_METADATA_HOST or metadata.google.internal if empty
+}
+
+// Name returns the provider name.
+func (p *GCPWorkloadIdentityProvider) Name() string {
+	return "gcp"
+}
+
+// Retrieve gets the identity token from the metadata server.
+func (p *GCPWorkloadIdentityProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	host := p.MetadataHost
+	if host == "" {
+		host = os.Getenv("GCE_METADATA_HOST")
+	}
+	if host == "" {
+		if !strings.HasPrefix(readDMI("product_name"), "Google") {
+			return nil, nil
+		}
+		host = "metadata.google.internal"
+	}
+	u := fmt.Sprintf("http://%v/computeMetadata/v1/instance/service-accounts/default/identity?audience=%v",
+		host, url.QueryEscape(workloadIdentityAudience))
+	token, err := getMetadataToken(ctx, u, map[string]string{"Metadata-Flavor": "Google"}, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Authenticator: authenticatorWorkloadIdentity, Provider: "GCP", Token: token}, nil
+}
+
+// AzureWorkloadIdentityProvider provides an access token of the managed identity of the Azure resource. It is
+// used if IDENTITY_ENDPOINT is set, e.g., in App Service and Functions, or the machine is an Azure VM.
+type AzureWorkloadIdentityProvider struct {
+	Endpoint string // IDENTITY_ENDPOINT or the instance metadata service if empty
+	Resource string // SNOWFLAKE_ENTRA_RESOURCE or the Snowflake application if empty
+}
+
+// Name returns the provider name.
+func (p *AzureWorkloadIdentityProvider) Name() string {
+	return "azure"
+}
+
+// Retrieve gets the access token from the managed identity endpoint.
+func (p *AzureWorkloadIdentityProvider) Retrieve(ctx context.Context, cfg *Config) (*Credentials, error) {
+	resource := p.Resource
+	if resource == "" {
+		resource = os.Getenv("SNOWFLAKE_ENTRA_RESOURCE")
+	}
+	if resource == "" {
+		resource = defaultAzureEntraResource
+	}
+	endpoint := p.Endpoint
+	headers := map[string]string{"Metadata": "true"}
+	apiVersion := "2018-02-01"
+	if endpoint == "" {
+		if e := os.Getenv("IDENTITY_ENDPOINT"); e != "" {
+			endpoint = e
+			headers = map[string]string{"X-IDENTITY-HEADER": os.Getenv("IDENTITY_HEADER")}
+			apiVersion = "2019-08-01"
+		}
+	}
+	if endpoint == "" {
+		if readDMI("sys_vendor") != "Microsoft Corporation" {
+			return nil, nil
+		}
+		endpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	}
+	u := fmt.Sprintf("%v?api-version=%v&resource=%v", endpoint, apiVersion, url.QueryEscape(resource))
+	token, err := getMetadataToken(ctx, u, headers, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Authenticator: authenticatorWorkloadIdentity, Provider: "AZURE", Token: token}, nil
+}
+
+// getMetadataToken gets a token from a metadata service. If isJSON is true, the token is the access_token
+// field of the JSON response, otherwise the response body itself.
+func getMetadataToken(ctx context.Context, u string, headers map[string]string, isJSON bool) (string, error) {
+	b, err := getMetadata(ctx, "GET", u, headers, "")
+	if err != nil {
+		return "", err
+	}
+	if !isJSON {
+		return strings.TrimSpace(string(b)), nil
+	}
+	var respd struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(b, &respd); err != nil {
+		return "", err
+	}
+	return respd.AccessToken, nil
+}
+
+// getMetadata sends a request to a metadata service and returns the body of the response.
+func getMetadata(ctx context.Context, method, u string, headers map[string]string, body string) ([]byte, error) {
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// the body may echo the credentials of the request
+		return nil, errors.New(maskSecrets(fmt.Sprintf("failed to get %v. HTTP: %v, body: %v", u, resp.StatusCode, string(b))))
+	}
+	return b, nil
+}